@@ -63,14 +63,21 @@ func (b *AgentBuilder) WithMemoryMiddleware(mm *memory.MemoryMiddleware) *AgentB
 
 // WithMemory adds a memory provider and creates the middleware automatically.
 // 如果 provider 实现了 memory.UserMemoryEventSearcher（事件检索模式），
-// 同时会自动注入 search_user_memory 工具，让 Agent 主动检索更早的事件记忆。
-func (b *AgentBuilder) WithMemory(provider memory.MemoryProvider) *AgentBuilder {
-	b.middlewares = append(b.middlewares, memory.NewMemoryMiddleware(provider))
-	if searcher, ok := provider.(memory.UserMemoryEventSearcher); ok {
+// 同时会自动注入 search_user_memory 工具，让 Agent 主动检索更早的事件记忆；
+// 实现了 memory.SearchableProvider 时自动注入 search_conversation_history 工具，用于检索对话原文。
+// opts 透传给 memory.NewMemoryMiddleware，例如 memory.WithNamespace / memory.WithSharedMemory。
+func (b *AgentBuilder) WithMemory(provider memory.MemoryProvider, opts ...memory.MiddlewareOption) *AgentBuilder {
+	b.middlewares = append(b.middlewares, memory.NewMemoryMiddleware(provider, opts...))
+	if searcher, ok := memory.As[memory.UserMemoryEventSearcher](provider); ok {
 		if t, err := memorytool.SearchUserMemoryTool(searcher); err == nil && t != nil {
 			b.tools = append(b.tools, t)
 		}
 	}
+	if searchable, ok := memory.As[memory.SearchableProvider](provider); ok {
+		if t, err := memorytool.SearchConversationHistoryTool(searchable); err == nil && t != nil {
+			b.tools = append(b.tools, t)
+		}
+	}
 	return b
 }
 
@@ -14,6 +14,9 @@ import (
 	"github.com/milvus-io/milvus/client/v2/milvusclient"
 )
 
+// DefaultCollectionName is the collection used when MilvusConfig.CollectionName is empty.
+const DefaultCollectionName = "aggo_knowledge_vectors"
+
 var milvusOutputFields = []string{"id", "content", "metadata", "created_at", "updated_at"}
 
 // Milvus wraps eino-ext milvus2 indexer + retriever as a unified Database.
@@ -30,19 +33,28 @@ type MilvusConfig struct {
 	Embedding      embedding.Embedder
 }
 
+// Validate checks the client and vector dimension required by every Milvus-backed store.
+// Stores that embed documents themselves additionally require Embedding.
+func (c MilvusConfig) Validate() error {
+	if c.Client == nil {
+		return fmt.Errorf("milvus client不能为空")
+	}
+	if c.EmbeddingDim <= 0 {
+		return fmt.Errorf("embedding维度必须大于0")
+	}
+	return nil
+}
+
 // NewMilvus creates a Milvus Database instance backed by eino-ext milvus2 components.
 func NewMilvus(ctx context.Context, config MilvusConfig) (*Milvus, error) {
-	if config.Client == nil {
-		return nil, fmt.Errorf("milvus client不能为空")
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
 	if config.Embedding == nil {
 		return nil, fmt.Errorf("embedding组件不能为空")
 	}
-	if config.EmbeddingDim <= 0 {
-		return nil, fmt.Errorf("embedding维度必须大于0")
-	}
 	if config.CollectionName == "" {
-		config.CollectionName = "aggo_knowledge_vectors"
+		config.CollectionName = DefaultCollectionName
 	}
 
 	idx, err := milvus2indexer.NewIndexer(ctx, &milvus2indexer.IndexerConfig{
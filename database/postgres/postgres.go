@@ -62,7 +62,7 @@ func NewPostgres(config PostgresConfig) (*Postgres, error) {
 	if config.CollectionName == "" {
 		config.CollectionName = "aggo_knowledge_vectors"
 	}
-	if err := validatePostgresIdentifier(config.CollectionName); err != nil {
+	if err := ValidateIdentifier(config.CollectionName); err != nil {
 		return nil, err
 	}
 
@@ -94,8 +94,8 @@ func (p *Postgres) initTable() error {
 
 // Create 创建向量表
 func (p *Postgres) Create() error {
-	tableName := quotePostgresIdentifier(p.collectionName)
-	indexName := quotePostgresIdentifier(strings.ReplaceAll(p.collectionName, ".", "_") + "_vector_idx")
+	tableName := QuoteIdentifier(p.collectionName)
+	indexName := QuoteIdentifier(strings.ReplaceAll(p.collectionName, ".", "_") + "_vector_idx")
 
 	// 检查pgvector扩展是否已安装
 	var count int64
@@ -172,7 +172,7 @@ func (p *Postgres) Store(ctx context.Context, docs []*schema.Document, opts ...i
 		return nil, err
 	}
 
-	tableName := quotePostgresIdentifier(p.collectionName)
+	tableName := QuoteIdentifier(p.collectionName)
 	ids := make([]string, len(docs))
 
 	// 使用PostgreSQL的ON CONFLICT进行批量Upsert
@@ -209,7 +209,7 @@ func (p *Postgres) Store(ctx context.Context, docs []*schema.Document, opts ...i
 
 // Search 向量搜索
 func (p *Postgres) Search(ctx context.Context, queryVector []float32, limit int, filters map[string]interface{}, threshold float64) ([]*schema.Document, error) {
-	tableName := quotePostgresIdentifier(p.collectionName)
+	tableName := QuoteIdentifier(p.collectionName)
 	// 将float32向量转换为字符串格式
 	vectorStr := utils.VectorToString(queryVector)
 
@@ -335,14 +335,16 @@ func (p *Postgres) GetType() string {
 	return "Postgres"
 }
 
-func validatePostgresIdentifier(identifier string) error {
+// ValidateIdentifier 校验表名等标识符，只允许简单标识符或 schema.table 形式
+func ValidateIdentifier(identifier string) error {
 	if !postgresIdentifierPattern.MatchString(identifier) {
-		return fmt.Errorf("invalid postgres collection name %q: only simple identifiers or schema.table are allowed", identifier)
+		return fmt.Errorf("invalid postgres identifier %q: only simple identifiers or schema.table are allowed", identifier)
 	}
 	return nil
 }
 
-func quotePostgresIdentifier(identifier string) string {
+// QuoteIdentifier 为标识符的每一段加上双引号，schema.table 会分别引用
+func QuoteIdentifier(identifier string) string {
 	parts := strings.Split(identifier, ".")
 	for i, part := range parts {
 		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
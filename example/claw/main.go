@@ -151,7 +151,7 @@ func main() {
 	defer provider.Close()
 
 	// 注册 search_user_memory 工具：用于回溯更早或更宽范围的长期事件
-	if searcher, ok := any(provider).(memory.UserMemoryEventSearcher); ok {
+	if searcher, ok := memory.As[memory.UserMemoryEventSearcher](provider); ok {
 		memSearchTool, err := memorytool.SearchUserMemoryTool(searcher)
 		if err != nil {
 			log.Fatalf("创建 search_user_memory 工具失败: %v", err)
@@ -125,6 +125,18 @@ func HasFunctionToolCall(msg *schema.AgenticMessage) bool {
 	return false
 }
 
+func HasFunctionToolResult(msg *schema.AgenticMessage) bool {
+	if msg == nil {
+		return false
+	}
+	for _, block := range msg.ContentBlocks {
+		if block != nil && block.FunctionToolResult != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func InputParts(msg *schema.AgenticMessage) []schema.MessageInputPart {
 	if msg == nil {
 		return nil
@@ -0,0 +1,170 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	"github.com/CoolBanHub/aggo/memory"
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// scriptedModel stands in for a real model: it extracts a handful of facts
+// from whatever prompt it is given, and answers probe questions by echoing
+// the prompt.
+type scriptedModel struct {
+	calls int
+}
+
+var (
+	catPattern   = regexp.MustCompile(`\[(\d{4}-\d{2}-\d{2})\] I adopted a cat named (\w+)`)
+	knownCat     = regexp.MustCompile(`Cat: (\w+), adopted (\S+)`)
+	cityPattern  = regexp.MustCompile(`I live in (\w+)`)
+	movedPattern = regexp.MustCompile(`I moved to (\w+)`)
+)
+
+func (m *scriptedModel) Generate(ctx context.Context, input []*schema.AgenticMessage, opts ...model.Option) (*schema.AgenticMessage, error) {
+	m.calls++
+	text := requestText(input)
+	if strings.Contains(text, "Answer the question") {
+		return agmsg.AssistantMessage(text), nil
+	}
+	var facts []string
+	if match := catPattern.FindStringSubmatch(text); match != nil {
+		facts = append(facts, "- Cat: "+match[2]+", adopted "+match[1])
+	} else if match := knownCat.FindStringSubmatch(text); match != nil {
+		facts = append(facts, "- "+match[0])
+	}
+	if match := movedPattern.FindStringSubmatch(text); match != nil {
+		facts = append(facts, "- City: "+match[1])
+	} else if match := cityPattern.FindStringSubmatch(text); match != nil {
+		facts = append(facts, "- City: "+match[1])
+	}
+	if len(facts) == 0 {
+		return agmsg.AssistantMessage(`{"op":"noop"}`), nil
+	}
+	resp, _ := json.Marshal(map[string]string{"op": "update", "memory": "# User memory\n" + strings.Join(facts, "\n")})
+	return agmsg.AssistantMessage(string(resp)), nil
+}
+
+func (m *scriptedModel) Stream(ctx context.Context, input []*schema.AgenticMessage, opts ...model.Option) (*schema.StreamReader[*schema.AgenticMessage], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.AgenticMessage{msg}), nil
+}
+
+func testDataset() *Dataset {
+	return &Dataset{
+		Name: "pets-and-moves",
+		Conversations: []Conversation{{
+			UserID: "u1",
+			Sessions: []Session{
+				{ID: "s1", Date: "2026-03-01", Turns: []Turn{
+					{User: "I live in Hangzhou.", Assistant: "Nice city."},
+					{User: "I adopted a cat named Mochi.", Assistant: "Congratulations!"},
+				}},
+				{ID: "s2", Date: "2026-05-10", Turns: []Turn{
+					{User: "Big news: I moved to Shanghai.", Assistant: "Good luck with the move."},
+				}},
+			},
+		}},
+		Probes: []Probe{
+			{ID: "cat", UserID: "u1", Category: CategoryFact, Question: "What is my cat called?", Expected: []string{"Mochi"}},
+			{ID: "adopted", UserID: "u1", Category: CategoryTemporal, Question: "When did I adopt my cat?", Expected: []string{"2026-03-01"}},
+			{ID: "city", UserID: "u1", Category: CategoryUpdate, Question: "Which city do I live in?", Expected: []string{"Shanghai"}, Stale: []string{"Hangzhou"}},
+		},
+	}
+}
+
+func builtinTarget(cm model.AgenticModel) Target {
+	return Target{
+		Name: "builtin",
+		New: func(ctx context.Context) (memory.MemoryProvider, error) {
+			config := builtin.DefaultMemoryConfig()
+			zero := 0
+			config.DebounceWindowSeconds = &zero
+			return memory.GlobalRegistry().CreateProvider("builtin", &builtin.ProviderConfig{
+				ChatModel:    cm,
+				Storage:      storage.NewMemoryStore(),
+				MemoryConfig: config,
+			})
+		},
+	}
+}
+
+func TestRunnerRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "recordings.json")
+
+	inner := &scriptedModel{}
+	recorder, err := NewRecordingModel(path, inner, RecordModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := NewRunner(WithAnswerModel(recorder), WithSettleEachTurn(true), WithSettleTimeout(10*time.Second))
+	recorded, err := runner.Run(ctx, testDataset(), builtinTarget(recorder))
+	if err != nil {
+		t.Fatalf("record run: %v", err)
+	}
+	if recorded.Overall.Correct != 3 {
+		for _, failure := range recorded.Failures() {
+			t.Logf("%s: %s\n%s", failure.Probe.ID, failure.Reason, failure.Answer)
+		}
+		t.Fatalf("recorded run score = %+v, want 3/3", recorded.Overall)
+	}
+	if inner.calls == 0 || recorder.Len() == 0 {
+		t.Fatalf("nothing recorded (calls=%d, recordings=%d)", inner.calls, recorder.Len())
+	}
+
+	replayer, err := NewRecordingModel(path, nil, RecordModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner = NewRunner(WithAnswerModel(replayer), WithSettleEachTurn(true), WithSettleTimeout(10*time.Second))
+	replayed, err := runner.Run(ctx, testDataset(), builtinTarget(replayer))
+	if err != nil {
+		t.Fatalf("replay run: %v", err)
+	}
+	if replayed.Overall != recorded.Overall {
+		t.Fatalf("replay score %+v differs from recorded %+v", replayed.Overall, recorded.Overall)
+	}
+
+	table := FormatReports([]*Report{recorded})
+	for _, want := range []string{"| builtin | 100.0% (3/3) |", "fact", "temporal", "update"} {
+		if !strings.Contains(table, want) {
+			t.Fatalf("table missing %q:\n%s", want, table)
+		}
+	}
+}
+
+func TestRecordingModelReplayMiss(t *testing.T) {
+	replayer, err := NewRecordingModel(filepath.Join(t.TempDir(), "missing.json"), nil, RecordModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = replayer.Generate(context.Background(), []*schema.AgenticMessage{schema.UserAgenticMessage("hi")})
+	if !errors.Is(err, ErrRecordingMiss) {
+		t.Fatalf("err = %v, want ErrRecordingMiss", err)
+	}
+}
+
+func TestScoreStaleWins(t *testing.T) {
+	probe := Probe{Expected: []string{"Shanghai"}, Stale: []string{"Hangzhou"}}
+	if ok, _ := score(probe, "moved from Hangzhou to Shanghai"); ok {
+		t.Fatal("stale value should fail the probe")
+	}
+	if ok, _ := score(probe, "lives in shanghai"); !ok {
+		t.Fatal("expected answer should match case-insensitively")
+	}
+}
@@ -0,0 +1,103 @@
+// Package bench replays multi-session conversation datasets through any
+// memory.MemoryProvider, asks probe questions afterwards and scores how well
+// the retrieved context (or an answer model using it) recalls facts, reasons
+// about time and handles updated facts.
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Probe categories. Datasets may use other names; reports group by whatever
+// category string a probe carries.
+const (
+	CategoryFact     = "fact"
+	CategoryTemporal = "temporal"
+	CategoryUpdate   = "update"
+)
+
+// Dataset is a set of conversations replayed in order followed by probes.
+type Dataset struct {
+	Name          string         `json:"name"`
+	Conversations []Conversation `json:"conversations"`
+	Probes        []Probe        `json:"probes"`
+}
+
+// Conversation is one user's sessions, replayed in order.
+type Conversation struct {
+	UserID   string    `json:"userId"`
+	Sessions []Session `json:"sessions"`
+}
+
+// Session is a single conversation session.
+type Session struct {
+	ID string `json:"id"`
+	// Date, when set, is prefixed to every user turn as "[Date] " so that
+	// providers storing wall-clock time still see when the session happened.
+	Date  string `json:"date,omitempty"`
+	Turns []Turn `json:"turns"`
+}
+
+// Turn is one user message and the assistant reply.
+type Turn struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+}
+
+// Probe is a question asked after the replay.
+type Probe struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	// SessionID the question is asked in. Empty uses a fresh session, which
+	// measures cross-session recall.
+	SessionID string `json:"sessionId,omitempty"`
+	Category  string `json:"category"`
+	Question  string `json:"question"`
+	// Expected lists acceptable answers; the probe passes when any of them
+	// appears (case-insensitive) in the scored text.
+	Expected []string `json:"expected"`
+	// Stale lists superseded values; the probe fails when any of them
+	// appears, which is how update handling is scored.
+	Stale []string `json:"stale,omitempty"`
+}
+
+// LoadDataset reads a JSON dataset file.
+func LoadDataset(path string) (*Dataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("bench: read dataset: %w", err)
+	}
+	var dataset Dataset
+	if err := json.Unmarshal(data, &dataset); err != nil {
+		return nil, fmt.Errorf("bench: parse dataset %s: %w", path, err)
+	}
+	if err := dataset.Validate(); err != nil {
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+// Validate checks that every conversation, session and probe is addressable.
+func (d *Dataset) Validate() error {
+	for i, conv := range d.Conversations {
+		if conv.UserID == "" {
+			return fmt.Errorf("bench: conversation %d has no userId", i)
+		}
+		for j, session := range conv.Sessions {
+			if session.ID == "" {
+				return fmt.Errorf("bench: conversation %d session %d has no id", i, j)
+			}
+		}
+	}
+	for i, probe := range d.Probes {
+		if probe.UserID == "" || probe.Question == "" {
+			return fmt.Errorf("bench: probe %d needs userId and question", i)
+		}
+		if len(probe.Expected) == 0 {
+			return fmt.Errorf("bench: probe %d has no expected answers", i)
+		}
+	}
+	return nil
+}
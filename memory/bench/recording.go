@@ -0,0 +1,187 @@
+package bench
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// RecordMode controls how a RecordingModel treats requests.
+type RecordMode int
+
+const (
+	// RecordModeReplay only serves recorded responses; a miss is an error.
+	// No inner model is needed, so runs are fully offline.
+	RecordModeReplay RecordMode = iota
+	// RecordModeRecord always calls the inner model and overwrites recordings.
+	RecordModeRecord
+	// RecordModeAuto replays when a recording exists and records otherwise.
+	RecordModeAuto
+)
+
+// ErrRecordingMiss is returned in replay mode for an unrecorded request.
+var ErrRecordingMiss = errors.New("bench: no recorded response for request")
+
+// timestampPattern matches date-times such as the <current_time> context the
+// builtin prompts carry, which would otherwise make every request unique.
+var timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}(:\d{2}(\.\d+)?)?( ?(Z|[+-]\d{2}:?\d{2}))?`)
+
+// DefaultKeyNormalizer replaces date-times with a placeholder before the
+// request is hashed. Plain dates are kept because datasets rely on them.
+func DefaultKeyNormalizer(request string) string {
+	return timestampPattern.ReplaceAllString(request, "<time>")
+}
+
+// RecordingOption configures a RecordingModel.
+type RecordingOption func(*RecordingModel)
+
+// WithKeyNormalizer sets the function applied to the request text before it
+// is hashed into a recording key. Defaults to DefaultKeyNormalizer.
+func WithKeyNormalizer(normalize func(string) string) RecordingOption {
+	return func(m *RecordingModel) {
+		if normalize != nil {
+			m.normalize = normalize
+		}
+	}
+}
+
+type recording struct {
+	Request  string `json:"request"`
+	Response string `json:"response"`
+}
+
+// RecordingModel wraps an AgenticModel and records text responses keyed by a
+// hash of the request messages, so a benchmark recorded once against a real
+// model can be replayed offline and deterministically. Only text content is
+// recorded; tool calls are not supported.
+type RecordingModel struct {
+	inner     model.AgenticModel
+	path      string
+	mode      RecordMode
+	normalize func(string) string
+
+	mu         sync.Mutex
+	recordings map[string]recording
+}
+
+// NewRecordingModel loads recordings from path (a missing file is treated as
+// empty). inner may be nil in RecordModeReplay.
+func NewRecordingModel(path string, inner model.AgenticModel, mode RecordMode, opts ...RecordingOption) (*RecordingModel, error) {
+	if path == "" {
+		return nil, errors.New("bench: recording path is required")
+	}
+	if inner == nil && mode != RecordModeReplay {
+		return nil, errors.New("bench: inner model is required unless replaying")
+	}
+	m := &RecordingModel{inner: inner, path: path, mode: mode, normalize: DefaultKeyNormalizer, recordings: make(map[string]recording)}
+	for _, opt := range opts {
+		opt(m)
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("bench: read recordings: %w", err)
+	default:
+		if err := json.Unmarshal(data, &m.recordings); err != nil {
+			return nil, fmt.Errorf("bench: parse recordings %s: %w", path, err)
+		}
+	}
+	return m, nil
+}
+
+// Generate implements model.AgenticModel.
+func (m *RecordingModel) Generate(ctx context.Context, input []*schema.AgenticMessage, opts ...model.Option) (*schema.AgenticMessage, error) {
+	request := m.normalize(requestText(input))
+	key := requestKey(request)
+
+	if m.mode != RecordModeRecord {
+		m.mu.Lock()
+		rec, ok := m.recordings[key]
+		m.mu.Unlock()
+		if ok {
+			return agmsg.AssistantMessage(rec.Response), nil
+		}
+		if m.mode == RecordModeReplay {
+			return nil, fmt.Errorf("%w (key %s)", ErrRecordingMiss, key[:12])
+		}
+	}
+
+	resp, err := m.inner.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.recordings[key] = recording{Request: request, Response: agmsg.Text(resp)}
+	err = m.saveLocked()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Stream implements model.AgenticModel by streaming the Generate result as a
+// single chunk.
+func (m *RecordingModel) Stream(ctx context.Context, input []*schema.AgenticMessage, opts ...model.Option) (*schema.StreamReader[*schema.AgenticMessage], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.AgenticMessage{msg}), nil
+}
+
+// Len returns the number of recorded responses.
+func (m *RecordingModel) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.recordings)
+}
+
+func (m *RecordingModel) saveLocked() error {
+	data, err := json.MarshalIndent(m.recordings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("bench: encode recordings: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return fmt.Errorf("bench: save recordings: %w", err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("bench: save recordings: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("bench: save recordings: %w", err)
+	}
+	return nil
+}
+
+// requestText renders the request as role-tagged text, which is both the
+// recording key input and a human-readable record of the prompt.
+func requestText(input []*schema.AgenticMessage) string {
+	var b strings.Builder
+	for _, msg := range input {
+		if msg == nil {
+			continue
+		}
+		b.WriteString("[" + string(msg.Role) + "]\n" + agmsg.Text(msg) + "\n")
+	}
+	return b.String()
+}
+
+func requestKey(request string) string {
+	sum := sha256.Sum256([]byte(request))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,167 @@
+package bench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProbeResult is the outcome of one probe.
+type ProbeResult struct {
+	Probe   Probe
+	Correct bool
+	// Reason explains a failure.
+	Reason string
+	// Answer is the answer model's reply, empty when scoring context.
+	Answer          string
+	ContextTokens   int
+	RetrieveLatency time.Duration
+	AnswerLatency   time.Duration
+}
+
+// CategoryScore aggregates the probes of one category.
+type CategoryScore struct {
+	Total   int
+	Correct int
+}
+
+// Accuracy returns Correct/Total, or 0 for an empty category.
+func (s CategoryScore) Accuracy() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Correct) / float64(s.Total)
+}
+
+// Report summarizes one target's run over a dataset.
+type Report struct {
+	Target  string
+	Dataset string
+
+	Overall    CategoryScore
+	Categories map[string]*CategoryScore
+	Results    []*ProbeResult
+
+	AvgContextTokens float64
+	MaxContextTokens int
+
+	RetrieveP50 time.Duration
+	RetrieveP95 time.Duration
+	MemorizeP50 time.Duration
+	MemorizeP95 time.Duration
+	// ReplayDuration covers memorizing every turn and waiting for the provider to settle.
+	ReplayDuration time.Duration
+
+	MemorizeLatencies []time.Duration
+}
+
+func newReport(target, dataset string) *Report {
+	return &Report{Target: target, Dataset: dataset, Categories: make(map[string]*CategoryScore)}
+}
+
+func (r *Report) add(result *ProbeResult) {
+	r.Results = append(r.Results, result)
+	category := r.Categories[result.Probe.Category]
+	if category == nil {
+		category = &CategoryScore{}
+		r.Categories[result.Probe.Category] = category
+	}
+	category.Total++
+	r.Overall.Total++
+	if result.Correct {
+		category.Correct++
+		r.Overall.Correct++
+	}
+}
+
+func (r *Report) finish() {
+	retrieve := make([]time.Duration, 0, len(r.Results))
+	tokens := 0
+	for _, result := range r.Results {
+		retrieve = append(retrieve, result.RetrieveLatency)
+		tokens += result.ContextTokens
+		if result.ContextTokens > r.MaxContextTokens {
+			r.MaxContextTokens = result.ContextTokens
+		}
+	}
+	if len(r.Results) > 0 {
+		r.AvgContextTokens = float64(tokens) / float64(len(r.Results))
+	}
+	r.RetrieveP50, r.RetrieveP95 = percentile(retrieve, 0.5), percentile(retrieve, 0.95)
+	r.MemorizeP50, r.MemorizeP95 = percentile(r.MemorizeLatencies, 0.5), percentile(r.MemorizeLatencies, 0.95)
+}
+
+// Failures returns the probes that did not pass.
+func (r *Report) Failures() []*ProbeResult {
+	var failures []*ProbeResult
+	for _, result := range r.Results {
+		if !result.Correct {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+func percentile(values []time.Duration, p float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(p*float64(len(sorted)-1)+0.5)]
+}
+
+// FormatReports renders reports as a Markdown table, one row per target with
+// a column per probe category.
+func FormatReports(reports []*Report) string {
+	categorySet := make(map[string]struct{})
+	for _, report := range reports {
+		for category := range report.Categories {
+			categorySet[category] = struct{}{}
+		}
+	}
+	categories := make([]string, 0, len(categorySet))
+	for category := range categorySet {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	var b strings.Builder
+	b.WriteString("| target | accuracy |")
+	for _, category := range categories {
+		b.WriteString(" " + category + " |")
+	}
+	b.WriteString(" avg ctx tokens | max ctx tokens | retrieve p50 | retrieve p95 | memorize p50 | memorize p95 |\n|---|---|")
+	for range categories {
+		b.WriteString("---|")
+	}
+	b.WriteString("---|---|---|---|---|---|\n")
+
+	for _, report := range reports {
+		fmt.Fprintf(&b, "| %s | %s |", report.Target, formatScore(report.Overall))
+		for _, category := range categories {
+			score := CategoryScore{}
+			if s := report.Categories[category]; s != nil {
+				score = *s
+			}
+			fmt.Fprintf(&b, " %s |", formatScore(score))
+		}
+		fmt.Fprintf(&b, " %.0f | %d | %s | %s | %s | %s |\n",
+			report.AvgContextTokens, report.MaxContextTokens,
+			formatLatency(report.RetrieveP50), formatLatency(report.RetrieveP95),
+			formatLatency(report.MemorizeP50), formatLatency(report.MemorizeP95))
+	}
+	return b.String()
+}
+
+func formatScore(score CategoryScore) string {
+	if score.Total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%% (%d/%d)", score.Accuracy()*100, score.Correct, score.Total)
+}
+
+func formatLatency(d time.Duration) string {
+	return d.Round(10 * time.Microsecond).String()
+}
@@ -0,0 +1,295 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	"github.com/CoolBanHub/aggo/memory"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const defaultSettleTimeout = 5 * time.Minute
+
+// Target is one provider configuration under test. New is called once per
+// run so every run starts from empty memory.
+type Target struct {
+	Name string
+	New  func(ctx context.Context) (memory.MemoryProvider, error)
+	// Settle waits for the provider's background processing (memory
+	// extraction, summaries) to finish before probing. When nil, providers
+	// exposing WaitIdle(ctx) error (such as builtin) are waited on and other
+	// providers are probed immediately; remote providers like mem0 or memu
+	// usually need a Settle that polls or sleeps.
+	Settle func(ctx context.Context, provider memory.MemoryProvider) error
+}
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithAnswerModel makes the runner ask the model each probe question with the
+// retrieved memory and score its answer. Without it the retrieved context
+// itself is scored, which needs no model at all. Wrap the model in a
+// RecordingModel to keep runs offline and repeatable.
+func WithAnswerModel(cm model.AgenticModel) Option {
+	return func(r *Runner) {
+		r.answerModel = cm
+	}
+}
+
+// WithTokenCounter sets how injected context is counted. Defaults to a rough
+// estimate of one token per CJK character and four other characters.
+func WithTokenCounter(counter func(string) int) Option {
+	return func(r *Runner) {
+		if counter != nil {
+			r.countTokens = counter
+		}
+	}
+}
+
+// WithRetrieveLimit sets RetrieveRequest.Limit for probes; 0 leaves it to the provider.
+func WithRetrieveLimit(limit int) Option {
+	return func(r *Runner) {
+		r.retrieveLimit = limit
+	}
+}
+
+// WithSettleTimeout bounds how long a target may take to settle. Defaults to 5 minutes.
+func WithSettleTimeout(timeout time.Duration) Option {
+	return func(r *Runner) {
+		if timeout > 0 {
+			r.settleTimeout = timeout
+		}
+	}
+}
+
+// WithSettleEachTurn waits for the target to settle after every memorized
+// turn instead of once after the replay. Background model calls then happen in
+// a fixed order with fixed inputs, which recordings need to replay reliably.
+// Memorize latencies exclude the wait.
+func WithSettleEachTurn(enabled bool) Option {
+	return func(r *Runner) {
+		r.settleEachTurn = enabled
+	}
+}
+
+// Runner replays datasets through targets and scores probes.
+type Runner struct {
+	answerModel    model.AgenticModel
+	countTokens    func(string) int
+	retrieveLimit  int
+	settleTimeout  time.Duration
+	settleEachTurn bool
+}
+
+// NewRunner creates a Runner.
+func NewRunner(opts ...Option) *Runner {
+	r := &Runner{countTokens: EstimateTokens, settleTimeout: defaultSettleTimeout}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Compare runs the dataset against every target in turn.
+func (r *Runner) Compare(ctx context.Context, dataset *Dataset, targets ...Target) ([]*Report, error) {
+	reports := make([]*Report, 0, len(targets))
+	for _, target := range targets {
+		report, err := r.Run(ctx, dataset, target)
+		if err != nil {
+			return reports, fmt.Errorf("bench: target %s: %w", target.Name, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// Run replays the dataset through a fresh provider from target, waits for it
+// to settle and scores every probe.
+func (r *Runner) Run(ctx context.Context, dataset *Dataset, target Target) (*Report, error) {
+	if dataset == nil {
+		return nil, errors.New("bench: dataset is nil")
+	}
+	if err := dataset.Validate(); err != nil {
+		return nil, err
+	}
+	if target.New == nil {
+		return nil, errors.New("bench: target has no provider constructor")
+	}
+	provider, err := target.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("bench: create provider: %w", err)
+	}
+	defer provider.Close()
+
+	report := newReport(target.Name, dataset.Name)
+	started := time.Now()
+	if err := r.replay(ctx, target, provider, dataset, report); err != nil {
+		return nil, err
+	}
+	if err := r.settle(ctx, target, provider); err != nil {
+		return nil, fmt.Errorf("bench: settle: %w", err)
+	}
+	report.ReplayDuration = time.Since(started)
+
+	for _, probe := range dataset.Probes {
+		result, err := r.probe(ctx, provider, probe)
+		if err != nil {
+			return nil, fmt.Errorf("bench: probe %s: %w", probe.ID, err)
+		}
+		report.add(result)
+	}
+	report.finish()
+	return report, nil
+}
+
+func (r *Runner) replay(ctx context.Context, target Target, provider memory.MemoryProvider, dataset *Dataset, report *Report) error {
+	for _, conv := range dataset.Conversations {
+		for _, session := range conv.Sessions {
+			for _, turn := range session.Turns {
+				user := turn.User
+				if session.Date != "" {
+					user = "[" + session.Date + "] " + user
+				}
+				messages := []*schema.AgenticMessage{schema.UserAgenticMessage(user)}
+				if turn.Assistant != "" {
+					messages = append(messages, agmsg.AssistantMessage(turn.Assistant))
+				}
+
+				started := time.Now()
+				err := provider.Memorize(ctx, &memory.MemorizeRequest{UserID: conv.UserID, SessionID: session.ID, Messages: messages})
+				if err != nil {
+					return fmt.Errorf("bench: memorize %s/%s: %w", conv.UserID, session.ID, err)
+				}
+				report.MemorizeLatencies = append(report.MemorizeLatencies, time.Since(started))
+				if r.settleEachTurn {
+					if err := r.settle(ctx, target, provider); err != nil {
+						return fmt.Errorf("bench: settle %s/%s: %w", conv.UserID, session.ID, err)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Runner) settle(ctx context.Context, target Target, provider memory.MemoryProvider) error {
+	ctx, cancel := context.WithTimeout(ctx, r.settleTimeout)
+	defer cancel()
+	if target.Settle != nil {
+		return target.Settle(ctx, provider)
+	}
+	if waiter, ok := provider.(interface{ WaitIdle(context.Context) error }); ok {
+		return waiter.WaitIdle(ctx)
+	}
+	return nil
+}
+
+func (r *Runner) probe(ctx context.Context, provider memory.MemoryProvider, probe Probe) (*ProbeResult, error) {
+	sessionID := probe.SessionID
+	if sessionID == "" {
+		sessionID = "bench-probe-" + probe.ID
+	}
+	question := schema.UserAgenticMessage(probe.Question)
+
+	started := time.Now()
+	retrieved, err := provider.Retrieve(ctx, &memory.RetrieveRequest{
+		UserID:    probe.UserID,
+		SessionID: sessionID,
+		Messages:  []*schema.AgenticMessage{question},
+		Limit:     r.retrieveLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("retrieve: %w", err)
+	}
+	result := &ProbeResult{Probe: probe, RetrieveLatency: time.Since(started)}
+
+	injected := contextText(retrieved)
+	result.ContextTokens = r.countTokens(injected)
+	scored := injected
+	if r.answerModel != nil {
+		started = time.Now()
+		answer, err := r.answerModel.Generate(ctx, answerPrompt(retrieved, question))
+		if err != nil {
+			return nil, fmt.Errorf("answer: %w", err)
+		}
+		result.AnswerLatency = time.Since(started)
+		result.Answer = agmsg.Text(answer)
+		scored = result.Answer
+	}
+	result.Correct, result.Reason = score(probe, scored)
+	return result, nil
+}
+
+// contextText flattens everything a provider would inject into the prompt.
+func contextText(result *memory.RetrieveResult) string {
+	if result == nil {
+		return ""
+	}
+	var lines []string
+	for _, group := range [][]*schema.AgenticMessage{result.SystemMessages, result.ContextMessages, result.HistoryMessages} {
+		for _, msg := range group {
+			if text := agmsg.Text(msg); text != "" {
+				lines = append(lines, text)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// answerPrompt assembles the messages the way the memory middleware does:
+// system and history messages first, then the question with the retrieved
+// context appended.
+func answerPrompt(result *memory.RetrieveResult, question *schema.AgenticMessage) []*schema.AgenticMessage {
+	messages := []*schema.AgenticMessage{schema.SystemAgenticMessage("Answer the question using only the memory and conversation provided. Reply briefly.")}
+	if result == nil {
+		return append(messages, question)
+	}
+	messages = append(messages, result.SystemMessages...)
+	messages = append(messages, result.HistoryMessages...)
+	var extra []string
+	for _, msg := range result.ContextMessages {
+		if text := agmsg.Text(msg); text != "" {
+			extra = append(extra, text)
+		}
+	}
+	if len(extra) == 0 {
+		return append(messages, question)
+	}
+	return append(messages, agmsg.AppendUserText(agmsg.Clone(question), "\n\n"+strings.Join(extra, "\n\n")))
+}
+
+// score checks the text against the probe's expected and stale answers.
+func score(probe Probe, text string) (bool, string) {
+	lower := strings.ToLower(text)
+	for _, stale := range probe.Stale {
+		if stale != "" && strings.Contains(lower, strings.ToLower(stale)) {
+			return false, "stale value " + stale
+		}
+	}
+	for _, expected := range probe.Expected {
+		if expected != "" && strings.Contains(lower, strings.ToLower(expected)) {
+			return true, ""
+		}
+	}
+	return false, "missing expected answer"
+}
+
+// EstimateTokens roughly counts tokens: one per CJK character and one per
+// four other characters.
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
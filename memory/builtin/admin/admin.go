@@ -0,0 +1,468 @@
+// Package admin 提供 builtin 记忆的管理 HTTP 接口，用于在不直接访问数据库的情况下查看和修正记忆。
+//
+// 接口本身不做鉴权，对外暴露前务必通过 WithMiddleware 挂载 BearerTokenAuth、BasicAuth 或自定义鉴权中间件。
+//
+//	GET    /stats                                               任务队列与管理器统计
+//	POST   /cleanup                                             立即执行清理
+//	POST   /retention                                           立即执行保留策略 ?dryRun=true 只返回将要删除的数据
+//	GET    /users/{userID}/memory                               用户记忆文档
+//	PUT    /users/{userID}/memory                               覆盖用户记忆 {"memory","reason","force","version"}
+//	DELETE /users/{userID}/memory                               清空用户记忆
+//	GET    /users/{userID}/memory/revisions                     用户记忆历史版本 ?limit=
+//	POST   /users/{userID}/memory/revisions/{revisionID}/restore 恢复到指定版本
+//	GET    /users/{userID}/events                               用户事件 ?q=&type=&match=&since=&until=&limit=&includeSuperseded=
+//	DELETE /users/{userID}/events/{eventID}                     删除事件
+//	GET    /users/{userID}/sessions                             会话列表 ?includeArchived=&archivedOnly=&limit=&offset=
+//	GET    /users/{userID}/sessions/{sessionID}                 会话元数据
+//	DELETE /users/{userID}/sessions/{sessionID}                 删除会话
+//	GET    /users/{userID}/sessions/{sessionID}/messages        会话消息 ?limit=
+//	GET    /users/{userID}/sessions/{sessionID}/summary         会话摘要
+//	POST   /users/{userID}/sessions/{sessionID}/summary/regenerate 重新生成会话摘要
+//
+// /users/{userID}/memory* 与 /users/{userID}/events* 接口支持 ?namespace= 参数，读写指定命名空间下的用户记忆，
+// 为空时为默认命名空间。
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/gookit/slog"
+)
+
+const (
+	defaultListLimit = 50
+	maxRequestBody   = 1 << 20
+)
+
+// Middleware 包装 http.Handler，用于鉴权、审计日志等
+type Middleware func(http.Handler) http.Handler
+
+// Option 配置 Handler
+type Option func(*Handler)
+
+// WithMiddleware 挂载中间件，按传入顺序由外向内执行
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(h *Handler) {
+		h.middlewares = append(h.middlewares, middlewares...)
+	}
+}
+
+// Handler builtin 记忆管理接口
+type Handler struct {
+	manager     *builtin.MemoryManager
+	middlewares []Middleware
+	handler     http.Handler
+}
+
+// NewHandler 创建管理接口。挂载到子路径时配合 http.StripPrefix 使用。
+func NewHandler(manager *builtin.MemoryManager, opts ...Option) (*Handler, error) {
+	if manager == nil {
+		return nil, errors.New("memory manager is required")
+	}
+	h := &Handler{manager: manager}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", h.stats)
+	mux.HandleFunc("POST /cleanup", h.cleanup)
+	mux.HandleFunc("POST /retention", h.retention)
+	mux.HandleFunc("GET /users/{userID}/memory", h.getUserMemory)
+	mux.HandleFunc("PUT /users/{userID}/memory", h.updateUserMemory)
+	mux.HandleFunc("DELETE /users/{userID}/memory", h.clearUserMemory)
+	mux.HandleFunc("GET /users/{userID}/memory/revisions", h.listRevisions)
+	mux.HandleFunc("POST /users/{userID}/memory/revisions/{revisionID}/restore", h.restoreRevision)
+	mux.HandleFunc("GET /users/{userID}/events", h.listEvents)
+	mux.HandleFunc("DELETE /users/{userID}/events/{eventID}", h.deleteEvent)
+	mux.HandleFunc("GET /users/{userID}/sessions", h.listSessions)
+	mux.HandleFunc("GET /users/{userID}/sessions/{sessionID}", h.getSession)
+	mux.HandleFunc("DELETE /users/{userID}/sessions/{sessionID}", h.deleteSession)
+	mux.HandleFunc("GET /users/{userID}/sessions/{sessionID}/messages", h.listMessages)
+	mux.HandleFunc("GET /users/{userID}/sessions/{sessionID}/summary", h.getSummary)
+	mux.HandleFunc("POST /users/{userID}/sessions/{sessionID}/summary/regenerate", h.regenerateSummary)
+
+	var handler http.Handler = mux
+	for i := len(h.middlewares) - 1; i >= 0; i-- {
+		handler = h.middlewares[i](handler)
+	}
+	h.handler = handler
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.ServeHTTP(w, r)
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	memoryStats := h.manager.GetMemoryStats()
+	// 配置中可能包含无法序列化的自定义组件（如 Embedder），此时省略配置
+	if _, err := json.Marshal(memoryStats["config"]); err != nil {
+		delete(memoryStats, "config")
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"taskQueue": h.manager.GetTaskQueueStats(),
+		"memory":    memoryStats,
+	})
+}
+
+func (h *Handler) cleanup(w http.ResponseWriter, r *http.Request) {
+	if err := h.manager.ForceCleanupNow(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+func (h *Handler) retention(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := queryBool(r, "dryRun")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	report, err := h.manager.ApplyRetention(r.Context(), dryRun)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	errs := make([]string, len(report.Errors))
+	for i, e := range report.Errors {
+		errs[i] = e.Error()
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"report": report, "errors": errs})
+}
+
+func (h *Handler) getUserMemory(w http.ResponseWriter, r *http.Request) {
+	ctx := namespaceContext(r)
+	memory, err := h.manager.GetUserMemory(ctx, r.PathValue("userID"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if memory == nil {
+		writeError(w, http.StatusNotFound, errors.New("用户记忆不存在"))
+		return
+	}
+	writeJSON(w, http.StatusOK, memory)
+}
+
+type updateUserMemoryRequest struct {
+	Memory string `json:"memory"`
+	Reason string `json:"reason"`
+	// Force 跳过缩减检查
+	Force bool `json:"force"`
+	// Version 编辑时看到的记忆版本，记忆已被其他写入修改时返回 409；为空时直接覆盖
+	Version *int64 `json:"version,omitempty"`
+}
+
+func (h *Handler) updateUserMemory(w http.ResponseWriter, r *http.Request) {
+	ctx := namespaceContext(r)
+	var req updateUserMemoryRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(req.Memory) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("记忆内容不能为空，清空请使用 DELETE"))
+		return
+	}
+
+	userID := r.PathValue("userID")
+	revision, err := h.manager.UpdateUserMemory(ctx, userID, &builtin.UserMemoryUpdate{
+		Memory:      req.Memory,
+		Source:      builtin.UserMemorySourceManual,
+		Force:       req.Force,
+		Reason:      req.Reason,
+		BaseVersion: req.Version,
+	})
+	if errors.Is(err, builtin.ErrUserMemoryShrinkRejected) {
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "revision": revision})
+		return
+	}
+	if errors.Is(err, builtin.ErrUserMemoryConflict) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	memory, err := h.manager.GetUserMemory(ctx, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"memory": memory, "revision": revision})
+}
+
+func (h *Handler) clearUserMemory(w http.ResponseWriter, r *http.Request) {
+	ctx := namespaceContext(r)
+	if err := h.manager.ClearUserMemory(ctx, r.PathValue("userID")); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listRevisions(w http.ResponseWriter, r *http.Request) {
+	ctx := namespaceContext(r)
+	limit, err := queryInt(r, "limit", defaultListLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	revisions, err := h.manager.ListUserMemoryRevisions(ctx, r.PathValue("userID"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeList(w, revisions)
+}
+
+func (h *Handler) restoreRevision(w http.ResponseWriter, r *http.Request) {
+	ctx := namespaceContext(r)
+	revision, err := h.manager.RestoreUserMemoryRevision(ctx, r.PathValue("userID"), r.PathValue("revisionID"))
+	if errors.Is(err, builtin.ErrUserMemoryRevisionNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, revision)
+}
+
+func (h *Handler) listEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := namespaceContext(r)
+	userID := r.PathValue("userID")
+	query := r.URL.Query()
+	limit, err := queryInt(r, "limit", defaultListLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	filter := &builtin.UserMemoryEventQuery{
+		UserID: userID,
+		Type:   query.Get("type"),
+		Match:  query.Get("match"),
+		Limit:  limit,
+	}
+	for _, keyword := range strings.Split(query.Get("q"), ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			filter.Keywords = append(filter.Keywords, keyword)
+		}
+	}
+	if filter.Since, err = queryTime(r, "since"); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if filter.Until, err = queryTime(r, "until"); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if filter.IncludeSuperseded, err = queryBool(r, "includeSuperseded"); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var events []*builtin.UserMemoryEvent
+	if len(filter.Keywords) == 0 && filter.Type == "" && filter.Since == nil && filter.Until == nil && !filter.IncludeSuperseded {
+		events, err = h.manager.ListRecentUserMemoryEvents(ctx, userID, limit)
+	} else {
+		events, err = h.manager.SearchUserMemoryEvents(ctx, filter)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeList(w, events)
+}
+
+func (h *Handler) deleteEvent(w http.ResponseWriter, r *http.Request) {
+	ctx := namespaceContext(r)
+	err := h.manager.DeleteUserMemoryEvent(ctx, r.PathValue("userID"), r.PathValue("eventID"))
+	if errors.Is(err, builtin.ErrUserMemoryEventNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listSessions(w http.ResponseWriter, r *http.Request) {
+	query := &builtin.SessionQuery{UserID: r.PathValue("userID")}
+	var err error
+	if query.IncludeArchived, err = queryBool(r, "includeArchived"); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if query.ArchivedOnly, err = queryBool(r, "archivedOnly"); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if query.Limit, err = queryInt(r, "limit", defaultListLimit); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if query.Offset, err = queryInt(r, "offset", 0); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	sessions, err := h.manager.ListSessions(r.Context(), query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeList(w, sessions)
+}
+
+func (h *Handler) getSession(w http.ResponseWriter, r *http.Request) {
+	session, err := h.manager.GetSession(r.Context(), r.PathValue("sessionID"), r.PathValue("userID"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if session == nil {
+		writeError(w, http.StatusNotFound, errors.New("会话不存在"))
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+func (h *Handler) deleteSession(w http.ResponseWriter, r *http.Request) {
+	if err := h.manager.DeleteSession(r.Context(), r.PathValue("sessionID"), r.PathValue("userID")); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listMessages(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	messages, err := h.manager.GetConversationMessages(r.Context(), r.PathValue("sessionID"), r.PathValue("userID"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeList(w, messages)
+}
+
+func (h *Handler) getSummary(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.manager.GetSessionSummary(r.Context(), r.PathValue("sessionID"), r.PathValue("userID"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if summary == nil {
+		writeError(w, http.StatusNotFound, errors.New("会话摘要不存在"))
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (h *Handler) regenerateSummary(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.manager.RegenerateSessionSummary(r.Context(), r.PathValue("sessionID"), r.PathValue("userID"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if summary == nil {
+		writeError(w, http.StatusNotFound, errors.New("会话没有消息"))
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// namespaceContext 返回携带 ?namespace= 指定的记忆命名空间的请求 ctx
+func namespaceContext(r *http.Request) context.Context {
+	return builtin.WithNamespace(r.Context(), r.URL.Query().Get("namespace"))
+}
+
+func decodeJSON(r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("请求体不是合法的 JSON: %w", err)
+	}
+	return nil
+}
+
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("参数 %s 必须是非负整数", name)
+	}
+	return v, nil
+}
+
+func queryBool(r *http.Request, name string) (bool, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return false, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("参数 %s 必须是布尔值", name)
+	}
+	return v, nil
+}
+
+// queryTime 解析 RFC3339 时间或 2006-01-02 日期
+func queryTime(r *http.Request, name string) (*time.Time, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("参数 %s 必须是 RFC3339 时间或 YYYY-MM-DD 日期", name)
+}
+
+// writeList 输出列表，nil 输出为 []
+func writeList[T any](w http.ResponseWriter, items []T) {
+	if items == nil {
+		items = []T{}
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		slog.Errorf("admin: 序列化响应失败: %v", err)
+		status = http.StatusInternalServerError
+		body, _ = json.Marshal(map[string]string{"error": "序列化响应失败"})
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
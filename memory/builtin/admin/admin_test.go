@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+type staticModel struct{}
+
+func (staticModel) Generate(ctx context.Context, input []*schema.AgenticMessage, opts ...einomodel.Option) (*schema.AgenticMessage, error) {
+	return agmsg.AssistantMessage("用户在咨询退款"), nil
+}
+
+func (staticModel) Stream(ctx context.Context, input []*schema.AgenticMessage, opts ...einomodel.Option) (*schema.StreamReader[*schema.AgenticMessage], error) {
+	return schema.StreamReaderFromArray([]*schema.AgenticMessage{agmsg.AssistantMessage("用户在咨询退款")}), nil
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	config := builtin.DefaultMemoryConfig()
+	config.EnableUserMemories = false
+	config.Retention = &builtin.RetentionConfig{
+		Policy: builtin.StaticRetentionPolicy(builtin.RetentionRule{MaxMessagesPerSession: 1}),
+		DryRun: true,
+	}
+	manager, err := builtin.NewMemoryManager(staticModel{}, storage.NewMemoryStore(), config)
+	if err != nil {
+		t.Fatalf("new manager err: %v", err)
+	}
+	defer manager.Close()
+	if err := manager.ProcessUserMessage(ctx, "u1", "s1", "退款失败了", nil); err != nil {
+		t.Fatalf("process user message err: %v", err)
+	}
+	if err := manager.ProcessAssistantMessage(ctx, "u1", "s1", "我看看"); err != nil {
+		t.Fatalf("process assistant message err: %v", err)
+	}
+
+	handler, err := NewHandler(manager, WithMiddleware(BearerTokenAuth("secret")))
+	if err != nil {
+		t.Fatalf("new handler err: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	call := func(method, path, body, token string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s err: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	if status, _ := call("GET", "/stats", "", "wrong"); status != http.StatusUnauthorized {
+		t.Fatalf("wrong token status = %d", status)
+	}
+	if status, body := call("GET", "/stats", "", "secret"); status != http.StatusOK || !strings.Contains(body, `"taskQueue"`) {
+		t.Fatalf("stats = %d %s", status, body)
+	}
+	if status, body := call("GET", "/users/u1/memory", "", "secret"); status != http.StatusNotFound {
+		t.Fatalf("missing memory = %d %s", status, body)
+	}
+	if status, body := call("PUT", "/users/u1/memory", `{"memory":"## 基础信息\n- 住在上海","reason":"客服修正"}`, "secret"); status != http.StatusOK {
+		t.Fatalf("update memory = %d %s", status, body)
+	}
+	if status, body := call("GET", "/users/u1/memory", "", "secret"); status != http.StatusOK || !strings.Contains(body, "住在上海") {
+		t.Fatalf("get memory = %d %s", status, body)
+	}
+	// 不同命名空间下的记忆互不影响
+	if status, body := call("GET", "/users/u1/memory?namespace=agent-b", "", "secret"); status != http.StatusNotFound {
+		t.Fatalf("memory in another namespace = %d %s", status, body)
+	}
+	if status, body := call("PUT", "/users/u1/memory?namespace=agent-b", `{"memory":"## 基础信息\n- 住在北京"}`, "secret"); status != http.StatusOK {
+		t.Fatalf("update namespaced memory = %d %s", status, body)
+	}
+	if status, body := call("GET", "/users/u1/memory?namespace=agent-b", "", "secret"); status != http.StatusOK || !strings.Contains(body, "住在北京") {
+		t.Fatalf("get namespaced memory = %d %s", status, body)
+	}
+	if status, body := call("GET", "/users/u1/memory", "", "secret"); status != http.StatusOK || !strings.Contains(body, "住在上海") {
+		t.Fatalf("default namespace memory = %d %s", status, body)
+	}
+	if status, body := call("GET", "/users/u1/memory/revisions?namespace=agent-b", "", "secret"); status != http.StatusOK || strings.Contains(body, "住在上海") || !strings.Contains(body, "住在北京") {
+		t.Fatalf("namespaced revisions = %d %s", status, body)
+	}
+	if status, body := call("POST", "/users/u1/memory/revisions/missing/restore", "", "secret"); status != http.StatusNotFound {
+		t.Fatalf("restore missing revision = %d %s", status, body)
+	}
+	if status, body := call("DELETE", "/users/u1/events/missing", "", "secret"); status != http.StatusNotFound {
+		t.Fatalf("delete missing event = %d %s", status, body)
+	}
+	if status, body := call("PUT", "/users/u1/memory", `{"memory":""}`, "secret"); status != http.StatusBadRequest {
+		t.Fatalf("empty memory = %d %s", status, body)
+	}
+	if status, body := call("GET", "/users/u1/events?limit=x", "", "secret"); status != http.StatusBadRequest {
+		t.Fatalf("invalid limit = %d %s", status, body)
+	}
+	if status, body := call("GET", "/users/u1/events?q=退款", "", "secret"); status != http.StatusOK || body != "[]" {
+		t.Fatalf("events = %d %s", status, body)
+	}
+
+	status, body := call("GET", "/users/u1/sessions/s1/messages", "", "secret")
+	var messages []*builtin.ConversationMessage
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &messages) != nil || len(messages) != 2 || messages[0].Content != "退款失败了" {
+		t.Fatalf("messages = %d %s", status, body)
+	}
+	if status, body := call("GET", "/users/u1/sessions", "", "secret"); status != http.StatusOK || !strings.Contains(body, `"s1"`) {
+		t.Fatalf("sessions = %d %s", status, body)
+	}
+
+	if status, body := call("POST", "/users/u1/sessions/s1/summary/regenerate", "", "secret"); status != http.StatusOK || !strings.Contains(body, "用户在咨询退款") {
+		t.Fatalf("regenerate summary = %d %s", status, body)
+	}
+	if status, body := call("GET", "/users/u1/sessions/s1/summary", "", "secret"); status != http.StatusOK || !strings.Contains(body, messages[1].ID) {
+		t.Fatalf("summary = %d %s", status, body)
+	}
+	if status, body := call("POST", "/cleanup", "", "secret"); status != http.StatusOK {
+		t.Fatalf("cleanup = %d %s", status, body)
+	}
+	if status, body := call("POST", "/retention?dryRun=true", "", "secret"); status != http.StatusOK || !strings.Contains(body, `"messagesDeleted":1`) {
+		t.Fatalf("retention dry run = %d %s", status, body)
+	}
+	if kept, _ := manager.GetConversationMessages(ctx, "s1", "u1", 0); len(kept) != 2 {
+		t.Fatalf("dry run should keep messages, got %d", len(kept))
+	}
+
+	if status, body := call("DELETE", "/users/u1/sessions/s1", "", "secret"); status != http.StatusNoContent {
+		t.Fatalf("delete session = %d %s", status, body)
+	}
+	if status, _ := call("GET", "/users/u1/sessions/s1/summary", "", "secret"); status != http.StatusNotFound {
+		t.Fatalf("summary after delete = %d", status)
+	}
+}
@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// BearerTokenAuth 校验 Authorization: Bearer <token>
+func BearerTokenAuth(token string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" || !secureEqual(got, token) {
+				writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BasicAuth 校验 HTTP Basic 认证
+func BasicAuth(username, password string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			// 两项都比较，避免通过响应时间判断用户名是否正确
+			userOK := secureEqual(user, username)
+			passOK := secureEqual(pass, password)
+			if !ok || username == "" || !userOK || !passOK {
+				w.Header().Set("WWW-Authenticate", `Basic realm="aggo memory admin"`)
+				writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// secureEqual 常量时间比较，先取哈希避免长度差异泄露信息
+func secureEqual(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
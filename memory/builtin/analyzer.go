@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"strings"
 	"time"
 
 	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	"github.com/CoolBanHub/aggo/memory/memoryevent"
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 )
@@ -151,6 +153,8 @@ type eventSearchAnalyzerEventParam struct {
 	Date     string   `json:"date"`
 	Summary  string   `json:"summary"`
 	Keywords []string `json:"keywords,omitempty"`
+	// 模型偶尔会输出字符串或小数，统一按数字宽松解析
+	Importance json.Number `json:"importance,omitempty"`
 }
 
 func parseEventSearchAnalyzerResponse(content string) (*MemoryAnalysisResult, error) {
@@ -183,11 +187,21 @@ func parseEventSearchAnalyzerResponse(content string) (*MemoryAnalysisResult, er
 			evt.EventDate = time.Now()
 		}
 		evt.Keywords = sanitizeKeywords(raw.Keywords)
+		evt.Importance = parseEventImportance(raw.Importance)
 		result.Events = append(result.Events, evt)
 	}
 	return result, nil
 }
 
+// parseEventImportance 解析模型给出的重要程度，无法解析时返回 0（未评估）
+func parseEventImportance(raw json.Number) int {
+	f, err := raw.Float64()
+	if err != nil {
+		return 0
+	}
+	return memoryevent.NormalizeImportance(int(math.Round(f)))
+}
+
 func normalizeEventType(t string) string {
 	t = strings.ToLower(strings.TrimSpace(t))
 	switch t {
@@ -358,10 +372,18 @@ func analyzerResponseText(msg *schema.AgenticMessage) string {
 	return agmsg.Text(msg)
 }
 
+// buildConversationHistoryPlainText 拼接分析与摘要使用的对话纯文本。
+// 工具结果可能很长且多为原始数据，不进入提示词；助手的工具调用只保留一行调用记录
 func buildConversationHistoryPlainText(historyMessages []*ConversationMessage) string {
 	var lines []string
 	for _, msg := range historyMessages {
+		if msg == nil || msg.Role == MessageRoleTool {
+			continue
+		}
 		content := conversationMessageToPlainText(msg)
+		if stub := toolCallStub(msg.ToolCalls); stub != "" {
+			content = strings.TrimSpace(content + "\n" + stub)
+		}
 		if content == "" {
 			continue
 		}
@@ -420,3 +442,17 @@ func conversationMessageRoleLabel(role string) string {
 		return role
 	}
 }
+
+// toolCallStub 把工具调用压缩为一行，只记录调用了哪些工具，不含参数
+func toolCallStub(calls []ToolCall) string {
+	names := make([]string, 0, len(calls))
+	for _, call := range calls {
+		if name := strings.TrimSpace(call.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return "[调用工具：" + strings.Join(names, "、") + "]"
+}
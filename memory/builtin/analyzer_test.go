@@ -174,6 +174,28 @@ func TestAnalyzerPutsDynamicContextInUserMessage(t *testing.T) {
 	assertDynamicContextOnlyInUser(t, cm.input, "榴莲披萨", "项目X-999验收完成", "明天提醒我复核项目X-999")
 }
 
+func TestAnalyzerIgnoresToolResults(t *testing.T) {
+	cm := &captureAgenticModel{response: `{"op":"noop"}`}
+	_, err := NewUserMemoryAnalyzer(cm).AnalyzeOnce(context.Background(), AnalyzeRequest{
+		HistoryMessages: []*ConversationMessage{
+			{Role: "user", Content: "帮我查一下订单10086"},
+			{Role: "assistant", ToolCalls: []ToolCall{{CallID: "c1", Name: "query_order", Arguments: `{"id":"10086"}`}}},
+			{Role: MessageRoleTool, Content: strings.Repeat("原始订单数据", 300)},
+			{Role: "assistant", Content: "订单已发货"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("AnalyzeOnce: %v", err)
+	}
+	analysisText := agmsg.Text(cm.input[len(cm.input)-1])
+	if strings.Contains(analysisText, "原始订单数据") || strings.Contains(analysisText, `"10086"`) {
+		t.Fatalf("tool results and arguments should not reach the analyzer: %q", analysisText)
+	}
+	if !strings.Contains(analysisText, "助手: [调用工具：query_order]") || !strings.Contains(analysisText, "订单已发货") {
+		t.Fatalf("tool call should be kept as a one-line stub: %q", analysisText)
+	}
+}
+
 func TestAnalyzerResponseTextIgnoresReasoningBlocks(t *testing.T) {
 	msg := &schema.AgenticMessage{
 		Role: schema.AgenticRoleTypeAssistant,
@@ -275,3 +297,26 @@ func assertDynamicContextOnlyInUser(t *testing.T, messages []*schema.AgenticMess
 		}
 	}
 }
+
+func TestParseEventSearchAnalyzerResponse_Importance(t *testing.T) {
+	raw := `{"op":"update","memory":"# 用户记忆","events":[
+        {"type":"event","date":"2026-04-15","summary":"签订年度合同","importance":5},
+        {"type":"event","date":"2026-04-15","summary":"询问发票抬头","importance":"1"},
+        {"type":"event","date":"2026-04-15","summary":"越界","importance":9},
+        {"type":"event","date":"2026-04-15","summary":"未评估"}
+    ]}`
+
+	result, err := parseEventSearchAnalyzerResponse(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []int{5, 1, 5, 0}
+	if len(result.Events) != len(want) {
+		t.Fatalf("want %d events, got %d", len(want), len(result.Events))
+	}
+	for i, evt := range result.Events {
+		if evt.Importance != want[i] {
+			t.Fatalf("event %d importance = %d, want %d", i, evt.Importance, want[i])
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin/blob"
+)
+
+// ArchiveBatch 一批待删除的数据：同一会话的消息，或同一记忆归属的事件
+type ArchiveBatch struct {
+	UserID string
+	// Namespace 事件所属的命名空间，消息不区分命名空间
+	Namespace string
+	// SessionID 消息所属会话，事件批次为空
+	SessionID  string
+	Messages   []*ConversationMessage
+	Events     []*UserMemoryEvent
+	ArchivedAt time.Time
+}
+
+// Archiver 在保留策略删除数据前把数据写入冷存储，返回错误时对应数据不会被删除。
+// 消息中的外置附件引用不会随消息归档，消息删除后附件会被附件垃圾回收删除。
+type Archiver interface {
+	Archive(ctx context.Context, batch *ArchiveBatch) error
+}
+
+// archiveRecord 归档文件中的一行
+type archiveRecord struct {
+	Kind       string               `json:"kind"`
+	ArchivedAt time.Time            `json:"archivedAt"`
+	Namespace  string               `json:"namespace,omitempty"`
+	Message    *ConversationMessage `json:"message,omitempty"`
+	Event      *UserMemoryEvent     `json:"event,omitempty"`
+}
+
+// encodeArchiveBatch 把批次编码为 JSONL，每行一条消息或事件
+func encodeArchiveBatch(batch *ArchiveBatch) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, msg := range batch.Messages {
+		if err := enc.Encode(archiveRecord{Kind: "message", ArchivedAt: batch.ArchivedAt, Message: msg}); err != nil {
+			return nil, err
+		}
+	}
+	for _, evt := range batch.Events {
+		if err := enc.Encode(archiveRecord{Kind: "event", ArchivedAt: batch.ArchivedAt, Namespace: batch.Namespace, Event: evt}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// JSONLArchiver 按天把归档数据追加到目录下的 JSONL 文件（messages-20060102.jsonl、events-20060102.jsonl）
+type JSONLArchiver struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewJSONLArchiver 创建写入 dir 的 JSONL 归档器，目录不存在时自动创建
+func NewJSONLArchiver(dir string) (*JSONLArchiver, error) {
+	if dir == "" {
+		return nil, errors.New("归档目录不能为空")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	return &JSONLArchiver{dir: dir}, nil
+}
+
+// Archive 实现 Archiver
+func (a *JSONLArchiver) Archive(ctx context.Context, batch *ArchiveBatch) error {
+	data, err := encodeArchiveBatch(batch)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	kind := "messages"
+	if len(batch.Messages) == 0 {
+		kind = "events"
+	}
+	name := filepath.Join(a.dir, fmt.Sprintf("%s-%s.jsonl", kind, batch.ArchivedAt.Format("20060102")))
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// BlobArchiver 把每个批次编码为一个 JSONL 对象写入 blob.Store，对象按内容寻址，可通过 Store.List 遍历。
+// 不要与 AttachmentConfig.Store 共用同一个 Store，否则归档对象会被附件垃圾回收删除。
+type BlobArchiver struct {
+	store blob.Store
+}
+
+// NewBlobArchiver 创建写入 store 的归档器
+func NewBlobArchiver(store blob.Store) (*BlobArchiver, error) {
+	if store == nil {
+		return nil, errors.New("归档存储不能为空")
+	}
+	return &BlobArchiver{store: store}, nil
+}
+
+// Archive 实现 Archiver
+func (a *BlobArchiver) Archive(ctx context.Context, batch *ArchiveBatch) error {
+	data, err := encodeArchiveBatch(batch)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return a.store.Put(ctx, blob.Key(data), data, "application/x-ndjson")
+}
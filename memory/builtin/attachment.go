@@ -0,0 +1,289 @@
+package builtin
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin/blob"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gookit/slog"
+)
+
+// AttachmentURLPrefix 外置附件在消息 Parts 中的引用前缀，完整引用为 aggo-blob://<sha256>
+const AttachmentURLPrefix = "aggo-blob://"
+
+const defaultAttachmentGCGracePeriod = time.Hour
+
+// AttachmentRehydrateMode 构建历史消息时还原附件的方式
+type AttachmentRehydrateMode string
+
+const (
+	// AttachmentRehydrateBase64 读取附件内容并还原为 Base64Data
+	AttachmentRehydrateBase64 AttachmentRehydrateMode = "base64"
+	// AttachmentRehydrateURL 使用存储提供的链接（如 S3 预签名链接），存储不支持链接时退回 base64
+	AttachmentRehydrateURL AttachmentRehydrateMode = "url"
+)
+
+// AttachmentConfig 多模态附件外置存储配置
+type AttachmentConfig struct {
+	// Store 附件存储，必填
+	Store blob.Store `json:"-"`
+	// Rehydrate 还原方式，默认 base64
+	Rehydrate AttachmentRehydrateMode `json:"rehydrate,omitempty"`
+	// MinBytes 解码后小于该字节数的内联数据仍保留在消息中，默认 0 表示全部外置
+	MinBytes int `json:"minBytes,omitempty"`
+	// GCGracePeriod 垃圾回收只删除写入超过该时长且不再被引用的附件，
+	// 避免误删已写入附件、但消息尚未保存的数据，默认 1 小时
+	GCGracePeriod time.Duration `json:"gcGracePeriod,omitempty"`
+}
+
+func normalizeAttachmentConfig(cfg *AttachmentConfig) *AttachmentConfig {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Store == nil {
+		slog.Warnf("memory: Attachments.Store 为空，附件将继续内联存储")
+		return nil
+	}
+	if cfg.Rehydrate != AttachmentRehydrateURL {
+		cfg.Rehydrate = AttachmentRehydrateBase64
+	}
+	if cfg.MinBytes < 0 {
+		cfg.MinBytes = 0
+	}
+	if cfg.GCGracePeriod <= 0 {
+		cfg.GCGracePeriod = defaultAttachmentGCGracePeriod
+	}
+	return cfg
+}
+
+func (m *MemoryManager) attachmentRefStorage() AttachmentRefStorage {
+	s, _ := m.storage.(AttachmentRefStorage)
+	return s
+}
+
+// AttachmentRefs 返回 Parts 中引用的外置附件 key，供存储实现 AttachmentRefStorage 使用
+func AttachmentRefs(parts []schema.MessageInputPart) []string {
+	var keys []string
+	for i := range parts {
+		common := partCommon(&parts[i])
+		if common == nil {
+			continue
+		}
+		if key, ok := attachmentKey(common); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// attachmentKey 解析外置附件引用
+func attachmentKey(common *schema.MessagePartCommon) (string, bool) {
+	if common.URL == nil {
+		return "", false
+	}
+	key, ok := strings.CutPrefix(*common.URL, AttachmentURLPrefix)
+	return key, ok && blob.ValidKey(key)
+}
+
+// partCommon 返回附件部分的公共字段，文本等其他类型返回 nil
+func partCommon(part *schema.MessageInputPart) *schema.MessagePartCommon {
+	switch {
+	case part.Image != nil:
+		return &part.Image.MessagePartCommon
+	case part.Audio != nil:
+		return &part.Audio.MessagePartCommon
+	case part.Video != nil:
+		return &part.Video.MessagePartCommon
+	case part.File != nil:
+		return &part.File.MessagePartCommon
+	}
+	return nil
+}
+
+// clonePart 复制附件结构体，修改副本不会影响调用方传入的 Parts
+func clonePart(part schema.MessageInputPart) schema.MessageInputPart {
+	switch {
+	case part.Image != nil:
+		image := *part.Image
+		part.Image = &image
+	case part.Audio != nil:
+		audio := *part.Audio
+		part.Audio = &audio
+	case part.Video != nil:
+		video := *part.Video
+		part.Video = &video
+	case part.File != nil:
+		file := *part.File
+		part.File = &file
+	}
+	return part
+}
+
+// offloadAttachments 把内联的 Base64Data 写入附件存储，Parts 中只保留按内容哈希的引用与 MIME 类型。
+// 未配置附件存储或没有内联数据时原样返回。
+func (m *MemoryManager) offloadAttachments(ctx context.Context, parts []schema.MessageInputPart) ([]schema.MessageInputPart, error) {
+	cfg := m.config.Attachments
+	if cfg == nil || len(parts) == 0 {
+		return parts, nil
+	}
+
+	var result []schema.MessageInputPart
+	for i := range parts {
+		common := partCommon(&parts[i])
+		if common == nil || common.Base64Data == nil || *common.Base64Data == "" {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(*common.Base64Data)
+		if err != nil {
+			slog.Warnf("附件不是合法的 base64，保留内联存储: %v", err)
+			continue
+		}
+		if len(data) < cfg.MinBytes {
+			continue
+		}
+		key := blob.Key(data)
+		if err := cfg.Store.Put(ctx, key, data, common.MIMEType); err != nil {
+			return nil, fmt.Errorf("保存附件失败: %w", err)
+		}
+
+		if result == nil {
+			result = append([]schema.MessageInputPart(nil), parts...)
+		}
+		result[i] = clonePart(parts[i])
+		common = partCommon(&result[i])
+		common.Base64Data = nil
+		common.URL = ptrTo(AttachmentURLPrefix + key)
+	}
+	if result == nil {
+		return parts, nil
+	}
+	return result, nil
+}
+
+// attachmentResolver 在一次历史构建中缓存已还原的附件，同一附件只读取一次
+type attachmentResolver struct {
+	m     *MemoryManager
+	cache map[string]schema.MessagePartCommon
+}
+
+func (m *MemoryManager) newAttachmentResolver() *attachmentResolver {
+	if m.config.Attachments == nil {
+		return nil
+	}
+	return &attachmentResolver{m: m, cache: make(map[string]schema.MessagePartCommon)}
+}
+
+// rehydrate 返回还原了外置附件的消息副本，没有引用时返回原消息。
+// 附件读取失败时丢弃该部分并记录日志，不影响其余历史。
+func (r *attachmentResolver) rehydrate(ctx context.Context, msg *ConversationMessage) *ConversationMessage {
+	if r == nil || len(AttachmentRefs(msg.Parts)) == 0 {
+		return msg
+	}
+	cloned := *msg
+	cloned.Parts = make([]schema.MessageInputPart, 0, len(msg.Parts))
+	for _, part := range msg.Parts {
+		common := partCommon(&part)
+		key, ok := "", false
+		if common != nil {
+			key, ok = attachmentKey(common)
+		}
+		if !ok {
+			cloned.Parts = append(cloned.Parts, part)
+			continue
+		}
+		resolved, err := r.resolve(ctx, key)
+		if err != nil {
+			slog.Warnf("还原附件失败，已从历史中省略: messageID=%s, key=%s, err=%v", msg.ID, key, err)
+			continue
+		}
+		part = clonePart(part)
+		common = partCommon(&part)
+		common.URL, common.Base64Data = resolved.URL, resolved.Base64Data
+		cloned.Parts = append(cloned.Parts, part)
+	}
+	return &cloned
+}
+
+func (r *attachmentResolver) resolve(ctx context.Context, key string) (schema.MessagePartCommon, error) {
+	if resolved, ok := r.cache[key]; ok {
+		return resolved, nil
+	}
+	cfg := r.m.config.Attachments
+
+	var resolved schema.MessagePartCommon
+	if cfg.Rehydrate == AttachmentRehydrateURL {
+		link, err := cfg.Store.URL(ctx, key)
+		if err != nil {
+			return resolved, err
+		}
+		if link != "" {
+			resolved.URL = &link
+		}
+	}
+	if resolved.URL == nil {
+		data, err := cfg.Store.Get(ctx, key)
+		if err != nil {
+			return resolved, err
+		}
+		resolved.Base64Data = ptrTo(base64.StdEncoding.EncodeToString(data))
+	}
+	r.cache[key] = resolved
+	return resolved, nil
+}
+
+// toAgenticMessages 转换存储消息为 agentic 消息，并还原外置附件
+func (m *MemoryManager) toAgenticMessages(ctx context.Context, messages []*ConversationMessage) []*schema.AgenticMessage {
+	resolver := m.newAttachmentResolver()
+	list := make([]*schema.AgenticMessage, len(messages))
+	for i, v := range messages {
+		list[i] = resolver.rehydrate(ctx, v).ToAgenticMessage()
+	}
+	return list
+}
+
+// CleanupAttachments 删除不再被任何消息引用的外置附件，返回删除数量。
+// 写入时间不足 GCGracePeriod 的附件会被保留；存储未实现 AttachmentRefStorage 时不做任何删除。
+func (m *MemoryManager) CleanupAttachments(ctx context.Context) (int, error) {
+	cfg := m.config.Attachments
+	if cfg == nil {
+		return 0, nil
+	}
+	refStorage := m.attachmentRefStorage()
+	if refStorage == nil {
+		return 0, fmt.Errorf("当前 storage 未实现 AttachmentRefStorage")
+	}
+
+	// 先列出附件再收集引用：列出之后写入的消息引用的附件要么不在列表中，要么仍在宽限期内
+	infos, err := cfg.Store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	refs, err := refStorage.ListAttachmentRefs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("获取附件引用失败: %w", err)
+	}
+	referenced := make(map[string]struct{}, len(refs))
+	for _, key := range refs {
+		referenced[key] = struct{}{}
+	}
+
+	deadline := time.Now().Add(-cfg.GCGracePeriod)
+	deleted := 0
+	for _, info := range infos {
+		if _, ok := referenced[info.Key]; ok || info.UpdatedAt.After(deadline) {
+			continue
+		}
+		if err := cfg.Store.Delete(ctx, info.Key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		slog.Infof("清理了 %d 个不再被引用的附件", deleted)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,54 @@
+// Package blob 提供按内容哈希寻址的附件存储，用于把多模态消息中的大块内容移出消息表。
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("blob not found")
+
+// Store 附件存储。key 由调用方按内容哈希生成，同一 key 的内容不会变化，
+// 因此 Put 可以对已存在的 key 直接返回。实现需并发安全。
+type Store interface {
+	// Put 写入对象，key 已存在时可以跳过
+	Put(ctx context.Context, key string, data []byte, mimeType string) error
+	// Get 读取对象，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// List 列出所有对象，用于垃圾回收
+	List(ctx context.Context) ([]Info, error)
+	// URL 返回可供模型直接访问的链接，不支持时返回空字符串
+	URL(ctx context.Context, key string) (string, error)
+}
+
+// Info 对象元数据
+type Info struct {
+	Key       string
+	Size      int64
+	UpdatedAt time.Time
+}
+
+// Key 计算内容的 sha256 十六进制摘要作为对象 key
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidKey 判断 key 是否为 Key 生成的格式，避免路径穿越
+func ValidKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStore 本地文件系统附件存储，对象保存在 <dir>/<key 前两位>/<key>
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore 创建本地附件存储，目录不存在时自动创建
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, errors.New("blob dir is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建附件目录失败: %w", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.dir, key[:2], key)
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, mimeType string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid blob key: %q", key)
+	}
+	path := s.path(key)
+	if _, err := os.Stat(path); err == nil {
+		// 刷新修改时间，避免刚被重新引用的附件在垃圾回收的宽限期判断中被当作旧对象
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建附件目录失败: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免并发写入或中途崩溃留下不完整的对象
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("写入附件失败: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("写入附件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("写入附件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("写入附件失败: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if !ValidKey(key) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取附件失败: %w", err)
+	}
+	return data, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return nil
+	}
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("删除附件失败: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStore) List(ctx context.Context) ([]Info, error) {
+	var infos []Info
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !ValidKey(d.Name()) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		infos = append(infos, Info{Key: d.Name(), Size: fi.Size(), UpdatedAt: fi.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("列出附件失败: %w", err)
+	}
+	return infos, nil
+}
+
+// URL 本地存储没有可供模型访问的链接，始终返回空字符串
+func (s *LocalStore) URL(ctx context.Context, key string) (string, error) {
+	return "", nil
+}
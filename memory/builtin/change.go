@@ -0,0 +1,66 @@
+package builtin
+
+import (
+	"context"
+	"time"
+)
+
+// ChangeKind 记忆数据变更类型
+type ChangeKind string
+
+const (
+	// ChangeUserMemoryUpdated 用户记忆文档被写入或清空，负载为 *UserMemoryChange
+	ChangeUserMemoryUpdated ChangeKind = "user_memory_updated"
+	// ChangeUserMemoryEventCreated 新增一条用户记忆事件（含整理合并产生的事件），负载为 *UserMemoryEvent
+	ChangeUserMemoryEventCreated ChangeKind = "user_memory_event_created"
+	// ChangeSessionSummaryUpdated 会话摘要被创建或增量更新，负载为 *SessionSummary
+	ChangeSessionSummaryUpdated ChangeKind = "session_summary_updated"
+	// ChangeUserDigestCreated 生成了一份用户周期摘要，负载为 *UserDigest
+	ChangeUserDigestCreated ChangeKind = "user_digest_created"
+	// ChangeCleanupPerformed 一轮清理执行完毕，负载为 *CleanupReport
+	ChangeCleanupPerformed ChangeKind = "cleanup_performed"
+)
+
+// ChangeListener 记忆数据变更回调，在写入成功后同步调用。
+// 回调运行在写入方的 goroutine 中（可能持有用户记忆锁），不应执行耗时操作或回写同一用户的记忆。
+type ChangeListener func(ctx context.Context, kind ChangeKind, payload any)
+
+// UserMemoryChange 用户记忆变更通知
+type UserMemoryChange struct {
+	UserID string
+	// 写入后的记忆，清空时为 nil
+	Memory *UserMemory
+	// 对应的版本记录，storage 未实现版本历史或清空时为 nil
+	Revision *UserMemoryRevision
+	// 写入来源，见 UserMemorySource* 常量
+	Source string
+}
+
+// CleanupReport 一轮清理的执行情况
+type CleanupReport struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// 是否清理了过期的会话触发状态
+	SessionStatesCleaned bool
+	// 是否执行了消息清理（按时间或按数量）
+	MessagesCleaned bool
+	// 保留策略的执行结果，未配置保留策略时为 nil
+	Retention *RetentionReport
+	// 删除的不再被引用的外置附件数量
+	AttachmentsDeleted int
+	// 清理过程中的错误，清理不会因单步失败而中断
+	Errors []error
+}
+
+// SetChangeListener 设置记忆数据变更回调，传 nil 取消。需在开始读写前设置。
+func (m *MemoryManager) SetChangeListener(listener ChangeListener) {
+	m.changeListener = listener
+}
+
+// notifyChange 通知变更回调，未设置回调时忽略
+func (m *MemoryManager) notifyChange(ctx context.Context, kind ChangeKind, payload any) {
+	if m.changeListener == nil {
+		return
+	}
+	m.changeListener(ctx, kind, payload)
+}
@@ -0,0 +1,661 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gookit/slog"
+)
+
+// EventConsolidationConfig 用户记忆事件整理配置，仅在 EnableEventSearch=true 时生效。
+// 整理任务定期把相似事件聚类后交给模型判断，合并重复事件、用新事实取代过时事实。
+// 被合并或取代的事件只做标记不删除，合并生成的新事件通过 Sources 记录来源。
+type EventConsolidationConfig struct {
+	// 整理间隔（分钟），默认 360
+	IntervalMinutes int `json:"intervalMinutes"`
+	// 每轮最多处理的用户数，默认 50。上次整理后没有新事件的用户直接跳过，不计入此上限
+	MaxUsersPerRun int `json:"maxUsersPerRun"`
+	// 每轮最多调用模型的次数（每个候选簇一次，配置 Search.Embedder 时每个用户计算向量另计一次），默认 20。
+	// 用尽后剩余用户留到下一轮
+	MaxLLMCallsPerRun int `json:"maxLLMCallsPerRun"`
+	// 每个用户参与聚类的最近事件数，默认 200
+	MaxEventsPerUser int `json:"maxEventsPerUser"`
+	// 单个簇最多包含的事件数，默认 8
+	MaxClusterSize int `json:"maxClusterSize"`
+	// 关键词重合度阈值（共同关键词数 / 较少一方关键词数），默认 0.5
+	KeywordThreshold float64 `json:"keywordThreshold"`
+	// 向量相似度阈值，默认 0.85；仅在 Search.Embedder 非空时使用
+	EmbeddingThreshold float64 `json:"embeddingThreshold"`
+}
+
+func normalizeConsolidationConfig(cfg *EventConsolidationConfig) *EventConsolidationConfig {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.IntervalMinutes <= 0 {
+		cfg.IntervalMinutes = 360
+	}
+	if cfg.MaxUsersPerRun <= 0 {
+		cfg.MaxUsersPerRun = 50
+	}
+	if cfg.MaxLLMCallsPerRun <= 0 {
+		cfg.MaxLLMCallsPerRun = 20
+	}
+	if cfg.MaxEventsPerUser <= 0 {
+		cfg.MaxEventsPerUser = 200
+	}
+	if cfg.MaxClusterSize < 2 {
+		cfg.MaxClusterSize = 8
+	}
+	if cfg.KeywordThreshold <= 0 || cfg.KeywordThreshold > 1 {
+		cfg.KeywordThreshold = 0.5
+	}
+	if cfg.EmbeddingThreshold <= 0 || cfg.EmbeddingThreshold > 1 {
+		cfg.EmbeddingThreshold = 0.85
+	}
+	return cfg
+}
+
+// EventConsolidator 调用模型整理一簇相似事件
+type EventConsolidator struct {
+	cm           model.AgenticModel
+	systemPrompt string
+}
+
+// NewEventConsolidator 创建事件整理器
+func NewEventConsolidator(cm model.AgenticModel) *EventConsolidator {
+	return &EventConsolidator{
+		cm:           cm,
+		systemPrompt: DefaultEventConsolidationPrompt,
+	}
+}
+
+// SetSystemPrompt 自定义事件整理 prompt
+func (c *EventConsolidator) SetSystemPrompt(prompt string) {
+	c.systemPrompt = prompt
+}
+
+// ConsolidationAction 整理动作
+type ConsolidationAction struct {
+	// Op 为 merge 或 supersede
+	Op string
+	// EventIDs merge 时为被合并的事件，supersede 时为被取代的事件
+	EventIDs []string
+	// Keep supersede 时保留的事件
+	Keep string
+	// Merged merge 时生成的新事件
+	Merged *UserMemoryEvent
+}
+
+const (
+	ConsolidationOpMerge     = "merge"
+	ConsolidationOpSupersede = "supersede"
+)
+
+type consolidationParam struct {
+	Op      string                    `json:"op"`
+	Actions []consolidationActionItem `json:"actions,omitempty"`
+}
+
+type consolidationActionItem struct {
+	Op       string   `json:"op"`
+	IDs      []string `json:"ids"`
+	Keep     string   `json:"keep,omitempty"`
+	Type     string   `json:"type,omitempty"`
+	Date     string   `json:"date,omitempty"`
+	Summary  string   `json:"summary,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// Consolidate 对一簇事件给出整理动作。事件在 prompt 中以 E1、E2… 编号，返回前映射回真实 ID。
+func (c *EventConsolidator) Consolidate(ctx context.Context, events []*UserMemoryEvent) ([]*ConsolidationAction, error) {
+	if len(events) < 2 {
+		return nil, nil
+	}
+	ctx = withObservationName(ctx, c.cm, "builtin-memory-consolidator")
+
+	lines := make([]string, 0, len(events))
+	for i, evt := range events {
+		line := fmt.Sprintf("E%d [%s][%s] %s", i+1, evt.EventDate.Format("2006-01-02"), evt.Type, evt.Summary)
+		if len(evt.Keywords) > 0 {
+			line += "（关键词: " + strings.Join(evt.Keywords, ", ") + "）"
+		}
+		lines = append(lines, line)
+	}
+	messages := []*schema.AgenticMessage{
+		schema.SystemAgenticMessage(c.systemPrompt),
+		schema.UserAgenticMessage("## 待整理事件\n" + strings.Join(lines, "\n")),
+	}
+
+	response, err := generateViaStream(ctx, c.cm, messages)
+	if err != nil {
+		return nil, fmt.Errorf("整理用户记忆事件失败: %w", err)
+	}
+	content := normalizeAnalyzerJSONContent(analyzerResponseText(response))
+	if content == "" {
+		return nil, nil
+	}
+	return parseConsolidationResponse(content, events)
+}
+
+func parseConsolidationResponse(content string, events []*UserMemoryEvent) ([]*ConsolidationAction, error) {
+	var param consolidationParam
+	if err := json.Unmarshal([]byte(content), &param); err != nil {
+		return nil, fmt.Errorf("解析事件整理响应失败(raw=%q): %w", content, err)
+	}
+	if param.Op == UserMemoryOpNoop {
+		return nil, nil
+	}
+
+	// 模型可能输出 E1 / e1 / 1，统一解析为下标；每个事件只能参与一个动作
+	resolve := func(label string) (*UserMemoryEvent, bool) {
+		label = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(label)), "E")
+		n, err := strconv.Atoi(label)
+		if err != nil || n < 1 || n > len(events) {
+			return nil, false
+		}
+		return events[n-1], true
+	}
+	used := make(map[string]struct{})
+	resolveAll := func(labels []string) []*UserMemoryEvent {
+		out := make([]*UserMemoryEvent, 0, len(labels))
+		for _, label := range labels {
+			evt, ok := resolve(label)
+			if !ok {
+				continue
+			}
+			if _, dup := used[evt.ID]; dup {
+				continue
+			}
+			out = append(out, evt)
+		}
+		return out
+	}
+	markUsed := func(evts ...*UserMemoryEvent) {
+		for _, evt := range evts {
+			used[evt.ID] = struct{}{}
+		}
+	}
+
+	var actions []*ConsolidationAction
+	for _, item := range param.Actions {
+		switch strings.ToLower(strings.TrimSpace(item.Op)) {
+		case ConsolidationOpMerge:
+			sources := resolveAll(item.IDs)
+			summary := strings.TrimSpace(item.Summary)
+			if len(sources) < 2 || summary == "" {
+				continue
+			}
+			markUsed(sources...)
+			actions = append(actions, &ConsolidationAction{
+				Op:       ConsolidationOpMerge,
+				EventIDs: eventIDs(sources),
+				Merged:   mergedEvent(item, summary, sources),
+			})
+		case ConsolidationOpSupersede:
+			keep, ok := resolve(item.Keep)
+			if !ok {
+				continue
+			}
+			if _, dup := used[keep.ID]; dup {
+				continue
+			}
+			var superseded []*UserMemoryEvent
+			for _, evt := range resolveAll(item.IDs) {
+				if evt.ID != keep.ID {
+					superseded = append(superseded, evt)
+				}
+			}
+			if len(superseded) == 0 {
+				continue
+			}
+			markUsed(keep)
+			markUsed(superseded...)
+			actions = append(actions, &ConsolidationAction{
+				Op:       ConsolidationOpSupersede,
+				EventIDs: eventIDs(superseded),
+				Keep:     keep.ID,
+			})
+		}
+	}
+	return actions, nil
+}
+
+func mergedEvent(item consolidationActionItem, summary string, sources []*UserMemoryEvent) *UserMemoryEvent {
+	evt := &UserMemoryEvent{
+		UserID:   sources[0].UserID,
+		Type:     normalizeEventType(item.Type),
+		Summary:  summary,
+		Keywords: sanitizeKeywords(item.Keywords),
+		Sources:  eventIDs(sources),
+	}
+	if strings.TrimSpace(item.Type) == "" {
+		evt.Type = sources[0].Type
+	}
+	if d, ok := parseEventDate(item.Date); ok {
+		evt.EventDate = d
+	} else {
+		for _, src := range sources {
+			if src.EventDate.After(evt.EventDate) {
+				evt.EventDate = src.EventDate
+			}
+		}
+	}
+	// 合并后的事件至少与最重要的来源同等重要，访问次数累加
+	for _, src := range sources {
+		if src.Importance > evt.Importance {
+			evt.Importance = src.Importance
+		}
+		evt.AccessCount += src.AccessCount
+	}
+	if len(evt.Keywords) == 0 {
+		var keywords []string
+		for _, src := range sources {
+			keywords = append(keywords, src.Keywords...)
+		}
+		evt.Keywords = sanitizeKeywords(keywords)
+	}
+	return evt
+}
+
+func eventIDs(events []*UserMemoryEvent) []string {
+	ids := make([]string, 0, len(events))
+	for _, evt := range events {
+		ids = append(ids, evt.ID)
+	}
+	return ids
+}
+
+// errConsolidationBudget 本轮模型调用次数已用尽
+var errConsolidationBudget = errors.New("consolidation llm budget exhausted")
+
+// consolidationState 整理任务的运行状态。
+// userWatermarks 记录每个用户上次完整整理的时间，只有包含此后新增事件的簇才会再次交给模型，
+// 避免反复审阅同一批事件；lastCompleteRun 之前没有新事件的用户不会被列出。
+type consolidationState struct {
+	mu              sync.Mutex
+	lastCompleteRun time.Time
+	userWatermarks  map[string]consolidationWatermark // key: UserMemoryOwner.Key()
+}
+
+// consolidationWatermark 单个用户的整理水位。produced 记录该轮整理自己写入的合并事件，
+// 它们的创建时间晚于水位但内容已审阅过，不算新事件
+type consolidationWatermark struct {
+	at       time.Time
+	produced map[string]struct{}
+}
+
+// ConsolidationReport 单轮整理结果
+type ConsolidationReport struct {
+	Users    int `json:"users"`
+	Clusters int `json:"clusters"`
+	LLMCalls int `json:"llmCalls"`
+	// EmbeddingCalls 计算事件向量的调用次数，与 LLMCalls 共用 MaxLLMCallsPerRun 预算
+	EmbeddingCalls int `json:"embeddingCalls"`
+	Merged         int `json:"merged"`
+	Superseded     int `json:"superseded"`
+	// Deferred 因模型调用预算用尽而留到下一轮的用户数
+	Deferred int `json:"deferred"`
+}
+
+// consolidationStorage 同时实现事件存储与整理接口的存储，未实现时返回 nil
+func (m *MemoryManager) consolidationStorage() (UserMemoryEventStorage, UserMemoryEventConsolidationStorage) {
+	events := m.userMemoryEventStorage()
+	consolidation, ok := m.storage.(UserMemoryEventConsolidationStorage)
+	if events == nil || !ok {
+		return nil, nil
+	}
+	return events, consolidation
+}
+
+// startPeriodicConsolidation 启动定期事件整理任务
+func (m *MemoryManager) startPeriodicConsolidation() {
+	cfg := m.config.Consolidation
+	if cfg == nil || !m.config.EnableEventSearch {
+		return
+	}
+	if events, _ := m.consolidationStorage(); events == nil {
+		slog.Warnf("memory: Consolidation 已配置但当前 storage 未实现 UserMemoryEventConsolidationStorage，事件整理不会运行")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.consolidationCancel = cancel
+	ticker := time.NewTicker(time.Duration(cfg.IntervalMinutes) * time.Minute)
+	m.consolidationWg.Add(1)
+	go func() {
+		defer m.consolidationWg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := m.ConsolidateUserMemoryEvents(ctx); err != nil {
+					slog.Errorf("整理用户记忆事件失败: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// stopPeriodicConsolidation 停止定期整理任务并等待退出
+func (m *MemoryManager) stopPeriodicConsolidation() {
+	if m.consolidationCancel != nil {
+		m.consolidationCancel()
+		m.consolidationWg.Wait()
+		m.consolidationCancel = nil
+	}
+}
+
+// ConsolidateUserMemoryEvents 立即执行一轮事件整理，受 Consolidation 配置的成本上限约束。
+// 未配置 Consolidation 或存储不支持时返回错误。
+func (m *MemoryManager) ConsolidateUserMemoryEvents(ctx context.Context) (*ConsolidationReport, error) {
+	cfg := m.config.Consolidation
+	if cfg == nil {
+		return nil, fmt.Errorf("未配置 Consolidation")
+	}
+	eventStore, consolidationStore := m.consolidationStorage()
+	if eventStore == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 UserMemoryEventConsolidationStorage")
+	}
+
+	state := &m.consolidation
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	runStart := time.Now()
+	owners, err := consolidationStore.ListUserMemoryEventOwners(ctx, state.lastCompleteRun)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ConsolidationReport{}
+	budget := cfg.MaxLLMCallsPerRun
+	complete := true
+	// processed 计入 MaxUsersPerRun 的用户数。上限触发时 lastCompleteRun 不推进，下一轮会重新列出同一批用户，
+	// 已整理过且没有新事件的用户在这里被跳过，排在后面的用户因此不会一直得不到处理
+	processed := 0
+	for i, owner := range owners {
+		if processed >= cfg.MaxUsersPerRun {
+			report.Deferred += len(owners) - i
+			complete = false
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		ownerCtx := WithNamespace(ctx, owner.Namespace)
+		produced := make(map[string]struct{})
+		handled, err := m.consolidateUser(ownerCtx, cfg, eventStore, consolidationStore, owner.UserID, state.userWatermarks[owner.Key()], produced, &budget, report)
+		if errors.Is(err, errConsolidationBudget) {
+			report.Deferred += len(owners) - i
+			complete = false
+			break
+		}
+		if err != nil {
+			// 单个用户失败不影响其他用户，水位不推进，下轮重试
+			slog.Errorf("整理用户 %s（命名空间 %q）的记忆事件失败: %v", owner.UserID, owner.Namespace, err)
+			processed++
+			complete = false
+			continue
+		}
+		if !handled {
+			continue
+		}
+		processed++
+		report.Users++
+		if state.userWatermarks == nil {
+			state.userWatermarks = make(map[string]consolidationWatermark)
+		}
+		state.userWatermarks[owner.Key()] = consolidationWatermark{at: runStart, produced: produced}
+	}
+	if complete {
+		state.lastCompleteRun = runStart
+	}
+	return report, nil
+}
+
+// consolidateUser 整理单个用户的事件，返回 false 表示该用户自 watermark 以来没有新事件，未做任何处理
+func (m *MemoryManager) consolidateUser(
+	ctx context.Context,
+	cfg *EventConsolidationConfig,
+	eventStore UserMemoryEventStorage,
+	consolidationStore UserMemoryEventConsolidationStorage,
+	userID string,
+	watermark consolidationWatermark,
+	produced map[string]struct{},
+	budget *int,
+	report *ConsolidationReport,
+) (bool, error) {
+	events, err := eventStore.ListRecentUserMemoryEvents(ctx, userID, cfg.MaxEventsPerUser)
+	if err != nil {
+		return false, err
+	}
+	// 先确认有新事件再计算向量，避免每轮为没有变化的用户重复调用 Embedder
+	if !clusterHasNewEvent(events, watermark) {
+		return false, nil
+	}
+	if len(events) < 2 {
+		return true, nil
+	}
+
+	var vectors [][]float64
+	if m.config.Search != nil && m.config.Search.Embedder != nil {
+		if *budget <= 0 {
+			return true, errConsolidationBudget
+		}
+		*budget--
+		report.EmbeddingCalls++
+		texts := make([]string, 0, len(events))
+		for _, evt := range events {
+			texts = append(texts, evt.Summary)
+		}
+		vectors, err = m.config.Search.Embedder.EmbedStrings(ctx, texts)
+		if err != nil {
+			slog.Warnf("事件整理计算向量失败，仅使用关键词聚类: %v", err)
+			vectors = nil
+		}
+	}
+
+	var tokenizer builtinsearch.Tokenizer
+	if m.config.Search != nil {
+		tokenizer = m.config.Search.Tokenizer
+	}
+	clusters := clusterUserMemoryEvents(events, vectors, tokenizer, cfg)
+	for _, cluster := range clusters {
+		if !clusterHasNewEvent(cluster, watermark) {
+			continue
+		}
+		if *budget <= 0 {
+			return true, errConsolidationBudget
+		}
+		*budget--
+		report.Clusters++
+		report.LLMCalls++
+
+		actions, err := m.eventConsolidator.Consolidate(ctx, cluster)
+		if err != nil {
+			return true, err
+		}
+		for _, action := range actions {
+			if err := applyConsolidationAction(ctx, eventStore, consolidationStore, userID, action); err != nil {
+				return true, err
+			}
+			switch action.Op {
+			case ConsolidationOpMerge:
+				produced[action.Merged.ID] = struct{}{}
+				m.notifyChange(ctx, ChangeUserMemoryEventCreated, action.Merged)
+				report.Merged++
+				report.Superseded += len(action.EventIDs)
+			case ConsolidationOpSupersede:
+				report.Superseded += len(action.EventIDs)
+			}
+		}
+	}
+	return true, nil
+}
+
+// applyConsolidationAction 先写入合并事件再标记来源事件，中途失败最多留下一条重复事件，下轮可再次整理
+func applyConsolidationAction(ctx context.Context, eventStore UserMemoryEventStorage, consolidationStore UserMemoryEventConsolidationStorage, userID string, action *ConsolidationAction) error {
+	switch action.Op {
+	case ConsolidationOpMerge:
+		merged := action.Merged
+		merged.UserID = userID
+		if err := eventStore.SaveUserMemoryEvent(ctx, merged); err != nil {
+			return err
+		}
+		return consolidationStore.SupersedeUserMemoryEvents(ctx, userID, merged.ID, action.EventIDs)
+	case ConsolidationOpSupersede:
+		return consolidationStore.SupersedeUserMemoryEvents(ctx, userID, action.Keep, action.EventIDs)
+	default:
+		return nil
+	}
+}
+
+func clusterHasNewEvent(cluster []*UserMemoryEvent, watermark consolidationWatermark) bool {
+	if watermark.at.IsZero() {
+		return true
+	}
+	for _, evt := range cluster {
+		if _, ok := watermark.produced[evt.ID]; ok {
+			continue
+		}
+		if !evt.CreatedAt.Before(watermark.at) {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterUserMemoryEvents 按关键词重合度或向量相似度把事件连成簇（并查集），
+// 只返回至少两条事件的簇；超过 MaxClusterSize 时保留最近的事件。
+// vectors 为空或长度与 events 不一致时只使用关键词。
+func clusterUserMemoryEvents(events []*UserMemoryEvent, vectors [][]float64, tokenizer builtinsearch.Tokenizer, cfg *EventConsolidationConfig) [][]*UserMemoryEvent {
+	n := len(events)
+	if len(vectors) != n {
+		vectors = nil
+	}
+	terms := make([]map[string]struct{}, n)
+	for i, evt := range events {
+		terms[i] = eventKeywordTerms(tokenizer, evt)
+	}
+
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			similar := overlapCoefficient(terms[i], terms[j]) >= cfg.KeywordThreshold
+			if !similar && vectors != nil {
+				similar = cosine(vectors[i], vectors[j]) >= cfg.EmbeddingThreshold
+			}
+			if similar {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	groups := make(map[int][]*UserMemoryEvent)
+	for i, evt := range events {
+		root := find(i)
+		groups[root] = append(groups[root], evt)
+	}
+
+	clusters := make([][]*UserMemoryEvent, 0, len(groups))
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].EventDate.Equal(group[j].EventDate) {
+				return group[i].CreatedAt.After(group[j].CreatedAt)
+			}
+			return group[i].EventDate.After(group[j].EventDate)
+		})
+		if len(group) > cfg.MaxClusterSize {
+			group = group[:cfg.MaxClusterSize]
+		}
+		clusters = append(clusters, group)
+	}
+	// 簇之间按最新事件排序，预算不足时优先整理最近的事件
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0].CreatedAt.After(clusters[j][0].CreatedAt)
+	})
+	return clusters
+}
+
+// eventKeywordTerms 优先使用事件关键词的检索词，没有关键词时退回正文分词
+func eventKeywordTerms(tokenizer builtinsearch.Tokenizer, evt *UserMemoryEvent) map[string]struct{} {
+	out := make(map[string]struct{})
+	for _, kw := range evt.Keywords {
+		terms := builtinsearch.Terms(tokenizer, kw)
+		if len(terms) == 0 {
+			terms = []string{strings.ToLower(strings.TrimSpace(kw))}
+		}
+		for _, term := range terms {
+			if term != "" {
+				out[term] = struct{}{}
+			}
+		}
+	}
+	if len(out) == 0 {
+		for _, term := range builtinsearch.Terms(tokenizer, evt.Summary) {
+			out[term] = struct{}{}
+		}
+	}
+	return out
+}
+
+func overlapCoefficient(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for term := range a {
+		if _, ok := b[term]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a))
+}
+
+func cosine(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,76 @@
+package builtin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClusterUserMemoryEvents_ByKeywords(t *testing.T) {
+	base := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	events := []*UserMemoryEvent{
+		{ID: "a", EventDate: base, CreatedAt: base, Summary: "海豚377充值入账异常", Keywords: []string{"海豚", "377", "充值"}},
+		{ID: "b", EventDate: base.Add(24 * time.Hour), CreatedAt: base.Add(24 * time.Hour), Summary: "海豚377充值已到账", Keywords: []string{"海豚", "377"}},
+		{ID: "c", EventDate: base, CreatedAt: base, Summary: "西皮士1473扩容完成", Keywords: []string{"西皮士", "1473"}},
+	}
+	cfg := normalizeConsolidationConfig(&EventConsolidationConfig{})
+
+	clusters := clusterUserMemoryEvents(events, nil, nil, cfg)
+	if len(clusters) != 1 || len(clusters[0]) != 2 {
+		t.Fatalf("unexpected clusters: %+v", clusters)
+	}
+	if clusters[0][0].ID != "b" {
+		t.Fatalf("expected most recent event first, got %s", clusters[0][0].ID)
+	}
+
+	// 向量足够相似时即使关键词不重合也归为一簇
+	vectors := [][]float64{{1, 0}, {0, 1}, {0.1, 1}}
+	clusters = clusterUserMemoryEvents(events, vectors, nil, cfg)
+	if len(clusters) != 1 || len(clusters[0]) != 3 {
+		t.Fatalf("expected embedding to join all events, got %+v", clusters)
+	}
+}
+
+func TestParseConsolidationResponse(t *testing.T) {
+	base := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	events := []*UserMemoryEvent{
+		{ID: "a", UserID: "u1", Type: UserMemoryEventTypeEvent, EventDate: base.Add(48 * time.Hour), Summary: "海豚377充值已到账", Keywords: []string{"海豚"}},
+		{ID: "b", UserID: "u1", Type: UserMemoryEventTypeEvent, EventDate: base, Summary: "海豚377充值入账异常", Keywords: []string{"377"}},
+		{ID: "c", UserID: "u1", Type: UserMemoryEventTypeEvent, EventDate: base, Summary: "套餐为标准版"},
+		{ID: "d", UserID: "u1", Type: UserMemoryEventTypeEvent, EventDate: base.Add(24 * time.Hour), Summary: "套餐升级为专业版"},
+	}
+	raw := `{"op":"update","actions":[
+        {"op":"merge","ids":["E1","e2"],"summary":"海豚377充值异常后已到账"},
+        {"op":"supersede","keep":"E4","ids":["E3","E1"]},
+        {"op":"merge","ids":["E9"],"summary":"越界"}
+    ]}`
+
+	actions, err := parseConsolidationResponse(raw, events)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(actions) != 2 {
+		t.Fatalf("want 2 actions, got %d", len(actions))
+	}
+
+	merge := actions[0]
+	if merge.Op != ConsolidationOpMerge || len(merge.EventIDs) != 2 {
+		t.Fatalf("unexpected merge action: %+v", merge)
+	}
+	if !merge.Merged.EventDate.Equal(events[0].EventDate) {
+		t.Fatalf("merged date should default to latest source, got %v", merge.Merged.EventDate)
+	}
+	if len(merge.Merged.Keywords) != 2 || len(merge.Merged.Sources) != 2 {
+		t.Fatalf("merged event should inherit keywords and record sources: %+v", merge.Merged)
+	}
+
+	// E1 已被合并，不应再次被取代
+	supersede := actions[1]
+	if supersede.Keep != "d" || len(supersede.EventIDs) != 1 || supersede.EventIDs[0] != "c" {
+		t.Fatalf("unexpected supersede action: %+v", supersede)
+	}
+
+	actions, err = parseConsolidationResponse(`{"op":"noop"}`, events)
+	if err != nil || len(actions) != 0 {
+		t.Fatalf("expected noop, got %+v err=%v", actions, err)
+	}
+}
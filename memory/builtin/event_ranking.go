@@ -0,0 +1,205 @@
+package builtin
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
+	"github.com/CoolBanHub/aggo/memory/memoryevent"
+	"github.com/gookit/slog"
+)
+
+const (
+	defaultEventRecencyHalfLifeDays = 30
+	minEventRankingCandidates       = 100
+)
+
+// EventRankingConfig 最近事件注入的排序配置，仅在 EnableEventSearch=true 时生效。
+// 注入时先取候选事件，再按 时间衰减、重要程度、与当前用户消息的相关度 加权打分，取前 RecentEventLimit 条。
+// 三个权重全为 0 时使用默认值（均为 1）；只想按时间倒序时把 RecencyWeight 设为 1、其余设为 0。
+type EventRankingConfig struct {
+	// 时间衰减权重
+	RecencyWeight float64 `json:"recencyWeight"`
+	// 重要程度权重
+	ImportanceWeight float64 `json:"importanceWeight"`
+	// 相关度权重（BM25，查询为当前用户消息）
+	RelevanceWeight float64 `json:"relevanceWeight"`
+	// 时间衰减半衰期（天），默认 30：EventDate 距今 30 天的事件时间分为 0.5
+	RecencyHalfLifeDays float64 `json:"recencyHalfLifeDays"`
+	// 参与排序的候选事件数，默认 max(RecentEventLimit*5, 100)。
+	// 候选由最近事件与关键词命中的事件合并而来，因此较早但相关的事件也能入选。
+	CandidateLimit int `json:"candidateLimit"`
+}
+
+func normalizeEventRankingConfig(cfg *EventRankingConfig) *EventRankingConfig {
+	if cfg == nil {
+		cfg = &EventRankingConfig{}
+	}
+	if cfg.RecencyWeight < 0 {
+		cfg.RecencyWeight = 0
+	}
+	if cfg.ImportanceWeight < 0 {
+		cfg.ImportanceWeight = 0
+	}
+	if cfg.RelevanceWeight < 0 {
+		cfg.RelevanceWeight = 0
+	}
+	if cfg.RecencyWeight+cfg.ImportanceWeight+cfg.RelevanceWeight == 0 {
+		cfg.RecencyWeight, cfg.ImportanceWeight, cfg.RelevanceWeight = 1, 1, 1
+	}
+	if cfg.RecencyHalfLifeDays <= 0 {
+		cfg.RecencyHalfLifeDays = defaultEventRecencyHalfLifeDays
+	}
+	if cfg.CandidateLimit < 0 {
+		cfg.CandidateLimit = 0
+	}
+	return cfg
+}
+
+// RankedUserMemoryEvents 返回按 EventRanking 打分后最值得注入上下文的 limit 条事件，
+// 结果按 EventDate 倒序排列。query 一般为当前用户消息，为空时不计算相关度。
+func (m *MemoryManager) RankedUserMemoryEvents(ctx context.Context, userID, query string, limit int) ([]*UserMemoryEvent, error) {
+	store := m.userMemoryEventStorage()
+	if store == nil || limit <= 0 {
+		return nil, nil
+	}
+	cfg := normalizeEventRankingConfig(m.config.EventRanking)
+	candidateLimit := cfg.CandidateLimit
+	if candidateLimit <= 0 {
+		candidateLimit = max(limit*5, minEventRankingCandidates)
+	}
+
+	candidates, err := store.ListRecentUserMemoryEvents(ctx, userID, candidateLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokenizer builtinsearch.Tokenizer
+	bm25Params := builtinsearch.BM25Params{}
+	if m.config.Search != nil {
+		tokenizer = m.config.Search.Tokenizer
+		if m.config.Search.BM25 != nil {
+			bm25Params = m.config.Search.BM25.params()
+		}
+	}
+	var keywords []string
+	if cfg.RelevanceWeight > 0 {
+		keywords = builtinsearch.InferKeywordsWith(tokenizer, query)
+	}
+	if len(keywords) > 0 {
+		// 补充关键词命中但不在最近候选中的较早事件
+		matched, err := store.SearchUserMemoryEvents(ctx, &UserMemoryEventQuery{
+			UserID:   userID,
+			Keywords: keywords,
+			Match:    builtinsearch.MatchAny,
+			Limit:    candidateLimit,
+		})
+		if err != nil {
+			slog.Warnf("检索相关用户记忆事件失败，仅按最近事件排序: %v", err)
+		} else {
+			candidates = mergeEventCandidates(candidates, matched)
+		}
+	}
+	if len(candidates) <= limit && len(keywords) == 0 {
+		return candidates, nil
+	}
+
+	relevance := make(map[string]float64)
+	if len(keywords) > 0 {
+		docs := make([]builtinsearch.BM25Document, 0, len(candidates))
+		for _, evt := range candidates {
+			text := evt.Summary
+			if len(evt.Keywords) > 0 {
+				text += "\n" + strings.Join(evt.Keywords, " ")
+			}
+			docs = append(docs, builtinsearch.BM25Document{ID: evt.ID, Text: text})
+		}
+		for _, score := range builtinsearch.RankBM25(tokenizer, bm25Params, docs, keywords, builtinsearch.MatchAny) {
+			relevance[score.ID] = score.Score
+		}
+	}
+
+	now := time.Now()
+	scores := make(map[string]float64, len(candidates))
+	for _, evt := range candidates {
+		scores[evt.ID] = cfg.RecencyWeight*eventRecencyScore(evt, now, cfg.RecencyHalfLifeDays) +
+			cfg.ImportanceWeight*eventImportanceScore(evt) +
+			cfg.RelevanceWeight*relevance[evt.ID]
+	}
+	ranked := append([]*UserMemoryEvent(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i].ID] > scores[ranked[j].ID]
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].EventDate.Equal(ranked[j].EventDate) {
+			return ranked[i].CreatedAt.After(ranked[j].CreatedAt)
+		}
+		return ranked[i].EventDate.After(ranked[j].EventDate)
+	})
+	return ranked, nil
+}
+
+// eventRecencyScore 按 EventDate 指数衰减，未来日期（如约定的截止日）视为当前
+func eventRecencyScore(evt *UserMemoryEvent, now time.Time, halfLifeDays float64) float64 {
+	at := evt.EventDate
+	if at.IsZero() {
+		at = evt.CreatedAt
+	}
+	ageDays := now.Sub(at).Hours() / 24
+	if ageDays <= 0 {
+		return 1
+	}
+	return math.Exp2(-ageDays / halfLifeDays)
+}
+
+// eventImportanceScore 将 1~5 的重要程度映射到 [0, 1]，被检索命中过的事件额外加分（至多 0.2）
+func eventImportanceScore(evt *UserMemoryEvent) float64 {
+	score := float64(memoryevent.NormalizeImportance(evt.Importance)-memoryevent.ImportanceMin) /
+		float64(memoryevent.ImportanceMax-memoryevent.ImportanceMin)
+	if evt.AccessCount > 0 {
+		score += math.Min(0.2, 0.05*math.Log1p(float64(evt.AccessCount)))
+	}
+	return math.Min(1, score)
+}
+
+func mergeEventCandidates(base, extra []*UserMemoryEvent) []*UserMemoryEvent {
+	seen := make(map[string]struct{}, len(base))
+	for _, evt := range base {
+		seen[evt.ID] = struct{}{}
+	}
+	for _, evt := range extra {
+		if evt == nil {
+			continue
+		}
+		if _, ok := seen[evt.ID]; ok {
+			continue
+		}
+		seen[evt.ID] = struct{}{}
+		base = append(base, evt)
+	}
+	return base
+}
+
+// touchUserMemoryEvents 记录检索命中，存储未实现 UserMemoryEventAccessStorage 时忽略
+func (m *MemoryManager) touchUserMemoryEvents(ctx context.Context, userID string, events []*UserMemoryEvent) {
+	store, ok := m.storage.(UserMemoryEventAccessStorage)
+	if !ok || userID == "" || len(events) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(events))
+	for _, evt := range events {
+		if evt != nil {
+			ids = append(ids, evt.ID)
+		}
+	}
+	if err := store.TouchUserMemoryEvents(ctx, userID, ids, time.Now()); err != nil {
+		slog.Warnf("更新用户记忆事件访问记录失败: %v", err)
+	}
+}
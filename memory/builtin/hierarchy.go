@@ -0,0 +1,475 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
+	"github.com/CoolBanHub/aggo/utils"
+	"github.com/gookit/slog"
+)
+
+const (
+	defaultChunkMessages         = 20
+	defaultRollupChunks          = 4
+	defaultRetrieveChunkLimit    = 3
+	defaultUserDigestPeriodDays  = 7
+	defaultUserDigestMaxSessions = 20
+	// 生成周期摘要时，每个会话最多纳入的未摘要消息数
+	userDigestMessageLimit = 30
+	// 周期摘要未到期时，两次到期检查之间的最短间隔
+	userDigestRecheckInterval = time.Hour
+)
+
+// HierarchicalSummaryConfig 分层会话摘要配置。
+// 未摘要消息每满 ChunkMessages 条生成一个分段摘要（记录消息ID范围），分段累积到 RollupChunks 个后汇总进会话总摘要；
+// 检索时注入总摘要、尚未汇总的分段，以及按与当前消息相关度选出的已汇总分段。
+type HierarchicalSummaryConfig struct {
+	// 每个分段覆盖的消息数，默认 20
+	ChunkMessages int `json:"chunkMessages"`
+	// 未汇总的分段达到该数量时汇总进总摘要，默认 4
+	RollupChunks int `json:"rollupChunks"`
+	// 检索时按相关度额外注入的已汇总分段数，默认 3；设为负数表示不注入
+	RetrieveChunkLimit int `json:"retrieveChunkLimit"`
+}
+
+// UserDigestConfig 跨会话用户周期摘要配置。
+// 助手回复后检查用户上一份周期摘要是否已过期，过期时异步汇总该周期内的全部会话。
+type UserDigestConfig struct {
+	// 摘要周期（天），默认 7
+	PeriodDays int `json:"periodDays"`
+	// 每份摘要最多纳入的会话数（最近活跃优先），默认 20
+	MaxSessions int `json:"maxSessions"`
+	// 检索时是否注入最近一份周期摘要
+	Inject bool `json:"inject"`
+}
+
+func normalizeHierarchicalSummaryConfig(cfg *HierarchicalSummaryConfig) *HierarchicalSummaryConfig {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.ChunkMessages <= 0 {
+		cfg.ChunkMessages = defaultChunkMessages
+	}
+	if cfg.RollupChunks <= 0 {
+		cfg.RollupChunks = defaultRollupChunks
+	}
+	if cfg.RetrieveChunkLimit == 0 {
+		cfg.RetrieveChunkLimit = defaultRetrieveChunkLimit
+	}
+	return cfg
+}
+
+func normalizeUserDigestConfig(cfg *UserDigestConfig) *UserDigestConfig {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.PeriodDays <= 0 {
+		cfg.PeriodDays = defaultUserDigestPeriodDays
+	}
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = defaultUserDigestMaxSessions
+	}
+	return cfg
+}
+
+func (m *MemoryManager) summaryChunkStorage() SummaryChunkStorage {
+	s, _ := m.storage.(SummaryChunkStorage)
+	return s
+}
+
+func (m *MemoryManager) userDigestStorage() UserDigestStorage {
+	s, _ := m.storage.(UserDigestStorage)
+	return s
+}
+
+// hierarchicalSummary 返回生效的分层摘要配置与存储，未启用时 store 为 nil
+func (m *MemoryManager) hierarchicalSummary() (*HierarchicalSummaryConfig, SummaryChunkStorage) {
+	cfg := m.config.HierarchicalSummary
+	if cfg == nil || !m.config.EnableSessionSummary {
+		return nil, nil
+	}
+	return cfg, m.summaryChunkStorage()
+}
+
+// updateHierarchicalSummary 把游标后的消息按 ChunkMessages 切成分段摘要，不足一段的消息留到下次；
+// 还没有总摘要或未汇总的分段达到 RollupChunks 时，把它们汇总进总摘要。返回游标是否前进。
+func (m *MemoryManager) updateHierarchicalSummary(ctx context.Context, userID, sessionID string, cfg *HierarchicalSummaryConfig, store SummaryChunkStorage) (bool, error) {
+	existingSummary, err := m.GetSessionSummary(ctx, sessionID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	var pending []*ConversationMessage
+	if existingSummary != nil {
+		pending, err = m.getMessagesAfterCursor(ctx, sessionID, userID,
+			existingSummary.LastSummarizedMessageID, effectiveSummaryBoundary(existingSummary), 0)
+	} else {
+		pending, err = m.storage.GetMessages(ctx, sessionID, userID, 0)
+	}
+	if err != nil {
+		return false, fmt.Errorf("获取未摘要消息失败: %w", err)
+	}
+	if len(pending) < cfg.ChunkMessages {
+		return false, nil
+	}
+
+	chunks, err := store.ListSummaryChunks(ctx, sessionID, userID)
+	if err != nil {
+		return false, fmt.Errorf("获取分段摘要失败: %w", err)
+	}
+
+	var lastMessage *ConversationMessage
+	for len(pending) >= cfg.ChunkMessages {
+		batch := pending[:cfg.ChunkMessages]
+		pending = pending[cfg.ChunkMessages:]
+
+		content, err := m.sessionSummaryGenerator.GenerateSummary(ctx, batch, "")
+		if err != nil {
+			return false, fmt.Errorf("生成分段摘要失败: %w", err)
+		}
+		first, last := batch[0], batch[len(batch)-1]
+		chunk := &SummaryChunk{
+			SessionID:      sessionID,
+			UserID:         userID,
+			Summary:        content,
+			StartMessageID: first.ID,
+			EndMessageID:   last.ID,
+			StartAt:        first.CreatedAt,
+			EndAt:          last.CreatedAt,
+			MessageCount:   len(batch),
+		}
+		if err := store.SaveSummaryChunk(ctx, chunk); err != nil {
+			return false, fmt.Errorf("保存分段摘要失败: %w", err)
+		}
+		chunks = append(chunks, chunk)
+		lastMessage = last
+	}
+
+	summary := existingSummary
+	if summary == nil {
+		summary = &SessionSummary{SessionID: sessionID, UserID: userID}
+	}
+	rolled := min(summary.RolledUpChunkCount, len(chunks))
+	if summary.Summary == "" || len(chunks)-rolled >= cfg.RollupChunks {
+		abstract, err := m.sessionSummaryGenerator.GenerateAbstract(ctx, chunks[rolled:], summary.Summary)
+		if err != nil {
+			return false, err
+		}
+		summary.Summary = abstract
+		rolled = len(chunks)
+	}
+	summary.RolledUpChunkCount = rolled
+	summary.LastSummarizedMessageID = lastMessage.ID
+	summary.LastSummarizedMessageAt = lastMessage.CreatedAt
+
+	if existingSummary != nil {
+		err = m.storage.UpdateSessionSummary(ctx, summary)
+	} else {
+		err = m.storage.SaveSessionSummary(ctx, summary)
+	}
+	if err != nil {
+		return false, err
+	}
+	m.cacheSessionSummary(summary)
+	m.notifyChange(ctx, ChangeSessionSummaryUpdated, summary)
+	return true, nil
+}
+
+// ListSummaryChunks 按时间顺序返回会话的分段摘要。存储未实现 SummaryChunkStorage 时返回错误。
+func (m *MemoryManager) ListSummaryChunks(ctx context.Context, sessionID, userID string) ([]*SummaryChunk, error) {
+	store := m.summaryChunkStorage()
+	if store == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 SummaryChunkStorage")
+	}
+	return store.ListSummaryChunks(ctx, sessionID, userID)
+}
+
+// RelevantSummaryChunks 返回检索时需要注入的分段摘要，按时间顺序排列：
+// 尚未汇总进总摘要的分段全部返回；已汇总的分段按与 query 的 BM25 相关度取前 RetrieveChunkLimit 个。
+// 未启用分层摘要时返回 nil。
+func (m *MemoryManager) RelevantSummaryChunks(ctx context.Context, sessionID, userID, query string) ([]*SummaryChunk, error) {
+	cfg, store := m.hierarchicalSummary()
+	if store == nil {
+		return nil, nil
+	}
+	chunks, err := store.ListSummaryChunks(ctx, sessionID, userID)
+	if err != nil || len(chunks) == 0 {
+		return nil, err
+	}
+	summary, err := m.GetSessionSummary(ctx, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	rolled := 0
+	if summary != nil {
+		rolled = min(summary.RolledUpChunkCount, len(chunks))
+	}
+
+	selected := make(map[int]bool)
+	for i := rolled; i < len(chunks); i++ {
+		selected[i] = true
+	}
+	if rolled > 0 && cfg.RetrieveChunkLimit > 0 {
+		var tokenizer builtinsearch.Tokenizer
+		bm25Params := builtinsearch.BM25Params{}
+		if m.config.Search != nil {
+			tokenizer = m.config.Search.Tokenizer
+			if m.config.Search.BM25 != nil {
+				bm25Params = m.config.Search.BM25.params()
+			}
+		}
+		if keywords := builtinsearch.InferKeywordsWith(tokenizer, query); len(keywords) > 0 {
+			docs := make([]builtinsearch.BM25Document, 0, rolled)
+			index := make(map[string]int, rolled)
+			for i, chunk := range chunks[:rolled] {
+				docs = append(docs, builtinsearch.BM25Document{ID: chunk.ID, Text: chunk.Summary})
+				index[chunk.ID] = i
+			}
+			scores := builtinsearch.RankBM25(tokenizer, bm25Params, docs, keywords, builtinsearch.MatchAny)
+			sort.SliceStable(scores, func(i, j int) bool { return scores[i].Score > scores[j].Score })
+			for _, score := range scores {
+				if len(selected) >= len(chunks)-rolled+cfg.RetrieveChunkLimit {
+					break
+				}
+				if score.Score > 0 {
+					selected[index[score.ID]] = true
+				}
+			}
+		}
+	}
+
+	result := make([]*SummaryChunk, 0, len(selected))
+	for i, chunk := range chunks {
+		if selected[i] {
+			result = append(result, chunk)
+		}
+	}
+	return result, nil
+}
+
+// GenerateUserDigest 汇总用户在 [periodStart, periodEnd) 内活跃过的会话，生成并保存一份周期摘要。
+// 周期内没有活跃会话时返回 nil, nil。需要存储同时实现 UserDigestStorage 与 SessionStorage。
+func (m *MemoryManager) GenerateUserDigest(ctx context.Context, userID string, periodStart, periodEnd time.Time) (*UserDigest, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("用户ID不能为空")
+	}
+	if !periodEnd.After(periodStart) {
+		return nil, fmt.Errorf("周期结束时间必须晚于开始时间")
+	}
+	digestStore := m.userDigestStorage()
+	if digestStore == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 UserDigestStorage")
+	}
+	sessionStore := m.sessionStorage()
+	if sessionStore == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 SessionStorage")
+	}
+
+	sessions, err := sessionStore.ListSessions(ctx, &SessionQuery{UserID: userID, IncludeArchived: true})
+	if err != nil {
+		return nil, fmt.Errorf("获取会话列表失败: %w", err)
+	}
+	maxSessions := defaultUserDigestMaxSessions
+	if cfg := m.config.UserDigest; cfg != nil {
+		maxSessions = cfg.MaxSessions
+	}
+	var active []*Session
+	for _, session := range sessions {
+		activeAt := session.LastActiveAt
+		if activeAt.IsZero() {
+			activeAt = session.CreatedAt
+		}
+		if activeAt.Before(periodStart) || !session.CreatedAt.Before(periodEnd) {
+			continue
+		}
+		active = append(active, session)
+		if len(active) >= maxSessions {
+			break
+		}
+	}
+	if len(active) == 0 {
+		return nil, nil
+	}
+
+	// 按时间顺序组织材料
+	var (
+		materials  []string
+		sessionIDs []string
+	)
+	for i := len(active) - 1; i >= 0; i-- {
+		material, err := m.userDigestMaterial(ctx, active[i], periodStart, periodEnd)
+		if err != nil {
+			return nil, err
+		}
+		if material == "" {
+			continue
+		}
+		materials = append(materials, material)
+		sessionIDs = append(sessionIDs, active[i].SessionID)
+	}
+	if len(materials) == 0 {
+		return nil, nil
+	}
+
+	content, err := m.sessionSummaryGenerator.GenerateDigest(ctx, periodStart, periodEnd, materials)
+	if err != nil {
+		return nil, err
+	}
+	if content == "" {
+		return nil, nil
+	}
+	digest := &UserDigest{
+		ID:          utils.GetULID(),
+		UserID:      userID,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Digest:      content,
+		SessionIDs:  sessionIDs,
+		CreatedAt:   time.Now(),
+	}
+	if err := digestStore.SaveUserDigest(ctx, digest); err != nil {
+		return nil, fmt.Errorf("保存用户周期摘要失败: %w", err)
+	}
+	m.notifyChange(ctx, ChangeUserDigestCreated, digest)
+	return digest, nil
+}
+
+// userDigestMaterial 整理单个会话在周期内的材料：优先使用周期内的分段摘要，没有时使用会话摘要，
+// 再补充周期内尚未摘要的消息
+func (m *MemoryManager) userDigestMaterial(ctx context.Context, session *Session, periodStart, periodEnd time.Time) (string, error) {
+	var sections []string
+
+	var chunkTexts []string
+	if store := m.summaryChunkStorage(); store != nil {
+		chunks, err := store.ListSummaryChunks(ctx, session.SessionID, session.UserID)
+		if err != nil {
+			return "", fmt.Errorf("获取分段摘要失败: %w", err)
+		}
+		for _, chunk := range chunks {
+			if chunk.EndAt.Before(periodStart) || !chunk.StartAt.Before(periodEnd) {
+				continue
+			}
+			chunkTexts = append(chunkTexts, fmt.Sprintf("（%s）\n%s", formatChunkRange(chunk), chunk.Summary))
+		}
+	}
+	summary, err := m.GetSessionSummary(ctx, session.SessionID, session.UserID)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(chunkTexts) > 0:
+		sections = append(sections, "分段摘要：\n"+strings.Join(chunkTexts, "\n\n"))
+	case summary != nil && summary.Summary != "":
+		sections = append(sections, "会话摘要：\n"+summary.Summary)
+	}
+
+	messages, err := m.storage.GetMessages(ctx, session.SessionID, session.UserID, userDigestMessageLimit)
+	if err != nil {
+		return "", fmt.Errorf("获取会话消息失败: %w", err)
+	}
+	var recent []*ConversationMessage
+	for _, msg := range unsummarizedMessages(messages, summary) {
+		if !msg.CreatedAt.Before(periodStart) && msg.CreatedAt.Before(periodEnd) {
+			recent = append(recent, msg)
+		}
+	}
+	if text := buildConversationHistoryPlainText(recent); text != "" {
+		sections = append(sections, "对话片段：\n"+text)
+	}
+	if len(sections) == 0 {
+		return "", nil
+	}
+
+	title := session.Title
+	if title == "" {
+		title = session.SessionID
+	}
+	return fmt.Sprintf("### 会话：%s\n%s", title, strings.Join(sections, "\n\n")), nil
+}
+
+// ListUserDigests 按周期倒序返回用户的周期摘要。存储未实现 UserDigestStorage 时返回错误。
+func (m *MemoryManager) ListUserDigests(ctx context.Context, userID string, limit int) ([]*UserDigest, error) {
+	store := m.userDigestStorage()
+	if store == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 UserDigestStorage")
+	}
+	return store.ListUserDigests(ctx, userID, limit)
+}
+
+// LatestUserDigest 返回用户最近一份周期摘要，没有或存储不支持时返回 nil
+func (m *MemoryManager) LatestUserDigest(ctx context.Context, userID string) (*UserDigest, error) {
+	store := m.userDigestStorage()
+	if store == nil {
+		return nil, nil
+	}
+	digests, err := store.ListUserDigests(ctx, userID, 1)
+	if err != nil || len(digests) == 0 {
+		return nil, err
+	}
+	return digests[0], nil
+}
+
+// scheduleUserDigest 在用户的周期摘要可能到期时提交异步任务，到期检查结果按用户缓存，避免每轮对话都查询存储
+func (m *MemoryManager) scheduleUserDigest(userID string) {
+	if m.config.UserDigest == nil || m.userDigestStorage() == nil || m.sessionStorage() == nil {
+		return
+	}
+	now := time.Now()
+	if next, ok := m.userDigestNextCheck.Load(userID); ok && now.Before(next.(time.Time)) {
+		return
+	}
+	m.userDigestNextCheck.Store(userID, now.Add(userDigestRecheckInterval))
+	m.submitAsyncTask(asyncTask{taskType: "digest", userID: userID})
+}
+
+// generateDueUserDigest 上一份周期摘要结束已满一个周期时生成新的周期摘要。
+// 没有周期摘要时以用户最早的会话时间为起点；长期不活跃的用户只回顾最近一个周期。
+func (m *MemoryManager) generateDueUserDigest(ctx context.Context, userID string) {
+	cfg := m.config.UserDigest
+	if cfg == nil {
+		return
+	}
+	period := time.Duration(cfg.PeriodDays) * 24 * time.Hour
+	now := time.Now()
+
+	latest, err := m.LatestUserDigest(ctx, userID)
+	if err != nil {
+		slog.Errorf("获取用户周期摘要失败: userID=%s, err=%v", userID, err)
+		return
+	}
+	var start time.Time
+	if latest != nil {
+		start = latest.PeriodEnd
+	} else if store := m.sessionStorage(); store != nil {
+		sessions, err := store.ListSessions(ctx, &SessionQuery{UserID: userID, IncludeArchived: true})
+		if err != nil {
+			slog.Errorf("获取会话列表失败: userID=%s, err=%v", userID, err)
+			return
+		}
+		for _, session := range sessions {
+			if !session.CreatedAt.IsZero() && (start.IsZero() || session.CreatedAt.Before(start)) {
+				start = session.CreatedAt
+			}
+		}
+	}
+	if start.IsZero() {
+		return
+	}
+	if due := start.Add(period); now.Before(due) {
+		m.userDigestNextCheck.Store(userID, due)
+		return
+	}
+	if start.Before(now.Add(-period)) {
+		start = now.Add(-period)
+	}
+
+	if _, err := m.GenerateUserDigest(ctx, userID, start, now); err != nil {
+		slog.Errorf("生成用户周期摘要失败: userID=%s, err=%v", userID, err)
+		return
+	}
+	m.userDigestNextCheck.Store(userID, now.Add(period))
+}
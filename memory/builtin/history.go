@@ -0,0 +1,452 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components"
+	"github.com/gookit/slog"
+)
+
+const (
+	defaultUserMemoryMaxShrinkRatio      = 0.5
+	defaultUserMemoryMinShrinkCheckRunes = 200
+	defaultUserMemoryMaxConflictRetries  = 2
+	// 去掉首尾相同的行后，剩余部分的 LCS 表超过该格数时不再计算逐行差异，直接记录整体替换，
+	// 把 O(n*m) 的内存控制在约 1 MB 以内
+	maxUserMemoryDiffCells = 250_000
+)
+
+// ErrUserMemoryShrinkRejected 更新会让用户记忆大幅缩短，被安全检查拦下。
+// 被拦下的内容以 rejected 状态记录在历史中，确认无误后可调用 RestoreUserMemoryRevision 应用。
+var ErrUserMemoryShrinkRejected = errors.New("用户记忆更新被拒绝：内容缩减超过阈值")
+
+// ErrUserMemoryRevisionNotFound 指定的用户记忆版本不存在
+var ErrUserMemoryRevisionNotFound = errors.New("用户记忆版本不存在")
+
+// UserMemoryHistoryConfig 用户记忆版本历史与安全检查配置
+type UserMemoryHistoryConfig struct {
+	// 允许单次更新删减的最大比例，默认 0.5：新内容短于原内容一半时拒绝
+	MaxShrinkRatio float64 `json:"maxShrinkRatio"`
+	// 原内容少于该字数时不做缩减检查，默认 200
+	MinShrinkCheckRunes int `json:"minShrinkCheckRunes"`
+	// 关闭缩减检查
+	DisableShrinkCheck bool `json:"disableShrinkCheck"`
+	// 写入时发现记忆已被其他写入修改（版本冲突）后的最大重试次数，默认 2。
+	// analyzer 基于最新记忆重新分析，记忆编辑基于最新内容重新应用。
+	MaxConflictRetries int `json:"maxConflictRetries"`
+}
+
+func normalizeUserMemoryHistoryConfig(cfg *UserMemoryHistoryConfig) *UserMemoryHistoryConfig {
+	if cfg == nil {
+		cfg = &UserMemoryHistoryConfig{}
+	}
+	if cfg.MaxShrinkRatio <= 0 || cfg.MaxShrinkRatio >= 1 {
+		cfg.MaxShrinkRatio = defaultUserMemoryMaxShrinkRatio
+	}
+	if cfg.MinShrinkCheckRunes <= 0 {
+		cfg.MinShrinkCheckRunes = defaultUserMemoryMinShrinkCheckRunes
+	}
+	if cfg.MaxConflictRetries <= 0 {
+		cfg.MaxConflictRetries = defaultUserMemoryMaxConflictRetries
+	}
+	return cfg
+}
+
+// UserMemoryUpdate 一次用户记忆更新
+type UserMemoryUpdate struct {
+	// 新的完整记忆内容
+	Memory string
+	// 来源 analyzer / manual / restore，默认 manual
+	Source string
+	// 产生该更新的会话
+	SessionID string
+	// 产生该更新的模型
+	Model string
+	// 跳过缩减检查
+	Force bool
+	// 记录在版本上的说明
+	Reason string
+	// BaseVersion 生成 Memory 时读取到的记忆版本（记忆不存在时为 0）。
+	// 非空且当前版本不同时不写入，返回 ErrUserMemoryConflict；为空时以最新内容为准直接覆盖。
+	BaseVersion *int64
+}
+
+func (m *MemoryManager) userMemoryHistoryStorage() UserMemoryHistoryStorage {
+	s, _ := m.storage.(UserMemoryHistoryStorage)
+	return s
+}
+
+// UpdateUserMemory 写入用户记忆并记录版本历史。
+// 内容未变化时返回 nil, nil；未通过缩减检查时返回 ErrUserMemoryShrinkRejected 与被拒绝的版本（存储支持历史时）。
+func (m *MemoryManager) UpdateUserMemory(ctx context.Context, userID string, update *UserMemoryUpdate) (*UserMemoryRevision, error) {
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+	if update == nil || strings.TrimSpace(update.Memory) == "" {
+		return nil, errors.New("记忆内容不能为空")
+	}
+	source := update.Source
+	if source == "" {
+		source = UserMemorySourceManual
+	}
+
+	m.userMemoryMu.Lock()
+	defer m.userMemoryMu.Unlock()
+	return m.updateUserMemoryLocked(ctx, userID, source, update)
+}
+
+// updateUserMemoryLocked 调用方需持有 userMemoryMu。
+// userMemoryMu 只能串行化本进程内的写入，写入时再按版本号比较，避免覆盖其他进程在读取之后的写入；
+// 未指定 BaseVersion 的更新在冲突后重新读取并重试。
+func (m *MemoryManager) updateUserMemoryLocked(ctx context.Context, userID, source string, update *UserMemoryUpdate) (*UserMemoryRevision, error) {
+	retries := normalizeUserMemoryHistoryConfig(m.config.UserMemoryHistory).MaxConflictRetries
+	for attempt := 0; ; attempt++ {
+		revision, err := m.tryUpdateUserMemory(ctx, userID, source, update)
+		if errors.Is(err, ErrUserMemoryConflict) && update.BaseVersion == nil && attempt < retries {
+			continue
+		}
+		return revision, err
+	}
+}
+
+// tryUpdateUserMemory 读取当前记忆并按其版本号写入一次
+func (m *MemoryManager) tryUpdateUserMemory(ctx context.Context, userID, source string, update *UserMemoryUpdate) (*UserMemoryRevision, error) {
+	existing, err := m.storage.GetUserMemory(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取现有用户记忆失败: %w", err)
+	}
+	previous := ""
+	var version int64
+	if existing != nil {
+		previous = existing.Memory
+		version = existing.Version
+	}
+	if previous == update.Memory {
+		return nil, nil
+	}
+	if update.BaseVersion != nil && *update.BaseVersion != version {
+		return nil, fmt.Errorf("%w: 基于版本 %d 生成，当前版本 %d", ErrUserMemoryConflict, *update.BaseVersion, version)
+	}
+
+	history := m.userMemoryHistoryStorage()
+	if history != nil && existing != nil {
+		if err := m.ensureUserMemoryBaseline(ctx, history, existing); err != nil {
+			slog.Warnf("补录用户记忆基线版本失败: %v", err)
+		}
+	}
+
+	revision := &UserMemoryRevision{
+		UserID:    userID,
+		Memory:    update.Memory,
+		Diff:      diffUserMemory(previous, update.Memory),
+		Status:    UserMemoryRevisionApplied,
+		Source:    source,
+		SessionID: update.SessionID,
+		Model:     update.Model,
+		Reason:    update.Reason,
+	}
+
+	if !update.Force {
+		if reason := m.checkUserMemoryShrink(previous, update.Memory); reason != "" {
+			revision.Status = UserMemoryRevisionRejected
+			revision.Reason = reason
+			if history == nil {
+				return nil, fmt.Errorf("%w: %s", ErrUserMemoryShrinkRejected, reason)
+			}
+			if err := history.SaveUserMemoryRevision(ctx, revision); err != nil {
+				return nil, fmt.Errorf("记录被拒绝的用户记忆版本失败: %w", err)
+			}
+			return revision, fmt.Errorf("%w: %s（版本 %s）", ErrUserMemoryShrinkRejected, reason, revision.ID)
+		}
+	}
+
+	mem := &UserMemory{UserID: userID, Memory: update.Memory}
+	if existing != nil {
+		mem.CreatedAt = existing.CreatedAt
+	}
+	if err := m.saveUserMemory(ctx, mem, version); err != nil {
+		return nil, err
+	}
+	var applied *UserMemoryRevision
+	if history != nil {
+		if err := history.SaveUserMemoryRevision(ctx, revision); err != nil {
+			// 记忆已写入，历史记录失败不回滚，只提示
+			slog.Errorf("记录用户记忆版本失败: %v", err)
+		} else {
+			applied = revision
+		}
+	}
+	m.notifyChange(ctx, ChangeUserMemoryUpdated, &UserMemoryChange{
+		UserID:   userID,
+		Memory:   mem,
+		Revision: applied,
+		Source:   source,
+	})
+	return applied, nil
+}
+
+// saveUserMemory 存储实现 UserMemoryCASStorage 时仅在版本仍为 expectedVersion 时写入，否则直接覆盖
+func (m *MemoryManager) saveUserMemory(ctx context.Context, mem *UserMemory, expectedVersion int64) error {
+	if cas, ok := m.storage.(UserMemoryCASStorage); ok {
+		return cas.CompareAndSwapUserMemory(ctx, mem, expectedVersion)
+	}
+	return m.storage.UpsertUserMemory(ctx, mem)
+}
+
+// deleteUserMemoryLocked 删除用户记忆并记录一条内容为空的版本，调用方需持有 userMemoryMu。
+// 删除前的内容与最新版本不一致（或还没有历史）时先补录为基线，保证可以通过 RestoreUserMemoryRevision 恢复；
+// 存储实现 UserMemoryCASStorage 时仅在版本仍为 existing.Version 时删除，否则返回 ErrUserMemoryConflict。
+func (m *MemoryManager) deleteUserMemoryLocked(ctx context.Context, existing *UserMemory, source, reason string) (*UserMemoryRevision, error) {
+	history := m.userMemoryHistoryStorage()
+	if history != nil {
+		latest, err := history.ListUserMemoryRevisions(ctx, existing.UserID, 1)
+		if err == nil && (len(latest) == 0 || latest[0].Memory != existing.Memory) {
+			err = history.SaveUserMemoryRevision(ctx, &UserMemoryRevision{
+				UserID: existing.UserID,
+				Memory: existing.Memory,
+				Status: UserMemoryRevisionApplied,
+				Source: UserMemorySourceBaseline,
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("记录删除前的用户记忆版本失败: %w", err)
+		}
+	}
+
+	var err error
+	if cas, ok := m.storage.(UserMemoryCASStorage); ok {
+		err = cas.CompareAndDeleteUserMemory(ctx, existing.UserID, existing.Version)
+	} else {
+		err = m.storage.ClearUserMemory(ctx, existing.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var applied *UserMemoryRevision
+	if history != nil {
+		revision := &UserMemoryRevision{
+			UserID: existing.UserID,
+			Diff:   diffUserMemory(existing.Memory, ""),
+			Status: UserMemoryRevisionApplied,
+			Source: source,
+			Reason: reason,
+		}
+		if err := history.SaveUserMemoryRevision(ctx, revision); err != nil {
+			slog.Errorf("记录用户记忆版本失败: %v", err)
+		} else {
+			applied = revision
+		}
+	}
+	m.notifyChange(ctx, ChangeUserMemoryUpdated, &UserMemoryChange{UserID: existing.UserID, Revision: applied, Source: source})
+	return applied, nil
+}
+
+// ensureUserMemoryBaseline 用户还没有任何历史版本时，把当前记忆补录为基线，保证首次更新也能回滚
+func (m *MemoryManager) ensureUserMemoryBaseline(ctx context.Context, history UserMemoryHistoryStorage, existing *UserMemory) error {
+	revisions, err := history.ListUserMemoryRevisions(ctx, existing.UserID, 1)
+	if err != nil || len(revisions) > 0 {
+		return err
+	}
+	return history.SaveUserMemoryRevision(ctx, &UserMemoryRevision{
+		UserID: existing.UserID,
+		Memory: existing.Memory,
+		Status: UserMemoryRevisionApplied,
+		Source: UserMemorySourceBaseline,
+	})
+}
+
+// checkUserMemoryShrink 返回拒绝原因，通过检查时返回空字符串
+func (m *MemoryManager) checkUserMemoryShrink(previous, next string) string {
+	cfg := normalizeUserMemoryHistoryConfig(m.config.UserMemoryHistory)
+	if cfg.DisableShrinkCheck {
+		return ""
+	}
+	before := utf8.RuneCountInString(previous)
+	if before < cfg.MinShrinkCheckRunes {
+		return ""
+	}
+	after := utf8.RuneCountInString(next)
+	if float64(after) >= float64(before)*(1-cfg.MaxShrinkRatio) {
+		return ""
+	}
+	return fmt.Sprintf("内容从 %d 字缩减到 %d 字，超过允许的 %.0f%%", before, after, cfg.MaxShrinkRatio*100)
+}
+
+// ListUserMemoryRevisions 按版本号倒序返回用户记忆历史。存储未实现历史接口时返回错误。
+func (m *MemoryManager) ListUserMemoryRevisions(ctx context.Context, userID string, limit int) ([]*UserMemoryRevision, error) {
+	history := m.userMemoryHistoryStorage()
+	if history == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 UserMemoryHistoryStorage")
+	}
+	return history.ListUserMemoryRevisions(ctx, userID, limit)
+}
+
+// GetUserMemoryRevision 获取指定历史版本
+func (m *MemoryManager) GetUserMemoryRevision(ctx context.Context, userID, revisionID string) (*UserMemoryRevision, error) {
+	history := m.userMemoryHistoryStorage()
+	if history == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 UserMemoryHistoryStorage")
+	}
+	revision, err := history.GetUserMemoryRevision(ctx, userID, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if revision == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUserMemoryRevisionNotFound, revisionID)
+	}
+	return revision, nil
+}
+
+// DiffUserMemoryRevisions 返回两个版本之间的行级差异。toRevisionID 为空时与当前记忆对比。
+func (m *MemoryManager) DiffUserMemoryRevisions(ctx context.Context, userID, fromRevisionID, toRevisionID string) (string, error) {
+	from, err := m.GetUserMemoryRevision(ctx, userID, fromRevisionID)
+	if err != nil {
+		return "", err
+	}
+	to := ""
+	if toRevisionID != "" {
+		revision, err := m.GetUserMemoryRevision(ctx, userID, toRevisionID)
+		if err != nil {
+			return "", err
+		}
+		to = revision.Memory
+	} else {
+		current, err := m.storage.GetUserMemory(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+		if current != nil {
+			to = current.Memory
+		}
+	}
+	return diffUserMemory(from.Memory, to), nil
+}
+
+// RestoreUserMemoryRevision 将用户记忆恢复为指定版本的内容，并追加一条 restore 版本。
+// 对 rejected 版本调用即表示确认该更新；恢复到内容为空的版本（删除记录）时清空用户记忆。
+func (m *MemoryManager) RestoreUserMemoryRevision(ctx context.Context, userID, revisionID string) (*UserMemoryRevision, error) {
+	revision, err := m.GetUserMemoryRevision(ctx, userID, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	reason := fmt.Sprintf("恢复自版本 %d（%s）", revision.Revision, revision.ID)
+	if strings.TrimSpace(revision.Memory) == "" {
+		return m.restoreEmptyUserMemory(ctx, userID, reason)
+	}
+	return m.UpdateUserMemory(ctx, userID, &UserMemoryUpdate{
+		Memory:    revision.Memory,
+		Source:    UserMemorySourceRestore,
+		SessionID: revision.SessionID,
+		Model:     revision.Model,
+		Force:     true,
+		Reason:    reason,
+	})
+}
+
+// restoreEmptyUserMemory 按删除流程清空用户记忆，与其他写入共用 userMemoryMu 与版本比较，冲突后基于最新版本重试。
+// 记忆已经为空时返回 nil, nil
+func (m *MemoryManager) restoreEmptyUserMemory(ctx context.Context, userID, reason string) (*UserMemoryRevision, error) {
+	m.userMemoryMu.Lock()
+	defer m.userMemoryMu.Unlock()
+
+	retries := normalizeUserMemoryHistoryConfig(m.config.UserMemoryHistory).MaxConflictRetries
+	for attempt := 0; ; attempt++ {
+		existing, err := m.storage.GetUserMemory(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("获取现有用户记忆失败: %w", err)
+		}
+		if existing == nil {
+			return nil, nil
+		}
+		revision, err := m.deleteUserMemoryLocked(ctx, existing, UserMemorySourceRestore, reason)
+		if errors.Is(err, ErrUserMemoryConflict) && attempt < retries {
+			continue
+		}
+		return revision, err
+	}
+}
+
+// analyzerModelName 返回记录在版本上的分析模型：优先使用配置的 ModelName，未配置时退化为模型组件的类型名
+func (m *MemoryManager) analyzerModelName() string {
+	if m.config != nil && m.config.ModelName != "" {
+		return m.config.ModelName
+	}
+	if m.userMemoryAnalyzer == nil || m.userMemoryAnalyzer.cm == nil {
+		return ""
+	}
+	typ, _ := components.GetType(m.userMemoryAnalyzer.cm)
+	return typ
+}
+
+// diffUserMemory 计算两段文本的行级差异，输出 "- " 删除行与 "+ " 新增行，未变化的行省略
+func diffUserMemory(before, after string) string {
+	if before == after {
+		return ""
+	}
+	a := splitDiffLines(before)
+	b := splitDiffLines(after)
+	// 记忆编辑通常只改动局部，首尾相同的行不参与 LCS
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	if (len(a)+1)*(len(b)+1) > maxUserMemoryDiffCells {
+		return renderDiffLines(a, "- ") + renderDiffLines(b, "+ ")
+	}
+
+	// 最长公共子序列，lcs[i][j] 为 a[i:] 与 b[j:] 的 LCS 长度
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			sb.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	sb.WriteString(renderDiffLines(a[i:], "- "))
+	sb.WriteString(renderDiffLines(b[j:], "+ "))
+	return sb.String()
+}
+
+func splitDiffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(s, "\n"), "\n")
+}
+
+func renderDiffLines(lines []string, prefix string) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(prefix + line + "\n")
+	}
+	return sb.String()
+}
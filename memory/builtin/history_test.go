@@ -0,0 +1,72 @@
+package builtin
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// typedAgenticModel 带组件类型名的模型
+type typedAgenticModel struct {
+	captureAgenticModel
+}
+
+func (*typedAgenticModel) GetType() string { return "OpenAI" }
+
+func TestAnalyzerModelName(t *testing.T) {
+	cases := []struct {
+		name      string
+		modelName string
+		want      string
+	}{
+		{name: "configured model name", modelName: "gpt-4o-mini", want: "gpt-4o-mini"},
+		{name: "falls back to component type", want: "OpenAI"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &MemoryManager{
+				config:             &MemoryConfig{ModelName: tc.modelName},
+				userMemoryAnalyzer: NewUserMemoryAnalyzer(&typedAgenticModel{}),
+			}
+			if got := m.analyzerModelName(); got != tc.want {
+				t.Fatalf("analyzerModelName() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiffUserMemory(t *testing.T) {
+	before := "# 用户记忆\n### 基础信息\n- 职业：Golang 程序员\n- 城市：杭州"
+	after := "# 用户记忆\n### 基础信息\n- 职业：Golang 程序员\n- 城市：上海\n- 饮食：不吃辣"
+
+	want := "- - 城市：杭州\n+ - 城市：上海\n+ - 饮食：不吃辣\n"
+	if got := diffUserMemory(before, after); got != want {
+		t.Fatalf("diff mismatch:\n%s\nwant:\n%s", got, want)
+	}
+	if got := diffUserMemory(before, before); got != "" {
+		t.Fatalf("identical documents should have empty diff, got %q", got)
+	}
+	if got := diffUserMemory("", "a"); got != "+ a\n" {
+		t.Fatalf("unexpected diff for new document: %q", got)
+	}
+}
+
+func TestDiffUserMemoryLargeDocument(t *testing.T) {
+	var lines []string
+	for i := 0; i < 5000; i++ {
+		lines = append(lines, fmt.Sprintf("- 事实 %d", i))
+	}
+	before := strings.Join(lines, "\n")
+	edited := append(append([]string{}, lines[:2500]...), "- 新增事实")
+	after := strings.Join(append(edited, lines[2500:]...), "\n")
+
+	// 首尾相同的行不参与 LCS，大文档中的局部修改仍输出逐行差异
+	if got := diffUserMemory(before, after); got != "+ - 新增事实\n" {
+		t.Fatalf("unexpected diff: %q", got)
+	}
+	// 整体替换超过 LCS 上限时退化为全部删除加全部新增
+	replaced := strings.ReplaceAll(before, "事实", "记录")
+	if got := diffUserMemory(before, replaced); strings.Count(got, "\n") != 10000 {
+		t.Fatalf("expected whole replacement, got %d lines", strings.Count(got, "\n"))
+	}
+}
@@ -25,6 +25,7 @@ type MemoryManager struct {
 
 	userMemoryAnalyzer      *UserMemoryAnalyzer
 	sessionSummaryGenerator *SessionSummaryGenerator
+	sessionTitleGenerator   *SessionTitleGenerator
 	searcher                builtinsearch.Searcher
 
 	// 摘要触发管理
@@ -43,14 +44,28 @@ type MemoryManager struct {
 	cleanupCtx    context.Context
 	cleanupCancel context.CancelFunc
 
+	// 串行化用户记忆写入，保证版本历史与写入顺序一致
+	userMemoryMu sync.Mutex
+
+	// 用户记忆事件整理相关
+	eventConsolidator   *EventConsolidator
+	consolidation       consolidationState
+	consolidationWg     sync.WaitGroup
+	consolidationCancel context.CancelFunc
+
 	// 异步任务队列统计
 	taskQueueStats TaskQueueStats
 
 	// 异步任务处理去重标记，防止同一(任务类型,用户,会话)多次排队
 	pendingTasks sync.Map
+	// 等待聚合、已入队但尚未处理完成的任务数，用于 WaitIdle
+	unfinishedTasks int64
+
+	// 用户周期摘要下次到期检查时间 key: userID, value: time.Time
+	userDigestNextCheck sync.Map
 
 	// 记忆任务聚合（debounce）相关
-	memoryTimers   sync.Map      // key: "memory:{userID}:{sessionID}", value: *time.Timer
+	memoryTimers   sync.Map      // key: "memory:{namespace}:{userID}:{sessionID}", value: *time.Timer
 	debounceWindow time.Duration // 聚合窗口时长，0 表示不做聚合
 
 	// 外部注入的清理函数
@@ -58,11 +73,15 @@ type MemoryManager struct {
 	CleanupMessagesByLimitFunc func(ctx context.Context) error // 按数量限制清理消息
 
 	asyncTaskContextBuilder AsyncTaskContextBuilder
+
+	// 记忆数据变更回调
+	changeListener ChangeListener
 }
 
 // asyncTask 异步任务结构
 type asyncTask struct {
-	taskType  string // "memory" 或 "summary"
+	taskType  string // "memory"、"summary"、"index"、"title" 或 "digest"
+	namespace string // 记忆命名空间，处理时写回 ctx
 	userID    string
 	sessionID string
 	message   *ConversationMessage
@@ -84,7 +103,9 @@ func NewMemoryManager(cm model.AgenticModel, memoryStorage MemoryStorage, config
 		storage:                 memoryStorage,
 		config:                  config,
 		userMemoryAnalyzer:      NewUserMemoryAnalyzer(cm),
+		eventConsolidator:       NewEventConsolidator(cm),
 		sessionSummaryGenerator: NewSessionSummaryGenerator(cm),
+		sessionTitleGenerator:   NewSessionTitleGenerator(cm),
 		summaryTrigger:          NewSummaryTriggerManager(config.SummaryTrigger),
 		summaryCache: newSessionSummaryCache(
 			time.Duration(config.SummaryCache.TTLSeconds)*time.Second,
@@ -122,6 +143,7 @@ func NewMemoryManager(cm model.AgenticModel, memoryStorage MemoryStorage, config
 
 	// 启动定期清理任务
 	manager.startPeriodicCleanup()
+	manager.startPeriodicConsolidation()
 
 	return manager, nil
 }
@@ -133,10 +155,15 @@ func (m *MemoryManager) SetAsyncTaskContextBuilder(builder AsyncTaskContextBuild
 func (m *MemoryManager) newAsyncTaskContext(task asyncTask) context.Context {
 	if m.asyncTaskContextBuilder != nil {
 		if ctx := m.asyncTaskContextBuilder(task.taskType, task.userID, task.sessionID); ctx != nil {
-			return ctx
+			return WithNamespace(ctx, task.namespace)
 		}
 	}
-	return context.Background()
+	return WithNamespace(context.Background(), task.namespace)
+}
+
+// key 任务去重键：任务类型 + 命名空间 + 用户 + 会话
+func (t asyncTask) key() string {
+	return fmt.Sprintf("%s:%s:%s:%s", t.taskType, t.namespace, t.userID, t.sessionID)
 }
 
 // startAsyncWorkers 启动异步工作goroutine池
@@ -155,11 +182,11 @@ func (m *MemoryManager) startAsyncWorkers() {
 					}
 
 					// 任务已取出准备处理，从排队重标记中移除，允许同类新任务入队
-					taskKey := fmt.Sprintf("%s:%s:%s", task.taskType, task.userID, task.sessionID)
-					m.pendingTasks.Delete(taskKey)
+					m.pendingTasks.Delete(task.key())
 
 					m.processAsyncTask(task)
 					atomic.AddInt64(&m.taskQueueStats.ProcessedTasks, 1)
+					atomic.AddInt64(&m.unfinishedTasks, -1)
 				}
 			}
 		}()
@@ -169,19 +196,21 @@ func (m *MemoryManager) startAsyncWorkers() {
 
 // submitAsyncTask 提交异步任务，带队列重防抖功能
 func (m *MemoryManager) submitAsyncTask(task asyncTask) bool {
-	taskKey := fmt.Sprintf("%s:%s:%s", task.taskType, task.userID, task.sessionID)
-	// 如果相同签名（任务类型+用户+会话）的任务已在队列中，则丢弃当前重复提交，节省开销
+	taskKey := task.key()
+	// 如果相同签名（任务类型+命名空间+用户+会话）的任务已在队列中，则丢弃当前重复提交，节省开销
 	if _, loaded := m.pendingTasks.LoadOrStore(taskKey, struct{}{}); loaded {
 		//slog.Debugf("异步任务去重: 已存在相同的待处理任务, 类型: %s, 用户: %s", task.taskType, task.userID)
 		return true // 返回 true 表示"已接收处理"（虽然是去重扔掉的），不视为"队列满丢弃"
 	}
 
+	atomic.AddInt64(&m.unfinishedTasks, 1)
 	select {
 	case m.taskChannel <- task:
 		return true
 	default:
 		// 队列满，入队失败，需清除去重标记以免卡死后续重试
 		m.pendingTasks.Delete(taskKey)
+		atomic.AddInt64(&m.unfinishedTasks, -1)
 
 		// 队列满，增加丢弃计数
 		atomic.AddInt64(&m.taskQueueStats.DroppedTasks, 1)
@@ -195,11 +224,12 @@ func (m *MemoryManager) submitAsyncTask(task asyncTask) bool {
 
 // scheduleMemoryTask 调度记忆分析任务，支持聚合窗口（debounce）
 // 首次请求后启动 debounceWindow 定时器，期间的新请求不重置定时器，到期统一处理一次
-func (m *MemoryManager) scheduleMemoryTask(userID, sessionID string) {
+func (m *MemoryManager) scheduleMemoryTask(namespace, userID, sessionID string) {
 	// debounceWindow 为 0 时，保持原有行为：立即提交
 	if m.debounceWindow <= 0 {
 		submitted := m.submitAsyncTask(asyncTask{
 			taskType:  "memory",
+			namespace: namespace,
 			userID:    userID,
 			sessionID: sessionID,
 		})
@@ -209,18 +239,21 @@ func (m *MemoryManager) scheduleMemoryTask(userID, sessionID string) {
 		return
 	}
 
-	timerKey := fmt.Sprintf("memory:%s:%s", userID, sessionID)
+	timerKey := fmt.Sprintf("memory:%s:%s:%s", namespace, userID, sessionID)
 
 	// 如果已有定时器，说明窗口内已有一次请求在等待，直接返回
 	if _, loaded := m.memoryTimers.Load(timerKey); loaded {
 		return
 	}
 
-	// 启动延迟定时器
+	// 启动延迟定时器，等待中的任务计入未完成任务数，入队后由队列接管计数
+	atomic.AddInt64(&m.unfinishedTasks, 1)
 	timer := time.AfterFunc(m.debounceWindow, func() {
+		defer atomic.AddInt64(&m.unfinishedTasks, -1)
 		m.memoryTimers.Delete(timerKey)
 		submitted := m.submitAsyncTask(asyncTask{
 			taskType:  "memory",
+			namespace: namespace,
 			userID:    userID,
 			sessionID: sessionID,
 		})
@@ -231,6 +264,23 @@ func (m *MemoryManager) scheduleMemoryTask(userID, sessionID string) {
 	m.memoryTimers.Store(timerKey, timer)
 }
 
+// WaitIdle 等待异步任务全部处理完成，包括聚合窗口中尚未入队的记忆任务；ctx 结束时返回 ctx.Err()。
+// 适用于测试、基准评测与平滑下线，处理期间产生的新任务也会被等待。
+func (m *MemoryManager) WaitIdle(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if atomic.LoadInt64(&m.unfinishedTasks) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
 // GetTaskQueueStats 获取异步任务队列统计
 func (m *MemoryManager) GetTaskQueueStats() TaskQueueStats {
 	stats := TaskQueueStats{
@@ -272,16 +322,20 @@ func (m *MemoryManager) performPeriodicCleanup(parentCtx context.Context) {
 	ctx, cancel := context.WithTimeout(parentCtx, 10*time.Minute)
 	defer cancel()
 
+	report := &CleanupReport{StartedAt: time.Now()}
+
 	// 1. 清理旧的会话状态
 	if m.config.Cleanup.SessionCleanupInterval > 0 {
 		sessionRetention := time.Duration(m.config.Cleanup.SessionRetentionTime) * time.Hour
 		m.summaryTrigger.CleanupOldSessions(sessionRetention)
+		report.SessionStatesCleaned = true
 	}
 
 	// 2. 清理旧的消息历史（按时间）- 调用外部注入的函数
 	if m.CleanupOldMessagesFunc != nil {
 		if err := m.CleanupOldMessagesFunc(ctx); err != nil {
 			slog.Errorf("清理旧消息失败: %v", err)
+			report.Errors = append(report.Errors, fmt.Errorf("清理旧消息失败: %w", err))
 		}
 	}
 
@@ -289,8 +343,44 @@ func (m *MemoryManager) performPeriodicCleanup(parentCtx context.Context) {
 	if m.CleanupMessagesByLimitFunc != nil {
 		if err := m.CleanupMessagesByLimitFunc(ctx); err != nil {
 			slog.Errorf("按数量清理消息失败: %v", err)
+			report.Errors = append(report.Errors, fmt.Errorf("按数量清理消息失败: %w", err))
+		}
+	}
+
+	// 4. 外部清理函数可能删除任意会话的消息，丢弃检索器的内存索引
+	if m.CleanupOldMessagesFunc != nil || m.CleanupMessagesByLimitFunc != nil {
+		m.invalidateSearchIndex("", "")
+		report.MessagesCleaned = true
+	}
+
+	// 5. 按保留策略清理消息与事件，先于附件回收，使被删除消息引用的附件本轮即可回收
+	if m.config.Retention != nil {
+		retention, err := m.ApplyRetention(ctx, m.config.Retention.DryRun)
+		if err != nil {
+			slog.Errorf("执行保留策略失败: %v", err)
+			report.Errors = append(report.Errors, fmt.Errorf("执行保留策略失败: %w", err))
+		}
+		if retention != nil {
+			report.Retention = retention
+			report.Errors = append(report.Errors, retention.Errors...)
+			if !retention.DryRun && retention.MessagesDeleted > 0 {
+				report.MessagesCleaned = true
+			}
 		}
 	}
+
+	// 6. 删除不再被引用的外置附件
+	if m.config.Attachments != nil {
+		deleted, err := m.CleanupAttachments(ctx)
+		if err != nil {
+			slog.Errorf("清理附件失败: %v", err)
+			report.Errors = append(report.Errors, fmt.Errorf("清理附件失败: %w", err))
+		}
+		report.AttachmentsDeleted = deleted
+	}
+
+	report.FinishedAt = time.Now()
+	m.notifyChange(ctx, ChangeCleanupPerformed, report)
 }
 
 // processAsyncTask 处理异步任务
@@ -312,13 +402,21 @@ func (m *MemoryManager) processAsyncTask(task asyncTask) {
 	case "summary":
 		ctx, cancel := context.WithTimeout(m.newAsyncTaskContext(task), m.asyncTaskTimeout())
 		defer cancel()
-		err := m.updateSessionSummary(ctx, task.userID, task.sessionID)
+		updated, err := m.updateSessionSummary(ctx, task.userID, task.sessionID)
 		if err != nil {
 			slog.Errorf("异步更新会话摘要失败: sessionID=%s, userID=%s, err=%v\n", task.sessionID, task.userID, err)
-		} else {
+		} else if updated {
 			// 标记摘要已更新
-			m.summaryTrigger.MarkSummaryUpdated(generateSessionKey(task.userID, task.sessionID))
+			m.markSummaryUpdated(ctx, task.userID, task.sessionID)
 		}
+	case "title":
+		ctx, cancel := context.WithTimeout(m.newAsyncTaskContext(task), m.asyncTaskTimeout())
+		defer cancel()
+		m.autoTitleSession(ctx, task.userID, task.sessionID)
+	case "digest":
+		ctx, cancel := context.WithTimeout(m.newAsyncTaskContext(task), m.asyncTaskTimeout())
+		defer cancel()
+		m.generateDueUserDigest(ctx, task.userID)
 	}
 }
 
@@ -346,6 +444,7 @@ func (m *MemoryManager) ProcessUserMessage(ctx context.Context, userID, sessionI
 			if err != nil {
 				slog.Errorf("清理超限消息失败: %v", err)
 			} else {
+				m.invalidateSearchIndex(sessionID, userID)
 				slog.Infof("会话 %s 消息数量达到限制 %d，已清理旧消息", sessionID, m.config.Cleanup.MessageHistoryLimit)
 			}
 		}
@@ -406,23 +505,29 @@ func (m *MemoryManager) ProcessAssistantMessage(ctx context.Context, userID, ses
 		}
 	}
 
+	// 如果启用了用户周期摘要，检查是否到期
+	m.scheduleUserDigest(userID)
+
+	// 如果启用了会话标题生成，为还没有标题的会话生成标题
+	if m.shouldAutoTitleSession() {
+		m.submitAsyncTask(asyncTask{
+			taskType:  "title",
+			userID:    userID,
+			sessionID: sessionID,
+		})
+	}
+
 	// 如果启用了用户记忆，分析消息并创建记忆（在AI回复后触发）
 	if m.config.EnableUserMemories {
-		m.scheduleMemoryTask(userID, sessionID)
+		m.scheduleMemoryTask(NamespaceFromContext(ctx), userID, sessionID)
 	}
 
 	return nil
 }
 
 // analyzeAndCreateUserMemory 分析用户消息并更新记忆
+// 分析期间记忆被其他写入修改时，基于最新记忆重新分析，最多重试 UserMemoryHistoryConfig.MaxConflictRetries 次。
 func (m *MemoryManager) analyzeAndCreateUserMemory(ctx context.Context, userID, sessionID string) {
-	// 获取现有记忆
-	existingMemory, err := m.storage.GetUserMemory(ctx, userID)
-	if err != nil {
-		slog.Errorf("获取用户记忆失败: %v\n", err)
-		return
-	}
-
 	// 获取最近消息作为上下文
 	historyMessages, err := m.storage.GetMessages(ctx, sessionID, userID, m.config.MemoryLimit/2)
 	if err != nil {
@@ -436,7 +541,6 @@ func (m *MemoryManager) analyzeAndCreateUserMemory(ctx context.Context, userID,
 
 	useEvent := m.config.EnableEventSearch
 	req := AnalyzeRequest{
-		ExistingMemory:  existingMemory,
 		HistoryMessages: historyMessages,
 		UseEventSearch:  &useEvent,
 	}
@@ -453,27 +557,53 @@ func (m *MemoryManager) analyzeAndCreateUserMemory(ctx context.Context, userID,
 		}
 	}
 
-	result, err := m.userMemoryAnalyzer.AnalyzeOnce(ctx, req)
-	if err != nil {
-		slog.Errorf("分析用户记忆失败: %v\n", err)
-		return
-	}
-	if result == nil || !result.NeedUpdate {
-		return
-	}
-
-	// 短文档为空表示用户没有触发约定/基础信息更新，但事件可能仍然需要写入。
-	if strings.TrimSpace(result.Memory) != "" {
-		mem := &UserMemory{
-			UserID: userID,
-			Memory: result.Memory,
+	retries := normalizeUserMemoryHistoryConfig(m.config.UserMemoryHistory).MaxConflictRetries
+	var result *MemoryAnalysisResult
+	for attempt := 0; ; attempt++ {
+		// 获取现有记忆
+		existingMemory, err := m.storage.GetUserMemory(ctx, userID)
+		if err != nil {
+			slog.Errorf("获取用户记忆失败: %v\n", err)
+			return
 		}
+		var baseVersion int64
 		if existingMemory != nil {
-			mem.CreatedAt = existingMemory.CreatedAt
+			baseVersion = existingMemory.Version
+		}
+		req.ExistingMemory = existingMemory
+
+		result, err = m.userMemoryAnalyzer.AnalyzeOnce(ctx, req)
+		if err != nil {
+			slog.Errorf("分析用户记忆失败: %v\n", err)
+			return
+		}
+		if result == nil || !result.NeedUpdate {
+			return
+		}
+
+		// 短文档为空表示用户没有触发约定/基础信息更新，但事件可能仍然需要写入。
+		if strings.TrimSpace(result.Memory) == "" {
+			break
+		}
+		_, err = m.UpdateUserMemory(ctx, userID, &UserMemoryUpdate{
+			Memory:      result.Memory,
+			Source:      UserMemorySourceAnalyzer,
+			SessionID:   sessionID,
+			Model:       m.analyzerModelName(),
+			BaseVersion: &baseVersion,
+		})
+		if errors.Is(err, ErrUserMemoryConflict) && attempt < retries {
+			slog.Infof("用户 %s 的记忆在分析期间被修改，基于最新记忆重新分析（第 %d 次）", userID, attempt+1)
+			continue
 		}
-		if err := m.storage.UpsertUserMemory(ctx, mem); err != nil {
+		if errors.Is(err, ErrUserMemoryConflict) {
+			slog.Warnf("用户 %s 的记忆在分析期间持续被修改，放弃本次更新: %v", userID, err)
+		} else if errors.Is(err, ErrUserMemoryShrinkRejected) {
+			slog.Warnf("用户 %s 的记忆更新未通过安全检查，已保留原记忆: %v", userID, err)
+		} else if err != nil {
 			slog.Errorf("保存用户记忆失败: %v\n", err)
 		}
+		break
 	}
 
 	if useEvent && len(result.Events) > 0 {
@@ -501,7 +631,11 @@ func (m *MemoryManager) SaveUserMemoryEvent(ctx context.Context, event *UserMemo
 	if store == nil {
 		return fmt.Errorf("当前 storage 未实现 UserMemoryEventStorage")
 	}
-	return store.SaveUserMemoryEvent(ctx, event)
+	if err := store.SaveUserMemoryEvent(ctx, event); err != nil {
+		return err
+	}
+	m.notifyChange(ctx, ChangeUserMemoryEventCreated, event)
+	return nil
 }
 
 // ListRecentUserMemoryEvents 返回用户最近的事件
@@ -519,7 +653,19 @@ func (m *MemoryManager) SearchUserMemoryEvents(ctx context.Context, query *UserM
 	if store == nil {
 		return nil, fmt.Errorf("当前 storage 未实现 UserMemoryEventStorage")
 	}
-	return store.SearchUserMemoryEvents(ctx, query)
+	var (
+		events []*UserMemoryEvent
+		err    error
+	)
+	if query != nil && len(query.Keywords) > 0 && m.config.Search != nil && m.config.Search.BM25 != nil {
+		events, err = m.rankUserMemoryEvents(ctx, store, query)
+	} else {
+		events, err = store.SearchUserMemoryEvents(ctx, query)
+	}
+	if err == nil && query != nil {
+		m.touchUserMemoryEvents(ctx, query.UserID, events)
+	}
+	return events, err
 }
 
 // shouldTriggerSummaryUpdate 判断是否需要触发摘要更新
@@ -529,14 +675,28 @@ func (m *MemoryManager) shouldTriggerSummaryUpdate(ctx context.Context, userID,
 		return false, fmt.Errorf("获取会话摘要失败: %w", err)
 	}
 
-	if existingSummary == nil {
-		totalMessageCount, err := m.storage.GetMessageCount(ctx, userID, sessionID)
+	totalMessageCount, err := m.storage.GetMessageCount(ctx, userID, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("获取消息总数失败: %w", err)
+	}
+	if existingSummary == nil && totalMessageCount == 0 {
+		return false, nil
+	}
+
+	state, err := m.syncSummaryTriggerState(ctx, userID, sessionID, existingSummary, totalMessageCount)
+	switch m.summaryTrigger.config.Strategy {
+	case TriggerByTokens, TriggerTopicShift:
 		if err != nil {
-			return false, fmt.Errorf("获取消息总数失败: %w", err)
-		}
-		if totalMessageCount == 0 {
-			return false, nil
+			return false, err
 		}
+		return m.summaryTrigger.shouldTriggerByState(state), nil
+	}
+	if err != nil {
+		// 其他策略按消息快照判断，触发状态只用于统计
+		slog.Warnf("同步摘要触发状态失败: sessionID=%s, userID=%s, err=%v", sessionID, userID, err)
+	}
+
+	if existingSummary == nil {
 		return m.shouldTriggerSummaryBySnapshot(time.Time{}, totalMessageCount, totalMessageCount, false), nil
 	}
 
@@ -551,20 +711,20 @@ func (m *MemoryManager) shouldTriggerSummaryUpdate(ctx context.Context, userID,
 		return false, fmt.Errorf("获取未摘要消息数量失败: %w", err)
 	}
 
-	totalMessageCount, err := m.storage.GetMessageCount(ctx, userID, sessionID)
-	if err != nil {
-		return false, fmt.Errorf("获取消息总数失败: %w", err)
-	}
-
 	return m.shouldTriggerSummaryBySnapshot(existingSummary.UpdatedAt, unsummarizedCount, totalMessageCount, true), nil
 }
 
 // updateSessionSummary 更新会话摘要（使用AI生成）
-func (m *MemoryManager) updateSessionSummary(ctx context.Context, userID, sessionID string) error {
+// 返回摘要游标是否前进。
+func (m *MemoryManager) updateSessionSummary(ctx context.Context, userID, sessionID string) (bool, error) {
+	if cfg, store := m.hierarchicalSummary(); store != nil {
+		return m.updateHierarchicalSummary(ctx, userID, sessionID, cfg, store)
+	}
+
 	// 检查是否已存在摘要
 	existingSummary, err := m.GetSessionSummary(ctx, sessionID, userID)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	var summaryContent string
@@ -578,17 +738,17 @@ func (m *MemoryManager) updateSessionSummary(ctx context.Context, userID, sessio
 			0,
 		)
 		if err != nil {
-			return fmt.Errorf("获取未摘要消息失败: %w", err)
+			return false, fmt.Errorf("获取未摘要消息失败: %w", err)
 		}
 		if len(recentMessages) == 0 {
-			return nil
+			return false, nil
 		}
 
 		// 使用增量摘要生成（基于现有摘要和游标后的最新消息）
 		summaryContent, err = m.sessionSummaryGenerator.GenerateIncrementalSummary(
 			ctx, recentMessages, existingSummary.Summary)
 		if err != nil {
-			return fmt.Errorf("生成增量摘要失败: %w", err)
+			return false, fmt.Errorf("生成增量摘要失败: %w", err)
 		}
 
 		// 更新现有摘要
@@ -597,23 +757,24 @@ func (m *MemoryManager) updateSessionSummary(ctx context.Context, userID, sessio
 		existingSummary.LastSummarizedMessageID = lastMessage.ID
 		existingSummary.LastSummarizedMessageAt = lastMessage.CreatedAt
 		if err := m.storage.UpdateSessionSummary(ctx, existingSummary); err != nil {
-			return err
+			return false, err
 		}
 		m.cacheSessionSummary(existingSummary)
-		return nil
+		m.notifyChange(ctx, ChangeSessionSummaryUpdated, existingSummary)
+		return true, nil
 	} else {
 		allMessages, err := m.storage.GetMessages(ctx, sessionID, userID, 0)
 		if err != nil {
-			return err
+			return false, err
 		}
 		if len(allMessages) == 0 {
-			return nil
+			return false, nil
 		}
 
 		// 生成新摘要
 		summaryContent, err = m.sessionSummaryGenerator.GenerateSummary(ctx, allMessages, "")
 		if err != nil {
-			return fmt.Errorf("生成新摘要失败: %w", err)
+			return false, fmt.Errorf("生成新摘要失败: %w", err)
 		}
 
 		lastMessage := allMessages[len(allMessages)-1]
@@ -626,11 +787,84 @@ func (m *MemoryManager) updateSessionSummary(ctx context.Context, userID, sessio
 			LastSummarizedMessageAt: lastMessage.CreatedAt,
 		}
 		if err := m.storage.SaveSessionSummary(ctx, summary); err != nil {
-			return err
+			return false, err
 		}
 		m.cacheSessionSummary(summary)
-		return nil
+		m.notifyChange(ctx, ChangeSessionSummaryUpdated, summary)
+		return true, nil
+	}
+}
+
+// RegenerateSessionSummary 用会话的全部消息重新生成摘要，覆盖现有摘要并返回新摘要；会话没有消息时返回 nil, nil。
+// 分层摘要模式下会先删除现有摘要与分段，再按分段重新生成，生成失败时需等待下次触发重建。
+func (m *MemoryManager) RegenerateSessionSummary(ctx context.Context, sessionID, userID string) (*SessionSummary, error) {
+	if sessionID == "" {
+		return nil, errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	sessionKey := generateSessionKey(userID, sessionID)
+	if _, store := m.hierarchicalSummary(); store != nil {
+		if err := m.storage.DeleteSessionSummary(ctx, sessionID, userID); err != nil {
+			return nil, fmt.Errorf("删除会话摘要失败: %w", err)
+		}
+		if m.summaryCache != nil {
+			m.summaryCache.Delete(sessionKey)
+		}
+		if err := store.DeleteSummaryChunks(ctx, sessionID, userID); err != nil {
+			return nil, fmt.Errorf("删除分段摘要失败: %w", err)
+		}
+		updated, err := m.updateSessionSummary(ctx, userID, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if updated {
+			m.markSummaryUpdated(ctx, userID, sessionID)
+		}
+		return m.GetSessionSummary(ctx, sessionID, userID)
+	}
+
+	messages, err := m.storage.GetMessages(ctx, sessionID, userID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	content, err := m.sessionSummaryGenerator.GenerateSummary(ctx, messages, "")
+	if err != nil {
+		return nil, fmt.Errorf("生成新摘要失败: %w", err)
+	}
+
+	lastMessage := messages[len(messages)-1]
+	summary, err := m.storage.GetSessionSummary(ctx, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if summary != nil {
+		summary.Summary = content
+		summary.LastSummarizedMessageID = lastMessage.ID
+		summary.LastSummarizedMessageAt = lastMessage.CreatedAt
+		err = m.storage.UpdateSessionSummary(ctx, summary)
+	} else {
+		summary = &SessionSummary{
+			SessionID:               sessionID,
+			UserID:                  userID,
+			Summary:                 content,
+			LastSummarizedMessageID: lastMessage.ID,
+			LastSummarizedMessageAt: lastMessage.CreatedAt,
+		}
+		err = m.storage.SaveSessionSummary(ctx, summary)
+	}
+	if err != nil {
+		return nil, err
 	}
+	m.cacheSessionSummary(summary)
+	m.notifyChange(ctx, ChangeSessionSummaryUpdated, summary)
+	m.markSummaryUpdated(ctx, userID, sessionID)
+	return cloneSessionSummary(summary), nil
 }
 
 // GetUserMemory 获取用户记忆
@@ -638,14 +872,31 @@ func (m *MemoryManager) GetUserMemory(ctx context.Context, userID string) (*User
 	return m.storage.GetUserMemory(ctx, userID)
 }
 
-// UpsertUserMemory 创建或更新用户记忆
+// UpsertUserMemory 创建或更新用户记忆。视为人工写入：跳过缩减检查，但仍记录版本历史。
 func (m *MemoryManager) UpsertUserMemory(ctx context.Context, memory *UserMemory) error {
-	return m.storage.UpsertUserMemory(ctx, memory)
+	if memory == nil {
+		return errors.New("记忆对象不能为空")
+	}
+	_, err := m.UpdateUserMemory(ctx, memory.UserID, &UserMemoryUpdate{
+		Memory: memory.Memory,
+		Source: UserMemorySourceManual,
+		Force:  true,
+	})
+	return err
 }
 
 // ClearUserMemory 清空用户记忆
 func (m *MemoryManager) ClearUserMemory(ctx context.Context, userID string) error {
-	return m.storage.ClearUserMemory(ctx, userID)
+	return m.clearUserMemory(ctx, userID, UserMemorySourceManual)
+}
+
+// clearUserMemory 清空用户记忆并通知变更
+func (m *MemoryManager) clearUserMemory(ctx context.Context, userID, source string) error {
+	if err := m.storage.ClearUserMemory(ctx, userID); err != nil {
+		return err
+	}
+	m.notifyChange(ctx, ChangeUserMemoryUpdated, &UserMemoryChange{UserID: userID, Source: source})
+	return nil
 }
 
 // GetSessionSummary 获取会话摘要
@@ -667,6 +918,13 @@ func (m *MemoryManager) GetSessionSummary(ctx context.Context, sessionID, userID
 
 // SaveMessage 保存消息
 func (m *MemoryManager) SaveMessage(ctx context.Context, message *ConversationMessage) error {
+	if message != nil {
+		parts, err := m.offloadAttachments(ctx, message.Parts)
+		if err != nil {
+			return err
+		}
+		message.Parts = parts
+	}
 	if err := m.storage.SaveMessage(ctx, message); err != nil {
 		return err
 	}
@@ -681,11 +939,12 @@ func (m *MemoryManager) GetMessages(ctx context.Context, sessionID, userID strin
 		return nil, err
 	}
 
-	list := make([]*schema.AgenticMessage, len(messages))
-	for i, v := range messages {
-		list[i] = v.ToAgenticMessage()
-	}
-	return list, nil
+	return m.toAgenticMessages(ctx, messages), nil
+}
+
+// GetConversationMessages 获取会话的原始存储消息，外置附件保持引用形式，适合查看与管理
+func (m *MemoryManager) GetConversationMessages(ctx context.Context, sessionID, userID string, limit int) ([]*ConversationMessage, error) {
+	return m.storage.GetMessages(ctx, sessionID, userID, limit)
 }
 
 // GetMessagesAfterSummary returns only the messages that have not yet been folded into the persisted session summary.
@@ -708,11 +967,7 @@ func (m *MemoryManager) GetMessagesAfterSummary(ctx context.Context, sessionID,
 		return nil, err
 	}
 
-	list := make([]*schema.AgenticMessage, len(messages))
-	for i, v := range messages {
-		list[i] = v.ToAgenticMessage()
-	}
-	return list, nil
+	return m.toAgenticMessages(ctx, messages), nil
 }
 
 // GetConfig 获取配置
@@ -761,6 +1016,9 @@ func (m *MemoryManager) UpdateConfig(config *MemoryConfig) {
 	m.cleanupCtx = cleanupCtx
 	m.cleanupCancel = cleanupCancel
 	m.startPeriodicCleanup()
+
+	m.stopPeriodicConsolidation()
+	m.startPeriodicConsolidation()
 }
 
 // GetMemoryStats 获取内存管理器统计信息
@@ -798,11 +1056,12 @@ func (m *MemoryManager) Close() error {
 		// 等待清理goroutine结束
 		m.cleanupWg.Wait()
 	}
+	m.stopPeriodicConsolidation()
 
 	// 停止所有聚合定时器，阻止新的记忆任务入队
 	m.memoryTimers.Range(func(key, value interface{}) bool {
-		if timer, ok := value.(*time.Timer); ok {
-			timer.Stop()
+		if timer, ok := value.(*time.Timer); ok && timer.Stop() {
+			atomic.AddInt64(&m.unfinishedTasks, -1)
 		}
 		m.memoryTimers.Delete(key)
 		return true
@@ -813,6 +1072,10 @@ func (m *MemoryManager) Close() error {
 	m.wg.Wait()
 	close(m.taskChannel)
 
+	if err := m.closeSearcher(); err != nil {
+		slog.Errorf("关闭检索器失败: %v", err)
+	}
+
 	return m.storage.Close()
 }
 
@@ -979,6 +1242,15 @@ func normalizeMemoryConfig(config *MemoryConfig) *MemoryConfig {
 	if config.SummaryTrigger.MinInterval <= 0 {
 		config.SummaryTrigger.MinInterval = defaults.SummaryTrigger.MinInterval
 	}
+	if config.SummaryTrigger.TokenThreshold <= 0 {
+		config.SummaryTrigger.TokenThreshold = defaults.SummaryTrigger.TokenThreshold
+	}
+	if config.SummaryTrigger.TopicShiftSimilarity <= 0 {
+		config.SummaryTrigger.TopicShiftSimilarity = defaults.SummaryTrigger.TopicShiftSimilarity
+	}
+	if config.SummaryTrigger.MinTopicShiftMessages <= 0 {
+		config.SummaryTrigger.MinTopicShiftMessages = defaults.SummaryTrigger.MinTopicShiftMessages
+	}
 	if config.SummaryCache.TTLSeconds <= 0 {
 		config.SummaryCache.TTLSeconds = defaults.SummaryCache.TTLSeconds
 	}
@@ -998,6 +1270,14 @@ func normalizeMemoryConfig(config *MemoryConfig) *MemoryConfig {
 		config.Cleanup.MessageHistoryLimit = defaults.Cleanup.MessageHistoryLimit
 	}
 	config.Search = normalizeSearchConfig(config.Search)
+	config.Consolidation = normalizeConsolidationConfig(config.Consolidation)
+	config.EventRanking = normalizeEventRankingConfig(config.EventRanking)
+	config.UserMemoryHistory = normalizeUserMemoryHistoryConfig(config.UserMemoryHistory)
+	config.SessionTitle = normalizeSessionTitleConfig(config.SessionTitle)
+	config.HierarchicalSummary = normalizeHierarchicalSummaryConfig(config.HierarchicalSummary)
+	config.UserDigest = normalizeUserDigestConfig(config.UserDigest)
+	config.Attachments = normalizeAttachmentConfig(config.Attachments)
+	config.Retention = normalizeRetentionConfig(config.Retention)
 	return config
 }
 
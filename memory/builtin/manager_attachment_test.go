@@ -0,0 +1,112 @@
+package builtin_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/blob"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+	"github.com/cloudwego/eino/schema"
+)
+
+var attachmentImage = base64.StdEncoding.EncodeToString([]byte("fake png bytes"))
+
+type attachmentFixture struct {
+	manager *builtin.MemoryManager
+	store   *storage.MemoryStore
+	blobs   *blob.LocalStore
+	parts   []schema.MessageInputPart
+}
+
+// newAttachmentFixture 把同一张图片分别发送到 s1 和 s2
+func newAttachmentFixture(t *testing.T) *attachmentFixture {
+	t.Helper()
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new blob store err: %v", err)
+	}
+	manager, store := newTestManager(t, func(config *builtin.MemoryConfig) {
+		config.EnableUserMemories = false
+		config.Attachments = &builtin.AttachmentConfig{Store: blobs, GCGracePeriod: time.Nanosecond}
+	})
+
+	image := attachmentImage
+	parts := []schema.MessageInputPart{
+		{Type: schema.ChatMessagePartTypeText, Text: "看看这张图"},
+		{Type: schema.ChatMessagePartTypeImageURL, Image: &schema.MessageInputImage{
+			MessagePartCommon: schema.MessagePartCommon{Base64Data: &image, MIMEType: "image/png"},
+		}},
+	}
+	for _, sessionID := range []string{"s1", "s2"} {
+		if err := manager.ProcessUserMessage(context.Background(), "u1", sessionID, "", parts); err != nil {
+			t.Fatalf("process user message err: %v", err)
+		}
+	}
+	return &attachmentFixture{manager: manager, store: store, blobs: blobs, parts: parts}
+}
+
+func TestMemoryManager_AttachmentOffload(t *testing.T) {
+	ctx := context.Background()
+	f := newAttachmentFixture(t)
+
+	if f.parts[1].Image.Base64Data == nil || f.parts[1].Image.URL != nil {
+		t.Fatal("caller's parts should not be modified")
+	}
+	stored, _ := f.store.GetMessages(ctx, "s1", "u1", 0)
+	storedImage := stored[0].Parts[1].Image
+	if storedImage.Base64Data != nil || storedImage.URL == nil || !strings.HasPrefix(*storedImage.URL, builtin.AttachmentURLPrefix) || storedImage.MIMEType != "image/png" {
+		t.Fatalf("stored part should only keep a reference: %#v", storedImage)
+	}
+	// 同一张图片发送两次，附件只保存一份
+	if infos, _ := f.blobs.List(ctx); len(infos) != 1 {
+		t.Fatalf("attachment should be stored once, got %d", len(infos))
+	}
+}
+
+func TestMemoryManager_AttachmentRehydration(t *testing.T) {
+	f := newAttachmentFixture(t)
+
+	history, err := f.manager.GetMessages(context.Background(), "s1", "u1", 0)
+	if err != nil || len(history) != 1 || len(history[0].ContentBlocks) != 2 {
+		t.Fatalf("unexpected history: %#v, err=%v", history, err)
+	}
+	if block := history[0].ContentBlocks[1].UserInputImage; block == nil || block.Base64Data != attachmentImage || block.URL != "" || block.MIMEType != "image/png" {
+		t.Fatalf("attachment should be rehydrated as base64: %#v", block)
+	}
+}
+
+func TestMemoryManager_AttachmentCleanup(t *testing.T) {
+	cases := []struct {
+		name      string
+		deleted   []string
+		wantBlobs int
+	}{
+		// 仍被 s2 引用时不删除
+		{name: "still referenced", deleted: []string{"s1"}, wantBlobs: 1},
+		// 所有引用删除后由清理任务回收
+		{name: "orphaned", deleted: []string{"s1", "s2"}, wantBlobs: 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newAttachmentFixture(t)
+			for _, sessionID := range tc.deleted {
+				if err := f.manager.DeleteSession(ctx, sessionID, "u1"); err != nil {
+					t.Fatalf("delete session err: %v", err)
+				}
+			}
+
+			deleted, err := f.manager.CleanupAttachments(ctx)
+			if err != nil || deleted != 1-tc.wantBlobs {
+				t.Fatalf("cleanup deleted=%d err=%v", deleted, err)
+			}
+			if infos, _ := f.blobs.List(ctx); len(infos) != tc.wantBlobs {
+				t.Fatalf("blobs = %d, want %d", len(infos), tc.wantBlobs)
+			}
+		})
+	}
+}
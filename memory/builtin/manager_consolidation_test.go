@@ -0,0 +1,243 @@
+package builtin_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+const mergedSummary = "海豚377充值异常后已到账"
+
+type consolidationFixture struct {
+	manager *builtin.MemoryManager
+	store   *storage.MemoryStore
+	model   *staticAgenticModel
+	report  *builtin.ConsolidationReport
+}
+
+// consolidateEvents 写入两条可合并的事件和一条无关事件，并执行一次整理
+func consolidateEvents(t *testing.T) *consolidationFixture {
+	t.Helper()
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	cm := &staticAgenticModel{response: `{"op":"update","actions":[{"op":"merge","ids":["E1","E2"],"summary":"` + mergedSummary + `"}]}`}
+	manager := newManagerWith(t, cm, store, func(config *builtin.MemoryConfig) {
+		config.EnableEventSearch = true
+		config.Consolidation = &builtin.EventConsolidationConfig{}
+	})
+
+	base := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, evt := range []*builtin.UserMemoryEvent{
+		{UserID: "u1", Type: builtin.UserMemoryEventTypeEvent, EventDate: base, Summary: "海豚377充值入账异常", Keywords: []string{"海豚", "377"}},
+		{UserID: "u1", Type: builtin.UserMemoryEventTypeEvent, EventDate: base.Add(24 * time.Hour), Summary: "海豚377充值已到账", Keywords: []string{"海豚", "377"}},
+		{UserID: "u1", Type: builtin.UserMemoryEventTypeMilestone, EventDate: base, Summary: "西皮士1473扩容完成", Keywords: []string{"西皮士", "1473"}},
+	} {
+		if err := store.SaveUserMemoryEvent(ctx, evt); err != nil {
+			t.Fatalf("save event err: %v", err)
+		}
+	}
+
+	report, err := manager.ConsolidateUserMemoryEvents(ctx)
+	if err != nil {
+		t.Fatalf("consolidate err: %v", err)
+	}
+	return &consolidationFixture{manager: manager, store: store, model: cm, report: report}
+}
+
+func findMerged(events []*builtin.UserMemoryEvent) *builtin.UserMemoryEvent {
+	for _, evt := range events {
+		if evt.Summary == mergedSummary {
+			return evt
+		}
+	}
+	return nil
+}
+
+func TestMemoryManager_ConsolidateUserMemoryEvents(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name  string
+		check func(t *testing.T, f *consolidationFixture)
+	}{
+		{
+			name: "reports the merge",
+			check: func(t *testing.T, f *consolidationFixture) {
+				if f.report.LLMCalls != 1 || f.report.Merged != 1 || f.report.Superseded != 2 {
+					t.Fatalf("unexpected report: %+v", f.report)
+				}
+			},
+		},
+		{
+			name: "replaces merged events with one active event",
+			check: func(t *testing.T, f *consolidationFixture) {
+				active, err := f.store.ListRecentUserMemoryEvents(ctx, "u1", 0)
+				if err != nil {
+					t.Fatalf("list err: %v", err)
+				}
+				if len(active) != 2 {
+					t.Fatalf("want 2 active events, got %d", len(active))
+				}
+				if merged := findMerged(active); merged == nil || len(merged.Sources) != 2 {
+					t.Fatalf("merged event with sources not found: %+v", active)
+				}
+			},
+		},
+		{
+			name: "keeps superseded events for provenance",
+			check: func(t *testing.T, f *consolidationFixture) {
+				all, err := f.store.SearchUserMemoryEvents(ctx, &builtin.UserMemoryEventQuery{UserID: "u1", IncludeSuperseded: true})
+				if err != nil {
+					t.Fatalf("search err: %v", err)
+				}
+				merged := findMerged(all)
+				superseded := 0
+				for _, evt := range all {
+					if evt.Superseded() {
+						superseded++
+						if evt.SupersededBy != merged.ID {
+							t.Fatalf("superseded event should point to merged event, got %s", evt.SupersededBy)
+						}
+					}
+				}
+				if superseded != 2 {
+					t.Fatalf("want 2 superseded events, got %d", superseded)
+				}
+			},
+		},
+		{
+			// 没有新事件时不会重复调用模型
+			name: "skips the model without new events",
+			check: func(t *testing.T, f *consolidationFixture) {
+				if _, err := f.manager.ConsolidateUserMemoryEvents(ctx); err != nil {
+					t.Fatalf("second consolidate err: %v", err)
+				}
+				if calls := f.model.calls.Load(); calls != 1 {
+					t.Fatalf("expected no extra llm calls, got %d", calls)
+				}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.check(t, consolidateEvents(t))
+		})
+	}
+}
+
+// countingEmbedder 把所有文本映射到同一方向，并记录调用次数
+type countingEmbedder struct {
+	calls atomic.Int64
+}
+
+func (e *countingEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	e.calls.Add(1)
+	vectors := make([][]float64, len(texts))
+	for i := range texts {
+		vectors[i] = []float64{1, 0}
+	}
+	return vectors, nil
+}
+
+// seedMergeablePair 为用户写入两条可合并的事件
+func seedMergeablePair(t *testing.T, store *storage.MemoryStore, userID string) {
+	t.Helper()
+	base := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, summary := range []string{"海豚377充值入账异常", "海豚377充值已到账"} {
+		evt := &builtin.UserMemoryEvent{UserID: userID, Type: builtin.UserMemoryEventTypeEvent, EventDate: base.AddDate(0, 0, i), Summary: summary, Keywords: []string{"海豚", "377"}}
+		if err := store.SaveUserMemoryEvent(context.Background(), evt); err != nil {
+			t.Fatalf("save event err: %v", err)
+		}
+	}
+}
+
+func TestMemoryManager_ConsolidationUserCapSkipsUnchangedUsers(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	cm := &staticAgenticModel{response: `{"op":"update","actions":[{"op":"merge","ids":["E1","E2"],"summary":"` + mergedSummary + `"}]}`}
+	manager := newManagerWith(t, cm, store, func(config *builtin.MemoryConfig) {
+		config.EnableEventSearch = true
+		config.Consolidation = &builtin.EventConsolidationConfig{MaxUsersPerRun: 1}
+	})
+	users := []string{"u1", "u2", "u3"}
+	for _, userID := range users {
+		seedMergeablePair(t, store, userID)
+	}
+
+	// 上限为 1 时每轮处理一个用户，已整理且没有新事件的用户不占名额
+	for run, userID := range users {
+		report, err := manager.ConsolidateUserMemoryEvents(ctx)
+		if err != nil {
+			t.Fatalf("consolidate err: %v", err)
+		}
+		if report.Users != 1 || report.Merged != 1 {
+			t.Fatalf("run %d: unexpected report: %+v", run, report)
+		}
+		active, _ := store.ListRecentUserMemoryEvents(ctx, userID, 0)
+		if len(active) != 1 || findMerged(active) == nil {
+			t.Fatalf("run %d: %s should be consolidated, got %+v", run, userID, active)
+		}
+	}
+	if calls := cm.calls.Load(); calls != 3 {
+		t.Fatalf("expected one llm call per user, got %d", calls)
+	}
+}
+
+func TestMemoryManager_ConsolidationEmbeddingBudget(t *testing.T) {
+	cases := []struct {
+		name         string
+		maxCalls     int
+		wantLLMCalls int
+		wantDeferred int
+	}{
+		{name: "embedding and cluster within budget", maxCalls: 2, wantLLMCalls: 1},
+		// 向量计算占用唯一的预算，簇留到下一轮
+		{name: "embedding exhausts budget", maxCalls: 1, wantDeferred: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := storage.NewMemoryStore()
+			embedder := &countingEmbedder{}
+			manager := newManagerWith(t, &staticAgenticModel{response: noopResponse}, store, func(config *builtin.MemoryConfig) {
+				config.EnableEventSearch = true
+				config.Search = &builtin.SearchConfig{Embedder: embedder}
+				config.Consolidation = &builtin.EventConsolidationConfig{MaxLLMCallsPerRun: tc.maxCalls}
+			})
+			seedMergeablePair(t, store, "u1")
+
+			report, err := manager.ConsolidateUserMemoryEvents(ctx)
+			if err != nil {
+				t.Fatalf("consolidate err: %v", err)
+			}
+			if report.EmbeddingCalls != 1 || report.LLMCalls != tc.wantLLMCalls || report.Deferred != tc.wantDeferred {
+				t.Fatalf("unexpected report: %+v", report)
+			}
+		})
+	}
+}
+
+func TestMemoryManager_ConsolidationSkipsEmbeddingWithoutNewEvents(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	embedder := &countingEmbedder{}
+	manager := newManagerWith(t, &staticAgenticModel{response: noopResponse}, store, func(config *builtin.MemoryConfig) {
+		config.EnableEventSearch = true
+		config.Search = &builtin.SearchConfig{Embedder: embedder}
+		config.Consolidation = &builtin.EventConsolidationConfig{}
+	})
+	seedMergeablePair(t, store, "u1")
+
+	for i := 0; i < 2; i++ {
+		if _, err := manager.ConsolidateUserMemoryEvents(ctx); err != nil {
+			t.Fatalf("consolidate err: %v", err)
+		}
+	}
+	if calls := embedder.calls.Load(); calls != 1 {
+		t.Fatalf("expected embedder to run once, got %d", calls)
+	}
+}
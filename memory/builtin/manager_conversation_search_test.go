@@ -0,0 +1,71 @@
+package builtin_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
+)
+
+func TestMemoryManager_SearchMessagesAcrossSessions(t *testing.T) {
+	searchConfigs := []struct {
+		name string
+		cfg  *builtin.SearchConfig
+	}{
+		{name: "keyword"},
+		{name: "bm25", cfg: &builtin.SearchConfig{BM25: &builtin.BM25Config{}}},
+	}
+	queries := []struct {
+		name         string
+		sessionID    string
+		wantSessions []string
+	}{
+		// 未指定会话时在该用户的全部会话中检索
+		{name: "all sessions", wantSessions: []string{"s1", "s2"}},
+		{name: "single session", sessionID: "s2", wantSessions: []string{"s2"}},
+	}
+	for _, sc := range searchConfigs {
+		for _, q := range queries {
+			t.Run(sc.name+"/"+q.name, func(t *testing.T) {
+				ctx := context.Background()
+				manager, _ := newTestManager(t, func(config *builtin.MemoryConfig) {
+					config.Search = sc.cfg
+				})
+
+				now := time.Now()
+				for i, msg := range []*builtin.ConversationMessage{
+					{SessionID: "s1", UserID: "u1", Role: "user", Content: "部署脚本报错了"},
+					{SessionID: "s2", UserID: "u1", Role: "assistant", Content: "部署前先检查配置"},
+					{SessionID: "s1", UserID: "u2", Role: "user", Content: "部署成功"},
+				} {
+					msg.CreatedAt = now.Add(time.Duration(i) * time.Second)
+					if err := manager.SaveMessage(ctx, msg); err != nil {
+						t.Fatalf("save message err: %v", err)
+					}
+				}
+
+				hits, err := manager.SearchMessages(ctx, &builtinsearch.SearchQuery{UserID: "u1", SessionID: q.sessionID, Keywords: []string{"部署"}, Limit: 10})
+				if err != nil {
+					t.Fatalf("search err: %v", err)
+				}
+				sessions := map[string]bool{}
+				for _, hit := range hits {
+					if hit.Message.UserID != "u1" {
+						t.Fatalf("hit from another user: %+v", hit.Message)
+					}
+					sessions[hit.Message.SessionID] = true
+				}
+				if len(hits) != len(q.wantSessions) {
+					t.Fatalf("hits = %d, want %d: %v", len(hits), len(q.wantSessions), sessions)
+				}
+				for _, sessionID := range q.wantSessions {
+					if !sessions[sessionID] {
+						t.Fatalf("missing hit from session %s: %v", sessionID, sessions)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package builtin_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+)
+
+// seedRankingEvents 写入一条半年前的重要合同事件和五条近期的琐碎巡检事件，返回合同事件
+func seedRankingEvents(t *testing.T, store *storage.MemoryStore) *builtin.UserMemoryEvent {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+	contract := &builtin.UserMemoryEvent{UserID: "u1", Type: builtin.UserMemoryEventTypeMilestone, EventDate: now.AddDate(0, -6, 0), Summary: "与图仑科技签订年度合同", Keywords: []string{"图仑科技", "合同"}, Importance: 5}
+	if err := store.SaveUserMemoryEvent(ctx, contract); err != nil {
+		t.Fatalf("save event err: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		evt := &builtin.UserMemoryEvent{UserID: "u1", Type: builtin.UserMemoryEventTypeEvent, EventDate: now.AddDate(0, 0, -i), Summary: "日常巡检完成", Keywords: []string{"巡检"}, Importance: 1}
+		if err := store.SaveUserMemoryEvent(ctx, evt); err != nil {
+			t.Fatalf("save event err: %v", err)
+		}
+	}
+	return contract
+}
+
+func TestMemoryManager_RankedUserMemoryEvents(t *testing.T) {
+	cases := []struct {
+		name         string
+		ranking      *builtin.EventRankingConfig
+		wantContract bool
+	}{
+		// 与当前消息相关的重要旧事件应挤掉不相关的近期琐事
+		{name: "relevance and importance", wantContract: true},
+		// 只按时间排序时退化为最近 N 条
+		{name: "recency only", ranking: &builtin.EventRankingConfig{RecencyWeight: 1}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			manager, store := newTestManager(t, func(config *builtin.MemoryConfig) {
+				config.EnableEventSearch = true
+				config.EventRanking = tc.ranking
+			})
+			contract := seedRankingEvents(t, store)
+
+			events, err := manager.RankedUserMemoryEvents(context.Background(), "u1", "图仑科技的合同什么时候到期", 3)
+			if err != nil {
+				t.Fatalf("rank err: %v", err)
+			}
+			if len(events) != 3 {
+				t.Fatalf("want 3 events, got %d", len(events))
+			}
+			selected := false
+			for _, evt := range events {
+				selected = selected || evt.ID == contract.ID
+			}
+			if selected != tc.wantContract {
+				t.Fatalf("contract selected = %v, want %v: %+v", selected, tc.wantContract, events)
+			}
+		})
+	}
+}
+
+func TestMemoryManager_SearchRecordsEventAccess(t *testing.T) {
+	ctx := context.Background()
+	manager, store := newTestManager(t, func(config *builtin.MemoryConfig) {
+		config.EnableEventSearch = true
+	})
+	seedRankingEvents(t, store)
+
+	query := &builtin.UserMemoryEventQuery{UserID: "u1", Keywords: []string{"合同"}}
+	if _, err := manager.SearchUserMemoryEvents(ctx, query); err != nil {
+		t.Fatalf("search err: %v", err)
+	}
+	hits, _ := store.SearchUserMemoryEvents(ctx, query)
+	if len(hits) != 1 || hits[0].AccessCount != 1 || hits[0].LastAccessedAt == nil {
+		t.Fatalf("expected access to be recorded: %+v", hits)
+	}
+}
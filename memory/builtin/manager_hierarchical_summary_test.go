@@ -0,0 +1,186 @@
+package builtin_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// layeredSummaryModel 分段摘要返回第一条用户消息，汇总与周期摘要返回固定文本并记录输入
+type layeredSummaryModel struct {
+	mu     sync.Mutex
+	inputs []string
+}
+
+func (m *layeredSummaryModel) Generate(ctx context.Context, input []*schema.AgenticMessage, opts ...einomodel.Option) (*schema.AgenticMessage, error) {
+	system, user := agmsg.Text(input[0]), agmsg.Text(input[len(input)-1])
+	m.mu.Lock()
+	m.inputs = append(m.inputs, user)
+	m.mu.Unlock()
+
+	switch {
+	case strings.Contains(system, "会话总摘要汇总任务"):
+		return agmsg.AssistantMessage("总摘要：用户在处理售后事务"), nil
+	case strings.Contains(system, "用户周期摘要任务"):
+		return agmsg.AssistantMessage("本期主题：售后与出行"), nil
+	}
+	if _, rest, ok := strings.Cut(user, "用户: "); ok {
+		line, _, _ := strings.Cut(rest, "\n")
+		return agmsg.AssistantMessage("分段：" + line), nil
+	}
+	return agmsg.AssistantMessage(""), nil
+}
+
+func (m *layeredSummaryModel) Stream(ctx context.Context, input []*schema.AgenticMessage, opts ...einomodel.Option) (*schema.StreamReader[*schema.AgenticMessage], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.AgenticMessage{msg}), nil
+}
+
+// lastInput 返回最近一次包含 marker 的输入
+func (m *layeredSummaryModel) lastInput(marker string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.inputs) - 1; i >= 0; i-- {
+		if strings.Contains(m.inputs[i], marker) {
+			return m.inputs[i]
+		}
+	}
+	return ""
+}
+
+var layeredTurns = [][2]string{
+	{"退款订单10086失败", "原因是余额不足"},
+	{"发票抬头改成公司", "已修改"},
+	{"快递地址换到北京", "好的"},
+}
+
+// newLayeredManager 写入一个 8 天前的旧会话使第一份周期摘要到期，
+// 再在 s1 中完成三轮对话，每轮生成一个分段，第三个分段触发汇总
+func newLayeredManager(t *testing.T) (*builtin.MemoryManager, *layeredSummaryModel) {
+	t.Helper()
+	ctx := context.Background()
+	cm := &layeredSummaryModel{}
+	store := storage.NewMemoryStore()
+	manager := newManagerWith(t, cm, store, func(config *builtin.MemoryConfig) {
+		config.EnableUserMemories = false
+		config.EnableSessionSummary = true
+		config.SummaryTrigger = builtin.SummaryTriggerConfig{Strategy: builtin.TriggerByMessages, MessageThreshold: 2}
+		config.HierarchicalSummary = &builtin.HierarchicalSummaryConfig{ChunkMessages: 2, RollupChunks: 2, RetrieveChunkLimit: 1}
+		config.UserDigest = &builtin.UserDigestConfig{PeriodDays: 7}
+	})
+
+	old := time.Now().Add(-8 * 24 * time.Hour)
+	for i, content := range []string{"下周去上海出差", "已记录"} {
+		if err := store.SaveMessage(ctx, &builtin.ConversationMessage{SessionID: "s-old", UserID: "u1", Role: "user", Content: content, CreatedAt: old.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatalf("save message err: %v", err)
+		}
+	}
+
+	for i, turn := range layeredTurns {
+		processTurn(t, manager, "s1", turn[0], turn[1])
+		var chunks []*builtin.SummaryChunk
+		eventually(func() bool {
+			chunks, _ = manager.ListSummaryChunks(ctx, "s1", "u1")
+			return len(chunks) == i+1
+		})
+		if len(chunks) != i+1 {
+			t.Fatalf("turn %d: chunks = %d, want %d", i+1, len(chunks), i+1)
+		}
+	}
+	return manager, cm
+}
+
+func waitDigests(manager *builtin.MemoryManager) []*builtin.UserDigest {
+	var digests []*builtin.UserDigest
+	eventually(func() bool {
+		digests, _ = manager.ListUserDigests(context.Background(), "u1", 0)
+		return len(digests) > 0
+	})
+	return digests
+}
+
+func TestMemoryManager_HierarchicalSummary(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name  string
+		check func(t *testing.T, manager *builtin.MemoryManager, cm *layeredSummaryModel)
+	}{
+		{
+			name: "chunks follow the turns",
+			check: func(t *testing.T, manager *builtin.MemoryManager, _ *layeredSummaryModel) {
+				chunks, _ := manager.ListSummaryChunks(ctx, "s1", "u1")
+				for i, chunk := range chunks {
+					if chunk.Summary != "分段："+layeredTurns[i][0] || chunk.MessageCount != 2 || chunk.StartMessageID == "" || chunk.EndMessageID == "" {
+						t.Fatalf("unexpected chunk %d: %#v", i, chunk)
+					}
+				}
+			},
+		},
+		{
+			name: "rolls pending chunks into the session abstract",
+			check: func(t *testing.T, manager *builtin.MemoryManager, cm *layeredSummaryModel) {
+				summary, err := manager.GetSessionSummary(ctx, "s1", "u1")
+				if err != nil || summary == nil || summary.Summary != "总摘要：用户在处理售后事务" || summary.RolledUpChunkCount != 3 {
+					t.Fatalf("unexpected session abstract: %#v, err=%v", summary, err)
+				}
+				if input := cm.lastInput("## 新增分段摘要"); !strings.Contains(input, "分段：发票抬头改成公司") || !strings.Contains(input, "分段：快递地址换到北京") {
+					t.Fatalf("rollup should include the pending chunks: %s", input)
+				}
+			},
+		},
+		{
+			name: "retrieves the relevant chunk",
+			check: func(t *testing.T, manager *builtin.MemoryManager, _ *layeredSummaryModel) {
+				relevant, err := manager.RelevantSummaryChunks(ctx, "s1", "u1", "退款进度怎么样")
+				if err != nil || len(relevant) != 1 || relevant[0].Summary != "分段：退款订单10086失败" {
+					t.Fatalf("unexpected relevant chunks: %#v, err=%v", relevant, err)
+				}
+			},
+		},
+		{
+			// 助手回复后检查到期并异步生成第一份周期摘要
+			name: "generates the due periodic digest",
+			check: func(t *testing.T, manager *builtin.MemoryManager, _ *layeredSummaryModel) {
+				digests := waitDigests(manager)
+				if len(digests) != 1 || digests[0].Digest != "本期主题：售后与出行" {
+					t.Fatalf("unexpected digests: %#v", digests)
+				}
+				if !digests[0].PeriodEnd.After(digests[0].PeriodStart) || len(digests[0].SessionIDs) == 0 {
+					t.Fatalf("unexpected digest period or sessions: %#v", digests[0])
+				}
+			},
+		},
+		{
+			name: "manual digest only covers the period",
+			check: func(t *testing.T, manager *builtin.MemoryManager, cm *layeredSummaryModel) {
+				// 先等自动生成的周期摘要完成，避免其输入覆盖手动摘要的记录
+				waitDigests(manager)
+				digest, err := manager.GenerateUserDigest(ctx, "u1", time.Now().Add(-time.Hour), time.Now().Add(time.Minute))
+				if err != nil || digest == nil || fmt.Sprint(digest.SessionIDs) != "[s1]" {
+					t.Fatalf("unexpected manual digest: %#v, err=%v", digest, err)
+				}
+				if input := cm.lastInput("## 会话材料"); !strings.Contains(input, "分段：退款订单10086失败") || strings.Contains(input, "上海出差") {
+					t.Fatalf("digest material should only cover the period: %s", input)
+				}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			manager, cm := newLayeredManager(t)
+			tc.check(t, manager, cm)
+		})
+	}
+}
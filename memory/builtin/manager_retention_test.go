@@ -0,0 +1,179 @@
+package builtin_test
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+)
+
+type retentionFixture struct {
+	manager    *builtin.MemoryManager
+	store      *storage.MemoryStore
+	model      *staticAgenticModel
+	archiveDir string
+}
+
+// newRetentionFixture 为 u1 和 vip 各写入 5 条旧消息和 1 条新消息，并为 u1 写入四类事件。
+// 默认规则清理 7 天前的消息、30 天前的普通事件和 1 天前被取代的事件，vip 用户不清理。
+func newRetentionFixture(t *testing.T) *retentionFixture {
+	t.Helper()
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	now := time.Now()
+
+	for _, userID := range []string{"u1", "vip"} {
+		for i := 0; i < 5; i++ {
+			_ = store.SaveMessage(ctx, &builtin.ConversationMessage{SessionID: "s1", UserID: userID, Role: "user", Content: "旧消息", CreatedAt: now.Add(-10*24*time.Hour + time.Duration(i)*time.Minute)})
+		}
+		_ = store.SaveMessage(ctx, &builtin.ConversationMessage{SessionID: "s1", UserID: userID, Role: "user", Content: "新消息", CreatedAt: now.Add(-time.Hour)})
+	}
+
+	twoDaysAgo := now.Add(-48 * time.Hour)
+	for _, evt := range []*builtin.UserMemoryEvent{
+		{ID: "old", UserID: "u1", Type: builtin.UserMemoryEventTypeEvent, Summary: "很久以前的事件", EventDate: now.AddDate(0, -2, 0), Importance: 3},
+		{ID: "important", UserID: "u1", Type: builtin.UserMemoryEventTypeMilestone, Summary: "重要里程碑", EventDate: now.AddDate(0, -2, 0), Importance: 5},
+		{ID: "recent", UserID: "u1", Type: builtin.UserMemoryEventTypeEvent, Summary: "最近的事件", EventDate: now},
+		{ID: "superseded", UserID: "u1", Type: builtin.UserMemoryEventTypeEvent, Summary: "已被取代", EventDate: now, SupersededBy: "recent", SupersededAt: &twoDaysAgo},
+	} {
+		if err := store.SaveUserMemoryEvent(ctx, evt); err != nil {
+			t.Fatalf("save event err: %v", err)
+		}
+	}
+
+	archiveDir := t.TempDir()
+	archiver, err := builtin.NewJSONLArchiver(archiveDir)
+	if err != nil {
+		t.Fatalf("new archiver err: %v", err)
+	}
+	cm := &staticAgenticModel{response: "用户聊了一些旧话题"}
+	manager := newManagerWith(t, cm, store, func(config *builtin.MemoryConfig) {
+		config.EnableUserMemories = false
+		config.Retention = &builtin.RetentionConfig{
+			Policy: &builtin.TieredRetentionPolicy{
+				Tier:  func(ctx context.Context, userID string) string { return userID },
+				Rules: map[string]*builtin.RetentionRule{"vip": nil},
+				Default: &builtin.RetentionRule{
+					MessageMaxAge:         7 * 24 * time.Hour,
+					SummarizeBeforePrune:  true,
+					EventMaxAge:           30 * 24 * time.Hour,
+					KeepEventImportance:   5,
+					SupersededEventMaxAge: 24 * time.Hour,
+				},
+			},
+			Archiver: archiver,
+		}
+	})
+	return &retentionFixture{manager: manager, store: store, model: cm, archiveDir: archiveDir}
+}
+
+func TestMemoryManager_RetentionDryRun(t *testing.T) {
+	ctx := context.Background()
+	f := newRetentionFixture(t)
+
+	preview, err := f.manager.ApplyRetention(ctx, true)
+	if err != nil {
+		t.Fatalf("dry run err: %v", err)
+	}
+	if preview.MessagesDeleted != 5 || len(preview.Sessions) != 1 || !preview.Sessions[0].NeedsSummary || preview.EventsDeleted != 2 {
+		t.Fatalf("unexpected dry run report: %+v", preview)
+	}
+	if count, _ := f.store.GetMessageCount(ctx, "u1", "s1"); count != 6 {
+		t.Fatalf("dry run should not delete messages, got %d left", count)
+	}
+	if f.model.calls.Load() != 0 {
+		t.Fatal("dry run should not generate summaries")
+	}
+}
+
+func TestMemoryManager_ApplyRetention(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name  string
+		check func(t *testing.T, f *retentionFixture, report *builtin.RetentionReport)
+	}{
+		{
+			name: "reports deletions and archives",
+			check: func(t *testing.T, _ *retentionFixture, report *builtin.RetentionReport) {
+				if report.MessagesDeleted != 5 || report.MessagesArchived != 5 || report.EventsDeleted != 2 || report.EventsArchived != 2 {
+					t.Fatalf("unexpected report: %+v", report)
+				}
+			},
+		},
+		{
+			name: "summarizes before pruning messages",
+			check: func(t *testing.T, f *retentionFixture, _ *builtin.RetentionReport) {
+				summary, _ := f.store.GetSessionSummary(ctx, "s1", "u1")
+				if summary == nil || summary.Summary != f.model.response {
+					t.Fatalf("summary should be generated before pruning: %+v", summary)
+				}
+				if remaining, _ := f.store.GetMessages(ctx, "s1", "u1", 0); len(remaining) != 1 || remaining[0].Content != "新消息" {
+					t.Fatalf("only the recent message should remain: %+v", remaining)
+				}
+			},
+		},
+		{
+			name: "skips exempt tiers",
+			check: func(t *testing.T, f *retentionFixture, _ *builtin.RetentionReport) {
+				if count, _ := f.store.GetMessageCount(ctx, "vip", "s1"); count != 6 {
+					t.Fatalf("vip messages should be kept, got %d", count)
+				}
+			},
+		},
+		{
+			name: "keeps important and recent events",
+			check: func(t *testing.T, f *retentionFixture, _ *builtin.RetentionReport) {
+				left, _ := f.store.SearchUserMemoryEvents(ctx, &builtin.UserMemoryEventQuery{UserID: "u1", IncludeSuperseded: true})
+				ids := map[string]bool{}
+				for _, evt := range left {
+					ids[evt.ID] = true
+				}
+				if len(left) != 2 || !ids["important"] || !ids["recent"] {
+					t.Fatalf("expected important and recent events to remain, got %v", ids)
+				}
+			},
+		},
+		{
+			name: "writes archive files",
+			check: func(t *testing.T, f *retentionFixture, report *builtin.RetentionReport) {
+				day := report.StartedAt.Format("20060102")
+				if lines := countLines(t, filepath.Join(f.archiveDir, "messages-"+day+".jsonl")); lines != 5 {
+					t.Fatalf("expected 5 archived messages, got %d", lines)
+				}
+				if lines := countLines(t, filepath.Join(f.archiveDir, "events-"+day+".jsonl")); lines != 2 {
+					t.Fatalf("expected 2 archived events, got %d", lines)
+				}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newRetentionFixture(t)
+			report, err := f.manager.ApplyRetention(ctx, false)
+			if err != nil || len(report.Errors) > 0 {
+				t.Fatalf("apply retention err: %v %v", err, report.Errors)
+			}
+			tc.check(t, f, report)
+		})
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s err: %v", path, err)
+	}
+	defer file.Close()
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
@@ -0,0 +1,137 @@
+package builtin_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+)
+
+var sessionBase = time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+
+// newSessionManager 创建开启自动标题的管理器，并写入一个没有会话元数据的旧会话 s-old
+func newSessionManager(t *testing.T) (*builtin.MemoryManager, *storage.MemoryStore) {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	cm := &staticAgenticModel{response: "标题：《退款失败排查》\n多余的说明"}
+	manager := newManagerWith(t, cm, store, func(config *builtin.MemoryConfig) {
+		config.EnableUserMemories = false
+		config.SessionTitle = &builtin.SessionTitleConfig{MaxRunes: 8}
+	})
+	for i, content := range []string{"旧会话问题", "旧会话回答"} {
+		if err := store.SaveMessage(context.Background(), &builtin.ConversationMessage{SessionID: "s-old", UserID: "u1", Role: "user", Content: content, CreatedAt: sessionBase.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatalf("save message err: %v", err)
+		}
+	}
+	return manager, store
+}
+
+// startTitledSession 在 s-new 中完成一轮对话，并等待助手回复后异步生成的标题
+func startTitledSession(t *testing.T, manager *builtin.MemoryManager) *builtin.Session {
+	t.Helper()
+	processTurn(t, manager, "s-new", "订单 10086 退款失败了", "原因是账户余额不足")
+	var session *builtin.Session
+	eventually(func() bool {
+		session, _ = manager.GetSession(context.Background(), "s-new", "u1")
+		return session != nil && session.Title != ""
+	})
+	return session
+}
+
+func TestMemoryManager_SessionAutoTitle(t *testing.T) {
+	manager, _ := newSessionManager(t)
+
+	// 模型输出中的前缀、书名号和多余内容被清理
+	if session := startTitledSession(t, manager); session == nil || session.Title != "退款失败排查" || session.MessageCount != 2 {
+		t.Fatalf("unexpected auto titled session: %#v", session)
+	}
+}
+
+func TestMemoryManager_ListSessions(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := newSessionManager(t)
+	startTitledSession(t, manager)
+
+	sessions, err := manager.ListSessions(ctx, &builtin.SessionQuery{UserID: "u1"})
+	if err != nil {
+		t.Fatalf("list sessions err: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].SessionID != "s-new" || sessions[1].SessionID != "s-old" {
+		t.Fatalf("sessions not ordered by activity: %#v", sessions)
+	}
+	// 没有元数据的旧会话由消息推算统计信息
+	if !sessions[1].LastActiveAt.Equal(sessionBase.Add(time.Minute)) || !sessions[1].CreatedAt.Equal(sessionBase) {
+		t.Fatalf("unexpected stats for legacy session: %#v", sessions[1])
+	}
+
+	if _, err := manager.RenameSession(ctx, "s-old", "u1", "  旧会话  "); err != nil {
+		t.Fatalf("rename session err: %v", err)
+	}
+	if _, err := manager.ArchiveSession(ctx, "s-new", "u1", true); err != nil {
+		t.Fatalf("archive session err: %v", err)
+	}
+	if _, err := manager.RenameSession(ctx, "missing", "u1", "x"); err == nil {
+		t.Fatal("renaming a missing session should fail")
+	}
+
+	cases := []struct {
+		name  string
+		query *builtin.SessionQuery
+		want  []string
+	}{
+		// 归档会话默认隐藏
+		{name: "active", query: &builtin.SessionQuery{UserID: "u1"}, want: []string{"s-old"}},
+		{name: "archived only", query: &builtin.SessionQuery{UserID: "u1", ArchivedOnly: true}, want: []string{"s-new"}},
+		{name: "paged", query: &builtin.SessionQuery{UserID: "u1", IncludeArchived: true, Offset: 1, Limit: 1}, want: []string{"s-old"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := manager.ListSessions(ctx, tc.query)
+			if err != nil || len(got) != len(tc.want) {
+				t.Fatalf("sessions = %#v err=%v, want %v", got, err, tc.want)
+			}
+			for i, session := range got {
+				if session.SessionID != tc.want[i] {
+					t.Fatalf("sessions[%d] = %s, want %s", i, session.SessionID, tc.want[i])
+				}
+				switch session.SessionID {
+				case "s-old":
+					if session.Title != "旧会话" {
+						t.Fatalf("title should be trimmed, got %q", session.Title)
+					}
+				case "s-new":
+					if session.ArchivedAt.IsZero() {
+						t.Fatalf("archived session should record ArchivedAt: %#v", session)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryManager_DeleteSession(t *testing.T) {
+	ctx := context.Background()
+	manager, store := newSessionManager(t)
+	startTitledSession(t, manager)
+	if err := store.SaveSessionSummary(ctx, &builtin.SessionSummary{SessionID: "s-new", UserID: "u1", Summary: "退款失败"}); err != nil {
+		t.Fatalf("save summary err: %v", err)
+	}
+
+	if err := manager.DeleteSession(ctx, "s-new", "u1"); err != nil {
+		t.Fatalf("delete session err: %v", err)
+	}
+	if session, _ := manager.GetSession(ctx, "s-new", "u1"); session != nil {
+		t.Fatalf("deleted session still exists: %#v", session)
+	}
+	if summary, _ := manager.GetSessionSummary(ctx, "s-new", "u1"); summary != nil {
+		t.Fatalf("summary of deleted session still exists: %#v", summary)
+	}
+	if msgs, _ := store.GetMessages(ctx, "s-new", "u1", 0); len(msgs) != 0 {
+		t.Fatalf("messages of deleted session still exist: %d", len(msgs))
+	}
+	if session, _ := manager.GetSession(ctx, "s-old", "u1"); session == nil {
+		t.Fatal("other sessions should be kept")
+	}
+}
@@ -0,0 +1,186 @@
+package builtin_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// topicEmbedder 把包含“退款”的文本映射到一个方向，其余文本映射到正交方向
+type topicEmbedder struct{}
+
+func (topicEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		if strings.Contains(text, "退款") {
+			vectors[i] = []float64{1, 0}
+		} else {
+			vectors[i] = []float64{0, 1}
+		}
+	}
+	return vectors, nil
+}
+
+// tokenTriggerConfig 按字符数计 token，累计 20 个以上触发摘要
+func tokenTriggerConfig(config *builtin.MemoryConfig) {
+	config.EnableUserMemories = false
+	config.EnableSessionSummary = true
+	config.SummaryTrigger = builtin.SummaryTriggerConfig{
+		Strategy:       builtin.TriggerByTokens,
+		TokenThreshold: 20,
+		TokenCounter:   utf8.RuneCountInString,
+	}
+}
+
+func newTokenTriggerManager(t *testing.T, store builtin.MemoryStorage) *builtin.MemoryManager {
+	t.Helper()
+	return newManagerWith(t, &staticAgenticModel{response: "用户在排查退款问题"}, store, tokenTriggerConfig)
+}
+
+func TestMemoryManager_TokenTriggerSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := storage.NewFileStore(dir, 0)
+	if err != nil {
+		t.Fatalf("new file store err: %v", err)
+	}
+	// 第一个管理器需要在重启前关闭，不使用自动关闭的辅助函数
+	config := builtin.DefaultMemoryConfig()
+	tokenTriggerConfig(config)
+	manager, err := builtin.NewMemoryManager(&staticAgenticModel{response: "用户在排查退款问题"}, store, config)
+	if err != nil {
+		t.Fatalf("new manager err: %v", err)
+	}
+	processTurn(t, manager, "s1", "退款失败了", "我看看")
+	manager.Close()
+
+	// 重启后累计的 token 数从存储恢复
+	store, err = storage.NewFileStore(dir, 0)
+	if err != nil {
+		t.Fatalf("reopen file store err: %v", err)
+	}
+	state, err := store.GetSummaryTriggerState(ctx, "s1", "u1")
+	if err != nil || state == nil || state.MessagesSinceLastSummary != 2 || state.UnsummarizedTokens != 8 {
+		t.Fatalf("unexpected persisted state: %#v, err=%v", state, err)
+	}
+
+	manager = newTokenTriggerManager(t, store)
+	processTurn(t, manager, "s1", "订单号10086", "收到")
+	if summary, _ := manager.GetSessionSummary(ctx, "s1", "u1"); summary != nil {
+		t.Fatalf("restored tokens plus the new turn are below the threshold: %#v", summary)
+	}
+	processTurn(t, manager, "s1", "余额不足怎么办", "请先充值")
+	if waitSummary(manager, "s1") == nil {
+		t.Fatal("summary should be generated once the restored count reaches the threshold")
+	}
+}
+
+func TestMemoryManager_TokenTriggerResetsAfterSummary(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	manager := newTokenTriggerManager(t, store)
+
+	processTurn(t, manager, "s1", "退款失败了", "我看看")
+	processTurn(t, manager, "s1", "订单号10086", "收到")
+	if summary, _ := manager.GetSessionSummary(ctx, "s1", "u1"); summary != nil {
+		t.Fatalf("summary generated below token threshold: %#v", summary)
+	}
+	processTurn(t, manager, "s1", "余额不足怎么办", "请先充值")
+	if waitSummary(manager, "s1") == nil {
+		t.Fatal("summary should be generated once the token threshold is reached")
+	}
+
+	var state *builtin.SessionState
+	eventually(func() bool {
+		state, _ = store.GetSummaryTriggerState(ctx, "s1", "u1")
+		return state != nil && state.UnsummarizedTokens == 0
+	})
+	if state == nil || state.UnsummarizedTokens != 0 || state.MessagesSinceLastSummary != 0 || state.TotalMessages != 6 {
+		t.Fatalf("trigger state should be reset after summary: %#v", state)
+	}
+
+	if err := manager.DeleteSession(ctx, "s1", "u1"); err != nil {
+		t.Fatalf("delete session err: %v", err)
+	}
+	if state, _ := store.GetSummaryTriggerState(ctx, "s1", "u1"); state != nil {
+		t.Fatalf("trigger state should be deleted with the session: %#v", state)
+	}
+}
+
+func TestMemoryManager_TokenTriggerIgnoresToolResults(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	manager := newTokenTriggerManager(t, store)
+
+	// 工具结果远超阈值，但不进入摘要输入，也不计入触发计数
+	for _, msg := range []*builtin.ConversationMessage{
+		{SessionID: "s1", UserID: "u1", Role: "assistant", ToolCalls: []builtin.ToolCall{{CallID: "c1", Name: "query_order"}}},
+		{SessionID: "s1", UserID: "u1", Role: builtin.MessageRoleTool, Content: strings.Repeat("原始订单数据", 50)},
+	} {
+		if err := manager.SaveMessage(ctx, msg); err != nil {
+			t.Fatalf("save message err: %v", err)
+		}
+	}
+	processTurn(t, manager, "s1", "退款", "好")
+
+	var state *builtin.SessionState
+	eventually(func() bool {
+		state, _ = store.GetSummaryTriggerState(ctx, "s1", "u1")
+		return state != nil && state.TotalMessages == 4
+	})
+	if state == nil || state.UnsummarizedTokens != 3 || state.MessagesSinceLastSummary != 3 {
+		t.Fatalf("tool results should not count toward the trigger: %#v", state)
+	}
+	if summary, _ := manager.GetSessionSummary(ctx, "s1", "u1"); summary != nil {
+		t.Fatalf("summary generated below token threshold: %#v", summary)
+	}
+}
+
+func TestMemoryManager_TopicShiftTrigger(t *testing.T) {
+	sameTopic := [][2]string{
+		{"退款一直没到账", "正在处理退款"},
+		{"退款什么时候到", "预计三天内退款到账"},
+	}
+	cases := []struct {
+		name        string
+		turns       [][2]string
+		wantSummary bool
+	}{
+		{name: "same topic", turns: sameTopic},
+		{name: "topic shift", turns: append(sameTopic[:2:2], [2]string{"明天天气怎么样", "明天晴"}), wantSummary: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			manager := newManagerWith(t, &staticAgenticModel{response: "用户咨询了退款问题"}, storage.NewMemoryStore(), func(config *builtin.MemoryConfig) {
+				config.EnableUserMemories = false
+				config.EnableSessionSummary = true
+				config.SummaryTrigger = builtin.SummaryTriggerConfig{
+					Strategy:              builtin.TriggerTopicShift,
+					MessageThreshold:      10,
+					MinTopicShiftMessages: 4,
+					TopicShiftEmbedder:    topicEmbedder{},
+				}
+			})
+			for _, turn := range tc.turns {
+				processTurn(t, manager, "s1", turn[0], turn[1])
+			}
+
+			if tc.wantSummary {
+				if waitSummary(manager, "s1") == nil {
+					t.Fatal("summary should be generated when the topic shifts")
+				}
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+			if summary, _ := manager.GetSessionSummary(context.Background(), "s1", "u1"); summary != nil {
+				t.Fatalf("summary generated without a topic shift: %#v", summary)
+			}
+		})
+	}
+}
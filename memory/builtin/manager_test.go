@@ -0,0 +1,108 @@
+package builtin_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const noopResponse = `{"op":"noop"}`
+
+// staticAgenticModel 总是返回固定内容，并记录调用次数
+type staticAgenticModel struct {
+	response string
+	calls    atomic.Int64
+}
+
+func (m *staticAgenticModel) Generate(ctx context.Context, input []*schema.AgenticMessage, opts ...einomodel.Option) (*schema.AgenticMessage, error) {
+	m.calls.Add(1)
+	return agmsg.AssistantMessage(m.response), nil
+}
+
+func (m *staticAgenticModel) Stream(ctx context.Context, input []*schema.AgenticMessage, opts ...einomodel.Option) (*schema.StreamReader[*schema.AgenticMessage], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.AgenticMessage{msg}), nil
+}
+
+// newTestManager 使用内存存储和返回 noop 的模型创建管理器，configure 可调整默认配置
+func newTestManager(t *testing.T, configure func(*builtin.MemoryConfig)) (*builtin.MemoryManager, *storage.MemoryStore) {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	return newManagerWith(t, &staticAgenticModel{response: noopResponse}, store, configure), store
+}
+
+// newManagerWith 使用指定的模型与存储创建管理器，测试结束时自动关闭
+func newManagerWith(t *testing.T, cm einomodel.AgenticModel, store builtin.MemoryStorage, configure func(*builtin.MemoryConfig)) *builtin.MemoryManager {
+	t.Helper()
+	config := builtin.DefaultMemoryConfig()
+	if configure != nil {
+		configure(config)
+	}
+	manager, err := builtin.NewMemoryManager(cm, store, config)
+	if err != nil {
+		t.Fatalf("new manager err: %v", err)
+	}
+	t.Cleanup(func() { manager.Close() })
+	return manager
+}
+
+// processTurn 以 u1 的身份完成一轮用户与助手对话
+func processTurn(t *testing.T, manager *builtin.MemoryManager, sessionID, user, assistant string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := manager.ProcessUserMessage(ctx, "u1", sessionID, user, nil); err != nil {
+		t.Fatalf("process user message err: %v", err)
+	}
+	if err := manager.ProcessAssistantMessage(ctx, "u1", sessionID, assistant); err != nil {
+		t.Fatalf("process assistant message err: %v", err)
+	}
+}
+
+// eventually 在超时前轮询 cond，用于等待异步任务完成
+func eventually(cond func() bool) bool {
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return false
+}
+
+func waitSummary(manager *builtin.MemoryManager, sessionID string) *builtin.SessionSummary {
+	var summary *builtin.SessionSummary
+	eventually(func() bool {
+		summary, _ = manager.GetSessionSummary(context.Background(), sessionID, "u1")
+		return summary != nil
+	})
+	return summary
+}
+
+// memoryOf 返回当前存储的记忆文本，不存在时返回空字符串
+func memoryOf(t *testing.T, store builtin.MemoryStorage, userID string) string {
+	t.Helper()
+	mem, err := store.GetUserMemory(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("get memory err: %v", err)
+	}
+	if mem == nil {
+		return ""
+	}
+	return mem.Memory
+}
+
+func seedMemory(t *testing.T, store builtin.MemoryStorage, userID, memory string) {
+	t.Helper()
+	if err := store.UpsertUserMemory(context.Background(), &builtin.UserMemory{UserID: userID, Memory: memory}); err != nil {
+		t.Fatalf("seed memory err: %v", err)
+	}
+}
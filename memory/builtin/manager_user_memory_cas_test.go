@@ -0,0 +1,69 @@
+package builtin_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+)
+
+func TestMemoryManager_UpdateUserMemoryBaseVersion(t *testing.T) {
+	cases := []struct {
+		name        string
+		baseVersion int64
+		conflict    bool
+		wantMemory  string
+		wantVersion int64
+	}{
+		// 带过期基线版本的更新返回冲突，原记忆保持不变
+		{name: "stale base version", baseVersion: 1, conflict: true, wantMemory: "初始", wantVersion: 2},
+		{name: "current base version", baseVersion: 2, wantMemory: "基于新版本", wantVersion: 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			manager, store := newTestManager(t, nil)
+			seedMemory(t, store, "u1", "旧")
+			seedMemory(t, store, "u1", "初始")
+
+			baseVersion := tc.baseVersion
+			_, err := manager.UpdateUserMemory(ctx, "u1", &builtin.UserMemoryUpdate{Memory: "基于新版本", Source: builtin.UserMemorySourceManual, BaseVersion: &baseVersion})
+			if tc.conflict != errors.Is(err, builtin.ErrUserMemoryConflict) || (!tc.conflict && err != nil) {
+				t.Fatalf("update err = %v, want conflict=%v", err, tc.conflict)
+			}
+			if mem, _ := store.GetUserMemory(ctx, "u1"); mem == nil || mem.Memory != tc.wantMemory || mem.Version != tc.wantVersion {
+				t.Fatalf("unexpected memory: %+v", mem)
+			}
+		})
+	}
+}
+
+func TestMemoryManager_EditRetryRecountsAfterConflict(t *testing.T) {
+	cases := []struct {
+		name        string
+		concurrent  string
+		forget      string
+		wantRemoved int
+	}{
+		// 其他进程抢先删除了同一行，重试后文档不再变化，不应报告删除
+		{name: "line already removed", concurrent: "# 用户记忆\n## 偏好\n- 喜欢茶\n", forget: "咖啡", wantRemoved: 0},
+		// 其他进程追加了一行，重试后只统计最新文档中的匹配行
+		{name: "line appended", concurrent: "# 用户记忆\n## 偏好\n- 喜欢咖啡\n- 喜欢茶\n- 绿茶\n", forget: "茶", wantRemoved: 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			manager, store := newRacingManager(t, "# 用户记忆\n## 偏好\n- 喜欢咖啡\n- 喜欢茶\n")
+			store.race = func(ctx context.Context) {
+				_ = store.MemoryStore.UpsertUserMemory(ctx, &builtin.UserMemory{UserID: "u1", Memory: tc.concurrent})
+			}
+			removed, err := manager.ForgetUserMemoryText(context.Background(), "u1", tc.forget)
+			if err != nil {
+				t.Fatalf("forget err: %v", err)
+			}
+			if removed != tc.wantRemoved {
+				t.Fatalf("removed = %d, want %d", removed, tc.wantRemoved)
+			}
+		})
+	}
+}
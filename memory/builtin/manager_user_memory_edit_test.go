@@ -0,0 +1,243 @@
+package builtin_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+)
+
+// racingStore 在第一次按版本写入或删除前插入一次其他进程的写入，模拟版本冲突
+type racingStore struct {
+	*storage.MemoryStore
+	race       func(ctx context.Context)
+	raceDelete func(ctx context.Context)
+}
+
+func (s *racingStore) CompareAndDeleteUserMemory(ctx context.Context, userID string, expectedVersion int64) error {
+	if race := s.raceDelete; race != nil {
+		s.raceDelete = nil
+		race(ctx)
+	}
+	return s.MemoryStore.CompareAndDeleteUserMemory(ctx, userID, expectedVersion)
+}
+
+func (s *racingStore) CompareAndSwapUserMemory(ctx context.Context, memory *builtin.UserMemory, expectedVersion int64) error {
+	if race := s.race; race != nil {
+		s.race = nil
+		race(ctx)
+	}
+	return s.MemoryStore.CompareAndSwapUserMemory(ctx, memory, expectedVersion)
+}
+
+// newRacingManager 创建基于 racingStore 的管理器，并写入初始记忆
+func newRacingManager(t *testing.T, memory string) (*builtin.MemoryManager, *racingStore) {
+	t.Helper()
+	store := &racingStore{MemoryStore: storage.NewMemoryStore()}
+	seedMemory(t, store, "u1", memory)
+	return newManagerWith(t, &staticAgenticModel{response: noopResponse}, store, nil), store
+}
+
+const editSeed = "# 用户记忆\n\n## 基础信息\n- 姓名：小王\n- 收货地址：北京市朝阳区A路1号\n\n## 事件记录\n- 2025-03-01 预约体检\n"
+
+func TestMemoryManager_EditUserMemory(t *testing.T) {
+	cases := []struct {
+		name string
+		// edit 返回变更数量：新增或删除的行数，找到的章节数
+		edit        func(ctx context.Context, manager *builtin.MemoryManager) (int, error)
+		wantChanged int
+		wantMemory  string
+	}{
+		{
+			name: "remember fact",
+			edit: func(ctx context.Context, manager *builtin.MemoryManager) (int, error) {
+				_, added, err := manager.RememberUserMemoryFact(ctx, "u1", "", "收货地址：上海市徐汇区B路2号")
+				return boolCount(added), err
+			},
+			wantChanged: 1,
+			wantMemory:  "# 用户记忆\n\n## 基础信息\n- 姓名：小王\n- 收货地址：北京市朝阳区A路1号\n- 收货地址：上海市徐汇区B路2号\n\n## 事件记录\n- 2025-03-01 预约体检\n",
+		},
+		{
+			name: "ignore duplicate fact",
+			edit: func(ctx context.Context, manager *builtin.MemoryManager) (int, error) {
+				revision, added, err := manager.RememberUserMemoryFact(ctx, "u1", "基础信息", "姓名：小王")
+				if revision != nil {
+					return 0, errors.New("duplicate fact should not create a revision")
+				}
+				return boolCount(added), err
+			},
+			wantMemory: editSeed,
+		},
+		{
+			name: "remember fact in new section",
+			edit: func(ctx context.Context, manager *builtin.MemoryManager) (int, error) {
+				_, added, err := manager.RememberUserMemoryFact(ctx, "u1", "核心约定", "不要使用emoji")
+				return boolCount(added), err
+			},
+			wantChanged: 1,
+			wantMemory:  editSeed + "\n## 核心约定\n- 不要使用emoji\n",
+		},
+		{
+			name: "forget text",
+			edit: func(ctx context.Context, manager *builtin.MemoryManager) (int, error) {
+				return manager.ForgetUserMemoryText(ctx, "u1", "北京市朝阳区")
+			},
+			wantChanged: 1,
+			wantMemory:  "# 用户记忆\n\n## 基础信息\n- 姓名：小王\n\n## 事件记录\n- 2025-03-01 预约体检\n",
+		},
+		{
+			name: "forget section",
+			edit: func(ctx context.Context, manager *builtin.MemoryManager) (int, error) {
+				found, err := manager.ForgetUserMemorySection(ctx, "u1", "事件记录")
+				return boolCount(found), err
+			},
+			wantChanged: 1,
+			wantMemory:  "# 用户记忆\n\n## 基础信息\n- 姓名：小王\n- 收货地址：北京市朝阳区A路1号\n",
+		},
+		{
+			// 其他用户的记忆不受影响
+			name: "replace for another user",
+			edit: func(ctx context.Context, manager *builtin.MemoryManager) (int, error) {
+				return manager.ReplaceUserMemoryText(ctx, "u2", "小王", "老王")
+			},
+			wantMemory: editSeed,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			manager, store := newTestManager(t, nil)
+			seedMemory(t, store, "u1", editSeed)
+
+			changed, err := tc.edit(context.Background(), manager)
+			if err != nil || changed != tc.wantChanged {
+				t.Fatalf("changed = %d err=%v, want %d", changed, err, tc.wantChanged)
+			}
+			if got := memoryOf(t, store, "u1"); got != tc.wantMemory {
+				t.Fatalf("unexpected memory:\n%s\nwant:\n%s", got, tc.wantMemory)
+			}
+		})
+	}
+}
+
+func boolCount(ok bool) int {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+// 删空文档同样记录版本，可以恢复删除前的内容
+func TestMemoryManager_ClearingUserMemoryRecordsRevision(t *testing.T) {
+	ctx := context.Background()
+	manager, store := newTestManager(t, nil)
+	seedMemory(t, store, "u1", "# 用户记忆\n\n## 基础信息\n- 姓名：小王\n\n## 核心约定\n- 不要使用emoji\n")
+
+	for _, section := range []string{"基础信息", "核心约定"} {
+		if _, err := manager.ForgetUserMemorySection(ctx, "u1", section); err != nil {
+			t.Fatalf("forget section %s err: %v", section, err)
+		}
+	}
+	if current, _ := store.GetUserMemory(ctx, "u1"); current != nil {
+		t.Fatalf("memory should be cleared, got %+v", current)
+	}
+	revisions, err := manager.ListUserMemoryRevisions(ctx, "u1", 2)
+	if err != nil || len(revisions) != 2 || revisions[0].Memory != "" || revisions[0].Source != builtin.UserMemorySourceTool {
+		t.Fatalf("clearing should record an empty revision, got %+v err=%v", revisions, err)
+	}
+	if _, err := manager.RestoreUserMemoryRevision(ctx, "u1", revisions[1].ID); err != nil {
+		t.Fatalf("restore err: %v", err)
+	}
+	if got := memoryOf(t, store, "u1"); got != "# 用户记忆\n\n## 核心约定\n- 不要使用emoji\n" {
+		t.Fatalf("unexpected restored memory: %q", got)
+	}
+}
+
+func TestMemoryManager_ClearUserMemoryIsConditional(t *testing.T) {
+	manager, store := newRacingManager(t, "# 用户记忆\n- 旧地址\n")
+
+	// 删除前其他进程追加了内容，删除应基于最新内容重试，不能抹掉新写入
+	store.raceDelete = func(ctx context.Context) {
+		_ = store.MemoryStore.UpsertUserMemory(ctx, &builtin.UserMemory{UserID: "u1", Memory: "# 用户记忆\n- 旧地址\n- 新写入\n"})
+	}
+	if removed, err := manager.ForgetUserMemoryText(context.Background(), "u1", "旧地址"); err != nil || removed != 1 {
+		t.Fatalf("forget removed=%d err=%v", removed, err)
+	}
+	if got := memoryOf(t, store, "u1"); got != "# 用户记忆\n- 新写入\n" {
+		t.Fatalf("concurrent write should survive, got %q", got)
+	}
+}
+
+// newEventManager 创建管理器并保存一条 u1 的事件
+func newEventManager(t *testing.T) (*builtin.MemoryManager, *builtin.UserMemoryEvent) {
+	t.Helper()
+	manager, _ := newTestManager(t, nil)
+	evt := &builtin.UserMemoryEvent{UserID: "u1", Summary: "周五下午三点开会", Keywords: []string{"开会"}}
+	if err := manager.SaveUserMemoryEvent(context.Background(), evt); err != nil {
+		t.Fatalf("save event err: %v", err)
+	}
+	return manager, evt
+}
+
+func TestMemoryManager_CorrectUserMemoryEvent(t *testing.T) {
+	cases := []struct {
+		name    string
+		userID  string
+		wantErr bool
+	}{
+		{name: "another user", userID: "u2", wantErr: true},
+		{name: "owner", userID: "u1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			manager, old := newEventManager(t)
+
+			corrected, err := manager.CorrectUserMemoryEvent(ctx, tc.userID, old.ID, &builtin.UserMemoryEvent{Summary: "周五下午四点开会"})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("correcting another user's event should fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("correct err: %v", err)
+			}
+			// 更正后的事件继承关键词，并记录被取代的旧事件
+			if len(corrected.Sources) != 1 || corrected.Sources[0] != old.ID || corrected.Keywords[0] != "开会" {
+				t.Fatalf("unexpected corrected event: %+v", corrected)
+			}
+			recent, _ := manager.ListRecentUserMemoryEvents(ctx, "u1", 10)
+			if len(recent) != 1 || recent[0].ID != corrected.ID {
+				t.Fatalf("old event should be superseded: %+v", recent)
+			}
+		})
+	}
+}
+
+func TestMemoryManager_DeleteUserMemoryEvent(t *testing.T) {
+	cases := []struct {
+		name       string
+		userID     string
+		wantErr    error
+		wantRemain int
+	}{
+		// 不能通过其他用户的 ID 删除事件
+		{name: "another user", userID: "u2", wantErr: builtin.ErrUserMemoryEventNotFound, wantRemain: 1},
+		{name: "owner", userID: "u1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			manager, evt := newEventManager(t)
+
+			if err := manager.DeleteUserMemoryEvent(ctx, tc.userID, evt.ID); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("delete err = %v, want %v", err, tc.wantErr)
+			}
+			if recent, _ := manager.ListRecentUserMemoryEvents(ctx, "u1", 10); len(recent) != tc.wantRemain {
+				t.Fatalf("remaining events = %d, want %d", len(recent), tc.wantRemain)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package builtin_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+)
+
+var historyBaseline = "# 用户记忆\n### 基础信息\n" + strings.Repeat("- 长期事实\n", 30)
+
+const historyAddedLine = "- 新增事实\n"
+
+type historyFixture struct {
+	manager *builtin.MemoryManager
+	store   *storage.MemoryStore
+	applied *builtin.UserMemoryRevision
+}
+
+// newHistoryFixture 在启用历史前写入记忆，再由分析器追加一行，首次更新时旧记忆应补录为基线
+func newHistoryFixture(t *testing.T) *historyFixture {
+	t.Helper()
+	manager, store := newTestManager(t, nil)
+	seedMemory(t, store, "u1", historyBaseline)
+	applied, err := manager.UpdateUserMemory(context.Background(), "u1", &builtin.UserMemoryUpdate{Memory: historyBaseline + historyAddedLine, Source: builtin.UserMemorySourceAnalyzer, SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("update err: %v", err)
+	}
+	return &historyFixture{manager: manager, store: store, applied: applied}
+}
+
+// rejectShrink 提交大幅缩减的更新，返回被拒绝的版本
+func (f *historyFixture) rejectShrink(t *testing.T) *builtin.UserMemoryRevision {
+	t.Helper()
+	rejected, err := f.manager.UpdateUserMemory(context.Background(), "u1", &builtin.UserMemoryUpdate{Memory: "# 用户记忆", Source: builtin.UserMemorySourceAnalyzer})
+	if !errors.Is(err, builtin.ErrUserMemoryShrinkRejected) {
+		t.Fatalf("expected shrink rejection, got %v", err)
+	}
+	if rejected == nil || rejected.Status != builtin.UserMemoryRevisionRejected {
+		t.Fatalf("rejected revision should be recorded: %+v", rejected)
+	}
+	return rejected
+}
+
+func TestMemoryManager_UserMemoryHistory(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name string
+		run  func(t *testing.T, f *historyFixture)
+	}{
+		{
+			name: "records the baseline before the first update",
+			run: func(t *testing.T, f *historyFixture) {
+				if f.applied.Revision != 2 || f.applied.Diff != "+ "+historyAddedLine {
+					t.Fatalf("unexpected revision: %+v", f.applied)
+				}
+				revisions, err := f.manager.ListUserMemoryRevisions(ctx, "u1", 0)
+				if err != nil || len(revisions) != 2 || revisions[1].Source != builtin.UserMemorySourceBaseline {
+					t.Fatalf("unexpected revisions: %+v err=%v", revisions, err)
+				}
+				diff, err := f.manager.DiffUserMemoryRevisions(ctx, "u1", revisions[1].ID, "")
+				if err != nil || diff != "+ "+historyAddedLine {
+					t.Fatalf("unexpected diff %q err=%v", diff, err)
+				}
+			},
+		},
+		{
+			name: "rejects a large shrink",
+			run: func(t *testing.T, f *historyFixture) {
+				f.rejectShrink(t)
+				if got := memoryOf(t, f.store, "u1"); got != historyBaseline+historyAddedLine {
+					t.Fatalf("memory should not change after rejection, got %q", got)
+				}
+			},
+		},
+		{
+			name: "restores the baseline",
+			run: func(t *testing.T, f *historyFixture) {
+				revisions, err := f.manager.ListUserMemoryRevisions(ctx, "u1", 0)
+				if err != nil {
+					t.Fatalf("list err: %v", err)
+				}
+				baseline := revisions[len(revisions)-1]
+				if _, err := f.manager.RestoreUserMemoryRevision(ctx, "u1", baseline.ID); err != nil {
+					t.Fatalf("restore err: %v", err)
+				}
+				if got := memoryOf(t, f.store, "u1"); got != historyBaseline {
+					t.Fatalf("memory should be restored to baseline, got %q", got)
+				}
+			},
+		},
+		{
+			name: "confirms a rejected revision",
+			run: func(t *testing.T, f *historyFixture) {
+				rejected := f.rejectShrink(t)
+				if _, err := f.manager.RestoreUserMemoryRevision(ctx, "u1", rejected.ID); err != nil {
+					t.Fatalf("confirm err: %v", err)
+				}
+				if got := memoryOf(t, f.store, "u1"); got != "# 用户记忆" {
+					t.Fatalf("confirmed revision should be applied, got %q", got)
+				}
+			},
+		},
+		{
+			// 删除记录的内容为空，恢复它等同于再次清空记忆
+			name: "restores an empty revision",
+			run: func(t *testing.T, f *historyFixture) {
+				if found, err := f.manager.ForgetUserMemorySection(ctx, "u1", "基础信息"); err != nil || !found {
+					t.Fatalf("forget section found=%v err=%v", found, err)
+				}
+				latest, err := f.manager.ListUserMemoryRevisions(ctx, "u1", 1)
+				if err != nil || len(latest) != 1 || latest[0].Memory != "" {
+					t.Fatalf("expected an empty revision: %+v err=%v", latest, err)
+				}
+				if _, err := f.manager.RestoreUserMemoryRevision(ctx, "u1", f.applied.ID); err != nil {
+					t.Fatalf("restore err: %v", err)
+				}
+				restored, err := f.manager.RestoreUserMemoryRevision(ctx, "u1", latest[0].ID)
+				if err != nil {
+					t.Fatalf("restore empty revision err: %v", err)
+				}
+				if restored == nil || restored.Source != builtin.UserMemorySourceRestore || restored.Memory != "" {
+					t.Fatalf("unexpected restore revision: %+v", restored)
+				}
+				if got := memoryOf(t, f.store, "u1"); got != "" {
+					t.Fatalf("memory should be cleared, got %q", got)
+				}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newHistoryFixture(t))
+		})
+	}
+}
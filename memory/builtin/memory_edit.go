@@ -0,0 +1,317 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// 新建记忆文档时使用的顶级标题，与 DefaultUserMemoryPrompt 约定一致
+	userMemoryDocumentTitle = "# 用户记忆"
+	// 未指定分类时写入的章节
+	defaultUserMemoryFactSection = "基础信息"
+)
+
+// 以下方法供 Agent 的记忆编辑工具使用：立即修改指定用户的记忆，不经过 analyzer 的防抖队列。
+// 所有对记忆文档的修改都以 tool 来源记录版本历史，可通过 RestoreUserMemoryRevision 回滚。
+
+// RememberUserMemoryFact 把一条事实以列表项追加到记忆文档的 section 章节下，章节不存在时新建。
+// section 为空时写入“基础信息”；文档中已有相同事实时不重复写入，added 为 false。
+// 存储未实现版本历史时 revision 为空。
+func (m *MemoryManager) RememberUserMemoryFact(ctx context.Context, userID, section, fact string) (revision *UserMemoryRevision, added bool, err error) {
+	fact = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(fact), "- "))
+	if fact == "" {
+		return nil, false, errors.New("记忆内容不能为空")
+	}
+	section = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(section), "#"))
+	if section == "" {
+		section = defaultUserMemoryFactSection
+	}
+
+	return m.editUserMemory(ctx, userID, fmt.Sprintf("记住：%s", fact), func(doc string) (string, error) {
+		lines := splitDiffLines(doc)
+		item := "- " + fact
+		for _, line := range lines {
+			if strings.TrimSpace(line) == item {
+				return doc, nil
+			}
+		}
+		if len(lines) == 0 {
+			lines = []string{userMemoryDocumentTitle}
+		}
+
+		start, end := findUserMemorySection(lines, section)
+		if start < 0 {
+			lines = append(trimTrailingBlankLines(lines), "", "## "+section, item)
+			return joinUserMemoryLines(lines), nil
+		}
+		// 插在章节最后一条非空行之后，保留章节间的空行
+		insert := end
+		for insert > start+1 && strings.TrimSpace(lines[insert-1]) == "" {
+			insert--
+		}
+		lines = append(lines[:insert], append([]string{item}, lines[insert:]...)...)
+		return joinUserMemoryLines(lines), nil
+	})
+}
+
+// ReplaceUserMemoryText 把记忆文档中所有 oldText 替换为 newText，返回替换次数
+func (m *MemoryManager) ReplaceUserMemoryText(ctx context.Context, userID, oldText, newText string) (int, error) {
+	if strings.TrimSpace(oldText) == "" {
+		return 0, errors.New("待替换的内容不能为空")
+	}
+	count := 0
+	_, written, err := m.editUserMemory(ctx, userID, fmt.Sprintf("更正：%s -> %s", oldText, newText), func(doc string) (string, error) {
+		count = strings.Count(doc, oldText)
+		return strings.ReplaceAll(doc, oldText, newText), nil
+	})
+	if err != nil || !written {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ForgetUserMemoryText 删除记忆文档中包含 text 的所有行（不区分大小写，标题行除外），返回删除的行数
+func (m *MemoryManager) ForgetUserMemoryText(ctx context.Context, userID, text string) (int, error) {
+	needle := strings.ToLower(strings.TrimSpace(text))
+	if needle == "" {
+		return 0, errors.New("待删除的内容不能为空")
+	}
+	removed := 0
+	_, written, err := m.editUserMemory(ctx, userID, fmt.Sprintf("遗忘：%s", text), func(doc string) (string, error) {
+		// 版本冲突重试时 edit 会基于最新内容再次调用，计数需从零开始
+		removed = 0
+		lines := splitDiffLines(doc)
+		kept := make([]string, 0, len(lines))
+		for _, line := range lines {
+			if userMemoryHeadingLevel(line) == 0 && strings.Contains(strings.ToLower(line), needle) {
+				removed++
+				continue
+			}
+			kept = append(kept, line)
+		}
+		if removed == 0 {
+			return doc, nil
+		}
+		return joinUserMemoryLines(kept), nil
+	})
+	if err != nil || !written {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// ForgetUserMemorySection 删除记忆文档中的整个章节（含标题与其下所有内容），章节不存在时返回 false
+func (m *MemoryManager) ForgetUserMemorySection(ctx context.Context, userID, section string) (bool, error) {
+	section = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(section), "#"))
+	if section == "" {
+		return false, errors.New("章节名称不能为空")
+	}
+	found := false
+	_, written, err := m.editUserMemory(ctx, userID, fmt.Sprintf("删除章节：%s", section), func(doc string) (string, error) {
+		found = false
+		lines := splitDiffLines(doc)
+		start, end := findUserMemorySection(lines, section)
+		if start < 0 {
+			return doc, nil
+		}
+		found = true
+		lines = append(lines[:start], lines[end:]...)
+		return joinUserMemoryLines(trimTrailingBlankLines(lines)), nil
+	})
+	if err != nil || !written {
+		return false, err
+	}
+	return found, nil
+}
+
+// editUserMemory 在 userMemoryMu 保护下读取记忆文档、应用 edit 并写回，避免与 analyzer 的写入交错。
+// 写回时记忆已被其他进程修改则基于最新内容重新应用 edit，edit 可能被调用多次，闭包内的统计需在每次调用时重置。
+// edit 返回的内容与原文相同时不写入；结果为空时清空用户记忆（同样记录版本）。written 表示是否写入了存储。
+func (m *MemoryManager) editUserMemory(ctx context.Context, userID, reason string, edit func(doc string) (string, error)) (revision *UserMemoryRevision, written bool, err error) {
+	if userID == "" {
+		return nil, false, errors.New("用户ID不能为空")
+	}
+
+	m.userMemoryMu.Lock()
+	defer m.userMemoryMu.Unlock()
+
+	retries := normalizeUserMemoryHistoryConfig(m.config.UserMemoryHistory).MaxConflictRetries
+	for attempt := 0; ; attempt++ {
+		existing, err := m.storage.GetUserMemory(ctx, userID)
+		if err != nil {
+			return nil, false, fmt.Errorf("获取现有用户记忆失败: %w", err)
+		}
+		doc := ""
+		var version int64
+		if existing != nil {
+			doc = existing.Memory
+			version = existing.Version
+		}
+		next, err := edit(doc)
+		if err != nil {
+			return nil, false, err
+		}
+		if next == doc {
+			return nil, false, nil
+		}
+		var revision *UserMemoryRevision
+		if strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(next), userMemoryDocumentTitle)) == "" {
+			revision, err = m.deleteUserMemoryLocked(ctx, existing, UserMemorySourceTool, reason)
+		} else {
+			revision, err = m.updateUserMemoryLocked(ctx, userID, UserMemorySourceTool, &UserMemoryUpdate{
+				Memory:      next,
+				Source:      UserMemorySourceTool,
+				Force:       true,
+				Reason:      reason,
+				BaseVersion: &version,
+			})
+		}
+		if errors.Is(err, ErrUserMemoryConflict) && attempt < retries {
+			continue
+		}
+		return revision, err == nil, err
+	}
+}
+
+// CorrectUserMemoryEvent 用 corrected 中的非零字段更正事件：写入一条新事件（Sources 指向原事件），
+// 原事件在存储支持时标记为被取代，否则直接删除。返回新事件。
+func (m *MemoryManager) CorrectUserMemoryEvent(ctx context.Context, userID, eventID string, corrected *UserMemoryEvent) (*UserMemoryEvent, error) {
+	store := m.userMemoryEventStorage()
+	if store == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 UserMemoryEventStorage")
+	}
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+	if eventID == "" {
+		return nil, errors.New("事件ID不能为空")
+	}
+	if corrected == nil {
+		return nil, errors.New("更正内容不能为空")
+	}
+
+	found, err := store.SearchUserMemoryEvents(ctx, &UserMemoryEventQuery{UserID: userID, IDs: []string{eventID}, Limit: 1})
+	if err != nil {
+		return nil, fmt.Errorf("查询用户记忆事件失败: %w", err)
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUserMemoryEventNotFound, eventID)
+	}
+	old := found[0]
+
+	next := &UserMemoryEvent{
+		UserID:      userID,
+		Type:        old.Type,
+		EventDate:   old.EventDate,
+		Keywords:    old.Keywords,
+		Summary:     old.Summary,
+		Importance:  old.Importance,
+		AccessCount: old.AccessCount,
+		Sources:     []string{old.ID},
+	}
+	if corrected.Type != "" {
+		next.Type = corrected.Type
+	}
+	if !corrected.EventDate.IsZero() {
+		next.EventDate = corrected.EventDate
+	}
+	if len(corrected.Keywords) > 0 {
+		next.Keywords = corrected.Keywords
+	}
+	if strings.TrimSpace(corrected.Summary) != "" {
+		next.Summary = strings.TrimSpace(corrected.Summary)
+	}
+	if corrected.Importance != 0 {
+		next.Importance = corrected.Importance
+	}
+	if err := store.SaveUserMemoryEvent(ctx, next); err != nil {
+		return nil, err
+	}
+	m.notifyChange(ctx, ChangeUserMemoryEventCreated, next)
+
+	if consolidation, ok := m.storage.(UserMemoryEventConsolidationStorage); ok {
+		err = consolidation.SupersedeUserMemoryEvents(ctx, userID, next.ID, []string{old.ID})
+	} else {
+		err = store.DeleteUserMemoryEvent(ctx, userID, old.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("替换原事件失败: %w", err)
+	}
+	return next, nil
+}
+
+// DeleteUserMemoryEvent 删除用户的指定事件，事件不存在时返回 ErrUserMemoryEventNotFound。
+// 底层 storage 未实现事件接口时返回错误。
+func (m *MemoryManager) DeleteUserMemoryEvent(ctx context.Context, userID, eventID string) error {
+	store := m.userMemoryEventStorage()
+	if store == nil {
+		return fmt.Errorf("当前 storage 未实现 UserMemoryEventStorage")
+	}
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	if eventID == "" {
+		return errors.New("事件ID不能为空")
+	}
+	found, err := store.SearchUserMemoryEvents(ctx, &UserMemoryEventQuery{UserID: userID, IDs: []string{eventID}, IncludeSuperseded: true, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("查询用户记忆事件失败: %w", err)
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("%w: %s", ErrUserMemoryEventNotFound, eventID)
+	}
+	return store.DeleteUserMemoryEvent(ctx, userID, eventID)
+}
+
+// findUserMemorySection 返回章节标题所在行与章节结束行（不含），未找到时返回 -1, -1。
+// 标题名以 section 开头即视为匹配，例如“核心约定”可匹配“核心约定（最高优先级）”。
+func findUserMemorySection(lines []string, section string) (int, int) {
+	for i, line := range lines {
+		level := userMemoryHeadingLevel(line)
+		if level == 0 {
+			continue
+		}
+		title := strings.TrimSpace(line[level:])
+		if !strings.HasPrefix(title, section) {
+			continue
+		}
+		end := len(lines)
+		for j := i + 1; j < len(lines); j++ {
+			if l := userMemoryHeadingLevel(lines[j]); l > 0 && l <= level {
+				end = j
+				break
+			}
+		}
+		return i, end
+	}
+	return -1, -1
+}
+
+// userMemoryHeadingLevel 返回 Markdown 标题级别，非标题行返回 0
+func userMemoryHeadingLevel(line string) int {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level == len(line) || line[level] != ' ' {
+		return 0
+	}
+	return level
+}
+
+func trimTrailingBlankLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func joinUserMemoryLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,45 @@
+package builtin
+
+import "context"
+
+// 命名空间用于隔离不同 Agent 的用户记忆：同一用户在不同命名空间下拥有独立的记忆文档、版本历史和事件流。
+// 空字符串为默认命名空间，与引入命名空间之前写入的数据兼容。
+// 会话消息与摘要按 sessionID 区分，不受命名空间影响。
+
+type namespaceContextKey struct{}
+
+// WithNamespace 返回携带记忆命名空间的 ctx，之后对用户记忆、版本历史和事件的读写都限定在该命名空间内
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	if namespace == "" && NamespaceFromContext(ctx) == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, namespaceContextKey{}, namespace)
+}
+
+// NamespaceFromContext 返回 ctx 中的记忆命名空间，未设置时返回空字符串（默认命名空间）
+func NamespaceFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	namespace, _ := ctx.Value(namespaceContextKey{}).(string)
+	return namespace
+}
+
+// UserMemoryOwner 用户记忆的归属：命名空间 + 用户 ID
+type UserMemoryOwner struct {
+	Namespace string `json:"namespace,omitempty"`
+	UserID    string `json:"userId"`
+}
+
+// Key 返回可用作 map 键的复合键，默认命名空间下即为 UserID
+func (o UserMemoryOwner) Key() string {
+	if o.Namespace == "" {
+		return o.UserID
+	}
+	return o.Namespace + "\x00" + o.UserID
+}
+
+// OwnerFromContext 返回 ctx 命名空间下 userID 对应的记忆归属
+func OwnerFromContext(ctx context.Context, userID string) UserMemoryOwner {
+	return UserMemoryOwner{Namespace: NamespaceFromContext(ctx), UserID: userID}
+}
@@ -151,13 +151,18 @@ const (
     "type": "milestone" | "event",
     "date": "YYYY-MM-DD",
     "summary": "精简的事实陈述，不超过80个汉字",
-    "keywords": ["关键词1", "关键词2", "..."]
+    "keywords": ["关键词1", "关键词2", "..."],
+    "importance": 1 ~ 5
   }
 - type 选择：
   - "milestone"：业务流程/任务/工作进度的里程碑（如"删除主体完成"、"账号扩容完成"）
   - "event"：通用事件（如约定、会议、退款、问题排查结论、待办）
 - date 必须是绝对日期（YYYY-MM-DD）。如果对话给出相对时间，请基于 user 消息里的当前时间上下文换算成绝对日期
 - keywords 是搜索用的索引词：覆盖事件涉及的产品/账号ID/客户/编号/手机号/关键动词，3~8 个为宜
+- importance 是该事件对长期服务用户的重要程度，用于决定旧事件是否仍值得常驻上下文：
+  - 5：长期有效的关键事实或承诺（如合同签订、账号迁移、长期约定的交付日期）
+  - 3：一般业务进展（默认）
+  - 1：一次性、很快过时的琐事
 - summary 必须聚焦客观事实，绝不要复述对话剧情；**禁止 Emoji**
 
 ## 现有记忆
@@ -176,7 +181,7 @@ const (
   "op":"update",
   "memory":"完整的短文档 Markdown，包含所有现有+新增的核心约定/基础信息",
   "events":[
-    {"type":"milestone","date":"2026-05-15","summary":"...","keywords":["...","..."]}
+    {"type":"milestone","date":"2026-05-15","summary":"...","keywords":["...","..."],"importance":3}
   ]
 }
 
@@ -528,4 +533,84 @@ const (
 
 ### 最终结论/当前状态
 - 已确认退款失败原因，后续需补足余额后重试。`
+
+	// DefaultEventConsolidationPrompt 事件整理任务使用的 prompt：
+	// 对一组相似事件判断是否需要合并重复项或用新事实取代过时事实。
+	DefaultEventConsolidationPrompt = `# 用户记忆事件整理任务
+
+## 目标
+你会收到同一用户的一组相似事件（按关键词或语义聚类得到），每条形如：
+E1 [日期][类型] 事件内容（关键词: ...）
+
+请判断其中是否存在：
+1. **重复事件**：描述同一件事（措辞不同、信息互补），应合并为一条
+2. **过时事件**：同一事实的新旧版本互相矛盾（如先"搬到上海"，后"搬到北京"），旧的应被新的取代
+
+## 输出格式
+严格输出 JSON，不要包含任何其他文字或代码块标记。
+
+无需整理时：
+{"op":"noop"}
+
+需要整理时：
+{"op":"update","actions":[
+  {"op":"merge","ids":["E1","E2"],"type":"event","date":"YYYY-MM-DD","summary":"合并后的事件内容","keywords":["关键词"]},
+  {"op":"supersede","keep":"E4","ids":["E3"]}
+]}
+
+- merge：ids 中的事件合并为一条新事件，summary 需保留所有来源中的有效信息；type 取 milestone 或 event；date 取事件实际发生日期
+- supersede：ids 中的事件被 keep 取代，keep 必须是更新、更准确的那条
+- 每个事件 ID 最多出现在一个 action 中；只能使用输入中给出的 ID
+
+## 处理规则
+1. 只是主题相近但属于不同事实的事件不要整理（如两次不同日期的体检、两个不同订单）
+2. 拿不准时输出 noop，宁可保留重复也不要误删事实
+3. summary 保持中文短句、事实化，不要写"用户说过""用户提到"这类前缀，禁止使用 Emoji
+4. 不要编造输入中没有的信息`
+
+	// DefaultSessionTitlePrompt 会话标题生成任务使用的 prompt：根据会话开头的对话生成简短标题。
+	DefaultSessionTitlePrompt = `# 会话标题生成任务
+
+根据下面的对话内容，为这段会话生成一个简短的标题，用于会话列表展示。
+
+## 要求
+1. 概括用户的核心问题或意图，不要复述助手的回答
+2. 使用与用户相同的语言；中文不超过 %d 个字
+3. 只输出标题本身，不要加引号、书名号、句号或"标题："之类的前缀，禁止使用 Emoji`
+
+	// DefaultSessionAbstractPrompt 分层摘要模式下，把分段摘要汇总进会话总摘要使用的 prompt。
+	DefaultSessionAbstractPrompt = `# 会话总摘要汇总任务
+
+## 目标
+长会话按消息分段生成了多份「分段摘要」。你需要把「新增分段摘要」汇总进「现有总摘要」，
+得到一份覆盖整个会话的总摘要。总摘要会作为会话的历史背景注入后续对话，与当前对话相关的分段摘要会另外按需补充。
+
+## 汇总原则
+1. 保留贯穿整个会话的主线：核心诉求、关键实体与事实、当前状态、遗留待办
+2. 只在分段中出现一次、与主线无关的细节可以省略，分段摘要本身会被保留并按需检索
+3. 后面的分段推翻前面的事实时，以后面的为准，不要同时保留冲突信息
+4. 不要按分段顺序罗列，要合并成当前状态页
+
+## 输出约束
+- 直接输出完整的新版总摘要 Markdown，沿用「核心诉求/主题」「关键实体与事实」「最终结论/当前状态」「遗留待办」结构，空小节可省略
+- 采用客观事实陈述，禁止使用 Emoji，不要输出 JSON、代码块或解释文字
+- 不超过 **400字**`
+
+	// DefaultUserDigestPrompt 跨会话用户周期摘要使用的 prompt：汇总一个周期内用户的全部会话。
+	DefaultUserDigestPrompt = `# 用户周期摘要任务
+
+## 目标
+你会收到同一用户在一个时间周期内（如一周）的多个会话材料，每个会话包含标题、会话摘要或对话片段。
+请生成一份跨会话的周期摘要，帮助之后的对话快速了解这段时间用户在做什么。
+
+## 要求
+1. 按主题归并，而不是按会话逐个复述；同一件事分散在多个会话时合并描述
+2. 突出进展与结论：完成了什么、决定了什么、卡在哪里
+3. 单独列出仍未完成的待办与约定的时间点
+4. 只写材料中出现的信息，不要编造；寒暄与一次性的知识问答可以省略
+
+## 输出约束
+- 输出 Markdown，使用「本期主题」「进展与结论」「未完成事项」三个小节，空小节可省略
+- 采用客观事实陈述，禁止使用 Emoji，不要输出 JSON、代码块或解释文字
+- 不超过 **500字**`
 )
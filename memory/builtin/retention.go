@@ -0,0 +1,366 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/memoryevent"
+	"github.com/gookit/slog"
+)
+
+// RetentionConfig 保留策略配置。nil 表示只执行 CleanupConfig 中的固定清理；
+// 设置后定期清理会按策略删除消息与事件，需存储实现 RetentionStorage。
+type RetentionConfig struct {
+	// Policy 决定每个用户的保留规则，必填
+	Policy RetentionPolicy `json:"-"`
+	// Archiver 删除前把数据写入冷存储，可选；归档失败时不会删除对应数据
+	Archiver Archiver `json:"-"`
+	// DryRun 为 true 时定期清理只生成报告（CleanupReport.Retention），不删除任何数据
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// RetentionRule 单个用户的保留规则，零值字段表示不按该条件清理
+type RetentionRule struct {
+	// MessageMaxAge 消息保留时长，早于该时长的消息会被删除
+	MessageMaxAge time.Duration `json:"messageMaxAge,omitempty"`
+	// MaxMessagesPerSession 每个会话最多保留的最新消息数
+	MaxMessagesPerSession int `json:"maxMessagesPerSession,omitempty"`
+	// SummarizeBeforePrune 删除前确保会话摘要已覆盖待删除的消息：未覆盖时先同步更新摘要，
+	// 摘要仍未覆盖的消息本轮保留
+	SummarizeBeforePrune bool `json:"summarizeBeforePrune,omitempty"`
+
+	// EventMaxAge 事件保留时长，按 EventDate 判断
+	EventMaxAge time.Duration `json:"eventMaxAge,omitempty"`
+	// KeepEventImportance 重要程度不低于该值的事件不按 EventMaxAge 删除，0 表示不保护
+	KeepEventImportance int `json:"keepEventImportance,omitempty"`
+	// SupersededEventMaxAge 已被取代的事件保留时长，按 SupersededAt 判断
+	SupersededEventMaxAge time.Duration `json:"supersededEventMaxAge,omitempty"`
+}
+
+func normalizeRetentionConfig(cfg *RetentionConfig) *RetentionConfig {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.Policy == nil {
+		slog.Warnf("memory: Retention.Policy 为空，保留策略不会执行")
+		return nil
+	}
+	return cfg
+}
+
+func (r *RetentionRule) prunesMessages() bool {
+	return r != nil && (r.MessageMaxAge > 0 || r.MaxMessagesPerSession > 0)
+}
+
+func (r *RetentionRule) prunesEvents() bool {
+	return r != nil && (r.EventMaxAge > 0 || r.SupersededEventMaxAge > 0)
+}
+
+// RetentionPolicy 保留策略
+type RetentionPolicy interface {
+	// RuleFor 返回用户的保留规则，nil 表示不清理该用户的数据。
+	// 处理事件时 ctx 携带事件所属的命名空间，可通过 NamespaceFromContext 区分。
+	RuleFor(ctx context.Context, userID string) *RetentionRule
+}
+
+// RetentionPolicyFunc 函数形式的保留策略
+type RetentionPolicyFunc func(ctx context.Context, userID string) *RetentionRule
+
+// RuleFor 实现 RetentionPolicy
+func (f RetentionPolicyFunc) RuleFor(ctx context.Context, userID string) *RetentionRule {
+	return f(ctx, userID)
+}
+
+// StaticRetentionPolicy 对所有用户使用同一规则
+func StaticRetentionPolicy(rule RetentionRule) RetentionPolicy {
+	return RetentionPolicyFunc(func(context.Context, string) *RetentionRule {
+		return &rule
+	})
+}
+
+// TieredRetentionPolicy 按用户等级（如免费版、付费版）选择规则
+type TieredRetentionPolicy struct {
+	// Tier 返回用户所属等级
+	Tier func(ctx context.Context, userID string) string
+	// Rules 各等级的规则
+	Rules map[string]*RetentionRule
+	// Default 等级未配置规则时使用，nil 表示不清理
+	Default *RetentionRule
+}
+
+// RuleFor 实现 RetentionPolicy
+func (p *TieredRetentionPolicy) RuleFor(ctx context.Context, userID string) *RetentionRule {
+	if p.Tier != nil {
+		if rule, ok := p.Rules[p.Tier(ctx, userID)]; ok {
+			return rule
+		}
+	}
+	return p.Default
+}
+
+// RetentionReport 一轮保留策略的执行结果，DryRun 时为将要删除的数据
+type RetentionReport struct {
+	DryRun     bool      `json:"dryRun"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	// 删除（或将要删除）的消息与事件总数
+	MessagesDeleted int `json:"messagesDeleted"`
+	EventsDeleted   int `json:"eventsDeleted"`
+	// 写入归档的消息与事件总数
+	MessagesArchived int `json:"messagesArchived"`
+	EventsArchived   int `json:"eventsArchived"`
+	// 按会话列出的消息清理明细
+	Sessions []*SessionRetention `json:"sessions,omitempty"`
+	// 按记忆归属列出的事件清理明细
+	Events []*EventRetention `json:"events,omitempty"`
+	// 执行过程中的错误，单个会话或用户失败不会中断整轮
+	Errors []error `json:"-"`
+}
+
+// SessionRetention 单个会话的消息清理明细
+type SessionRetention struct {
+	SessionID string `json:"sessionId"`
+	UserID    string `json:"userId"`
+	// 删除（或将要删除）的消息数
+	Messages int `json:"messages"`
+	// 被删除消息的时间范围
+	OldestAt time.Time `json:"oldestAt"`
+	NewestAt time.Time `json:"newestAt"`
+	// 因摘要尚未覆盖而保留的消息数
+	Held int `json:"held,omitempty"`
+	// DryRun 时为 true 表示删除前需要先更新会话摘要
+	NeedsSummary bool `json:"needsSummary,omitempty"`
+}
+
+// EventRetention 单个记忆归属的事件清理明细
+type EventRetention struct {
+	Namespace string   `json:"namespace,omitempty"`
+	UserID    string   `json:"userId"`
+	EventIDs  []string `json:"eventIds"`
+}
+
+func (m *MemoryManager) retentionStorage() RetentionStorage {
+	s, _ := m.storage.(RetentionStorage)
+	return s
+}
+
+// ApplyRetention 按 MemoryConfig.Retention 的策略清理消息与事件并返回报告；dryRun 为 true 时只统计不删除。
+// 事件清理需存储同时实现 UserMemoryEventStorage 与 UserMemoryEventConsolidationStorage，否则跳过。
+func (m *MemoryManager) ApplyRetention(ctx context.Context, dryRun bool) (*RetentionReport, error) {
+	cfg := m.config.Retention
+	if cfg == nil || cfg.Policy == nil {
+		return nil, errors.New("未配置保留策略")
+	}
+	store := m.retentionStorage()
+	if store == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 RetentionStorage")
+	}
+
+	report := &RetentionReport{DryRun: dryRun, StartedAt: time.Now()}
+	sessions, err := store.ListMessageSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取会话列表失败: %w", err)
+	}
+	for _, session := range sessions {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		rule := cfg.Policy.RuleFor(ctx, session.UserID)
+		if !rule.prunesMessages() {
+			continue
+		}
+		result, err := m.applySessionRetention(ctx, store, cfg.Archiver, session, rule, report.StartedAt, dryRun)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("会话 %s（用户 %s）: %w", session.SessionID, session.UserID, err))
+		}
+		if result == nil || (result.Messages == 0 && result.Held == 0) {
+			continue
+		}
+		report.Sessions = append(report.Sessions, result)
+		report.MessagesDeleted += result.Messages
+		if cfg.Archiver != nil && !dryRun {
+			report.MessagesArchived += result.Messages
+		}
+	}
+
+	m.applyEventRetention(ctx, cfg, report, dryRun)
+
+	report.FinishedAt = time.Now()
+	if !dryRun && (report.MessagesDeleted > 0 || report.EventsDeleted > 0) {
+		slog.Infof("保留策略清理了 %d 条消息、%d 条事件", report.MessagesDeleted, report.EventsDeleted)
+	}
+	return report, nil
+}
+
+// applySessionRetention 清理单个会话的消息，返回的明细在出错时也反映已完成的部分
+func (m *MemoryManager) applySessionRetention(ctx context.Context, store RetentionStorage, archiver Archiver, session *Session, rule *RetentionRule, now time.Time, dryRun bool) (*SessionRetention, error) {
+	messages, err := m.storage.GetMessages(ctx, session.SessionID, session.UserID, 0)
+	if err != nil {
+		return nil, err
+	}
+	// 消息按时间正序，待删除的消息总是最前面的一段
+	n := 0
+	if rule.MessageMaxAge > 0 {
+		cutoff := now.Add(-rule.MessageMaxAge)
+		for n < len(messages) && messages[n].CreatedAt.Before(cutoff) {
+			n++
+		}
+	}
+	if rule.MaxMessagesPerSession > 0 && len(messages)-rule.MaxMessagesPerSession > n {
+		n = len(messages) - rule.MaxMessagesPerSession
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	candidates := messages[:n]
+
+	result := &SessionRetention{SessionID: session.SessionID, UserID: session.UserID}
+	if rule.SummarizeBeforePrune {
+		summary, err := m.GetSessionSummary(ctx, session.SessionID, session.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("获取会话摘要失败: %w", err)
+		}
+		if summary == nil || messageAfterSummary(candidates[n-1], summary) {
+			if dryRun {
+				result.NeedsSummary = true
+			} else {
+				updated, err := m.updateSessionSummary(ctx, session.UserID, session.SessionID)
+				if err != nil {
+					return nil, fmt.Errorf("删除前更新会话摘要失败: %w", err)
+				}
+				if updated {
+					m.markSummaryUpdated(ctx, session.UserID, session.SessionID)
+				}
+				if summary, err = m.GetSessionSummary(ctx, session.SessionID, session.UserID); err != nil {
+					return nil, fmt.Errorf("获取会话摘要失败: %w", err)
+				}
+				covered := 0
+				for covered < n && summary != nil && !messageAfterSummary(candidates[covered], summary) {
+					covered++
+				}
+				result.Held = n - covered
+				candidates = candidates[:covered]
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return result, nil
+	}
+
+	result.Messages = len(candidates)
+	result.OldestAt = candidates[0].CreatedAt
+	result.NewestAt = candidates[len(candidates)-1].CreatedAt
+	if dryRun {
+		return result, nil
+	}
+
+	if archiver != nil {
+		batch := &ArchiveBatch{
+			UserID:     session.UserID,
+			SessionID:  session.SessionID,
+			Messages:   candidates,
+			ArchivedAt: now,
+		}
+		if err := archiver.Archive(ctx, batch); err != nil {
+			result.Messages = 0
+			return result, fmt.Errorf("归档消息失败: %w", err)
+		}
+	}
+	ids := make([]string, len(candidates))
+	for i, msg := range candidates {
+		ids[i] = msg.ID
+	}
+	deleted, err := store.DeleteMessagesByIDs(ctx, session.SessionID, session.UserID, ids)
+	result.Messages = deleted
+	if deleted > 0 {
+		m.invalidateSearchIndex(session.SessionID, session.UserID)
+	}
+	if err != nil {
+		return result, fmt.Errorf("删除消息失败: %w", err)
+	}
+	return result, nil
+}
+
+// applyEventRetention 按规则清理各记忆归属的事件，结果写入 report
+func (m *MemoryManager) applyEventRetention(ctx context.Context, cfg *RetentionConfig, report *RetentionReport, dryRun bool) {
+	eventStore, ok := m.storage.(UserMemoryEventStorage)
+	if !ok {
+		return
+	}
+	ownerStore, ok := m.storage.(UserMemoryEventConsolidationStorage)
+	if !ok {
+		return
+	}
+	owners, err := ownerStore.ListUserMemoryEventOwners(ctx, time.Time{})
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("获取事件归属失败: %w", err))
+		return
+	}
+
+	for _, owner := range owners {
+		if ctx.Err() != nil {
+			report.Errors = append(report.Errors, ctx.Err())
+			return
+		}
+		ownerCtx := WithNamespace(ctx, owner.Namespace)
+		rule := cfg.Policy.RuleFor(ownerCtx, owner.UserID)
+		if !rule.prunesEvents() {
+			continue
+		}
+		events, err := eventStore.SearchUserMemoryEvents(ownerCtx, &UserMemoryEventQuery{UserID: owner.UserID, IncludeSuperseded: true})
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("获取用户 %s 的事件失败: %w", owner.UserID, err))
+			continue
+		}
+		expired := expiredEvents(events, rule, report.StartedAt)
+		if len(expired) == 0 {
+			continue
+		}
+
+		result := &EventRetention{Namespace: owner.Namespace, UserID: owner.UserID}
+		if !dryRun && cfg.Archiver != nil {
+			batch := &ArchiveBatch{
+				UserID:     owner.UserID,
+				Namespace:  owner.Namespace,
+				Events:     expired,
+				ArchivedAt: report.StartedAt,
+			}
+			if err := cfg.Archiver.Archive(ownerCtx, batch); err != nil {
+				report.Errors = append(report.Errors, fmt.Errorf("归档用户 %s 的事件失败: %w", owner.UserID, err))
+				continue
+			}
+			report.EventsArchived += len(expired)
+		}
+		for _, evt := range expired {
+			if !dryRun {
+				if err := eventStore.DeleteUserMemoryEvent(ownerCtx, owner.UserID, evt.ID); err != nil {
+					report.Errors = append(report.Errors, fmt.Errorf("删除事件 %s 失败: %w", evt.ID, err))
+					continue
+				}
+			}
+			result.EventIDs = append(result.EventIDs, evt.ID)
+		}
+		if len(result.EventIDs) > 0 {
+			report.Events = append(report.Events, result)
+			report.EventsDeleted += len(result.EventIDs)
+		}
+	}
+}
+
+// expiredEvents 返回按规则应删除的事件
+func expiredEvents(events []*UserMemoryEvent, rule *RetentionRule, now time.Time) []*UserMemoryEvent {
+	var expired []*UserMemoryEvent
+	for _, evt := range events {
+		switch {
+		case rule.SupersededEventMaxAge > 0 && evt.Superseded() && evt.SupersededAt != nil &&
+			evt.SupersededAt.Before(now.Add(-rule.SupersededEventMaxAge)):
+			expired = append(expired, evt)
+		case rule.EventMaxAge > 0 && evt.EventDate.Before(now.Add(-rule.EventMaxAge)) &&
+			(rule.KeepEventImportance <= 0 || memoryevent.NormalizeImportance(evt.Importance) < rule.KeepEventImportance):
+			expired = append(expired, evt)
+		}
+	}
+	return expired
+}
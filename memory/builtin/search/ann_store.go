@@ -0,0 +1,497 @@
+package search
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gookit/slog"
+)
+
+const (
+	defaultANNOverFetch       = 4
+	defaultANNPersistInterval = 5 * time.Minute
+)
+
+// ANNVectorStore 在 GormVectorStore 之上叠加进程内 HNSW 近似索引。
+// 每个用户的索引在首次检索时从数据库懒加载，Upsert 时增量更新；
+// 检索先在图中取 limit×overFetch 个候选，再按会话、角色、时间过滤，不足时扩大候选数重试。
+// 快照只是预热缓存：从快照恢复的用户在首次检索时与数据库中的向量 id 核对，补齐缺失、移除已删除的向量。
+type ANNVectorStore struct {
+	base            *GormVectorStore
+	params          HNSWParams
+	overFetch       int
+	persistPath     string
+	persistInterval time.Duration
+
+	// loadMu 串行化索引加载，并保证加载期间写入的向量不会丢失
+	loadMu sync.Mutex
+	mu     sync.RWMutex
+	users  map[string]*annUserIndex
+	// restored 从快照恢复、尚未与数据库核对的用户
+	restored map[string]bool
+	// dirty 上次写回快照后索引是否有变化
+	dirty atomic.Bool
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+type annUserIndex struct {
+	mu     sync.RWMutex
+	params HNSWParams
+	graph  *hnswGraph
+	meta   map[string]annMeta
+}
+
+type annMeta struct {
+	SessionID string
+	Role      string
+	CreatedAt time.Time
+}
+
+// ANNOption ANNVectorStore 的可选配置
+type ANNOption func(*ANNVectorStore)
+
+// WithHNSWParams 指定 HNSW 图参数
+func WithHNSWParams(params HNSWParams) ANNOption {
+	return func(s *ANNVectorStore) {
+		s.params = params.normalize()
+	}
+}
+
+// WithANNOverFetch 指定过滤前的候选放大倍数，默认 4
+func WithANNOverFetch(n int) ANNOption {
+	return func(s *ANNVectorStore) {
+		if n > 0 {
+			s.overFetch = n
+		}
+	}
+}
+
+// WithANNPersistPath 指定索引快照文件。创建时若文件存在则从中恢复，定期及 Close 时写回
+func WithANNPersistPath(path string) ANNOption {
+	return func(s *ANNVectorStore) {
+		s.persistPath = strings.TrimSpace(path)
+	}
+}
+
+// WithANNPersistInterval 指定快照定期写回间隔，默认 5 分钟；<=0 时只在 Close 时写回
+func WithANNPersistInterval(interval time.Duration) ANNOption {
+	return func(s *ANNVectorStore) {
+		s.persistInterval = interval
+	}
+}
+
+// NewANNVectorStore 创建带近似索引的向量存储
+func NewANNVectorStore(base *GormVectorStore, opts ...ANNOption) (*ANNVectorStore, error) {
+	if base == nil {
+		return nil, errors.New("gorm vector store is required")
+	}
+	s := &ANNVectorStore{
+		base:            base,
+		params:          HNSWParams{}.normalize(),
+		overFetch:       defaultANNOverFetch,
+		persistInterval: defaultANNPersistInterval,
+		users:           make(map[string]*annUserIndex),
+		restored:        make(map[string]bool),
+		stop:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	if s.persistPath != "" {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+		if s.persistInterval > 0 {
+			s.wg.Add(1)
+			go s.persistLoop()
+		}
+	}
+	return s, nil
+}
+
+func (s *ANNVectorStore) Upsert(ctx context.Context, msg *Message, vector []float64) error {
+	if s == nil {
+		return errors.New("ann vector store is nil")
+	}
+	if err := s.base.Upsert(ctx, msg, vector); err != nil {
+		return err
+	}
+
+	s.loadMu.Lock()
+	s.mu.RLock()
+	idx := s.users[msg.UserID]
+	s.mu.RUnlock()
+	s.loadMu.Unlock()
+	if idx != nil {
+		idx.add(msg, vector)
+		s.dirty.Store(true)
+	}
+	return nil
+}
+
+func (s *ANNVectorStore) Search(ctx context.Context, q *SearchQuery, vector []float64, limit int) ([]*SearchHit, error) {
+	if s == nil {
+		return nil, errors.New("ann vector store is nil")
+	}
+	if q == nil {
+		return nil, errors.New("search query is nil")
+	}
+	if len(vector) == 0 {
+		return nil, errors.New("query vector is empty")
+	}
+	if limit <= 0 {
+		limit = 5
+	}
+
+	idx, err := s.userIndex(ctx, q.UserID)
+	if err != nil {
+		return nil, err
+	}
+	candidates, ok := idx.search(q, vector, limit, s.overFetch)
+	if !ok {
+		// 查询向量与索引维度不一致（如更换了 embedding 模型），退回逐条计算
+		return s.base.Search(ctx, q, vector, limit)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		ids = append(ids, c.ID)
+	}
+	msgs, err := s.base.fetchMessages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]*SearchHit, 0, len(candidates))
+	for _, c := range candidates {
+		// 数据库中已删除的消息直接跳过，下次失效重建时从索引中移除
+		if msg := msgs[c.ID]; msg != nil {
+			hits = append(hits, &SearchHit{Message: msg, Score: c.Score})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score == hits[j].Score {
+			return hits[i].Message.CreatedAt.After(hits[j].Message.CreatedAt)
+		}
+		return hits[i].Score > hits[j].Score
+	})
+	return hits, nil
+}
+
+// Invalidate 丢弃用户的内存索引，下次检索时重新加载。userID 为空时丢弃全部
+func (s *ANNVectorStore) Invalidate(_, userID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty.Store(true)
+	if userID == "" {
+		s.users = make(map[string]*annUserIndex)
+		s.restored = make(map[string]bool)
+		return
+	}
+	delete(s.users, userID)
+	delete(s.restored, userID)
+}
+
+// Close 配置了快照文件时停止定期写回并写回索引快照，可重复调用
+func (s *ANNVectorStore) Close() error {
+	if s == nil || s.persistPath == "" {
+		return nil
+	}
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.wg.Wait()
+		err = s.save()
+	})
+	return err
+}
+
+func (s *ANNVectorStore) persistLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.persistInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if !s.dirty.Load() {
+				continue
+			}
+			if err := s.save(); err != nil {
+				slog.Warnf("定期写回向量索引快照失败: %v", err)
+			}
+		}
+	}
+}
+
+func (s *ANNVectorStore) userIndex(ctx context.Context, userID string) (*annUserIndex, error) {
+	s.mu.RLock()
+	idx := s.users[userID]
+	pending := s.restored[userID]
+	s.mu.RUnlock()
+	if idx != nil && !pending {
+		return idx, nil
+	}
+
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	s.mu.RLock()
+	idx = s.users[userID]
+	pending = s.restored[userID]
+	s.mu.RUnlock()
+	if idx != nil && !pending {
+		return idx, nil
+	}
+
+	if idx != nil {
+		if err := s.reconcile(ctx, userID, idx); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		delete(s.restored, userID)
+		s.mu.Unlock()
+		return idx, nil
+	}
+
+	idx = &annUserIndex{params: s.params, meta: make(map[string]annMeta)}
+	err := s.base.iterate(ctx, defaultMigrateBatchSize, userID, func(msg *Message, vector []float64) error {
+		idx.add(msg, vector)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("构建向量索引失败: %w", err)
+	}
+
+	s.mu.Lock()
+	s.users[userID] = idx
+	delete(s.restored, userID)
+	s.mu.Unlock()
+	s.dirty.Store(true)
+	return idx, nil
+}
+
+// reconcile 用数据库中该用户的向量 id 核对从快照恢复的索引：
+// 补齐快照之后写入的向量（崩溃前未写回、其他实例写入、较早消息补写向量），移除已删除消息的向量
+func (s *ANNVectorStore) reconcile(ctx context.Context, userID string, idx *annUserIndex) error {
+	ids, err := s.base.vectorIDs(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("核对向量索引失败: %w", err)
+	}
+
+	idx.mu.Lock()
+	current := make(map[string]bool, len(ids))
+	var missing []string
+	for _, id := range ids {
+		current[id] = true
+		if _, ok := idx.meta[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	removed := 0
+	for id := range idx.meta {
+		if !current[id] {
+			idx.graph.Remove(id)
+			delete(idx.meta, id)
+			removed++
+		}
+	}
+	idx.mu.Unlock()
+
+	err = s.base.fetchVectors(ctx, missing, func(msg *Message, vector []float64) error {
+		idx.add(msg, vector)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("补齐向量索引失败: %w", err)
+	}
+	if removed > 0 || len(missing) > 0 {
+		s.dirty.Store(true)
+	}
+	return nil
+}
+
+func (idx *annUserIndex) add(msg *Message, vector []float64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.graph == nil {
+		idx.graph = newHNSWGraph(idx.params, len(vector))
+	}
+	if idx.graph.Add(msg.ID, vector) {
+		idx.meta[msg.ID] = annMeta{SessionID: msg.SessionID, Role: msg.Role, CreatedAt: msg.CreatedAt}
+	}
+}
+
+// search 返回通过过滤的候选，维度与索引不一致时 ok 为 false
+func (idx *annUserIndex) search(q *SearchQuery, vector []float64, limit, overFetch int) ([]hnswResult, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if idx.graph == nil {
+		return nil, true
+	}
+	if len(vector) != idx.graph.dim {
+		return nil, false
+	}
+
+	total := idx.graph.Len()
+	for k := limit * overFetch; ; k *= 2 {
+		candidates := idx.graph.Search(vector, k)
+		out := make([]hnswResult, 0, limit)
+		for _, c := range candidates {
+			if idx.matches(c.ID, q) {
+				out = append(out, c)
+				if len(out) >= limit {
+					return out, true
+				}
+			}
+		}
+		// 图中的候选已全部取出仍不足 limit，说明过滤后确实没有更多结果
+		if k >= total || len(candidates) < k {
+			return out, true
+		}
+	}
+}
+
+func (idx *annUserIndex) matches(id string, q *SearchQuery) bool {
+	meta, ok := idx.meta[id]
+	if !ok {
+		return false
+	}
+	if q.SessionID != "" && meta.SessionID != q.SessionID {
+		return false
+	}
+	if role := strings.TrimSpace(q.Role); role != "" && meta.Role != role {
+		return false
+	}
+	if q.Since != nil && meta.CreatedAt.Before(*q.Since) {
+		return false
+	}
+	if q.Until != nil && meta.CreatedAt.After(*q.Until) {
+		return false
+	}
+	return true
+}
+
+// annSnapshot 快照保存完整的图结构，恢复时无需重新建图。
+// 向量体积较大，使用 gob 而非 JSON 编码。
+type annSnapshot struct {
+	Users map[string]*annUserSnapshot
+}
+
+type annUserSnapshot struct {
+	Graph *hnswSnapshot
+	Meta  map[string]annMeta
+}
+
+func (s *ANNVectorStore) save() error {
+	s.dirty.Store(false)
+	s.mu.RLock()
+	users := make(map[string]*annUserIndex, len(s.users))
+	for userID, idx := range s.users {
+		users[userID] = idx
+	}
+	s.mu.RUnlock()
+
+	// 编码期间持有各用户的读锁，避免并发 Upsert 修改图结构
+	snapshot := annSnapshot{Users: make(map[string]*annUserSnapshot, len(users))}
+	for userID, idx := range users {
+		idx.mu.RLock()
+		defer idx.mu.RUnlock()
+		if idx.graph != nil {
+			snapshot.Users[userID] = &annUserSnapshot{Graph: idx.graph.snapshot(), Meta: idx.meta}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.persistPath), 0o755); err != nil {
+		return fmt.Errorf("创建向量索引快照目录失败: %w", err)
+	}
+	tmp := s.persistPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("写入向量索引快照失败: %w", err)
+	}
+	err = gob.NewEncoder(f).Encode(&snapshot)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("写入向量索引快照失败: %w", err)
+	}
+	return os.Rename(tmp, s.persistPath)
+}
+
+func (s *ANNVectorStore) load() error {
+	f, err := os.Open(s.persistPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取向量索引快照失败: %w", err)
+	}
+	defer f.Close()
+
+	var snapshot annSnapshot
+	if err := gob.NewDecoder(f).Decode(&snapshot); err != nil {
+		return fmt.Errorf("解析向量索引快照失败: %w", err)
+	}
+	for userID, user := range snapshot.Users {
+		if user == nil || user.Graph == nil {
+			continue
+		}
+		meta := user.Meta
+		if meta == nil {
+			meta = make(map[string]annMeta)
+		}
+		s.users[userID] = &annUserIndex{params: s.params, graph: restoreHNSWGraph(user.Graph), meta: meta}
+		s.restored[userID] = true
+	}
+	return nil
+}
+
+// fetchMessages 按 id 批量读取消息（不含向量）
+func (s *GormVectorStore) fetchMessages(ctx context.Context, ids []string) (map[string]*Message, error) {
+	var records []gormVectorRecord
+	err := s.db.WithContext(ctx).
+		Table(s.tableName).
+		Select("id, session_id, user_id, role, content, parts, created_at").
+		Where("id IN ?", ids).
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("fetch messages: %w", err)
+	}
+
+	out := make(map[string]*Message, len(records))
+	for _, record := range records {
+		out[record.ID] = &Message{
+			ID:        record.ID,
+			SessionID: record.SessionID,
+			UserID:    record.UserID,
+			Role:      record.Role,
+			Content:   record.Content,
+			Parts:     decodeParts(record.Parts),
+			CreatedAt: record.CreatedAt,
+		}
+	}
+	return out, nil
+}
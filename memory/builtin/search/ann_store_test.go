@@ -0,0 +1,95 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestANNVectorStoreCatchesUpRestoredSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "messages.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.Table("messages").AutoMigrate(&gormVectorRecord{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	base, err := NewGormVectorStore(db, "messages")
+	if err != nil {
+		t.Fatalf("NewGormVectorStore: %v", err)
+	}
+	write := func(id string, vector []float64) {
+		t.Helper()
+		msg := &Message{ID: id, SessionID: "s1", UserID: "u1", Role: "user", Content: id, CreatedAt: time.Now()}
+		if err := db.Table("messages").Create(&gormVectorRecord{ID: msg.ID, SessionID: msg.SessionID, UserID: msg.UserID, Role: msg.Role, Content: msg.Content, CreatedAt: msg.CreatedAt}).Error; err != nil {
+			t.Fatalf("insert %s: %v", id, err)
+		}
+		if err := base.Upsert(ctx, msg, vector); err != nil {
+			t.Fatalf("upsert %s: %v", id, err)
+		}
+	}
+	top := func(store *ANNVectorStore, vector []float64) string {
+		t.Helper()
+		hits, err := store.Search(ctx, &SearchQuery{UserID: "u1"}, vector, 1)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		if len(hits) == 0 {
+			return ""
+		}
+		return hits[0].Message.ID
+	}
+
+	path := filepath.Join(dir, "ann.gob")
+	write("m1", []float64{1, 0})
+	write("m2", []float64{0, 1})
+	store, err := NewANNVectorStore(base, WithANNPersistPath(path), WithANNPersistInterval(0))
+	if err != nil {
+		t.Fatalf("NewANNVectorStore: %v", err)
+	}
+	if got := top(store, []float64{1, 0.1}); got != "m1" {
+		t.Fatalf("expected m1, got %q", got)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// 快照之后由其他实例写入的向量在恢复时补齐
+	write("m3", []float64{-1, 0})
+	restored, err := NewANNVectorStore(base, WithANNPersistPath(path), WithANNPersistInterval(0))
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if got := top(restored, []float64{-1, 0.1}); got != "m3" {
+		t.Fatalf("vector written after snapshot should be indexed, got %q", got)
+	}
+	_ = restored.Close()
+
+	// 快照之后删除的消息从索引移除，id 较小的消息补写的向量同样补齐
+	if err := db.Table("messages").Where("id = ?", "m3").Delete(&gormVectorRecord{}).Error; err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	write("m0", []float64{0, -1})
+	write("m4", []float64{0.7, 0.7})
+	restored, err = NewANNVectorStore(base, WithANNPersistPath(path), WithANNPersistInterval(0))
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	defer restored.Close()
+	if got := top(restored, []float64{0.1, -1}); got != "m0" {
+		t.Fatalf("late vector with a smaller id should be indexed, got %q", got)
+	}
+	if got := top(restored, []float64{-1, 0.1}); got == "m3" {
+		t.Fatal("deleted message should be removed from the index")
+	}
+	if indexed := len(restored.users["u1"].meta); indexed != 4 {
+		t.Fatalf("expected 4 indexed vectors, got %d", indexed)
+	}
+}
@@ -0,0 +1,281 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultBM25K1 = 1.2
+	defaultBM25B  = 0.75
+)
+
+// BM25Params BM25 打分参数，零值或越界时使用 k1=1.2、b=0.75
+type BM25Params struct {
+	K1 float64
+	// B 文档长度归一化系数，取值 (0, 1]
+	B float64
+}
+
+func (p BM25Params) normalize() BM25Params {
+	if p.K1 <= 0 {
+		p.K1 = defaultBM25K1
+	}
+	if p.B <= 0 || p.B > 1 {
+		p.B = defaultBM25B
+	}
+	return p
+}
+
+// BM25Document 参与打分的文档
+type BM25Document struct {
+	ID   string
+	Text string
+}
+
+// BM25Score 打分结果。
+// Score 为校准后的分数，取值 [0, 1)：原始 BM25 分数除以查询词在 tf→∞ 时可取得的上界，
+// 因此不同查询、不同语料之间的分数可以直接比较，也适合与向量相似度加权融合。
+type BM25Score struct {
+	ID      string
+	Score   float64
+	Raw     float64
+	Matched int
+}
+
+// BM25Index 按 scope 划分的内存倒排索引，scope 之间的文档频率互不影响。并发安全。
+type BM25Index struct {
+	mu        sync.RWMutex
+	params    BM25Params
+	tokenizer Tokenizer
+	scopes    map[string]*bm25Scope
+}
+
+type bm25Scope struct {
+	docs     map[string]*bm25Doc
+	postings map[string]map[string]int
+	totalLen int
+}
+
+type bm25Doc struct {
+	terms  map[string]int
+	length int
+}
+
+// NewBM25Index 创建 BM25 索引，tokenizer 为空时使用 DefaultTokenizer()
+func NewBM25Index(tokenizer Tokenizer, params BM25Params) *BM25Index {
+	return &BM25Index{
+		params:    params.normalize(),
+		tokenizer: tokenizer,
+		scopes:    make(map[string]*bm25Scope),
+	}
+}
+
+// Add 添加或替换文档
+func (ix *BM25Index) Add(scope string, doc BM25Document) {
+	terms := Terms(ix.tokenizer, doc.Text)
+	freq := make(map[string]int, len(terms))
+	for _, term := range terms {
+		freq[term]++
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	sc := ix.scopeLocked(scope)
+	sc.remove(doc.ID)
+	sc.docs[doc.ID] = &bm25Doc{terms: freq, length: len(terms)}
+	sc.totalLen += len(terms)
+	for term, tf := range freq {
+		posting := sc.postings[term]
+		if posting == nil {
+			posting = make(map[string]int)
+			sc.postings[term] = posting
+		}
+		posting[doc.ID] = tf
+	}
+}
+
+// Remove 删除文档
+func (ix *BM25Index) Remove(scope, id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if sc := ix.scopes[scope]; sc != nil {
+		sc.remove(id)
+	}
+}
+
+// ResetScope 清空并标记 scope 为已加载（用于重建索引）
+func (ix *BM25Index) ResetScope(scope string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.scopes[scope] = newBM25Scope()
+}
+
+// DropScope 删除 scope，之后 HasScope 返回 false
+func (ix *BM25Index) DropScope(scope string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	delete(ix.scopes, scope)
+}
+
+// DropAll 删除所有 scope
+func (ix *BM25Index) DropAll() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.scopes = make(map[string]*bm25Scope)
+}
+
+// HasScope 判断 scope 是否已加载
+func (ix *BM25Index) HasScope(scope string) bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	_, ok := ix.scopes[scope]
+	return ok
+}
+
+// Search 按关键词打分，返回按分数倒序的命中文档。
+// 关键词分词后的检索词全部出现在文档中才算命中该关键词；
+// match=all 时需命中全部关键词，match=any 时至少命中一个。
+func (ix *BM25Index) Search(scope string, keywords []string, match string) []BM25Score {
+	groups := keywordTermGroups(ix.tokenizer, keywords)
+	if len(groups) == 0 {
+		return nil
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	sc := ix.scopes[scope]
+	if sc == nil || len(sc.docs) == 0 {
+		return nil
+	}
+	return sc.score(ix.params, groups, NormalizeMatch(match) == MatchAll)
+}
+
+// RankBM25 对一组文档一次性打分，适合候选集较小、无需常驻索引的场景（如用户事件检索）
+func RankBM25(tokenizer Tokenizer, params BM25Params, docs []BM25Document, keywords []string, match string) []BM25Score {
+	ix := NewBM25Index(tokenizer, params)
+	for _, doc := range docs {
+		ix.Add("", doc)
+	}
+	return ix.Search("", keywords, match)
+}
+
+func (ix *BM25Index) scopeLocked(scope string) *bm25Scope {
+	sc := ix.scopes[scope]
+	if sc == nil {
+		sc = newBM25Scope()
+		ix.scopes[scope] = sc
+	}
+	return sc
+}
+
+func newBM25Scope() *bm25Scope {
+	return &bm25Scope{
+		docs:     make(map[string]*bm25Doc),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+func (sc *bm25Scope) remove(id string) {
+	doc := sc.docs[id]
+	if doc == nil {
+		return
+	}
+	for term := range doc.terms {
+		if posting := sc.postings[term]; posting != nil {
+			delete(posting, id)
+			if len(posting) == 0 {
+				delete(sc.postings, term)
+			}
+		}
+	}
+	sc.totalLen -= doc.length
+	delete(sc.docs, id)
+}
+
+func (sc *bm25Scope) score(params BM25Params, groups [][]string, matchAll bool) []BM25Score {
+	n := float64(len(sc.docs))
+	avgLen := float64(sc.totalLen) / n
+	if avgLen <= 0 {
+		avgLen = 1
+	}
+
+	// 查询词去重后计算 idf 与分数上界
+	idf := make(map[string]float64)
+	upperBound := 0.0
+	for _, group := range groups {
+		for _, term := range group {
+			if _, ok := idf[term]; ok {
+				continue
+			}
+			df := float64(len(sc.postings[term]))
+			idf[term] = math.Log(1 + (n-df+0.5)/(df+0.5))
+			upperBound += idf[term] * (params.K1 + 1)
+		}
+	}
+
+	raw := make(map[string]float64)
+	for term, weight := range idf {
+		for id, tf := range sc.postings[term] {
+			doc := sc.docs[id]
+			norm := params.K1 * (1 - params.B + params.B*float64(doc.length)/avgLen)
+			raw[id] += weight * float64(tf) * (params.K1 + 1) / (float64(tf) + norm)
+		}
+	}
+
+	out := make([]BM25Score, 0, len(raw))
+	for id, score := range raw {
+		doc := sc.docs[id]
+		matchedGroups := 0
+		for _, group := range groups {
+			if doc.containsAll(group) {
+				matchedGroups++
+			}
+		}
+		// 与 MatchesKeywords 一致：关键词的所有检索词都出现才算命中该关键词
+		if matchedGroups == 0 || (matchAll && matchedGroups < len(groups)) {
+			continue
+		}
+		calibrated := 0.0
+		if upperBound > 0 {
+			calibrated = score / upperBound
+		}
+		out = append(out, BM25Score{ID: id, Score: calibrated, Raw: score, Matched: matchedGroups})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Raw == out[j].Raw {
+			return out[i].ID > out[j].ID
+		}
+		return out[i].Raw > out[j].Raw
+	})
+	return out
+}
+
+func (d *bm25Doc) containsAll(terms []string) bool {
+	for _, term := range terms {
+		if d.terms[term] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// keywordTermGroups 将每个关键词分词为一组检索词，空组被忽略
+func keywordTermGroups(tokenizer Tokenizer, keywords []string) [][]string {
+	groups := make([][]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" {
+			continue
+		}
+		terms := Terms(tokenizer, keyword)
+		if len(terms) == 0 {
+			// 关键词全部是停用词时按小写原文作为检索词
+			terms = []string{strings.ToLower(keyword)}
+		}
+		groups = append(groups, terms)
+	}
+	return groups
+}
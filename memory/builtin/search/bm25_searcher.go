@@ -0,0 +1,402 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gookit/slog"
+)
+
+// Invalidator 由持有派生内存状态的 Searcher 实现。
+// 消息在 Index 之外被删除（清理、截断）后调用，sessionID 与 userID 都为空时失效全部状态。
+type Invalidator interface {
+	Invalidate(sessionID, userID string)
+}
+
+// Deleter 由在消息表之外持久化索引的 Searcher / VectorStore 实现（如 pgvector、Milvus），
+// 删除会话时用于移除对应消息的索引条目。
+type Deleter interface {
+	Delete(ctx context.Context, ids ...string) error
+}
+
+// defaultBM25PersistInterval 配置快照文件时的默认定期写回间隔
+const defaultBM25PersistInterval = time.Minute
+
+// BM25Searcher 基于内存倒排索引的关键词检索器。
+// 每个 (sessionID, userID) 会话在首次检索时从 MessageSource 懒加载，此后通过 Index 增量维护。
+// 快照只作为预热缓存：从快照恢复的会话在首次检索时与数据源核对，不一致则按数据源重建。
+type BM25Searcher struct {
+	source          MessageSource
+	index           *BM25Index
+	tokenizer       Tokenizer
+	persistPath     string
+	persistInterval time.Duration
+
+	mu       sync.RWMutex
+	messages map[string]map[string]*Message
+	// restored 从快照恢复、尚未与数据源核对的会话
+	restored map[string]bool
+	// dirty 上次写回快照后索引是否有变化
+	dirty bool
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// BM25SearcherOption BM25Searcher 的可选配置
+type BM25SearcherOption func(*BM25Searcher)
+
+// WithBM25Tokenizer 指定分词器，默认使用 DefaultTokenizer()
+func WithBM25Tokenizer(tokenizer Tokenizer) BM25SearcherOption {
+	return func(s *BM25Searcher) {
+		s.tokenizer = tokenizer
+	}
+}
+
+// WithBM25PersistPath 指定索引快照文件。创建时若文件存在则从中恢复，Close 时写回
+func WithBM25PersistPath(path string) BM25SearcherOption {
+	return func(s *BM25Searcher) {
+		s.persistPath = strings.TrimSpace(path)
+	}
+}
+
+// WithBM25PersistInterval 指定快照定期写回间隔，默认 1 分钟；<=0 时只在 Close 时写回
+func WithBM25PersistInterval(interval time.Duration) BM25SearcherOption {
+	return func(s *BM25Searcher) {
+		s.persistInterval = interval
+	}
+}
+
+// NewBM25Searcher 创建 BM25 检索器
+func NewBM25Searcher(source MessageSource, params BM25Params, opts ...BM25SearcherOption) (*BM25Searcher, error) {
+	if source == nil {
+		return nil, errors.New("message source is required")
+	}
+	s := &BM25Searcher{
+		source:          source,
+		persistInterval: defaultBM25PersistInterval,
+		messages:        make(map[string]map[string]*Message),
+		restored:        make(map[string]bool),
+		stop:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	s.index = NewBM25Index(s.tokenizer, params)
+
+	if s.persistPath != "" {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+		if s.persistInterval > 0 {
+			s.wg.Add(1)
+			go s.persistLoop()
+		}
+	}
+	return s, nil
+}
+
+func bm25ScopeKey(sessionID, userID string) string {
+	return sessionID + "\x00" + userID
+}
+
+func (s *BM25Searcher) Search(ctx context.Context, q *SearchQuery) ([]*SearchHit, error) {
+	if s == nil {
+		return nil, errors.New("bm25 searcher is nil")
+	}
+	if q == nil {
+		return nil, errors.New("search query is nil")
+	}
+	if q.UserID == "" {
+		return nil, errors.New("userID is required")
+	}
+
+	query := *q
+	if query.Limit <= 0 {
+		query.Limit = 5
+	}
+	if len(query.Keywords) == 0 {
+		query.Keywords = InferKeywordsWith(s.tokenizer, query.Query)
+	}
+	if len(query.Keywords) == 0 {
+		return nil, errors.New("keywords are required")
+	}
+
+	scope := bm25ScopeKey(query.SessionID, query.UserID)
+	if err := s.ensureScope(ctx, query.SessionID, query.UserID); err != nil {
+		return nil, err
+	}
+
+	scores := s.index.Search(scope, query.Keywords, query.Match)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	msgs := s.messages[scope]
+	hits := make([]*SearchHit, 0, query.Limit)
+	for _, score := range scores {
+		msg := msgs[score.ID]
+		if msg == nil || !matchesMessageFilters(msg, &query) {
+			continue
+		}
+		hits = append(hits, &SearchHit{
+			Message: CloneMessage(msg),
+			Score:   score.Score,
+			Snippet: buildSnippet(SearchText(msg), query.Keywords),
+		})
+		if len(hits) >= query.Limit {
+			break
+		}
+	}
+	return hits, nil
+}
+
+// Index 增量索引一条消息。会话尚未加载时跳过，首次检索时会从数据源完整加载。
+// 已加载该用户的跨会话索引时同时写入。
+func (s *BM25Searcher) Index(_ context.Context, msg *Message) error {
+	if s == nil {
+		return errors.New("bm25 searcher is nil")
+	}
+	if msg == nil {
+		return errors.New("message is nil")
+	}
+	for _, scope := range []string{bm25ScopeKey(msg.SessionID, msg.UserID), bm25ScopeKey("", msg.UserID)} {
+		if s.index.HasScope(scope) {
+			s.add(scope, msg)
+		}
+	}
+	return nil
+}
+
+// Reindex 从数据源重建会话索引
+func (s *BM25Searcher) Reindex(ctx context.Context, sessionID, userID string) error {
+	if s == nil {
+		return errors.New("bm25 searcher is nil")
+	}
+	msgs, err := s.source.ListMessages(ctx, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	s.rebuild(bm25ScopeKey(sessionID, userID), msgs)
+	return nil
+}
+
+// ensureScope 保证会话索引可用：未加载时从数据源加载；
+// 从快照恢复的会话与数据源核对一次，快照之后新增或删除过消息（崩溃、其他进程写入）时按数据源重建
+func (s *BM25Searcher) ensureScope(ctx context.Context, sessionID, userID string) error {
+	scope := bm25ScopeKey(sessionID, userID)
+	s.mu.RLock()
+	pending := s.restored[scope]
+	s.mu.RUnlock()
+	if !pending && s.index.HasScope(scope) {
+		return nil
+	}
+
+	msgs, err := s.source.ListMessages(ctx, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if pending && s.matchesSource(scope, msgs) {
+		s.mu.Lock()
+		delete(s.restored, scope)
+		s.mu.Unlock()
+		return nil
+	}
+	s.rebuild(scope, msgs)
+	return nil
+}
+
+// matchesSource 判断内存中的会话索引与数据源的消息是否一致：
+// 除消息 ID 集合外还逐条比较索引文本、角色与创建时间，快照之后被原地改写的消息也会触发重建
+func (s *BM25Searcher) matchesSource(scope string, msgs []*Message) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	indexed := s.messages[scope]
+	count := 0
+	for _, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+		text := SearchText(msg)
+		if text == "" {
+			continue
+		}
+		old, ok := indexed[msg.ID]
+		if !ok || SearchText(old) != text || old.Role != msg.Role || !old.CreatedAt.Equal(msg.CreatedAt) {
+			return false
+		}
+		count++
+	}
+	return count == len(indexed)
+}
+
+func (s *BM25Searcher) rebuild(scope string, msgs []*Message) {
+	s.mu.Lock()
+	s.messages[scope] = make(map[string]*Message, len(msgs))
+	delete(s.restored, scope)
+	s.dirty = true
+	s.mu.Unlock()
+	s.index.ResetScope(scope)
+	for _, msg := range msgs {
+		if msg != nil {
+			s.add(scope, msg)
+		}
+	}
+}
+
+// Invalidate 丢弃会话及该用户跨会话的内存索引，下次检索时重新加载
+func (s *BM25Searcher) Invalidate(sessionID, userID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+	if sessionID == "" && userID == "" {
+		s.messages = make(map[string]map[string]*Message)
+		s.restored = make(map[string]bool)
+		s.index.DropAll()
+		return
+	}
+	for _, scope := range []string{bm25ScopeKey(sessionID, userID), bm25ScopeKey("", userID)} {
+		delete(s.messages, scope)
+		delete(s.restored, scope)
+		s.index.DropScope(scope)
+	}
+}
+
+// Close 配置了快照文件时停止定期写回并写回索引快照，可重复调用
+func (s *BM25Searcher) Close() error {
+	if s == nil || s.persistPath == "" {
+		return nil
+	}
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.wg.Wait()
+		err = s.save()
+	})
+	return err
+}
+
+func (s *BM25Searcher) persistLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.persistInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			dirty := s.dirty
+			s.mu.RUnlock()
+			if !dirty {
+				continue
+			}
+			if err := s.save(); err != nil {
+				slog.Warnf("定期写回 BM25 索引快照失败: %v", err)
+			}
+		}
+	}
+}
+
+func (s *BM25Searcher) add(scope string, msg *Message) {
+	text := SearchText(msg)
+	if text == "" {
+		return
+	}
+	s.mu.Lock()
+	msgs := s.messages[scope]
+	if msgs == nil {
+		msgs = make(map[string]*Message)
+		s.messages[scope] = msgs
+	}
+	msgs[msg.ID] = CloneMessage(msg)
+	s.dirty = true
+	s.mu.Unlock()
+	s.index.Add(scope, BM25Document{ID: msg.ID, Text: text})
+}
+
+// bm25Snapshot 快照只保存消息，索引在加载时重建，避免快照格式与打分实现耦合
+type bm25Snapshot struct {
+	Messages []*Message `json:"messages"`
+}
+
+func (s *BM25Searcher) save() error {
+	s.mu.Lock()
+	snapshot := bm25Snapshot{}
+	for scope, msgs := range s.messages {
+		for _, msg := range msgs {
+			// 跨会话索引中的消息同时存在于各自会话的索引中，只保存一次
+			if scope == bm25ScopeKey(msg.SessionID, msg.UserID) {
+				snapshot.Messages = append(snapshot.Messages, msg)
+			}
+		}
+	}
+	data, err := json.Marshal(snapshot)
+	s.dirty = false
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("序列化 BM25 索引快照失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.persistPath), 0o755); err != nil {
+		return fmt.Errorf("创建 BM25 快照目录失败: %w", err)
+	}
+	tmp := s.persistPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入 BM25 索引快照失败: %w", err)
+	}
+	return os.Rename(tmp, s.persistPath)
+}
+
+func (s *BM25Searcher) load() error {
+	data, err := os.ReadFile(s.persistPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取 BM25 索引快照失败: %w", err)
+	}
+
+	var snapshot bm25Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("解析 BM25 索引快照失败: %w", err)
+	}
+	for _, msg := range snapshot.Messages {
+		if msg == nil {
+			continue
+		}
+		scope := bm25ScopeKey(msg.SessionID, msg.UserID)
+		if !s.index.HasScope(scope) {
+			s.index.ResetScope(scope)
+			s.restored[scope] = true
+		}
+		s.add(scope, msg)
+	}
+	s.dirty = false
+	return nil
+}
+
+func matchesMessageFilters(msg *Message, q *SearchQuery) bool {
+	if role := strings.TrimSpace(q.Role); role != "" && msg.Role != role {
+		return false
+	}
+	if q.Since != nil && msg.CreatedAt.Before(*q.Since) {
+		return false
+	}
+	if q.Until != nil && msg.CreatedAt.After(*q.Until) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,187 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBM25IndexRanksByRelevance(t *testing.T) {
+	scores := RankBM25(nil, BM25Params{}, []BM25Document{
+		{ID: "a", Text: "报销流程需要先提交发票，再走报销审批"},
+		{ID: "b", Text: "今天的会议讨论了项目进度"},
+		{ID: "c", Text: "报销已经到账"},
+		{ID: "d", Text: "审批流程调整"},
+	}, []string{"报销流程"}, MatchAny)
+
+	if len(scores) != 1 || scores[0].ID != "a" {
+		t.Fatalf("unexpected scores: %+v", scores)
+	}
+	if scores[0].Score <= 0 || scores[0].Score >= 1 {
+		t.Fatalf("calibrated score out of range: %v", scores[0].Score)
+	}
+
+	scores = RankBM25(nil, BM25Params{}, []BM25Document{
+		{ID: "a", Text: "报销流程需要先提交发票，再走报销审批"},
+		{ID: "c", Text: "报销已经到账"},
+		{ID: "d", Text: "审批流程调整"},
+	}, []string{"报销", "审批"}, MatchAny)
+	if len(scores) != 3 || scores[0].ID != "a" {
+		t.Fatalf("document matching both keywords should rank first: %+v", scores)
+	}
+}
+
+type fakeMessageSource struct {
+	messages []*Message
+	calls    int
+}
+
+func (s *fakeMessageSource) ListMessages(context.Context, string, string) ([]*Message, error) {
+	s.calls++
+	return s.messages, nil
+}
+
+func TestBM25SearcherLazyLoadIndexAndPersist(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	source := &fakeMessageSource{messages: []*Message{
+		{ID: "m1", SessionID: "s1", UserID: "u1", Role: "user", Content: "deploy pipeline failed", CreatedAt: now},
+		{ID: "m2", SessionID: "s1", UserID: "u1", Role: "assistant", Content: "restart the worker", CreatedAt: now},
+	}}
+	path := filepath.Join(t.TempDir(), "bm25.json")
+
+	searcher, err := NewBM25Searcher(source, BM25Params{}, WithBM25PersistPath(path))
+	if err != nil {
+		t.Fatalf("NewBM25Searcher: %v", err)
+	}
+	query := &SearchQuery{SessionID: "s1", UserID: "u1", Query: "deploying"}
+	hits, err := searcher.Search(ctx, query)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Message.ID != "m1" || hits[0].Snippet == "" {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+
+	if err := searcher.Index(ctx, &Message{ID: "m3", SessionID: "s1", UserID: "u1", Role: "user", Content: "deploy again", CreatedAt: now}); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	hits, _ = searcher.Search(ctx, query)
+	if len(hits) != 2 || hits[0].Message.ID != "m3" {
+		t.Fatalf("indexed message should be searchable and rank first: %+v", hits)
+	}
+	if source.calls != 1 {
+		t.Fatalf("source should be loaded once, got %d", source.calls)
+	}
+
+	if err := searcher.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// 快照与数据源一致时直接使用，只核对一次
+	current := &fakeMessageSource{messages: append(source.messages, &Message{ID: "m3", SessionID: "s1", UserID: "u1", Role: "user", Content: "deploy again", CreatedAt: now})}
+	restored, err := NewBM25Searcher(current, BM25Params{}, WithBM25PersistPath(path))
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	hits, _ = restored.Search(ctx, query)
+	hits, _ = restored.Search(ctx, query)
+	if len(hits) != 2 || current.calls != 1 {
+		t.Fatalf("restored searcher should keep snapshot after one check, got %d hits, %d calls", len(hits), current.calls)
+	}
+
+	restored.Invalidate("s1", "u1")
+	current.messages = nil
+	hits, _ = restored.Search(ctx, query)
+	if len(hits) != 0 {
+		t.Fatalf("invalidated scope should reload from empty source, got %d hits", len(hits))
+	}
+	_ = restored.Close()
+}
+
+func TestBM25SearcherReconcilesStaleSnapshot(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "bm25.json")
+	source := &fakeMessageSource{messages: []*Message{
+		{ID: "m1", SessionID: "s1", UserID: "u1", Role: "user", Content: "deploy pipeline failed", CreatedAt: now},
+		{ID: "m2", SessionID: "s1", UserID: "u1", Role: "user", Content: "deploy rollback", CreatedAt: now},
+	}}
+	searcher, err := NewBM25Searcher(source, BM25Params{}, WithBM25PersistPath(path), WithBM25PersistInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewBM25Searcher: %v", err)
+	}
+	query := &SearchQuery{SessionID: "s1", UserID: "u1", Query: "deploy"}
+	if hits, _ := searcher.Search(ctx, query); len(hits) != 2 {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+	// 未调用 Close（模拟崩溃），依赖定期写回的快照
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("snapshot should be written periodically")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 快照之后 m2 被删除、m3 由其他进程写入
+	changed := &fakeMessageSource{messages: []*Message{
+		source.messages[0],
+		{ID: "m3", SessionID: "s1", UserID: "u1", Role: "user", Content: "deploy succeeded", CreatedAt: now},
+	}}
+	restored, err := NewBM25Searcher(changed, BM25Params{}, WithBM25PersistPath(path), WithBM25PersistInterval(0))
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	hits, err := restored.Search(ctx, query)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, hit := range hits {
+		ids[hit.Message.ID] = true
+	}
+	if len(hits) != 2 || !ids["m1"] || !ids["m3"] {
+		t.Fatalf("stale snapshot should be reconciled with source, got %+v", ids)
+	}
+	_ = searcher.Close()
+}
+
+func TestBM25SearcherRebuildsEditedSnapshotMessage(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "bm25.json")
+	source := &fakeMessageSource{messages: []*Message{
+		{ID: "m1", SessionID: "s1", UserID: "u1", Role: "user", Content: "deploy pipeline failed", CreatedAt: now},
+	}}
+	searcher, err := NewBM25Searcher(source, BM25Params{}, WithBM25PersistPath(path))
+	if err != nil {
+		t.Fatalf("NewBM25Searcher: %v", err)
+	}
+	if hits, _ := searcher.Search(ctx, &SearchQuery{SessionID: "s1", UserID: "u1", Query: "deploy"}); len(hits) != 1 {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+	if err := searcher.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// 快照之后 m1 被原地改写，ID 集合不变
+	edited := &fakeMessageSource{messages: []*Message{
+		{ID: "m1", SessionID: "s1", UserID: "u1", Role: "user", Content: "rollback finished", CreatedAt: now},
+	}}
+	restored, err := NewBM25Searcher(edited, BM25Params{}, WithBM25PersistPath(path))
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	defer restored.Close()
+	if hits, _ := restored.Search(ctx, &SearchQuery{SessionID: "s1", UserID: "u1", Query: "deploy"}); len(hits) != 0 {
+		t.Fatalf("edited message should not match its old content: %+v", hits)
+	}
+	if hits, _ := restored.Search(ctx, &SearchQuery{SessionID: "s1", UserID: "u1", Query: "rollback"}); len(hits) != 1 {
+		t.Fatalf("edited message should be reindexed: %+v", hits)
+	}
+}
@@ -0,0 +1,17 @@
+package search
+
+// builtinChineseDictionary 内置的精简中文词表，覆盖对话记忆检索中常见的时间、工作、生活与技术词汇。
+// 词表只用于最大匹配分词；专业领域词汇建议通过 Segmenter.AddWords / LoadDictionary 补充。
+const builtinChineseDictionary = `
+叫 名字 姓名 称呼 昵称 年龄 生日 性别 男 女 家 家里 家人 父母 爸爸 妈妈 父亲 母亲 孩子 儿子 女儿 老婆 老公 妻子 丈夫 朋友 同事 领导 老板 客户 用户 同学 老师
+今天 明天 昨天 前天 后天 今年 明年 去年 前年 本周 上周 下周 这周 周末 本月 上个月 下个月 这个月 月初 月底 月中 年初 年底 季度 上半年 下半年 早上 上午 中午 下午 晚上 凌晨 夜里 最近 近期 目前 现在 以后 将来 未来 过去 每天 每周 每月 每年 工作日 节假日 假期 春节 国庆 元旦 中秋
+星期一 星期二 星期三 星期四 星期五 星期六 星期日 星期天 周一 周二 周三 周四 周五 周六 周日
+一月 二月 三月 四月 五月 六月 七月 八月 九月 十月 十一月 十二月
+公司 部门 团队 项目 任务 计划 方案 目标 进度 里程碑 需求 功能 模块 系统 平台 产品 服务 业务 流程 规范 制度 政策 规则 标准 文档 报告 周报 日报 月报 总结 汇报 会议 例会 评审 复盘 讨论 沟通 协作 安排 分配 负责 负责人 对接 审批 申请 提交 通过 驳回 拒绝 批准 确认 通知 提醒 截止 截止日期 期限 延期 上线 发布 部署 交付 验收 测试 开发 设计 维护 运维 迭代 版本 升级 迁移 回滚 重构 优化 修复 排查 定位 分析 统计 监控 告警 故障 事故 异常 错误 报错 失败 成功 超时 延迟 卡顿 崩溃 重启 恢复 备份 扩容 缩容 配置 参数 权限 账号 账户 密码 登录 注册 退出 认证 授权 验证 验证码 接口 数据 数据库 服务器 集群 节点 网络 域名 证书 日志 缓存 队列 存储 文件 目录 代码 仓库 分支 合并 提交记录 脚本 工具 插件 客户端 服务端 前端 后端 移动端 网页 页面 按钮 链接 地址 端口 主体 删除 新增 添加 修改 更新 查询 搜索 检索 导入 导出 下载 上传 同步 异步 完成 开始 结束 暂停 继续 取消 关闭 打开 启用 禁用
+报销 发票 费用 预算 成本 价格 金额 付款 支付 收款 转账 充值 退款 提现 入账 到账 对账 结算 账单 订单 合同 协议 采购 供应商 工资 薪资 奖金 绩效 考勤 请假 加班 出差 差旅 机票 酒店 住宿 交通 打车 报价 发货 收货 物流 快递 库存 仓储 销售 市场 运营 推广 营销 活动 渠道 客服 售后 投诉 反馈 建议 意见 满意度 评价
+医院 医生 看病 体检 身体 健康 生病 感冒 发烧 咳嗽 药 吃药 过敏 睡眠 失眠 运动 跑步 健身 游泳 减肥 饮食 早餐 午餐 晚餐 吃饭 喝水 咖啡 茶 水果 蔬菜 喜欢 不喜欢 讨厌 爱好 兴趣 习惯 偏好 口味 辣 甜 咸 素食
+旅行 旅游 出行 行程 航班 火车 高铁 地铁 公交 开车 驾照 汽车 房子 租房 买房 房租 房贷 搬家 装修 学习 考试 课程 培训 学校 大学 专业 毕业 论文 作业 读书 看书 电影 音乐 游戏 手机 电脑 笔记本 电话 邮件 邮箱 短信 微信 消息 聊天 群 视频 照片 图片 截图
+北京 上海 广州 深圳 杭州 成都 南京 武汉 西安 重庆 天津 苏州 长沙 厦门 青岛 香港 台湾 中国 美国 日本 英国
+人工智能 模型 大模型 机器学习 深度学习 算法 向量 嵌入 检索 记忆 摘要 上下文 提示词 智能体 知识库 知识图谱 分词 关键词 索引 排序 召回 相似度 问答 对话 会话 历史 记录 事件 用户画像
+重要 紧急 优先级 高 低 中 多 少 大 小 新 旧 好 坏 快 慢 早 晚 第一 第二 第三 一次 两次 多次 每次 全部 部分 所有 其他 另外 还有 已经 正在 将要 马上 立即 尽快 一直 总是 经常 偶尔 从来 几乎 大概 可能 应该 必须 一定 确定 不确定 同意 不同意 支持 反对
+`
@@ -0,0 +1,352 @@
+package search
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	defaultHNSWM              = 16
+	defaultHNSWEfConstruction = 200
+	defaultHNSWEfSearch       = 64
+)
+
+// HNSWParams HNSW 图参数，零值时使用 M=16、efConstruction=200、efSearch=64
+type HNSWParams struct {
+	// M 每个节点在上层保留的邻居数，第 0 层为 2M
+	M int
+	// EfConstruction 建图时的候选队列长度，越大召回越高、写入越慢
+	EfConstruction int
+	// EfSearch 检索时的候选队列长度，实际取 max(EfSearch, k)
+	EfSearch int
+}
+
+func (p HNSWParams) normalize() HNSWParams {
+	if p.M <= 1 {
+		p.M = defaultHNSWM
+	}
+	if p.EfConstruction <= 0 {
+		p.EfConstruction = defaultHNSWEfConstruction
+	}
+	if p.EfSearch <= 0 {
+		p.EfSearch = defaultHNSWEfSearch
+	}
+	return p
+}
+
+// hnswGraph 余弦距离的 HNSW 图。向量写入前归一化，距离为 1 - 点积。
+// 更新与删除采用墓碑标记，墓碑超过一半时整图重建。非并发安全，由调用方加锁。
+type hnswGraph struct {
+	params   HNSWParams
+	levelMul float64
+	rng      *rand.Rand
+
+	nodes    []*hnswNode
+	ids      map[string]int
+	entry    int
+	maxLevel int
+	dim      int
+	deleted  int
+}
+
+type hnswNode struct {
+	ID        string
+	Vector    []float64
+	Level     int
+	Neighbors [][]int
+	Deleted   bool
+}
+
+type hnswResult struct {
+	ID    string
+	Score float64
+}
+
+func newHNSWGraph(params HNSWParams, dim int) *hnswGraph {
+	params = params.normalize()
+	return &hnswGraph{
+		params:   params,
+		levelMul: 1 / math.Log(float64(params.M)),
+		rng:      rand.New(rand.NewSource(rand.Int63())),
+		ids:      make(map[string]int),
+		entry:    -1,
+		dim:      dim,
+	}
+}
+
+// Len 返回有效（未删除）节点数
+func (g *hnswGraph) Len() int {
+	return len(g.ids)
+}
+
+// Add 插入或替换向量，维度不符时忽略并返回 false
+func (g *hnswGraph) Add(id string, vector []float64) bool {
+	if len(vector) != g.dim {
+		return false
+	}
+	normalized := normalizeVector(vector)
+	if normalized == nil {
+		return false
+	}
+	g.Remove(id)
+
+	level := int(math.Floor(-math.Log(1-g.rng.Float64()) * g.levelMul))
+	node := &hnswNode{ID: id, Vector: normalized, Level: level, Neighbors: make([][]int, level+1)}
+	idx := len(g.nodes)
+	g.nodes = append(g.nodes, node)
+	g.ids[id] = idx
+
+	if g.entry < 0 {
+		g.entry = idx
+		g.maxLevel = level
+		return true
+	}
+
+	ep := g.entry
+	for l := g.maxLevel; l > level; l-- {
+		ep = g.greedyClosest(normalized, ep, l)
+	}
+	for l := min(level, g.maxLevel); l >= 0; l-- {
+		candidates := g.searchLayer(normalized, ep, g.params.EfConstruction, l)
+		neighbors := make([]int, 0, g.params.M)
+		for _, c := range candidates {
+			if len(neighbors) >= g.params.M {
+				break
+			}
+			neighbors = append(neighbors, c.idx)
+		}
+		node.Neighbors[l] = neighbors
+		for _, nb := range neighbors {
+			g.link(nb, idx, l)
+		}
+		if len(candidates) > 0 {
+			ep = candidates[0].idx
+		}
+	}
+	if level > g.maxLevel {
+		g.entry = idx
+		g.maxLevel = level
+	}
+	return true
+}
+
+// Remove 以墓碑标记删除向量，节点仍参与图遍历但不出现在结果中
+func (g *hnswGraph) Remove(id string) {
+	idx, ok := g.ids[id]
+	if !ok {
+		return
+	}
+	delete(g.ids, id)
+	g.nodes[idx].Deleted = true
+	g.deleted++
+	if g.deleted > len(g.ids) && g.deleted > 64 {
+		g.rebuild()
+	}
+}
+
+// Search 返回与 vector 最相似的 k 个有效节点，Score 为余弦相似度
+func (g *hnswGraph) Search(vector []float64, k int) []hnswResult {
+	if g.entry < 0 || k <= 0 || len(vector) != g.dim {
+		return nil
+	}
+	normalized := normalizeVector(vector)
+	if normalized == nil {
+		return nil
+	}
+
+	ep := g.entry
+	for l := g.maxLevel; l > 0; l-- {
+		ep = g.greedyClosest(normalized, ep, l)
+	}
+	// 墓碑节点占用候选位，按比例放大队列以保证有效结果数量
+	ef := max(g.params.EfSearch, k)
+	if g.deleted > 0 && len(g.ids) > 0 {
+		ef += ef * g.deleted / len(g.ids)
+	}
+	candidates := g.searchLayer(normalized, ep, ef, 0)
+
+	out := make([]hnswResult, 0, k)
+	for _, c := range candidates {
+		node := g.nodes[c.idx]
+		if node.Deleted {
+			continue
+		}
+		out = append(out, hnswResult{ID: node.ID, Score: 1 - c.dist})
+		if len(out) >= k {
+			break
+		}
+	}
+	return out
+}
+
+func (g *hnswGraph) rebuild() {
+	nodes := g.nodes
+	*g = *newHNSWGraph(g.params, g.dim)
+	for _, node := range nodes {
+		if !node.Deleted {
+			g.Add(node.ID, node.Vector)
+		}
+	}
+}
+
+func (g *hnswGraph) distance(a []float64, idx int) float64 {
+	b := g.nodes[idx].Vector
+	var dot float64
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return 1 - dot
+}
+
+func (g *hnswGraph) greedyClosest(vector []float64, ep, level int) int {
+	best := ep
+	bestDist := g.distance(vector, ep)
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range g.nodes[best].Neighbors[level] {
+			if d := g.distance(vector, nb); d < bestDist {
+				best, bestDist = nb, d
+				changed = true
+			}
+		}
+	}
+	return best
+}
+
+// searchLayer 在指定层做 best-first 搜索，返回按距离升序的至多 ef 个候选
+func (g *hnswGraph) searchLayer(vector []float64, ep, ef, level int) []hnswCandidate {
+	visited := map[int]struct{}{ep: {}}
+	start := hnswCandidate{idx: ep, dist: g.distance(vector, ep)}
+	candidates := &hnswMinHeap{start}
+	results := &hnswMaxHeap{start}
+
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && current.dist > (*results)[0].dist {
+			break
+		}
+		node := g.nodes[current.idx]
+		if level >= len(node.Neighbors) {
+			continue
+		}
+		for _, nb := range node.Neighbors[level] {
+			if _, ok := visited[nb]; ok {
+				continue
+			}
+			visited[nb] = struct{}{}
+			d := g.distance(vector, nb)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(candidates, hnswCandidate{idx: nb, dist: d})
+				heap.Push(results, hnswCandidate{idx: nb, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	out := []hnswCandidate(*results)
+	sort.Slice(out, func(i, j int) bool { return out[i].dist < out[j].dist })
+	return out
+}
+
+// link 将 to 加入 from 的邻居表，超出上限时保留距离最近的邻居
+func (g *hnswGraph) link(from, to, level int) {
+	node := g.nodes[from]
+	node.Neighbors[level] = append(node.Neighbors[level], to)
+	maxConn := g.params.M
+	if level == 0 {
+		maxConn = 2 * g.params.M
+	}
+	if len(node.Neighbors[level]) <= maxConn {
+		return
+	}
+	neighbors := node.Neighbors[level]
+	sort.Slice(neighbors, func(i, j int) bool {
+		return g.distance(node.Vector, neighbors[i]) < g.distance(node.Vector, neighbors[j])
+	})
+	node.Neighbors[level] = neighbors[:maxConn]
+}
+
+func normalizeVector(vector []float64) []float64 {
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm == 0 {
+		return nil
+	}
+	norm = math.Sqrt(norm)
+	out := make([]float64, len(vector))
+	for i, v := range vector {
+		out[i] = v / norm
+	}
+	return out
+}
+
+type hnswCandidate struct {
+	idx  int
+	dist float64
+}
+
+type hnswMinHeap []hnswCandidate
+
+func (h hnswMinHeap) Len() int           { return len(h) }
+func (h hnswMinHeap) Less(i, j int) bool { return h[i].dist < h[j].dist }
+func (h hnswMinHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hnswMinHeap) Push(x any)        { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMinHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+type hnswMaxHeap []hnswCandidate
+
+func (h hnswMaxHeap) Len() int           { return len(h) }
+func (h hnswMaxHeap) Less(i, j int) bool { return h[i].dist > h[j].dist }
+func (h hnswMaxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hnswMaxHeap) Push(x any)        { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMaxHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// hnswSnapshot 图的可序列化形式，恢复时无需重新建图
+type hnswSnapshot struct {
+	Params   HNSWParams
+	Nodes    []*hnswNode
+	Entry    int
+	MaxLevel int
+	Dim      int
+}
+
+func (g *hnswGraph) snapshot() *hnswSnapshot {
+	return &hnswSnapshot{
+		Params:   g.params,
+		Nodes:    g.nodes,
+		Entry:    g.entry,
+		MaxLevel: g.maxLevel,
+		Dim:      g.dim,
+	}
+}
+
+func restoreHNSWGraph(snap *hnswSnapshot) *hnswGraph {
+	g := newHNSWGraph(snap.Params, snap.Dim)
+	g.nodes = snap.Nodes
+	g.entry = snap.Entry
+	g.maxLevel = snap.MaxLevel
+	for idx, node := range g.nodes {
+		if node.Deleted {
+			g.deleted++
+			continue
+		}
+		g.ids[node.ID] = idx
+	}
+	return g
+}
@@ -0,0 +1,104 @@
+package search
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func randomVectors(n, dim int, seed int64) [][]float64 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([][]float64, n)
+	for i := range out {
+		out[i] = make([]float64, dim)
+		for j := range out[i] {
+			out[i][j] = rng.NormFloat64()
+		}
+	}
+	return out
+}
+
+func TestHNSWGraphRecall(t *testing.T) {
+	const n, dim, k = 1000, 32, 10
+	vectors := randomVectors(n, dim, 1)
+	g := newHNSWGraph(HNSWParams{}, dim)
+	for i, v := range vectors {
+		g.Add(fmt.Sprintf("m%d", i), v)
+	}
+
+	queries := randomVectors(20, dim, 2)
+	found, total := 0, 0
+	for _, q := range queries {
+		exact := make([]hnswResult, 0, n)
+		for i, v := range vectors {
+			exact = append(exact, hnswResult{ID: fmt.Sprintf("m%d", i), Score: cosineSimilarity(q, v)})
+		}
+		sort.Slice(exact, func(i, j int) bool { return exact[i].Score > exact[j].Score })
+
+		got := make(map[string]bool)
+		for _, r := range g.Search(q, k) {
+			got[r.ID] = true
+		}
+		for _, r := range exact[:k] {
+			total++
+			if got[r.ID] {
+				found++
+			}
+		}
+	}
+	if recall := float64(found) / float64(total); recall < 0.9 {
+		t.Fatalf("recall@%d = %.2f, want >= 0.9", k, recall)
+	}
+}
+
+func TestHNSWGraphReplaceAndSnapshot(t *testing.T) {
+	g := newHNSWGraph(HNSWParams{M: 4}, 2)
+	g.Add("a", []float64{1, 0})
+	g.Add("b", []float64{0, 1})
+	g.Add("a", []float64{-1, 0})
+	if g.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", g.Len())
+	}
+
+	restored := restoreHNSWGraph(g.snapshot())
+	results := restored.Search([]float64{-1, 0.1}, 1)
+	if len(results) != 1 || results[0].ID != "a" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results := restored.Search([]float64{1, 0}, 2); len(results) != 2 {
+		t.Fatalf("tombstoned node should not be returned: %+v", results)
+	}
+}
+
+func TestANNUserIndexSearchOverFetchesForFilters(t *testing.T) {
+	idx := &annUserIndex{meta: make(map[string]annMeta)}
+	now := time.Now()
+	vectors := randomVectors(200, 8, 3)
+	for i, v := range vectors {
+		session := "s1"
+		// 只有少量消息属于 s2，需要扩大候选数才能凑满 limit
+		if i%50 == 0 {
+			session = "s2"
+		}
+		idx.add(&Message{ID: fmt.Sprintf("m%d", i), SessionID: session, Role: "user", CreatedAt: now}, v)
+	}
+
+	results, ok := idx.search(&SearchQuery{SessionID: "s2"}, vectors[1], 3, 2)
+	if !ok {
+		t.Fatal("expected dimension to match")
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 filtered results, got %d", len(results))
+	}
+	for _, r := range results {
+		if idx.meta[r.ID].SessionID != "s2" {
+			t.Fatalf("result %s does not match session filter", r.ID)
+		}
+	}
+
+	if _, ok := idx.search(&SearchQuery{}, []float64{1, 2}, 3, 2); ok {
+		t.Fatal("expected dimension mismatch to be reported")
+	}
+}
@@ -71,6 +71,11 @@ func (s *HybridSearcher) Index(ctx context.Context, msg *Message) error {
 	if s == nil || s.vector == nil {
 		return errors.New("hybrid searcher has no vector indexer")
 	}
+	if s.keyword != nil {
+		if err := s.keyword.Index(ctx, msg); err != nil {
+			return err
+		}
+	}
 	return s.vector.Index(ctx, msg)
 }
 
@@ -78,9 +83,57 @@ func (s *HybridSearcher) Reindex(ctx context.Context, sessionID, userID string)
 	if s == nil || s.vector == nil {
 		return errors.New("hybrid searcher has no vector indexer")
 	}
+	if s.keyword != nil {
+		if err := s.keyword.Reindex(ctx, sessionID, userID); err != nil {
+			return err
+		}
+	}
 	return s.vector.Reindex(ctx, sessionID, userID)
 }
 
+// Invalidate 转发给实现了 Invalidator 的子检索器
+func (s *HybridSearcher) Invalidate(sessionID, userID string) {
+	if s == nil {
+		return
+	}
+	for _, searcher := range []Searcher{s.keyword, s.vector} {
+		if invalidator, ok := searcher.(Invalidator); ok {
+			invalidator.Invalidate(sessionID, userID)
+		}
+	}
+}
+
+// Delete 转发给实现了 Deleter 的子检索器
+func (s *HybridSearcher) Delete(ctx context.Context, ids ...string) error {
+	if s == nil {
+		return nil
+	}
+	for _, searcher := range []Searcher{s.keyword, s.vector} {
+		if deleter, ok := searcher.(Deleter); ok {
+			if err := deleter.Delete(ctx, ids...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close 关闭实现了 Close() error 的子检索器
+func (s *HybridSearcher) Close() error {
+	if s == nil {
+		return nil
+	}
+	var errs []error
+	for _, searcher := range []Searcher{s.keyword, s.vector} {
+		if closer, ok := searcher.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func rrfFuse(keywordHits, vectorHits []*SearchHit, rrfK, limit int) []*SearchHit {
 	if rrfK <= 0 {
 		rrfK = 60
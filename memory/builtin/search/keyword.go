@@ -7,11 +7,28 @@ import (
 )
 
 type KeywordSearcher struct {
-	store KeywordStore
+	store     KeywordStore
+	tokenizer Tokenizer
 }
 
-func NewKeywordSearcher(store KeywordStore) *KeywordSearcher {
-	return &KeywordSearcher{store: store}
+// KeywordSearcherOption KeywordSearcher 的可选配置
+type KeywordSearcherOption func(*KeywordSearcher)
+
+// WithKeywordTokenizer 指定关键词推断与匹配使用的分词器，默认使用 DefaultTokenizer()
+func WithKeywordTokenizer(tokenizer Tokenizer) KeywordSearcherOption {
+	return func(s *KeywordSearcher) {
+		s.tokenizer = tokenizer
+	}
+}
+
+func NewKeywordSearcher(store KeywordStore, opts ...KeywordSearcherOption) *KeywordSearcher {
+	s := &KeywordSearcher{store: store}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	return s
 }
 
 func (s *KeywordSearcher) Search(ctx context.Context, q *SearchQuery) ([]*SearchHit, error) {
@@ -28,12 +45,16 @@ func (s *KeywordSearcher) Search(ctx context.Context, q *SearchQuery) ([]*Search
 	}
 	query.Match = NormalizeMatch(query.Match)
 	if len(query.Keywords) == 0 {
-		query.Keywords = InferKeywords(query.Query)
+		query.Keywords = InferKeywordsWith(s.tokenizer, query.Query)
 	}
 	if len(query.Keywords) == 0 {
 		return nil, errors.New("keywords are required")
 	}
 
+	if ranked, ok := s.store.(RankedKeywordStore); ok {
+		return s.searchRanked(ctx, ranked, &query)
+	}
+
 	msgs, err := s.store.SearchMessagesByKeywords(ctx, &query)
 	if err != nil {
 		return nil, err
@@ -42,7 +63,7 @@ func (s *KeywordSearcher) Search(ctx context.Context, q *SearchQuery) ([]*Search
 	hits := make([]*SearchHit, 0, len(msgs))
 	for _, msg := range msgs {
 		text := SearchText(msg)
-		matched, ok := MatchesKeywords(text, query.Keywords, query.Match)
+		matched, ok := MatchesKeywordsWith(s.tokenizer, text, query.Keywords, query.Match)
 		if !ok {
 			continue
 		}
@@ -55,6 +76,32 @@ func (s *KeywordSearcher) Search(ctx context.Context, q *SearchQuery) ([]*Search
 	return hits, nil
 }
 
+// searchRanked 使用存储层全文索引给出的分数，不再按子串二次过滤，
+// 因为分词后的匹配语义（词干、分词）与子串匹配并不一致。
+func (s *KeywordSearcher) searchRanked(ctx context.Context, store RankedKeywordStore, query *SearchQuery) ([]*SearchHit, error) {
+	ranked, err := store.SearchMessagesRanked(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]*SearchHit, 0, len(ranked))
+	for _, hit := range ranked {
+		if hit == nil || hit.Message == nil {
+			continue
+		}
+		snippet := hit.Snippet
+		if snippet == "" {
+			snippet = buildSnippet(SearchText(hit.Message), query.Keywords)
+		}
+		hits = append(hits, &SearchHit{
+			Message: CloneMessage(hit.Message),
+			Score:   hit.Score,
+			Snippet: snippet,
+		})
+	}
+	return hits, nil
+}
+
 func (s *KeywordSearcher) Index(context.Context, *Message) error {
 	return nil
 }
@@ -69,3 +69,39 @@ func TestKeywordSearcherSearchBuildsSnippetAndScore(t *testing.T) {
 		t.Fatalf("snippet should not be empty")
 	}
 }
+
+type fakeRankedKeywordStore struct {
+	fakeKeywordStore
+	hits []*SearchHit
+}
+
+func (s *fakeRankedKeywordStore) SearchMessagesRanked(context.Context, *SearchQuery) ([]*SearchHit, error) {
+	return s.hits, nil
+}
+
+func TestKeywordSearcherUsesRankedStoreScores(t *testing.T) {
+	searcher := NewKeywordSearcher(&fakeRankedKeywordStore{
+		hits: []*SearchHit{
+			{Message: &Message{ID: "m2", Content: "running the deploy pipeline"}, Score: 3.5},
+			{Message: &Message{ID: "m1", Content: "deploy finished"}, Score: 1.25},
+		},
+	})
+
+	hits, err := searcher.Search(context.Background(), &SearchQuery{
+		SessionID: "s1",
+		UserID:    "u1",
+		Keywords:  []string{"run"},
+	})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("len(hits) = %d, want 2", len(hits))
+	}
+	if hits[0].Message.ID != "m2" || hits[0].Score != 3.5 {
+		t.Fatalf("unexpected first hit: id=%s score=%v", hits[0].Message.ID, hits[0].Score)
+	}
+	if hits[1].Snippet == "" {
+		t.Fatalf("snippet should be filled for ranked hits")
+	}
+}
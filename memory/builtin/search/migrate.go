@@ -0,0 +1,169 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const defaultMigrateBatchSize = 500
+
+// VectorIterator 可按批遍历已存储向量的 VectorStore，用作迁移源
+type VectorIterator interface {
+	Iterate(ctx context.Context, batchSize int, fn func(msg *Message, vector []float64) error) error
+}
+
+// MigrateResult 迁移统计
+type MigrateResult struct {
+	Migrated int
+	// Skipped 开启 ContinueOnError 时写入失败被跳过的条数
+	Skipped int
+}
+
+// MigrateOptions 向量迁移选项
+type MigrateOptions struct {
+	// BatchSize 每批读取条数，默认 500
+	BatchSize int
+	// ContinueOnError 写入单条失败时跳过并继续，默认遇错即停
+	ContinueOnError bool
+}
+
+// MigrateVectors 将 src 中已有的向量逐条写入 dst，典型用法是把 GormVectorStore 的 blob 向量
+// 迁移到 PgVectorStore 或 Milvus。写入为 Upsert，重复执行是安全的。
+func MigrateVectors(ctx context.Context, src VectorIterator, dst VectorStore, opts MigrateOptions) (MigrateResult, error) {
+	var result MigrateResult
+	if src == nil {
+		return result, errors.New("migrate source is required")
+	}
+	if dst == nil {
+		return result, errors.New("migrate destination is required")
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultMigrateBatchSize
+	}
+
+	err := src.Iterate(ctx, batchSize, func(msg *Message, vector []float64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := dst.Upsert(ctx, msg, vector); err != nil {
+			if opts.ContinueOnError {
+				result.Skipped++
+				return nil
+			}
+			return fmt.Errorf("migrate vector %s: %w", msg.ID, err)
+		}
+		result.Migrated++
+		return nil
+	})
+	return result, err
+}
+
+// Iterate 按 id 顺序分批遍历已写入向量的消息，实现 VectorIterator
+func (s *GormVectorStore) Iterate(ctx context.Context, batchSize int, fn func(msg *Message, vector []float64) error) error {
+	return s.iterate(ctx, batchSize, "", fn)
+}
+
+// iterate userID 非空时只遍历该用户的消息
+func (s *GormVectorStore) iterate(ctx context.Context, batchSize int, userID string, fn func(msg *Message, vector []float64) error) error {
+	if s == nil {
+		return errors.New("gorm vector store is nil")
+	}
+	if fn == nil {
+		return errors.New("iterate callback is required")
+	}
+	if batchSize <= 0 {
+		batchSize = defaultMigrateBatchSize
+	}
+
+	lastID := ""
+	for {
+		var records []gormVectorRecord
+		query := s.db.WithContext(ctx).
+			Table(s.tableName).
+			Select("id, session_id, user_id, role, content, parts, created_at, embedding, embedding_dim").
+			Where("embedding IS NOT NULL AND embedding_dim > 0").
+			Where("id > ?", lastID)
+		if userID != "" {
+			query = query.Where("user_id = ?", userID)
+		}
+		err := query.Order("id ASC").Limit(batchSize).Find(&records).Error
+		if err != nil {
+			return fmt.Errorf("iterate vectors: %w", err)
+		}
+
+		for _, record := range records {
+			vector, err := decodeVector(record.Embedding)
+			if err != nil || len(vector) == 0 {
+				continue
+			}
+			msg := &Message{
+				ID:        record.ID,
+				SessionID: record.SessionID,
+				UserID:    record.UserID,
+				Role:      record.Role,
+				Content:   record.Content,
+				Parts:     decodeParts(record.Parts),
+				CreatedAt: record.CreatedAt,
+			}
+			if err := fn(msg, vector); err != nil {
+				return err
+			}
+		}
+
+		if len(records) < batchSize {
+			return nil
+		}
+		lastID = records[len(records)-1].ID
+	}
+}
+
+// vectorIDs 返回用户已写入向量的消息 id
+func (s *GormVectorStore) vectorIDs(ctx context.Context, userID string) ([]string, error) {
+	var ids []string
+	err := s.db.WithContext(ctx).
+		Table(s.tableName).
+		Where("embedding IS NOT NULL AND embedding_dim > 0").
+		Where("user_id = ?", userID).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("list vector ids: %w", err)
+	}
+	return ids, nil
+}
+
+// fetchVectors 按 id 分批读取消息及其向量
+func (s *GormVectorStore) fetchVectors(ctx context.Context, ids []string, fn func(msg *Message, vector []float64) error) error {
+	for start := 0; start < len(ids); start += defaultMigrateBatchSize {
+		end := min(start+defaultMigrateBatchSize, len(ids))
+		var records []gormVectorRecord
+		err := s.db.WithContext(ctx).
+			Table(s.tableName).
+			Select("id, session_id, user_id, role, content, parts, created_at, embedding, embedding_dim").
+			Where("id IN ?", ids[start:end]).
+			Find(&records).Error
+		if err != nil {
+			return fmt.Errorf("fetch vectors: %w", err)
+		}
+		for _, record := range records {
+			vector, err := decodeVector(record.Embedding)
+			if err != nil || len(vector) == 0 {
+				continue
+			}
+			msg := &Message{
+				ID:        record.ID,
+				SessionID: record.SessionID,
+				UserID:    record.UserID,
+				Role:      record.Role,
+				Content:   record.Content,
+				Parts:     decodeParts(record.Parts),
+				CreatedAt: record.CreatedAt,
+			}
+			if err := fn(msg, vector); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
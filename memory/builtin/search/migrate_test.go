@@ -0,0 +1,58 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeVectorIterator struct {
+	items []*Message
+}
+
+func (it *fakeVectorIterator) Iterate(_ context.Context, _ int, fn func(msg *Message, vector []float64) error) error {
+	for i, msg := range it.items {
+		if err := fn(msg, []float64{float64(i), 1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type recordingVectorStore struct {
+	vectors map[string][]float64
+	reject  string
+}
+
+func (s *recordingVectorStore) Upsert(_ context.Context, msg *Message, vector []float64) error {
+	if msg.ID == s.reject {
+		return errors.New("rejected")
+	}
+	s.vectors[msg.ID] = vector
+	return nil
+}
+
+func (s *recordingVectorStore) Search(context.Context, *SearchQuery, []float64, int) ([]*SearchHit, error) {
+	return nil, nil
+}
+
+func TestMigrateVectors(t *testing.T) {
+	src := &fakeVectorIterator{items: []*Message{{ID: "m1"}, {ID: "m2"}, {ID: "m3"}}}
+
+	dst := &recordingVectorStore{vectors: map[string][]float64{}, reject: "m2"}
+	if _, err := MigrateVectors(context.Background(), src, dst, MigrateOptions{}); err == nil {
+		t.Fatal("expected migration to stop on upsert error")
+	}
+
+	dst = &recordingVectorStore{vectors: map[string][]float64{}, reject: "m2"}
+	result, err := MigrateVectors(context.Background(), src, dst, MigrateOptions{ContinueOnError: true})
+	if err != nil {
+		t.Fatalf("MigrateVectors() error = %v", err)
+	}
+	if result.Migrated != 2 || result.Skipped != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got := dst.vectors["m3"]; len(got) != 2 || got[0] != 2 {
+		t.Fatalf("unexpected vector for m3: %v", got)
+	}
+}
@@ -0,0 +1,292 @@
+// Package milvus 提供基于 Milvus 的记忆检索 VectorStore 实现
+package milvus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	dbmilvus "github.com/CoolBanHub/aggo/database/milvus"
+	"github.com/CoolBanHub/aggo/memory/builtin/search"
+	"github.com/cloudwego/eino/schema"
+	"github.com/milvus-io/milvus/client/v2/entity"
+	"github.com/milvus-io/milvus/client/v2/index"
+	"github.com/milvus-io/milvus/client/v2/milvusclient"
+)
+
+const (
+	defaultCollectionName = "aggo_mem_message_vectors"
+
+	fieldID        = "id"
+	fieldSessionID = "session_id"
+	fieldUserID    = "user_id"
+	fieldRole      = "role"
+	fieldContent   = "content"
+	fieldParts     = "parts"
+	fieldCreatedAt = "created_at"
+	fieldVector    = "vector"
+
+	maxIDLength   = 255
+	maxRoleLength = 32
+	// maxTextLength Milvus VarChar 字段的最大字节数
+	maxTextLength = 65535
+)
+
+var outputFields = []string{fieldSessionID, fieldUserID, fieldRole, fieldContent, fieldParts, fieldCreatedAt}
+
+// VectorStore 基于 Milvus 的 search.VectorStore。
+// 向量与消息内容一同写入集合，检索时会话、用户、角色与时间过滤条件以表达式形式下推到 Milvus。
+type VectorStore struct {
+	client     *milvusclient.Client
+	collection string
+	dimension  int
+}
+
+var _ search.VectorStore = (*VectorStore)(nil)
+
+// NewVectorStore 创建 Milvus 向量存储，集合不存在时自动创建并加载。
+// 配置沿用 database/milvus 的 MilvusConfig：Client 与 EmbeddingDim 必填，CollectionName 默认 aggo_mem_message_vectors；
+// 向量由记忆检索层计算后传入，Embedding 无需设置。
+func NewVectorStore(ctx context.Context, cfg dbmilvus.MilvusConfig) (*VectorStore, error) {
+	s, err := newVectorStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureCollection(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newVectorStore(cfg dbmilvus.MilvusConfig) (*VectorStore, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	collection := strings.TrimSpace(cfg.CollectionName)
+	if collection == "" {
+		collection = defaultCollectionName
+	}
+	return &VectorStore{client: cfg.Client, collection: collection, dimension: cfg.EmbeddingDim}, nil
+}
+
+func (s *VectorStore) collectionSchema() *entity.Schema {
+	return entity.NewSchema().
+		WithName(s.collection).
+		WithField(entity.NewField().WithName(fieldID).WithDataType(entity.FieldTypeVarChar).WithIsPrimaryKey(true).WithMaxLength(maxIDLength)).
+		WithField(entity.NewField().WithName(fieldSessionID).WithDataType(entity.FieldTypeVarChar).WithMaxLength(maxIDLength)).
+		WithField(entity.NewField().WithName(fieldUserID).WithDataType(entity.FieldTypeVarChar).WithMaxLength(maxIDLength)).
+		WithField(entity.NewField().WithName(fieldRole).WithDataType(entity.FieldTypeVarChar).WithMaxLength(maxRoleLength)).
+		WithField(entity.NewField().WithName(fieldContent).WithDataType(entity.FieldTypeVarChar).WithMaxLength(maxTextLength)).
+		WithField(entity.NewField().WithName(fieldParts).WithDataType(entity.FieldTypeVarChar).WithMaxLength(maxTextLength)).
+		WithField(entity.NewField().WithName(fieldCreatedAt).WithDataType(entity.FieldTypeInt64)).
+		WithField(entity.NewField().WithName(fieldVector).WithDataType(entity.FieldTypeFloatVector).WithDim(int64(s.dimension)))
+}
+
+func (s *VectorStore) ensureCollection(ctx context.Context) error {
+	has, err := s.client.HasCollection(ctx, milvusclient.NewHasCollectionOption(s.collection))
+	if err != nil {
+		return fmt.Errorf("检查集合失败: %w", err)
+	}
+	if !has {
+		option := milvusclient.NewCreateCollectionOption(s.collection, s.collectionSchema()).
+			WithIndexOptions(milvusclient.NewCreateIndexOption(s.collection, fieldVector, index.NewHNSWIndex(entity.COSINE, 16, 200)))
+		if err := s.client.CreateCollection(ctx, option); err != nil {
+			return fmt.Errorf("创建集合失败: %w", err)
+		}
+	}
+
+	task, err := s.client.LoadCollection(ctx, milvusclient.NewLoadCollectionOption(s.collection))
+	if err != nil {
+		return fmt.Errorf("加载集合失败: %w", err)
+	}
+	if err := task.Await(ctx); err != nil {
+		return fmt.Errorf("加载集合失败: %w", err)
+	}
+	return nil
+}
+
+func (s *VectorStore) Upsert(ctx context.Context, msg *search.Message, vector []float64) error {
+	if s == nil {
+		return errors.New("milvus vector store is nil")
+	}
+	if msg == nil || strings.TrimSpace(msg.ID) == "" {
+		return errors.New("message id is required")
+	}
+	if len(vector) != s.dimension {
+		return fmt.Errorf("vector dimension mismatch: expected %d, got %d", s.dimension, len(vector))
+	}
+
+	parts := ""
+	if len(msg.Parts) > 0 {
+		data, err := json.Marshal(msg.Parts)
+		if err != nil {
+			return fmt.Errorf("marshal message parts: %w", err)
+		}
+		// 超长的多模态内容无法完整写入 VarChar，只保留文本
+		if len(data) <= maxTextLength {
+			parts = string(data)
+		}
+	}
+	createdAt := msg.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	option := milvusclient.NewColumnBasedInsertOption(s.collection).
+		WithVarcharColumn(fieldID, []string{msg.ID}).
+		WithVarcharColumn(fieldSessionID, []string{msg.SessionID}).
+		WithVarcharColumn(fieldUserID, []string{msg.UserID}).
+		WithVarcharColumn(fieldRole, []string{msg.Role}).
+		WithVarcharColumn(fieldContent, []string{truncateBytes(msg.Content, maxTextLength)}).
+		WithVarcharColumn(fieldParts, []string{parts}).
+		WithInt64Column(fieldCreatedAt, []int64{createdAt.UnixMilli()}).
+		WithFloatVectorColumn(fieldVector, s.dimension, [][]float32{toFloat32(vector)})
+	if _, err := s.client.Upsert(ctx, option); err != nil {
+		return fmt.Errorf("upsert vector: %w", err)
+	}
+	return nil
+}
+
+func (s *VectorStore) Search(ctx context.Context, q *search.SearchQuery, vector []float64, limit int) ([]*search.SearchHit, error) {
+	if s == nil {
+		return nil, errors.New("milvus vector store is nil")
+	}
+	if q == nil {
+		return nil, errors.New("search query is nil")
+	}
+	if len(vector) != s.dimension {
+		return nil, fmt.Errorf("vector dimension mismatch: expected %d, got %d", s.dimension, len(vector))
+	}
+	if limit <= 0 {
+		limit = 5
+	}
+
+	expr, params := buildFilter(q)
+	option := milvusclient.NewSearchOption(s.collection, limit, []entity.Vector{entity.FloatVector(toFloat32(vector))}).
+		WithANNSField(fieldVector).
+		WithFilter(expr).
+		WithOutputFields(outputFields...)
+	for key, value := range params {
+		option = option.WithTemplateParam(key, value)
+	}
+
+	results, err := s.client.Search(ctx, option)
+	if err != nil {
+		return nil, fmt.Errorf("search vectors: %w", err)
+	}
+
+	var hits []*search.SearchHit
+	for _, rs := range results {
+		if rs.Err != nil {
+			return nil, fmt.Errorf("search vectors: %w", rs.Err)
+		}
+		for i := 0; i < rs.ResultCount; i++ {
+			hit, err := resultHit(&rs, i)
+			if err != nil {
+				return nil, err
+			}
+			hits = append(hits, hit)
+		}
+	}
+	return hits, nil
+}
+
+// Delete 删除指定消息的向量
+func (s *VectorStore) Delete(ctx context.Context, ids ...string) error {
+	if s == nil {
+		return errors.New("milvus vector store is nil")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := s.client.Delete(ctx, milvusclient.NewDeleteOption(s.collection).WithStringIDs(fieldID, ids))
+	return err
+}
+
+func resultHit(rs *milvusclient.ResultSet, i int) (*search.SearchHit, error) {
+	id, err := rs.IDs.GetAsString(i)
+	if err != nil {
+		return nil, fmt.Errorf("read result id: %w", err)
+	}
+	msg := &search.Message{ID: id}
+	strFields := map[string]*string{
+		fieldSessionID: &msg.SessionID,
+		fieldUserID:    &msg.UserID,
+		fieldRole:      &msg.Role,
+		fieldContent:   &msg.Content,
+	}
+	for name, dst := range strFields {
+		if col := rs.GetColumn(name); col != nil {
+			if *dst, err = col.GetAsString(i); err != nil {
+				return nil, fmt.Errorf("read result field %s: %w", name, err)
+			}
+		}
+	}
+	if col := rs.GetColumn(fieldParts); col != nil {
+		raw, err := col.GetAsString(i)
+		if err == nil && raw != "" {
+			var parts []schema.MessageInputPart
+			if json.Unmarshal([]byte(raw), &parts) == nil {
+				msg.Parts = parts
+			}
+		}
+	}
+	if col := rs.GetColumn(fieldCreatedAt); col != nil {
+		if ms, err := col.GetAsInt64(i); err == nil {
+			msg.CreatedAt = time.UnixMilli(ms)
+		}
+	}
+
+	score := 0.0
+	if i < len(rs.Scores) {
+		score = float64(rs.Scores[i])
+	}
+	return &search.SearchHit{Message: msg, Score: score}, nil
+}
+
+// buildFilter 将查询条件转换为 Milvus 过滤表达式，取值通过模板参数传入，避免拼接转义问题
+func buildFilter(q *search.SearchQuery) (string, map[string]any) {
+	clauses := []string{fieldUserID + " == {user_id}"}
+	params := map[string]any{"user_id": q.UserID}
+	if q.SessionID != "" {
+		clauses = append(clauses, fieldSessionID+" == {session_id}")
+		params["session_id"] = q.SessionID
+	}
+	if role := strings.TrimSpace(q.Role); role != "" {
+		clauses = append(clauses, fieldRole+" == {role}")
+		params["role"] = role
+	}
+	if q.Since != nil {
+		clauses = append(clauses, fieldCreatedAt+" >= {since}")
+		params["since"] = q.Since.UnixMilli()
+	}
+	if q.Until != nil {
+		clauses = append(clauses, fieldCreatedAt+" <= {until}")
+		params["until"] = q.Until.UnixMilli()
+	}
+	return strings.Join(clauses, " && "), params
+}
+
+func toFloat32(vector []float64) []float32 {
+	out := make([]float32, len(vector))
+	for i, v := range vector {
+		out[i] = float32(v)
+	}
+	return out
+}
+
+// truncateBytes 按字节截断字符串，不截断多字节字符
+func truncateBytes(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = s[:limit]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
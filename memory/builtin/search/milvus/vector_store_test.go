@@ -0,0 +1,201 @@
+package milvus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	dbmilvus "github.com/CoolBanHub/aggo/database/milvus"
+	"github.com/CoolBanHub/aggo/memory/builtin/search"
+	"github.com/cloudwego/eino/schema"
+	"github.com/milvus-io/milvus/client/v2/column"
+	"github.com/milvus-io/milvus/client/v2/entity"
+	"github.com/milvus-io/milvus/client/v2/milvusclient"
+)
+
+func TestNewVectorStoreConfig(t *testing.T) {
+	client := &milvusclient.Client{}
+	cases := []struct {
+		name       string
+		cfg        dbmilvus.MilvusConfig
+		wantErr    string
+		collection string
+	}{
+		{name: "missing client", cfg: dbmilvus.MilvusConfig{EmbeddingDim: 4}, wantErr: "client"},
+		{name: "missing dimension", cfg: dbmilvus.MilvusConfig{Client: client}, wantErr: "维度"},
+		{name: "default collection", cfg: dbmilvus.MilvusConfig{Client: client, EmbeddingDim: 4}, collection: defaultCollectionName},
+		{name: "custom collection", cfg: dbmilvus.MilvusConfig{Client: client, EmbeddingDim: 4, CollectionName: " mem_vectors "}, collection: "mem_vectors"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := newVectorStore(tc.cfg)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newVectorStore: %v", err)
+			}
+			if s.collection != tc.collection || s.dimension != 4 || s.client != client {
+				t.Fatalf("unexpected store: %+v", s)
+			}
+		})
+	}
+}
+
+func TestCollectionSchema(t *testing.T) {
+	s := &VectorStore{collection: "mem_vectors", dimension: 8}
+	sch := s.collectionSchema()
+	if sch.CollectionName != "mem_vectors" {
+		t.Fatalf("collection = %q", sch.CollectionName)
+	}
+
+	fields := make(map[string]*entity.Field, len(sch.Fields))
+	for _, field := range sch.Fields {
+		fields[field.Name] = field
+	}
+	for _, name := range append([]string{fieldID, fieldVector}, outputFields...) {
+		if fields[name] == nil {
+			t.Fatalf("schema is missing field %s", name)
+		}
+	}
+	if !fields[fieldID].PrimaryKey || fields[fieldID].DataType != entity.FieldTypeVarChar {
+		t.Fatalf("id should be a varchar primary key: %+v", fields[fieldID])
+	}
+	if dim, err := fields[fieldVector].GetDim(); err != nil || dim != 8 {
+		t.Fatalf("vector dim = %d err=%v", dim, err)
+	}
+	if fields[fieldCreatedAt].DataType != entity.FieldTypeInt64 {
+		t.Fatalf("created_at should be int64: %+v", fields[fieldCreatedAt])
+	}
+}
+
+func TestVectorStoreRejectsInvalidInput(t *testing.T) {
+	ctx := context.Background()
+	// 校验失败时不会访问 client，因此这里不需要真实连接
+	s := &VectorStore{collection: defaultCollectionName, dimension: 2}
+	cases := []struct {
+		name string
+		run  func() error
+	}{
+		{name: "upsert nil message", run: func() error { return s.Upsert(ctx, nil, []float64{1, 0}) }},
+		{name: "upsert empty id", run: func() error { return s.Upsert(ctx, &search.Message{ID: " "}, []float64{1, 0}) }},
+		{name: "upsert dimension mismatch", run: func() error { return s.Upsert(ctx, &search.Message{ID: "m1"}, []float64{1}) }},
+		{name: "search nil query", run: func() error {
+			_, err := s.Search(ctx, nil, []float64{1, 0}, 5)
+			return err
+		}},
+		{name: "search dimension mismatch", run: func() error {
+			_, err := s.Search(ctx, &search.SearchQuery{UserID: "u1"}, []float64{1, 0, 0}, 5)
+			return err
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.run(); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+	if err := s.Delete(ctx); err != nil {
+		t.Fatalf("deleting nothing should be a no-op, got %v", err)
+	}
+}
+
+func TestBuildFilter(t *testing.T) {
+	since := time.UnixMilli(1000)
+	until := time.UnixMilli(2000)
+	cases := []struct {
+		name   string
+		query  *search.SearchQuery
+		expr   string
+		params map[string]any
+	}{
+		{
+			name:   "user only",
+			query:  &search.SearchQuery{UserID: "u1", Role: " "},
+			expr:   `user_id == {user_id}`,
+			params: map[string]any{"user_id": "u1"},
+		},
+		{
+			name:   "session and role",
+			query:  &search.SearchQuery{UserID: `u"1`, SessionID: "s1", Role: " user "},
+			expr:   `user_id == {user_id} && session_id == {session_id} && role == {role}`,
+			params: map[string]any{"user_id": `u"1`, "session_id": "s1", "role": "user"},
+		},
+		{
+			name:   "time range",
+			query:  &search.SearchQuery{UserID: "u1", Since: &since, Until: &until},
+			expr:   `user_id == {user_id} && created_at >= {since} && created_at <= {until}`,
+			params: map[string]any{"user_id": "u1", "since": int64(1000), "until": int64(2000)},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expr, params := buildFilter(tc.query)
+			if expr != tc.expr {
+				t.Fatalf("expr = %q, want %q", expr, tc.expr)
+			}
+			if len(params) != len(tc.params) {
+				t.Fatalf("params = %v, want %v", params, tc.params)
+			}
+			for key, want := range tc.params {
+				if params[key] != want {
+					t.Fatalf("params[%s] = %v, want %v", key, params[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestResultHit(t *testing.T) {
+	rs := &milvusclient.ResultSet{
+		ResultCount: 2,
+		IDs:         column.NewColumnVarChar(fieldID, []string{"m1", "m2"}),
+		Fields: milvusclient.DataSet{
+			column.NewColumnVarChar(fieldSessionID, []string{"s1", "s2"}),
+			column.NewColumnVarChar(fieldUserID, []string{"u1", "u1"}),
+			column.NewColumnVarChar(fieldRole, []string{"user", "assistant"}),
+			column.NewColumnVarChar(fieldContent, []string{"看图", "好的"}),
+			column.NewColumnVarChar(fieldParts, []string{`[{"type":"text","text":"看图"}]`, "not json"}),
+			column.NewColumnInt64(fieldCreatedAt, []int64{1000, 2000}),
+		},
+		Scores: []float32{0.9},
+	}
+
+	first, err := resultHit(rs, 0)
+	if err != nil {
+		t.Fatalf("resultHit(0): %v", err)
+	}
+	msg := first.Message
+	if msg.ID != "m1" || msg.SessionID != "s1" || msg.UserID != "u1" || msg.Role != "user" || msg.Content != "看图" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if len(msg.Parts) != 1 || msg.Parts[0].Type != schema.ChatMessagePartTypeText || msg.Parts[0].Text != "看图" {
+		t.Fatalf("unexpected parts: %+v", msg.Parts)
+	}
+	if !msg.CreatedAt.Equal(time.UnixMilli(1000)) || first.Score < 0.89 || first.Score > 0.91 {
+		t.Fatalf("unexpected hit: createdAt=%v score=%v", msg.CreatedAt, first.Score)
+	}
+
+	// 无法解析的 parts 被忽略，缺失的分数按 0 处理
+	second, err := resultHit(rs, 1)
+	if err != nil {
+		t.Fatalf("resultHit(1): %v", err)
+	}
+	if second.Message.ID != "m2" || second.Message.Parts != nil || second.Score != 0 {
+		t.Fatalf("unexpected hit: %+v score=%v", second.Message, second.Score)
+	}
+}
+
+func TestTruncateBytes(t *testing.T) {
+	if got := truncateBytes("你好", 4); got != "你" {
+		t.Fatalf("truncateBytes() = %q", got)
+	}
+	if got := truncateBytes("abc", 10); got != "abc" {
+		t.Fatalf("truncateBytes() = %q", got)
+	}
+}
@@ -0,0 +1,253 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CoolBanHub/aggo/database/postgres"
+	"github.com/CoolBanHub/aggo/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPgVectorTableName = "aggo_mem_message_vectors"
+
+	PgVectorIndexHNSW    = "hnsw"
+	PgVectorIndexIVFFlat = "ivfflat"
+	PgVectorIndexNone    = "none"
+)
+
+// PgVectorConfig PgVectorStore 配置
+type PgVectorConfig struct {
+	DB *gorm.DB
+	// TableName 向量表名，支持 schema.table，默认 aggo_mem_message_vectors
+	TableName string
+	// Dimension 向量维度，必填
+	Dimension int
+	// IndexType 近似索引类型：hnsw（默认）、ivfflat 或 none
+	IndexType string
+}
+
+// PgVectorStore 基于 pgvector 的 VectorStore。
+// 消息与向量存放在独立的表中，相似度在数据库内计算，会话、用户、角色与时间过滤条件随查询下推。
+type PgVectorStore struct {
+	db        *gorm.DB
+	tableName string
+	dimension int
+}
+
+// NewPgVectorStore 创建 pgvector 向量存储，并确保扩展、表和索引存在
+func NewPgVectorStore(cfg PgVectorConfig) (*PgVectorStore, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("db is required")
+	}
+	if cfg.Dimension <= 0 {
+		return nil, errors.New("vector dimension must be greater than 0")
+	}
+	tableName := strings.TrimSpace(cfg.TableName)
+	if tableName == "" {
+		tableName = defaultPgVectorTableName
+	}
+	if err := postgres.ValidateIdentifier(tableName); err != nil {
+		return nil, err
+	}
+	indexType := strings.ToLower(strings.TrimSpace(cfg.IndexType))
+	switch indexType {
+	case "":
+		indexType = PgVectorIndexHNSW
+	case PgVectorIndexHNSW, PgVectorIndexIVFFlat, PgVectorIndexNone:
+	default:
+		return nil, fmt.Errorf("unsupported pgvector index type %q", cfg.IndexType)
+	}
+
+	s := &PgVectorStore{db: cfg.DB, tableName: tableName, dimension: cfg.Dimension}
+	if err := s.migrate(indexType); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *PgVectorStore) migrate(indexType string) error {
+	if err := s.db.Exec("CREATE EXTENSION IF NOT EXISTS vector").Error; err != nil {
+		return fmt.Errorf("安装pgvector扩展失败: %w，请确保已安装pgvector扩展", err)
+	}
+
+	table := postgres.QuoteIdentifier(s.tableName)
+	createTableSQL := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id VARCHAR(255) PRIMARY KEY,
+			session_id VARCHAR(255) NOT NULL,
+			user_id VARCHAR(255) NOT NULL,
+			role VARCHAR(32) NOT NULL,
+			content TEXT,
+			parts TEXT,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			embedding vector(%d) NOT NULL
+		)`, table, s.dimension)
+	if err := s.db.Exec(createTableSQL).Error; err != nil {
+		return fmt.Errorf("创建向量表失败: %w", err)
+	}
+
+	baseName := strings.ReplaceAll(s.tableName, ".", "_")
+	scopeIndexSQL := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (user_id, session_id, created_at)`,
+		postgres.QuoteIdentifier(baseName+"_scope_idx"), table)
+	if err := s.db.Exec(scopeIndexSQL).Error; err != nil {
+		return fmt.Errorf("创建向量表过滤索引失败: %w", err)
+	}
+
+	var vectorIndexSQL string
+	switch indexType {
+	case PgVectorIndexHNSW:
+		vectorIndexSQL = fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s USING hnsw (embedding vector_cosine_ops)`,
+			postgres.QuoteIdentifier(baseName+"_embedding_idx"), table)
+	case PgVectorIndexIVFFlat:
+		vectorIndexSQL = fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s USING ivfflat (embedding vector_cosine_ops) WITH (lists = 100)`,
+			postgres.QuoteIdentifier(baseName+"_embedding_idx"), table)
+	default:
+		return nil
+	}
+	if err := s.db.Exec(vectorIndexSQL).Error; err != nil {
+		return fmt.Errorf("创建向量索引失败: %w", err)
+	}
+	return nil
+}
+
+func (s *PgVectorStore) Upsert(ctx context.Context, msg *Message, vector []float64) error {
+	if s == nil {
+		return errors.New("pgvector store is nil")
+	}
+	if msg == nil || strings.TrimSpace(msg.ID) == "" {
+		return errors.New("message id is required")
+	}
+	if len(vector) != s.dimension {
+		return fmt.Errorf("vector dimension mismatch: expected %d, got %d", s.dimension, len(vector))
+	}
+
+	parts, err := encodeParts(msg)
+	if err != nil {
+		return err
+	}
+	createdAt := msg.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	upsertSQL := fmt.Sprintf(`
+		INSERT INTO %s (id, session_id, user_id, role, content, parts, created_at, embedding)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?::vector)
+		ON CONFLICT (id) DO UPDATE SET
+			session_id = EXCLUDED.session_id,
+			user_id = EXCLUDED.user_id,
+			role = EXCLUDED.role,
+			content = EXCLUDED.content,
+			parts = EXCLUDED.parts,
+			created_at = EXCLUDED.created_at,
+			embedding = EXCLUDED.embedding
+	`, postgres.QuoteIdentifier(s.tableName))
+
+	err = s.db.WithContext(ctx).Exec(upsertSQL,
+		msg.ID, msg.SessionID, msg.UserID, msg.Role, msg.Content, parts, createdAt,
+		utils.Vector64ToString(vector)).Error
+	if err != nil {
+		return fmt.Errorf("upsert vector: %w", err)
+	}
+	return nil
+}
+
+func (s *PgVectorStore) Search(ctx context.Context, q *SearchQuery, vector []float64, limit int) ([]*SearchHit, error) {
+	if s == nil {
+		return nil, errors.New("pgvector store is nil")
+	}
+	if q == nil {
+		return nil, errors.New("search query is nil")
+	}
+	if len(vector) != s.dimension {
+		return nil, fmt.Errorf("vector dimension mismatch: expected %d, got %d", s.dimension, len(vector))
+	}
+	if limit <= 0 {
+		limit = 5
+	}
+
+	vectorStr := utils.Vector64ToString(vector)
+	query := s.db.WithContext(ctx).
+		Table(s.tableName).
+		Select("id, session_id, user_id, role, content, parts, created_at, (1 - (embedding <=> ?::vector)) AS score", vectorStr).
+		Where("user_id = ?", q.UserID)
+	if q.SessionID != "" {
+		query = query.Where("session_id = ?", q.SessionID)
+	}
+	if role := strings.TrimSpace(q.Role); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	if q.Since != nil {
+		query = query.Where("created_at >= ?", q.Since)
+	}
+	if q.Until != nil {
+		query = query.Where("created_at <= ?", q.Until)
+	}
+
+	var records []pgVectorRecord
+	// 按距离表达式排序才能命中向量索引；gorm 的 Order 不接受 gorm.Expr，需要直接构造 OrderBy 子句
+	err := query.Order(clause.OrderBy{Expression: clause.Expr{SQL: "(embedding <=> ?::vector) ASC", Vars: []any{vectorStr}}}).
+		Limit(limit).
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("search vectors: %w", err)
+	}
+
+	hits := make([]*SearchHit, 0, len(records))
+	for _, record := range records {
+		hits = append(hits, &SearchHit{
+			Message: &Message{
+				ID:        record.ID,
+				SessionID: record.SessionID,
+				UserID:    record.UserID,
+				Role:      record.Role,
+				Content:   record.Content,
+				Parts:     decodeParts(record.Parts),
+				CreatedAt: record.CreatedAt,
+			},
+			Score: record.Score,
+		})
+	}
+	return hits, nil
+}
+
+// Delete 删除指定消息的向量
+func (s *PgVectorStore) Delete(ctx context.Context, ids ...string) error {
+	if s == nil {
+		return errors.New("pgvector store is nil")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE id IN ?", postgres.QuoteIdentifier(s.tableName))
+	return s.db.WithContext(ctx).Exec(deleteSQL, ids).Error
+}
+
+type pgVectorRecord struct {
+	ID        string    `gorm:"column:id"`
+	SessionID string    `gorm:"column:session_id"`
+	UserID    string    `gorm:"column:user_id"`
+	Role      string    `gorm:"column:role"`
+	Content   string    `gorm:"column:content"`
+	Parts     string    `gorm:"column:parts"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	Score     float64   `gorm:"column:score"`
+}
+
+func encodeParts(msg *Message) (string, error) {
+	if len(msg.Parts) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(msg.Parts)
+	if err != nil {
+		return "", fmt.Errorf("marshal message parts: %w", err)
+	}
+	return string(data), nil
+}
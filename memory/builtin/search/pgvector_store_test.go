@@ -0,0 +1,164 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder 记录 DryRun 模式下生成的语句，无需真实的 PostgreSQL
+type sqlRecorder struct {
+	statements []string
+	vars       [][]any
+}
+
+func (r *sqlRecorder) record(db *gorm.DB) {
+	r.statements = append(r.statements, strings.Join(strings.Fields(db.Statement.SQL.String()), " "))
+	r.vars = append(r.vars, db.Statement.Vars)
+}
+
+func newDryRunPgVectorStore(t *testing.T, cfg PgVectorConfig) (*PgVectorStore, *sqlRecorder) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "dry.db")), &gorm.Config{Logger: logger.Discard, DryRun: true})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	recorder := &sqlRecorder{}
+	if err := db.Callback().Raw().After("gorm:raw").Register("test:record_raw", recorder.record); err != nil {
+		t.Fatalf("register raw callback: %v", err)
+	}
+	if err := db.Callback().Query().After("gorm:query").Register("test:record_query", recorder.record); err != nil {
+		t.Fatalf("register query callback: %v", err)
+	}
+	cfg.DB = db
+	store, err := NewPgVectorStore(cfg)
+	if err != nil {
+		t.Fatalf("NewPgVectorStore: %v", err)
+	}
+	return store, recorder
+}
+
+func TestPgVectorStoreMigrate(t *testing.T) {
+	_, recorder := newDryRunPgVectorStore(t, PgVectorConfig{TableName: "mem.vectors", Dimension: 3, IndexType: "IVFFlat"})
+	all := strings.Join(recorder.statements, "\n")
+	for _, want := range []string{
+		"CREATE EXTENSION IF NOT EXISTS vector",
+		`CREATE TABLE IF NOT EXISTS "mem"."vectors"`,
+		"embedding vector(3) NOT NULL",
+		`CREATE INDEX IF NOT EXISTS "mem_vectors_scope_idx" ON "mem"."vectors"`,
+		"USING ivfflat (embedding vector_cosine_ops)",
+	} {
+		if !strings.Contains(all, want) {
+			t.Fatalf("migration is missing %q:\n%s", want, all)
+		}
+	}
+}
+
+func TestPgVectorStoreRejectsInvalidConfig(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  PgVectorConfig
+	}{
+		{name: "invalid table name", cfg: PgVectorConfig{TableName: "vectors; DROP TABLE x", Dimension: 3}},
+		{name: "unsupported index", cfg: PgVectorConfig{Dimension: 3, IndexType: "flat"}},
+		{name: "missing dimension", cfg: PgVectorConfig{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.DB = &gorm.DB{}
+			if _, err := NewPgVectorStore(tc.cfg); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestPgVectorStoreSearchPushesDownFilters(t *testing.T) {
+	store, recorder := newDryRunPgVectorStore(t, PgVectorConfig{Dimension: 2})
+	since := time.UnixMilli(1000)
+	cases := []struct {
+		name     string
+		query    *SearchQuery
+		want     []string
+		excluded []string
+	}{
+		{
+			name:     "user scope",
+			query:    &SearchQuery{UserID: "u1"},
+			want:     []string{"user_id = ?", "ORDER BY (embedding <=> ?::vector) ASC", "LIMIT 5"},
+			excluded: []string{"session_id = ?", "role = ?", "created_at >="},
+		},
+		{
+			name:  "session role and time",
+			query: &SearchQuery{UserID: "u1", SessionID: "s1", Role: "user", Since: &since},
+			want:  []string{"user_id = ?", "session_id = ?", "role = ?", "created_at >= ?"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder.statements = nil
+			if _, err := store.Search(context.Background(), tc.query, []float64{1, 0}, 0); err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if len(recorder.statements) != 1 {
+				t.Fatalf("expected one query, got %v", recorder.statements)
+			}
+			sql := recorder.statements[0]
+			// 表名由方言引用，这里是 sqlite 的反引号，且只引用一次
+			if !strings.Contains(sql, "FROM `aggo_mem_message_vectors`") {
+				t.Fatalf("query should target the default table: %s", sql)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(sql, want) {
+					t.Fatalf("query is missing %q: %s", want, sql)
+				}
+			}
+			for _, excluded := range tc.excluded {
+				if strings.Contains(sql, excluded) {
+					t.Fatalf("query should not contain %q: %s", excluded, sql)
+				}
+			}
+		})
+	}
+}
+
+func TestPgVectorStoreUpsertAndDelete(t *testing.T) {
+	ctx := context.Background()
+	store, recorder := newDryRunPgVectorStore(t, PgVectorConfig{Dimension: 2, IndexType: PgVectorIndexNone})
+	recorder.statements, recorder.vars = nil, nil
+
+	if err := store.Upsert(ctx, &Message{ID: "m1", Content: "hi"}, []float64{1}); err == nil {
+		t.Fatal("expected dimension mismatch")
+	}
+	if err := store.Upsert(ctx, &Message{ID: "m1", SessionID: "s1", UserID: "u1", Role: "user", Content: "hi"}, []float64{1, 0}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if err := store.Delete(ctx); err != nil {
+		t.Fatalf("empty Delete: %v", err)
+	}
+	if err := store.Delete(ctx, "m1", "m2"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if len(recorder.statements) != 2 {
+		t.Fatalf("expected upsert and delete statements, got %v", recorder.statements)
+	}
+	if sql := recorder.statements[0]; !strings.Contains(sql, "ON CONFLICT (id) DO UPDATE") {
+		t.Fatalf("unexpected upsert: %s", sql)
+	}
+	if vars := recorder.vars[0]; len(vars) != 8 || vars[0] != "m1" || vars[7] != "[1.000000,0.000000]" {
+		t.Fatalf("unexpected upsert vars: %v", vars)
+	}
+	if created, ok := recorder.vars[0][6].(time.Time); !ok || created.IsZero() {
+		t.Fatalf("created_at should default to now, got %v", recorder.vars[0][6])
+	}
+	if sql := recorder.statements[1]; !strings.HasPrefix(sql, `DELETE FROM "aggo_mem_message_vectors" WHERE id IN`) {
+		t.Fatalf("unexpected delete: %s", sql)
+	}
+}
@@ -37,6 +37,7 @@ type Message struct {
 }
 
 type SearchQuery struct {
+	// SessionID 为空时跨会话检索该用户的全部消息
 	SessionID string
 	UserID    string
 
@@ -72,6 +73,14 @@ type KeywordStore interface {
 	SearchMessagesByKeywords(ctx context.Context, q *SearchQuery) ([]*Message, error)
 }
 
+// RankedKeywordStore is implemented by keyword stores that compute relevance
+// scores themselves (full-text indexes). KeywordSearcher prefers it over
+// SearchMessagesByKeywords when available.
+type RankedKeywordStore interface {
+	KeywordStore
+	SearchMessagesRanked(ctx context.Context, q *SearchQuery) ([]*SearchHit, error)
+}
+
 type VectorStore interface {
 	Upsert(ctx context.Context, msg *Message, vector []float64) error
 	Search(ctx context.Context, q *SearchQuery, vector []float64, limit int) ([]*SearchHit, error)
@@ -107,12 +116,43 @@ func NormalizeMatch(match string) string {
 	return MatchAny
 }
 
+// InferKeywords 使用默认分词器从自然语言查询中提取关键词
 func InferKeywords(text string) []string {
+	return InferKeywordsWith(DefaultTokenizer(), text)
+}
+
+// InferKeywordsWith 使用指定分词器提取关键词（原文形式，去除停用词并去重）。
+// 分词结果为空（例如整句都是停用词）时退化为按空白与标点切分。
+func InferKeywordsWith(tokenizer Tokenizer, text string) []string {
 	text = strings.TrimSpace(text)
 	if text == "" {
 		return nil
 	}
+	if tokenizer == nil {
+		tokenizer = DefaultTokenizer()
+	}
+
+	tokens := tokenizer.Tokenize(text)
+	seen := make(map[string]struct{}, len(tokens))
+	out := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		key := strings.ToLower(token.Text)
+		if key == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, token.Text)
+	}
+	if len(out) > 0 {
+		return out
+	}
+	return splitKeywords(text)
+}
 
+func splitKeywords(text string) []string {
 	splitter := strings.NewReplacer(
 		"\n", " ",
 		"\t", " ",
@@ -165,7 +205,15 @@ func SearchText(msg *Message) string {
 	return strings.Join(texts, "\n")
 }
 
+// MatchesKeywords 使用默认分词器判断文本是否命中关键词，返回命中的关键词个数
 func MatchesKeywords(text string, keywords []string, match string) (int, bool) {
+	return MatchesKeywordsWith(DefaultTokenizer(), text, keywords, match)
+}
+
+// MatchesKeywordsWith 判断文本是否命中关键词。
+// 关键词作为子串出现即命中；否则将关键词分词，所有检索词都出现在文本的检索词中也算命中，
+// 因此 "报销流程" 可以命中 "报销的审批流程"，"deploys" 可以命中 "deploying"。
+func MatchesKeywordsWith(tokenizer Tokenizer, text string, keywords []string, match string) (int, bool) {
 	text = strings.ToLower(strings.TrimSpace(text))
 	if text == "" {
 		return 0, false
@@ -182,18 +230,40 @@ func MatchesKeywords(text string, keywords []string, match string) (int, bool) {
 		return 0, false
 	}
 
+	var textTerms map[string]struct{}
+	termsMatch := func(keyword string) bool {
+		keywordTerms := Terms(tokenizer, keyword)
+		if len(keywordTerms) == 0 {
+			return false
+		}
+		if textTerms == nil {
+			all := Terms(tokenizer, text)
+			textTerms = make(map[string]struct{}, len(all))
+			for _, term := range all {
+				textTerms[term] = struct{}{}
+			}
+		}
+		for _, term := range keywordTerms {
+			if _, ok := textTerms[term]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	all := NormalizeMatch(match) == MatchAll
 	matched := 0
 	for _, keyword := range filtered {
-		if strings.Contains(text, keyword) {
+		if strings.Contains(text, keyword) || termsMatch(keyword) {
 			matched++
 			continue
 		}
-		if NormalizeMatch(match) == MatchAll {
+		if all {
 			return matched, false
 		}
 	}
 
-	if NormalizeMatch(match) == MatchAll {
+	if all {
 		return matched, matched == len(filtered)
 	}
 	return matched, matched > 0
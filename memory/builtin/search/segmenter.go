@@ -0,0 +1,191 @@
+package search
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Segmenter 基于词典的中文分词器，使用双向最大匹配。
+// 词典外连续的未登录单字会合并成一个词（通常是人名、项目名等专有名词）。
+type Segmenter struct {
+	mu     sync.RWMutex
+	words  map[string]struct{}
+	maxLen int
+}
+
+var (
+	defaultSegmenterOnce sync.Once
+	defaultSegmenter     *Segmenter
+)
+
+// NewSegmenter 创建只包含指定词汇的分词器
+func NewSegmenter(words ...string) *Segmenter {
+	s := &Segmenter{words: make(map[string]struct{}, len(words))}
+	s.AddWords(words...)
+	return s
+}
+
+// DefaultSegmenter 返回加载内置词表（含单字停用词）的共享分词器。
+// 对它调用 AddWords 会影响所有使用默认分词器的检索。
+func DefaultSegmenter() *Segmenter {
+	defaultSegmenterOnce.Do(func() {
+		defaultSegmenter = NewBuiltinSegmenter()
+	})
+	return defaultSegmenter
+}
+
+// NewBuiltinSegmenter 创建加载内置词表的独立分词器
+func NewBuiltinSegmenter() *Segmenter {
+	s := NewSegmenter(strings.Fields(builtinChineseDictionary)...)
+	s.AddWords(ChineseStopwords...)
+	return s
+}
+
+// AddWords 向词典添加词汇
+func (s *Segmenter) AddWords(words ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word == "" {
+			continue
+		}
+		s.words[word] = struct{}{}
+		if n := utf8.RuneCountInString(word); n > s.maxLen {
+			s.maxLen = n
+		}
+	}
+}
+
+// LoadDictionary 从文本加载词典，每行第一列为词，兼容 jieba 等 "词 词频 词性" 格式
+func (s *Segmenter) LoadDictionary(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	var words []string
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		words = append(words, fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	s.AddWords(words...)
+	return nil
+}
+
+// Segment 对一段连续的中文文本分词
+func (s *Segmenter) Segment(text string) []string {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return nil
+	}
+
+	s.mu.RLock()
+	forward := s.forwardMatch(runes)
+	backward := s.backwardMatch(runes)
+	s.mu.RUnlock()
+
+	// 双向最大匹配：词数少者优先，其次未登录单字少者优先，仍相同时取逆向结果
+	if len(forward) < len(backward) {
+		return mergeUnknown(forward)
+	}
+	if len(forward) == len(backward) && countUnknown(forward) < countUnknown(backward) {
+		return mergeUnknown(forward)
+	}
+	return mergeUnknown(backward)
+}
+
+// segmentPiece 分词片段，known 表示是否命中词典
+type segmentPiece struct {
+	text  string
+	known bool
+}
+
+func (s *Segmenter) contains(runes []rune) bool {
+	_, ok := s.words[string(runes)]
+	return ok
+}
+
+func (s *Segmenter) forwardMatch(runes []rune) []segmentPiece {
+	pieces := make([]segmentPiece, 0, len(runes))
+	for i := 0; i < len(runes); {
+		size := s.maxLen
+		if size > len(runes)-i {
+			size = len(runes) - i
+		}
+		for ; size > 1; size-- {
+			if s.contains(runes[i : i+size]) {
+				break
+			}
+		}
+		if size < 1 {
+			size = 1
+		}
+		piece := runes[i : i+size]
+		pieces = append(pieces, segmentPiece{text: string(piece), known: s.contains(piece)})
+		i += size
+	}
+	return pieces
+}
+
+func (s *Segmenter) backwardMatch(runes []rune) []segmentPiece {
+	pieces := make([]segmentPiece, 0, len(runes))
+	for end := len(runes); end > 0; {
+		size := s.maxLen
+		if size > end {
+			size = end
+		}
+		for ; size > 1; size-- {
+			if s.contains(runes[end-size : end]) {
+				break
+			}
+		}
+		if size < 1 {
+			size = 1
+		}
+		piece := runes[end-size : end]
+		pieces = append(pieces, segmentPiece{text: string(piece), known: s.contains(piece)})
+		end -= size
+	}
+	for i, j := 0, len(pieces)-1; i < j; i, j = i+1, j-1 {
+		pieces[i], pieces[j] = pieces[j], pieces[i]
+	}
+	return pieces
+}
+
+func countUnknown(pieces []segmentPiece) int {
+	n := 0
+	for _, piece := range pieces {
+		if !piece.known {
+			n++
+		}
+	}
+	return n
+}
+
+// mergeUnknown 合并连续的未登录单字
+func mergeUnknown(pieces []segmentPiece) []string {
+	out := make([]string, 0, len(pieces))
+	var pending strings.Builder
+	flush := func() {
+		if pending.Len() > 0 {
+			out = append(out, pending.String())
+			pending.Reset()
+		}
+	}
+	for _, piece := range pieces {
+		if piece.known {
+			flush()
+			out = append(out, piece.text)
+			continue
+		}
+		pending.WriteString(piece.text)
+	}
+	flush()
+	return out
+}
@@ -0,0 +1,47 @@
+package search
+
+import "strings"
+
+// ChineseStopwords 默认中文停用词：代词、助词、连词以及检索时常见的指代性词语
+var ChineseStopwords = strings.Fields(`
+的 地 得 了 着 过 是 在 有 和 与 及 或 而 且 并 也 都 就 还 又 才 再 很 太 更 最
+我 你 您 他 她 它 我们 你们 他们 她们 它们 咱们 自己 大家 别人 人家
+这 那 哪 这个 那个 哪个 这些 那些 哪些 这里 那里 哪里 这儿 那儿 这样 那样 这么 那么 这种 那种
+什么 怎么 怎样 怎么样 如何 为什么 为何 多少 谁 啥
+吗 呢 吧 啊 呀 哦 嗯 哈 嘛 么 啦 哇 喔 呗
+把 被 让 给 对 向 从 到 跟
+一个 一些 一下 一点 一种 一样 有点 有些 没有 不是 就是 还是 但是 可是 而且 然后 因为 所以 如果 虽然 只是 或者
+可以 能 能够 会 要 想 需要 应该
+说 讲 问 告诉 提到 提过 说过 讲过 聊 聊过 聊到
+之前 以前 上次 那次 那天 曾经 刚才 刚刚 当时 的话 时候
+请 帮 帮我 帮忙 麻烦 一起 关于 相关 有关 东西 事情 事儿 内容 情况 问题 记得 记住 还记得
+`)
+
+// EnglishStopwords 默认英文停用词
+var EnglishStopwords = strings.Fields(`
+a an the and or but if then else so of to in on at by for with from into onto about as
+is am are was were be been being do does did done have has had having
+i me my mine we us our ours you your yours he him his she her hers it its they them their theirs
+this that these those there here what which who whom whose when where why how
+not no nor can could will would shall should may might must
+just than too very also only own same such both each few more most other some any all
+up down out over under again further once
+please tell told said say remember recall mentioned
+`)
+
+func newStopwordSet(lists ...[]string) map[string]struct{} {
+	size := 0
+	for _, list := range lists {
+		size += len(list)
+	}
+	set := make(map[string]struct{}, size)
+	for _, list := range lists {
+		for _, word := range list {
+			word = strings.ToLower(strings.TrimSpace(word))
+			if word != "" {
+				set[word] = struct{}{}
+			}
+		}
+	}
+	return set
+}
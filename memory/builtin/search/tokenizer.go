@@ -0,0 +1,198 @@
+package search
+
+import (
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Token 分词结果
+type Token struct {
+	// Text 原文中的词（保留大小写），用于下推到存储层的关键词查询
+	Text string
+	// Term 归一化后的检索词（小写、英文词干），用于词项匹配与打分
+	Term string
+}
+
+// Tokenizer 将文本切分为检索词。实现需要并发安全。
+type Tokenizer interface {
+	Tokenize(text string) []Token
+}
+
+// StandardTokenizer 默认分词器：
+// 中文按词典分词，英文/数字按单词切分并做 Porter 词干化，同时过滤中英文停用词。
+type StandardTokenizer struct {
+	segmenter *Segmenter
+	stopwords map[string]struct{}
+	stem      bool
+}
+
+// TokenizerOption StandardTokenizer 的可选配置
+type TokenizerOption func(*StandardTokenizer)
+
+// WithSegmenter 指定中文分词器，默认使用 DefaultSegmenter()
+func WithSegmenter(segmenter *Segmenter) TokenizerOption {
+	return func(t *StandardTokenizer) {
+		if segmenter != nil {
+			t.segmenter = segmenter
+		}
+	}
+}
+
+// WithStopwords 替换默认停用词表
+func WithStopwords(words ...string) TokenizerOption {
+	return func(t *StandardTokenizer) {
+		t.stopwords = newStopwordSet(words)
+	}
+}
+
+// WithExtraStopwords 在当前停用词表基础上追加停用词
+func WithExtraStopwords(words ...string) TokenizerOption {
+	return func(t *StandardTokenizer) {
+		for word := range newStopwordSet(words) {
+			t.stopwords[word] = struct{}{}
+		}
+	}
+}
+
+// WithStemming 是否对英文单词做词干化，默认开启
+func WithStemming(enabled bool) TokenizerOption {
+	return func(t *StandardTokenizer) {
+		t.stem = enabled
+	}
+}
+
+// NewStandardTokenizer 创建默认分词器
+func NewStandardTokenizer(opts ...TokenizerOption) *StandardTokenizer {
+	t := &StandardTokenizer{
+		segmenter: DefaultSegmenter(),
+		stopwords: newStopwordSet(ChineseStopwords, EnglishStopwords),
+		stem:      true,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(t)
+		}
+	}
+	return t
+}
+
+// Tokenize 实现 Tokenizer
+func (t *StandardTokenizer) Tokenize(text string) []Token {
+	runes := []rune(text)
+	tokens := make([]Token, 0, len(runes)/2+1)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.Is(unicode.Han, r):
+			end := i
+			for end < len(runes) && unicode.Is(unicode.Han, runes[end]) {
+				end++
+			}
+			for _, word := range t.segmenter.Segment(string(runes[i:end])) {
+				tokens = t.appendToken(tokens, word, word)
+			}
+			i = end
+		case isWordRune(r):
+			end := i + 1
+			for end < len(runes) {
+				if isWordRune(runes[end]) && !unicode.Is(unicode.Han, runes[end]) {
+					end++
+					continue
+				}
+				// 保留 user-123、v1.2、a_b 这类带连接符的标识符
+				if isWordConnector(runes[end]) && end+1 < len(runes) && isWordRune(runes[end+1]) && !unicode.Is(unicode.Han, runes[end+1]) {
+					end += 2
+					continue
+				}
+				break
+			}
+			word := string(runes[i:end])
+			term := strings.ToLower(word)
+			if t.stem {
+				term = PorterStem(term)
+			}
+			tokens = t.appendToken(tokens, word, term)
+			i = end
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+func (t *StandardTokenizer) appendToken(tokens []Token, text, term string) []Token {
+	if _, ok := t.stopwords[strings.ToLower(text)]; ok {
+		return tokens
+	}
+	return append(tokens, Token{Text: text, Term: term})
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isWordConnector(r rune) bool {
+	switch r {
+	case '-', '_', '.', '@':
+		return true
+	}
+	return false
+}
+
+// tokenizerHolder 让 atomic.Value 始终存储同一具体类型
+type tokenizerHolder struct {
+	tokenizer Tokenizer
+}
+
+var defaultTokenizer atomic.Value
+
+// DefaultTokenizer 返回包级默认分词器，InferKeywords / MatchesKeywords 使用它
+func DefaultTokenizer() Tokenizer {
+	if holder, ok := defaultTokenizer.Load().(tokenizerHolder); ok {
+		return holder.tokenizer
+	}
+	t := NewStandardTokenizer()
+	defaultTokenizer.CompareAndSwap(nil, tokenizerHolder{tokenizer: t})
+	return defaultTokenizer.Load().(tokenizerHolder).tokenizer
+}
+
+// SetDefaultTokenizer 替换包级默认分词器，传入 nil 时恢复为 StandardTokenizer
+func SetDefaultTokenizer(t Tokenizer) {
+	if t == nil {
+		t = NewStandardTokenizer()
+	}
+	defaultTokenizer.Store(tokenizerHolder{tokenizer: t})
+}
+
+// Terms 返回文本的归一化检索词（保留重复，顺序与原文一致）
+func Terms(tokenizer Tokenizer, text string) []string {
+	if tokenizer == nil {
+		tokenizer = DefaultTokenizer()
+	}
+	tokens := tokenizer.Tokenize(text)
+	terms := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if token.Term != "" {
+			terms = append(terms, token.Term)
+		}
+	}
+	return terms
+}
+
+// KeywordTerms 将一个关键词拆成原文中的子词，供存储层做 LIKE 展开。
+// 关键词本身不可再分时返回 nil。
+func KeywordTerms(tokenizer Tokenizer, keyword string) []string {
+	if tokenizer == nil {
+		tokenizer = DefaultTokenizer()
+	}
+	tokens := tokenizer.Tokenize(keyword)
+	if len(tokens) < 2 {
+		return nil
+	}
+	out := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		out = append(out, token.Text)
+	}
+	return out
+}
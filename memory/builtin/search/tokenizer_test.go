@@ -0,0 +1,64 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInferKeywordsSegmentsChinese(t *testing.T) {
+	got := InferKeywords("我上周说的那个报销流程")
+	want := []string{"上周", "报销", "流程"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("InferKeywords = %v, want %v", got, want)
+	}
+}
+
+func TestInferKeywordsMixedText(t *testing.T) {
+	got := InferKeywords("酷企1418主体删除 and the Deploy of user-123")
+	want := []string{"酷企", "1418", "主体", "删除", "Deploy", "user-123"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("InferKeywords = %v, want %v", got, want)
+	}
+}
+
+func TestMatchesKeywordsUsesTerms(t *testing.T) {
+	if _, ok := MatchesKeywords("提交了报销的审批流程", []string{"报销流程"}, MatchAll); !ok {
+		t.Fatalf("segmented keyword should match")
+	}
+	if _, ok := MatchesKeywords("the deploying step failed", []string{"deploys"}, MatchAny); !ok {
+		t.Fatalf("stemmed keyword should match")
+	}
+	if _, ok := MatchesKeywords("支付超时问题", []string{"报销流程"}, MatchAny); ok {
+		t.Fatalf("unrelated text should not match")
+	}
+}
+
+func TestPorterStem(t *testing.T) {
+	cases := map[string]string{
+		"caresses":        "caress",
+		"ponies":          "poni",
+		"running":         "run",
+		"hopping":         "hop",
+		"filing":          "file",
+		"relational":      "relat",
+		"generalizations": "gener",
+		"adoption":        "adopt",
+		"controlling":     "control",
+	}
+	for word, want := range cases {
+		if got := PorterStem(word); got != want {
+			t.Errorf("PorterStem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestSegmenterCustomWords(t *testing.T) {
+	segmenter := NewBuiltinSegmenter()
+	segmenter.AddWords("海豚充值")
+	tokenizer := NewStandardTokenizer(WithSegmenter(segmenter))
+	got := InferKeywordsWith(tokenizer, "海豚充值入账异常")
+	want := []string{"海豚充值", "入账", "异常"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("InferKeywordsWith = %v, want %v", got, want)
+	}
+}
@@ -101,3 +101,35 @@ func (s *VectorSearcher) Reindex(ctx context.Context, sessionID, userID string)
 	}
 	return nil
 }
+
+// Invalidate 转发给实现了 Invalidator 的向量存储
+func (s *VectorSearcher) Invalidate(sessionID, userID string) {
+	if s == nil {
+		return
+	}
+	if invalidator, ok := s.store.(Invalidator); ok {
+		invalidator.Invalidate(sessionID, userID)
+	}
+}
+
+// Delete 转发给实现了 Deleter 的向量存储；向量与消息同表存储时无需删除
+func (s *VectorSearcher) Delete(ctx context.Context, ids ...string) error {
+	if s == nil {
+		return nil
+	}
+	if deleter, ok := s.store.(Deleter); ok {
+		return deleter.Delete(ctx, ids...)
+	}
+	return nil
+}
+
+// Close 关闭实现了 Close() error 的向量存储
+func (s *VectorSearcher) Close() error {
+	if s == nil {
+		return nil
+	}
+	if closer, ok := s.store.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -83,8 +83,11 @@ func (s *GormVectorStore) Search(ctx context.Context, q *SearchQuery, vector []f
 	query := s.db.WithContext(ctx).
 		Table(s.tableName).
 		Select("id, session_id, user_id, role, content, parts, created_at, embedding, embedding_dim").
-		Where("session_id = ? AND user_id = ?", q.SessionID, q.UserID).
+		Where("user_id = ?", q.UserID).
 		Where("embedding IS NOT NULL AND embedding_dim > 0")
+	if q.SessionID != "" {
+		query = query.Where("session_id = ?", q.SessionID)
+	}
 
 	if role := strings.TrimSpace(q.Role); role != "" {
 		query = query.Where("role = ?", role)
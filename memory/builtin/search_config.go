@@ -1,6 +1,8 @@
 package builtin
 
 import (
+	"time"
+
 	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
 	"github.com/cloudwego/eino/components/embedding"
 )
@@ -13,4 +15,63 @@ type SearchConfig struct {
 	VectorStore builtinsearch.VectorStore
 	Hybrid      HybridConfig
 	AsyncIndex  bool
+	// Tokenizer 关键词推断与匹配使用的分词器，为空时使用 builtinsearch.DefaultTokenizer()
+	Tokenizer builtinsearch.Tokenizer
+	// BM25 非空时关键词检索改用内存倒排索引 + BM25 打分，用户事件检索也按 BM25 排序
+	BM25 *BM25Config
+	// ANN 非空且未指定 VectorStore 时，在默认的 GORM 向量存储之上启用进程内 HNSW 近似索引
+	ANN *ANNConfig
+}
+
+// BM25Config BM25 关键词检索配置
+type BM25Config struct {
+	// K1 词频饱和参数，默认 1.2
+	K1 float64
+	// B 文档长度归一化系数，默认 0.75
+	B float64
+	// PersistPath 索引快照文件路径，为空时只保存在内存中，重启后按会话懒加载
+	PersistPath string
+	// PersistInterval 快照定期写回间隔，为 0 时使用默认 1 分钟，为负数时只在关闭时写回
+	PersistInterval time.Duration
+}
+
+func (c *BM25Config) params() builtinsearch.BM25Params {
+	return builtinsearch.BM25Params{K1: c.K1, B: c.B}
+}
+
+func (c *BM25Config) options(tokenizer builtinsearch.Tokenizer) []builtinsearch.BM25SearcherOption {
+	opts := []builtinsearch.BM25SearcherOption{
+		builtinsearch.WithBM25Tokenizer(tokenizer),
+		builtinsearch.WithBM25PersistPath(c.PersistPath),
+	}
+	if c.PersistInterval != 0 {
+		opts = append(opts, builtinsearch.WithBM25PersistInterval(c.PersistInterval))
+	}
+	return opts
+}
+
+// ANNConfig 进程内近似向量索引配置
+type ANNConfig struct {
+	// M、EfConstruction、EfSearch 为 HNSW 图参数，默认 16、200、64
+	M              int
+	EfConstruction int
+	EfSearch       int
+	// OverFetch 过滤前的候选放大倍数，默认 4
+	OverFetch int
+	// PersistPath 索引快照文件路径，为空时只保存在内存中，重启后按用户懒加载
+	PersistPath string
+	// PersistInterval 快照定期写回间隔，为 0 时使用默认 5 分钟，为负数时只在关闭时写回
+	PersistInterval time.Duration
+}
+
+func (c *ANNConfig) options() []builtinsearch.ANNOption {
+	opts := []builtinsearch.ANNOption{
+		builtinsearch.WithHNSWParams(builtinsearch.HNSWParams{M: c.M, EfConstruction: c.EfConstruction, EfSearch: c.EfSearch}),
+		builtinsearch.WithANNOverFetch(c.OverFetch),
+		builtinsearch.WithANNPersistPath(c.PersistPath),
+	}
+	if c.PersistInterval != 0 {
+		opts = append(opts, builtinsearch.WithANNPersistInterval(c.PersistInterval))
+	}
+	return opts
 }
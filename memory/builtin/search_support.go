@@ -13,7 +13,8 @@ import (
 )
 
 type storageSearchAdapter struct {
-	storage MemoryStorage
+	storage   MemoryStorage
+	tokenizer builtinsearch.Tokenizer
 }
 
 func (a *storageSearchAdapter) SearchMessagesByKeywords(ctx context.Context, q *builtinsearch.SearchQuery) ([]*builtinsearch.Message, error) {
@@ -32,7 +33,7 @@ func (a *storageSearchAdapter) SearchMessagesByKeywords(ctx context.Context, q *
 
 	keywords := q.Keywords
 	if len(keywords) == 0 {
-		keywords = builtinsearch.InferKeywords(q.Query)
+		keywords = builtinsearch.InferKeywordsWith(a.tokenizer, q.Query)
 	}
 	if len(keywords) == 0 {
 		return []*builtinsearch.Message{}, nil
@@ -49,7 +50,7 @@ func (a *storageSearchAdapter) SearchMessagesByKeywords(ctx context.Context, q *
 		if q.Until != nil && msg.CreatedAt.After(*q.Until) {
 			continue
 		}
-		if _, ok := builtinsearch.MatchesKeywords(builtinsearch.SearchText(msg), keywords, q.Match); !ok {
+		if _, ok := builtinsearch.MatchesKeywordsWith(a.tokenizer, builtinsearch.SearchText(msg), keywords, q.Match); !ok {
 			continue
 		}
 		filtered = append(filtered, msg)
@@ -65,7 +66,19 @@ func (a *storageSearchAdapter) SearchMessagesByKeywords(ctx context.Context, q *
 	return filtered, nil
 }
 
+// ListMessages sessionID 为空时跨会话读取用户的全部消息，要求存储实现 UserMessageStorage
 func (a *storageSearchAdapter) ListMessages(ctx context.Context, sessionID, userID string) ([]*builtinsearch.Message, error) {
+	if sessionID == "" {
+		userStore, ok := a.storage.(UserMessageStorage)
+		if !ok {
+			return nil, fmt.Errorf("当前 storage 未实现 UserMessageStorage，不支持跨会话检索")
+		}
+		msgs, err := userStore.GetUserMessages(ctx, userID, 0)
+		if err != nil {
+			return nil, err
+		}
+		return toSearchMessages(msgs), nil
+	}
 	msgs, err := a.storage.GetMessages(ctx, sessionID, userID, 0)
 	if err != nil {
 		return nil, err
@@ -73,6 +86,39 @@ func (a *storageSearchAdapter) ListMessages(ctx context.Context, sessionID, user
 	return toSearchMessages(msgs), nil
 }
 
+// rankedStorageSearchAdapter 在底层存储支持全文索引打分时暴露 RankedKeywordStore，
+// 让 KeywordSearcher 直接使用存储层的相关度排序。
+type rankedStorageSearchAdapter struct {
+	*storageSearchAdapter
+	ranked RankedSearchMessageStorage
+}
+
+func (a *rankedStorageSearchAdapter) SearchMessagesRanked(ctx context.Context, q *builtinsearch.SearchQuery) ([]*builtinsearch.SearchHit, error) {
+	ranked, err := a.ranked.SearchMessagesRanked(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]*builtinsearch.SearchHit, 0, len(ranked))
+	for _, item := range ranked {
+		if item == nil || item.Message == nil {
+			continue
+		}
+		hits = append(hits, &builtinsearch.SearchHit{
+			Message: toSearchMessage(item.Message),
+			Score:   item.Score,
+		})
+	}
+	return hits, nil
+}
+
+func newKeywordStore(storage MemoryStorage, tokenizer builtinsearch.Tokenizer) builtinsearch.KeywordStore {
+	adapter := &storageSearchAdapter{storage: storage, tokenizer: tokenizer}
+	if ranked, ok := storage.(RankedSearchMessageStorage); ok {
+		return &rankedStorageSearchAdapter{storageSearchAdapter: adapter, ranked: ranked}
+	}
+	return adapter
+}
+
 func normalizeSearchConfig(cfg *SearchConfig) *SearchConfig {
 	if cfg == nil {
 		return &SearchConfig{Mode: builtinsearch.ModeKeyword}
@@ -85,8 +131,15 @@ func normalizeSearchConfig(cfg *SearchConfig) *SearchConfig {
 
 func newSearcher(storage MemoryStorage, cfg *SearchConfig) (builtinsearch.Searcher, error) {
 	cfg = normalizeSearchConfig(cfg)
-	adapter := &storageSearchAdapter{storage: storage}
-	keywordSearcher := builtinsearch.NewKeywordSearcher(adapter)
+	adapter := newKeywordStore(storage, cfg.Tokenizer)
+	var keywordSearcher builtinsearch.Searcher = builtinsearch.NewKeywordSearcher(adapter, builtinsearch.WithKeywordTokenizer(cfg.Tokenizer))
+	if cfg.BM25 != nil {
+		bm25Searcher, err := builtinsearch.NewBM25Searcher(adapter, cfg.BM25.params(), cfg.BM25.options(cfg.Tokenizer)...)
+		if err != nil {
+			return nil, err
+		}
+		keywordSearcher = bm25Searcher
+	}
 
 	switch cfg.Mode {
 	case builtinsearch.ModeKeyword:
@@ -120,6 +173,13 @@ func newVectorSearcher(storage MemoryStorage, cfg *SearchConfig, source builtins
 			return nil, err
 		}
 		store = defaultStore
+		if cfg.ANN != nil {
+			annStore, err := builtinsearch.NewANNVectorStore(defaultStore, cfg.ANN.options()...)
+			if err != nil {
+				return nil, err
+			}
+			store = annStore
+		}
 	}
 
 	return builtinsearch.NewVectorSearcher(cfg.Embedder, store, source)
@@ -156,6 +216,13 @@ func cloneConversationMessage(msg *ConversationMessage) *ConversationMessage {
 	if len(msg.Parts) > 0 {
 		cloned.Parts = append([]schema.MessageInputPart(nil), msg.Parts...)
 	}
+	if len(msg.ToolCalls) > 0 {
+		cloned.ToolCalls = append([]ToolCall(nil), msg.ToolCalls...)
+	}
+	if msg.ToolResult != nil {
+		result := *msg.ToolResult
+		cloned.ToolResult = &result
+	}
 	return &cloned
 }
 
@@ -169,6 +236,57 @@ func (m *MemoryManager) SearchMessages(ctx context.Context, q *builtinsearch.Sea
 	return m.searcher.Search(ctx, q)
 }
 
+// invalidateSearchIndex 在消息被删除后丢弃检索器的内存索引，sessionID 与 userID 都为空时丢弃全部
+func (m *MemoryManager) invalidateSearchIndex(sessionID, userID string) {
+	if invalidator, ok := m.searcher.(builtinsearch.Invalidator); ok {
+		invalidator.Invalidate(sessionID, userID)
+	}
+}
+
+func (m *MemoryManager) closeSearcher() error {
+	if closer, ok := m.searcher.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// rankUserMemoryEvents 对用户事件按 BM25 相关度排序。
+// 先按类型、时间过滤取出候选事件，再以候选集为语料打分，使文档频率反映该用户自身的事件分布。
+func (m *MemoryManager) rankUserMemoryEvents(ctx context.Context, store UserMemoryEventStorage, query *UserMemoryEventQuery) ([]*UserMemoryEvent, error) {
+	candidateQuery := *query
+	candidateQuery.Keywords = nil
+	candidateQuery.Limit = 0
+	candidates, err := store.SearchUserMemoryEvents(ctx, &candidateQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]builtinsearch.BM25Document, 0, len(candidates))
+	byID := make(map[string]*UserMemoryEvent, len(candidates))
+	for _, evt := range candidates {
+		if evt == nil {
+			continue
+		}
+		text := evt.Summary
+		if len(evt.Keywords) > 0 {
+			text += "\n" + strings.Join(evt.Keywords, " ")
+		}
+		docs = append(docs, builtinsearch.BM25Document{ID: evt.ID, Text: text})
+		byID[evt.ID] = evt
+	}
+
+	searchCfg := normalizeSearchConfig(m.config.Search)
+	scores := builtinsearch.RankBM25(searchCfg.Tokenizer, searchCfg.BM25.params(), docs, query.Keywords, query.Match)
+	out := make([]*UserMemoryEvent, 0, len(scores))
+	for _, score := range scores {
+		out = append(out, byID[score.ID])
+		if query.Limit > 0 && len(out) >= query.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
 func (m *MemoryManager) ReindexConversation(ctx context.Context, sessionID, userID string) error {
 	if m.searcher == nil {
 		return nil
@@ -192,7 +310,8 @@ func PrefixHistoryTimestamp(msg *schema.AgenticMessage) *schema.AgenticMessage {
 	if msg == nil || strings.TrimSpace(agmsg.Text(msg)) == "" {
 		return msg
 	}
-	if msg.Extra == nil {
+	// 工具结果消息只能包含 FunctionToolResult 块，不加时间前缀
+	if msg.Extra == nil || agmsg.HasFunctionToolResult(msg) {
 		return msg
 	}
 	formatted := formatHistoryMessageTime(msg.Extra[MessageExtraCreatedAtKey])
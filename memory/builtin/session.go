@@ -0,0 +1,301 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gookit/slog"
+)
+
+const (
+	defaultSessionTitleMaxRunes        = 20
+	defaultSessionTitleContextMessages = 4
+)
+
+// SessionTitleConfig 会话标题自动生成配置
+type SessionTitleConfig struct {
+	// 标题最大字数，默认 20
+	MaxRunes int `json:"maxRunes"`
+	// 生成标题时参考的会话开头消息数，默认 4（两轮对话）
+	ContextMessages int `json:"contextMessages"`
+}
+
+func normalizeSessionTitleConfig(cfg *SessionTitleConfig) *SessionTitleConfig {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.MaxRunes <= 0 {
+		cfg.MaxRunes = defaultSessionTitleMaxRunes
+	}
+	if cfg.ContextMessages <= 0 {
+		cfg.ContextMessages = defaultSessionTitleContextMessages
+	}
+	return cfg
+}
+
+// SessionTitleGenerator 基于AI的会话标题生成器
+type SessionTitleGenerator struct {
+	cm     model.AgenticModel
+	prompt string
+}
+
+// NewSessionTitleGenerator 创建新的会话标题生成器
+func NewSessionTitleGenerator(cm model.AgenticModel) *SessionTitleGenerator {
+	return &SessionTitleGenerator{
+		cm:     cm,
+		prompt: DefaultSessionTitlePrompt,
+	}
+}
+
+// SetPrompt 自定义标题生成系统提示词，可包含一个 %d 占位符表示最大字数
+func (g *SessionTitleGenerator) SetPrompt(prompt string) {
+	g.prompt = prompt
+}
+
+// GenerateTitle 根据会话开头的消息生成标题，结果超过 maxRunes 时截断
+func (g *SessionTitleGenerator) GenerateTitle(ctx context.Context, messages []*ConversationMessage, maxRunes int) (string, error) {
+	if g.cm == nil {
+		return "", errors.New("未配置模型，无法生成会话标题")
+	}
+	historyText := buildConversationHistoryPlainText(messages)
+	if historyText == "" {
+		return "", nil
+	}
+	if maxRunes <= 0 {
+		maxRunes = defaultSessionTitleMaxRunes
+	}
+
+	ctx = withObservationName(ctx, g.cm, "builtin-session-title")
+
+	systemPrompt := g.prompt
+	if strings.Contains(systemPrompt, "%d") {
+		systemPrompt = fmt.Sprintf(systemPrompt, maxRunes)
+	}
+	promptMessages := []*schema.AgenticMessage{
+		schema.SystemAgenticMessage(systemPrompt),
+		schema.UserAgenticMessage("## 对话内容\n" + historyText),
+	}
+
+	response, err := generateViaStream(ctx, g.cm, promptMessages)
+	if err != nil {
+		return "", fmt.Errorf("生成会话标题失败: %w", err)
+	}
+	return normalizeSessionTitle(agmsg.Text(response), maxRunes), nil
+}
+
+// normalizeSessionTitle 取第一行，去掉常见的引号与前缀，并截断到 maxRunes 字
+func normalizeSessionTitle(title string, maxRunes int) string {
+	title = strings.TrimSpace(title)
+	if idx := strings.IndexByte(title, '\n'); idx >= 0 {
+		title = strings.TrimSpace(title[:idx])
+	}
+	title = strings.TrimPrefix(title, "标题：")
+	title = strings.TrimPrefix(title, "标题:")
+	title = strings.Trim(title, " \t\"'“”‘’《》「」。.")
+	if maxRunes > 0 && utf8.RuneCountInString(title) > maxRunes {
+		title = string([]rune(title)[:maxRunes])
+	}
+	return title
+}
+
+// sessionStorage 获取实现了会话接口的底层存储，未实现时返回 nil。
+func (m *MemoryManager) sessionStorage() SessionStorage {
+	s, _ := m.storage.(SessionStorage)
+	return s
+}
+
+// ListSessions 按最近活跃时间倒序返回用户的会话列表。存储未实现 SessionStorage 时返回错误。
+func (m *MemoryManager) ListSessions(ctx context.Context, query *SessionQuery) ([]*Session, error) {
+	store := m.sessionStorage()
+	if store == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 SessionStorage")
+	}
+	if query == nil || query.UserID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+	return store.ListSessions(ctx, query)
+}
+
+// GetSession 获取会话信息，会话不存在时返回 nil, nil
+func (m *MemoryManager) GetSession(ctx context.Context, sessionID, userID string) (*Session, error) {
+	store := m.sessionStorage()
+	if store == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 SessionStorage")
+	}
+	return store.GetSession(ctx, sessionID, userID)
+}
+
+// RenameSession 修改会话标题，title 为空表示清除标题（之后可再次自动生成）
+func (m *MemoryManager) RenameSession(ctx context.Context, sessionID, userID, title string) (*Session, error) {
+	return m.updateSession(ctx, sessionID, userID, func(session *Session) {
+		session.Title = strings.TrimSpace(title)
+	})
+}
+
+// ArchiveSession 归档或取消归档会话。归档只影响会话列表的默认过滤，不删除任何数据。
+func (m *MemoryManager) ArchiveSession(ctx context.Context, sessionID, userID string, archived bool) (*Session, error) {
+	return m.updateSession(ctx, sessionID, userID, func(session *Session) {
+		if session.Archived == archived {
+			return
+		}
+		session.Archived = archived
+		session.ArchivedAt = time.Time{}
+		if archived {
+			session.ArchivedAt = time.Now()
+		}
+	})
+}
+
+// updateSession 读取会话、应用修改并保存元数据
+func (m *MemoryManager) updateSession(ctx context.Context, sessionID, userID string, apply func(session *Session)) (*Session, error) {
+	store := m.sessionStorage()
+	if store == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 SessionStorage")
+	}
+	session, err := store.GetSession(ctx, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, fmt.Errorf("会话 %s 不存在", sessionID)
+	}
+	apply(session)
+	if err := store.SaveSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// DeleteSession 删除会话及其消息、摘要、检索索引和元数据，删除后不可恢复。
+// 存储未实现 SessionStorage 时仍会删除消息、摘要与索引。
+func (m *MemoryManager) DeleteSession(ctx context.Context, sessionID, userID string) error {
+	if sessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	// 先取出消息ID，用于删除消息表之外持久化的向量索引
+	messages, err := m.storage.GetMessages(ctx, sessionID, userID, 0)
+	if err != nil {
+		return fmt.Errorf("获取会话消息失败: %w", err)
+	}
+	if deleter, ok := m.searcher.(builtinsearch.Deleter); ok && len(messages) > 0 {
+		ids := make([]string, 0, len(messages))
+		for _, msg := range messages {
+			ids = append(ids, msg.ID)
+		}
+		if err := deleter.Delete(ctx, ids...); err != nil {
+			return fmt.Errorf("删除会话检索索引失败: %w", err)
+		}
+	}
+
+	if err := m.storage.DeleteMessages(ctx, sessionID, userID); err != nil {
+		return fmt.Errorf("删除会话消息失败: %w", err)
+	}
+	m.invalidateSearchIndex(sessionID, userID)
+
+	if err := m.storage.DeleteSessionSummary(ctx, sessionID, userID); err != nil {
+		return fmt.Errorf("删除会话摘要失败: %w", err)
+	}
+	sessionKey := generateSessionKey(userID, sessionID)
+	if m.summaryCache != nil {
+		m.summaryCache.Delete(sessionKey)
+	}
+	m.summaryTrigger.RemoveSession(sessionKey)
+	if store := m.summaryChunkStorage(); store != nil {
+		if err := store.DeleteSummaryChunks(ctx, sessionID, userID); err != nil {
+			return fmt.Errorf("删除分段摘要失败: %w", err)
+		}
+	}
+	if store := m.triggerStateStorage(); store != nil {
+		if err := store.DeleteSummaryTriggerState(ctx, sessionID, userID); err != nil {
+			return fmt.Errorf("删除摘要触发状态失败: %w", err)
+		}
+	}
+
+	if store := m.sessionStorage(); store != nil {
+		if err := store.DeleteSession(ctx, sessionID, userID); err != nil {
+			return fmt.Errorf("删除会话元数据失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// GenerateSessionTitle 用模型根据会话开头的消息生成标题并保存，会覆盖已有标题
+func (m *MemoryManager) GenerateSessionTitle(ctx context.Context, sessionID, userID string) (*Session, error) {
+	store := m.sessionStorage()
+	if store == nil {
+		return nil, fmt.Errorf("当前 storage 未实现 SessionStorage")
+	}
+	session, err := store.GetSession(ctx, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, fmt.Errorf("会话 %s 不存在", sessionID)
+	}
+	if err := m.generateSessionTitle(ctx, store, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (m *MemoryManager) generateSessionTitle(ctx context.Context, store SessionStorage, session *Session) error {
+	cfg := normalizeSessionTitleConfig(m.config.SessionTitle)
+	if cfg == nil {
+		cfg = normalizeSessionTitleConfig(&SessionTitleConfig{})
+	}
+
+	messages, err := m.storage.GetMessages(ctx, session.SessionID, session.UserID, 0)
+	if err != nil {
+		return fmt.Errorf("获取会话消息失败: %w", err)
+	}
+	if len(messages) > cfg.ContextMessages {
+		messages = messages[:cfg.ContextMessages]
+	}
+
+	title, err := m.sessionTitleGenerator.GenerateTitle(ctx, messages, cfg.MaxRunes)
+	if err != nil {
+		return err
+	}
+	if title == "" {
+		return nil
+	}
+	session.Title = title
+	return store.SaveSession(ctx, session)
+}
+
+// shouldAutoTitleSession 是否需要为会话自动生成标题：已启用、存储支持且配置了模型
+func (m *MemoryManager) shouldAutoTitleSession() bool {
+	return m.config.SessionTitle != nil && m.sessionStorage() != nil &&
+		m.sessionTitleGenerator != nil && m.sessionTitleGenerator.cm != nil
+}
+
+// autoTitleSession 会话还没有标题时生成一个，由异步任务在助手回复后调用
+func (m *MemoryManager) autoTitleSession(ctx context.Context, userID, sessionID string) {
+	store := m.sessionStorage()
+	if store == nil {
+		return
+	}
+	session, err := store.GetSession(ctx, sessionID, userID)
+	if err != nil {
+		slog.Errorf("获取会话失败: sessionID=%s, userID=%s, err=%v\n", sessionID, userID, err)
+		return
+	}
+	if session == nil || session.Title != "" {
+		return
+	}
+	if err := m.generateSessionTitle(ctx, store, session); err != nil {
+		slog.Errorf("自动生成会话标题失败: sessionID=%s, userID=%s, err=%v\n", sessionID, userID, err)
+	}
+}
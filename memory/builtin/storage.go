@@ -2,6 +2,7 @@ package builtin
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
@@ -10,12 +11,15 @@ import (
 
 // MemoryStorage 记忆存储接口
 // 定义了记忆存储的基本操作，可以有多种实现（内存、SQL、NoSQL等）
+//
+// 用户记忆、版本历史与事件的读写按 NamespaceFromContext(ctx) 隔离：写入时把命名空间填入记录，
+// 读取和删除只作用于该命名空间。会话摘要与消息不区分命名空间。
 type MemoryStorage interface {
 	AutoMigrate() error
 
 	// 用户记忆操作
 
-	// UpsertUserMemory 创建或更新用户记忆（每个用户一条记录）
+	// UpsertUserMemory 创建或更新用户记忆（每个用户一条记录），写入后版本号加一
 	UpsertUserMemory(ctx context.Context, memory *UserMemory) error
 
 	// GetUserMemory 获取用户的记忆
@@ -84,12 +88,32 @@ type CursorMessageStorage interface {
 	GetMessageCountAfter(ctx context.Context, sessionID string, userID string, afterMessageID string, afterTime time.Time) (int, error)
 }
 
+// UserMessageStorage 是可选扩展接口，跨会话读取用户的消息，供 SessionID 为空的跨会话检索使用。
+type UserMessageStorage interface {
+	// GetUserMessages 返回该用户全部会话中最新的 limit 条消息（limit<=0 表示不限制），按时间正序。
+	GetUserMessages(ctx context.Context, userID string, limit int) ([]*ConversationMessage, error)
+}
+
 // SearchMessageStorage is an optional extension for stores that can perform
 // keyword search efficiently in the storage layer.
 type SearchMessageStorage interface {
 	SearchMessagesByKeywords(ctx context.Context, q *builtinsearch.SearchQuery) ([]*ConversationMessage, error)
 }
 
+// RankedSearchMessageStorage is an optional extension for stores that can rank
+// keyword matches natively (e.g. SQLite FTS5 bm25, PostgreSQL ts_rank_cd).
+// Results are expected to be ordered by score descending.
+type RankedSearchMessageStorage interface {
+	SearchMessagesRanked(ctx context.Context, q *builtinsearch.SearchQuery) ([]*RankedConversationMessage, error)
+}
+
+// RankedConversationMessage 带相关度分数的检索结果
+type RankedConversationMessage struct {
+	Message *ConversationMessage
+	// 相关度分数，越大越相关
+	Score float64
+}
+
 // UserMemoryEventStorage 是可选扩展接口，提供按用户拆分的事件级记忆存储与检索。
 // 启用 MemoryConfig.EnableEventSearch 时，底层 MemoryStorage 必须实现该接口；
 // 不实现时 Provider 会退化为兼容模式（全量注入 UserMemory.Memory）。
@@ -97,11 +121,12 @@ type UserMemoryEventStorage interface {
 	// SaveUserMemoryEvent 新增一条用户记忆事件。事件 ID 未填写时由实现侧生成（建议 ULID 单调递增）。
 	SaveUserMemoryEvent(ctx context.Context, event *UserMemoryEvent) error
 
-	// ListRecentUserMemoryEvents 返回该用户最近的 N 条事件，按 EventDate 倒序、同日按 CreatedAt 倒序。
+	// ListRecentUserMemoryEvents 返回该用户最近的 N 条未被取代的事件，按 EventDate 倒序、同日按 CreatedAt 倒序。
 	// limit <= 0 时由实现侧选取一个安全的默认值（建议返回空切片以避免误注入大量数据）。
 	ListRecentUserMemoryEvents(ctx context.Context, userID string, limit int) ([]*UserMemoryEvent, error)
 
 	// SearchUserMemoryEvents 按 UserMemoryEventQuery 过滤事件，返回按 EventDate 倒序的命中列表。
+	// 未设置 IncludeSuperseded 时排除已被取代的事件。
 	SearchUserMemoryEvents(ctx context.Context, query *UserMemoryEventQuery) ([]*UserMemoryEvent, error)
 
 	// DeleteUserMemoryEvent 删除指定事件。eventID 为空时返回错误。
@@ -111,6 +136,121 @@ type UserMemoryEventStorage interface {
 	ClearUserMemoryEvents(ctx context.Context, userID string) error
 }
 
+// UserMemoryEventConsolidationStorage 是可选扩展接口，供事件整理任务标记被取代的事件。
+// 启用 MemoryConfig.Consolidation 时，底层存储需要同时实现 UserMemoryEventStorage 与该接口。
+type UserMemoryEventConsolidationStorage interface {
+	// SupersedeUserMemoryEvents 将 eventIDs 标记为被 supersededBy 取代。事件保留不删除，
+	// 已被取代的事件不会再次标记。
+	SupersedeUserMemoryEvents(ctx context.Context, userID, supersededBy string, eventIDs []string) error
+
+	// ListUserMemoryEventOwners 返回在 since 之后（含）新增过事件的记忆归属，since 为零值时返回全部。
+	// 该方法跨所有命名空间，不受 ctx 中命名空间的限制。
+	ListUserMemoryEventOwners(ctx context.Context, since time.Time) ([]UserMemoryOwner, error)
+}
+
+// UserMemoryHistoryStorage 是可选扩展接口，保存用户记忆的历史版本，用于审计、对比与回滚。
+type UserMemoryHistoryStorage interface {
+	// SaveUserMemoryRevision 追加一个版本。ID 为空时由实现侧生成，Revision 由实现侧按用户递增分配。
+	SaveUserMemoryRevision(ctx context.Context, revision *UserMemoryRevision) error
+
+	// ListUserMemoryRevisions 按版本号倒序返回用户的历史版本，limit<=0 表示不限制。
+	ListUserMemoryRevisions(ctx context.Context, userID string, limit int) ([]*UserMemoryRevision, error)
+
+	// GetUserMemoryRevision 获取指定版本，不存在时返回 nil, nil。
+	GetUserMemoryRevision(ctx context.Context, userID, revisionID string) (*UserMemoryRevision, error)
+}
+
+// ErrUserMemoryConflict 用户记忆在读取之后已被其他写入修改
+var ErrUserMemoryConflict = errors.New("用户记忆已被其他写入修改")
+
+// ErrUserMemoryEventNotFound 指定的用户记忆事件不存在
+var ErrUserMemoryEventNotFound = errors.New("用户记忆事件不存在")
+
+// UserMemoryCASStorage 是可选扩展接口，按版本号比较并写入用户记忆（乐观并发控制）。
+// 未实现时管理器直接调用 UpsertUserMemory，多个进程并发写同一用户的记忆可能互相覆盖。
+type UserMemoryCASStorage interface {
+	// CompareAndSwapUserMemory 仅当当前版本等于 expectedVersion 时写入（记录不存在视为版本 0），
+	// 成功后 memory.Version 为新的版本号；版本不一致时返回 ErrUserMemoryConflict。
+	CompareAndSwapUserMemory(ctx context.Context, memory *UserMemory, expectedVersion int64) error
+	// CompareAndDeleteUserMemory 仅当当前版本等于 expectedVersion 时删除用户记忆，版本不一致时返回 ErrUserMemoryConflict
+	CompareAndDeleteUserMemory(ctx context.Context, userID string, expectedVersion int64) error
+}
+
+// UserMemoryEventAccessStorage 是可选扩展接口，记录事件被检索命中的次数与时间，
+// 供最近事件注入排序时参考。未实现时访问统计保持为零，不影响检索本身。
+type UserMemoryEventAccessStorage interface {
+	// TouchUserMemoryEvents 将 eventIDs 的访问次数加一，并把最近访问时间更新为 at。
+	TouchUserMemoryEvents(ctx context.Context, userID string, eventIDs []string, at time.Time) error
+}
+
+// SessionStorage 是可选扩展接口，保存会话元数据（标题、归档状态）并按用户列出会话，供会话侧边栏等界面使用。
+// 会话的消息数与最近活跃时间由消息统计得出，只有消息没有元数据的会话同样会被列出。
+type SessionStorage interface {
+	// ListSessions 按最近活跃时间倒序返回用户的会话，归档过滤与分页见 SessionQuery。
+	ListSessions(ctx context.Context, query *SessionQuery) ([]*Session, error)
+
+	// GetSession 获取会话信息，既没有消息也没有元数据时返回 nil, nil。
+	GetSession(ctx context.Context, sessionID, userID string) (*Session, error)
+
+	// SaveSession 创建或更新会话元数据（Title、Archived、ArchivedAt），MessageCount 与 LastActiveAt 不会被保存。
+	SaveSession(ctx context.Context, session *Session) error
+
+	// DeleteSession 删除会话元数据。消息与摘要由 DeleteMessages / DeleteSessionSummary 删除。
+	DeleteSession(ctx context.Context, sessionID, userID string) error
+}
+
+// SummaryTriggerStateStorage 是可选扩展接口，持久化摘要触发状态（未摘要消息数、token 数、话题向量），
+// 使进程重启后触发判断不会从零开始累计。未实现时状态只保存在内存中，重启后由未摘要消息重新估算。
+type SummaryTriggerStateStorage interface {
+	// GetSummaryTriggerState 获取会话的触发状态，不存在时返回 nil, nil。
+	GetSummaryTriggerState(ctx context.Context, sessionID, userID string) (*SessionState, error)
+
+	// SaveSummaryTriggerState 创建或覆盖会话的触发状态。
+	SaveSummaryTriggerState(ctx context.Context, sessionID, userID string, state *SessionState) error
+
+	// DeleteSummaryTriggerState 删除会话的触发状态。
+	DeleteSummaryTriggerState(ctx context.Context, sessionID, userID string) error
+}
+
+// SummaryChunkStorage 是可选扩展接口，保存分层摘要模式下的分段摘要。
+type SummaryChunkStorage interface {
+	// SaveSummaryChunk 保存一个分段摘要，ID 为空时由存储生成。
+	SaveSummaryChunk(ctx context.Context, chunk *SummaryChunk) error
+
+	// ListSummaryChunks 按时间顺序返回会话的全部分段摘要。
+	ListSummaryChunks(ctx context.Context, sessionID, userID string) ([]*SummaryChunk, error)
+
+	// DeleteSummaryChunks 删除会话的全部分段摘要。
+	DeleteSummaryChunks(ctx context.Context, sessionID, userID string) error
+}
+
+// UserDigestStorage 是可选扩展接口，保存跨会话的用户周期摘要。
+type UserDigestStorage interface {
+	// SaveUserDigest 保存一份周期摘要，ID 为空时由存储生成。
+	SaveUserDigest(ctx context.Context, digest *UserDigest) error
+
+	// ListUserDigests 按周期结束时间倒序返回用户的周期摘要，limit<=0 表示不限制。
+	ListUserDigests(ctx context.Context, userID string, limit int) ([]*UserDigest, error)
+}
+
+// AttachmentRefStorage 是可选扩展接口，列出消息中引用的外置附件，供附件垃圾回收使用。
+// 未实现时不会删除任何附件。
+type AttachmentRefStorage interface {
+	// ListAttachmentRefs 返回所有消息引用的附件 key（可重复），可用 AttachmentRefs 从 Parts 中解析。
+	ListAttachmentRefs(ctx context.Context) ([]string, error)
+}
+
+// RetentionStorage 是可选扩展接口，供保留策略（MemoryConfig.Retention）跨用户枚举会话，
+// 并按消息 ID 精确删除，使归档与删除的消息保持一致。未实现时保留策略不会执行。
+type RetentionStorage interface {
+	// ListMessageSessions 返回所有存在消息的会话（跨用户），只填充 SessionID、UserID、MessageCount、
+	// LastActiveAt 与 CreatedAt（第一条消息时间）。
+	ListMessageSessions(ctx context.Context) ([]*Session, error)
+
+	// DeleteMessagesByIDs 删除会话中指定 ID 的消息，返回实际删除的数量。
+	DeleteMessagesByIDs(ctx context.Context, sessionID, userID string, ids []string) (int, error)
+}
+
 // GormConversationStorage exposes the underlying gorm DB and message table
 // so builtin search can construct the default vector store without depending
 // on concrete storage implementations.
@@ -1,30 +1,117 @@
 package storage
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/CoolBanHub/aggo/memory/builtin"
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
+	"github.com/gookit/slog"
+)
+
+var errFileStoreLocked = errors.New("存储目录已被其他进程占用")
+
+const (
+	// fileStoreLockName 目录锁文件，防止多个进程同时写同一个目录
+	fileStoreLockName = "LOCK"
+	// fileStoreShardDir 用户分片的根目录
+	fileStoreShardDir = "users"
+	// compactMinGarbage 日志中被覆盖或删除的记录至少达到该数量，且不少于存活记录数时才自动压缩
+	compactMinGarbage = 128
 )
 
 // FileStore 基于文件的记忆存储实现
-// 分三个文件分别存储不同类型的数据，并在新增消息时支持修剪历史避免文件无限增长。
+// 每个用户的数据单独存放在 users/<hash>/ 目录下，每类数据一个只追加的日志文件，每条记录带 CRC32C 校验并在写入后 fsync。
+// 启动时不读取任何用户数据，用户首次被访问时才加载；日志中被覆盖、删除的记录由后台定期压缩清理。
+// 目录通过 LOCK 文件加排他锁，同一时刻只允许一个 FileStore 打开。
 type FileStore struct {
 	*MemoryStore
 	dirPath            string
 	maxSessionMessages int
-	fileMu             sync.Mutex // 防止并发写文件
+	sync               bool
+	compactInterval    time.Duration
+
+	lockFile *os.File
+
+	// loadMu 串行化分片加载与跨用户扫描
+	loadMu sync.Mutex
+	// shards 已加载的分片，分片目录 -> *fileShard
+	shards sync.Map
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// fileShard 一个已加载到内存的用户分片
+type fileShard struct {
+	dir string
+	// mu 串行化该分片的写入与压缩，保证日志顺序与内存修改顺序一致
+	mu sync.Mutex
+	// records 各日志文件中的记录条数（包括已被覆盖或删除的）
+	records map[string]int
+	// users 数据落在该分片的用户ID，压缩时只收集这些用户的记录
+	users sync.Map
+}
+
+// addUser 记录数据落在该分片的用户
+func (s *fileShard) addUser(userID string) {
+	if _, ok := s.users.Load(userID); !ok {
+		s.users.Store(userID, struct{}{})
+	}
+}
+
+// userIDs 返回数据落在该分片的用户ID
+func (s *fileShard) userIDs() []string {
+	var userIDs []string
+	s.users.Range(func(key, _ any) bool {
+		userIDs = append(userIDs, key.(string))
+		return true
+	})
+	sort.Strings(userIDs)
+	return userIDs
+}
+
+// FileStoreOption FileStore 配置项
+type FileStoreOption func(*FileStore)
+
+// WithFileSync 设置每次写入后是否 fsync，默认开启。关闭后写入更快，但断电可能丢失最近的写入。
+func WithFileSync(sync bool) FileStoreOption {
+	return func(f *FileStore) {
+		f.sync = sync
+	}
+}
+
+// WithCompactionInterval 设置后台压缩日志的间隔，默认 10 分钟，<= 0 时关闭后台压缩（仍可手动调用 Compact）
+func WithCompactionInterval(interval time.Duration) FileStoreOption {
+	return func(f *FileStore) {
+		f.compactInterval = interval
+	}
+}
+
+// WithFileTokenizer 指定关键词检索与事件匹配使用的分词器，应与 SearchConfig.Tokenizer 保持一致
+func WithFileTokenizer(tokenizer builtinsearch.Tokenizer) FileStoreOption {
+	return func(f *FileStore) {
+		f.MemoryStore.tokenizer = tokenizer
+	}
 }
 
 // NewFileStore 创建新的基于文件的存储实例
 // dirPath: 保存数据的目录路径
-// maxSessionMessages: 每个会话最大保存的消息数量，超过会裁剪旧消息。如果传 <= 0，默认保留100条。
-func NewFileStore(dirPath string, maxSessionMessages int) (*FileStore, error) {
+// maxSessionMessages: 每个会话最大保存的消息数量，超过会裁剪旧消息。如果传 <= 0，默认保留300条。
+// 目录已被其他 FileStore 打开时返回错误；旧版本的单文件数据会在首次打开时自动迁移到用户分片。
+func NewFileStore(dirPath string, maxSessionMessages int, opts ...FileStoreOption) (*FileStore, error) {
 	if maxSessionMessages <= 0 {
 		maxSessionMessages = 300
 	}
@@ -38,372 +125,928 @@ func NewFileStore(dirPath string, maxSessionMessages int) (*FileStore, error) {
 		MemoryStore:        NewMemoryStore(),
 		dirPath:            dirPath,
 		maxSessionMessages: maxSessionMessages,
+		sync:               true,
+		compactInterval:    10 * time.Minute,
+		stop:               make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(fs)
+		}
 	}
 
-	if err := fs.load(); err != nil {
+	lock, err := os.OpenFile(filepath.Join(dirPath, fileStoreLockName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
 		return nil, err
 	}
+	if err := lockFile(lock); err != nil {
+		_ = lock.Close()
+		if errors.Is(err, errFileStoreLocked) {
+			return nil, fmt.Errorf("%w: %s", errFileStoreLocked, dirPath)
+		}
+		return nil, fmt.Errorf("锁定存储目录失败: %w", err)
+	}
+	fs.lockFile = lock
+
+	if err := fs.migrateLegacy(); err != nil {
+		_ = fs.releaseLock()
+		return nil, fmt.Errorf("迁移旧版本文件失败: %w", err)
+	}
 
+	if fs.compactInterval > 0 {
+		fs.wg.Add(1)
+		go fs.compactLoop()
+	}
 	return fs, nil
 }
 
-// getFilePath 获取配置文件完整路径
-func (f *FileStore) getFilePath(name string) string {
-	return filepath.Join(f.dirPath, name+".json")
+// Close 停止后台压缩并释放目录锁，可以重复调用
+func (f *FileStore) Close() error {
+	var err error
+	f.closeOnce.Do(func() {
+		close(f.stop)
+		f.wg.Wait()
+		err = f.releaseLock()
+	})
+	if err != nil {
+		return err
+	}
+	return f.MemoryStore.Close()
 }
 
-// load 从拆分的三个文件加载数据
-func (f *FileStore) load() error {
-	f.fileMu.Lock()
-	defer f.fileMu.Unlock()
+func (f *FileStore) releaseLock() error {
+	if err := unlockFile(f.lockFile); err != nil {
+		_ = f.lockFile.Close()
+		return err
+	}
+	return f.lockFile.Close()
+}
 
-	f.MemoryStore.mu.Lock()
-	defer f.MemoryStore.mu.Unlock()
+// shardDir 返回用户分片目录，目录名取用户ID的哈希，避免用户ID中的特殊字符
+func (f *FileStore) shardDir(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	name := hex.EncodeToString(sum[:16])
+	return filepath.Join(f.dirPath, fileStoreShardDir, name[:2], name)
+}
 
-	// 1. 加载用户记忆 (JSONL 格式，每用户一条记录)
-	if data, err := os.ReadFile(f.getFilePath("user_memories")); err == nil {
-		userMemories := make(map[string]*builtin.UserMemory)
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			if strings.TrimSpace(line) == "" {
-				continue
-			}
-			var mem builtin.UserMemory
-			if err := json.Unmarshal([]byte(line), &mem); err == nil {
-				userMemories[mem.UserID] = &mem
-			}
-		}
-		f.MemoryStore.userMemories = userMemories
-	} else if !os.IsNotExist(err) {
-		return err
+// shardDirs 返回磁盘上所有的用户分片目录
+func (f *FileStore) shardDirs() ([]string, error) {
+	return filepath.Glob(filepath.Join(f.dirPath, fileStoreShardDir, "*", "*"))
+}
+
+// shard 返回用户的分片，未加载时从磁盘加载
+func (f *FileStore) shard(userID string) (*fileShard, error) {
+	dir := f.shardDir(userID)
+	if shard, ok := f.shards.Load(dir); ok {
+		shard.(*fileShard).addUser(userID)
+		return shard.(*fileShard), nil
 	}
 
-	// 2. 加载会话摘要 (JSONL 格式)
-	if data, err := os.ReadFile(f.getFilePath("session_summaries")); err == nil {
-		sessionSummaries := make(map[string]*builtin.SessionSummary)
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			if strings.TrimSpace(line) == "" {
-				continue
-			}
-			var summary builtin.SessionSummary
-			if err := json.Unmarshal([]byte(line), &summary); err == nil {
-				key := f.generateKey(summary.SessionID, summary.UserID)
-				sessionSummaries[key] = &summary // 遇到同名的会被后续行覆盖（符合更新逻辑）
-			}
+	f.loadMu.Lock()
+	defer f.loadMu.Unlock()
+	if shard, ok := f.shards.Load(dir); ok {
+		shard.(*fileShard).addUser(userID)
+		return shard.(*fileShard), nil
+	}
+	shard, err := loadShard(dir, f.MemoryStore)
+	if err != nil {
+		return nil, fmt.Errorf("加载用户数据失败: %w", err)
+	}
+	shard.addUser(userID)
+	if err := f.compactShard(shard, false); err != nil {
+		slog.Warnf("压缩用户分片 %s 失败: %v", dir, err)
+	}
+	f.shards.Store(dir, shard)
+	return shard, nil
+}
+
+// ensureLoaded 读取前确保用户数据已加载，userID 为空时交给 MemoryStore 校验
+func (f *FileStore) ensureLoaded(userID string) error {
+	if userID == "" {
+		return nil
+	}
+	_, err := f.shard(userID)
+	return err
+}
+
+// loadShard 回放分片目录下的所有日志并装入 m
+func loadShard(dir string, m *MemoryStore) (*fileShard, error) {
+	shard := &fileShard{dir: dir, records: make(map[string]int, len(fileTables))}
+	replays := make([]*logReplay, len(fileTables))
+	for i, table := range fileTables {
+		replay, err := replayLog(filepath.Join(dir, table.name+".log"))
+		if err != nil {
+			return nil, err
 		}
-		f.MemoryStore.sessionSummaries = sessionSummaries
-	} else if !os.IsNotExist(err) {
-		return err
+		replays[i] = replay
+		shard.records[table.name] = replay.records
 	}
 
-	// 3. 加载对话消息 (JSONL 格式)
-	if data, err := os.ReadFile(f.getFilePath("messages")); err == nil {
-		messages := make(map[string][]*builtin.ConversationMessage)
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			if strings.TrimSpace(line) == "" {
-				continue
-			}
-			var msg builtin.ConversationMessage
-			if err := json.Unmarshal([]byte(line), &msg); err == nil {
-				key := f.generateKey(msg.SessionID, msg.UserID)
-				messages[key] = append(messages[key], &msg)
-			}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, table := range fileTables {
+		if err := table.restore(m, replays[i].values); err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %w", table.name, err)
 		}
-		f.MemoryStore.messages = messages
-	} else if !os.IsNotExist(err) {
+	}
+	return shard, nil
+}
+
+// scanShards 对已加载的数据以及每个未加载的分片分别调用 fn，未加载的分片回放到临时 MemoryStore，不会常驻内存
+func (f *FileStore) scanShards(fn func(m *MemoryStore) error) error {
+	f.loadMu.Lock()
+	defer f.loadMu.Unlock()
+
+	if err := fn(f.MemoryStore); err != nil {
 		return err
 	}
+	dirs, err := f.shardDirs()
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if _, ok := f.shards.Load(dir); ok {
+			continue
+		}
+		scratch := NewMemoryStore()
+		if _, err := loadShard(dir, scratch); err != nil {
+			return fmt.Errorf("加载用户分片 %s 失败: %w", dir, err)
+		}
+		if err := fn(scratch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// 4. 加载用户记忆事件 (JSONL 格式)
-	if data, err := os.ReadFile(f.getFilePath("user_memory_events")); err == nil {
-		events := make(map[string][]*builtin.UserMemoryEvent)
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			if strings.TrimSpace(line) == "" {
-				continue
+// appendRecords 把记录追加到分片的日志文件，调用方持有 shard.mu
+func (f *FileStore) appendRecords(shard *fileShard, table *fileTable, records []logRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(shard.dir); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(shard.dir, 0755); err != nil {
+			return err
+		}
+		if f.sync {
+			if err := syncDir(filepath.Dir(shard.dir)); err != nil {
+				return err
 			}
-			var evt builtin.UserMemoryEvent
-			if err := json.Unmarshal([]byte(line), &evt); err == nil {
-				events[evt.UserID] = append(events[evt.UserID], &evt)
+			if err := syncDir(filepath.Dir(filepath.Dir(shard.dir))); err != nil {
+				return err
 			}
 		}
-		f.MemoryStore.userMemoryEvents = events
-	} else if !os.IsNotExist(err) {
+	}
+	if err := appendLog(filepath.Join(shard.dir, table.name+".log"), records, f.sync); err != nil {
 		return err
 	}
-
+	shard.records[table.name] += len(records)
 	return nil
 }
 
-// saveToFileB 原子的写入文件
-func (f *FileStore) saveToFileB(name string, b []byte) error {
-	filePath := f.getFilePath(name)
-	tmpFile := filePath + ".tmp"
-	if err := os.WriteFile(tmpFile, b, 0644); err != nil {
+// mutate 在用户分片锁内执行 fn，并把 fn 前后 tables 中该用户数据的差异追加到日志。
+// 追加日志失败时把尚未落盘的表回滚到 fn 之前的状态，保证内存与磁盘一致。
+func (f *FileStore) mutate(userID string, fn func() error, tables ...*fileTable) error {
+	if userID == "" {
+		return fn()
+	}
+	shard, err := f.shard(userID)
+	if err != nil {
 		return err
 	}
-	return os.Rename(tmpFile, filePath)
-}
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
 
-// saveUserMemories 重新全量保存用户记忆到 JSONL 文件
-func (f *FileStore) saveUserMemories() error {
+	before := make([]tableSnapshot, len(tables))
+	entries := make([][]fileEntry, len(tables))
 	f.MemoryStore.mu.RLock()
-	var lines []string
-	for _, mem := range f.MemoryStore.userMemories {
-		if b, err := json.Marshal(mem); err == nil {
-			lines = append(lines, string(b))
+	for i, table := range tables {
+		if before[i], entries[i], err = snapshotTable(f.MemoryStore, table, userID); err != nil {
+			break
 		}
 	}
 	f.MemoryStore.mu.RUnlock()
+	if err != nil {
+		return err
+	}
 
-	data := []byte(strings.Join(lines, "\n") + "\n")
-	if len(lines) == 0 {
-		data = []byte("")
+	if err := fn(); err != nil {
+		return err
 	}
 
-	f.fileMu.Lock()
-	defer f.fileMu.Unlock()
-	return f.saveToFileB("user_memories", data)
+	for i, table := range tables {
+		f.MemoryStore.mu.RLock()
+		records, err := diffTable(f.MemoryStore, table, userID, before[i])
+		f.MemoryStore.mu.RUnlock()
+		if err == nil {
+			err = f.appendRecords(shard, table, records)
+		}
+		if err != nil {
+			f.MemoryStore.mu.Lock()
+			defer f.MemoryStore.mu.Unlock()
+			for j := i; j < len(tables); j++ {
+				if rollbackErr := restoreTable(f.MemoryStore, tables[j], userID, entries[j], before[j]); rollbackErr != nil {
+					slog.Errorf("回滚用户 %s 的 %s 失败: %v", userID, tables[j].name, rollbackErr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
 }
 
-// saveSessionSummaries 重新全量保存会话摘要到 JSONL 文件
-func (f *FileStore) saveSessionSummaries() error {
-	f.MemoryStore.mu.RLock()
-	var lines []string
-	for _, summary := range f.MemoryStore.sessionSummaries {
-		if b, err := json.Marshal(summary); err == nil {
-			lines = append(lines, string(b))
-		}
+// appendEntry 在用户分片锁内执行 fn，再把 fn 写入的单条记录追加到日志，用于只新增记录的高频写入
+func (f *FileStore) appendEntry(userID string, table *fileTable, fn func() (fileEntry, error)) error {
+	if userID == "" {
+		_, err := fn()
+		return err
 	}
-	f.MemoryStore.mu.RUnlock()
+	shard, err := f.shard(userID)
+	if err != nil {
+		return err
+	}
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
 
-	data := []byte(strings.Join(lines, "\n") + "\n")
-	if len(lines) == 0 {
-		data = []byte("")
+	entry, err := fn()
+	if err != nil {
+		return err
+	}
+	records, err := entryRecords([]fileEntry{entry})
+	if err == nil {
+		err = f.appendRecords(shard, table, records)
+	}
+	if err != nil {
+		// 从内存中移除未能落盘的记录
+		f.MemoryStore.mu.Lock()
+		defer f.MemoryStore.mu.Unlock()
+		var kept []fileEntry
+		for _, e := range table.collect(f.MemoryStore, userID) {
+			if e.key != entry.key {
+				kept = append(kept, e)
+			}
+		}
+		if rollbackErr := restoreTable(f.MemoryStore, table, userID, kept, nil); rollbackErr != nil {
+			slog.Errorf("回滚用户 %s 的 %s 失败: %v", userID, table.name, rollbackErr)
+		}
+		return err
 	}
+	return nil
+}
 
-	f.fileMu.Lock()
-	defer f.fileMu.Unlock()
-	return f.saveToFileB("session_summaries", data)
+// Compact 立即压缩所有已加载分片中存在冗余记录的日志文件
+func (f *FileStore) Compact(ctx context.Context) error {
+	var errs []error
+	f.shards.Range(func(_, value any) bool {
+		shard := value.(*fileShard)
+		shard.mu.Lock()
+		err := f.compactShard(shard, true)
+		shard.mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", shard.dir, err))
+		}
+		return ctx.Err() == nil
+	})
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
 
-// appendSessionSummary 增量追加单条会话摘要到 JSONL 文件结尾
-func (f *FileStore) appendSessionSummary(summary *builtin.SessionSummary) error {
-	b, err := json.Marshal(summary)
-	if err != nil {
-		return err
+// compactLoop 定期压缩冗余记录较多的日志
+func (f *FileStore) compactLoop() {
+	defer f.wg.Done()
+	ticker := time.NewTicker(f.compactInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			f.shards.Range(func(_, value any) bool {
+				shard := value.(*fileShard)
+				shard.mu.Lock()
+				err := f.compactShard(shard, false)
+				shard.mu.Unlock()
+				if err != nil {
+					slog.Errorf("压缩用户分片 %s 失败: %v", shard.dir, err)
+				}
+				return true
+			})
+		}
 	}
-	b = append(b, '\n')
+}
 
-	f.fileMu.Lock()
-	defer f.fileMu.Unlock()
+// compactShard 用内存中的存活记录重写分片的日志文件。force 为 false 时只压缩冗余记录足够多的文件。
+// 调用方持有 shard.mu 或分片尚未发布。
+func (f *FileStore) compactShard(shard *fileShard, force bool) error {
+	for _, table := range fileTables {
+		total := shard.records[table.name]
+		if total == 0 {
+			continue
+		}
 
-	filePath := f.getFilePath("session_summaries")
-	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+		f.MemoryStore.mu.RLock()
+		entries := shardEntries(f.MemoryStore, table, shard.userIDs())
+		garbage := total - len(entries)
+		if garbage <= 0 || (!force && (garbage < compactMinGarbage || garbage < len(entries))) {
+			f.MemoryStore.mu.RUnlock()
+			continue
+		}
+		records, err := entryRecords(entries)
+		f.MemoryStore.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		if err := rewriteLog(filepath.Join(shard.dir, table.name+".log"), records, f.sync); err != nil {
+			return err
+		}
+		shard.records[table.name] = len(records)
 	}
-	defer file.Close()
+	return nil
+}
 
-	_, err = file.Write(b)
-	return err
+// shardEntries 返回内存中属于分片用户 userIDs 的全部记录，调用方持有读锁
+func shardEntries(m *MemoryStore, table *fileTable, userIDs []string) []fileEntry {
+	var entries []fileEntry
+	for _, userID := range userIDs {
+		entries = append(entries, table.collect(m, userID)...)
+	}
+	return entries
 }
 
-// saveMessages 重新全量保存对话消息到 JSONL 文件
-func (f *FileStore) saveMessages() error {
-	f.MemoryStore.mu.RLock()
-	var lines []string
-	for _, sessionMsgs := range f.MemoryStore.messages {
-		for _, msg := range sessionMsgs {
-			if b, err := json.Marshal(msg); err == nil {
-				lines = append(lines, string(b))
-			}
+// storeUserIDs 返回 m 中出现过的所有用户ID，调用方持有读锁
+func storeUserIDs(m *MemoryStore) []string {
+	seen := make(map[string]struct{})
+	add := func(userID string) {
+		if userID != "" {
+			seen[userID] = struct{}{}
 		}
 	}
-	f.MemoryStore.mu.RUnlock()
+	for _, mem := range m.userMemories {
+		add(mem.UserID)
+	}
+	for _, summary := range m.sessionSummaries {
+		add(summary.UserID)
+	}
+	for _, msgs := range m.messages {
+		if len(msgs) > 0 {
+			add(msgs[0].UserID)
+		}
+	}
+	for _, events := range m.userMemoryEvents {
+		for _, evt := range events {
+			add(evt.UserID)
+		}
+	}
+	for _, revisions := range m.userMemoryRevisions {
+		for _, revision := range revisions {
+			add(revision.UserID)
+		}
+	}
+	for _, session := range m.sessions {
+		add(session.UserID)
+	}
+	for _, record := range m.triggerStates {
+		add(record.UserID)
+	}
+	for _, chunks := range m.summaryChunks {
+		for _, chunk := range chunks {
+			add(chunk.UserID)
+		}
+	}
+	for userID := range m.userDigests {
+		add(userID)
+	}
 
-	// 拼接所有行，加换行符
-	data := []byte(strings.Join(lines, "\n") + "\n")
-	if len(lines) == 0 {
-		data = []byte("")
+	userIDs := make([]string, 0, len(seen))
+	for userID := range seen {
+		userIDs = append(userIDs, userID)
 	}
+	sort.Strings(userIDs)
+	return userIDs
+}
 
-	f.fileMu.Lock()
-	defer f.fileMu.Unlock()
-	return f.saveToFileB("messages", data)
+// legacyFileNames 旧版本单文件布局下的数据文件，<name>.json
+var legacyFileNames = []string{
+	"user_memories",
+	"session_summaries",
+	"messages",
+	"user_memory_events",
+	"user_memory_revisions",
+	"sessions",
+	"trigger_states",
+	"summary_chunks",
+	"user_digests",
 }
 
-// appendMessage 增量追加单条对话消息到 JSONL 文件结尾
-func (f *FileStore) appendMessage(msg *builtin.ConversationMessage) error {
-	b, err := json.Marshal(msg)
-	if err != nil {
+// migrateLegacy 把旧版本所有用户共用的 <name>.json 文件拆分写入用户分片，完成后把旧文件重命名为 <name>.json.migrated。
+// 分片文件整体重写，迁移中途崩溃后重新打开会再次完整迁移。
+func (f *FileStore) migrateLegacy() error {
+	var found []string
+	for _, name := range legacyFileNames {
+		path := filepath.Join(f.dirPath, name+".json")
+		if _, err := os.Stat(path); err == nil {
+			found = append(found, path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+
+	legacy := NewMemoryStore()
+	if err := loadLegacy(f.dirPath, legacy); err != nil {
 		return err
 	}
-	b = append(b, '\n')
 
-	f.fileMu.Lock()
-	defer f.fileMu.Unlock()
+	userIDs := storeUserIDs(legacy)
+	for _, userID := range userIDs {
+		dir := f.shardDir(userID)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		for _, table := range fileTables {
+			records, err := entryRecords(table.collect(legacy, userID))
+			if err != nil {
+				return err
+			}
+			if err := rewriteLog(filepath.Join(dir, table.name+".log"), records, f.sync); err != nil {
+				return err
+			}
+		}
+	}
+	for _, path := range found {
+		if err := os.Rename(path, path+".migrated"); err != nil {
+			return err
+		}
+	}
+	slog.Infof("已将 %d 个用户的旧版本文件迁移到 %s", len(userIDs), filepath.Join(f.dirPath, fileStoreShardDir))
+	return nil
+}
 
-	filePath := f.getFilePath("messages")
-	// 追加模式打开文件
-	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+// loadLegacy 读取旧版本的 JSONL 文件，无法解析的行直接跳过
+func loadLegacy(dir string, m *MemoryStore) error {
+	forEachLine := func(name string, fn func(line []byte) error) error {
+		file, err := os.Open(filepath.Join(dir, name+".json"))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+				_ = fn(line)
+			}
+		}
+		return scanner.Err()
+	}
+	// 旧文件没有校验，逐行解码后复用分片表的装载逻辑，同一主键后写覆盖先写
+	restore := func(table *fileTable) error {
+		return forEachLine(table.name, func(line []byte) error {
+			return table.restore(m, []json.RawMessage{append([]byte(nil), line...)})
+		})
 	}
-	defer file.Close()
 
-	_, err = file.Write(b)
-	return err
+	for _, table := range fileTables {
+		if err := restore(table); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// === 下面覆写所有可能修改数据的方法，按对应的表分离落地存储 ===
+// === 下面覆写所有可能修改数据的方法，修改内存后把差异追加到用户分片的日志 ===
 
 // UpsertUserMemory 创建或更新用户记忆
-// 由于每个用户只有一条记录，直接全量重写文件（记录数极少，开销可忽略）
 func (f *FileStore) UpsertUserMemory(ctx context.Context, userMemory *builtin.UserMemory) error {
-	if err := f.MemoryStore.UpsertUserMemory(ctx, userMemory); err != nil {
-		return err
+	if userMemory == nil {
+		return f.MemoryStore.UpsertUserMemory(ctx, userMemory)
+	}
+	return f.mutate(userMemory.UserID, func() error {
+		return f.MemoryStore.UpsertUserMemory(ctx, userMemory)
+	}, userMemoriesTable)
+}
+
+// CompareAndSwapUserMemory 当前版本等于 expectedVersion 时写入用户记忆
+func (f *FileStore) CompareAndSwapUserMemory(ctx context.Context, userMemory *builtin.UserMemory, expectedVersion int64) error {
+	if userMemory == nil {
+		return f.MemoryStore.CompareAndSwapUserMemory(ctx, userMemory, expectedVersion)
 	}
-	return f.saveUserMemories()
+	return f.mutate(userMemory.UserID, func() error {
+		return f.MemoryStore.CompareAndSwapUserMemory(ctx, userMemory, expectedVersion)
+	}, userMemoriesTable)
+}
+
+// CompareAndDeleteUserMemory 当前版本等于 expectedVersion 时删除用户记忆
+func (f *FileStore) CompareAndDeleteUserMemory(ctx context.Context, userID string, expectedVersion int64) error {
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.CompareAndDeleteUserMemory(ctx, userID, expectedVersion)
+	}, userMemoriesTable)
+}
+
+// SaveUserMemoryRevision 内存写入后追加持久化
+func (f *FileStore) SaveUserMemoryRevision(ctx context.Context, revision *builtin.UserMemoryRevision) error {
+	if revision == nil {
+		return f.MemoryStore.SaveUserMemoryRevision(ctx, revision)
+	}
+	return f.appendEntry(revision.UserID, userMemoryRevisionsTable, func() (fileEntry, error) {
+		err := f.MemoryStore.SaveUserMemoryRevision(ctx, revision)
+		return fileEntry{key: namespacedKey(revision.Namespace, revision.ID), value: revision}, err
+	})
 }
 
 // ClearUserMemory 清空用户记忆
 func (f *FileStore) ClearUserMemory(ctx context.Context, userID string) error {
-	if err := f.MemoryStore.ClearUserMemory(ctx, userID); err != nil {
-		return err
-	}
-	return f.saveUserMemories()
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.ClearUserMemory(ctx, userID)
+	}, userMemoriesTable)
 }
 
 func (f *FileStore) SaveSessionSummary(ctx context.Context, summary *builtin.SessionSummary) error {
-	if err := f.MemoryStore.SaveSessionSummary(ctx, summary); err != nil {
-		return err
+	if summary == nil {
+		return f.MemoryStore.SaveSessionSummary(ctx, summary)
 	}
-	return f.appendSessionSummary(summary)
+	return f.mutate(summary.UserID, func() error {
+		return f.MemoryStore.SaveSessionSummary(ctx, summary)
+	}, sessionSummariesTable)
 }
 
 func (f *FileStore) UpdateSessionSummary(ctx context.Context, summary *builtin.SessionSummary) error {
-	if err := f.MemoryStore.UpdateSessionSummary(ctx, summary); err != nil {
-		return err
+	if summary == nil {
+		return f.MemoryStore.UpdateSessionSummary(ctx, summary)
 	}
-	return f.appendSessionSummary(summary)
+	return f.mutate(summary.UserID, func() error {
+		return f.MemoryStore.UpdateSessionSummary(ctx, summary)
+	}, sessionSummariesTable)
 }
 
 func (f *FileStore) DeleteSessionSummary(ctx context.Context, sessionID string, userID string) error {
-	if err := f.MemoryStore.DeleteSessionSummary(ctx, sessionID, userID); err != nil {
-		return err
-	}
-	return f.saveSessionSummaries()
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.DeleteSessionSummary(ctx, sessionID, userID)
+	}, sessionSummariesTable)
 }
 
+// SaveMessage 追加消息，超过单会话上限时裁剪旧消息并追加删除记录
 func (f *FileStore) SaveMessage(ctx context.Context, message *builtin.ConversationMessage) error {
+	if message == nil || message.UserID == "" {
+		return f.MemoryStore.SaveMessage(ctx, message)
+	}
+	shard, err := f.shard(message.UserID)
+	if err != nil {
+		return err
+	}
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	// 保存会话原有消息，写日志失败时恢复，避免内存中留下未落盘的消息或被误裁剪的旧消息
+	key := f.MemoryStore.generateKey(message.SessionID, message.UserID)
+	f.MemoryStore.mu.RLock()
+	previous, existed := f.MemoryStore.messages[key]
+	previous = append([]*builtin.ConversationMessage(nil), previous...)
+	f.MemoryStore.mu.RUnlock()
+	rollback := func() {
+		f.MemoryStore.mu.Lock()
+		defer f.MemoryStore.mu.Unlock()
+		if existed {
+			f.MemoryStore.messages[key] = previous
+		} else {
+			delete(f.MemoryStore.messages, key)
+		}
+	}
+
 	if err := f.MemoryStore.SaveMessage(ctx, message); err != nil {
 		return err
 	}
+	records, err := entryRecords([]fileEntry{{key: message.ID, value: message}})
+	if err != nil {
+		rollback()
+		return err
+	}
 
 	// 控制消息最大保存数量
-	needRewrite := false
 	if f.maxSessionMessages > 0 {
 		count, _ := f.MemoryStore.GetMessageCount(ctx, message.UserID, message.SessionID)
 		if count > f.maxSessionMessages {
+			before, _ := f.MemoryStore.GetMessages(ctx, message.SessionID, message.UserID, 0)
 			_ = f.MemoryStore.CleanupMessagesByLimit(ctx, message.UserID, message.SessionID, f.maxSessionMessages)
-			needRewrite = true
+			after, _ := f.MemoryStore.GetMessages(ctx, message.SessionID, message.UserID, 0)
+			kept := make(map[string]struct{}, len(after))
+			for _, msg := range after {
+				kept[msg.ID] = struct{}{}
+			}
+			for _, msg := range before {
+				if _, ok := kept[msg.ID]; !ok {
+					records = append(records, logRecord{K: msg.ID})
+				}
+			}
 		}
 	}
-
-	if needRewrite {
-		return f.saveMessages() // 超过限额裁剪后，必须重写全量文件
+	if err := f.appendRecords(shard, messagesTable, records); err != nil {
+		rollback()
+		return err
 	}
-	return f.appendMessage(message) // 未超过额度时，直接以追加形式写入磁盘，O(1) 复杂度
+	return nil
 }
 
 func (f *FileStore) DeleteMessages(ctx context.Context, sessionID string, userID string) error {
-	if err := f.MemoryStore.DeleteMessages(ctx, sessionID, userID); err != nil {
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.DeleteMessages(ctx, sessionID, userID)
+	}, messagesTable)
+}
+
+func (f *FileStore) DeleteMessagesByIDs(ctx context.Context, sessionID, userID string, ids []string) (int, error) {
+	var deleted int
+	err := f.mutate(userID, func() error {
+		var err error
+		deleted, err = f.MemoryStore.DeleteMessagesByIDs(ctx, sessionID, userID, ids)
 		return err
-	}
-	return f.saveMessages()
+	}, messagesTable)
+	return deleted, err
 }
 
 func (f *FileStore) CleanupOldMessages(ctx context.Context, userID string, before time.Time) error {
-	if err := f.MemoryStore.CleanupOldMessages(ctx, userID, before); err != nil {
-		return err
-	}
-	return f.saveMessages()
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.CleanupOldMessages(ctx, userID, before)
+	}, messagesTable)
 }
 
 func (f *FileStore) CleanupMessagesByLimit(ctx context.Context, userID, sessionID string, keepLimit int) error {
-	if err := f.MemoryStore.CleanupMessagesByLimit(ctx, userID, sessionID, keepLimit); err != nil {
-		return err
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.CleanupMessagesByLimit(ctx, userID, sessionID, keepLimit)
+	}, messagesTable)
+}
+
+// SaveUserMemoryEvent 内存写入后追加持久化
+func (f *FileStore) SaveUserMemoryEvent(ctx context.Context, event *builtin.UserMemoryEvent) error {
+	if event == nil {
+		return f.MemoryStore.SaveUserMemoryEvent(ctx, event)
 	}
-	return f.saveMessages()
+	return f.appendEntry(event.UserID, userMemoryEventsTable, func() (fileEntry, error) {
+		err := f.MemoryStore.SaveUserMemoryEvent(ctx, event)
+		return fileEntry{key: namespacedKey(event.Namespace, event.ID), value: event}, err
+	})
 }
 
-// saveUserMemoryEvents 重新全量保存用户记忆事件到 JSONL 文件
-func (f *FileStore) saveUserMemoryEvents() error {
-	f.MemoryStore.mu.RLock()
-	var lines []string
-	for _, list := range f.MemoryStore.userMemoryEvents {
-		for _, evt := range list {
-			if b, err := json.Marshal(evt); err == nil {
-				lines = append(lines, string(b))
-			}
+// DeleteUserMemoryEvent 删除后追加删除记录
+func (f *FileStore) DeleteUserMemoryEvent(ctx context.Context, userID, eventID string) error {
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.DeleteUserMemoryEvent(ctx, userID, eventID)
+	}, userMemoryEventsTable)
+}
+
+// SupersedeUserMemoryEvents 标记后追加被修改的事件
+func (f *FileStore) SupersedeUserMemoryEvents(ctx context.Context, userID, supersededBy string, eventIDs []string) error {
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.SupersedeUserMemoryEvents(ctx, userID, supersededBy, eventIDs)
+	}, userMemoryEventsTable)
+}
+
+// TouchUserMemoryEvents 更新访问统计后追加被修改的事件
+func (f *FileStore) TouchUserMemoryEvents(ctx context.Context, userID string, eventIDs []string, at time.Time) error {
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.TouchUserMemoryEvents(ctx, userID, eventIDs, at)
+	}, userMemoryEventsTable)
+}
+
+// ClearUserMemoryEvents 清空后追加删除记录
+func (f *FileStore) ClearUserMemoryEvents(ctx context.Context, userID string) error {
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.ClearUserMemoryEvents(ctx, userID)
+	}, userMemoryEventsTable)
+}
+
+// SaveSession 保存会话元数据
+func (f *FileStore) SaveSession(ctx context.Context, session *builtin.Session) error {
+	if session == nil {
+		return f.MemoryStore.SaveSession(ctx, session)
+	}
+	return f.mutate(session.UserID, func() error {
+		return f.MemoryStore.SaveSession(ctx, session)
+	}, sessionsTable)
+}
+
+// DeleteSession 删除会话元数据
+func (f *FileStore) DeleteSession(ctx context.Context, sessionID, userID string) error {
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.DeleteSession(ctx, sessionID, userID)
+	}, sessionsTable)
+}
+
+// SaveSummaryTriggerState 保存摘要触发状态
+func (f *FileStore) SaveSummaryTriggerState(ctx context.Context, sessionID, userID string, state *builtin.SessionState) error {
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.SaveSummaryTriggerState(ctx, sessionID, userID, state)
+	}, triggerStatesTable)
+}
+
+// DeleteSummaryTriggerState 删除摘要触发状态
+func (f *FileStore) DeleteSummaryTriggerState(ctx context.Context, sessionID, userID string) error {
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.DeleteSummaryTriggerState(ctx, sessionID, userID)
+	}, triggerStatesTable)
+}
+
+// SaveSummaryChunk 内存写入后追加持久化
+func (f *FileStore) SaveSummaryChunk(ctx context.Context, chunk *builtin.SummaryChunk) error {
+	if chunk == nil {
+		return f.MemoryStore.SaveSummaryChunk(ctx, chunk)
+	}
+	return f.appendEntry(chunk.UserID, summaryChunksTable, func() (fileEntry, error) {
+		err := f.MemoryStore.SaveSummaryChunk(ctx, chunk)
+		return fileEntry{key: chunk.ID, value: chunk}, err
+	})
+}
+
+// DeleteSummaryChunks 删除分段摘要
+func (f *FileStore) DeleteSummaryChunks(ctx context.Context, sessionID, userID string) error {
+	return f.mutate(userID, func() error {
+		return f.MemoryStore.DeleteSummaryChunks(ctx, sessionID, userID)
+	}, summaryChunksTable)
+}
+
+// SaveUserDigest 内存写入后追加持久化
+func (f *FileStore) SaveUserDigest(ctx context.Context, digest *builtin.UserDigest) error {
+	if digest == nil {
+		return f.MemoryStore.SaveUserDigest(ctx, digest)
+	}
+	return f.appendEntry(digest.UserID, userDigestsTable, func() (fileEntry, error) {
+		err := f.MemoryStore.SaveUserDigest(ctx, digest)
+		return fileEntry{key: digest.ID, value: digest}, err
+	})
+}
+
+// === 下面覆写所有读取方法，读取前按需加载用户分片 ===
+
+func (f *FileStore) GetUserMemory(ctx context.Context, userID string) (*builtin.UserMemory, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
+	}
+	return f.MemoryStore.GetUserMemory(ctx, userID)
+}
+
+func (f *FileStore) ListUserMemoryRevisions(ctx context.Context, userID string, limit int) ([]*builtin.UserMemoryRevision, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
+	}
+	return f.MemoryStore.ListUserMemoryRevisions(ctx, userID, limit)
+}
+
+func (f *FileStore) GetUserMemoryRevision(ctx context.Context, userID, revisionID string) (*builtin.UserMemoryRevision, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
+	}
+	return f.MemoryStore.GetUserMemoryRevision(ctx, userID, revisionID)
+}
+
+func (f *FileStore) GetSessionSummary(ctx context.Context, sessionID string, userID string) (*builtin.SessionSummary, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
+	}
+	return f.MemoryStore.GetSessionSummary(ctx, sessionID, userID)
+}
+
+func (f *FileStore) GetMessages(ctx context.Context, sessionID string, userID string, limit int) ([]*builtin.ConversationMessage, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
+	}
+	return f.MemoryStore.GetMessages(ctx, sessionID, userID, limit)
+}
+
+func (f *FileStore) GetUserMessages(ctx context.Context, userID string, limit int) ([]*builtin.ConversationMessage, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
+	}
+	return f.MemoryStore.GetUserMessages(ctx, userID, limit)
+}
+
+func (f *FileStore) SearchMessagesByKeywords(ctx context.Context, q *builtinsearch.SearchQuery) ([]*builtin.ConversationMessage, error) {
+	if q != nil {
+		if err := f.ensureLoaded(q.UserID); err != nil {
+			return nil, err
 		}
 	}
-	f.MemoryStore.mu.RUnlock()
+	return f.MemoryStore.SearchMessagesByKeywords(ctx, q)
+}
 
-	data := []byte(strings.Join(lines, "\n") + "\n")
-	if len(lines) == 0 {
-		data = []byte("")
+func (f *FileStore) GetMessagesAfter(ctx context.Context, sessionID string, userID string, afterMessageID string, afterTime time.Time, limit int) ([]*builtin.ConversationMessage, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
 	}
+	return f.MemoryStore.GetMessagesAfter(ctx, sessionID, userID, afterMessageID, afterTime, limit)
+}
 
-	f.fileMu.Lock()
-	defer f.fileMu.Unlock()
-	return f.saveToFileB("user_memory_events", data)
+func (f *FileStore) GetMessageCountAfter(ctx context.Context, sessionID string, userID string, afterMessageID string, afterTime time.Time) (int, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return 0, err
+	}
+	return f.MemoryStore.GetMessageCountAfter(ctx, sessionID, userID, afterMessageID, afterTime)
 }
 
-// appendUserMemoryEvent 增量追加单条事件到 JSONL 文件
-func (f *FileStore) appendUserMemoryEvent(evt *builtin.UserMemoryEvent) error {
-	b, err := json.Marshal(evt)
-	if err != nil {
-		return err
+func (f *FileStore) GetMessageCount(ctx context.Context, userID, sessionID string) (int, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return 0, err
 	}
-	b = append(b, '\n')
+	return f.MemoryStore.GetMessageCount(ctx, userID, sessionID)
+}
 
-	f.fileMu.Lock()
-	defer f.fileMu.Unlock()
+func (f *FileStore) ListSessions(ctx context.Context, query *builtin.SessionQuery) ([]*builtin.Session, error) {
+	if query != nil {
+		if err := f.ensureLoaded(query.UserID); err != nil {
+			return nil, err
+		}
+	}
+	return f.MemoryStore.ListSessions(ctx, query)
+}
 
-	filePath := f.getFilePath("user_memory_events")
-	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+func (f *FileStore) GetSession(ctx context.Context, sessionID, userID string) (*builtin.Session, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
 	}
-	defer file.Close()
-	_, err = file.Write(b)
-	return err
+	return f.MemoryStore.GetSession(ctx, sessionID, userID)
 }
 
-// SaveUserMemoryEvent 内存写入后追加持久化
-func (f *FileStore) SaveUserMemoryEvent(ctx context.Context, event *builtin.UserMemoryEvent) error {
-	if err := f.MemoryStore.SaveUserMemoryEvent(ctx, event); err != nil {
-		return err
+func (f *FileStore) GetSummaryTriggerState(ctx context.Context, sessionID, userID string) (*builtin.SessionState, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
 	}
-	return f.appendUserMemoryEvent(event)
+	return f.MemoryStore.GetSummaryTriggerState(ctx, sessionID, userID)
 }
 
-// DeleteUserMemoryEvent 删除后全量重写
-func (f *FileStore) DeleteUserMemoryEvent(ctx context.Context, userID, eventID string) error {
-	if err := f.MemoryStore.DeleteUserMemoryEvent(ctx, userID, eventID); err != nil {
-		return err
+func (f *FileStore) ListSummaryChunks(ctx context.Context, sessionID, userID string) ([]*builtin.SummaryChunk, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
 	}
-	return f.saveUserMemoryEvents()
+	return f.MemoryStore.ListSummaryChunks(ctx, sessionID, userID)
 }
 
-// ClearUserMemoryEvents 清空后全量重写
-func (f *FileStore) ClearUserMemoryEvents(ctx context.Context, userID string) error {
-	if err := f.MemoryStore.ClearUserMemoryEvents(ctx, userID); err != nil {
-		return err
+func (f *FileStore) ListUserDigests(ctx context.Context, userID string, limit int) ([]*builtin.UserDigest, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
 	}
-	return f.saveUserMemoryEvents()
+	return f.MemoryStore.ListUserDigests(ctx, userID, limit)
+}
+
+func (f *FileStore) ListRecentUserMemoryEvents(ctx context.Context, userID string, limit int) ([]*builtin.UserMemoryEvent, error) {
+	if err := f.ensureLoaded(userID); err != nil {
+		return nil, err
+	}
+	return f.MemoryStore.ListRecentUserMemoryEvents(ctx, userID, limit)
+}
+
+func (f *FileStore) SearchUserMemoryEvents(ctx context.Context, query *builtin.UserMemoryEventQuery) ([]*builtin.UserMemoryEvent, error) {
+	if query != nil {
+		if err := f.ensureLoaded(query.UserID); err != nil {
+			return nil, err
+		}
+	}
+	return f.MemoryStore.SearchUserMemoryEvents(ctx, query)
+}
+
+// === 跨用户的维护操作逐个扫描分片，不会把未加载的用户留在内存中 ===
+
+// ListAttachmentRefs 返回所有消息引用的外置附件 key
+func (f *FileStore) ListAttachmentRefs(ctx context.Context) ([]string, error) {
+	var refs []string
+	err := f.scanShards(func(m *MemoryStore) error {
+		list, err := m.ListAttachmentRefs(ctx)
+		refs = append(refs, list...)
+		return err
+	})
+	return refs, err
+}
+
+// ListMessageSessions 返回所有存在消息的会话（跨用户）
+func (f *FileStore) ListMessageSessions(ctx context.Context) ([]*builtin.Session, error) {
+	var sessions []*builtin.Session
+	err := f.scanShards(func(m *MemoryStore) error {
+		list, err := m.ListMessageSessions(ctx)
+		sessions = append(sessions, list...)
+		return err
+	})
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].UserID != sessions[j].UserID {
+			return sessions[i].UserID < sessions[j].UserID
+		}
+		return sessions[i].SessionID < sessions[j].SessionID
+	})
+	return sessions, err
+}
+
+// ListUserMemoryEventOwners 返回在 since 之后新增过事件的记忆归属（跨所有命名空间）
+func (f *FileStore) ListUserMemoryEventOwners(ctx context.Context, since time.Time) ([]builtin.UserMemoryOwner, error) {
+	var owners []builtin.UserMemoryOwner
+	err := f.scanShards(func(m *MemoryStore) error {
+		list, err := m.ListUserMemoryEventOwners(ctx, since)
+		owners = append(owners, list...)
+		return err
+	})
+	sortUserMemoryOwners(owners)
+	return owners, err
 }
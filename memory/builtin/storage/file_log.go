@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+
+	"github.com/gookit/slog"
+)
+
+// logRecord 日志中的一条记录，按主键 K 后写覆盖先写；V 为空表示删除该主键
+type logRecord struct {
+	K string          `json:"k"`
+	V json.RawMessage `json:"v,omitempty"`
+}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// encodeLogLine 把记录编码为一行：8 位十六进制 CRC32C、空格、JSON、换行
+func encodeLogLine(rec logRecord) ([]byte, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, len(data)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.Checksum(data, crcTable))
+	line = append(line, data...)
+	return append(line, '\n'), nil
+}
+
+// decodeLogLine 校验并解析一行（不含换行），校验失败返回 false
+func decodeLogLine(line []byte) (logRecord, bool) {
+	var rec logRecord
+	if len(line) < 10 || line[8] != ' ' {
+		return rec, false
+	}
+	var sum [4]byte
+	if _, err := hex.Decode(sum[:], line[:8]); err != nil {
+		return rec, false
+	}
+	data := line[9:]
+	want := uint32(sum[0])<<24 | uint32(sum[1])<<16 | uint32(sum[2])<<8 | uint32(sum[3])
+	if crc32.Checksum(data, crcTable) != want {
+		return rec, false
+	}
+	if err := json.Unmarshal(data, &rec); err != nil || rec.K == "" {
+		return rec, false
+	}
+	return rec, true
+}
+
+// logReplay 回放一个日志文件的结果
+type logReplay struct {
+	// values 按主键首次写入的顺序排列的存活记录
+	values []json.RawMessage
+	// records 文件中校验通过的记录数（包括已被覆盖或删除的）
+	records int
+}
+
+// replayLog 读取并回放日志文件，文件不存在时返回空结果。
+// 文件末尾不完整或校验失败的记录视为写入中途崩溃，直接截断；中间的损坏记录跳过并打印警告。
+func replayLog(path string) (*logReplay, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &logReplay{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		keys     []string
+		values   = make(map[string]json.RawMessage)
+		records  int
+		corrupt  int
+		tailBad  int
+		goodEnd  int
+		position int
+	)
+	for position < len(data) {
+		end := bytes.IndexByte(data[position:], '\n')
+		if end < 0 {
+			// 最后一行没有换行符，一定是写入不完整
+			corrupt++
+			tailBad++
+			break
+		}
+		line := data[position : position+end]
+		position += end + 1
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		rec, ok := decodeLogLine(line)
+		if !ok {
+			corrupt++
+			tailBad++
+			continue
+		}
+		tailBad = 0
+		goodEnd = position
+		records++
+		if _, exists := values[rec.K]; !exists {
+			keys = append(keys, rec.K)
+		}
+		values[rec.K] = rec.V
+	}
+
+	if corrupt -= tailBad; corrupt > 0 {
+		slog.Warnf("日志文件 %s 中有 %d 条记录校验失败，已跳过", path, corrupt)
+	}
+	if goodEnd < len(data) && len(bytes.TrimSpace(data[goodEnd:])) > 0 {
+		slog.Warnf("日志文件 %s 末尾有 %d 字节不完整的写入，已截断", path, len(data)-goodEnd)
+		if err := os.Truncate(path, int64(goodEnd)); err != nil {
+			return nil, fmt.Errorf("截断日志文件失败: %w", err)
+		}
+	}
+
+	replay := &logReplay{records: records}
+	for _, key := range keys {
+		if value := values[key]; len(value) > 0 {
+			replay.values = append(replay.values, value)
+		}
+	}
+	return replay, nil
+}
+
+// appendLog 把记录追加到日志文件，sync 为 true 时在返回前 fsync
+func appendLog(path string, records []logRecord, sync bool) error {
+	var buf bytes.Buffer
+	for _, rec := range records {
+		line, err := encodeLogLine(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	_, statErr := os.Stat(path)
+	created := errors.Is(statErr, os.ErrNotExist)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if sync {
+		if err := file.Sync(); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if sync && created {
+		return syncDir(filepath.Dir(path))
+	}
+	return nil
+}
+
+// rewriteLog 用 records 原子替换日志文件：写临时文件、fsync、重命名、fsync 目录。records 为空时删除文件。
+func rewriteLog(path string, records []logRecord, sync bool) error {
+	if len(records) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if sync {
+			return syncDir(filepath.Dir(path))
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range records {
+		line, err := encodeLogLine(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+	}
+
+	tmpFile := path + ".tmp"
+	file, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if sync {
+		if err := file.Sync(); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		return err
+	}
+	if sync {
+		return syncDir(filepath.Dir(path))
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+)
+
+// fileEntry 内存中的一条记录及其日志主键
+type fileEntry struct {
+	key   string
+	value any
+}
+
+// fileTable 描述一类数据如何在用户分片的日志文件中存取
+type fileTable struct {
+	name string
+	// immutable 为 true 时记录写入后不会被原地修改，比较快照时只比较主键
+	immutable bool
+	// collect 返回内存中属于 userID 的全部记录，调用方持有读锁
+	collect func(m *MemoryStore, userID string) []fileEntry
+	// restore 把日志回放得到的记录装入内存，调用方持有写锁
+	restore func(m *MemoryStore, values []json.RawMessage) error
+	// drop 从内存中移除 userID 在该表中的全部记录，调用方持有写锁
+	drop func(m *MemoryStore, userID string)
+}
+
+var (
+	userMemoriesTable = &fileTable{
+		name: "user_memories",
+		collect: func(m *MemoryStore, userID string) []fileEntry {
+			var entries []fileEntry
+			for _, mem := range m.userMemories {
+				if mem.UserID == userID {
+					entries = append(entries, fileEntry{key: namespacedKey(mem.Namespace, "memory"), value: mem})
+				}
+			}
+			return entries
+		},
+		restore: func(m *MemoryStore, values []json.RawMessage) error {
+			return decodeEach(values, func(mem *builtin.UserMemory) {
+				m.userMemories[builtin.UserMemoryOwner{Namespace: mem.Namespace, UserID: mem.UserID}.Key()] = mem
+			})
+		},
+		drop: func(m *MemoryStore, userID string) {
+			dropWhere(m.userMemories, func(mem *builtin.UserMemory) bool { return mem.UserID == userID })
+		},
+	}
+
+	sessionSummariesTable = &fileTable{
+		name: "session_summaries",
+		collect: func(m *MemoryStore, userID string) []fileEntry {
+			var entries []fileEntry
+			for _, summary := range m.sessionSummaries {
+				if summary.UserID == userID {
+					entries = append(entries, fileEntry{key: summary.SessionID, value: summary})
+				}
+			}
+			return entries
+		},
+		restore: func(m *MemoryStore, values []json.RawMessage) error {
+			return decodeEach(values, func(summary *builtin.SessionSummary) {
+				m.sessionSummaries[m.generateKey(summary.SessionID, summary.UserID)] = summary
+			})
+		},
+		drop: func(m *MemoryStore, userID string) {
+			dropWhere(m.sessionSummaries, func(summary *builtin.SessionSummary) bool { return summary.UserID == userID })
+		},
+	}
+
+	messagesTable = &fileTable{
+		name:      "messages",
+		immutable: true,
+		collect: func(m *MemoryStore, userID string) []fileEntry {
+			var entries []fileEntry
+			for _, msgs := range m.messages {
+				if len(msgs) == 0 || msgs[0].UserID != userID {
+					continue
+				}
+				for _, msg := range msgs {
+					entries = append(entries, fileEntry{key: msg.ID, value: msg})
+				}
+			}
+			return entries
+		},
+		restore: func(m *MemoryStore, values []json.RawMessage) error {
+			return decodeEach(values, func(msg *builtin.ConversationMessage) {
+				key := m.generateKey(msg.SessionID, msg.UserID)
+				m.messages[key] = append(m.messages[key], msg)
+			})
+		},
+		drop: func(m *MemoryStore, userID string) {
+			dropMatching(m.messages, func(msg *builtin.ConversationMessage) bool { return msg.UserID == userID })
+		},
+	}
+
+	userMemoryEventsTable = &fileTable{
+		name: "user_memory_events",
+		collect: func(m *MemoryStore, userID string) []fileEntry {
+			var entries []fileEntry
+			for _, events := range m.userMemoryEvents {
+				for _, evt := range events {
+					if evt.UserID == userID {
+						entries = append(entries, fileEntry{key: namespacedKey(evt.Namespace, evt.ID), value: evt})
+					}
+				}
+			}
+			return entries
+		},
+		restore: func(m *MemoryStore, values []json.RawMessage) error {
+			return decodeEach(values, func(evt *builtin.UserMemoryEvent) {
+				key := builtin.UserMemoryOwner{Namespace: evt.Namespace, UserID: evt.UserID}.Key()
+				m.userMemoryEvents[key] = append(m.userMemoryEvents[key], evt)
+			})
+		},
+		drop: func(m *MemoryStore, userID string) {
+			dropMatching(m.userMemoryEvents, func(evt *builtin.UserMemoryEvent) bool { return evt.UserID == userID })
+		},
+	}
+
+	userMemoryRevisionsTable = &fileTable{
+		name:      "user_memory_revisions",
+		immutable: true,
+		collect: func(m *MemoryStore, userID string) []fileEntry {
+			var entries []fileEntry
+			for _, revisions := range m.userMemoryRevisions {
+				for _, revision := range revisions {
+					if revision.UserID == userID {
+						entries = append(entries, fileEntry{key: namespacedKey(revision.Namespace, revision.ID), value: revision})
+					}
+				}
+			}
+			return entries
+		},
+		restore: func(m *MemoryStore, values []json.RawMessage) error {
+			return decodeEach(values, func(revision *builtin.UserMemoryRevision) {
+				key := builtin.UserMemoryOwner{Namespace: revision.Namespace, UserID: revision.UserID}.Key()
+				m.userMemoryRevisions[key] = append(m.userMemoryRevisions[key], revision)
+			})
+		},
+		drop: func(m *MemoryStore, userID string) {
+			dropMatching(m.userMemoryRevisions, func(revision *builtin.UserMemoryRevision) bool { return revision.UserID == userID })
+		},
+	}
+
+	sessionsTable = &fileTable{
+		name: "sessions",
+		collect: func(m *MemoryStore, userID string) []fileEntry {
+			var entries []fileEntry
+			for _, session := range m.sessions {
+				if session.UserID == userID {
+					entries = append(entries, fileEntry{key: session.SessionID, value: session})
+				}
+			}
+			return entries
+		},
+		restore: func(m *MemoryStore, values []json.RawMessage) error {
+			return decodeEach(values, func(session *builtin.Session) {
+				m.sessions[m.generateKey(session.SessionID, session.UserID)] = session
+			})
+		},
+		drop: func(m *MemoryStore, userID string) {
+			dropWhere(m.sessions, func(session *builtin.Session) bool { return session.UserID == userID })
+		},
+	}
+
+	triggerStatesTable = &fileTable{
+		name: "trigger_states",
+		collect: func(m *MemoryStore, userID string) []fileEntry {
+			var entries []fileEntry
+			for _, record := range m.triggerStates {
+				if record.UserID == userID {
+					entries = append(entries, fileEntry{key: record.SessionID, value: record})
+				}
+			}
+			return entries
+		},
+		restore: func(m *MemoryStore, values []json.RawMessage) error {
+			return decodeEach(values, func(record *triggerStateRecord) {
+				if record.State != nil {
+					m.triggerStates[m.generateKey(record.SessionID, record.UserID)] = record
+				}
+			})
+		},
+		drop: func(m *MemoryStore, userID string) {
+			dropWhere(m.triggerStates, func(record *triggerStateRecord) bool { return record.UserID == userID })
+		},
+	}
+
+	summaryChunksTable = &fileTable{
+		name:      "summary_chunks",
+		immutable: true,
+		collect: func(m *MemoryStore, userID string) []fileEntry {
+			var entries []fileEntry
+			for _, chunks := range m.summaryChunks {
+				for _, chunk := range chunks {
+					if chunk.UserID == userID {
+						entries = append(entries, fileEntry{key: chunk.ID, value: chunk})
+					}
+				}
+			}
+			return entries
+		},
+		restore: func(m *MemoryStore, values []json.RawMessage) error {
+			touched := make(map[string]struct{})
+			err := decodeEach(values, func(chunk *builtin.SummaryChunk) {
+				key := m.generateKey(chunk.SessionID, chunk.UserID)
+				m.summaryChunks[key] = append(m.summaryChunks[key], chunk)
+				touched[key] = struct{}{}
+			})
+			for key := range touched {
+				sortSummaryChunks(m.summaryChunks[key])
+			}
+			return err
+		},
+		drop: func(m *MemoryStore, userID string) {
+			dropMatching(m.summaryChunks, func(chunk *builtin.SummaryChunk) bool { return chunk.UserID == userID })
+		},
+	}
+
+	userDigestsTable = &fileTable{
+		name:      "user_digests",
+		immutable: true,
+		collect: func(m *MemoryStore, userID string) []fileEntry {
+			var entries []fileEntry
+			for _, digest := range m.userDigests[userID] {
+				entries = append(entries, fileEntry{key: digest.ID, value: digest})
+			}
+			return entries
+		},
+		restore: func(m *MemoryStore, values []json.RawMessage) error {
+			return decodeEach(values, func(digest *builtin.UserDigest) {
+				m.userDigests[digest.UserID] = append(m.userDigests[digest.UserID], digest)
+			})
+		},
+		drop: func(m *MemoryStore, userID string) {
+			delete(m.userDigests, userID)
+		},
+	}
+
+	// fileTables 每个用户分片目录下的全部日志文件
+	fileTables = []*fileTable{
+		userMemoriesTable,
+		sessionSummariesTable,
+		messagesTable,
+		userMemoryEventsTable,
+		userMemoryRevisionsTable,
+		sessionsTable,
+		triggerStatesTable,
+		summaryChunksTable,
+		userDigestsTable,
+	}
+)
+
+// namespacedKey 带命名空间的日志主键，保证不同命名空间下的同名 ID 不互相覆盖
+func namespacedKey(namespace, id string) string {
+	if namespace == "" {
+		return id
+	}
+	return namespace + "\x00" + id
+}
+
+// decodeEach 逐条解码记录并交给 fn
+func decodeEach[T any](values []json.RawMessage, fn func(*T)) error {
+	for _, value := range values {
+		item := new(T)
+		if err := json.Unmarshal(value, item); err != nil {
+			return err
+		}
+		fn(item)
+	}
+	return nil
+}
+
+// tableSnapshot 某张表在某一时刻的记录，主键 -> 编码后的 JSON（immutable 表只记录主键）
+type tableSnapshot map[string][]byte
+
+// snapshotTable 对 userID 在表中的记录做快照，同时返回快照时的记录供回滚使用，调用方持有读锁
+func snapshotTable(m *MemoryStore, table *fileTable, userID string) (tableSnapshot, []fileEntry, error) {
+	entries := table.collect(m, userID)
+	snapshot := make(tableSnapshot, len(entries))
+	for _, entry := range entries {
+		if table.immutable {
+			snapshot[entry.key] = nil
+			continue
+		}
+		data, err := json.Marshal(entry.value)
+		if err != nil {
+			return nil, nil, err
+		}
+		snapshot[entry.key] = data
+	}
+	return snapshot, entries, nil
+}
+
+// diffTable 比较修改前的快照与当前内存中的记录，返回需要追加的日志记录，调用方持有读锁
+func diffTable(m *MemoryStore, table *fileTable, userID string, before tableSnapshot) ([]logRecord, error) {
+	var records []logRecord
+	seen := make(map[string]struct{}, len(before))
+	for _, entry := range table.collect(m, userID) {
+		seen[entry.key] = struct{}{}
+		old, existed := before[entry.key]
+		if existed && table.immutable {
+			continue
+		}
+		data, err := json.Marshal(entry.value)
+		if err != nil {
+			return nil, err
+		}
+		if existed && bytes.Equal(old, data) {
+			continue
+		}
+		records = append(records, logRecord{K: entry.key, V: data})
+	}
+	for key := range before {
+		if _, ok := seen[key]; !ok {
+			records = append(records, logRecord{K: key})
+		}
+	}
+	return records, nil
+}
+
+// entryRecords 把记录编码为写入日志的 put 记录
+func entryRecords(entries []fileEntry) ([]logRecord, error) {
+	records := make([]logRecord, 0, len(entries))
+	for _, entry := range entries {
+		data, err := json.Marshal(entry.value)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, logRecord{K: entry.key, V: data})
+	}
+	return records, nil
+}
+
+// restoreTable 把 userID 在表中的记录恢复为 entries。
+// 可变表的记录可能已被原地修改，优先使用 encoded 中快照时的编码；immutable 表的记录不会被修改，直接重新编码。
+// 调用方持有写锁。
+func restoreTable(m *MemoryStore, table *fileTable, userID string, entries []fileEntry, encoded tableSnapshot) error {
+	values := make([]json.RawMessage, 0, len(entries))
+	for _, entry := range entries {
+		data := encoded[entry.key]
+		if data == nil {
+			var err error
+			if data, err = json.Marshal(entry.value); err != nil {
+				return err
+			}
+		}
+		values = append(values, data)
+	}
+	table.drop(m, userID)
+	return table.restore(m, values)
+}
+
+// dropMatching 删除各列表中满足 match 的记录，列表因此变空时删除整个 key
+func dropMatching[T any](items map[string][]T, match func(T) bool) {
+	for key, list := range items {
+		kept := list[:0:0]
+		for _, item := range list {
+			if !match(item) {
+				kept = append(kept, item)
+			}
+		}
+		switch {
+		case len(kept) == len(list):
+		case len(kept) == 0:
+			delete(items, key)
+		default:
+			items[key] = kept
+		}
+	}
+}
+
+// dropWhere 删除满足 match 的记录
+func dropWhere[T any](items map[string]T, match func(T) bool) {
+	for key, item := range items {
+		if match(item) {
+			delete(items, key)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+)
+
+func TestFileStore_LockAndCrashRecovery(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir, 0)
+	if err != nil {
+		t.Fatalf("new file store err: %v", err)
+	}
+	if _, err := NewFileStore(dir, 0); !errors.Is(err, errFileStoreLocked) {
+		t.Fatalf("second open should fail with lock error, got %v", err)
+	}
+	for _, content := range []string{"第一条", "第二条", "第三条"} {
+		if err := store.SaveMessage(ctx, &builtin.ConversationMessage{SessionID: "s1", UserID: "u1", Role: "user", Content: content}); err != nil {
+			t.Fatalf("save message err: %v", err)
+		}
+	}
+	logPath := filepath.Join(store.shardDir("u1"), messagesTable.name+".log")
+	if err := store.Close(); err != nil {
+		t.Fatalf("close err: %v", err)
+	}
+
+	// 模拟中间一条记录损坏、末尾写入中途崩溃
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read log err: %v", err)
+	}
+	lines := splitLines(data)
+	lines[1][20] ^= 0xff
+	corrupted := append(joinLines(lines), []byte(`0000abcd {"k":"torn","v":{"con`)...)
+	if err := os.WriteFile(logPath, corrupted, 0644); err != nil {
+		t.Fatalf("write log err: %v", err)
+	}
+
+	store, err = NewFileStore(dir, 0)
+	if err != nil {
+		t.Fatalf("reopen err: %v", err)
+	}
+	defer store.Close()
+	msgs, err := store.GetMessages(ctx, "s1", "u1", 0)
+	if err != nil {
+		t.Fatalf("get messages err: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].Content != "第一条" || msgs[1].Content != "第三条" {
+		t.Fatalf("expected the two intact messages, got %+v", msgs)
+	}
+	if data, _ := os.ReadFile(logPath); len(splitLines(data)) != 3 || data[len(data)-1] != '\n' {
+		t.Fatalf("torn tail should be truncated, got %q", data)
+	}
+}
+
+func TestFileStore_LazyLoadAndCompaction(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir, 0, WithCompactionInterval(0))
+	if err != nil {
+		t.Fatalf("new file store err: %v", err)
+	}
+	for _, userID := range []string{"u1", "u2"} {
+		if err := store.SaveMessage(ctx, &builtin.ConversationMessage{SessionID: "s1", UserID: userID, Role: "user", Content: "你好"}); err != nil {
+			t.Fatalf("save message err: %v", err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if err := store.UpsertUserMemory(ctx, &builtin.UserMemory{UserID: "u1", Memory: "版本" + string(rune('A'+i))}); err != nil {
+			t.Fatalf("upsert memory err: %v", err)
+		}
+	}
+	_ = store.Close()
+
+	store, err = NewFileStore(dir, 0, WithCompactionInterval(0))
+	if err != nil {
+		t.Fatalf("reopen err: %v", err)
+	}
+	defer store.Close()
+
+	// 跨用户扫描不会把用户加载进内存
+	sessions, err := store.ListMessageSessions(ctx)
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("expected sessions of both users, got %d err=%v", len(sessions), err)
+	}
+	if len(store.MemoryStore.messages) != 0 {
+		t.Fatal("no user should be loaded before being accessed")
+	}
+
+	mem, err := store.GetUserMemory(ctx, "u1")
+	if err != nil || mem == nil || mem.Memory != "版本E" {
+		t.Fatalf("expected latest memory, got %+v err=%v", mem, err)
+	}
+	if _, ok := store.shards.Load(store.shardDir("u2")); ok {
+		t.Fatal("u2 should still be unloaded")
+	}
+
+	memoryLog := filepath.Join(store.shardDir("u1"), userMemoriesTable.name+".log")
+	if data, _ := os.ReadFile(memoryLog); len(splitLines(data)) != 5 {
+		t.Fatalf("expected 5 records before compaction, got %q", data)
+	}
+	if err := store.Compact(ctx); err != nil {
+		t.Fatalf("compact err: %v", err)
+	}
+	if data, _ := os.ReadFile(memoryLog); len(splitLines(data)) != 1 {
+		t.Fatalf("expected 1 record after compaction, got %q", data)
+	}
+	_ = store.Close()
+
+	store, err = NewFileStore(dir, 0, WithCompactionInterval(0))
+	if err != nil {
+		t.Fatalf("reopen err: %v", err)
+	}
+	defer store.Close()
+	if mem, _ := store.GetUserMemory(ctx, "u1"); mem == nil || mem.Memory != "版本E" {
+		t.Fatalf("memory lost after compaction: %+v", mem)
+	}
+}
+
+func TestFileStore_RollsBackFailedAppends(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name  string
+		table *fileTable
+		setup func(store *FileStore) error
+		write func(store *FileStore) error
+		check func(t *testing.T, store *FileStore)
+	}{
+		{
+			name:  "mutate",
+			table: userMemoriesTable,
+			setup: func(store *FileStore) error {
+				return store.UpsertUserMemory(ctx, &builtin.UserMemory{UserID: "u1", Memory: "旧记忆"})
+			},
+			write: func(store *FileStore) error {
+				return store.UpsertUserMemory(ctx, &builtin.UserMemory{UserID: "u1", Memory: "新记忆"})
+			},
+			check: func(t *testing.T, store *FileStore) {
+				if mem, _ := store.GetUserMemory(ctx, "u1"); mem == nil || mem.Memory != "旧记忆" {
+					t.Fatalf("memory should be rolled back, got %+v", mem)
+				}
+			},
+		},
+		{
+			name:  "save message with trimming",
+			table: messagesTable,
+			setup: func(store *FileStore) error {
+				for _, id := range []string{"m1", "m2"} {
+					if err := store.SaveMessage(ctx, &builtin.ConversationMessage{ID: id, SessionID: "s1", UserID: "u1", Role: "user", Content: id}); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			write: func(store *FileStore) error {
+				return store.SaveMessage(ctx, &builtin.ConversationMessage{ID: "m3", SessionID: "s1", UserID: "u1", Role: "user", Content: "m3"})
+			},
+			check: func(t *testing.T, store *FileStore) {
+				msgs, _ := store.GetMessages(ctx, "s1", "u1", 0)
+				if len(msgs) != 2 || msgs[0].ID != "m1" || msgs[1].ID != "m2" {
+					t.Fatalf("messages should be rolled back, got %+v", msgs)
+				}
+			},
+		},
+		{
+			name:  "append entry",
+			table: userMemoryEventsTable,
+			setup: func(store *FileStore) error {
+				return store.SaveUserMemoryEvent(ctx, &builtin.UserMemoryEvent{ID: "e1", UserID: "u1", Summary: "已落盘"})
+			},
+			write: func(store *FileStore) error {
+				return store.SaveUserMemoryEvent(ctx, &builtin.UserMemoryEvent{ID: "e2", UserID: "u1", Summary: "未落盘"})
+			},
+			check: func(t *testing.T, store *FileStore) {
+				events, _ := store.ListRecentUserMemoryEvents(ctx, "u1", 0)
+				if len(events) != 1 || events[0].ID != "e1" {
+					t.Fatalf("events should be rolled back, got %+v", events)
+				}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := NewFileStore(t.TempDir(), 2, WithCompactionInterval(0))
+			if err != nil {
+				t.Fatalf("new file store err: %v", err)
+			}
+			defer store.Close()
+			if err := tc.setup(store); err != nil {
+				t.Fatalf("setup err: %v", err)
+			}
+
+			// 把日志文件替换成同名目录，让追加写入失败
+			logPath := filepath.Join(store.shardDir("u1"), tc.table.name+".log")
+			if err := os.Remove(logPath); err != nil {
+				t.Fatalf("remove log err: %v", err)
+			}
+			if err := os.Mkdir(logPath, 0755); err != nil {
+				t.Fatalf("mkdir err: %v", err)
+			}
+			if err := tc.write(store); err == nil {
+				t.Fatal("write should fail when the log cannot be appended")
+			}
+			tc.check(t, store)
+		})
+	}
+}
+
+func TestFileStore_MigrateLegacyFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	legacy := `{"id":"m1","sessionId":"s1","userId":"u1","role":"user","content":"旧格式消息","createdAt":"2026-01-01T00:00:00Z"}` + "\n" +
+		`{"id":"m2","sessionId":"s1","userId":"u2","role":"user","content":"另一个用户","createdAt":"2026-01-01T00:00:00Z"}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "messages.json"), []byte(legacy), 0644); err != nil {
+		t.Fatalf("write legacy err: %v", err)
+	}
+
+	store, err := NewFileStore(dir, 0)
+	if err != nil {
+		t.Fatalf("new file store err: %v", err)
+	}
+	defer store.Close()
+	if _, err := os.Stat(filepath.Join(dir, "messages.json.migrated")); err != nil {
+		t.Fatalf("legacy file should be renamed: %v", err)
+	}
+	msgs, err := store.GetMessagesAfter(ctx, "s1", "u1", "", time.Time{}, 0)
+	if err != nil || len(msgs) != 1 || msgs[0].Content != "旧格式消息" {
+		t.Fatalf("expected migrated message, got %+v err=%v", msgs, err)
+	}
+}
+
+func splitLines(data []byte) [][]byte {
+	return bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+}
+
+func joinLines(lines [][]byte) []byte {
+	return append(bytes.Join(lines, []byte("\n")), '\n')
+}
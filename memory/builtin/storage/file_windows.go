@@ -0,0 +1,31 @@
+//go:build windows
+
+package storage
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile 对文件加非阻塞排他锁，进程退出时由操作系统自动释放
+func lockFile(file *os.File) error {
+	var overlapped windows.Overlapped
+	err := windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &overlapped)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errFileStoreLocked
+	}
+	return err
+}
+
+// unlockFile 释放 lockFile 加的锁
+func unlockFile(file *os.File) error {
+	var overlapped windows.Overlapped
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, &overlapped)
+}
+
+// syncDir Windows 不支持对目录 fsync，重命名在 NTFS 上由文件系统日志保证
+func syncDir(dir string) error {
+	return nil
+}
@@ -21,7 +21,7 @@ type MemoryStore struct {
 	// 读写锁，保证并发安全
 	mu sync.RWMutex
 
-	// 用户记忆存储 map[userID]*UserMemory
+	// 用户记忆存储 map[UserMemoryOwner.Key()]*UserMemory
 	userMemories map[string]*builtin.UserMemory
 
 	// 会话摘要存储 map[sessionID+userID]*SessionSummary
@@ -30,18 +30,58 @@ type MemoryStore struct {
 	// 对话消息存储 map[sessionID+userID][]*ConversationMessage
 	messages map[string][]*builtin.ConversationMessage
 
-	// 用户记忆事件存储 map[userID][]*UserMemoryEvent
+	// 用户记忆事件存储 map[UserMemoryOwner.Key()][]*UserMemoryEvent
 	userMemoryEvents map[string][]*builtin.UserMemoryEvent
+
+	// 用户记忆历史版本 map[UserMemoryOwner.Key()][]*UserMemoryRevision，按版本号升序
+	userMemoryRevisions map[string][]*builtin.UserMemoryRevision
+
+	// 会话元数据 map[sessionID+userID]*Session，只保存标题与归档状态
+	sessions map[string]*builtin.Session
+
+	// 摘要触发状态 map[sessionID+userID]*triggerStateRecord
+	triggerStates map[string]*triggerStateRecord
+
+	// 分段摘要 map[sessionID+userID][]*SummaryChunk，按时间顺序
+	summaryChunks map[string][]*builtin.SummaryChunk
+
+	// 用户周期摘要 map[userID][]*UserDigest
+	userDigests map[string][]*builtin.UserDigest
+
+	// 关键词检索与匹配使用的分词器，为空时使用 builtinsearch.DefaultTokenizer()
+	tokenizer builtinsearch.Tokenizer
+}
+
+// MemoryStoreOption MemoryStore 配置项
+type MemoryStoreOption func(*MemoryStore)
+
+// WithMemoryStoreTokenizer 指定关键词检索与事件匹配使用的分词器，应与 SearchConfig.Tokenizer 保持一致
+func WithMemoryStoreTokenizer(tokenizer builtinsearch.Tokenizer) MemoryStoreOption {
+	return func(m *MemoryStore) {
+		m.tokenizer = tokenizer
+	}
 }
 
 // NewMemoryStore 创建新的内存存储实例
-func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{
+func NewMemoryStore(opts ...MemoryStoreOption) *MemoryStore {
+	m := &MemoryStore{
 		userMemories:     make(map[string]*builtin.UserMemory),
 		sessionSummaries: make(map[string]*builtin.SessionSummary),
 		messages:         make(map[string][]*builtin.ConversationMessage),
 		userMemoryEvents: make(map[string][]*builtin.UserMemoryEvent),
+
+		userMemoryRevisions: make(map[string][]*builtin.UserMemoryRevision),
+		sessions:            make(map[string]*builtin.Session),
+		triggerStates:       make(map[string]*triggerStateRecord),
+		summaryChunks:       make(map[string][]*builtin.SummaryChunk),
+		userDigests:         make(map[string][]*builtin.UserDigest),
 	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(m)
+		}
+	}
+	return m
 }
 
 func (m *MemoryStore) AutoMigrate() error {
@@ -76,7 +116,73 @@ func (m *MemoryStore) UpsertUserMemory(ctx context.Context, userMemory *builtin.
 	userMemory.UpdatedAt = now
 
 	// 保存记忆
-	m.userMemories[userMemory.UserID] = userMemory
+	key := ownerKey(ctx, userMemory.UserID)
+	userMemory.Version = 1
+	if existing, ok := m.userMemories[key]; ok {
+		userMemory.Version = existing.Version + 1
+	}
+	userMemory.Namespace = builtin.NamespaceFromContext(ctx)
+	m.userMemories[key] = userMemory
+	return nil
+}
+
+// CompareAndSwapUserMemory 当前版本等于 expectedVersion 时写入用户记忆
+func (m *MemoryStore) CompareAndSwapUserMemory(ctx context.Context, userMemory *builtin.UserMemory, expectedVersion int64) error {
+	if userMemory == nil {
+		return errors.New("记忆对象不能为空")
+	}
+	if userMemory.UserID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	if userMemory.Memory == "" {
+		return errors.New("记忆内容不能为空")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := ownerKey(ctx, userMemory.UserID)
+	var current int64
+	existing, ok := m.userMemories[key]
+	if ok {
+		current = existing.Version
+	}
+	if current != expectedVersion {
+		return fmt.Errorf("%w: 期望版本 %d，当前版本 %d", builtin.ErrUserMemoryConflict, expectedVersion, current)
+	}
+
+	now := time.Now()
+	if userMemory.CreatedAt.IsZero() {
+		userMemory.CreatedAt = now
+		if ok {
+			userMemory.CreatedAt = existing.CreatedAt
+		}
+	}
+	userMemory.UpdatedAt = now
+	userMemory.Version = expectedVersion + 1
+	userMemory.Namespace = builtin.NamespaceFromContext(ctx)
+	m.userMemories[key] = userMemory
+	return nil
+}
+
+// CompareAndDeleteUserMemory 当前版本等于 expectedVersion 时删除用户记忆
+func (m *MemoryStore) CompareAndDeleteUserMemory(ctx context.Context, userID string, expectedVersion int64) error {
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := ownerKey(ctx, userID)
+	var current int64
+	if existing, ok := m.userMemories[key]; ok {
+		current = existing.Version
+	}
+	if current != expectedVersion {
+		return fmt.Errorf("%w: 期望版本 %d，当前版本 %d", builtin.ErrUserMemoryConflict, expectedVersion, current)
+	}
+	delete(m.userMemories, key)
 	return nil
 }
 
@@ -89,7 +195,7 @@ func (m *MemoryStore) GetUserMemory(ctx context.Context, userID string) (*builti
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	userMemory, exists := m.userMemories[userID]
+	userMemory, exists := m.userMemories[ownerKey(ctx, userID)]
 	if !exists {
 		return nil, nil
 	}
@@ -106,10 +212,76 @@ func (m *MemoryStore) ClearUserMemory(ctx context.Context, userID string) error
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	delete(m.userMemories, userID)
+	delete(m.userMemories, ownerKey(ctx, userID))
+	return nil
+}
+
+// SaveUserMemoryRevision 追加一个用户记忆版本
+func (m *MemoryStore) SaveUserMemoryRevision(ctx context.Context, revision *builtin.UserMemoryRevision) error {
+	if revision == nil {
+		return errors.New("记忆版本不能为空")
+	}
+	if revision.UserID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if revision.ID == "" {
+		revision.ID = utils.GetULID()
+	}
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now()
+	}
+	key := ownerKey(ctx, revision.UserID)
+	revision.Namespace = builtin.NamespaceFromContext(ctx)
+	revisions := m.userMemoryRevisions[key]
+	revision.Revision = len(revisions) + 1
+	cloned := *revision
+	m.userMemoryRevisions[key] = append(revisions, &cloned)
 	return nil
 }
 
+// ListUserMemoryRevisions 按版本号倒序返回用户记忆版本
+func (m *MemoryStore) ListUserMemoryRevisions(ctx context.Context, userID string, limit int) ([]*builtin.UserMemoryRevision, error) {
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := m.userMemoryRevisions[ownerKey(ctx, userID)]
+	out := make([]*builtin.UserMemoryRevision, 0, len(revisions))
+	for i := len(revisions) - 1; i >= 0; i-- {
+		cloned := *revisions[i]
+		out = append(out, &cloned)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// GetUserMemoryRevision 获取指定用户记忆版本
+func (m *MemoryStore) GetUserMemoryRevision(ctx context.Context, userID, revisionID string) (*builtin.UserMemoryRevision, error) {
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, revision := range m.userMemoryRevisions[ownerKey(ctx, userID)] {
+		if revision.ID == revisionID {
+			cloned := *revision
+			return &cloned, nil
+		}
+	}
+	return nil, nil
+}
+
 // SaveSessionSummary 保存会话摘要
 func (m *MemoryStore) SaveSessionSummary(ctx context.Context, summary *builtin.SessionSummary) error {
 	if summary == nil {
@@ -270,19 +442,57 @@ func (m *MemoryStore) GetMessages(ctx context.Context, sessionID string, userID
 	return messages, nil
 }
 
+// GetUserMessages 返回用户全部会话中最新的 limit 条消息，按时间正序
+func (m *MemoryStore) GetUserMessages(ctx context.Context, userID string, limit int) ([]*builtin.ConversationMessage, error) {
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	m.mu.RLock()
+	messages := make([]*builtin.ConversationMessage, 0)
+	for _, msgs := range m.messages {
+		for _, msg := range msgs {
+			if msg.UserID == userID {
+				messages = append(messages, msg)
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(messages, func(i, j int) bool {
+		if messages[i].CreatedAt.Equal(messages[j].CreatedAt) {
+			return messages[i].ID < messages[j].ID
+		}
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return messages, nil
+}
+
+// SearchMessagesByKeywords 按关键词检索消息，SessionID 为空时检索该用户的全部会话
 func (m *MemoryStore) SearchMessagesByKeywords(ctx context.Context, q *builtinsearch.SearchQuery) ([]*builtin.ConversationMessage, error) {
 	if q == nil {
 		return nil, errors.New("搜索参数不能为空")
 	}
 
-	messages, err := m.GetMessages(ctx, q.SessionID, q.UserID, 0)
+	var (
+		messages []*builtin.ConversationMessage
+		err      error
+	)
+	if q.SessionID == "" {
+		messages, err = m.GetUserMessages(ctx, q.UserID, 0)
+	} else {
+		messages, err = m.GetMessages(ctx, q.SessionID, q.UserID, 0)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	keywords := q.Keywords
 	if len(keywords) == 0 {
-		keywords = builtinsearch.InferKeywords(q.Query)
+		keywords = builtinsearch.InferKeywordsWith(m.tokenizer, q.Query)
 	}
 	if len(keywords) == 0 {
 		return []*builtin.ConversationMessage{}, nil
@@ -307,7 +517,7 @@ func (m *MemoryStore) SearchMessagesByKeywords(ctx context.Context, q *builtinse
 				Parts:   msg.Parts,
 			})
 		}
-		if _, ok := builtinsearch.MatchesKeywords(text, keywords, q.Match); !ok {
+		if _, ok := builtinsearch.MatchesKeywordsWith(m.tokenizer, text, keywords, q.Match); !ok {
 			continue
 		}
 		filtered = append(filtered, msg)
@@ -405,6 +615,377 @@ func (m *MemoryStore) DeleteMessages(ctx context.Context, sessionID string, user
 	return nil
 }
 
+// ListAttachmentRefs 返回所有消息引用的外置附件 key
+func (m *MemoryStore) ListAttachmentRefs(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var refs []string
+	for _, messages := range m.messages {
+		for _, msg := range messages {
+			refs = append(refs, builtin.AttachmentRefs(msg.Parts)...)
+		}
+	}
+	return refs, nil
+}
+
+// ListMessageSessions 返回所有存在消息的会话（跨用户）
+func (m *MemoryStore) ListMessageSessions(ctx context.Context) ([]*builtin.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sessions := make([]*builtin.Session, 0, len(m.messages))
+	for _, msgs := range m.messages {
+		if len(msgs) == 0 {
+			continue
+		}
+		var st sessionStats
+		for _, msg := range msgs {
+			st = st.add(msg.CreatedAt)
+		}
+		sessions = append(sessions, buildSession(nil, msgs[0].SessionID, msgs[0].UserID, st))
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].UserID != sessions[j].UserID {
+			return sessions[i].UserID < sessions[j].UserID
+		}
+		return sessions[i].SessionID < sessions[j].SessionID
+	})
+	return sessions, nil
+}
+
+// DeleteMessagesByIDs 删除会话中指定 ID 的消息
+func (m *MemoryStore) DeleteMessagesByIDs(ctx context.Context, sessionID, userID string, ids []string) (int, error) {
+	if sessionID == "" {
+		return 0, errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return 0, errors.New("用户ID不能为空")
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	remove := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		remove[id] = struct{}{}
+	}
+	key := m.generateKey(sessionID, userID)
+	kept := make([]*builtin.ConversationMessage, 0, len(m.messages[key]))
+	for _, msg := range m.messages[key] {
+		if _, ok := remove[msg.ID]; !ok {
+			kept = append(kept, msg)
+		}
+	}
+	deleted := len(m.messages[key]) - len(kept)
+	if len(kept) == 0 {
+		delete(m.messages, key)
+	} else {
+		m.messages[key] = kept
+	}
+	return deleted, nil
+}
+
+// ListSessions 按最近活跃时间倒序返回用户的会话
+func (m *MemoryStore) ListSessions(ctx context.Context, query *builtin.SessionQuery) ([]*builtin.Session, error) {
+	if query == nil || query.UserID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make(map[string]sessionStats)
+	for _, msgs := range m.messages {
+		for _, msg := range msgs {
+			if msg.UserID == query.UserID {
+				stats[msg.SessionID] = stats[msg.SessionID].add(msg.CreatedAt)
+			}
+		}
+	}
+	metas := make(map[string]*builtin.Session)
+	for _, meta := range m.sessions {
+		if meta.UserID == query.UserID {
+			metas[meta.SessionID] = meta
+		}
+	}
+
+	sessions := make([]*builtin.Session, 0, len(stats)+len(metas))
+	for sessionID, st := range stats {
+		sessions = append(sessions, buildSession(metas[sessionID], sessionID, query.UserID, st))
+	}
+	for sessionID, meta := range metas {
+		if _, ok := stats[sessionID]; !ok {
+			sessions = append(sessions, buildSession(meta, sessionID, query.UserID, sessionStats{}))
+		}
+	}
+	return pageSessions(sessions, query), nil
+}
+
+// GetSession 获取会话信息
+func (m *MemoryStore) GetSession(ctx context.Context, sessionID, userID string) (*builtin.Session, error) {
+	if sessionID == "" {
+		return nil, errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key := m.generateKey(sessionID, userID)
+	var st sessionStats
+	for _, msg := range m.messages[key] {
+		st = st.add(msg.CreatedAt)
+	}
+	meta := m.sessions[key]
+	if meta == nil && st.count == 0 {
+		return nil, nil
+	}
+	return buildSession(meta, sessionID, userID, st), nil
+}
+
+// SaveSession 创建或更新会话元数据
+func (m *MemoryStore) SaveSession(ctx context.Context, session *builtin.Session) error {
+	if session == nil {
+		return errors.New("会话对象不能为空")
+	}
+	if session.SessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if session.UserID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := m.generateKey(session.SessionID, session.UserID)
+	now := time.Now()
+	if existing, ok := m.sessions[key]; ok {
+		session.CreatedAt = existing.CreatedAt
+	} else if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	session.UpdatedAt = now
+	m.sessions[key] = sessionMeta(session)
+	return nil
+}
+
+// DeleteSession 删除会话元数据
+func (m *MemoryStore) DeleteSession(ctx context.Context, sessionID, userID string) error {
+	if sessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, m.generateKey(sessionID, userID))
+	return nil
+}
+
+// GetSummaryTriggerState 获取会话的摘要触发状态
+func (m *MemoryStore) GetSummaryTriggerState(ctx context.Context, sessionID, userID string) (*builtin.SessionState, error) {
+	if sessionID == "" {
+		return nil, errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	record, ok := m.triggerStates[m.generateKey(sessionID, userID)]
+	if !ok {
+		return nil, nil
+	}
+	return copySessionState(record.State), nil
+}
+
+// SaveSummaryTriggerState 创建或覆盖会话的摘要触发状态
+func (m *MemoryStore) SaveSummaryTriggerState(ctx context.Context, sessionID, userID string, state *builtin.SessionState) error {
+	if sessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	if state == nil {
+		return errors.New("触发状态不能为空")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.triggerStates[m.generateKey(sessionID, userID)] = &triggerStateRecord{
+		SessionID: sessionID,
+		UserID:    userID,
+		State:     copySessionState(state),
+	}
+	return nil
+}
+
+// DeleteSummaryTriggerState 删除会话的摘要触发状态
+func (m *MemoryStore) DeleteSummaryTriggerState(ctx context.Context, sessionID, userID string) error {
+	if sessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.triggerStates, m.generateKey(sessionID, userID))
+	return nil
+}
+
+// triggerStateRecord 带会话标识的摘要触发状态，也是文件存储中的一行
+type triggerStateRecord struct {
+	SessionID string                `json:"sessionId"`
+	UserID    string                `json:"userId"`
+	State     *builtin.SessionState `json:"state"`
+}
+
+func copySessionState(state *builtin.SessionState) *builtin.SessionState {
+	copied := *state
+	copied.TopicCentroid = append([]float64(nil), state.TopicCentroid...)
+	return &copied
+}
+
+// SaveSummaryChunk 保存一个分段摘要
+func (m *MemoryStore) SaveSummaryChunk(ctx context.Context, chunk *builtin.SummaryChunk) error {
+	if chunk == nil {
+		return errors.New("分段摘要不能为空")
+	}
+	if chunk.SessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if chunk.UserID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if chunk.ID == "" {
+		chunk.ID = utils.GetULID()
+	}
+	if chunk.CreatedAt.IsZero() {
+		chunk.CreatedAt = time.Now()
+	}
+	key := m.generateKey(chunk.SessionID, chunk.UserID)
+	stored := *chunk
+	m.summaryChunks[key] = append(m.summaryChunks[key], &stored)
+	sortSummaryChunks(m.summaryChunks[key])
+	return nil
+}
+
+// ListSummaryChunks 按时间顺序返回会话的分段摘要
+func (m *MemoryStore) ListSummaryChunks(ctx context.Context, sessionID, userID string) ([]*builtin.SummaryChunk, error) {
+	if sessionID == "" {
+		return nil, errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	chunks := m.summaryChunks[m.generateKey(sessionID, userID)]
+	result := make([]*builtin.SummaryChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		copied := *chunk
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+// DeleteSummaryChunks 删除会话的分段摘要
+func (m *MemoryStore) DeleteSummaryChunks(ctx context.Context, sessionID, userID string) error {
+	if sessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.summaryChunks, m.generateKey(sessionID, userID))
+	return nil
+}
+
+// SaveUserDigest 保存一份用户周期摘要
+func (m *MemoryStore) SaveUserDigest(ctx context.Context, digest *builtin.UserDigest) error {
+	if digest == nil {
+		return errors.New("周期摘要不能为空")
+	}
+	if digest.UserID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if digest.ID == "" {
+		digest.ID = utils.GetULID()
+	}
+	if digest.CreatedAt.IsZero() {
+		digest.CreatedAt = time.Now()
+	}
+	stored := *digest
+	stored.SessionIDs = append([]string(nil), digest.SessionIDs...)
+	m.userDigests[digest.UserID] = append(m.userDigests[digest.UserID], &stored)
+	return nil
+}
+
+// ListUserDigests 按周期结束时间倒序返回用户的周期摘要
+func (m *MemoryStore) ListUserDigests(ctx context.Context, userID string, limit int) ([]*builtin.UserDigest, error) {
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	digests := make([]*builtin.UserDigest, 0, len(m.userDigests[userID]))
+	for _, digest := range m.userDigests[userID] {
+		copied := *digest
+		copied.SessionIDs = append([]string(nil), digest.SessionIDs...)
+		digests = append(digests, &copied)
+	}
+	sort.SliceStable(digests, func(i, j int) bool {
+		if digests[i].PeriodEnd.Equal(digests[j].PeriodEnd) {
+			return digests[i].CreatedAt.After(digests[j].CreatedAt)
+		}
+		return digests[i].PeriodEnd.After(digests[j].PeriodEnd)
+	})
+	if limit > 0 && len(digests) > limit {
+		digests = digests[:limit]
+	}
+	return digests, nil
+}
+
+// sortSummaryChunks 按覆盖的消息时间排序
+func sortSummaryChunks(chunks []*builtin.SummaryChunk) {
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].StartAt.Before(chunks[j].StartAt)
+	})
+}
+
 // Close 关闭存储连接（内存存储无需关闭）
 func (m *MemoryStore) Close() error {
 	return nil
@@ -439,11 +1020,9 @@ func (m *MemoryStore) SaveUserMemoryEvent(ctx context.Context, event *builtin.Us
 		event.Type = builtin.UserMemoryEventTypeEvent
 	}
 
-	cloned := *event
-	if len(event.Keywords) > 0 {
-		cloned.Keywords = append([]string(nil), event.Keywords...)
-	}
-	m.userMemoryEvents[event.UserID] = append(m.userMemoryEvents[event.UserID], &cloned)
+	event.Namespace = builtin.NamespaceFromContext(ctx)
+	key := ownerKey(ctx, event.UserID)
+	m.userMemoryEvents[key] = append(m.userMemoryEvents[key], cloneEvent(event))
 	return nil
 }
 
@@ -455,12 +1034,17 @@ func (m *MemoryStore) ListRecentUserMemoryEvents(ctx context.Context, userID str
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	events := m.userMemoryEvents[userID]
+	events := m.userMemoryEvents[ownerKey(ctx, userID)]
 	if len(events) == 0 {
 		return nil, nil
 	}
 
-	out := cloneEventSlice(events)
+	out := make([]*builtin.UserMemoryEvent, 0, len(events))
+	for _, evt := range events {
+		if !evt.Superseded() {
+			out = append(out, cloneEvent(evt))
+		}
+	}
 	sortEventsDesc(out)
 	if limit > 0 && len(out) > limit {
 		out = out[:limit]
@@ -477,7 +1061,7 @@ func (m *MemoryStore) SearchUserMemoryEvents(ctx context.Context, query *builtin
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	events := m.userMemoryEvents[query.UserID]
+	events := m.userMemoryEvents[ownerKey(ctx, query.UserID)]
 	if len(events) == 0 {
 		return nil, nil
 	}
@@ -488,8 +1072,24 @@ func (m *MemoryStore) SearchUserMemoryEvents(ctx context.Context, query *builtin
 		match = "any"
 	}
 
+	var ids map[string]struct{}
+	if len(query.IDs) > 0 {
+		ids = make(map[string]struct{}, len(query.IDs))
+		for _, id := range query.IDs {
+			ids[id] = struct{}{}
+		}
+	}
+
 	filtered := make([]*builtin.UserMemoryEvent, 0, len(events))
 	for _, evt := range events {
+		if evt.Superseded() && !query.IncludeSuperseded {
+			continue
+		}
+		if ids != nil {
+			if _, ok := ids[evt.ID]; !ok {
+				continue
+			}
+		}
 		if query.Type != "" && evt.Type != query.Type {
 			continue
 		}
@@ -499,14 +1099,10 @@ func (m *MemoryStore) SearchUserMemoryEvents(ctx context.Context, query *builtin
 		if query.Until != nil && evt.EventDate.After(*query.Until) {
 			continue
 		}
-		if len(keywords) > 0 && !matchEventKeywords(evt, keywords, match) {
+		if len(keywords) > 0 && !matchEventKeywords(m.tokenizer, evt, keywords, match) {
 			continue
 		}
-		cloned := *evt
-		if len(evt.Keywords) > 0 {
-			cloned.Keywords = append([]string(nil), evt.Keywords...)
-		}
-		filtered = append(filtered, &cloned)
+		filtered = append(filtered, cloneEvent(evt))
 	}
 
 	sortEventsDesc(filtered)
@@ -529,7 +1125,8 @@ func (m *MemoryStore) DeleteUserMemoryEvent(ctx context.Context, userID, eventID
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	events := m.userMemoryEvents[userID]
+	key := ownerKey(ctx, userID)
+	events := m.userMemoryEvents[key]
 	if len(events) == 0 {
 		return nil
 	}
@@ -541,9 +1138,9 @@ func (m *MemoryStore) DeleteUserMemoryEvent(ctx context.Context, userID, eventID
 		kept = append(kept, evt)
 	}
 	if len(kept) == 0 {
-		delete(m.userMemoryEvents, userID)
+		delete(m.userMemoryEvents, key)
 	} else {
-		m.userMemoryEvents[userID] = kept
+		m.userMemoryEvents[key] = kept
 	}
 	return nil
 }
@@ -555,20 +1152,205 @@ func (m *MemoryStore) ClearUserMemoryEvents(ctx context.Context, userID string)
 	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.userMemoryEvents, userID)
+	delete(m.userMemoryEvents, ownerKey(ctx, userID))
 	return nil
 }
 
-func cloneEventSlice(events []*builtin.UserMemoryEvent) []*builtin.UserMemoryEvent {
-	out := make([]*builtin.UserMemoryEvent, 0, len(events))
-	for _, evt := range events {
-		cloned := *evt
-		if len(evt.Keywords) > 0 {
-			cloned.Keywords = append([]string(nil), evt.Keywords...)
+// SupersedeUserMemoryEvents 将事件标记为被 supersededBy 取代
+func (m *MemoryStore) SupersedeUserMemoryEvents(ctx context.Context, userID, supersededBy string, eventIDs []string) error {
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	if supersededBy == "" {
+		return errors.New("取代事件ID不能为空")
+	}
+	if len(eventIDs) == 0 {
+		return nil
+	}
+
+	ids := make(map[string]struct{}, len(eventIDs))
+	for _, id := range eventIDs {
+		if id != supersededBy {
+			ids[id] = struct{}{}
 		}
-		out = append(out, &cloned)
 	}
-	return out
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, evt := range m.userMemoryEvents[ownerKey(ctx, userID)] {
+		if _, ok := ids[evt.ID]; !ok || evt.Superseded() {
+			continue
+		}
+		evt.SupersededBy = supersededBy
+		evt.SupersededAt = &now
+	}
+	return nil
+}
+
+// TouchUserMemoryEvents 累加事件访问次数并更新最近访问时间
+func (m *MemoryStore) TouchUserMemoryEvents(ctx context.Context, userID string, eventIDs []string, at time.Time) error {
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	if len(eventIDs) == 0 {
+		return nil
+	}
+
+	ids := make(map[string]struct{}, len(eventIDs))
+	for _, id := range eventIDs {
+		ids[id] = struct{}{}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, evt := range m.userMemoryEvents[ownerKey(ctx, userID)] {
+		if _, ok := ids[evt.ID]; !ok {
+			continue
+		}
+		accessedAt := at
+		evt.AccessCount++
+		evt.LastAccessedAt = &accessedAt
+	}
+	return nil
+}
+
+// ListUserMemoryEventOwners 返回在 since 之后新增过事件的记忆归属（跨所有命名空间）
+func (m *MemoryStore) ListUserMemoryEventOwners(ctx context.Context, since time.Time) ([]builtin.UserMemoryOwner, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	owners := make([]builtin.UserMemoryOwner, 0, len(m.userMemoryEvents))
+	for _, events := range m.userMemoryEvents {
+		for _, evt := range events {
+			if since.IsZero() || !evt.CreatedAt.Before(since) {
+				owners = append(owners, builtin.UserMemoryOwner{Namespace: evt.Namespace, UserID: evt.UserID})
+				break
+			}
+		}
+	}
+	sortUserMemoryOwners(owners)
+	return owners, nil
+}
+
+// ownerKey 返回 ctx 命名空间下 userID 的 map 键
+func ownerKey(ctx context.Context, userID string) string {
+	return builtin.OwnerFromContext(ctx, userID).Key()
+}
+
+// sessionStats 由会话消息统计出的数量与时间范围
+type sessionStats struct {
+	count int
+	first time.Time
+	last  time.Time
+}
+
+func (st sessionStats) add(at time.Time) sessionStats {
+	st.count++
+	if st.first.IsZero() || at.Before(st.first) {
+		st.first = at
+	}
+	if at.After(st.last) {
+		st.last = at
+	}
+	return st
+}
+
+// sessionMeta 返回只包含元数据字段的会话副本
+func sessionMeta(session *builtin.Session) *builtin.Session {
+	return &builtin.Session{
+		SessionID:  session.SessionID,
+		UserID:     session.UserID,
+		Title:      session.Title,
+		Archived:   session.Archived,
+		ArchivedAt: session.ArchivedAt,
+		CreatedAt:  session.CreatedAt,
+		UpdatedAt:  session.UpdatedAt,
+	}
+}
+
+// buildSession 合并会话元数据与消息统计，meta 可以为空
+func buildSession(meta *builtin.Session, sessionID, userID string, st sessionStats) *builtin.Session {
+	session := &builtin.Session{SessionID: sessionID, UserID: userID}
+	if meta != nil {
+		session = sessionMeta(meta)
+	}
+	session.MessageCount = st.count
+	session.LastActiveAt = st.last
+	if !st.first.IsZero() && (session.CreatedAt.IsZero() || st.first.Before(session.CreatedAt)) {
+		session.CreatedAt = st.first
+	}
+	if session.UpdatedAt.IsZero() {
+		session.UpdatedAt = session.CreatedAt
+	}
+	return session
+}
+
+// pageSessions 按归档状态过滤，按最近活跃时间倒序排序后分页
+func pageSessions(sessions []*builtin.Session, query *builtin.SessionQuery) []*builtin.Session {
+	filtered := sessions[:0]
+	for _, session := range sessions {
+		switch {
+		case query.ArchivedOnly && !session.Archived:
+			continue
+		case !query.ArchivedOnly && !query.IncludeArchived && session.Archived:
+			continue
+		}
+		filtered = append(filtered, session)
+	}
+
+	activeAt := func(session *builtin.Session) time.Time {
+		if session.LastActiveAt.IsZero() {
+			return session.CreatedAt
+		}
+		return session.LastActiveAt
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		ai, aj := activeAt(filtered[i]), activeAt(filtered[j])
+		if !ai.Equal(aj) {
+			return ai.After(aj)
+		}
+		return filtered[i].SessionID > filtered[j].SessionID
+	})
+
+	if query.Offset > 0 {
+		if query.Offset >= len(filtered) {
+			return []*builtin.Session{}
+		}
+		filtered = filtered[query.Offset:]
+	}
+	if query.Limit > 0 && len(filtered) > query.Limit {
+		filtered = filtered[:query.Limit]
+	}
+	return filtered
+}
+
+func sortUserMemoryOwners(owners []builtin.UserMemoryOwner) {
+	sort.Slice(owners, func(i, j int) bool {
+		if owners[i].Namespace != owners[j].Namespace {
+			return owners[i].Namespace < owners[j].Namespace
+		}
+		return owners[i].UserID < owners[j].UserID
+	})
+}
+
+func cloneEvent(evt *builtin.UserMemoryEvent) *builtin.UserMemoryEvent {
+	cloned := *evt
+	if len(evt.Keywords) > 0 {
+		cloned.Keywords = append([]string(nil), evt.Keywords...)
+	}
+	if len(evt.Sources) > 0 {
+		cloned.Sources = append([]string(nil), evt.Sources...)
+	}
+	if evt.SupersededAt != nil {
+		at := *evt.SupersededAt
+		cloned.SupersededAt = &at
+	}
+	if evt.LastAccessedAt != nil {
+		at := *evt.LastAccessedAt
+		cloned.LastAccessedAt = &at
+	}
+	return &cloned
 }
 
 func sortEventsDesc(events []*builtin.UserMemoryEvent) {
@@ -600,29 +1382,16 @@ func normalizeKeywords(keywords []string) []string {
 	return out
 }
 
-func matchEventKeywords(evt *builtin.UserMemoryEvent, keywords []string, match string) bool {
+func matchEventKeywords(tokenizer builtinsearch.Tokenizer, evt *builtin.UserMemoryEvent, keywords []string, match string) bool {
 	if evt == nil {
 		return false
 	}
-	hay := strings.ToLower(evt.Summary)
+	hay := evt.Summary
 	if len(evt.Keywords) > 0 {
-		hay += "\n" + strings.ToLower(strings.Join(evt.Keywords, " "))
-	}
-
-	matched := 0
-	for _, kw := range keywords {
-		if strings.Contains(hay, kw) {
-			matched++
-			continue
-		}
-		if match == "all" {
-			return false
-		}
-	}
-	if match == "all" {
-		return matched == len(keywords)
+		hay += "\n" + strings.Join(evt.Keywords, " ")
 	}
-	return matched > 0
+	_, ok := builtinsearch.MatchesKeywordsWith(tokenizer, hay, keywords, match)
+	return ok
 }
 
 // CleanupOldMessages 清理指定时间之前的消息
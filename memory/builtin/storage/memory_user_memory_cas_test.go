@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+)
+
+func TestMemoryStore_CompareAndSwapUserMemory(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	if err := store.CompareAndSwapUserMemory(ctx, &builtin.UserMemory{UserID: "u1", Memory: "初始"}, 0); err != nil {
+		t.Fatalf("create err: %v", err)
+	}
+	if err := store.UpsertUserMemory(ctx, &builtin.UserMemory{UserID: "u1", Memory: "其他写入"}); err != nil {
+		t.Fatalf("upsert err: %v", err)
+	}
+
+	// 依次执行，当前版本为 2
+	cases := []struct {
+		name     string
+		memory   string
+		expected int64
+		conflict bool
+	}{
+		// 记录已存在时以 0 为期望版本再次创建应冲突
+		{name: "create over existing", memory: "并发创建", expected: 0, conflict: true},
+		{name: "stale version", memory: "过期写入", expected: 1, conflict: true},
+		{name: "current version", memory: "最新", expected: 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := store.CompareAndSwapUserMemory(ctx, &builtin.UserMemory{UserID: "u1", Memory: tc.memory}, tc.expected)
+			if tc.conflict != errors.Is(err, builtin.ErrUserMemoryConflict) || (!tc.conflict && err != nil) {
+				t.Fatalf("cas err = %v, want conflict=%v", err, tc.conflict)
+			}
+		})
+	}
+
+	mem, _ := store.GetUserMemory(ctx, "u1")
+	if mem == nil || mem.Memory != "最新" || mem.Version != 3 {
+		t.Fatalf("unexpected memory: %+v", mem)
+	}
+}
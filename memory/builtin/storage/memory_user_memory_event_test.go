@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/CoolBanHub/aggo/memory/builtin"
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
 )
 
 func TestMemoryStore_UserMemoryEvent_CRUD(t *testing.T) {
@@ -104,3 +105,35 @@ func TestMemoryStore_UserMemoryEvent_CRUD(t *testing.T) {
 		t.Fatalf("after clear, want 0, got %d", len(left))
 	}
 }
+
+func TestMemoryStore_KeywordSearchUsesConfiguredTokenizer(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name string
+		opts []MemoryStoreOption
+		want int
+	}{
+		{name: "default tokenizer", want: 0},
+		{name: "configured tokenizer", opts: []MemoryStoreOption{WithMemoryStoreTokenizer(compoundTokenizer{})}, want: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewMemoryStore(tc.opts...)
+			if err := store.SaveMessage(ctx, &builtin.ConversationMessage{ID: "m1", SessionID: "s1", UserID: "u1", Role: "user", Content: "the order needs a Refund", CreatedAt: time.Now()}); err != nil {
+				t.Fatalf("save message: %v", err)
+			}
+			if err := store.SaveUserMemoryEvent(ctx, &builtin.UserMemoryEvent{UserID: "u1", Summary: "order Refund approved", EventDate: time.Now()}); err != nil {
+				t.Fatalf("save event: %v", err)
+			}
+
+			messages, err := store.SearchMessagesByKeywords(ctx, &builtinsearch.SearchQuery{UserID: "u1", Keywords: []string{"orderRefund"}})
+			if err != nil || len(messages) != tc.want {
+				t.Fatalf("messages = %d err=%v, want %d", len(messages), err, tc.want)
+			}
+			events, err := store.SearchUserMemoryEvents(ctx, &builtin.UserMemoryEventQuery{UserID: "u1", Keywords: []string{"orderRefund"}})
+			if err != nil || len(events) != tc.want {
+				t.Fatalf("events = %d err=%v, want %d", len(events), err, tc.want)
+			}
+		})
+	}
+}
@@ -3,7 +3,9 @@ package storage
 import (
 	"fmt"
 
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
@@ -20,16 +22,30 @@ const (
 type SQLStore struct {
 	db                *gorm.DB
 	tableNameProvider *TableNameProvider
+	fullText          *FullTextConfig
+	// 关键词 LIKE 展开与匹配使用的分词器，为空时使用 builtinsearch.DefaultTokenizer()
+	tokenizer builtinsearch.Tokenizer
+}
+
+// SQLStoreOption SQL存储的可选配置
+type SQLStoreOption func(*SQLStore)
+
+// WithSQLTokenizer 指定关键词检索使用的分词器，应与 SearchConfig.Tokenizer 保持一致。
+// 关键词按该分词器拆成子词做 LIKE 展开，LIKE 回退的结果也用它复核。
+func WithSQLTokenizer(tokenizer builtinsearch.Tokenizer) SQLStoreOption {
+	return func(s *SQLStore) {
+		s.tokenizer = tokenizer
+	}
 }
 
 // NewGormStorage 创建新的SQL存储实例
-func NewGormStorage(db *gorm.DB) (*SQLStore, error) {
-	return NewGormStorageWithPrefix(db, "")
+func NewGormStorage(db *gorm.DB, opts ...SQLStoreOption) (*SQLStore, error) {
+	return NewGormStorageWithPrefix(db, "", opts...)
 }
 
 // NewGormStorageWithPrefix 创建带自定义表名前缀的 SQL 存储实例。
 // prefix 为空时使用默认值 "aggo_mem"。
-func NewGormStorageWithPrefix(db *gorm.DB, prefix string) (*SQLStore, error) {
+func NewGormStorageWithPrefix(db *gorm.DB, prefix string, opts ...SQLStoreOption) (*SQLStore, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database instance cannot be nil")
 	}
@@ -37,12 +53,25 @@ func NewGormStorageWithPrefix(db *gorm.DB, prefix string) (*SQLStore, error) {
 		db:                db,
 		tableNameProvider: NewTableNameProvider(prefix),
 	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(store)
+		}
+	}
+	if store.fullText != nil {
+		if err := store.fullText.validate(); err != nil {
+			return nil, err
+		}
+	}
 	return store, nil
 }
 
 // AutoMigrate 自动迁移表结构
 func (s *SQLStore) AutoMigrate() error {
 	// 使用实例的表名提供器来指定表名
+	if err := s.migrateUserMemoryNamespace(); err != nil {
+		return err
+	}
 	if err := s.db.Table(s.tableNameProvider.GetUserMemoryTableName()).AutoMigrate(&UserMemoryModel{}); err != nil {
 		return err
 	}
@@ -55,6 +84,69 @@ func (s *SQLStore) AutoMigrate() error {
 	if err := s.db.Table(s.tableNameProvider.GetUserMemoryEventTableName()).AutoMigrate(&UserMemoryEventModel{}); err != nil {
 		return err
 	}
+	if err := s.db.Table(s.tableNameProvider.GetUserMemoryRevisionTableName()).AutoMigrate(&UserMemoryRevisionModel{}); err != nil {
+		return err
+	}
+	if err := s.db.Table(s.tableNameProvider.GetSessionTableName()).AutoMigrate(&SessionModel{}); err != nil {
+		return err
+	}
+	if err := s.db.Table(s.tableNameProvider.GetSummaryTriggerStateTableName()).AutoMigrate(&SummaryTriggerStateModel{}); err != nil {
+		return err
+	}
+	if err := s.db.Table(s.tableNameProvider.GetSummaryChunkTableName()).AutoMigrate(&SummaryChunkModel{}); err != nil {
+		return err
+	}
+	if err := s.db.Table(s.tableNameProvider.GetUserDigestTableName()).AutoMigrate(&UserDigestModel{}); err != nil {
+		return err
+	}
+	if err := s.dropLegacyRevisionIndex(); err != nil {
+		return err
+	}
+	if err := s.migrateFullText(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// migrateUserMemoryNamespace 为旧版用户记忆表引入 namespace 列。
+// 旧表主键只有 user_id，AutoMigrate 无法修改主键，因此重建表并把旧数据迁入默认命名空间。
+func (s *SQLStore) migrateUserMemoryNamespace() error {
+	table := s.tableNameProvider.GetUserMemoryTableName()
+	migrator := s.db.Migrator()
+	if !migrator.HasTable(table) || migrator.HasColumn(table, "namespace") {
+		return nil
+	}
+
+	legacy := table + "_legacy"
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().RenameTable(table, legacy); err != nil {
+			return fmt.Errorf("重命名旧用户记忆表失败: %w", err)
+		}
+		if err := tx.Table(table).AutoMigrate(&UserMemoryModel{}); err != nil {
+			return fmt.Errorf("创建用户记忆表失败: %w", err)
+		}
+		if err := tx.Exec("INSERT INTO ? (user_id, namespace, memory, created_at, updated_at) SELECT user_id, '', memory, created_at, updated_at FROM ?",
+			clause.Table{Name: table}, clause.Table{Name: legacy}).Error; err != nil {
+			return fmt.Errorf("迁移用户记忆数据失败: %w", err)
+		}
+		if err := tx.Migrator().DropTable(legacy); err != nil {
+			return fmt.Errorf("删除旧用户记忆表失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// dropLegacyRevisionIndex 删除旧版 (user_id, revision) 唯一索引，
+// 不同命名空间下的版本号各自从 1 开始，唯一约束改由 idx_namespace_user_revision 保证
+func (s *SQLStore) dropLegacyRevisionIndex() error {
+	table := s.tableNameProvider.GetUserMemoryRevisionTableName()
+	migrator := s.db.Table(table).Migrator()
+	if !migrator.HasIndex(&UserMemoryRevisionModel{}, "idx_user_revision") {
+		return nil
+	}
+	if err := migrator.DropIndex(&UserMemoryRevisionModel{}, "idx_user_revision"); err != nil {
+		return fmt.Errorf("删除旧用户记忆版本索引失败: %w", err)
+	}
 	return nil
 }
 
@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultSQLiteFTSTokenizer SQLite FTS5 默认分词器。
+	// unicode61 会把连续的中文字符当成一个词，中文场景建议使用 "trigram"（关键词需 >= 3 个字符）。
+	DefaultSQLiteFTSTokenizer = "unicode61"
+	// DefaultPostgresTextSearchConfig PostgreSQL 默认全文检索配置
+	DefaultPostgresTextSearchConfig = "simple"
+)
+
+var (
+	sqliteTokenizerPattern    = regexp.MustCompile(`^[A-Za-z0-9_ ]+$`)
+	postgresTextConfigPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	errEmptyFullTextQuery     = errors.New("全文检索关键词为空")
+)
+
+// FullTextConfig SQL 全文检索配置。
+// SQLite 使用 FTS5 虚拟表（由触发器与消息表保持同步），PostgreSQL 使用 tsvector 生成列 + GIN 索引，
+// 其他方言退化为 LIKE 查询并按命中关键词数打分。
+type FullTextConfig struct {
+	// SQLiteTokenizer FTS5 tokenize 参数，如 "unicode61"、"trigram"、"porter unicode61"
+	SQLiteTokenizer string
+	// PostgresConfig PostgreSQL 文本检索配置名，如 "simple"、"english"，
+	// 安装 zhparser 后可使用自建的中文配置（例如 "chinese"）。
+	// 生成列创建后修改该值不会生效，需要先手动删除 content_tsv 列。
+	PostgresConfig string
+}
+
+// WithFullTextSearch 启用消息全文检索，AutoMigrate 时会创建对应的索引结构
+func WithFullTextSearch(cfg FullTextConfig) SQLStoreOption {
+	return func(s *SQLStore) {
+		if strings.TrimSpace(cfg.SQLiteTokenizer) == "" {
+			cfg.SQLiteTokenizer = DefaultSQLiteFTSTokenizer
+		}
+		if strings.TrimSpace(cfg.PostgresConfig) == "" {
+			cfg.PostgresConfig = DefaultPostgresTextSearchConfig
+		}
+		s.fullText = &cfg
+	}
+}
+
+func (c *FullTextConfig) validate() error {
+	if !sqliteTokenizerPattern.MatchString(c.SQLiteTokenizer) {
+		return fmt.Errorf("无效的 SQLite FTS5 分词器: %q", c.SQLiteTokenizer)
+	}
+	if !postgresTextConfigPattern.MatchString(c.PostgresConfig) {
+		return fmt.Errorf("无效的 PostgreSQL 全文检索配置: %q", c.PostgresConfig)
+	}
+	return nil
+}
+
+// fullTextDialect 返回当前启用全文检索的方言，未启用或方言不支持时返回空字符串
+func (s *SQLStore) fullTextDialect() string {
+	if s.fullText == nil {
+		return ""
+	}
+	switch name := s.db.Config.Dialector.Name(); name {
+	case DialectSQLite, DialectPostgreSQL:
+		return name
+	default:
+		return ""
+	}
+}
+
+func (s *SQLStore) messageFTSTableName() string {
+	return s.tableNameProvider.GetConversationMessageTableName() + "_fts"
+}
+
+// migrateFullText 创建全文检索所需的索引结构，可重复执行
+func (s *SQLStore) migrateFullText() error {
+	switch s.fullTextDialect() {
+	case DialectSQLite:
+		return s.migrateSQLiteFTS()
+	case DialectPostgreSQL:
+		return s.migratePostgresFTS()
+	default:
+		return nil
+	}
+}
+
+// migrateSQLiteFTS 创建 FTS5 虚拟表及同步触发器。
+// 不使用 external content 表：消息表主键为字符串，隐式 rowid 在 VACUUM 后可能变化，
+// 因此 FTS 表自行保存 message_id 与内容。
+func (s *SQLStore) migrateSQLiteFTS() error {
+	msgTable := s.tableNameProvider.GetConversationMessageTableName()
+	ftsTable := s.messageFTSTableName()
+
+	var existing int64
+	if err := s.db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", ftsTable).Scan(&existing).Error; err != nil {
+		return fmt.Errorf("检查 FTS5 表失败: %v", err)
+	}
+
+	quotedMsg := quoteSQLIdentifier(msgTable)
+	quotedFTS := quoteSQLIdentifier(ftsTable)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if existing == 0 {
+			ddl := fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(message_id UNINDEXED, content, tokenize='%s')",
+				quotedFTS, s.fullText.SQLiteTokenizer)
+			if err := tx.Exec(ddl).Error; err != nil {
+				return fmt.Errorf("创建 FTS5 表失败: %v", err)
+			}
+			// 回填已有消息
+			backfill := fmt.Sprintf("INSERT INTO %s(message_id, content) SELECT id, COALESCE(content, '') FROM %s", quotedFTS, quotedMsg)
+			if err := tx.Exec(backfill).Error; err != nil {
+				return fmt.Errorf("回填 FTS5 索引失败: %v", err)
+			}
+		}
+
+		triggers := []string{
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s AFTER INSERT ON %s BEGIN
+	INSERT INTO %s(message_id, content) VALUES (new.id, COALESCE(new.content, ''));
+END`, quoteSQLIdentifier(ftsTable+"_ai"), quotedMsg, quotedFTS),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s AFTER DELETE ON %s BEGIN
+	DELETE FROM %s WHERE message_id = old.id;
+END`, quoteSQLIdentifier(ftsTable+"_ad"), quotedMsg, quotedFTS),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s AFTER UPDATE OF content ON %s BEGIN
+	DELETE FROM %s WHERE message_id = old.id;
+	INSERT INTO %s(message_id, content) VALUES (new.id, COALESCE(new.content, ''));
+END`, quoteSQLIdentifier(ftsTable+"_au"), quotedMsg, quotedFTS, quotedFTS),
+		}
+		for _, trigger := range triggers {
+			if err := tx.Exec(trigger).Error; err != nil {
+				return fmt.Errorf("创建 FTS5 同步触发器失败: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// migratePostgresFTS 创建 tsvector 生成列及 GIN 索引（需要 PostgreSQL 12+）
+func (s *SQLStore) migratePostgresFTS() error {
+	msgTable := s.tableNameProvider.GetConversationMessageTableName()
+	quotedMsg := quoteSQLIdentifier(msgTable)
+
+	alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS content_tsv tsvector GENERATED ALWAYS AS (to_tsvector('%s'::regconfig, COALESCE(content, ''))) STORED",
+		quotedMsg, s.fullText.PostgresConfig)
+	if err := s.db.Exec(alter).Error; err != nil {
+		return fmt.Errorf("创建 tsvector 列失败: %v", err)
+	}
+
+	index := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (content_tsv)",
+		quoteSQLIdentifier(msgTable+"_content_tsv_idx"), quotedMsg)
+	if err := s.db.Exec(index).Error; err != nil {
+		return fmt.Errorf("创建 GIN 索引失败: %v", err)
+	}
+	return nil
+}
+
+// rankedMessageRow 全文检索结果行
+type rankedMessageRow struct {
+	ConversationMessageModel `gorm:"embedded"`
+	FTSScore                 float64 `gorm:"column:fts_score"`
+}
+
+// SearchMessagesRanked 使用数据库全文索引检索消息并返回相关度分数，按分数倒序。
+// 未启用全文检索或方言不支持时退化为 LIKE 查询，分数为命中关键词数。
+func (s *SQLStore) SearchMessagesRanked(ctx context.Context, q *builtinsearch.SearchQuery) ([]*builtin.RankedConversationMessage, error) {
+	if err := validateMessageSearchQuery(q); err != nil {
+		return nil, err
+	}
+	keywords := normalizeSearchKeywords(s.tokenizer, q)
+	if len(keywords) == 0 {
+		return []*builtin.RankedConversationMessage{}, nil
+	}
+
+	switch s.fullTextDialect() {
+	case DialectSQLite:
+		return s.searchSQLiteFTS(ctx, q, keywords)
+	case DialectPostgreSQL:
+		return s.searchPostgresFTS(ctx, q, keywords)
+	}
+
+	// LIKE 回退按命中关键词数打分，需取回全部候选排序后再截断，否则会丢掉较早但命中更多的消息
+	msgs, err := s.searchMessagesByLike(ctx, q, keywords, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*builtin.RankedConversationMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		matched, ok := builtinsearch.MatchesKeywordsWith(s.tokenizer, msg.Content, keywords, q.Match)
+		if !ok {
+			continue
+		}
+		out = append(out, &builtin.RankedConversationMessage{Message: msg, Score: float64(matched)})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Message.CreatedAt.After(out[j].Message.CreatedAt)
+	})
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 5
+	}
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *SQLStore) searchSQLiteFTS(ctx context.Context, q *builtinsearch.SearchQuery, keywords []string) ([]*builtin.RankedConversationMessage, error) {
+	expr := buildFTS5MatchExpression(keywords, q.Match)
+	if expr == "" {
+		return nil, errEmptyFullTextQuery
+	}
+
+	ftsTable := quoteSQLIdentifier(s.messageFTSTableName())
+	// bm25() 越小越相关，取反后与其他实现保持“越大越相关”
+	query := s.db.WithContext(ctx).
+		Table(quoteSQLIdentifier(s.tableNameProvider.GetConversationMessageTableName())+" AS m").
+		Select("m.*, -bm25("+ftsTable+") AS fts_score").
+		Joins("JOIN "+ftsTable+" ON "+ftsTable+".message_id = m.id").
+		Where(ftsTable+" MATCH ?", expr)
+	query = applyMessageSearchFilters(query, q, "m.")
+
+	return s.findRankedMessages(query, q.Limit)
+}
+
+func (s *SQLStore) searchPostgresFTS(ctx context.Context, q *builtinsearch.SearchQuery, keywords []string) ([]*builtin.RankedConversationMessage, error) {
+	tsQuery, args := buildPostgresTSQuery(s.fullText.PostgresConfig, keywords, q.Match)
+	if tsQuery == "" {
+		return nil, errEmptyFullTextQuery
+	}
+
+	query := s.db.WithContext(ctx).
+		Table(quoteSQLIdentifier(s.tableNameProvider.GetConversationMessageTableName())).
+		Select("*, ts_rank_cd(content_tsv, "+tsQuery+") AS fts_score", args...).
+		Where("content_tsv @@ ("+tsQuery+")", args...)
+	query = applyMessageSearchFilters(query, q, "")
+
+	return s.findRankedMessages(query, q.Limit)
+}
+
+func (s *SQLStore) findRankedMessages(query *gorm.DB, limit int) ([]*builtin.RankedConversationMessage, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+	var rows []rankedMessageRow
+	if err := query.Order("fts_score DESC").Order("created_at DESC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("全文检索消息失败: %v", err)
+	}
+
+	out := make([]*builtin.RankedConversationMessage, 0, len(rows))
+	for i := range rows {
+		out = append(out, &builtin.RankedConversationMessage{
+			Message: rows[i].ToConversationMessage(),
+			Score:   rows[i].FTSScore,
+		})
+	}
+	return out, nil
+}
+
+// buildFTS5MatchExpression 将关键词转换为 FTS5 MATCH 表达式，每个关键词作为短语匹配，避免语法注入
+func buildFTS5MatchExpression(keywords []string, match string) string {
+	phrases := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" {
+			continue
+		}
+		phrases = append(phrases, `"`+strings.ReplaceAll(keyword, `"`, `""`)+`"`)
+	}
+	if builtinsearch.NormalizeMatch(match) == builtinsearch.MatchAll {
+		return strings.Join(phrases, " AND ")
+	}
+	return strings.Join(phrases, " OR ")
+}
+
+// buildPostgresTSQuery 构建参数化的 tsquery 表达式，多个关键词通过 || 或 && 组合
+func buildPostgresTSQuery(config string, keywords []string, match string) (string, []any) {
+	parts := make([]string, 0, len(keywords))
+	args := make([]any, 0, len(keywords))
+	for _, keyword := range keywords {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("plainto_tsquery('%s'::regconfig, ?)", config))
+		args = append(args, keyword)
+	}
+	op := " || "
+	if builtinsearch.NormalizeMatch(match) == builtinsearch.MatchAll {
+		op = " && "
+	}
+	return strings.Join(parts, op), args
+}
+
+func quoteSQLIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
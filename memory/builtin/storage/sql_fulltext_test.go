@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
+)
+
+func TestBuildFTS5MatchExpression(t *testing.T) {
+	got := buildFTS5MatchExpression([]string{"登录", ` say "hi" `, ""}, builtinsearch.MatchAny)
+	if want := `"登录" OR "say ""hi"""`; got != want {
+		t.Fatalf("any expression = %s, want %s", got, want)
+	}
+	got = buildFTS5MatchExpression([]string{"deploy", "error"}, builtinsearch.MatchAll)
+	if want := `"deploy" AND "error"`; got != want {
+		t.Fatalf("all expression = %s, want %s", got, want)
+	}
+}
+
+func TestBuildPostgresTSQuery(t *testing.T) {
+	expr, args := buildPostgresTSQuery("chinese", []string{"登录", "报错"}, builtinsearch.MatchAll)
+	want := "plainto_tsquery('chinese'::regconfig, ?) && plainto_tsquery('chinese'::regconfig, ?)"
+	if expr != want {
+		t.Fatalf("expr = %s, want %s", expr, want)
+	}
+	if len(args) != 2 || args[0] != "登录" || args[1] != "报错" {
+		t.Fatalf("unexpected args: %v", args)
+	}
+}
+
+func TestFullTextConfigValidate(t *testing.T) {
+	store := &SQLStore{}
+	WithFullTextSearch(FullTextConfig{})(store)
+	if store.fullText.SQLiteTokenizer != DefaultSQLiteFTSTokenizer || store.fullText.PostgresConfig != DefaultPostgresTextSearchConfig {
+		t.Fatalf("defaults not applied: %+v", store.fullText)
+	}
+	if err := store.fullText.validate(); err != nil {
+		t.Fatalf("validate defaults: %v", err)
+	}
+
+	invalid := []FullTextConfig{
+		{SQLiteTokenizer: "unicode61'); DROP TABLE x; --", PostgresConfig: "simple"},
+		{SQLiteTokenizer: "trigram", PostgresConfig: "simple'::regconfig"},
+	}
+	for _, cfg := range invalid {
+		if err := cfg.validate(); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+}
+
+// saveRankingMessages 写入一条较早但命中全部关键词的消息，以及若干较新但只命中一个关键词的消息
+func saveRankingMessages(t *testing.T, store *SQLStore) {
+	t.Helper()
+	ctx := context.Background()
+	base := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	messages := []*builtin.ConversationMessage{
+		{ID: "old", Content: "deploy failed with a timeout error", CreatedAt: base},
+		{ID: "new1", Content: "deploy went fine", CreatedAt: base.Add(time.Hour)},
+		{ID: "new2", Content: "another deploy today", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: "new3", Content: "deploy scheduled for friday", CreatedAt: base.Add(3 * time.Hour)},
+		{ID: "other", Content: "lunch plans", CreatedAt: base.Add(4 * time.Hour)},
+	}
+	for _, msg := range messages {
+		msg.SessionID, msg.UserID, msg.Role = "s1", "u1", "user"
+		if err := store.SaveMessage(ctx, msg); err != nil {
+			t.Fatalf("save %s: %v", msg.ID, err)
+		}
+	}
+}
+
+func rankedIDs(items []*builtin.RankedConversationMessage) []string {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.Message.ID)
+	}
+	return ids
+}
+
+func TestSQLStoreSearchMessagesRanked(t *testing.T) {
+	cases := []struct {
+		name  string
+		opts  []SQLStoreOption
+		match string
+		limit int
+		want  []string
+	}{
+		// LIKE 回退：先按命中数排序再截断，较早但命中更多的消息不会被 limit 挤掉
+		{name: "like fallback ranks before limit", match: builtinsearch.MatchAny, limit: 2, want: []string{"old", "new3"}},
+		{name: "like fallback match all", match: builtinsearch.MatchAll, limit: 5, want: []string{"old"}},
+		{name: "fts5 match all", opts: []SQLStoreOption{WithFullTextSearch(FullTextConfig{})}, match: builtinsearch.MatchAll, limit: 5, want: []string{"old"}},
+		{name: "fts5 ranks full match first", opts: []SQLStoreOption{WithFullTextSearch(FullTextConfig{})}, match: builtinsearch.MatchAny, limit: 1, want: []string{"old"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := openSQLiteStore(t, tc.opts...)
+			saveRankingMessages(t, store)
+			got, err := store.SearchMessagesRanked(context.Background(), &builtinsearch.SearchQuery{
+				UserID:   "u1",
+				Keywords: []string{"deploy", "error"},
+				Match:    tc.match,
+				Limit:    tc.limit,
+			})
+			if err != nil {
+				t.Fatalf("SearchMessagesRanked: %v", err)
+			}
+			if ids := rankedIDs(got); strings.Join(ids, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("ids = %v, want %v", ids, tc.want)
+			}
+		})
+	}
+}
+
+func TestSQLStoreFTS5TracksMessageChanges(t *testing.T) {
+	ctx := context.Background()
+	store := openSQLiteStore(t, WithFullTextSearch(FullTextConfig{}))
+	saveRankingMessages(t, store)
+
+	search := func() []string {
+		t.Helper()
+		got, err := store.SearchMessagesRanked(ctx, &builtinsearch.SearchQuery{UserID: "u1", Keywords: []string{"deploy"}, Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchMessagesRanked: %v", err)
+		}
+		return rankedIDs(got)
+	}
+	if ids := search(); len(ids) != 4 {
+		t.Fatalf("expected 4 indexed matches, got %v", ids)
+	}
+	// 删除消息后触发器同步清理 FTS5 索引
+	if _, err := store.DeleteMessagesByIDs(ctx, "s1", "u1", []string{"old", "new1"}); err != nil {
+		t.Fatalf("delete messages: %v", err)
+	}
+	if ids := search(); strings.Join(ids, ",") != "new3,new2" && strings.Join(ids, ",") != "new2,new3" {
+		t.Fatalf("deleted messages should leave the index, got %v", ids)
+	}
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/utils"
+)
+
+// SaveSummaryChunk 保存一个分段摘要
+func (s *SQLStore) SaveSummaryChunk(ctx context.Context, chunk *builtin.SummaryChunk) error {
+	if chunk == nil {
+		return errors.New("分段摘要不能为空")
+	}
+	if chunk.SessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if chunk.UserID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	if chunk.ID == "" {
+		chunk.ID = utils.GetULID()
+	}
+	if chunk.CreatedAt.IsZero() {
+		chunk.CreatedAt = time.Now()
+	}
+
+	model := &SummaryChunkModel{}
+	model.FromSummaryChunk(chunk)
+	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetSummaryChunkTableName()).Create(model).Error; err != nil {
+		return fmt.Errorf("保存分段摘要失败: %v", err)
+	}
+	return nil
+}
+
+// ListSummaryChunks 按时间顺序返回会话的分段摘要
+func (s *SQLStore) ListSummaryChunks(ctx context.Context, sessionID, userID string) ([]*builtin.SummaryChunk, error) {
+	if sessionID == "" {
+		return nil, errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	var rows []SummaryChunkModel
+	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetSummaryChunkTableName()).
+		Where("session_id = ? AND user_id = ?", sessionID, userID).
+		Order("start_at ASC, id ASC").
+		Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询分段摘要失败: %v", err)
+	}
+
+	chunks := make([]*builtin.SummaryChunk, 0, len(rows))
+	for i := range rows {
+		chunks = append(chunks, rows[i].ToSummaryChunk())
+	}
+	return chunks, nil
+}
+
+// DeleteSummaryChunks 删除会话的分段摘要
+func (s *SQLStore) DeleteSummaryChunks(ctx context.Context, sessionID, userID string) error {
+	if sessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetSummaryChunkTableName()).
+		Where("session_id = ? AND user_id = ?", sessionID, userID).
+		Delete(&SummaryChunkModel{}).Error; err != nil {
+		return fmt.Errorf("删除分段摘要失败: %v", err)
+	}
+	return nil
+}
+
+// SaveUserDigest 保存一份用户周期摘要
+func (s *SQLStore) SaveUserDigest(ctx context.Context, digest *builtin.UserDigest) error {
+	if digest == nil {
+		return errors.New("周期摘要不能为空")
+	}
+	if digest.UserID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	if digest.ID == "" {
+		digest.ID = utils.GetULID()
+	}
+	if digest.CreatedAt.IsZero() {
+		digest.CreatedAt = time.Now()
+	}
+
+	model := &UserDigestModel{}
+	model.FromUserDigest(digest)
+	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetUserDigestTableName()).Create(model).Error; err != nil {
+		return fmt.Errorf("保存用户周期摘要失败: %v", err)
+	}
+	return nil
+}
+
+// ListUserDigests 按周期结束时间倒序返回用户的周期摘要
+func (s *SQLStore) ListUserDigests(ctx context.Context, userID string, limit int) ([]*builtin.UserDigest, error) {
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	var rows []UserDigestModel
+	q := s.db.WithContext(ctx).Table(s.tableNameProvider.GetUserDigestTableName()).
+		Where("user_id = ?", userID).
+		Order("period_end DESC, created_at DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询用户周期摘要失败: %v", err)
+	}
+
+	digests := make([]*builtin.UserDigest, 0, len(rows))
+	for i := range rows {
+		digests = append(digests, rows[i].ToUserDigest())
+	}
+	return digests, nil
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"strings"
+
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
+)
+
+// likeKeywordClause 生成单个关键词的 LIKE 条件：关键词整体出现在任一列中，
+// 或者关键词经 tokenizer 分词后的所有子词都出现（如 "报销流程" 可命中 "报销的审批流程"）。
+func likeKeywordClause(tokenizer builtinsearch.Tokenizer, columns []string, keyword string) (string, []any) {
+	clause, args := likeColumnsClause(columns, keyword)
+	terms := builtinsearch.KeywordTerms(tokenizer, keyword)
+	if len(terms) == 0 {
+		return clause, args
+	}
+
+	termClauses := make([]string, 0, len(terms))
+	for _, term := range terms {
+		termClause, termArgs := likeColumnsClause(columns, term)
+		termClauses = append(termClauses, termClause)
+		args = append(args, termArgs...)
+	}
+	return "(" + clause + " OR (" + strings.Join(termClauses, " AND ") + "))", args
+}
+
+func likeColumnsClause(columns []string, value string) (string, []any) {
+	parts := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns))
+	for _, column := range columns {
+		parts = append(parts, column+" LIKE ?")
+		args = append(args, "%"+value+"%")
+	}
+	return "(" + strings.Join(parts, " OR ") + ")", args
+}
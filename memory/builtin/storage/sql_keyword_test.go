@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// compoundTokenizer 把复合词按词表拆开，默认分词器会把 "orderRefund" 当作一个词
+type compoundTokenizer struct{}
+
+var compoundVocabulary = []string{"order", "refund"}
+
+func (compoundTokenizer) Tokenize(text string) []builtinsearch.Token {
+	var tokens []builtinsearch.Token
+	for _, field := range strings.Fields(text) {
+		lower := strings.ToLower(field)
+		for pos := 0; pos < len(field); {
+			size := len(field) - pos
+			for _, word := range compoundVocabulary {
+				if strings.HasPrefix(lower[pos:], word) {
+					size = len(word)
+					break
+				}
+			}
+			tokens = append(tokens, builtinsearch.Token{Text: field[pos : pos+size], Term: lower[pos : pos+size]})
+			pos += size
+		}
+	}
+	return tokens
+}
+
+func openSQLiteStore(t *testing.T, opts ...SQLStoreOption) *SQLStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "memory.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	store, err := NewGormStorage(db, opts...)
+	if err != nil {
+		t.Fatalf("NewGormStorage: %v", err)
+	}
+	if err := store.AutoMigrate(); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			t.Skip("sqlite 驱动未启用 FTS5，需使用 -tags sqlite_fts5 运行")
+		}
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	return store
+}
+
+func TestLikeKeywordClauseUsesTokenizer(t *testing.T) {
+	clause, args := likeKeywordClause(nil, []string{"content"}, "orderRefund")
+	if clause != "(content LIKE ?)" || len(args) != 1 {
+		t.Fatalf("default tokenizer should not split, got %s %v", clause, args)
+	}
+	clause, args = likeKeywordClause(compoundTokenizer{}, []string{"content"}, "orderRefund")
+	if want := "((content LIKE ?) OR ((content LIKE ?) AND (content LIKE ?)))"; clause != want {
+		t.Fatalf("clause = %s, want %s", clause, want)
+	}
+	if len(args) != 3 || args[1] != "%order%" || args[2] != "%Refund%" {
+		t.Fatalf("unexpected args: %v", args)
+	}
+}
+
+func TestSQLStoreKeywordSearchUsesConfiguredTokenizer(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name string
+		opts []SQLStoreOption
+		want int
+	}{
+		{name: "default tokenizer", want: 0},
+		{name: "configured tokenizer", opts: []SQLStoreOption{WithSQLTokenizer(compoundTokenizer{})}, want: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := openSQLiteStore(t, tc.opts...)
+			if err := store.SaveMessage(ctx, &builtin.ConversationMessage{ID: "m1", SessionID: "s1", UserID: "u1", Role: "user", Content: "the order needs a Refund", CreatedAt: time.Now()}); err != nil {
+				t.Fatalf("save message: %v", err)
+			}
+			if err := store.SaveUserMemoryEvent(ctx, &builtin.UserMemoryEvent{UserID: "u1", Summary: "order Refund approved", EventDate: time.Now()}); err != nil {
+				t.Fatalf("save event: %v", err)
+			}
+
+			messages, err := store.SearchMessagesByKeywords(ctx, &builtinsearch.SearchQuery{UserID: "u1", Keywords: []string{"orderRefund"}})
+			if err != nil || len(messages) != tc.want {
+				t.Fatalf("messages = %d err=%v, want %d", len(messages), err, tc.want)
+			}
+			events, err := store.SearchUserMemoryEvents(ctx, &builtin.UserMemoryEventQuery{UserID: "u1", Keywords: []string{"orderRefund"}})
+			if err != nil || len(events) != tc.want {
+				t.Fatalf("events = %d err=%v, want %d", len(events), err, tc.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package storage
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/CoolBanHub/aggo/memory/builtin"
@@ -42,10 +44,82 @@ func (mp MessageParts) GormDataType() string {
 	return "text"
 }
 
-// UserMemoryModel GORM模型 - 用户记忆表（每个用户一条记录）
+// ToolCalls 自定义 GORM 类型，存储助手消息中的工具调用（JSON 编码）
+type ToolCalls []builtin.ToolCall
+
+// Value 实现 driver.Valuer 接口
+func (tc ToolCalls) Value() (driver.Value, error) {
+	if len(tc) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(tc)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (tc *ToolCalls) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		if len(v) == 0 {
+			*tc = nil
+			return nil
+		}
+		return json.Unmarshal(v, tc)
+	case string:
+		if v == "" {
+			*tc = nil
+			return nil
+		}
+		return json.Unmarshal([]byte(v), tc)
+	default:
+		*tc = nil
+		return nil
+	}
+}
+
+func (tc ToolCalls) GormDataType() string {
+	return "text"
+}
+
+// ToolResultColumn 自定义 GORM 类型，存储工具结果消息的结果（JSON 编码）
+type ToolResultColumn struct {
+	*builtin.ToolResult
+}
+
+// Value 实现 driver.Valuer 接口
+func (tr ToolResultColumn) Value() (driver.Value, error) {
+	if tr.ToolResult == nil {
+		return nil, nil
+	}
+	return json.Marshal(tr.ToolResult)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (tr *ToolResultColumn) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	}
+	if len(data) == 0 {
+		tr.ToolResult = nil
+		return nil
+	}
+	tr.ToolResult = &builtin.ToolResult{}
+	return json.Unmarshal(data, tr.ToolResult)
+}
+
+func (tr ToolResultColumn) GormDataType() string {
+	return "text"
+}
+
+// UserMemoryModel GORM模型 - 用户记忆表（每个用户在每个命名空间下一条记录）
 type UserMemoryModel struct {
 	UserID    string    `gorm:"primaryKey;size:255" json:"userId"`
+	Namespace string    `gorm:"primaryKey;size:128;default:''" json:"namespace,omitempty"`
 	Memory    string    `gorm:"type:text;not null" json:"memory"`
+	Version   int64     `gorm:"not null;default:0" json:"version"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
 }
@@ -90,12 +164,37 @@ func (s StringSlice) GormDataType() string {
 // UserMemoryEventModel GORM 模型 - 用户记忆事件表（事件检索模式）。
 type UserMemoryEventModel struct {
 	ID        string      `gorm:"primaryKey;size:64" json:"id"`
-	UserID    string      `gorm:"size:255;not null;index:idx_user_event_date" json:"userId"`
+	UserID    string      `gorm:"size:255;not null;index:idx_user_event_date;index:idx_event_namespace_user,priority:2" json:"userId"`
+	Namespace string      `gorm:"size:128;not null;default:'';index:idx_event_namespace_user,priority:1" json:"namespace,omitempty"`
 	Type      string      `gorm:"size:32;not null;index" json:"type"`
 	EventDate time.Time   `gorm:"not null;index:idx_user_event_date" json:"eventDate"`
 	Keywords  StringSlice `gorm:"type:text" json:"keywords,omitempty"`
 	Summary   string      `gorm:"type:text;not null" json:"summary"`
 	CreatedAt time.Time   `gorm:"autoCreateTime" json:"createdAt"`
+	// 整理任务写入：取代该事件的事件 ID、取代时间与合并来源
+	SupersededBy string      `gorm:"size:64;default:'';index" json:"supersededBy,omitempty"`
+	SupersededAt *time.Time  `json:"supersededAt,omitempty"`
+	Sources      StringSlice `gorm:"type:text" json:"sources,omitempty"`
+	// 重要程度与访问统计，用于最近事件注入时的排序
+	Importance     int        `gorm:"not null;default:0" json:"importance,omitempty"`
+	AccessCount    int        `gorm:"not null;default:0" json:"accessCount,omitempty"`
+	LastAccessedAt *time.Time `json:"lastAccessedAt,omitempty"`
+}
+
+// UserMemoryRevisionModel GORM 模型 - 用户记忆历史版本表
+type UserMemoryRevisionModel struct {
+	ID        string    `gorm:"primaryKey;size:64" json:"id"`
+	Namespace string    `gorm:"size:128;not null;default:'';uniqueIndex:idx_namespace_user_revision" json:"namespace,omitempty"`
+	UserID    string    `gorm:"size:255;not null;uniqueIndex:idx_namespace_user_revision" json:"userId"`
+	Revision  int       `gorm:"not null;uniqueIndex:idx_namespace_user_revision" json:"revision"`
+	Memory    string    `gorm:"type:text;not null" json:"memory"`
+	Diff      string    `gorm:"type:text" json:"diff,omitempty"`
+	Status    string    `gorm:"size:32;not null" json:"status"`
+	Source    string    `gorm:"size:32;not null" json:"source"`
+	SessionID string    `gorm:"size:255" json:"sessionId,omitempty"`
+	Model     string    `gorm:"size:255" json:"model,omitempty"`
+	Reason    string    `gorm:"type:text" json:"reason,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
 }
 
 // SessionSummaryModel GORM模型 - 会话摘要表
@@ -105,10 +204,62 @@ type SessionSummaryModel struct {
 	Summary                 string    `gorm:"type:text;not null" json:"summary"`
 	LastSummarizedMessageID string    `gorm:"size:255" json:"lastSummarizedMessageId,omitempty"`
 	LastSummarizedMessageAt time.Time `json:"lastSummarizedMessageAt,omitempty"`
+	RolledUpChunkCount      int       `gorm:"not null;default:0" json:"rolledUpChunkCount,omitempty"`
 	CreatedAt               time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt               time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
 }
 
+// SummaryChunkModel GORM模型 - 分段摘要表
+type SummaryChunkModel struct {
+	ID             string    `gorm:"primaryKey;size:255" json:"id"`
+	SessionID      string    `gorm:"size:255;not null;index:idx_chunk_session_user" json:"sessionId"`
+	UserID         string    `gorm:"size:255;not null;index:idx_chunk_session_user" json:"userId"`
+	Summary        string    `gorm:"type:text;not null" json:"summary"`
+	StartMessageID string    `gorm:"size:255" json:"startMessageId"`
+	EndMessageID   string    `gorm:"size:255" json:"endMessageId"`
+	StartAt        time.Time `json:"startAt"`
+	EndAt          time.Time `json:"endAt"`
+	MessageCount   int       `gorm:"not null;default:0" json:"messageCount"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// UserDigestModel GORM模型 - 用户周期摘要表
+type UserDigestModel struct {
+	ID          string      `gorm:"primaryKey;size:255" json:"id"`
+	UserID      string      `gorm:"size:255;not null;index:idx_digest_user_period,priority:1" json:"userId"`
+	PeriodStart time.Time   `json:"periodStart"`
+	PeriodEnd   time.Time   `gorm:"index:idx_digest_user_period,priority:2" json:"periodEnd"`
+	Digest      string      `gorm:"type:text;not null" json:"digest"`
+	SessionIDs  StringSlice `gorm:"type:text" json:"sessionIds,omitempty"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
+
+// SessionModel GORM模型 - 会话元数据表，消息数与最近活跃时间由消息表统计
+type SessionModel struct {
+	SessionID  string     `gorm:"primaryKey;size:255" json:"sessionId"`
+	UserID     string     `gorm:"primaryKey;size:255;index" json:"userId"`
+	Title      string     `gorm:"size:255;not null;default:''" json:"title,omitempty"`
+	Archived   bool       `gorm:"not null;default:false" json:"archived,omitempty"`
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// SummaryTriggerStateModel GORM模型 - 摘要触发状态表
+type SummaryTriggerStateModel struct {
+	SessionID                string    `gorm:"primaryKey;size:255" json:"sessionId"`
+	UserID                   string    `gorm:"primaryKey;size:255" json:"userId"`
+	LastSummaryTime          time.Time `json:"lastSummaryTime"`
+	MessagesSinceLastSummary int       `gorm:"not null;default:0" json:"messagesSinceLastSummary"`
+	TotalMessages            int       `gorm:"not null;default:0" json:"totalMessages"`
+	UnsummarizedTokens       int       `gorm:"not null;default:0" json:"unsummarizedTokens"`
+	// 话题向量，JSON 数组
+	TopicCentroid string    `gorm:"type:text" json:"topicCentroid,omitempty"`
+	TopicTurns    int       `gorm:"not null;default:0" json:"topicTurns"`
+	TopicShifted  bool      `gorm:"not null;default:false" json:"topicShifted,omitempty"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
 // ConversationMessageModel GORM模型 - 对话消息表
 type ConversationMessageModel struct {
 	ID        string `gorm:"primaryKey;size:255" json:"id"`
@@ -118,10 +269,12 @@ type ConversationMessageModel struct {
 	// 保留Content字段用于向后兼容
 	Content string `gorm:"type:text" json:"content,omitempty"`
 	// 多部分内容，使用自定义类型直接存储
-	Parts        MessageParts `gorm:"type:text" json:"parts,omitempty"`
-	Embedding    []byte       `gorm:"type:blob" json:"embedding,omitempty"`
-	EmbeddingDim int          `json:"embeddingDim,omitempty"`
-	CreatedAt    time.Time    `gorm:"autoCreateTime;index:idx_user_session" json:"createdAt"`
+	Parts        MessageParts     `gorm:"type:text" json:"parts,omitempty"`
+	ToolCalls    ToolCalls        `gorm:"type:text" json:"toolCalls,omitempty"`
+	ToolResult   ToolResultColumn `gorm:"type:text" json:"toolResult,omitempty"`
+	Embedding    []byte           `gorm:"type:blob" json:"embedding,omitempty"`
+	EmbeddingDim int              `json:"embeddingDim,omitempty"`
+	CreatedAt    time.Time        `gorm:"autoCreateTime;index:idx_user_session" json:"createdAt"`
 }
 
 // 模型转换函数
@@ -130,7 +283,9 @@ type ConversationMessageModel struct {
 func (m *UserMemoryModel) ToUserMemory() *builtin.UserMemory {
 	return &builtin.UserMemory{
 		UserID:    m.UserID,
+		Namespace: m.Namespace,
 		Memory:    m.Memory,
+		Version:   m.Version,
 		CreatedAt: m.CreatedAt,
 		UpdatedAt: m.UpdatedAt,
 	}
@@ -139,7 +294,9 @@ func (m *UserMemoryModel) ToUserMemory() *builtin.UserMemory {
 // FromUserMemory 将业务模型转换为数据库模型
 func (m *UserMemoryModel) FromUserMemory(userMemory *builtin.UserMemory) {
 	m.UserID = userMemory.UserID
+	m.Namespace = userMemory.Namespace
 	m.Memory = userMemory.Memory
+	m.Version = userMemory.Version
 	m.CreatedAt = userMemory.CreatedAt
 	m.UpdatedAt = userMemory.UpdatedAt
 }
@@ -152,6 +309,7 @@ func (m *SessionSummaryModel) ToSessionSummary() *builtin.SessionSummary {
 		Summary:                 m.Summary,
 		LastSummarizedMessageID: m.LastSummarizedMessageID,
 		LastSummarizedMessageAt: m.LastSummarizedMessageAt,
+		RolledUpChunkCount:      m.RolledUpChunkCount,
 		CreatedAt:               m.CreatedAt,
 		UpdatedAt:               m.UpdatedAt,
 	}
@@ -166,10 +324,65 @@ func (m *SessionSummaryModel) FromSessionSummary(sessionSummary *builtin.Session
 	m.Summary = sessionSummary.Summary
 	m.LastSummarizedMessageID = sessionSummary.LastSummarizedMessageID
 	m.LastSummarizedMessageAt = sessionSummary.LastSummarizedMessageAt
+	m.RolledUpChunkCount = sessionSummary.RolledUpChunkCount
 	m.CreatedAt = sessionSummary.CreatedAt
 	m.UpdatedAt = sessionSummary.UpdatedAt
 }
 
+// ToSummaryChunk 将数据库模型转换为业务模型
+func (m *SummaryChunkModel) ToSummaryChunk() *builtin.SummaryChunk {
+	return &builtin.SummaryChunk{
+		ID:             m.ID,
+		SessionID:      m.SessionID,
+		UserID:         m.UserID,
+		Summary:        m.Summary,
+		StartMessageID: m.StartMessageID,
+		EndMessageID:   m.EndMessageID,
+		StartAt:        m.StartAt,
+		EndAt:          m.EndAt,
+		MessageCount:   m.MessageCount,
+		CreatedAt:      m.CreatedAt,
+	}
+}
+
+// FromSummaryChunk 将业务模型转换为数据库模型
+func (m *SummaryChunkModel) FromSummaryChunk(chunk *builtin.SummaryChunk) {
+	m.ID = chunk.ID
+	m.SessionID = chunk.SessionID
+	m.UserID = chunk.UserID
+	m.Summary = chunk.Summary
+	m.StartMessageID = chunk.StartMessageID
+	m.EndMessageID = chunk.EndMessageID
+	m.StartAt = chunk.StartAt
+	m.EndAt = chunk.EndAt
+	m.MessageCount = chunk.MessageCount
+	m.CreatedAt = chunk.CreatedAt
+}
+
+// ToUserDigest 将数据库模型转换为业务模型
+func (m *UserDigestModel) ToUserDigest() *builtin.UserDigest {
+	return &builtin.UserDigest{
+		ID:          m.ID,
+		UserID:      m.UserID,
+		PeriodStart: m.PeriodStart,
+		PeriodEnd:   m.PeriodEnd,
+		Digest:      m.Digest,
+		SessionIDs:  []string(m.SessionIDs),
+		CreatedAt:   m.CreatedAt,
+	}
+}
+
+// FromUserDigest 将业务模型转换为数据库模型
+func (m *UserDigestModel) FromUserDigest(digest *builtin.UserDigest) {
+	m.ID = digest.ID
+	m.UserID = digest.UserID
+	m.PeriodStart = digest.PeriodStart
+	m.PeriodEnd = digest.PeriodEnd
+	m.Digest = digest.Digest
+	m.SessionIDs = StringSlice(digest.SessionIDs)
+	m.CreatedAt = digest.CreatedAt
+}
+
 // ToConversationMessage 将数据库模型转换为业务模型
 func (m *ConversationMessageModel) ToConversationMessage() *builtin.ConversationMessage {
 	// Parts 现在是自定义类型，可以直接转换为 []schema.MessageInputPart
@@ -177,13 +390,15 @@ func (m *ConversationMessageModel) ToConversationMessage() *builtin.Conversation
 	content := m.Content
 
 	return &builtin.ConversationMessage{
-		ID:        m.ID,
-		SessionID: m.SessionID,
-		UserID:    m.UserID,
-		Role:      m.Role,
-		Content:   content,
-		Parts:     parts,
-		CreatedAt: m.CreatedAt,
+		ID:         m.ID,
+		SessionID:  m.SessionID,
+		UserID:     m.UserID,
+		Role:       m.Role,
+		Content:    content,
+		Parts:      parts,
+		ToolCalls:  []builtin.ToolCall(m.ToolCalls),
+		ToolResult: m.ToolResult.ToolResult,
+		CreatedAt:  m.CreatedAt,
 	}
 }
 
@@ -195,6 +410,8 @@ func (m *ConversationMessageModel) FromConversationMessage(message *builtin.Conv
 	m.Role = message.Role
 	m.Content = message.Content
 	m.Parts = message.Parts
+	m.ToolCalls = message.ToolCalls
+	m.ToolResult = ToolResultColumn{ToolResult: message.ToolResult}
 	m.CreatedAt = message.CreatedAt
 }
 
@@ -204,11 +421,20 @@ func (m *UserMemoryEventModel) ToUserMemoryEvent() *builtin.UserMemoryEvent {
 	return &builtin.UserMemoryEvent{
 		ID:        m.ID,
 		UserID:    m.UserID,
+		Namespace: m.Namespace,
 		Type:      m.Type,
 		EventDate: m.EventDate,
 		Keywords:  keywords,
 		Summary:   m.Summary,
 		CreatedAt: m.CreatedAt,
+
+		SupersededBy: m.SupersededBy,
+		SupersededAt: m.SupersededAt,
+		Sources:      []string(m.Sources),
+
+		Importance:     m.Importance,
+		AccessCount:    m.AccessCount,
+		LastAccessedAt: m.LastAccessedAt,
 	}
 }
 
@@ -216,9 +442,169 @@ func (m *UserMemoryEventModel) ToUserMemoryEvent() *builtin.UserMemoryEvent {
 func (m *UserMemoryEventModel) FromUserMemoryEvent(event *builtin.UserMemoryEvent) {
 	m.ID = event.ID
 	m.UserID = event.UserID
+	m.Namespace = event.Namespace
 	m.Type = event.Type
 	m.EventDate = event.EventDate
 	m.Keywords = StringSlice(event.Keywords)
 	m.Summary = event.Summary
 	m.CreatedAt = event.CreatedAt
+	m.SupersededBy = event.SupersededBy
+	m.SupersededAt = event.SupersededAt
+	m.Sources = StringSlice(event.Sources)
+	m.Importance = event.Importance
+	m.AccessCount = event.AccessCount
+	m.LastAccessedAt = event.LastAccessedAt
+}
+
+// ToUserMemoryRevision 数据库模型 -> 业务模型
+func (m *UserMemoryRevisionModel) ToUserMemoryRevision() *builtin.UserMemoryRevision {
+	return &builtin.UserMemoryRevision{
+		ID:        m.ID,
+		UserID:    m.UserID,
+		Namespace: m.Namespace,
+		Revision:  m.Revision,
+		Memory:    m.Memory,
+		Diff:      m.Diff,
+		Status:    m.Status,
+		Source:    m.Source,
+		SessionID: m.SessionID,
+		Model:     m.Model,
+		Reason:    m.Reason,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// FromUserMemoryRevision 业务模型 -> 数据库模型
+func (m *UserMemoryRevisionModel) FromUserMemoryRevision(revision *builtin.UserMemoryRevision) {
+	m.ID = revision.ID
+	m.UserID = revision.UserID
+	m.Namespace = revision.Namespace
+	m.Revision = revision.Revision
+	m.Memory = revision.Memory
+	m.Diff = revision.Diff
+	m.Status = revision.Status
+	m.Source = revision.Source
+	m.SessionID = revision.SessionID
+	m.Model = revision.Model
+	m.Reason = revision.Reason
+	m.CreatedAt = revision.CreatedAt
+}
+
+// ToSession 将数据库模型转换为业务模型（不含消息统计）
+func (m *SessionModel) ToSession() *builtin.Session {
+	session := &builtin.Session{
+		SessionID: m.SessionID,
+		UserID:    m.UserID,
+		Title:     m.Title,
+		Archived:  m.Archived,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+	if m.ArchivedAt != nil {
+		session.ArchivedAt = *m.ArchivedAt
+	}
+	return session
+}
+
+// FromSession 将业务模型转换为数据库模型
+func (m *SessionModel) FromSession(session *builtin.Session) {
+	m.SessionID = session.SessionID
+	m.UserID = session.UserID
+	m.Title = session.Title
+	m.Archived = session.Archived
+	m.ArchivedAt = nil
+	if !session.ArchivedAt.IsZero() {
+		archivedAt := session.ArchivedAt
+		m.ArchivedAt = &archivedAt
+	}
+	m.CreatedAt = session.CreatedAt
+	m.UpdatedAt = session.UpdatedAt
+}
+
+// ToSessionState 将数据库模型转换为业务模型
+func (m *SummaryTriggerStateModel) ToSessionState() *builtin.SessionState {
+	state := &builtin.SessionState{
+		LastSummaryTime:          m.LastSummaryTime,
+		MessagesSinceLastSummary: m.MessagesSinceLastSummary,
+		TotalMessages:            m.TotalMessages,
+		UnsummarizedTokens:       m.UnsummarizedTokens,
+		TopicTurns:               m.TopicTurns,
+		TopicShifted:             m.TopicShifted,
+	}
+	if m.TopicCentroid != "" {
+		_ = json.Unmarshal([]byte(m.TopicCentroid), &state.TopicCentroid)
+	}
+	return state
+}
+
+// FromSessionState 将业务模型转换为数据库模型
+func (m *SummaryTriggerStateModel) FromSessionState(sessionID, userID string, state *builtin.SessionState) {
+	m.SessionID = sessionID
+	m.UserID = userID
+	m.LastSummaryTime = state.LastSummaryTime
+	m.MessagesSinceLastSummary = state.MessagesSinceLastSummary
+	m.TotalMessages = state.TotalMessages
+	m.UnsummarizedTokens = state.UnsummarizedTokens
+	m.TopicTurns = state.TopicTurns
+	m.TopicShifted = state.TopicShifted
+	m.TopicCentroid = ""
+	if len(state.TopicCentroid) > 0 {
+		if b, err := json.Marshal(state.TopicCentroid); err == nil {
+			m.TopicCentroid = string(b)
+		}
+	}
+}
+
+// aggregateTime 读取 MIN/MAX 聚合出的时间列。
+// 聚合结果不带列类型，SQLite 驱动（以及未开启 parseTime 的 MySQL 驱动）会返回字符串。
+type aggregateTime struct {
+	Time time.Time
+}
+
+var aggregateTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+// Scan 实现 sql.Scanner 接口
+func (t *aggregateTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	default:
+		return fmt.Errorf("无法解析时间列: %T", value)
+	}
+}
+
+// Value 实现 driver.Valuer 接口
+func (t aggregateTime) Value() (driver.Value, error) {
+	if t.Time.IsZero() {
+		return nil, nil
+	}
+	return t.Time, nil
+}
+
+func (t *aggregateTime) parse(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+	for _, layout := range aggregateTimeLayouts {
+		if parsed, err := time.Parse(layout, raw); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("无法解析时间: %s", raw)
 }
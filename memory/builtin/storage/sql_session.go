@@ -11,6 +11,7 @@ import (
 	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
 	"github.com/CoolBanHub/aggo/utils"
 	"github.com/gookit/slog"
+	"gorm.io/gorm"
 )
 
 // SaveMessage 保存对话消息
@@ -82,66 +83,82 @@ func (s *SQLStore) GetMessages(ctx context.Context, sessionID string, userID str
 	return messages, nil
 }
 
-func (s *SQLStore) SearchMessagesByKeywords(ctx context.Context, q *builtinsearch.SearchQuery) ([]*builtin.ConversationMessage, error) {
-	if q == nil {
-		return nil, errors.New("搜索参数不能为空")
-	}
-	if q.SessionID == "" {
-		return nil, errors.New("会话ID不能为空")
-	}
-	if q.UserID == "" {
+// GetUserMessages 返回用户全部会话中最新的 limit 条消息，按时间正序
+func (s *SQLStore) GetUserMessages(ctx context.Context, userID string, limit int) ([]*builtin.ConversationMessage, error) {
+	if userID == "" {
 		return nil, errors.New("用户ID不能为空")
 	}
 
-	keywords := q.Keywords
-	if len(keywords) == 0 {
-		keywords = builtinsearch.InferKeywords(q.Query)
+	var models []ConversationMessageModel
+	query := s.db.WithContext(ctx).Table(s.tableNameProvider.GetConversationMessageTableName()).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
 	}
-	if len(keywords) == 0 {
-		return []*builtin.ConversationMessage{}, nil
+	if err := query.Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("获取用户消息历史失败: %v", err)
 	}
 
-	query := s.db.WithContext(ctx).
-		Table(s.tableNameProvider.GetConversationMessageTableName()).
-		Where("session_id = ? AND user_id = ?", q.SessionID, q.UserID)
-
-	if role := strings.TrimSpace(q.Role); role != "" {
-		query = query.Where("role = ?", role)
+	messages := make([]*builtin.ConversationMessage, 0, len(models))
+	for i := len(models) - 1; i >= 0; i-- {
+		messages = append(messages, models[i].ToConversationMessage())
 	}
-	if q.Since != nil {
-		query = query.Where("created_at >= ?", q.Since)
+	return messages, nil
+}
+
+// SearchMessagesByKeywords 按关键词检索消息。
+// 启用全文检索时使用数据库全文索引并按相关度排序，否则使用 LIKE 查询按时间倒序返回。
+func (s *SQLStore) SearchMessagesByKeywords(ctx context.Context, q *builtinsearch.SearchQuery) ([]*builtin.ConversationMessage, error) {
+	if err := validateMessageSearchQuery(q); err != nil {
+		return nil, err
 	}
-	if q.Until != nil {
-		query = query.Where("created_at <= ?", q.Until)
+	keywords := normalizeSearchKeywords(s.tokenizer, q)
+	if len(keywords) == 0 {
+		return []*builtin.ConversationMessage{}, nil
 	}
 
-	switch builtinsearch.NormalizeMatch(q.Match) {
-	case builtinsearch.MatchAll:
-		for _, keyword := range keywords {
-			keyword = strings.TrimSpace(keyword)
-			if keyword == "" {
-				continue
-			}
-			query = query.Where("content LIKE ?", "%"+keyword+"%")
+	if s.fullTextDialect() != "" {
+		ranked, err := s.SearchMessagesRanked(ctx, q)
+		if err != nil {
+			return nil, err
 		}
-	default:
-		orQuery := s.db
-		for _, keyword := range keywords {
-			keyword = strings.TrimSpace(keyword)
-			if keyword == "" {
-				continue
-			}
-			orQuery = orQuery.Or("content LIKE ?", "%"+keyword+"%")
+		messages := make([]*builtin.ConversationMessage, 0, len(ranked))
+		for _, item := range ranked {
+			messages = append(messages, item.Message)
 		}
-		query = query.Where(orQuery)
+		return messages, nil
 	}
-
-	query = query.Order("created_at DESC").Order("id DESC")
 	limit := q.Limit
 	if limit <= 0 {
 		limit = 5
 	}
-	query = query.Limit(limit)
+	return s.searchMessagesByLike(ctx, q, keywords, limit)
+}
+
+// searchMessagesByLike 按 LIKE 条件检索消息，按时间倒序返回；limit <= 0 时不限制条数
+func (s *SQLStore) searchMessagesByLike(ctx context.Context, q *builtinsearch.SearchQuery, keywords []string, limit int) ([]*builtin.ConversationMessage, error) {
+	query := s.db.WithContext(ctx).
+		Table(s.tableNameProvider.GetConversationMessageTableName())
+	query = applyMessageSearchFilters(query, q, "")
+
+	clauses := make([]string, 0, len(keywords))
+	var args []any
+	for _, keyword := range keywords {
+		clause, clauseArgs := likeKeywordClause(s.tokenizer, []string{"content"}, keyword)
+		clauses = append(clauses, clause)
+		args = append(args, clauseArgs...)
+	}
+	sep := " OR "
+	if builtinsearch.NormalizeMatch(q.Match) == builtinsearch.MatchAll {
+		sep = " AND "
+	}
+	query = query.Where(strings.Join(clauses, sep), args...)
+
+	query = query.Order("created_at DESC").Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
 
 	var models []ConversationMessageModel
 	if err := query.Find(&models).Error; err != nil {
@@ -155,6 +172,51 @@ func (s *SQLStore) SearchMessagesByKeywords(ctx context.Context, q *builtinsearc
 	return messages, nil
 }
 
+func validateMessageSearchQuery(q *builtinsearch.SearchQuery) error {
+	if q == nil {
+		return errors.New("搜索参数不能为空")
+	}
+	if q.UserID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	return nil
+}
+
+// normalizeSearchKeywords 返回去除空白后的关键词，未指定时从 Query 推断
+func normalizeSearchKeywords(tokenizer builtinsearch.Tokenizer, q *builtinsearch.SearchQuery) []string {
+	keywords := q.Keywords
+	if len(keywords) == 0 {
+		keywords = builtinsearch.InferKeywordsWith(tokenizer, q.Query)
+	}
+	out := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		keyword = strings.TrimSpace(keyword)
+		if keyword != "" {
+			out = append(out, keyword)
+		}
+	}
+	return out
+}
+
+// applyMessageSearchFilters 追加用户、会话、角色与时间范围过滤条件，column 前缀用于联表查询。
+// SessionID 为空时跨会话检索。
+func applyMessageSearchFilters(query *gorm.DB, q *builtinsearch.SearchQuery, prefix string) *gorm.DB {
+	query = query.Where(prefix+"user_id = ?", q.UserID)
+	if q.SessionID != "" {
+		query = query.Where(prefix+"session_id = ?", q.SessionID)
+	}
+	if role := strings.TrimSpace(q.Role); role != "" {
+		query = query.Where(prefix+"role = ?", role)
+	}
+	if q.Since != nil {
+		query = query.Where(prefix+"created_at >= ?", q.Since)
+	}
+	if q.Until != nil {
+		query = query.Where(prefix+"created_at <= ?", q.Until)
+	}
+	return query
+}
+
 // GetMessagesAfter 获取游标之后的会话消息历史。
 // Results are returned in chronological order (oldest first). Internally the query
 // uses DESC ordering with LIMIT to fetch the most recent N messages efficiently,
@@ -243,6 +305,55 @@ func (s *SQLStore) DeleteMessages(ctx context.Context, sessionID string, userID
 	return nil
 }
 
+// ListMessageSessions 返回所有存在消息的会话（跨用户）
+func (s *SQLStore) ListMessageSessions(ctx context.Context) ([]*builtin.Session, error) {
+	var rows []struct {
+		UserID       string
+		SessionID    string
+		MessageCount int
+		FirstAt      aggregateTime
+		LastAt       aggregateTime
+	}
+	if err := s.db.WithContext(ctx).
+		Table(s.tableNameProvider.GetConversationMessageTableName()).
+		Select("user_id, session_id, COUNT(*) AS message_count, MIN(created_at) AS first_at, MAX(created_at) AS last_at").
+		Group("user_id, session_id").
+		Order("user_id, session_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计会话消息失败: %v", err)
+	}
+
+	sessions := make([]*builtin.Session, 0, len(rows))
+	for _, row := range rows {
+		st := sessionStats{count: row.MessageCount, first: row.FirstAt.Time, last: row.LastAt.Time}
+		sessions = append(sessions, buildSession(nil, row.SessionID, row.UserID, st))
+	}
+	return sessions, nil
+}
+
+// DeleteMessagesByIDs 删除会话中指定 ID 的消息
+func (s *SQLStore) DeleteMessagesByIDs(ctx context.Context, sessionID, userID string, ids []string) (int, error) {
+	if sessionID == "" {
+		return 0, errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return 0, errors.New("用户ID不能为空")
+	}
+
+	deleted := 0
+	for start := 0; start < len(ids); start += 500 {
+		end := min(start+500, len(ids))
+		result := s.db.WithContext(ctx).Table(s.tableNameProvider.GetConversationMessageTableName()).
+			Where("session_id = ? AND user_id = ? AND id IN ?", sessionID, userID, ids[start:end]).
+			Delete(&ConversationMessageModel{})
+		if result.Error != nil {
+			return deleted, fmt.Errorf("删除消息失败: %v", result.Error)
+		}
+		deleted += int(result.RowsAffected)
+	}
+	return deleted, nil
+}
+
 // CleanupOldMessages 清理指定时间之前的消息
 func (s *SQLStore) CleanupOldMessages(ctx context.Context, userID string, before time.Time) error {
 	if userID == "" {
@@ -346,3 +457,24 @@ func (s *SQLStore) GetMessageCount(ctx context.Context, userID, sessionID string
 
 	return int(count), nil
 }
+
+// ListAttachmentRefs 返回所有消息引用的外置附件 key
+func (s *SQLStore) ListAttachmentRefs(ctx context.Context) ([]string, error) {
+	var (
+		refs  []string
+		batch []ConversationMessageModel
+	)
+	result := s.db.WithContext(ctx).Table(s.tableNameProvider.GetConversationMessageTableName()).
+		Select("id", "parts").
+		Where("parts LIKE ?", "%"+builtin.AttachmentURLPrefix+"%").
+		FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, model := range batch {
+				refs = append(refs, builtin.AttachmentRefs(model.Parts)...)
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("获取附件引用失败: %v", result.Error)
+	}
+	return refs, nil
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"gorm.io/gorm/clause"
+)
+
+// sessionStatsRow 按会话聚合的消息统计
+type sessionStatsRow struct {
+	SessionID    string
+	MessageCount int
+	FirstAt      aggregateTime
+	LastAt       aggregateTime
+}
+
+func (r sessionStatsRow) stats() sessionStats {
+	return sessionStats{count: r.MessageCount, first: r.FirstAt.Time, last: r.LastAt.Time}
+}
+
+// querySessionStats 统计用户各会话的消息数与时间范围，sessionID 非空时只统计该会话
+func (s *SQLStore) querySessionStats(ctx context.Context, userID, sessionID string) ([]sessionStatsRow, error) {
+	query := s.db.WithContext(ctx).
+		Table(s.tableNameProvider.GetConversationMessageTableName()).
+		Select("session_id, COUNT(*) AS message_count, MIN(created_at) AS first_at, MAX(created_at) AS last_at").
+		Where("user_id = ?", userID)
+	if sessionID != "" {
+		query = query.Where("session_id = ?", sessionID)
+	}
+
+	var rows []sessionStatsRow
+	if err := query.Group("session_id").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计会话消息失败: %v", err)
+	}
+	return rows, nil
+}
+
+// ListSessions 按最近活跃时间倒序返回用户的会话
+func (s *SQLStore) ListSessions(ctx context.Context, query *builtin.SessionQuery) ([]*builtin.Session, error) {
+	if query == nil || query.UserID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	rows, err := s.querySessionStats(ctx, query.UserID, "")
+	if err != nil {
+		return nil, err
+	}
+	var models []SessionModel
+	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetSessionTableName()).
+		Where("user_id = ?", query.UserID).Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("获取会话元数据失败: %v", err)
+	}
+
+	metas := make(map[string]*builtin.Session, len(models))
+	for i := range models {
+		metas[models[i].SessionID] = models[i].ToSession()
+	}
+	sessions := make([]*builtin.Session, 0, len(rows)+len(models))
+	for _, row := range rows {
+		sessions = append(sessions, buildSession(metas[row.SessionID], row.SessionID, query.UserID, row.stats()))
+		delete(metas, row.SessionID)
+	}
+	for sessionID, meta := range metas {
+		sessions = append(sessions, buildSession(meta, sessionID, query.UserID, sessionStats{}))
+	}
+	return pageSessions(sessions, query), nil
+}
+
+// GetSession 获取会话信息
+func (s *SQLStore) GetSession(ctx context.Context, sessionID, userID string) (*builtin.Session, error) {
+	if sessionID == "" {
+		return nil, errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	rows, err := s.querySessionStats(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	var models []SessionModel
+	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetSessionTableName()).
+		Where("session_id = ? AND user_id = ?", sessionID, userID).Limit(1).Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("获取会话元数据失败: %v", err)
+	}
+
+	var meta *builtin.Session
+	if len(models) > 0 {
+		meta = models[0].ToSession()
+	}
+	var st sessionStats
+	if len(rows) > 0 {
+		st = rows[0].stats()
+	}
+	if meta == nil && st.count == 0 {
+		return nil, nil
+	}
+	return buildSession(meta, sessionID, userID, st), nil
+}
+
+// SaveSession 创建或更新会话元数据
+func (s *SQLStore) SaveSession(ctx context.Context, session *builtin.Session) error {
+	if session == nil {
+		return errors.New("会话对象不能为空")
+	}
+	if session.SessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if session.UserID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	now := time.Now()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	session.UpdatedAt = now
+
+	model := &SessionModel{}
+	model.FromSession(session)
+	// 已存在时保留原创建时间，只更新元数据字段
+	err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetSessionTableName()).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "session_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"title", "archived", "archived_at", "updated_at"}),
+		}).Create(model).Error
+	if err != nil {
+		return fmt.Errorf("保存会话元数据失败: %v", err)
+	}
+	return nil
+}
+
+// DeleteSession 删除会话元数据
+func (s *SQLStore) DeleteSession(ctx context.Context, sessionID, userID string) error {
+	if sessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetSessionTableName()).
+		Where("session_id = ? AND user_id = ?", sessionID, userID).
+		Delete(&SessionModel{}).Error; err != nil {
+		return fmt.Errorf("删除会话元数据失败: %v", err)
+	}
+	return nil
+}
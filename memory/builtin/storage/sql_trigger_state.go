@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetSummaryTriggerState 获取会话的摘要触发状态
+func (s *SQLStore) GetSummaryTriggerState(ctx context.Context, sessionID, userID string) (*builtin.SessionState, error) {
+	if sessionID == "" {
+		return nil, errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	var model SummaryTriggerStateModel
+	err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetSummaryTriggerStateTableName()).
+		Where("session_id = ? AND user_id = ?", sessionID, userID).
+		First(&model).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("获取摘要触发状态失败: %v", err)
+	}
+	return model.ToSessionState(), nil
+}
+
+// SaveSummaryTriggerState 创建或覆盖会话的摘要触发状态
+func (s *SQLStore) SaveSummaryTriggerState(ctx context.Context, sessionID, userID string, state *builtin.SessionState) error {
+	if sessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	if state == nil {
+		return errors.New("触发状态不能为空")
+	}
+
+	model := &SummaryTriggerStateModel{}
+	model.FromSessionState(sessionID, userID, state)
+	err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetSummaryTriggerStateTableName()).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "session_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"last_summary_time", "messages_since_last_summary", "total_messages", "unsummarized_tokens",
+				"topic_centroid", "topic_turns", "topic_shifted", "updated_at",
+			}),
+		}).Create(model).Error
+	if err != nil {
+		return fmt.Errorf("保存摘要触发状态失败: %v", err)
+	}
+	return nil
+}
+
+// DeleteSummaryTriggerState 删除会话的摘要触发状态
+func (s *SQLStore) DeleteSummaryTriggerState(ctx context.Context, sessionID, userID string) error {
+	if sessionID == "" {
+		return errors.New("会话ID不能为空")
+	}
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetSummaryTriggerStateTableName()).
+		Where("session_id = ? AND user_id = ?", sessionID, userID).
+		Delete(&SummaryTriggerStateModel{}).Error; err != nil {
+		return fmt.Errorf("删除摘要触发状态失败: %v", err)
+	}
+	return nil
+}
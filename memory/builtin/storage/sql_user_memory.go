@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UpsertUserMemory 创建或更新用户记忆（每个用户一条记录）
@@ -30,19 +32,117 @@ func (s *SQLStore) UpsertUserMemory(ctx context.Context, userMemory *builtin.Use
 	userMemory.UpdatedAt = now
 
 	// 转换为数据库模型
+	userMemory.Namespace = builtin.NamespaceFromContext(ctx)
+	userMemory.Version = 1
 	model := &UserMemoryModel{}
 	model.FromUserMemory(userMemory)
 
 	// 使用 GORM 的 Clauses 实现 upsert（OnConflict）
-	// 主键是 UserID，所以会自动判断是创建还是更新
-	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetUserMemoryTableName()).
-		Save(model).Error; err != nil {
+	// 主键是 (UserID, Namespace)；默认命名空间为空字符串，Save 会把零值主键当作新记录，因此显式指定冲突列
+	table := s.tableNameProvider.GetUserMemoryTableName()
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(table).
+			Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "user_id"}, {Name: "namespace"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"memory":     userMemory.Memory,
+					"updated_at": userMemory.UpdatedAt,
+					"version":    gorm.Expr("version + 1"),
+				}),
+			}).
+			Create(model).Error; err != nil {
+			return err
+		}
+		return tx.Table(table).
+			Where("user_id = ? AND namespace = ?", userMemory.UserID, userMemory.Namespace).
+			Select("version").
+			Scan(&userMemory.Version).Error
+	})
+	if err != nil {
 		return fmt.Errorf("保存用户记忆到%s失败: %v", s.db.Config.Dialector.Name(), err)
 	}
 
 	return nil
 }
 
+// CompareAndSwapUserMemory 当前版本等于 expectedVersion 时写入用户记忆。
+// 先按版本号条件更新；没有命中且期望版本为 0 时尝试插入，主键冲突说明记录已被其他写入创建。
+func (s *SQLStore) CompareAndSwapUserMemory(ctx context.Context, userMemory *builtin.UserMemory, expectedVersion int64) error {
+	if userMemory == nil {
+		return errors.New("记忆对象不能为空")
+	}
+	if userMemory.UserID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	if userMemory.Memory == "" {
+		return errors.New("记忆内容不能为空")
+	}
+
+	now := time.Now()
+	userMemory.UpdatedAt = now
+	userMemory.Namespace = builtin.NamespaceFromContext(ctx)
+	table := s.tableNameProvider.GetUserMemoryTableName()
+
+	result := s.db.WithContext(ctx).Table(table).
+		Where("user_id = ? AND namespace = ? AND version = ?", userMemory.UserID, userMemory.Namespace, expectedVersion).
+		Updates(map[string]interface{}{
+			"memory":     userMemory.Memory,
+			"updated_at": now,
+			"version":    expectedVersion + 1,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("保存用户记忆到%s失败: %v", s.db.Config.Dialector.Name(), result.Error)
+	}
+	if result.RowsAffected == 0 && expectedVersion == 0 {
+		if userMemory.CreatedAt.IsZero() {
+			userMemory.CreatedAt = now
+		}
+		userMemory.Version = 1
+		model := &UserMemoryModel{}
+		model.FromUserMemory(userMemory)
+		result = s.db.WithContext(ctx).Table(table).Clauses(clause.OnConflict{DoNothing: true}).Create(model)
+		if result.Error != nil {
+			return fmt.Errorf("保存用户记忆到%s失败: %v", s.db.Config.Dialector.Name(), result.Error)
+		}
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: 期望版本 %d", builtin.ErrUserMemoryConflict, expectedVersion)
+	}
+	userMemory.Version = expectedVersion + 1
+	return nil
+}
+
+// CompareAndDeleteUserMemory 当前版本等于 expectedVersion 时删除用户记忆。
+// 没有删除任何记录时，只有期望版本为 0 且记录确实不存在才视为成功。
+func (s *SQLStore) CompareAndDeleteUserMemory(ctx context.Context, userID string, expectedVersion int64) error {
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+
+	namespace := builtin.NamespaceFromContext(ctx)
+	table := s.tableNameProvider.GetUserMemoryTableName()
+	result := s.db.WithContext(ctx).Table(table).
+		Where("user_id = ? AND namespace = ? AND version = ?", userID, namespace, expectedVersion).
+		Delete(&UserMemoryModel{})
+	if result.Error != nil {
+		return fmt.Errorf("清空用户记忆失败: %v", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	if expectedVersion == 0 {
+		var count int64
+		if err := s.db.WithContext(ctx).Table(table).
+			Where("user_id = ? AND namespace = ?", userID, namespace).Count(&count).Error; err != nil {
+			return fmt.Errorf("清空用户记忆失败: %v", err)
+		}
+		if count == 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: 期望版本 %d", builtin.ErrUserMemoryConflict, expectedVersion)
+}
+
 // GetUserMemory 获取用户的记忆
 func (s *SQLStore) GetUserMemory(ctx context.Context, userID string) (*builtin.UserMemory, error) {
 	if userID == "" {
@@ -51,7 +151,7 @@ func (s *SQLStore) GetUserMemory(ctx context.Context, userID string) (*builtin.U
 
 	var model UserMemoryModel
 	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetUserMemoryTableName()).
-		Where("user_id = ?", userID).First(&model).Error; err != nil {
+		Where("user_id = ? AND namespace = ?", userID, builtin.NamespaceFromContext(ctx)).First(&model).Error; err != nil {
 		// 如果记录不存在，返回nil而不是错误
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -69,9 +169,87 @@ func (s *SQLStore) ClearUserMemory(ctx context.Context, userID string) error {
 	}
 
 	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetUserMemoryTableName()).
-		Where("user_id = ?", userID).Delete(&UserMemoryModel{}).Error; err != nil {
+		Where("user_id = ? AND namespace = ?", userID, builtin.NamespaceFromContext(ctx)).Delete(&UserMemoryModel{}).Error; err != nil {
 		return fmt.Errorf("清空用户记忆失败: %v", err)
 	}
 
 	return nil
 }
+
+// SaveUserMemoryRevision 追加一个用户记忆版本，版本号取该用户在当前命名空间下的最大版本号 + 1
+func (s *SQLStore) SaveUserMemoryRevision(ctx context.Context, revision *builtin.UserMemoryRevision) error {
+	if revision == nil {
+		return errors.New("记忆版本不能为空")
+	}
+	if revision.UserID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	if revision.ID == "" {
+		revision.ID = utils.GetULID()
+	}
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now()
+	}
+
+	revision.Namespace = builtin.NamespaceFromContext(ctx)
+	table := s.tableNameProvider.GetUserMemoryRevisionTableName()
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Table(table).
+			Where("user_id = ? AND namespace = ?", revision.UserID, revision.Namespace).
+			Select("COALESCE(MAX(revision), 0)").
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+		revision.Revision = latest + 1
+
+		model := &UserMemoryRevisionModel{}
+		model.FromUserMemoryRevision(revision)
+		return tx.Table(table).Create(model).Error
+	})
+	if err != nil {
+		return fmt.Errorf("保存用户记忆版本失败: %v", err)
+	}
+	return nil
+}
+
+// ListUserMemoryRevisions 按版本号倒序返回用户记忆版本
+func (s *SQLStore) ListUserMemoryRevisions(ctx context.Context, userID string, limit int) ([]*builtin.UserMemoryRevision, error) {
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	query := s.db.WithContext(ctx).Table(s.tableNameProvider.GetUserMemoryRevisionTableName()).
+		Where("user_id = ? AND namespace = ?", userID, builtin.NamespaceFromContext(ctx)).
+		Order("revision DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var models []UserMemoryRevisionModel
+	if err := query.Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("获取用户记忆版本失败: %v", err)
+	}
+	out := make([]*builtin.UserMemoryRevision, 0, len(models))
+	for i := range models {
+		out = append(out, models[i].ToUserMemoryRevision())
+	}
+	return out, nil
+}
+
+// GetUserMemoryRevision 获取指定用户记忆版本
+func (s *SQLStore) GetUserMemoryRevision(ctx context.Context, userID, revisionID string) (*builtin.UserMemoryRevision, error) {
+	if userID == "" {
+		return nil, errors.New("用户ID不能为空")
+	}
+
+	var model UserMemoryRevisionModel
+	if err := s.db.WithContext(ctx).Table(s.tableNameProvider.GetUserMemoryRevisionTableName()).
+		Where("user_id = ? AND namespace = ? AND id = ?", userID, builtin.NamespaceFromContext(ctx), revisionID).First(&model).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("获取用户记忆版本失败: %v", err)
+	}
+	return model.ToUserMemoryRevision(), nil
+}
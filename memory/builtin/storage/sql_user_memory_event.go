@@ -9,6 +9,7 @@ import (
 
 	"github.com/CoolBanHub/aggo/memory/builtin"
 	"github.com/CoolBanHub/aggo/utils"
+	"gorm.io/gorm"
 )
 
 // SaveUserMemoryEvent 保存一条用户记忆事件
@@ -37,6 +38,7 @@ func (s *SQLStore) SaveUserMemoryEvent(ctx context.Context, event *builtin.UserM
 		event.Type = builtin.UserMemoryEventTypeEvent
 	}
 
+	event.Namespace = builtin.NamespaceFromContext(ctx)
 	model := &UserMemoryEventModel{}
 	model.FromUserMemoryEvent(event)
 
@@ -57,7 +59,8 @@ func (s *SQLStore) ListRecentUserMemoryEvents(ctx context.Context, userID string
 	var rows []UserMemoryEventModel
 	q := s.db.WithContext(ctx).
 		Table(s.tableNameProvider.GetUserMemoryEventTableName()).
-		Where("user_id = ?", userID).
+		Where("user_id = ? AND namespace = ?", userID, builtin.NamespaceFromContext(ctx)).
+		Where(activeEventClause).
 		Order("event_date DESC, created_at DESC")
 	if limit > 0 {
 		q = q.Limit(limit)
@@ -81,8 +84,14 @@ func (s *SQLStore) SearchUserMemoryEvents(ctx context.Context, query *builtin.Us
 
 	q := s.db.WithContext(ctx).
 		Table(s.tableNameProvider.GetUserMemoryEventTableName()).
-		Where("user_id = ?", query.UserID)
+		Where("user_id = ? AND namespace = ?", query.UserID, builtin.NamespaceFromContext(ctx))
 
+	if !query.IncludeSuperseded {
+		q = q.Where(activeEventClause)
+	}
+	if ids := dedupNonEmpty(query.IDs); len(ids) > 0 {
+		q = q.Where("id IN ?", ids)
+	}
 	if query.Type != "" {
 		q = q.Where("type = ?", query.Type)
 	}
@@ -104,9 +113,9 @@ func (s *SQLStore) SearchUserMemoryEvents(ctx context.Context, query *builtin.Us
 		clauseParts := make([]string, 0, len(keywords))
 		args := make([]any, 0, len(keywords)*2)
 		for _, kw := range keywords {
-			like := "%" + kw + "%"
-			clauseParts = append(clauseParts, "(summary LIKE ? OR keywords LIKE ?)")
-			args = append(args, like, like)
+			clause, clauseArgs := likeKeywordClause(s.tokenizer, []string{"summary", "keywords"}, kw)
+			clauseParts = append(clauseParts, clause)
+			args = append(args, clauseArgs...)
 		}
 		sep := " OR "
 		if match == "all" {
@@ -142,7 +151,7 @@ func (s *SQLStore) DeleteUserMemoryEvent(ctx context.Context, userID, eventID st
 	}
 	if err := s.db.WithContext(ctx).
 		Table(s.tableNameProvider.GetUserMemoryEventTableName()).
-		Where("user_id = ? AND id = ?", userID, eventID).
+		Where("user_id = ? AND namespace = ? AND id = ?", userID, builtin.NamespaceFromContext(ctx), eventID).
 		Delete(&UserMemoryEventModel{}).Error; err != nil {
 		return fmt.Errorf("删除用户记忆事件失败: %v", err)
 	}
@@ -156,13 +165,85 @@ func (s *SQLStore) ClearUserMemoryEvents(ctx context.Context, userID string) err
 	}
 	if err := s.db.WithContext(ctx).
 		Table(s.tableNameProvider.GetUserMemoryEventTableName()).
-		Where("user_id = ?", userID).
+		Where("user_id = ? AND namespace = ?", userID, builtin.NamespaceFromContext(ctx)).
 		Delete(&UserMemoryEventModel{}).Error; err != nil {
 		return fmt.Errorf("清空用户记忆事件失败: %v", err)
 	}
 	return nil
 }
 
+// SupersedeUserMemoryEvents 将事件标记为被 supersededBy 取代
+func (s *SQLStore) SupersedeUserMemoryEvents(ctx context.Context, userID, supersededBy string, eventIDs []string) error {
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	if supersededBy == "" {
+		return errors.New("取代事件ID不能为空")
+	}
+	ids := make([]string, 0, len(eventIDs))
+	for _, id := range dedupNonEmpty(eventIDs) {
+		if id != supersededBy {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := s.db.WithContext(ctx).
+		Table(s.tableNameProvider.GetUserMemoryEventTableName()).
+		Where("user_id = ? AND namespace = ? AND id IN ?", userID, builtin.NamespaceFromContext(ctx), ids).
+		Where(activeEventClause).
+		Updates(map[string]any{
+			"superseded_by": supersededBy,
+			"superseded_at": time.Now(),
+		}).Error; err != nil {
+		return fmt.Errorf("标记用户记忆事件失败: %v", err)
+	}
+	return nil
+}
+
+// TouchUserMemoryEvents 累加事件访问次数并更新最近访问时间
+func (s *SQLStore) TouchUserMemoryEvents(ctx context.Context, userID string, eventIDs []string, at time.Time) error {
+	if userID == "" {
+		return errors.New("用户ID不能为空")
+	}
+	ids := dedupNonEmpty(eventIDs)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := s.db.WithContext(ctx).
+		Table(s.tableNameProvider.GetUserMemoryEventTableName()).
+		Where("user_id = ? AND namespace = ? AND id IN ?", userID, builtin.NamespaceFromContext(ctx), ids).
+		Updates(map[string]any{
+			"access_count":     gorm.Expr("access_count + 1"),
+			"last_accessed_at": at,
+		}).Error; err != nil {
+		return fmt.Errorf("更新用户记忆事件访问记录失败: %v", err)
+	}
+	return nil
+}
+
+// ListUserMemoryEventOwners 返回在 since 之后新增过事件的记忆归属（跨所有命名空间）
+func (s *SQLStore) ListUserMemoryEventOwners(ctx context.Context, since time.Time) ([]builtin.UserMemoryOwner, error) {
+	q := s.db.WithContext(ctx).
+		Table(s.tableNameProvider.GetUserMemoryEventTableName()).
+		Distinct("namespace", "user_id")
+	if !since.IsZero() {
+		q = q.Where("created_at >= ?", since)
+	}
+
+	var owners []builtin.UserMemoryOwner
+	if err := q.Order("namespace, user_id").Scan(&owners).Error; err != nil {
+		return nil, fmt.Errorf("查询用户记忆事件用户失败: %v", err)
+	}
+	return owners, nil
+}
+
+// activeEventClause 未被取代的事件。老数据新增列后可能为 NULL
+const activeEventClause = "(superseded_by IS NULL OR superseded_by = '')"
+
 func dedupNonEmpty(in []string) []string {
 	if len(in) == 0 {
 		return nil
@@ -32,3 +32,28 @@ func (p *TableNameProvider) GetConversationMessageTableName() string {
 func (p *TableNameProvider) GetUserMemoryEventTableName() string {
 	return p.tablePrefix + "_user_memory_events"
 }
+
+// GetUserMemoryRevisionTableName returns the table name for user memory revisions
+func (p *TableNameProvider) GetUserMemoryRevisionTableName() string {
+	return p.tablePrefix + "_user_memory_revisions"
+}
+
+// GetSessionTableName returns the table name for session metadata
+func (p *TableNameProvider) GetSessionTableName() string {
+	return p.tablePrefix + "_sessions"
+}
+
+// GetSummaryTriggerStateTableName returns the table name for summary trigger states
+func (p *TableNameProvider) GetSummaryTriggerStateTableName() string {
+	return p.tablePrefix + "_summary_trigger_states"
+}
+
+// GetSummaryChunkTableName returns the table name for summary chunks
+func (p *TableNameProvider) GetSummaryChunkTableName() string {
+	return p.tablePrefix + "_summary_chunks"
+}
+
+// GetUserDigestTableName returns the table name for user digests
+func (p *TableNameProvider) GetUserDigestTableName() string {
+	return p.tablePrefix + "_user_digests"
+}
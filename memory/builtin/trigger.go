@@ -14,14 +14,29 @@ type SummaryTriggerManager struct {
 	mutex         sync.RWMutex
 }
 
-// SessionState 会话状态
+// SessionState 会话状态，存储实现 SummaryTriggerStateStorage 时会被持久化，重启后继续累计
 type SessionState struct {
 	// 上次摘要更新时间
-	LastSummaryTime time.Time
+	LastSummaryTime time.Time `json:"lastSummaryTime"`
 	// 上次摘要后新增的消息数量
-	MessagesSinceLastSummary int
+	MessagesSinceLastSummary int `json:"messagesSinceLastSummary"`
 	// 会话的总消息数量
-	TotalMessages int
+	TotalMessages int `json:"totalMessages"`
+	// 上次摘要后新增消息的估算 token 数
+	UnsummarizedTokens int `json:"unsummarizedTokens"`
+	// 当前话题的向量（各轮对话向量的均值），话题切换时重置
+	TopicCentroid []float64 `json:"topicCentroid,omitempty"`
+	// 计入当前话题向量的对话轮数
+	TopicTurns int `json:"topicTurns"`
+	// 是否检测到话题切换且尚未生成摘要
+	TopicShifted bool `json:"topicShifted,omitempty"`
+}
+
+// clone 返回状态的深拷贝
+func (s *SessionState) clone() *SessionState {
+	copied := *s
+	copied.TopicCentroid = append([]float64(nil), s.TopicCentroid...)
+	return &copied
 }
 
 // NewSummaryTriggerManager 创建新的摘要触发管理器
@@ -36,6 +51,15 @@ func NewSummaryTriggerManager(config SummaryTriggerConfig) *SummaryTriggerManage
 	if config.Strategy == "" {
 		config.Strategy = TriggerSmart
 	}
+	if config.TokenThreshold <= 0 {
+		config.TokenThreshold = 4000
+	}
+	if config.TopicShiftSimilarity <= 0 {
+		config.TopicShiftSimilarity = 0.6
+	}
+	if config.MinTopicShiftMessages <= 0 {
+		config.MinTopicShiftMessages = 4
+	}
 
 	return &SummaryTriggerManager{
 		config:        config,
@@ -106,6 +130,9 @@ func (stm *SummaryTriggerManager) ShouldTriggerSummary(sessionKey string, curren
 	case TriggerSmart:
 		return stm.shouldTriggerSmart(state)
 
+	case TriggerByTokens, TriggerTopicShift:
+		return stm.shouldTriggerByState(state)
+
 	default:
 		return true
 	}
@@ -143,6 +170,22 @@ func (stm *SummaryTriggerManager) shouldTriggerSmart(state *SessionState) bool {
 	return false
 }
 
+// shouldTriggerByState 基于累计状态的触发判断（by_tokens、topic_shift）
+func (stm *SummaryTriggerManager) shouldTriggerByState(state *SessionState) bool {
+	if state.MessagesSinceLastSummary <= 0 {
+		return false
+	}
+	switch stm.config.Strategy {
+	case TriggerByTokens:
+		return state.UnsummarizedTokens >= stm.config.TokenThreshold
+	case TriggerTopicShift:
+		// 话题一直不变或没有向量模型时，按消息数量兜底
+		return state.TopicShifted || state.MessagesSinceLastSummary >= stm.config.MessageThreshold*2
+	default:
+		return false
+	}
+}
+
 // MarkSummaryUpdated 标记摘要已更新
 func (stm *SummaryTriggerManager) MarkSummaryUpdated(sessionKey string) {
 	stm.mutex.Lock()
@@ -151,6 +194,8 @@ func (stm *SummaryTriggerManager) MarkSummaryUpdated(sessionKey string) {
 	if state, exists := stm.sessionStates[sessionKey]; exists {
 		state.LastSummaryTime = time.Now()
 		state.MessagesSinceLastSummary = 0
+		state.UnsummarizedTokens = 0
+		state.TopicShifted = false
 	}
 }
 
@@ -168,15 +213,18 @@ func (stm *SummaryTriggerManager) GetSessionState(sessionKey string) *SessionSta
 
 	if state, exists := stm.sessionStates[sessionKey]; exists {
 		// 返回副本避免并发问题
-		return &SessionState{
-			LastSummaryTime:          state.LastSummaryTime,
-			MessagesSinceLastSummary: state.MessagesSinceLastSummary,
-			TotalMessages:            state.TotalMessages,
-		}
+		return state.clone()
 	}
 	return nil
 }
 
+// setSessionState 保存会话状态的副本
+func (stm *SummaryTriggerManager) setSessionState(sessionKey string, state *SessionState) {
+	stm.mutex.Lock()
+	defer stm.mutex.Unlock()
+	stm.sessionStates[sessionKey] = state.clone()
+}
+
 // CleanupOldSessions 清理旧会话状态（建议定期调用）
 func (stm *SummaryTriggerManager) CleanupOldSessions(maxAge time.Duration) {
 	stm.mutex.Lock()
@@ -190,6 +238,13 @@ func (stm *SummaryTriggerManager) CleanupOldSessions(maxAge time.Duration) {
 	}
 }
 
+// RemoveSession 移除会话状态，会话被删除时调用
+func (stm *SummaryTriggerManager) RemoveSession(sessionKey string) {
+	stm.mutex.Lock()
+	defer stm.mutex.Unlock()
+	delete(stm.sessionStates, sessionKey)
+}
+
 // generateSessionKey 生成会话键
 func generateSessionKey(userID, sessionID string) string {
 	return userID + ":" + sessionID
@@ -0,0 +1,196 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/gookit/slog"
+)
+
+// triggerStateStorage 返回实现了 SummaryTriggerStateStorage 的存储，未实现时返回 nil
+func (m *MemoryManager) triggerStateStorage() SummaryTriggerStateStorage {
+	s, _ := m.storage.(SummaryTriggerStateStorage)
+	return s
+}
+
+// syncSummaryTriggerState 把会话的触发状态与存储中的消息对齐：
+// 依次从内存、持久化存储读取状态，都没有时由未摘要消息重新估算；
+// 再把上次同步后新增的消息计入消息数、token 数与话题向量，并持久化。
+func (m *MemoryManager) syncSummaryTriggerState(ctx context.Context, userID, sessionID string, summary *SessionSummary, totalMessageCount int) (*SessionState, error) {
+	key := generateSessionKey(userID, sessionID)
+	state := m.summaryTrigger.GetSessionState(key)
+	if state == nil {
+		if store := m.triggerStateStorage(); store != nil {
+			persisted, err := store.GetSummaryTriggerState(ctx, sessionID, userID)
+			if err != nil {
+				return nil, fmt.Errorf("获取摘要触发状态失败: %w", err)
+			}
+			state = persisted
+		}
+	}
+
+	var (
+		newMessages []*ConversationMessage
+		err         error
+		rebuilt     bool
+	)
+	switch {
+	case state == nil:
+		// 没有任何记录（首次出现或重启且存储不支持持久化），由未摘要消息重新估算
+		rebuilt = true
+		state = &SessionState{}
+		if summary != nil {
+			state.LastSummaryTime = summary.UpdatedAt
+			newMessages, err = m.getMessagesAfterCursor(ctx, sessionID, userID, summary.LastSummarizedMessageID, effectiveSummaryBoundary(summary), 0)
+		} else {
+			newMessages, err = m.storage.GetMessages(ctx, sessionID, userID, 0)
+		}
+	case totalMessageCount > state.TotalMessages:
+		newMessages, err = m.storage.GetMessages(ctx, sessionID, userID, totalMessageCount-state.TotalMessages)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取新增消息失败: %w", err)
+	}
+
+	// 消息被清理后总数可能变小，只需对齐总数
+	changed := rebuilt || len(newMessages) > 0 || state.TotalMessages != totalMessageCount
+	state.TotalMessages = totalMessageCount
+	// 工具结果不进入摘要输入，也不计入触发阈值
+	for _, msg := range newMessages {
+		if msg.Role == MessageRoleTool {
+			continue
+		}
+		state.MessagesSinceLastSummary++
+		state.UnsummarizedTokens += m.countTokens(conversationMessageToPlainText(msg))
+	}
+	if m.summaryTrigger.config.Strategy == TriggerTopicShift && len(newMessages) > 0 {
+		m.trackTopic(ctx, state, newMessages, rebuilt)
+	}
+
+	m.summaryTrigger.setSessionState(key, state)
+	if changed {
+		m.saveSummaryTriggerState(ctx, userID, sessionID, state)
+	}
+	return state, nil
+}
+
+// markSummaryUpdated 摘要生成成功后重置触发计数并持久化，话题向量保留用于后续的切换判断
+func (m *MemoryManager) markSummaryUpdated(ctx context.Context, userID, sessionID string) {
+	key := generateSessionKey(userID, sessionID)
+	m.summaryTrigger.MarkSummaryUpdated(key)
+
+	state := m.summaryTrigger.GetSessionState(key)
+	if state == nil {
+		store := m.triggerStateStorage()
+		if store == nil {
+			return
+		}
+		persisted, err := store.GetSummaryTriggerState(ctx, sessionID, userID)
+		if err != nil || persisted == nil {
+			return
+		}
+		m.summaryTrigger.setSessionState(key, persisted)
+		m.summaryTrigger.MarkSummaryUpdated(key)
+		state = m.summaryTrigger.GetSessionState(key)
+	}
+	m.saveSummaryTriggerState(ctx, userID, sessionID, state)
+}
+
+// saveSummaryTriggerState 持久化触发状态，失败只记录日志，不影响对话流程
+func (m *MemoryManager) saveSummaryTriggerState(ctx context.Context, userID, sessionID string, state *SessionState) {
+	store := m.triggerStateStorage()
+	if store == nil {
+		return
+	}
+	if err := store.SaveSummaryTriggerState(ctx, sessionID, userID, state); err != nil {
+		slog.Warnf("保存摘要触发状态失败: sessionID=%s, userID=%s, err=%v", sessionID, userID, err)
+	}
+}
+
+// trackTopic 用新一轮对话的向量更新话题状态。与当前话题的相似度低于阈值、
+// 且旧话题已累积足够消息时标记为话题切换，并以新一轮对话作为新话题的起点。
+// 重新估算的状态只初始化话题向量，不做切换判断。
+func (m *MemoryManager) trackTopic(ctx context.Context, state *SessionState, newMessages []*ConversationMessage, rebuilt bool) {
+	embedder := m.topicShiftEmbedder()
+	if embedder == nil {
+		return
+	}
+	text := topicText(newMessages)
+	if text == "" {
+		return
+	}
+	vectors, err := embedder.EmbedStrings(ctx, []string{text})
+	if err != nil || len(vectors) == 0 || len(vectors[0]) == 0 {
+		slog.Warnf("话题切换计算向量失败，仅按消息数量兜底触发: %v", err)
+		return
+	}
+	vector := vectors[0]
+
+	cfg := m.summaryTrigger.config
+	previous := state.MessagesSinceLastSummary - len(newMessages)
+	if !rebuilt && len(state.TopicCentroid) == len(vector) && previous >= cfg.MinTopicShiftMessages &&
+		cosine(vector, state.TopicCentroid) < cfg.TopicShiftSimilarity {
+		state.TopicShifted = true
+		state.TopicCentroid = append([]float64(nil), vector...)
+		state.TopicTurns = 1
+		return
+	}
+
+	if len(state.TopicCentroid) != len(vector) || state.TopicTurns <= 0 {
+		state.TopicCentroid = append([]float64(nil), vector...)
+		state.TopicTurns = 1
+		return
+	}
+	n := float64(state.TopicTurns)
+	for i := range vector {
+		state.TopicCentroid[i] = (state.TopicCentroid[i]*n + vector[i]) / (n + 1)
+	}
+	state.TopicTurns++
+}
+
+func (m *MemoryManager) topicShiftEmbedder() embedding.Embedder {
+	if m.summaryTrigger.config.TopicShiftEmbedder != nil {
+		return m.summaryTrigger.config.TopicShiftEmbedder
+	}
+	if m.config.Search != nil {
+		return m.config.Search.Embedder
+	}
+	return nil
+}
+
+// topicText 拼接用户与助手的文本，工具调用结果不参与话题判断
+func topicText(messages []*ConversationMessage) string {
+	var lines []string
+	for _, msg := range messages {
+		if msg.Role == MessageRoleTool {
+			continue
+		}
+		if content := conversationMessageToPlainText(msg); content != "" {
+			lines = append(lines, content)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m *MemoryManager) countTokens(text string) int {
+	if counter := m.summaryTrigger.config.TokenCounter; counter != nil {
+		return counter(text)
+	}
+	return estimateTokens(text)
+}
+
+// estimateTokens 粗略估算 token 数：中日韩字符每个按 1 个 token 计，其余字符约 4 个计 1 个 token
+func estimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
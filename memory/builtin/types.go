@@ -5,6 +5,7 @@ import (
 
 	builtinsearch "github.com/CoolBanHub/aggo/memory/builtin/search"
 	"github.com/CoolBanHub/aggo/memory/memoryevent"
+	"github.com/cloudwego/eino/components/embedding"
 	"github.com/cloudwego/eino/schema"
 )
 
@@ -27,7 +28,18 @@ func (m *ConversationMessage) ToAgenticMessage() *schema.AgenticMessage {
 	msg := &schema.AgenticMessage{
 		Role: schema.AgenticRoleType(m.Role),
 	}
-	if len(m.Parts) > 0 {
+	if m.ToolResult != nil {
+		// 工具结果在 agentic 消息中以 user 角色的 FunctionToolResult 块表示
+		msg.Role = schema.AgenticRoleTypeUser
+		msg.ContentBlocks = []*schema.ContentBlock{schema.NewContentBlock(&schema.FunctionToolResult{
+			CallID: m.ToolResult.CallID,
+			Name:   m.ToolResult.Name,
+			Content: []*schema.FunctionToolResultContentBlock{{
+				Type: schema.FunctionToolResultContentBlockTypeText,
+				Text: &schema.UserInputText{Text: m.Content},
+			}},
+		})}
+	} else if len(m.Parts) > 0 {
 		msg.ContentBlocks = messageInputPartsToContentBlocks(m.Parts)
 	} else if m.Content != "" {
 		switch msg.Role {
@@ -37,6 +49,13 @@ func (m *ConversationMessage) ToAgenticMessage() *schema.AgenticMessage {
 			msg.ContentBlocks = []*schema.ContentBlock{schema.NewContentBlock(&schema.UserInputText{Text: m.Content})}
 		}
 	}
+	for _, call := range m.ToolCalls {
+		msg.ContentBlocks = append(msg.ContentBlocks, schema.NewContentBlock(&schema.FunctionToolCall{
+			CallID:    call.CallID,
+			Name:      call.Name,
+			Arguments: call.Arguments,
+		}))
+	}
 	msg.Extra = map[string]any{
 		MessageExtraIDKey:        m.ID,
 		MessageExtraSessionIDKey: m.SessionID,
@@ -109,16 +128,68 @@ func valueOrEmpty(v *string) string {
 // 每个用户一条记录，使用Markdown格式存储“常驻短文档”（核心约定 + 基础信息）。
 // 累积型条目（任务里程碑、事件记录）请改用 UserMemoryEvent，避免短文档无限膨胀。
 type UserMemory struct {
-	// 用户ID（主键）
+	// 用户ID（与 Namespace 组成主键）
 	UserID string `json:"userId"`
+	// 记忆命名空间，空为默认命名空间，由 storage 按写入时 ctx 中的命名空间填充
+	Namespace string `json:"namespace,omitempty"`
 	// 记忆内容（Markdown格式）
 	Memory string `json:"memory"`
+	// 版本号，每次写入加一，用于乐观并发控制；记录不存在时视为 0
+	Version int64 `json:"version"`
 	// 创建时间
 	CreatedAt time.Time `json:"createdAt"`
 	// 最后更新时间
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// 用户记忆版本状态
+const (
+	// UserMemoryRevisionApplied 已写入 UserMemory
+	UserMemoryRevisionApplied = "applied"
+	// UserMemoryRevisionRejected 未通过安全检查，仅记录在历史中，确认后可通过 RestoreUserMemoryRevision 应用
+	UserMemoryRevisionRejected = "rejected"
+)
+
+// 用户记忆版本来源
+const (
+	UserMemorySourceAnalyzer = "analyzer"
+	UserMemorySourceManual   = "manual"
+	UserMemorySourceRestore  = "restore"
+	// UserMemorySourceTool Agent 通过记忆编辑工具写入
+	UserMemorySourceTool = "tool"
+	// UserMemorySourceBaseline 启用历史前已存在的记忆，首次更新时补录
+	UserMemorySourceBaseline = "baseline"
+)
+
+// UserMemoryRevision 用户记忆的一个历史版本。每次写入 UserMemory 都会追加一条，
+// 被安全检查拦下的更新也会以 rejected 状态记录。
+type UserMemoryRevision struct {
+	// 版本 ID（ULID）
+	ID string `json:"id"`
+	// 用户 ID
+	UserID string `json:"userId"`
+	// 记忆命名空间，空为默认命名空间
+	Namespace string `json:"namespace,omitempty"`
+	// 该用户在该命名空间下递增的版本号，从 1 开始
+	Revision int `json:"revision"`
+	// 该版本的完整记忆内容
+	Memory string `json:"memory"`
+	// 相对上一个已应用版本的行级差异
+	Diff string `json:"diff,omitempty"`
+	// 状态 applied / rejected
+	Status string `json:"status"`
+	// 来源 analyzer / manual / restore / baseline
+	Source string `json:"source"`
+	// 产生该版本的会话 ID
+	SessionID string `json:"sessionId,omitempty"`
+	// 产生该版本的分析模型
+	Model string `json:"model,omitempty"`
+	// 被拒绝的原因，或恢复操作的来源版本说明
+	Reason string `json:"reason,omitempty"`
+	// 创建时间
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 // 用户记忆事件类型（兼容别名，新代码请直接用 memoryevent.TypeMilestone/TypeEvent）
 const (
 	// UserMemoryEventTypeMilestone 任务里程碑
@@ -147,12 +218,93 @@ type SessionSummary struct {
 	LastSummarizedMessageID string `json:"lastSummarizedMessageId,omitempty"`
 	// 上次已纳入摘要的最后一条消息时间
 	LastSummarizedMessageAt time.Time `json:"lastSummarizedMessageAt,omitempty"`
+	// 分层摘要模式下，已汇总进 Summary 的分段数（按时间顺序的前 N 个分段）
+	RolledUpChunkCount int `json:"rolledUpChunkCount,omitempty"`
 	// 创建时间
 	CreatedAt time.Time `json:"createdAt"`
 	// 最后更新时间
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// SummaryChunk 分段摘要，覆盖会话中一段连续的消息
+type SummaryChunk struct {
+	// 分段ID
+	ID string `json:"id"`
+	// 会话ID
+	SessionID string `json:"sessionId"`
+	// 用户ID
+	UserID string `json:"userId"`
+	// 分段摘要内容
+	Summary string `json:"summary"`
+	// 覆盖的第一条消息ID
+	StartMessageID string `json:"startMessageId"`
+	// 覆盖的最后一条消息ID
+	EndMessageID string `json:"endMessageId"`
+	// 第一条消息时间
+	StartAt time.Time `json:"startAt"`
+	// 最后一条消息时间
+	EndAt time.Time `json:"endAt"`
+	// 覆盖的消息数量
+	MessageCount int `json:"messageCount"`
+	// 创建时间
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// UserDigest 跨会话的用户周期摘要（如每周摘要）
+type UserDigest struct {
+	// 摘要ID
+	ID string `json:"id"`
+	// 用户ID
+	UserID string `json:"userId"`
+	// 周期开始时间（含）
+	PeriodStart time.Time `json:"periodStart"`
+	// 周期结束时间（不含）
+	PeriodEnd time.Time `json:"periodEnd"`
+	// 摘要内容
+	Digest string `json:"digest"`
+	// 纳入摘要的会话ID
+	SessionIDs []string `json:"sessionIds,omitempty"`
+	// 创建时间
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Session 会话信息
+// 标题与归档状态保存在会话元数据中，消息数与最近活跃时间由会话消息统计得出
+type Session struct {
+	// 会话ID
+	SessionID string `json:"sessionId"`
+	// 用户ID
+	UserID string `json:"userId"`
+	// 会话标题，未命名时为空
+	Title string `json:"title,omitempty"`
+	// 是否已归档
+	Archived bool `json:"archived,omitempty"`
+	// 归档时间
+	ArchivedAt time.Time `json:"archivedAt,omitempty"`
+	// 消息数量
+	MessageCount int `json:"messageCount"`
+	// 最近一条消息的时间
+	LastActiveAt time.Time `json:"lastActiveAt,omitempty"`
+	// 创建时间（元数据创建时间与第一条消息时间中较早的一个）
+	CreatedAt time.Time `json:"createdAt"`
+	// 元数据最后更新时间
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SessionQuery 会话列表查询条件
+type SessionQuery struct {
+	// 用户ID，必填
+	UserID string `json:"userId"`
+	// 是否包含已归档会话，默认只返回未归档会话
+	IncludeArchived bool `json:"includeArchived,omitempty"`
+	// 只返回已归档会话，优先于 IncludeArchived
+	ArchivedOnly bool `json:"archivedOnly,omitempty"`
+	// 返回数量上限，<=0 表示不限制
+	Limit int `json:"limit,omitempty"`
+	// 跳过的数量，用于分页
+	Offset int `json:"offset,omitempty"`
+}
+
 // ConversationMessage 对话消息结构
 // 存储完整的对话历史
 type ConversationMessage struct {
@@ -168,10 +320,37 @@ type ConversationMessage struct {
 	Content string `json:"content,omitempty"`
 	// 多部分内容，支持文本、图片、音频、视频、文件等
 	Parts []schema.MessageInputPart `json:"parts,omitempty"`
+	// 助手消息发起的工具调用，仅在记录工具调用历史时存在
+	ToolCalls []ToolCall `json:"toolCalls,omitempty"`
+	// 工具调用结果，仅 Role 为 tool 的消息存在
+	ToolResult *ToolResult `json:"toolResult,omitempty"`
 	// 创建时间
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// MessageRoleTool 工具结果消息的角色
+const MessageRoleTool = string(schema.Tool)
+
+// ToolCall 助手发起的一次工具调用
+type ToolCall struct {
+	// 调用ID，与 ToolResult.CallID 对应
+	CallID string `json:"callId,omitempty"`
+	// 工具名
+	Name string `json:"name"`
+	// JSON 格式的调用参数
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// ToolResult 一次工具调用的结果
+type ToolResult struct {
+	// 调用ID，与 ToolCall.CallID 对应
+	CallID string `json:"callId,omitempty"`
+	// 工具名
+	Name string `json:"name"`
+	// 结果文本是否被截断，结果文本本身保存在消息的 Content 中
+	Truncated bool `json:"truncated,omitempty"`
+}
+
 // MemoryRetrieval 记忆检索方式
 type MemoryRetrieval string
 
@@ -200,6 +379,8 @@ type MemoryConfig struct {
 	// 常驻注入的最近事件条数，默认 20，仅在 EnableEventSearch=true 时生效。
 	// 设为 0 表示不注入任何事件，全部交给检索工具。
 	RecentEventLimit int `json:"recentEventLimit,omitempty"`
+	// 最近事件注入的排序方式（时间衰减 + 重要程度 + 相关度），nil 时使用默认权重。
+	EventRanking *EventRankingConfig `json:"eventRanking,omitempty"`
 	// 用户记忆检索方式 EnableUserMemories开启采生效
 	Retrieval MemoryRetrieval `json:"retrieval"`
 	// 记忆数量限制
@@ -215,6 +396,9 @@ type MemoryConfig struct {
 	// 异步任务执行超时时间（秒），用于用户记忆分析、会话摘要和索引任务。
 	// 默认120秒；设为0或负数时使用默认值。
 	AsyncTaskTimeoutSeconds int `json:"asyncTaskTimeoutSeconds,omitempty"`
+	// 分析模型名称（如 gpt-4o-mini），记录在用户记忆版本历史的 Model 字段中。
+	// 为空时使用模型组件的类型名（如 OpenAI）。
+	ModelName string `json:"modelName,omitempty"`
 
 	// 摘要触发配置
 	SummaryTrigger SummaryTriggerConfig `json:"summaryTrigger"`
@@ -227,6 +411,29 @@ type MemoryConfig struct {
 
 	// 搜索配置。nil 时按 keyword 默认行为初始化。
 	Search *SearchConfig `json:"search,omitempty"`
+
+	// 用户记忆版本历史与安全检查配置。nil 时使用默认值；存储未实现 UserMemoryHistoryStorage 时只做安全检查。
+	UserMemoryHistory *UserMemoryHistoryConfig `json:"userMemoryHistory,omitempty"`
+
+	// 用户记忆事件整理配置。nil 表示不启用；仅在 EnableEventSearch=true 且存储实现
+	// UserMemoryEventConsolidationStorage 时生效。
+	Consolidation *EventConsolidationConfig `json:"consolidation,omitempty"`
+
+	// 会话标题自动生成配置。nil 表示不启用；仅在存储实现 SessionStorage 时生效。
+	SessionTitle *SessionTitleConfig `json:"sessionTitle,omitempty"`
+
+	// 分层会话摘要配置。nil 表示不启用；仅在 EnableSessionSummary=true 且存储实现 SummaryChunkStorage 时生效。
+	HierarchicalSummary *HierarchicalSummaryConfig `json:"hierarchicalSummary,omitempty"`
+
+	// 跨会话用户周期摘要配置。nil 表示不自动生成；仅在存储实现 UserDigestStorage 与 SessionStorage 时生效。
+	UserDigest *UserDigestConfig `json:"userDigest,omitempty"`
+
+	// 多模态附件外置存储配置。nil 表示附件内联保存在消息中；垃圾回收需存储实现 AttachmentRefStorage。
+	Attachments *AttachmentConfig `json:"attachments,omitempty"`
+
+	// 保留策略：按用户规则清理消息与事件，支持删除前归档与摘要覆盖检查。nil 表示只执行 Cleanup 的固定清理；
+	// 需存储实现 RetentionStorage。
+	Retention *RetentionConfig `json:"retention,omitempty"`
 }
 
 // CleanupConfig 清理相关配置
@@ -262,9 +469,12 @@ func DefaultMemoryConfig() *MemoryConfig {
 		DebounceWindowSeconds:   ptrTo(30),
 		AsyncTaskTimeoutSeconds: 120,
 		SummaryTrigger: SummaryTriggerConfig{
-			Strategy:         TriggerSmart,
-			MessageThreshold: 10,
-			MinInterval:      600, // 600秒最小间隔
+			Strategy:              TriggerSmart,
+			MessageThreshold:      10,
+			MinInterval:           600, // 600秒最小间隔
+			TokenThreshold:        4000,
+			TopicShiftSimilarity:  0.6,
+			MinTopicShiftMessages: 4,
 		},
 		SummaryCache: SummaryCacheConfig{
 			TTLSeconds: int(defaultSessionSummaryCacheTTL / time.Second),
@@ -290,6 +500,16 @@ type SummaryTriggerConfig struct {
 	MessageThreshold int `json:"messageThreshold"`
 	// 最小触发间隔（秒）
 	MinInterval int `json:"minInterval"`
+	// 基于 token 数量触发的阈值：未摘要消息的估算 token 数达到该值时触发（by_tokens 策略）
+	TokenThreshold int `json:"tokenThreshold"`
+	// token 计数函数，为空时按中日韩字符 1 token、其他字符约 4 个 1 token 估算
+	TokenCounter func(text string) int `json:"-"`
+	// 话题切换判定的相似度阈值：新一轮对话与当前话题的余弦相似度低于该值视为切换（topic_shift 策略）
+	TopicShiftSimilarity float64 `json:"topicShiftSimilarity"`
+	// 话题切换使用的向量模型，为空时使用 Search.Embedder
+	TopicShiftEmbedder embedding.Embedder `json:"-"`
+	// 话题切换前至少累积的未摘要消息数，避免为零碎的几条消息生成摘要
+	MinTopicShiftMessages int `json:"minTopicShiftMessages"`
 }
 
 // SummaryTriggerStrategy 摘要触发策略
@@ -304,6 +524,10 @@ const (
 	TriggerByTime SummaryTriggerStrategy = "by_time"
 	// TriggerSmart 智能触发（综合考虑多种因素）
 	TriggerSmart SummaryTriggerStrategy = "smart"
+	// TriggerByTokens 基于未摘要消息的 token 数量触发
+	TriggerByTokens SummaryTriggerStrategy = "by_tokens"
+	// TriggerTopicShift 基于话题切换触发（比较最近一轮对话与当前话题的向量距离）
+	TriggerTopicShift SummaryTriggerStrategy = "topic_shift"
 )
 
 // UserMemoryAnalyzerParam 用户记忆更新参数
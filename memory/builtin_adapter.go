@@ -20,6 +20,25 @@ import (
 // builtinProvider wraps a *builtin.MemoryManager to implement MemoryProvider.
 type builtinProvider struct {
 	*builtin.MemoryManager
+	hooks Hooks
+}
+
+// newBuiltinProvider wraps mgr and forwards its change notifications to the
+// registered hooks.
+func newBuiltinProvider(mgr *builtin.MemoryManager) *builtinProvider {
+	p := &builtinProvider{MemoryManager: mgr}
+	mgr.SetChangeListener(p.onChange)
+	return p
+}
+
+// RegisterHook implements HookableProvider.
+func (p *builtinProvider) RegisterHook(event HookEvent, handler HookHandler) {
+	p.hooks.RegisterHook(event, handler)
+}
+
+// onChange bridges builtin change kinds to hook events; both use the same names.
+func (p *builtinProvider) onChange(ctx context.Context, kind builtin.ChangeKind, payload any) {
+	p.hooks.Notify(ctx, HookEvent(kind), payload)
 }
 
 // Retrieve implements MemoryProvider.
@@ -27,7 +46,11 @@ func (p *builtinProvider) Retrieve(ctx context.Context, req *RetrieveRequest) (*
 	if req == nil {
 		return nil, fmt.Errorf("retrieve request is nil")
 	}
+	return p.hooks.WrapRetrieve(ctx, req, p.retrieve)
+}
 
+func (p *builtinProvider) retrieve(ctx context.Context, req *RetrieveRequest) (*RetrieveResult, error) {
+	ctx = builtin.WithNamespace(ctx, req.Namespace)
 	cfg := p.MemoryManager.GetConfig()
 
 	result := &RetrieveResult{
@@ -45,15 +68,32 @@ func (p *builtinProvider) Retrieve(ctx context.Context, req *RetrieveRequest) (*
 			result.ContextMessages = append(result.ContextMessages, schema.UserAgenticMessage(fmt.Sprintf("<user_memory>\n%s\n</user_memory>", userMemory.Memory)))
 		}
 
-		// 事件检索模式：再追加“最近 N 条事件”块，更早的事件由 search_user_memory 工具按需检索。
+		// 事件检索模式：再追加 N 条事件块，按时间衰减、重要程度和与当前消息的相关度挑选；
+		// 其余事件由 search_user_memory 工具按需检索。
 		if cfg.EnableEventSearch && cfg.RecentEventLimit > 0 {
-			events, evtErr := p.MemoryManager.ListRecentUserMemoryEvents(ctx, req.UserID, cfg.RecentEventLimit)
+			events, evtErr := p.MemoryManager.RankedUserMemoryEvents(ctx, req.UserID, latestUserText(req.Messages), cfg.RecentEventLimit)
 			if evtErr == nil && len(events) > 0 {
 				result.ContextMessages = append(result.ContextMessages, schema.UserAgenticMessage(formatRecentEventsBlock(events)))
 			}
 		}
 	}
 
+	// Shared team / organization memories live in the default namespace and
+	// are read-only here; they are maintained with UpsertUserMemory or the
+	// memory edit methods using the shared ID as the user ID.
+	if shared := p.sharedMemoryBlock(ctx, req.SharedMemoryIDs); shared != "" {
+		result.ContextMessages = append(result.ContextMessages, schema.UserAgenticMessage(shared))
+	}
+
+	// The latest cross-session digest gives a short overview of what the
+	// user has been doing recently.
+	if cfg.UserDigest != nil && cfg.UserDigest.Inject {
+		digest, err := p.MemoryManager.LatestUserDigest(ctx, req.UserID)
+		if err == nil && digest != nil && digest.Digest != "" {
+			result.ContextMessages = append(result.ContextMessages, schema.UserAgenticMessage(formatUserDigestBlock(digest)))
+		}
+	}
+
 	// Fetch session summary as dynamic context.
 	if cfg.EnableSessionSummary {
 		summary, err := p.MemoryManager.GetSessionSummary(ctx, req.SessionID, req.UserID)
@@ -61,6 +101,15 @@ func (p *builtinProvider) Retrieve(ctx context.Context, req *RetrieveRequest) (*
 			sessionSummary = summary
 			result.ContextMessages = append(result.ContextMessages, schema.UserAgenticMessage(fmt.Sprintf("<session_context>\n%s\n</session_context>", summary.Summary)))
 		}
+
+		// With hierarchical summaries, add the chunks not yet rolled up into
+		// the session abstract and the older chunks relevant to this turn.
+		if sessionSummary != nil {
+			chunks, err := p.MemoryManager.RelevantSummaryChunks(ctx, req.SessionID, req.UserID, latestUserText(req.Messages))
+			if err == nil && len(chunks) > 0 {
+				result.ContextMessages = append(result.ContextMessages, schema.UserAgenticMessage(formatSummaryChunksBlock(chunks)))
+			}
+		}
 	}
 
 	// Fetch conversation history
@@ -97,9 +146,29 @@ func (p *builtinProvider) Memorize(ctx context.Context, req *MemorizeRequest) er
 	if req == nil {
 		return fmt.Errorf("memorize request is nil")
 	}
+	return p.hooks.WrapMemorize(ctx, req, p.memorize)
+}
+
+func (p *builtinProvider) memorize(ctx context.Context, req *MemorizeRequest) error {
+	ctx = builtin.WithNamespace(ctx, req.Namespace)
 
+	// Messages are saved in order so that tool calls and their results
+	// (see WithToolCallHistory) land between the user message and the final
+	// assistant reply.
 	for _, msg := range req.Messages {
-		if msg.Role == schema.AgenticRoleTypeUser {
+		if msg == nil {
+			continue
+		}
+		switch {
+		case agmsg.HasFunctionToolResult(msg):
+			if err := p.saveToolResults(ctx, req, msg); err != nil {
+				return err
+			}
+		case agmsg.HasFunctionToolCall(msg):
+			if err := p.saveToolCalls(ctx, req, msg); err != nil {
+				return err
+			}
+		case msg.Role == schema.AgenticRoleTypeUser:
 			content := agmsg.Text(msg)
 			parts := agmsg.InputParts(msg)
 			if content == "" && len(parts) > 0 {
@@ -108,11 +177,7 @@ func (p *builtinProvider) Memorize(ctx context.Context, req *MemorizeRequest) er
 			if err := p.MemoryManager.ProcessUserMessage(ctx, req.UserID, req.SessionID, content, parts); err != nil {
 				return fmt.Errorf("save user message: %w", err)
 			}
-		}
-	}
-
-	for _, msg := range req.Messages {
-		if msg.Role == schema.AgenticRoleTypeAssistant {
+		case msg.Role == schema.AgenticRoleTypeAssistant:
 			content := agmsg.Text(msg)
 			if content == "" {
 				continue
@@ -126,6 +191,61 @@ func (p *builtinProvider) Memorize(ctx context.Context, req *MemorizeRequest) er
 	return nil
 }
 
+// saveToolCalls stores an assistant message that requested tool calls. It
+// only goes to conversation history; tool calls never trigger summaries or
+// user memory extraction.
+func (p *builtinProvider) saveToolCalls(ctx context.Context, req *MemorizeRequest, msg *schema.AgenticMessage) error {
+	stored := &builtin.ConversationMessage{
+		SessionID: req.SessionID,
+		UserID:    req.UserID,
+		Role:      string(schema.Assistant),
+	}
+	var text []string
+	for _, block := range msg.ContentBlocks {
+		switch {
+		case block == nil:
+		case block.AssistantGenText != nil:
+			text = append(text, block.AssistantGenText.Text)
+		case block.FunctionToolCall != nil:
+			stored.ToolCalls = append(stored.ToolCalls, builtin.ToolCall{
+				CallID:    block.FunctionToolCall.CallID,
+				Name:      block.FunctionToolCall.Name,
+				Arguments: block.FunctionToolCall.Arguments,
+			})
+		}
+	}
+	stored.Content = strings.TrimSpace(strings.Join(text, ""))
+	if err := p.MemoryManager.SaveMessage(ctx, stored); err != nil {
+		return fmt.Errorf("save tool call message: %w", err)
+	}
+	return nil
+}
+
+// saveToolResults stores one tool message per function tool result block.
+func (p *builtinProvider) saveToolResults(ctx context.Context, req *MemorizeRequest, msg *schema.AgenticMessage) error {
+	for _, block := range msg.ContentBlocks {
+		if block == nil || block.FunctionToolResult == nil {
+			continue
+		}
+		content := agmsg.Text(&schema.AgenticMessage{ContentBlocks: []*schema.ContentBlock{block}})
+		err := p.MemoryManager.SaveMessage(ctx, &builtin.ConversationMessage{
+			SessionID: req.SessionID,
+			UserID:    req.UserID,
+			Role:      builtin.MessageRoleTool,
+			Content:   content,
+			ToolResult: &builtin.ToolResult{
+				CallID:    block.FunctionToolResult.CallID,
+				Name:      block.FunctionToolResult.Name,
+				Truncated: strings.Contains(content, toolResultTruncatedMarker),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("save tool result message: %w", err)
+		}
+	}
+	return nil
+}
+
 // Close delegates to the underlying MemoryManager.
 func (p *builtinProvider) Close() error {
 	return p.MemoryManager.Close()
@@ -145,11 +265,44 @@ func (p *builtinProvider) ListRecentUserMemoryEvents(ctx context.Context, userID
 	return p.MemoryManager.ListRecentUserMemoryEvents(ctx, userID, limit)
 }
 
+var (
+	_ UserMemoryEditor   = (*builtinProvider)(nil)
+	_ SearchableProvider = (*builtinProvider)(nil)
+	_ HookableProvider   = (*builtinProvider)(nil)
+	_ SessionManager     = (*builtinProvider)(nil)
+)
+
+// sharedMemoryBlock renders the shared memories as one context block.
+func (p *builtinProvider) sharedMemoryBlock(ctx context.Context, ids []string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	ctx = builtin.WithNamespace(ctx, "")
+	var b strings.Builder
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		shared, err := p.MemoryManager.GetUserMemory(ctx, id)
+		if err != nil || shared == nil || strings.TrimSpace(shared.Memory) == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "<shared_memory id=%q>\n%s\n</shared_memory>\n", id, strings.TrimSpace(shared.Memory))
+	}
+	return strings.TrimSpace(b.String())
+}
+
 // formatRecentEventsBlock 把最近事件渲染为上下文块，控制每条字数避免冲爆 prompt。
 func formatRecentEventsBlock(events []*builtin.UserMemoryEvent) string {
 	var b strings.Builder
 	b.WriteString("<user_memory_recent_events>\n")
-	b.WriteString("以下是该用户最近的任务里程碑/事件记录，按 EventDate 倒序。\n")
+	b.WriteString("以下是该用户近期或重要的任务里程碑/事件记录，按 EventDate 倒序。\n")
 	b.WriteString("如需查找更早或更宽范围的事件，请调用 search_user_memory 工具检索。\n\n")
 	for _, evt := range events {
 		if evt == nil {
@@ -171,6 +324,24 @@ func formatRecentEventsBlock(events []*builtin.UserMemoryEvent) string {
 	return b.String()
 }
 
+// formatSummaryChunksBlock 格式化分层摘要中的分段摘要，按时间顺序排列
+func formatSummaryChunksBlock(chunks []*builtin.SummaryChunk) string {
+	var b strings.Builder
+	b.WriteString("<session_chunks>\n")
+	b.WriteString("以下是本会话较早对话的分段摘要，按时间顺序排列，作为 session_context 的细节补充。\n")
+	for _, chunk := range chunks {
+		b.WriteString(fmt.Sprintf("\n### %s ~ %s\n%s\n", chunk.StartAt.Format("2006-01-02 15:04"), chunk.EndAt.Format("2006-01-02 15:04"), chunk.Summary))
+	}
+	b.WriteString("</session_chunks>")
+	return b.String()
+}
+
+// formatUserDigestBlock 格式化用户周期摘要
+func formatUserDigestBlock(digest *builtin.UserDigest) string {
+	return fmt.Sprintf("<user_digest period=\"%s ~ %s\">\n%s\n</user_digest>",
+		digest.PeriodStart.Format("2006-01-02"), digest.PeriodEnd.Format("2006-01-02"), digest.Digest)
+}
+
 // extractTextFromParts 从多部分内容中提取纯文本，拼接为一个字符串
 func extractTextFromParts(parts []schema.MessageInputPart) string {
 	var texts []string
@@ -183,6 +354,7 @@ func extractTextFromParts(parts []schema.MessageInputPart) string {
 }
 
 func decorateHistoryMessages(history []*schema.AgenticMessage) []*schema.AgenticMessage {
+	history = dropIncompleteToolExchanges(history)
 	decorated := make([]*schema.AgenticMessage, 0, len(history))
 	for _, msg := range history {
 		decorated = append(decorated, builtin.PrefixHistoryTimestamp(msg))
@@ -190,6 +362,63 @@ func decorateHistoryMessages(history []*schema.AgenticMessage) []*schema.Agentic
 	return decorated
 }
 
+// dropIncompleteToolExchanges removes tool calls whose results are missing
+// from history and tool results whose call is missing, which happens when
+// the history limit cuts through a tool exchange. Models reject function
+// call / result blocks that are not paired.
+func dropIncompleteToolExchanges(history []*schema.AgenticMessage) []*schema.AgenticMessage {
+	results := make(map[string]struct{})
+	for _, msg := range history {
+		for _, block := range msg.ContentBlocks {
+			if block != nil && block.FunctionToolResult != nil {
+				results[block.FunctionToolResult.CallID] = struct{}{}
+			}
+		}
+	}
+
+	kept := make(map[string]struct{})
+	filtered := make([]*schema.AgenticMessage, 0, len(history))
+	for _, msg := range history {
+		switch {
+		case agmsg.HasFunctionToolCall(msg):
+			complete := true
+			for _, block := range msg.ContentBlocks {
+				if block == nil || block.FunctionToolCall == nil {
+					continue
+				}
+				if _, ok := results[block.FunctionToolCall.CallID]; !ok {
+					complete = false
+					break
+				}
+			}
+			if !complete {
+				continue
+			}
+			for _, block := range msg.ContentBlocks {
+				if block != nil && block.FunctionToolCall != nil {
+					kept[block.FunctionToolCall.CallID] = struct{}{}
+				}
+			}
+		case agmsg.HasFunctionToolResult(msg):
+			complete := true
+			for _, block := range msg.ContentBlocks {
+				if block == nil || block.FunctionToolResult == nil {
+					continue
+				}
+				if _, ok := kept[block.FunctionToolResult.CallID]; !ok {
+					complete = false
+					break
+				}
+			}
+			if !complete {
+				continue
+			}
+		}
+		filtered = append(filtered, msg)
+	}
+	return filtered
+}
+
 func mergeHistoryMessages(limit int, histories ...[]*schema.AgenticMessage) []*schema.AgenticMessage {
 	type item struct {
 		msg     *schema.AgenticMessage
@@ -289,7 +518,7 @@ func init() {
 				return nil, err
 			}
 			mgr.SetAsyncTaskContextBuilder(cfg.AsyncTaskContextBuilder)
-			return &builtinProvider{MemoryManager: mgr}, nil
+			return newBuiltinProvider(mgr), nil
 		},
 	})
 }
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -9,6 +10,7 @@ import (
 	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
 	"github.com/CoolBanHub/aggo/memory/builtin"
 	"github.com/CoolBanHub/aggo/memory/builtin/storage"
+	"github.com/cloudwego/eino/schema"
 )
 
 func TestBuiltinRetrieveKeepsRecentMessagesWithSessionSummary(t *testing.T) {
@@ -81,3 +83,199 @@ func TestBuiltinRetrieveKeepsRecentMessagesWithSessionSummary(t *testing.T) {
 func intPtr(v int) *int {
 	return &v
 }
+
+func TestBuiltinProviderFiresHooks(t *testing.T) {
+	ctx := context.Background()
+	manager, err := builtin.NewMemoryManager(nil, storage.NewMemoryStore(), &builtin.MemoryConfig{
+		AsyncWorkerPoolSize:   1,
+		DebounceWindowSeconds: intPtr(0),
+		SummaryTrigger:        builtin.DefaultMemoryConfig().SummaryTrigger,
+		SummaryCache:          builtin.DefaultMemoryConfig().SummaryCache,
+		Cleanup:               builtin.DefaultMemoryConfig().Cleanup,
+		Search:                builtin.DefaultMemoryConfig().Search,
+	})
+	if err != nil {
+		t.Fatalf("NewMemoryManager: %v", err)
+	}
+	defer manager.Close()
+
+	provider := newBuiltinProvider(manager)
+	var fired []HookEvent
+	record := func(ctx context.Context, event HookEvent, data any) error {
+		fired = append(fired, event)
+		return nil
+	}
+	for _, event := range []HookEvent{HookBeforeRetrieve, HookAfterRetrieve, HookUserMemoryUpdated, HookUserMemoryEventCreated, HookCleanupPerformed} {
+		provider.RegisterHook(event, record)
+	}
+	provider.RegisterHook(HookBeforeMemorize, func(context.Context, HookEvent, any) error {
+		return errors.New("rejected")
+	})
+
+	if _, err := provider.Retrieve(ctx, &RetrieveRequest{UserID: "user-1", SessionID: "session-1"}); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	var changed *builtin.UserMemoryChange
+	provider.RegisterHook(HookUserMemoryUpdated, func(_ context.Context, _ HookEvent, data any) error {
+		changed, _ = data.(*builtin.UserMemoryChange)
+		return nil
+	})
+	if err := provider.UpsertUserMemory(ctx, &builtin.UserMemory{UserID: "user-1", Memory: "# 用户记忆\n- 喜欢咖啡\n"}); err != nil {
+		t.Fatalf("UpsertUserMemory: %v", err)
+	}
+	if err := provider.SaveUserMemoryEvent(ctx, &builtin.UserMemoryEvent{UserID: "user-1", Summary: "完成上线"}); err != nil {
+		t.Fatalf("SaveUserMemoryEvent: %v", err)
+	}
+	if err := provider.ForceCleanupNow(ctx); err != nil {
+		t.Fatalf("ForceCleanupNow: %v", err)
+	}
+
+	want := []HookEvent{HookBeforeRetrieve, HookAfterRetrieve, HookUserMemoryUpdated, HookUserMemoryEventCreated, HookCleanupPerformed}
+	if len(fired) != len(want) {
+		t.Fatalf("fired = %v, want %v", fired, want)
+	}
+	for i := range want {
+		if fired[i] != want[i] {
+			t.Fatalf("fired = %v, want %v", fired, want)
+		}
+	}
+	if changed == nil || changed.Source != builtin.UserMemorySourceManual || changed.Memory == nil {
+		t.Fatalf("user memory change payload = %#v", changed)
+	}
+
+	err = provider.Memorize(ctx, &MemorizeRequest{UserID: "user-1", SessionID: "session-1"})
+	if err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("Memorize error = %v, want hook rejection", err)
+	}
+}
+
+func TestBuiltinRetrieveIsolatesNamespacesAndInjectsSharedMemory(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	cfg := builtin.DefaultMemoryConfig()
+	cfg.EnableSessionSummary = false
+	cfg.DebounceWindowSeconds = intPtr(0)
+	manager, err := builtin.NewMemoryManager(nil, store, cfg)
+	if err != nil {
+		t.Fatalf("NewMemoryManager: %v", err)
+	}
+	defer manager.Close()
+
+	for ns, text := range map[string]string{"": "默认命名空间记忆", "sales": "销售助手记忆", "support": "客服助手记忆"} {
+		if err := manager.UpsertUserMemory(builtin.WithNamespace(ctx, ns), &builtin.UserMemory{UserID: "user-1", Memory: text}); err != nil {
+			t.Fatalf("UpsertUserMemory(%q): %v", ns, err)
+		}
+	}
+	if err := manager.UpsertUserMemory(ctx, &builtin.UserMemory{UserID: "team-1", Memory: "团队约定：周五发布"}); err != nil {
+		t.Fatalf("UpsertUserMemory(team-1): %v", err)
+	}
+
+	provider := &builtinProvider{MemoryManager: manager}
+	result, err := provider.Retrieve(ctx, &RetrieveRequest{
+		UserID:          "user-1",
+		SessionID:       "session-1",
+		Namespace:       "sales",
+		SharedMemoryIDs: []string{"team-1", "team-1", "missing"},
+	})
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+
+	var texts []string
+	for _, msg := range result.ContextMessages {
+		texts = append(texts, agmsg.Text(msg))
+	}
+	joined := strings.Join(texts, "\n")
+	if !strings.Contains(joined, "销售助手记忆") {
+		t.Fatalf("namespaced memory missing: %q", joined)
+	}
+	if strings.Contains(joined, "客服助手记忆") || strings.Contains(joined, "默认命名空间记忆") {
+		t.Fatalf("memory leaked across namespaces: %q", joined)
+	}
+	if strings.Count(joined, `<shared_memory id="team-1">`) != 1 || !strings.Contains(joined, "团队约定：周五发布") {
+		t.Fatalf("shared memory not injected once: %q", joined)
+	}
+	if strings.Contains(joined, "missing") {
+		t.Fatalf("empty shared memory should be skipped: %q", joined)
+	}
+
+	if got, err := manager.GetUserMemory(ctx, "user-1"); err != nil || got == nil || got.Memory != "默认命名空间记忆" {
+		t.Fatalf("default namespace memory = %#v, %v", got, err)
+	}
+}
+
+func TestBuiltinMemorizePersistsToolExchanges(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	cfg := builtin.DefaultMemoryConfig()
+	cfg.EnableUserMemories = false
+	cfg.EnableSessionSummary = false
+	manager, err := builtin.NewMemoryManager(nil, store, cfg)
+	if err != nil {
+		t.Fatalf("NewMemoryManager: %v", err)
+	}
+	defer manager.Close()
+
+	toolCall := &schema.AgenticMessage{
+		Role: schema.AgenticRoleTypeAssistant,
+		ContentBlocks: []*schema.ContentBlock{
+			schema.NewContentBlock(&schema.Reasoning{Text: "需要查天气"}),
+			schema.NewContentBlock(&schema.FunctionToolCall{CallID: "call-1", Name: "weather", Arguments: `{"city":"上海"}`}),
+		},
+	}
+	toolResult := &schema.AgenticMessage{
+		Role: schema.AgenticRoleTypeUser,
+		ContentBlocks: []*schema.ContentBlock{schema.NewContentBlock(&schema.FunctionToolResult{
+			CallID: "call-1",
+			Name:   "weather",
+			Content: []*schema.FunctionToolResultContentBlock{{
+				Type: schema.FunctionToolResultContentBlockTypeText,
+				Text: &schema.UserInputText{Text: strings.Repeat("晴", 50)},
+			}},
+		})},
+	}
+
+	provider := &builtinProvider{MemoryManager: manager}
+	messages := []*schema.AgenticMessage{schema.UserAgenticMessage("上海天气怎么样")}
+	messages = append(messages, compactToolMessages([]*schema.AgenticMessage{toolCall, toolResult}, 10)...)
+	messages = append(messages, agmsg.AssistantMessage("上海今天晴"))
+	if err := provider.Memorize(ctx, &MemorizeRequest{UserID: "user-1", SessionID: "session-1", Messages: messages}); err != nil {
+		t.Fatalf("Memorize: %v", err)
+	}
+
+	stored, err := store.GetMessages(ctx, "session-1", "user-1", 0)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(stored) != 4 {
+		t.Fatalf("stored %d messages, want 4", len(stored))
+	}
+	if got := stored[1].ToolCalls; len(got) != 1 || got[0].Name != "weather" || stored[1].Content != "" {
+		t.Fatalf("tool call message = %#v", stored[1])
+	}
+	if stored[2].Role != builtin.MessageRoleTool || stored[2].ToolResult == nil || !stored[2].ToolResult.Truncated {
+		t.Fatalf("tool result message = %#v", stored[2])
+	}
+
+	// A history limit that cuts off the tool call must also drop its result.
+	result, err := provider.Retrieve(ctx, &RetrieveRequest{UserID: "user-1", SessionID: "session-1", Limit: 2})
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if len(result.HistoryMessages) != 1 || agmsg.HasFunctionToolResult(result.HistoryMessages[0]) {
+		t.Fatalf("orphan tool result kept in history: %d messages", len(result.HistoryMessages))
+	}
+
+	result, err = provider.Retrieve(ctx, &RetrieveRequest{UserID: "user-1", SessionID: "session-1"})
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	history := result.HistoryMessages
+	if len(history) != 4 || !agmsg.HasFunctionToolCall(history[1]) || !agmsg.HasFunctionToolResult(history[2]) {
+		t.Fatalf("history does not replay the tool exchange: %d messages", len(history))
+	}
+	resultText := agmsg.Text(history[2])
+	if !strings.HasPrefix(resultText, strings.Repeat("晴", 10)+toolResultTruncatedMarker) || !strings.Contains(resultText, "50 chars total") {
+		t.Fatalf("tool result text = %q", resultText)
+	}
+}
@@ -0,0 +1,53 @@
+package memory
+
+import "reflect"
+
+// CapabilityProvider is implemented by providers that wrap other providers,
+// e.g. the composite provider. Such wrappers cannot satisfy every optional
+// interface (UserMemoryEditor, SessionManager, ...) through type assertions
+// without a hand-written type per combination, so they answer capability
+// lookups instead.
+type CapabilityProvider interface {
+	// Capability reports whether the provider can serve the optional interface
+	// that target points to, and if so stores the implementation in *target,
+	// in the manner of errors.As. target must be a non-nil pointer to an
+	// interface type.
+	Capability(target any) bool
+}
+
+// As returns the optional capability T of provider, e.g.
+// memory.As[memory.UserMemoryEditor](provider). It uses provider itself when
+// it implements T and otherwise asks a CapabilityProvider, so capabilities
+// survive wrapping. Prefer it over a plain type assertion.
+func As[T any](provider any) (T, bool) {
+	if c, ok := provider.(T); ok {
+		return c, true
+	}
+	var target T
+	if cp, ok := provider.(CapabilityProvider); ok && cp.Capability(&target) {
+		return target, true
+	}
+	return target, false
+}
+
+// AssignCapability stores candidate in target when candidate implements the
+// interface target points to, or when candidate is itself a
+// CapabilityProvider that can serve it. It is a helper for CapabilityProvider
+// implementations.
+func AssignCapability(candidate, target any) bool {
+	if candidate == nil {
+		return false
+	}
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() || ptr.Elem().Kind() != reflect.Interface {
+		return false
+	}
+	if value := reflect.ValueOf(candidate); value.Type().Implements(ptr.Elem().Type()) {
+		ptr.Elem().Set(value)
+		return true
+	}
+	if cp, ok := candidate.(CapabilityProvider); ok {
+		return cp.Capability(target)
+	}
+	return false
+}
@@ -0,0 +1,459 @@
+// Package composite provides a memory.MemoryProvider that fans out to several
+// child providers, e.g. builtin for conversation history plus mem0 for semantic
+// facts.
+package composite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	"github.com/CoolBanHub/aggo/memory"
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	defaultRetrieveTimeout = 10 * time.Second
+	defaultMemorizeTimeout = 2 * time.Minute
+
+	// MetadataErrorsKey holds a map of member name to Retrieve error message.
+	MetadataErrorsKey = "composite_errors"
+)
+
+// HistoryStrategy controls how HistoryMessages from several members are combined.
+type HistoryStrategy string
+
+const (
+	// HistoryFirst uses the history of the highest-priority member that returned any.
+	HistoryFirst HistoryStrategy = "first"
+	// HistoryMerge merges the histories of all members, dropping duplicates and
+	// ordering messages by creation time. A message without one is ordered as if
+	// created with the nearest preceding message from the same member.
+	HistoryMerge HistoryStrategy = "merge"
+)
+
+// MemberConfig describes one child provider.
+type MemberConfig struct {
+	// Name identifies the member in logs and result metadata. Defaults to the
+	// plugin ID or "member-<index>".
+	Name string
+
+	// Provider is an already constructed child provider. When nil, the provider
+	// is created from PluginID and Config through the registry.
+	Provider memory.MemoryProvider
+
+	// PluginID is the registry plugin used to create the provider, e.g. "builtin" or "mem0".
+	PluginID string
+
+	// Config is passed to the plugin factory, e.g. *builtin.ProviderConfig.
+	Config any
+
+	// Priority orders merged results: higher priority members come first and win
+	// deduplication. Members with equal priority keep their configuration order.
+	Priority int
+
+	// Timeout bounds Retrieve for this member. Zero uses ProviderConfig.RetrieveTimeout.
+	Timeout time.Duration
+
+	// Required makes a Retrieve failure of this member fail the whole Retrieve.
+	// Failures of optional members are logged and skipped.
+	Required bool
+
+	// SkipMemorize excludes the member from Memorize fan-out, e.g. for read-only
+	// knowledge providers.
+	SkipMemorize bool
+}
+
+// ProviderConfig configures a composite provider.
+type ProviderConfig struct {
+	// Members are the child providers. At least one is required.
+	Members []MemberConfig
+
+	// Registry creates members declared by PluginID. Defaults to memory.GlobalRegistry().
+	Registry *memory.Registry
+
+	// RetrieveTimeout is the default per-member Retrieve timeout. Defaults to 10s.
+	RetrieveTimeout time.Duration
+
+	// MemorizeTimeout bounds each member's Memorize call. Defaults to 2m.
+	MemorizeTimeout time.Duration
+
+	// History selects how HistoryMessages are combined. Defaults to HistoryFirst,
+	// since two providers replaying the same conversation would duplicate turns.
+	History HistoryStrategy
+}
+
+type member struct {
+	name         string
+	provider     memory.MemoryProvider
+	priority     int
+	timeout      time.Duration
+	required     bool
+	skipMemorize bool
+}
+
+// Provider implements memory.MemoryProvider by fanning out to its members.
+// Optional capabilities of the members (memory.SearchableProvider,
+// memory.UserMemoryEditor, memory.SessionManager, ...) are exposed through
+// memory.CapabilityProvider; look them up with memory.As.
+type Provider struct {
+	members         []*member
+	memorizeTimeout time.Duration
+	history         HistoryStrategy
+	hooks           memory.Hooks
+}
+
+var (
+	_ memory.MemoryProvider     = (*Provider)(nil)
+	_ memory.HookableProvider   = (*Provider)(nil)
+	_ memory.CapabilityProvider = (*Provider)(nil)
+)
+
+// NewProvider creates a composite provider. Optional capabilities are served
+// by the highest-priority member that supports them, e.g.
+// memory.As[memory.UserMemoryEditor](provider).
+func NewProvider(config *ProviderConfig) (memory.MemoryProvider, error) {
+	return newProvider(config)
+}
+
+func newProvider(config *ProviderConfig) (*Provider, error) {
+	if config == nil || len(config.Members) == 0 {
+		return nil, errors.New("composite: at least one member is required")
+	}
+	registry := config.Registry
+	if registry == nil {
+		registry = memory.GlobalRegistry()
+	}
+	retrieveTimeout := config.RetrieveTimeout
+	if retrieveTimeout <= 0 {
+		retrieveTimeout = defaultRetrieveTimeout
+	}
+
+	p := &Provider{
+		memorizeTimeout: config.MemorizeTimeout,
+		history:         config.History,
+	}
+	if p.memorizeTimeout <= 0 {
+		p.memorizeTimeout = defaultMemorizeTimeout
+	}
+	if p.history == "" {
+		p.history = HistoryFirst
+	}
+	if p.history != HistoryFirst && p.history != HistoryMerge {
+		return nil, fmt.Errorf("composite: unsupported history strategy %q", p.history)
+	}
+
+	names := make(map[string]struct{}, len(config.Members))
+	for i, cfg := range config.Members {
+		m := &member{
+			name:         cfg.Name,
+			provider:     cfg.Provider,
+			priority:     cfg.Priority,
+			timeout:      cfg.Timeout,
+			required:     cfg.Required,
+			skipMemorize: cfg.SkipMemorize,
+		}
+		if m.name == "" {
+			m.name = cfg.PluginID
+		}
+		if m.name == "" {
+			m.name = fmt.Sprintf("member-%d", i)
+		}
+		if _, dup := names[m.name]; dup {
+			m.name = fmt.Sprintf("%s-%d", m.name, i)
+		}
+		names[m.name] = struct{}{}
+		if m.timeout <= 0 {
+			m.timeout = retrieveTimeout
+		}
+
+		if m.provider == nil {
+			if cfg.PluginID == "" {
+				_ = p.Close()
+				return nil, fmt.Errorf("composite: member %q needs either Provider or PluginID", m.name)
+			}
+			provider, err := registry.CreateProvider(cfg.PluginID, cfg.Config)
+			if err != nil {
+				_ = p.Close()
+				return nil, fmt.Errorf("composite: create member %q: %w", m.name, err)
+			}
+			m.provider = provider
+		}
+		p.members = append(p.members, m)
+	}
+
+	sort.SliceStable(p.members, func(i, j int) bool {
+		return p.members[i].priority > p.members[j].priority
+	})
+	return p, nil
+}
+
+type retrieveOutcome struct {
+	result *memory.RetrieveResult
+	err    error
+}
+
+// Retrieve calls every member concurrently, each bounded by its own timeout,
+// and merges the results in priority order.
+func (p *Provider) Retrieve(ctx context.Context, req *memory.RetrieveRequest) (*memory.RetrieveResult, error) {
+	if req == nil {
+		return nil, fmt.Errorf("retrieve request is nil")
+	}
+	return p.hooks.WrapRetrieve(ctx, req, p.retrieve)
+}
+
+func (p *Provider) retrieve(ctx context.Context, req *memory.RetrieveRequest) (*memory.RetrieveResult, error) {
+
+	outcomes := make([]retrieveOutcome, len(p.members))
+	var wg sync.WaitGroup
+	for i, m := range p.members {
+		wg.Add(1)
+		go func(i int, m *member) {
+			defer wg.Done()
+			outcomes[i] = m.retrieve(ctx, req)
+		}(i, m)
+	}
+	wg.Wait()
+
+	merged := &memory.RetrieveResult{Metadata: make(map[string]any)}
+	contextSeen := make(map[string]struct{})
+	systemSeen := make(map[string]struct{})
+	var histories [][]*schema.AgenticMessage
+	failures := make(map[string]string)
+	for i, m := range p.members {
+		outcome := outcomes[i]
+		if outcome.err != nil {
+			if m.required {
+				return nil, fmt.Errorf("composite: retrieve from %q: %w", m.name, outcome.err)
+			}
+			log.Printf("composite: Retrieve from %q failed: %v", m.name, outcome.err)
+			failures[m.name] = outcome.err.Error()
+			continue
+		}
+		res := outcome.result
+		if res == nil {
+			continue
+		}
+		merged.ContextMessages = appendUnique(merged.ContextMessages, contextSeen, res.ContextMessages)
+		merged.SystemMessages = appendUnique(merged.SystemMessages, systemSeen, res.SystemMessages)
+		if len(res.HistoryMessages) > 0 {
+			histories = append(histories, res.HistoryMessages)
+		}
+		if len(res.Metadata) > 0 {
+			merged.Metadata[m.name] = res.Metadata
+		}
+	}
+	if len(failures) > 0 {
+		merged.Metadata[MetadataErrorsKey] = failures
+	}
+	merged.HistoryMessages = p.mergeHistory(histories)
+	return merged, nil
+}
+
+func (m *member) retrieve(ctx context.Context, req *memory.RetrieveRequest) retrieveOutcome {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	// The member may ignore ctx; never let it hold up the other members.
+	done := make(chan retrieveOutcome, 1)
+	go func() {
+		res, err := m.provider.Retrieve(ctx, req)
+		done <- retrieveOutcome{result: res, err: err}
+	}()
+	select {
+	case outcome := <-done:
+		return outcome
+	case <-ctx.Done():
+		return retrieveOutcome{err: ctx.Err()}
+	}
+}
+
+func (p *Provider) mergeHistory(histories [][]*schema.AgenticMessage) []*schema.AgenticMessage {
+	if len(histories) == 0 {
+		return nil
+	}
+	if p.history == HistoryFirst {
+		return histories[0]
+	}
+
+	// Every message gets a comparable sort key: its own timestamp, or else the
+	// timestamp of the nearest preceding message from the same member, then
+	// the member's priority order, then its position in that member's history.
+	type entry struct {
+		msg    *schema.AgenticMessage
+		at     time.Time
+		member int
+		index  int
+	}
+	seen := make(map[string]struct{})
+	var entries []entry
+	for member, history := range histories {
+		var last time.Time
+		for index, msg := range appendUnique(nil, seen, history) {
+			if ts, ok := messageCreatedAt(msg); ok {
+				last = ts
+			}
+			entries = append(entries, entry{msg: msg, at: last, member: member, index: index})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.at.Equal(b.at) {
+			return a.at.Before(b.at)
+		}
+		if a.member != b.member {
+			return a.member < b.member
+		}
+		return a.index < b.index
+	})
+	merged := make([]*schema.AgenticMessage, 0, len(entries))
+	for _, e := range entries {
+		merged = append(merged, e.msg)
+	}
+	return merged
+}
+
+// Memorize fans out to every member that accepts writes. All members are
+// attempted; their errors are joined.
+func (p *Provider) Memorize(ctx context.Context, req *memory.MemorizeRequest) error {
+	if req == nil {
+		return fmt.Errorf("memorize request is nil")
+	}
+	return p.hooks.WrapMemorize(ctx, req, p.memorize)
+}
+
+func (p *Provider) memorize(ctx context.Context, req *memory.MemorizeRequest) error {
+
+	errs := make([]error, len(p.members))
+	var wg sync.WaitGroup
+	for i, m := range p.members {
+		if m.skipMemorize {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, m *member) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, p.memorizeTimeout)
+			defer cancel()
+			if err := m.provider.Memorize(ctx, req); err != nil {
+				errs[i] = fmt.Errorf("composite: memorize to %q: %w", m.name, err)
+			}
+		}(i, m)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// RegisterHook registers a hook. Lifecycle events fire once around the
+// composite Retrieve/Memorize; change events are registered on every member
+// that implements memory.HookableProvider, since only members own the data.
+func (p *Provider) RegisterHook(event memory.HookEvent, handler memory.HookHandler) {
+	if memory.IsLifecycleHookEvent(event) {
+		p.hooks.RegisterHook(event, handler)
+		return
+	}
+	for _, m := range p.members {
+		if hookable, ok := m.provider.(memory.HookableProvider); ok {
+			hookable.RegisterHook(event, handler)
+		}
+	}
+}
+
+// Close closes every member, including the ones passed in as instances.
+func (p *Provider) Close() error {
+	var errs []error
+	for _, m := range p.members {
+		if err := m.provider.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("composite: close %q: %w", m.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Capability implements memory.CapabilityProvider: target receives the
+// highest-priority member that implements the requested interface.
+func (p *Provider) Capability(target any) bool {
+	for _, m := range p.members {
+		if memory.AssignCapability(m.provider, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendUnique appends messages whose key has not been seen yet.
+func appendUnique(dst []*schema.AgenticMessage, seen map[string]struct{}, src []*schema.AgenticMessage) []*schema.AgenticMessage {
+	for _, msg := range src {
+		if msg == nil {
+			continue
+		}
+		key := messageKey(msg)
+		if key != "" {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+		}
+		dst = append(dst, msg)
+	}
+	return dst
+}
+
+// messageKey identifies a message by its stored ID when present, otherwise by
+// role and normalized text.
+func messageKey(msg *schema.AgenticMessage) string {
+	if id := extraString(msg, builtin.MessageExtraIDKey); id != "" {
+		return "id:" + id
+	}
+	text := strings.Join(strings.Fields(agmsg.Text(msg)), " ")
+	if text == "" {
+		return ""
+	}
+	return string(msg.Role) + ":" + text
+}
+
+func messageCreatedAt(msg *schema.AgenticMessage) (time.Time, bool) {
+	raw := extraString(msg, builtin.MessageExtraCreatedAtKey)
+	if raw == "" {
+		return time.Time{}, false
+	}
+	ts, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
+
+func extraString(msg *schema.AgenticMessage, key string) string {
+	if msg == nil || msg.Extra == nil {
+		return ""
+	}
+	value, ok := msg.Extra[key]
+	if !ok || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+func init() {
+	memory.MustRegisterPlugin(&memory.Plugin{
+		ID: "composite",
+		Factory: func(config any) (memory.MemoryProvider, error) {
+			cfg, ok := config.(*ProviderConfig)
+			if !ok {
+				return nil, fmt.Errorf("composite: expected *ProviderConfig, got %T", config)
+			}
+			return NewProvider(cfg)
+		},
+	})
+}
@@ -0,0 +1,231 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	"github.com/CoolBanHub/aggo/memory"
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/builtin/search"
+	"github.com/CoolBanHub/aggo/memory/memoryevent"
+	"github.com/cloudwego/eino/schema"
+)
+
+type fakeProvider struct {
+	result *memory.RetrieveResult
+	err    error
+	delay  time.Duration
+
+	mu        sync.Mutex
+	memorized int
+	closed    bool
+}
+
+func (p *fakeProvider) Retrieve(ctx context.Context, _ *memory.RetrieveRequest) (*memory.RetrieveResult, error) {
+	if p.delay > 0 {
+		time.Sleep(p.delay)
+	}
+	return p.result, p.err
+}
+
+func (p *fakeProvider) Memorize(context.Context, *memory.MemorizeRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.memorized++
+	return p.err
+}
+
+func (p *fakeProvider) Close() error {
+	p.closed = true
+	return nil
+}
+
+type fakeSearchProvider struct {
+	fakeProvider
+}
+
+func (p *fakeSearchProvider) SearchMessages(context.Context, *search.SearchQuery) ([]*search.SearchHit, error) {
+	return []*search.SearchHit{{Snippet: "hit"}}, nil
+}
+
+func (p *fakeSearchProvider) SearchUserMemoryEvents(context.Context, *memoryevent.Query) ([]*memoryevent.Event, error) {
+	return nil, nil
+}
+
+func (p *fakeSearchProvider) ListRecentUserMemoryEvents(context.Context, string, int) ([]*memoryevent.Event, error) {
+	return nil, nil
+}
+
+type fakeEditorProvider struct {
+	fakeProvider
+	memory.UserMemoryEditor
+}
+
+func TestRetrieveMergesByPriority(t *testing.T) {
+	history := []*schema.AgenticMessage{schema.UserAgenticMessage("hi")}
+	low := &fakeProvider{result: &memory.RetrieveResult{
+		ContextMessages: []*schema.AgenticMessage{schema.UserAgenticMessage("facts"), schema.UserAgenticMessage("shared")},
+		HistoryMessages: []*schema.AgenticMessage{schema.UserAgenticMessage("other history")},
+		Metadata:        map[string]any{"count": 1},
+	}}
+	high := &fakeProvider{result: &memory.RetrieveResult{
+		ContextMessages: []*schema.AgenticMessage{schema.UserAgenticMessage("  shared ")},
+		HistoryMessages: history,
+	}}
+	slow := &fakeProvider{delay: 300 * time.Millisecond, result: &memory.RetrieveResult{
+		ContextMessages: []*schema.AgenticMessage{schema.UserAgenticMessage("late")},
+	}}
+
+	provider, err := NewProvider(&ProviderConfig{Members: []MemberConfig{
+		{Name: "mem0", Provider: low},
+		{Name: "builtin", Provider: high, Priority: 10},
+		{Name: "slow", Provider: slow, Timeout: 20 * time.Millisecond},
+	}})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+
+	start := time.Now()
+	res, err := provider.Retrieve(context.Background(), &memory.RetrieveRequest{UserID: "u1", SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if time.Since(start) > 200*time.Millisecond {
+		t.Fatal("slow member should not block Retrieve past its timeout")
+	}
+
+	var texts []string
+	for _, msg := range res.ContextMessages {
+		texts = append(texts, agmsg.Text(msg))
+	}
+	if len(texts) != 2 || texts[0] != "  shared " || texts[1] != "facts" {
+		t.Fatalf("unexpected context messages: %q", texts)
+	}
+	if len(res.HistoryMessages) != 1 || res.HistoryMessages[0] != history[0] {
+		t.Fatalf("history should come from the highest-priority member: %+v", res.HistoryMessages)
+	}
+	if res.Metadata["mem0"] == nil {
+		t.Fatalf("member metadata missing: %+v", res.Metadata)
+	}
+	if failures, _ := res.Metadata[MetadataErrorsKey].(map[string]string); failures["slow"] == "" {
+		t.Fatalf("timeout should be reported: %+v", res.Metadata)
+	}
+}
+
+func TestRequiredMemberFailureAndMemorizeFanOut(t *testing.T) {
+	ok := &fakeProvider{}
+	readOnly := &fakeProvider{}
+	broken := &fakeProvider{err: errors.New("boom")}
+
+	provider, err := NewProvider(&ProviderConfig{Members: []MemberConfig{
+		{Name: "ok", Provider: ok},
+		{Name: "readonly", Provider: readOnly, SkipMemorize: true},
+		{Name: "broken", Provider: broken, Required: true},
+	}})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	if _, err := provider.Retrieve(context.Background(), &memory.RetrieveRequest{}); err == nil {
+		t.Fatal("required member failure should fail Retrieve")
+	}
+
+	err = provider.Memorize(context.Background(), &memory.MemorizeRequest{UserID: "u1"})
+	if err == nil || ok.memorized != 1 || readOnly.memorized != 0 || broken.memorized != 1 {
+		t.Fatalf("unexpected fan-out err=%v ok=%d readonly=%d broken=%d", err, ok.memorized, readOnly.memorized, broken.memorized)
+	}
+
+	if err := provider.Close(); err != nil || !ok.closed || !broken.closed {
+		t.Fatalf("Close should close all members: %v", err)
+	}
+}
+
+func TestCapabilitiesAndRegistry(t *testing.T) {
+	registry := memory.NewRegistry()
+	registry.MustRegister(&memory.Plugin{ID: "search", Factory: func(config any) (memory.MemoryProvider, error) {
+		return &fakeSearchProvider{}, nil
+	}})
+	registry.MustRegister(&memory.Plugin{ID: "plain", Factory: func(config any) (memory.MemoryProvider, error) {
+		return &fakeProvider{}, nil
+	}})
+
+	plain, err := NewProvider(&ProviderConfig{Registry: registry, Members: []MemberConfig{{PluginID: "plain"}}})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	if _, ok := memory.As[memory.SearchableProvider](plain); ok {
+		t.Fatal("composite without searchable members should not expose SearchableProvider")
+	}
+	if _, ok := memory.As[memory.UserMemoryEventSearcher](plain); ok {
+		t.Fatal("composite without event members should not expose UserMemoryEventSearcher")
+	}
+
+	both, err := NewProvider(&ProviderConfig{Registry: registry, Members: []MemberConfig{{PluginID: "plain"}, {PluginID: "search"}}})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	searchable, ok := memory.As[memory.SearchableProvider](both)
+	if !ok {
+		t.Fatal("composite should expose SearchableProvider")
+	}
+	if hits, err := searchable.SearchMessages(context.Background(), &search.SearchQuery{}); err != nil || len(hits) != 1 {
+		t.Fatalf("SearchMessages hits=%d err=%v", len(hits), err)
+	}
+	if _, ok := memory.As[memory.UserMemoryEventSearcher](both); !ok {
+		t.Fatal("composite should expose UserMemoryEventSearcher")
+	}
+
+	// 任意可选能力都按成员优先级查找，嵌套的 composite 同样透传
+	editor := &fakeEditorProvider{}
+	nestedSearch, err := NewProvider(&ProviderConfig{Members: []MemberConfig{{Provider: both}, {Provider: editor}}})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	if got, ok := memory.As[memory.UserMemoryEditor](nestedSearch); !ok || got != memory.UserMemoryEditor(editor) {
+		t.Fatalf("composite should expose the member's UserMemoryEditor, got %v", got)
+	}
+	if _, ok := memory.As[memory.SearchableProvider](nestedSearch); !ok {
+		t.Fatal("nested composite should expose SearchableProvider")
+	}
+	if _, ok := memory.As[memory.SessionManager](nestedSearch); ok {
+		t.Fatal("composite without session members should not expose SessionManager")
+	}
+
+	if _, err := NewProvider(&ProviderConfig{Registry: registry, Members: []MemberConfig{{PluginID: "missing"}}}); err == nil {
+		t.Fatal("unknown plugin should fail")
+	}
+
+	nested, err := memory.GlobalRegistry().CreateProvider("composite", &ProviderConfig{Registry: registry, Members: []MemberConfig{{PluginID: "plain"}}})
+	if err != nil || nested == nil {
+		t.Fatalf("global registry create err=%v", err)
+	}
+}
+
+func TestMergeHistoryOrdersUntimestampedMessages(t *testing.T) {
+	at := func(text string, ts time.Time) *schema.AgenticMessage {
+		msg := schema.UserAgenticMessage(text)
+		msg.Extra = map[string]any{builtin.MessageExtraCreatedAtKey: ts.Format(time.RFC3339)}
+		return msg
+	}
+	base := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	histories := [][]*schema.AgenticMessage{
+		{at("a1", base), schema.UserAgenticMessage("a1 tool"), at("a2", base.Add(2*time.Minute))},
+		{schema.UserAgenticMessage("b0"), at("b1", base.Add(time.Minute)), schema.UserAgenticMessage("b1 tool"), at("b2", base.Add(2*time.Minute))},
+	}
+
+	p := &Provider{history: HistoryMerge}
+	var texts []string
+	for _, msg := range p.mergeHistory(histories) {
+		texts = append(texts, agmsg.Text(msg))
+	}
+	// Untimestamped messages stay after their member's preceding message; ties
+	// fall back to member order, then position.
+	want := []string{"b0", "a1", "a1 tool", "b1", "b1 tool", "a2", "b2"}
+	if strings.Join(texts, ",") != strings.Join(want, ",") {
+		t.Fatalf("merged history = %q, want %q", texts, want)
+	}
+}
@@ -0,0 +1,529 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	"github.com/CoolBanHub/aggo/memory"
+	"github.com/cloudwego/eino/schema"
+)
+
+var (
+	_ memory.MemoryProvider   = (*Provider)(nil)
+	_ memory.HookableProvider = (*Provider)(nil)
+)
+
+// Provider implements memory.MemoryProvider with a temporal knowledge graph.
+// Memorize extracts entities and relations from each turn with a chat model;
+// Retrieve injects the subgraph around the entities mentioned in the latest
+// user message, plus the user's own relations.
+type Provider struct {
+	store     Store
+	extractor *Extractor
+	config    *ProviderConfig
+	hooks     memory.Hooks
+	now       func() time.Time
+}
+
+// NewProvider creates a knowledge-graph provider with normalized config defaults.
+func NewProvider(config *ProviderConfig) (*Provider, error) {
+	if config == nil {
+		return nil, fmt.Errorf("graph: config is required")
+	}
+	if config.Model == nil {
+		return nil, fmt.Errorf("graph: Model is required")
+	}
+	if config.Store == nil {
+		return nil, fmt.Errorf("graph: Store is required")
+	}
+
+	cfg := *config
+	if cfg.MaxHops <= 0 {
+		cfg.MaxHops = defaultMaxHops
+	}
+	if cfg.MaxRelations <= 0 {
+		cfg.MaxRelations = defaultMaxRelations
+	}
+	if cfg.MaxKnownEntities <= 0 {
+		cfg.MaxKnownEntities = defaultMaxKnown
+	}
+
+	return &Provider{
+		store:     cfg.Store,
+		extractor: NewExtractor(cfg.Model, cfg.ExtractionPrompt),
+		config:    &cfg,
+		now:       time.Now,
+	}, nil
+}
+
+// Retrieve injects the relevant subgraph as a <knowledge_graph> context block.
+// Shared memory (req.SharedMemoryIDs) is not supported by this provider.
+func (p *Provider) Retrieve(ctx context.Context, req *memory.RetrieveRequest) (*memory.RetrieveResult, error) {
+	return p.hooks.WrapRetrieve(ctx, req, p.retrieve)
+}
+
+func (p *Provider) retrieve(ctx context.Context, req *memory.RetrieveRequest) (*memory.RetrieveResult, error) {
+	text := latestUserText(req.Messages)
+	entities, err := p.store.ListEntities(ctx, req.UserID, req.Namespace)
+	if err != nil {
+		log.Printf("graph: Retrieve failed: %v", err)
+		return &memory.RetrieveResult{}, nil
+	}
+	if len(entities) == 0 {
+		return &memory.RetrieveResult{}, nil
+	}
+
+	mentioned := mentionedEntities(entities, text)
+	seeds := entityIDs(mentioned)
+	if user := findEntity(entities, UserEntityName); user != nil {
+		seeds = append(seeds, user.ID)
+	}
+	subgraph, err := p.subgraph(ctx, req.UserID, req.Namespace, entities, seeds, entityIDs(mentioned))
+	if err != nil {
+		log.Printf("graph: Retrieve failed: %v", err)
+		return &memory.RetrieveResult{}, nil
+	}
+
+	result := &memory.RetrieveResult{
+		Metadata: map[string]any{
+			"mentioned_entities": len(mentioned),
+			"retrieved_count":    len(subgraph.Relations),
+		},
+	}
+	if block := FormatSubgraph(subgraph); block != "" {
+		result.ContextMessages = []*schema.AgenticMessage{schema.UserAgenticMessage(block)}
+	}
+	return result, nil
+}
+
+// Subgraph returns the relations within MaxHops of the named entities
+// (matched case-insensitively), capped at MaxRelations.
+func (p *Provider) Subgraph(ctx context.Context, userID, namespace string, names ...string) (*Subgraph, error) {
+	entities, err := p.store.ListEntities(ctx, userID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var seeds []string
+	for _, name := range names {
+		if entity := findEntity(entities, name); entity != nil {
+			seeds = append(seeds, entity.ID)
+		}
+	}
+	return p.subgraph(ctx, userID, namespace, entities, seeds, seeds)
+}
+
+// DeleteGraph removes the user's whole graph in a namespace.
+func (p *Provider) DeleteGraph(ctx context.Context, userID, namespace string) error {
+	return p.store.DeleteGraph(ctx, userID, namespace)
+}
+
+// subgraph expands from seeds hop by hop. Relations touching a preferred
+// entity (one the user mentioned) rank before the rest, current facts before
+// ended ones, newer before older.
+func (p *Provider) subgraph(ctx context.Context, userID, namespace string, entities []*Entity, seeds, preferred []string) (*Subgraph, error) {
+	byID := make(map[string]*Entity, len(entities))
+	for _, entity := range entities {
+		byID[entity.ID] = entity
+	}
+
+	visited := make(map[string]struct{}, len(seeds))
+	seen := make(map[string]struct{})
+	var relations []*Relation
+	frontier := seeds
+	for hop := 0; hop < p.config.MaxHops && len(frontier) > 0; hop++ {
+		for _, id := range frontier {
+			visited[id] = struct{}{}
+		}
+		found, err := p.store.ListRelations(ctx, &RelationQuery{
+			UserID:       userID,
+			Namespace:    namespace,
+			EntityIDs:    frontier,
+			IncludeEnded: p.config.IncludeEnded,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var next []string
+		for _, relation := range found {
+			if _, ok := seen[relation.ID]; ok {
+				continue
+			}
+			seen[relation.ID] = struct{}{}
+			relations = append(relations, relation)
+			for _, id := range []string{relation.SubjectID, relation.ObjectID, relation.ContextID} {
+				if _, ok := visited[id]; !ok && id != "" {
+					visited[id] = struct{}{}
+					next = append(next, id)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	preferredSet := make(map[string]struct{}, len(preferred))
+	for _, id := range preferred {
+		preferredSet[id] = struct{}{}
+	}
+	sort.SliceStable(relations, func(i, j int) bool {
+		pi, pj := touchesAny(relations[i], preferredSet), touchesAny(relations[j], preferredSet)
+		if pi != pj {
+			return pi
+		}
+		if relations[i].Current() != relations[j].Current() {
+			return relations[i].Current()
+		}
+		return relations[i].ValidFrom.After(relations[j].ValidFrom)
+	})
+	if len(relations) > p.config.MaxRelations {
+		relations = relations[:p.config.MaxRelations]
+	}
+
+	subgraph := &Subgraph{Entities: make(map[string]*Entity), Relations: relations}
+	for _, relation := range relations {
+		for _, id := range []string{relation.SubjectID, relation.ObjectID, relation.ContextID} {
+			if entity, ok := byID[id]; ok {
+				subgraph.Entities[id] = entity
+			}
+		}
+	}
+	return subgraph, nil
+}
+
+// Memorize extracts entities and relations from the turn and applies them to
+// the graph. Tool calls and results are ignored.
+func (p *Provider) Memorize(ctx context.Context, req *memory.MemorizeRequest) error {
+	return p.hooks.WrapMemorize(ctx, req, p.memorize)
+}
+
+func (p *Provider) memorize(ctx context.Context, req *memory.MemorizeRequest) error {
+	turn := turnText(req.Messages)
+	if turn == "" {
+		return nil
+	}
+
+	entities, err := p.store.ListEntities(ctx, req.UserID, req.Namespace)
+	if err != nil {
+		return err
+	}
+	known := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		known = append(known, entity.Name)
+	}
+	if len(known) > p.config.MaxKnownEntities {
+		known = known[len(known)-p.config.MaxKnownEntities:]
+	}
+
+	// Current facts about the entities in this turn let the model end or
+	// replace them instead of adding contradicting ones.
+	var facts []string
+	seeds := entityIDs(mentionedEntities(entities, turn))
+	if user := findEntity(entities, UserEntityName); user != nil {
+		seeds = append(seeds, user.ID)
+	}
+	if len(seeds) > 0 {
+		current, err := p.store.ListRelations(ctx, &RelationQuery{UserID: req.UserID, Namespace: req.Namespace, EntityIDs: seeds})
+		if err != nil {
+			return err
+		}
+		names := entityNames(entities)
+		for _, relation := range current {
+			facts = append(facts, describeRelation(relation, names))
+		}
+	}
+
+	now := p.now()
+	extraction, err := p.extractor.Extract(ctx, turn, known, facts, now)
+	if err != nil {
+		log.Printf("graph: Memorize failed: %v", err)
+		return err
+	}
+	return p.apply(ctx, req, extraction, now)
+}
+
+// apply writes an extraction to the store: entities are upserted, exclusive
+// relations close the facts they replace, ended relations are closed, and
+// facts that already hold are not duplicated. On a TxStore all writes of one
+// extraction commit or roll back together.
+func (p *Provider) apply(ctx context.Context, req *memory.MemorizeRequest, extraction *Extraction, now time.Time) error {
+	if txStore, ok := p.store.(TxStore); ok {
+		return txStore.WithTx(ctx, func(store Store) error {
+			return applyExtraction(ctx, store, req, extraction, now)
+		})
+	}
+	return applyExtraction(ctx, p.store, req, extraction, now)
+}
+
+func applyExtraction(ctx context.Context, store Store, req *memory.MemorizeRequest, extraction *Extraction, now time.Time) error {
+	resolved := make(map[string]*Entity)
+	resolve := func(name, entityType, description string) (*Entity, error) {
+		name = strings.TrimSpace(name)
+		key := normalizeEntityName(name)
+		if key == "" {
+			return nil, nil
+		}
+		if entity, ok := resolved[key]; ok && entityType == "" && description == "" {
+			return entity, nil
+		}
+		if key == UserEntityName {
+			name, entityType = UserEntityName, EntityPerson
+		}
+		entity := &Entity{
+			UserID:      req.UserID,
+			Namespace:   req.Namespace,
+			Name:        name,
+			Type:        entityType,
+			Description: strings.TrimSpace(description),
+		}
+		if err := store.UpsertEntity(ctx, entity); err != nil {
+			return nil, err
+		}
+		resolved[key] = entity
+		return entity, nil
+	}
+
+	for _, extracted := range extraction.Entities {
+		if _, err := resolve(extracted.Name, extracted.Type, extracted.Description); err != nil {
+			return err
+		}
+	}
+
+	for _, extracted := range extraction.Relations {
+		predicate := normalizePredicate(extracted.Predicate)
+		if predicate == "" {
+			continue
+		}
+		subject, err := resolve(extracted.Subject, "", "")
+		if err != nil {
+			return err
+		}
+		object, err := resolve(extracted.Object, "", "")
+		if err != nil {
+			return err
+		}
+		if subject == nil || object == nil {
+			continue
+		}
+		scope, err := resolve(extracted.Context, "", "")
+		if err != nil {
+			return err
+		}
+		contextID := ""
+		if scope != nil {
+			contextID = scope.ID
+		}
+
+		// The model's date is untrusted: a change cannot happen in the future
+		// or before the facts it ends started to hold.
+		changedAt := extracted.validFrom()
+		if changedAt.IsZero() || changedAt.After(now) {
+			changedAt = now
+		}
+
+		current, err := store.ListRelations(ctx, &RelationQuery{UserID: req.UserID, Namespace: req.Namespace, EntityIDs: []string{subject.ID}})
+		if err != nil {
+			return err
+		}
+		holds := false
+		var ending []*Relation
+		for _, relation := range current {
+			if relation.SubjectID != subject.ID || relation.Predicate != predicate || relation.ContextID != contextID {
+				continue
+			}
+			sameObject := relation.ObjectID == object.ID
+			switch {
+			case extracted.Ended && sameObject, !extracted.Ended && extracted.Exclusive && !sameObject:
+				ending = append(ending, relation)
+				if changedAt.Before(relation.ValidFrom) {
+					changedAt = relation.ValidFrom
+				}
+			case sameObject:
+				holds = true
+			}
+		}
+		for _, relation := range ending {
+			if err := store.EndRelation(ctx, req.UserID, req.Namespace, relation.ID, changedAt); err != nil {
+				return err
+			}
+		}
+		if extracted.Ended || holds {
+			continue
+		}
+
+		if err := store.SaveRelation(ctx, &Relation{
+			UserID:    req.UserID,
+			Namespace: req.Namespace,
+			SubjectID: subject.ID,
+			Predicate: predicate,
+			ObjectID:  object.ID,
+			ContextID: contextID,
+			Fact:      strings.TrimSpace(extracted.Fact),
+			SessionID: req.SessionID,
+			ValidFrom: changedAt,
+			CreatedAt: now,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterHook registers a lifecycle hook. Only the before/after
+// retrieve/memorize events fire.
+func (p *Provider) RegisterHook(event memory.HookEvent, handler memory.HookHandler) {
+	p.hooks.RegisterHook(event, handler)
+}
+
+// Close releases resources held by the provider. The store is owned by the
+// caller, so this is a no-op.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// FormatSubgraph renders a subgraph as a <knowledge_graph> context block, or
+// "" when it has no relations.
+func FormatSubgraph(subgraph *Subgraph) string {
+	if subgraph == nil || len(subgraph.Relations) == 0 {
+		return ""
+	}
+	names := make(map[string]string, len(subgraph.Entities))
+	for id, entity := range subgraph.Entities {
+		names[id] = entity.Name
+	}
+
+	var b strings.Builder
+	b.WriteString("<knowledge_graph>\n")
+	b.WriteString("Known facts about the user and the people, organizations and projects around them. \"user\" is the current user.\n")
+	for _, relation := range subgraph.Relations {
+		b.WriteString("- ")
+		b.WriteString(describeRelation(relation, names))
+		b.WriteString("\n")
+	}
+
+	var described []*Entity
+	for _, entity := range subgraph.Entities {
+		if entity.Description != "" && normalizeEntityName(entity.Name) != UserEntityName {
+			described = append(described, entity)
+		}
+	}
+	if len(described) > 0 {
+		sort.Slice(described, func(i, j int) bool { return described[i].Name < described[j].Name })
+		b.WriteString("Entities:\n")
+		for _, entity := range described {
+			fmt.Fprintf(&b, "- %s (%s): %s\n", entity.Name, entity.Type, entity.Description)
+		}
+	}
+	b.WriteString("</knowledge_graph>")
+	return b.String()
+}
+
+// describeRelation renders one relation with its validity period.
+func describeRelation(relation *Relation, names map[string]string) string {
+	text := fmt.Sprintf("%s -%s-> %s", names[relation.SubjectID], relation.Predicate, names[relation.ObjectID])
+	if relation.ContextID != "" {
+		text += fmt.Sprintf(" (context: %s)", names[relation.ContextID])
+	}
+	if relation.Fact != "" {
+		text = relation.Fact + " [" + text + "]"
+	}
+	if relation.Current() {
+		return text + " (since " + relation.ValidFrom.Format("2006-01-02") + ")"
+	}
+	return fmt.Sprintf("%s (%s ~ %s, no longer true)", text, relation.ValidFrom.Format("2006-01-02"), relation.ValidTo.Format("2006-01-02"))
+}
+
+// mentionedEntities returns the entities whose name appears in text. The
+// reserved user entity and one-character names are never matched.
+func mentionedEntities(entities []*Entity, text string) []*Entity {
+	text = normalizeEntityName(text)
+	if text == "" {
+		return nil
+	}
+	var mentioned []*Entity
+	for _, entity := range entities {
+		key := normalizeEntityName(entity.Name)
+		if key == UserEntityName || utf8.RuneCountInString(key) < 2 {
+			continue
+		}
+		if strings.Contains(text, key) {
+			mentioned = append(mentioned, entity)
+		}
+	}
+	return mentioned
+}
+
+func findEntity(entities []*Entity, name string) *Entity {
+	key := normalizeEntityName(name)
+	for _, entity := range entities {
+		if normalizeEntityName(entity.Name) == key {
+			return entity
+		}
+	}
+	return nil
+}
+
+func entityIDs(entities []*Entity) []string {
+	ids := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		ids = append(ids, entity.ID)
+	}
+	return ids
+}
+
+func entityNames(entities []*Entity) map[string]string {
+	names := make(map[string]string, len(entities))
+	for _, entity := range entities {
+		names[entity.ID] = entity.Name
+	}
+	return names
+}
+
+// latestUserText returns the text of the latest user message, skipping tool results.
+func latestUserText(messages []*schema.AgenticMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg != nil && msg.Role == schema.AgenticRoleTypeUser && !agmsg.HasFunctionToolResult(msg) {
+			return agmsg.Text(msg)
+		}
+	}
+	return ""
+}
+
+// turnText renders the user and assistant text of a turn for extraction.
+func turnText(messages []*schema.AgenticMessage) string {
+	var lines []string
+	for _, msg := range messages {
+		if msg == nil || agmsg.HasFunctionToolCall(msg) || agmsg.HasFunctionToolResult(msg) {
+			continue
+		}
+		content := strings.TrimSpace(agmsg.Text(msg))
+		if content == "" {
+			continue
+		}
+		switch msg.Role {
+		case schema.AgenticRoleTypeUser:
+			lines = append(lines, "User: "+content)
+		case schema.AgenticRoleTypeAssistant:
+			lines = append(lines, "Assistant: "+content)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func init() {
+	memory.MustRegisterPlugin(&memory.Plugin{
+		ID: "graph",
+		Factory: func(config any) (memory.MemoryProvider, error) {
+			cfg, ok := config.(*ProviderConfig)
+			if !ok {
+				return nil, fmt.Errorf("graph: expected *ProviderConfig, got %T", config)
+			}
+			return NewProvider(cfg)
+		},
+	})
+}
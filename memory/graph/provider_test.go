@@ -0,0 +1,190 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	agmsg "github.com/CoolBanHub/aggo/internal/agentic"
+	"github.com/CoolBanHub/aggo/memory"
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// scriptedAgenticModel replies with the queued responses in order.
+type scriptedAgenticModel struct {
+	responses []string
+}
+
+func (m *scriptedAgenticModel) Generate(ctx context.Context, input []*schema.AgenticMessage, opts ...einomodel.Option) (*schema.AgenticMessage, error) {
+	response := m.responses[0]
+	m.responses = m.responses[1:]
+	return agmsg.AssistantMessage(response), nil
+}
+
+func (m *scriptedAgenticModel) Stream(ctx context.Context, input []*schema.AgenticMessage, opts ...einomodel.Option) (*schema.StreamReader[*schema.AgenticMessage], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.AgenticMessage{msg}), nil
+}
+
+func TestProviderTracksChangingFacts(t *testing.T) {
+	ctx := context.Background()
+	cm := &scriptedAgenticModel{responses: []string{
+		`{"entities":[{"name":"Alice","type":"person","description":"产品经理"},{"name":"Project X","type":"project"}],
+		  "relations":[{"subject":"user","predicate":"has manager","object":"Alice","context":"Project X","fact":"Alice 是用户在 Project X 上的经理","exclusive":true},
+		               {"subject":"user","predicate":"prefers","object":"邮件沟通"}]}`,
+		"```json\n" + `{"entities":[{"name":"Bob","type":"person"}],
+		  "relations":[{"subject":"user","predicate":"has_manager","object":"Bob","context":"project x","fact":"Bob 接替 Alice 成为用户在 Project X 上的经理","valid_from":"2026-03-01","exclusive":true},
+		               {"subject":"user","predicate":"prefers","object":"邮件沟通"}]}` + "\n```",
+	}}
+	store := NewMemoryStore()
+	provider, err := NewProvider(&ProviderConfig{Model: cm, Store: store, IncludeEnded: true})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	for i, text := range []string{"我在 Project X 的经理是 Alice，沟通请发邮件", "Project X 从三月起换成 Bob 管了"} {
+		provider.now = func() time.Time { return time.Date(2026, time.Month(1+3*i), 10, 9, 0, 0, 0, time.Local) }
+		err := provider.Memorize(ctx, &memory.MemorizeRequest{
+			UserID:    "u1",
+			SessionID: "s1",
+			Messages:  []*schema.AgenticMessage{schema.UserAgenticMessage(text), agmsg.AssistantMessage("好的")},
+		})
+		if err != nil {
+			t.Fatalf("Memorize: %v", err)
+		}
+	}
+
+	relations, err := store.ListRelations(ctx, &RelationQuery{UserID: "u1", IncludeEnded: true})
+	if err != nil {
+		t.Fatalf("ListRelations: %v", err)
+	}
+	if len(relations) != 3 {
+		t.Fatalf("relations = %d, want 3 (old manager, new manager, preference once)", len(relations))
+	}
+
+	result, err := provider.Retrieve(ctx, &memory.RetrieveRequest{
+		UserID:   "u1",
+		Messages: []*schema.AgenticMessage{schema.UserAgenticMessage("我现在在 project x 的经理是谁？")},
+	})
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if len(result.ContextMessages) != 1 {
+		t.Fatalf("context messages = %d, want 1", len(result.ContextMessages))
+	}
+	block := agmsg.Text(result.ContextMessages[0])
+	for _, want := range []string{
+		"Bob 接替 Alice 成为用户在 Project X 上的经理 [user -has_manager-> Bob (context: Project X)] (since 2026-03-01)",
+		"[user -has_manager-> Alice (context: Project X)] (2026-01-10 ~ 2026-03-01, no longer true)",
+		"Alice (person): 产品经理",
+	} {
+		if !strings.Contains(block, want) {
+			t.Fatalf("knowledge graph block missing %q:\n%s", want, block)
+		}
+	}
+	if strings.Index(block, "Bob 接替") > strings.Index(block, "Alice 是用户") {
+		t.Fatalf("current fact should rank before the ended one:\n%s", block)
+	}
+
+	other, err := provider.Retrieve(ctx, &memory.RetrieveRequest{
+		UserID:    "u1",
+		Namespace: "other-agent",
+		Messages:  []*schema.AgenticMessage{schema.UserAgenticMessage("Project X")},
+	})
+	if err != nil || len(other.ContextMessages) != 0 {
+		t.Fatalf("graph leaked across namespaces: %+v, %v", other, err)
+	}
+}
+
+// memorizeTurns feeds one scripted extraction per turn, all at the given time.
+func memorizeTurns(t *testing.T, store Store, now time.Time, responses ...string) error {
+	t.Helper()
+	provider, err := NewProvider(&ProviderConfig{Model: &scriptedAgenticModel{responses: responses}, Store: store})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	provider.now = func() time.Time { return now }
+	for range responses {
+		err := provider.Memorize(context.Background(), &memory.MemorizeRequest{
+			UserID:   "u1",
+			Messages: []*schema.AgenticMessage{schema.UserAgenticMessage("我的经理换人了"), agmsg.AssistantMessage("好的")},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestProviderClampsChangeTime(t *testing.T) {
+	now := time.Date(2026, 5, 10, 9, 0, 0, 0, time.UTC)
+	first := `{"relations":[{"subject":"user","predicate":"has_manager","object":"Alice","valid_from":"2026-04-01","exclusive":true}]}`
+	cases := []struct {
+		name      string
+		validFrom string
+		want      time.Time
+	}{
+		{name: "future date", validFrom: "2027-01-01", want: now},
+		{name: "before the ended fact", validFrom: "2026-03-01", want: time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local)},
+		{name: "valid date", validFrom: "2026-05-01", want: time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			second := `{"relations":[{"subject":"user","predicate":"has_manager","object":"Bob","valid_from":"` + tc.validFrom + `","exclusive":true}]}`
+			if err := memorizeTurns(t, store, now, first, second); err != nil {
+				t.Fatalf("Memorize: %v", err)
+			}
+			relations, err := store.ListRelations(context.Background(), &RelationQuery{UserID: "u1", IncludeEnded: true})
+			if err != nil || len(relations) != 2 {
+				t.Fatalf("relations = %+v, %v", relations, err)
+			}
+			ended, current := relations[0], relations[1]
+			if ended.ValidTo == nil || !ended.ValidTo.Equal(tc.want) || !current.ValidFrom.Equal(tc.want) {
+				t.Fatalf("change time: ended %v, current %v, want %v", ended.ValidTo, current.ValidFrom, tc.want)
+			}
+		})
+	}
+}
+
+func TestProviderAppliesExtractionInTransaction(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "graph.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	store, err := NewSQLStore(db, "")
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	if err := store.AutoMigrate(); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	// Saving the relation fails after the entities were upserted.
+	failSave := errors.New("save relation failed")
+	err = db.Callback().Create().Before("gorm:create").Register("test:fail_relation", func(tx *gorm.DB) {
+		if tx.Statement.Table == store.relationTable {
+			_ = tx.AddError(failSave)
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	extraction := `{"entities":[{"name":"Alice","type":"person"}],"relations":[{"subject":"user","predicate":"has_manager","object":"Alice"}]}`
+	if err := memorizeTurns(t, store, time.Now(), extraction); !errors.Is(err, failSave) {
+		t.Fatalf("Memorize err = %v, want %v", err, failSave)
+	}
+	entities, err := store.ListEntities(context.Background(), "u1", "")
+	if err != nil || len(entities) != 0 {
+		t.Fatalf("entity upserts should roll back, got %+v, %v", entities, err)
+	}
+}
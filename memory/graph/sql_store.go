@@ -0,0 +1,256 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/CoolBanHub/aggo/utils"
+	"gorm.io/gorm"
+)
+
+const defaultTablePrefix = "aggo_graph"
+
+// EntityModel is the GORM model of the entity table.
+type EntityModel struct {
+	ID          string    `gorm:"primaryKey;size:64"`
+	UserID      string    `gorm:"size:255;not null;uniqueIndex:idx_graph_entity_name,priority:1"`
+	Namespace   string    `gorm:"size:255;not null;default:'';uniqueIndex:idx_graph_entity_name,priority:2"`
+	NameKey     string    `gorm:"size:255;not null;uniqueIndex:idx_graph_entity_name,priority:3"`
+	Name        string    `gorm:"size:255;not null"`
+	Type        string    `gorm:"size:32;not null"`
+	Description string    `gorm:"type:text"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+// RelationModel is the GORM model of the relation table.
+type RelationModel struct {
+	ID        string     `gorm:"primaryKey;size:64"`
+	UserID    string     `gorm:"size:255;not null;index:idx_graph_relation_owner,priority:1"`
+	Namespace string     `gorm:"size:255;not null;default:'';index:idx_graph_relation_owner,priority:2"`
+	SubjectID string     `gorm:"size:64;not null;index"`
+	Predicate string     `gorm:"size:128;not null"`
+	ObjectID  string     `gorm:"size:64;not null;index"`
+	ContextID string     `gorm:"size:64;index"`
+	Fact      string     `gorm:"type:text"`
+	SessionID string     `gorm:"size:255"`
+	ValidFrom time.Time  `gorm:"not null"`
+	ValidTo   *time.Time `gorm:"index"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
+
+// SQLStore stores knowledge graphs in two SQL tables through GORM:
+// <prefix>_entities and <prefix>_relations.
+type SQLStore struct {
+	db            *gorm.DB
+	entityTable   string
+	relationTable string
+}
+
+var _ TxStore = (*SQLStore)(nil)
+
+// NewSQLStore creates a SQL store. prefix defaults to "aggo_graph". Call
+// AutoMigrate before first use.
+func NewSQLStore(db *gorm.DB, prefix string) (*SQLStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("graph: database instance cannot be nil")
+	}
+	if prefix == "" {
+		prefix = defaultTablePrefix
+	}
+	return &SQLStore{
+		db:            db,
+		entityTable:   prefix + "_entities",
+		relationTable: prefix + "_relations",
+	}, nil
+}
+
+// AutoMigrate creates or updates the graph tables.
+func (s *SQLStore) AutoMigrate() error {
+	if err := s.db.Table(s.entityTable).AutoMigrate(&EntityModel{}); err != nil {
+		return err
+	}
+	return s.db.Table(s.relationTable).AutoMigrate(&RelationModel{})
+}
+
+// UpsertEntity implements Store.
+func (s *SQLStore) UpsertEntity(ctx context.Context, entity *Entity) error {
+	if entity == nil || entity.UserID == "" || normalizeEntityName(entity.Name) == "" {
+		return fmt.Errorf("graph: entity user id and name are required")
+	}
+	key := normalizeEntityName(entity.Name)
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var model EntityModel
+		err := tx.Table(s.entityTable).
+			Where("user_id = ? AND namespace = ? AND name_key = ?", entity.UserID, entity.Namespace, key).
+			First(&model).Error
+		switch {
+		case err == nil:
+			existing := model.toEntity()
+			mergeEntity(existing, entity, time.Now())
+			if err := tx.Table(s.entityTable).Where("id = ?", model.ID).
+				Updates(map[string]any{"type": existing.Type, "description": existing.Description, "updated_at": existing.UpdatedAt}).Error; err != nil {
+				return err
+			}
+			*entity = *existing
+			return nil
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if entity.ID == "" {
+				entity.ID = utils.GetULID()
+			}
+			entity.Type = normalizeEntityType(entity.Type)
+			model = EntityModel{
+				ID:          entity.ID,
+				UserID:      entity.UserID,
+				Namespace:   entity.Namespace,
+				NameKey:     key,
+				Name:        entity.Name,
+				Type:        entity.Type,
+				Description: entity.Description,
+			}
+			if err := tx.Table(s.entityTable).Create(&model).Error; err != nil {
+				return err
+			}
+			entity.CreatedAt = model.CreatedAt
+			entity.UpdatedAt = model.UpdatedAt
+			return nil
+		default:
+			return err
+		}
+	})
+}
+
+// ListEntities implements Store.
+func (s *SQLStore) ListEntities(ctx context.Context, userID, namespace string) ([]*Entity, error) {
+	var models []EntityModel
+	if err := s.db.WithContext(ctx).Table(s.entityTable).
+		Where("user_id = ? AND namespace = ?", userID, namespace).
+		Order("created_at ASC").Order("id ASC").
+		Find(&models).Error; err != nil {
+		return nil, err
+	}
+	entities := make([]*Entity, 0, len(models))
+	for i := range models {
+		entities = append(entities, models[i].toEntity())
+	}
+	return entities, nil
+}
+
+// WithTx implements TxStore with a database transaction.
+func (s *SQLStore) WithTx(ctx context.Context, fn func(store Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&SQLStore{db: tx, entityTable: s.entityTable, relationTable: s.relationTable})
+	})
+}
+
+// SaveRelation implements Store.
+func (s *SQLStore) SaveRelation(ctx context.Context, relation *Relation) error {
+	if relation == nil || relation.UserID == "" || relation.SubjectID == "" || relation.ObjectID == "" {
+		return fmt.Errorf("graph: relation user, subject and object are required")
+	}
+	if relation.ID == "" {
+		relation.ID = utils.GetULID()
+	}
+	if relation.CreatedAt.IsZero() {
+		relation.CreatedAt = time.Now()
+	}
+	if relation.ValidFrom.IsZero() {
+		relation.ValidFrom = relation.CreatedAt
+	}
+	model := RelationModel{
+		ID:        relation.ID,
+		UserID:    relation.UserID,
+		Namespace: relation.Namespace,
+		SubjectID: relation.SubjectID,
+		Predicate: relation.Predicate,
+		ObjectID:  relation.ObjectID,
+		ContextID: relation.ContextID,
+		Fact:      relation.Fact,
+		SessionID: relation.SessionID,
+		ValidFrom: relation.ValidFrom,
+		ValidTo:   relation.ValidTo,
+		CreatedAt: relation.CreatedAt,
+	}
+	return s.db.WithContext(ctx).Table(s.relationTable).Create(&model).Error
+}
+
+// EndRelation implements Store.
+func (s *SQLStore) EndRelation(ctx context.Context, userID, namespace, relationID string, validTo time.Time) error {
+	result := s.db.WithContext(ctx).Table(s.relationTable).
+		Where("id = ? AND user_id = ? AND namespace = ?", relationID, userID, namespace).
+		Update("valid_to", validTo)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("graph: relation %s not found", relationID)
+	}
+	return nil
+}
+
+// ListRelations implements Store.
+func (s *SQLStore) ListRelations(ctx context.Context, query *RelationQuery) ([]*Relation, error) {
+	if query == nil || query.UserID == "" {
+		return nil, fmt.Errorf("graph: user id is required")
+	}
+	db := s.db.WithContext(ctx).Table(s.relationTable).
+		Where("user_id = ? AND namespace = ?", query.UserID, query.Namespace)
+	if !query.IncludeEnded {
+		db = db.Where("valid_to IS NULL")
+	}
+	if len(query.EntityIDs) > 0 {
+		db = db.Where("subject_id IN ? OR object_id IN ? OR context_id IN ?", query.EntityIDs, query.EntityIDs, query.EntityIDs)
+	}
+
+	var models []RelationModel
+	if err := db.Order("valid_from ASC").Order("id ASC").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	relations := make([]*Relation, 0, len(models))
+	for i := range models {
+		relations = append(relations, models[i].toRelation())
+	}
+	return relations, nil
+}
+
+// DeleteGraph implements Store.
+func (s *SQLStore) DeleteGraph(ctx context.Context, userID, namespace string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(s.relationTable).Where("user_id = ? AND namespace = ?", userID, namespace).Delete(&RelationModel{}).Error; err != nil {
+			return err
+		}
+		return tx.Table(s.entityTable).Where("user_id = ? AND namespace = ?", userID, namespace).Delete(&EntityModel{}).Error
+	})
+}
+
+func (m *EntityModel) toEntity() *Entity {
+	return &Entity{
+		ID:          m.ID,
+		UserID:      m.UserID,
+		Namespace:   m.Namespace,
+		Name:        m.Name,
+		Type:        m.Type,
+		Description: m.Description,
+		CreatedAt:   m.CreatedAt,
+		UpdatedAt:   m.UpdatedAt,
+	}
+}
+
+func (m *RelationModel) toRelation() *Relation {
+	return &Relation{
+		ID:        m.ID,
+		UserID:    m.UserID,
+		Namespace: m.Namespace,
+		SubjectID: m.SubjectID,
+		Predicate: m.Predicate,
+		ObjectID:  m.ObjectID,
+		ContextID: m.ContextID,
+		Fact:      m.Fact,
+		SessionID: m.SessionID,
+		ValidFrom: m.ValidFrom,
+		ValidTo:   m.ValidTo,
+		CreatedAt: m.CreatedAt,
+	}
+}
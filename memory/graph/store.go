@@ -0,0 +1,221 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CoolBanHub/aggo/utils"
+)
+
+// Store persists knowledge graphs. All methods are scoped to one user and
+// namespace; implementations must not return or modify other users' data.
+type Store interface {
+	// UpsertEntity stores an entity, matching an existing one by normalized
+	// name. An existing entity keeps its ID and only takes over a more
+	// specific type and a non-empty description. entity.ID is set on return.
+	UpsertEntity(ctx context.Context, entity *Entity) error
+	// ListEntities returns all entities of a user, oldest first.
+	ListEntities(ctx context.Context, userID, namespace string) ([]*Entity, error)
+	// SaveRelation stores a new relation and sets its ID.
+	SaveRelation(ctx context.Context, relation *Relation) error
+	// EndRelation marks a relation as no longer holding since validTo.
+	EndRelation(ctx context.Context, userID, namespace, relationID string, validTo time.Time) error
+	// ListRelations returns matching relations ordered by ValidFrom.
+	ListRelations(ctx context.Context, query *RelationQuery) ([]*Relation, error)
+	// DeleteGraph removes all entities and relations of a user.
+	DeleteGraph(ctx context.Context, userID, namespace string) error
+}
+
+// TxStore is implemented by stores that can apply several writes atomically.
+// The Provider applies each extraction inside WithTx when the store supports it.
+type TxStore interface {
+	Store
+	// WithTx runs fn with a Store whose writes commit together when fn returns
+	// nil and roll back otherwise.
+	WithTx(ctx context.Context, fn func(store Store) error) error
+}
+
+// MemoryStore is an in-memory Store, suitable for tests and single-process
+// deployments that don't need persistence.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	entities  map[string]*Entity
+	relations map[string]*Relation
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entities:  make(map[string]*Entity),
+		relations: make(map[string]*Relation),
+	}
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// UpsertEntity implements Store.
+func (s *MemoryStore) UpsertEntity(ctx context.Context, entity *Entity) error {
+	if entity == nil || entity.UserID == "" || normalizeEntityName(entity.Name) == "" {
+		return fmt.Errorf("graph: entity user id and name are required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := normalizeEntityName(entity.Name)
+	for _, existing := range s.entities {
+		if existing.UserID != entity.UserID || existing.Namespace != entity.Namespace || normalizeEntityName(existing.Name) != key {
+			continue
+		}
+		mergeEntity(existing, entity, now)
+		*entity = *existing
+		return nil
+	}
+
+	if entity.ID == "" {
+		entity.ID = utils.GetULID()
+	}
+	entity.Type = normalizeEntityType(entity.Type)
+	entity.CreatedAt = now
+	entity.UpdatedAt = now
+	stored := *entity
+	s.entities[stored.ID] = &stored
+	return nil
+}
+
+// ListEntities implements Store.
+func (s *MemoryStore) ListEntities(ctx context.Context, userID, namespace string) ([]*Entity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entities []*Entity
+	for _, entity := range s.entities {
+		if entity.UserID == userID && entity.Namespace == namespace {
+			copied := *entity
+			entities = append(entities, &copied)
+		}
+	}
+	sort.Slice(entities, func(i, j int) bool {
+		if entities[i].CreatedAt.Equal(entities[j].CreatedAt) {
+			return entities[i].ID < entities[j].ID
+		}
+		return entities[i].CreatedAt.Before(entities[j].CreatedAt)
+	})
+	return entities, nil
+}
+
+// SaveRelation implements Store.
+func (s *MemoryStore) SaveRelation(ctx context.Context, relation *Relation) error {
+	if relation == nil || relation.UserID == "" || relation.SubjectID == "" || relation.ObjectID == "" {
+		return fmt.Errorf("graph: relation user, subject and object are required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if relation.ID == "" {
+		relation.ID = utils.GetULID()
+	}
+	if relation.CreatedAt.IsZero() {
+		relation.CreatedAt = time.Now()
+	}
+	if relation.ValidFrom.IsZero() {
+		relation.ValidFrom = relation.CreatedAt
+	}
+	stored := *relation
+	s.relations[stored.ID] = &stored
+	return nil
+}
+
+// EndRelation implements Store.
+func (s *MemoryStore) EndRelation(ctx context.Context, userID, namespace, relationID string, validTo time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	relation, ok := s.relations[relationID]
+	if !ok || relation.UserID != userID || relation.Namespace != namespace {
+		return fmt.Errorf("graph: relation %s not found", relationID)
+	}
+	relation.ValidTo = &validTo
+	return nil
+}
+
+// ListRelations implements Store.
+func (s *MemoryStore) ListRelations(ctx context.Context, query *RelationQuery) ([]*Relation, error) {
+	if query == nil || query.UserID == "" {
+		return nil, fmt.Errorf("graph: user id is required")
+	}
+	ids := make(map[string]struct{}, len(query.EntityIDs))
+	for _, id := range query.EntityIDs {
+		ids[id] = struct{}{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var relations []*Relation
+	for _, relation := range s.relations {
+		if relation.UserID != query.UserID || relation.Namespace != query.Namespace {
+			continue
+		}
+		if !query.IncludeEnded && !relation.Current() {
+			continue
+		}
+		if len(ids) > 0 && !touchesAny(relation, ids) {
+			continue
+		}
+		copied := *relation
+		relations = append(relations, &copied)
+	}
+	sortRelations(relations)
+	return relations, nil
+}
+
+// DeleteGraph implements Store.
+func (s *MemoryStore) DeleteGraph(ctx context.Context, userID, namespace string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, entity := range s.entities {
+		if entity.UserID == userID && entity.Namespace == namespace {
+			delete(s.entities, id)
+		}
+	}
+	for id, relation := range s.relations {
+		if relation.UserID == userID && relation.Namespace == namespace {
+			delete(s.relations, id)
+		}
+	}
+	return nil
+}
+
+// mergeEntity applies an upserted entity to the stored one.
+func mergeEntity(existing, update *Entity, now time.Time) {
+	if t := normalizeEntityType(update.Type); t != EntityOther {
+		existing.Type = t
+	}
+	if update.Description != "" {
+		existing.Description = update.Description
+	}
+	existing.UpdatedAt = now
+}
+
+func touchesAny(relation *Relation, ids map[string]struct{}) bool {
+	for _, id := range []string{relation.SubjectID, relation.ObjectID, relation.ContextID} {
+		if _, ok := ids[id]; ok && id != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func sortRelations(relations []*Relation) {
+	sort.Slice(relations, func(i, j int) bool {
+		if relations[i].ValidFrom.Equal(relations[j].ValidFrom) {
+			return relations[i].ID < relations[j].ID
+		}
+		return relations[i].ValidFrom.Before(relations[j].ValidFrom)
+	})
+}
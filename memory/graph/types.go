@@ -0,0 +1,121 @@
+package graph
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+)
+
+// Entity types extracted from conversations.
+const (
+	EntityPerson       = "person"
+	EntityOrganization = "organization"
+	EntityProject      = "project"
+	EntityPreference   = "preference"
+	EntityOther        = "other"
+)
+
+// UserEntityName is the reserved entity that stands for the user the memory
+// belongs to, so "my manager" becomes a relation of this entity.
+const UserEntityName = "user"
+
+const (
+	defaultMaxHops      = 1
+	defaultMaxRelations = 30
+	defaultMaxKnown     = 50
+)
+
+// Entity is a node of a user's knowledge graph. Entities are unique per
+// user, namespace and case-insensitive name.
+type Entity struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"userId"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Relation is a directed, time-bounded edge: Subject -Predicate-> Object,
+// optionally qualified by a context entity ("has_manager Alice" in the
+// context of "Project X"). ValidTo is nil while the fact still holds; facts
+// that change are closed rather than overwritten, so the graph keeps their
+// history.
+type Relation struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"userId"`
+	Namespace string     `json:"namespace,omitempty"`
+	SubjectID string     `json:"subjectId"`
+	Predicate string     `json:"predicate"`
+	ObjectID  string     `json:"objectId"`
+	ContextID string     `json:"contextId,omitempty"`
+	Fact      string     `json:"fact,omitempty"`
+	SessionID string     `json:"sessionId,omitempty"`
+	ValidFrom time.Time  `json:"validFrom"`
+	ValidTo   *time.Time `json:"validTo,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// Current reports whether the relation still holds.
+func (r *Relation) Current() bool {
+	return r != nil && r.ValidTo == nil
+}
+
+// RelationQuery selects relations of one user and namespace.
+type RelationQuery struct {
+	UserID    string
+	Namespace string
+	// EntityIDs keeps relations whose subject, object or context is one of
+	// these entities; empty selects all relations.
+	EntityIDs []string
+	// IncludeEnded also returns relations that no longer hold.
+	IncludeEnded bool
+}
+
+// Subgraph is the part of a graph around a set of entities.
+type Subgraph struct {
+	Entities  map[string]*Entity
+	Relations []*Relation
+}
+
+// ProviderConfig configures the knowledge-graph provider.
+type ProviderConfig struct {
+	// Model extracts entities and relations from each memorized turn. Required.
+	Model model.AgenticModel
+	// Store persists the graph. Required; use NewSQLStore for SQL tables.
+	Store Store
+
+	// MaxHops is how far Retrieve expands from the entities mentioned in the
+	// latest user message. Default 1.
+	MaxHops int
+	// MaxRelations caps the relations injected into the context. Default 30.
+	MaxRelations int
+	// IncludeEnded also injects facts that no longer hold, with their
+	// validity period, so the model can answer "who was ...".
+	IncludeEnded bool
+	// MaxKnownEntities caps the known entity names given to the extraction
+	// prompt to keep names consistent across turns. Default 50.
+	MaxKnownEntities int
+	// ExtractionPrompt overrides DefaultExtractionPrompt.
+	ExtractionPrompt string
+}
+
+func normalizeEntityName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func normalizeEntityType(t string) string {
+	switch t = strings.ToLower(strings.TrimSpace(t)); t {
+	case EntityPerson, EntityOrganization, EntityProject, EntityPreference:
+		return t
+	default:
+		return EntityOther
+	}
+}
+
+func normalizePredicate(p string) string {
+	return strings.ToLower(strings.Join(strings.Fields(p), "_"))
+}
@@ -0,0 +1,151 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Change events fired by providers that own persistent memory. The builtin
+// provider fires all of them; remote providers (mem0, memu) only fire the
+// retrieve/memorize lifecycle events because the changes happen server-side.
+const (
+	// HookUserMemoryUpdated fires after the user memory document is written
+	// or cleared. The builtin provider passes a *builtin.UserMemoryChange.
+	HookUserMemoryUpdated HookEvent = "user_memory_updated"
+
+	// HookUserMemoryEventCreated fires after a user memory event is stored.
+	// The builtin provider passes the new *builtin.UserMemoryEvent.
+	HookUserMemoryEventCreated HookEvent = "user_memory_event_created"
+
+	// HookSessionSummaryUpdated fires after a session summary is created or
+	// refreshed. The builtin provider passes the *builtin.SessionSummary.
+	HookSessionSummaryUpdated HookEvent = "session_summary_updated"
+
+	// HookUserDigestCreated fires after a cross-session user digest is
+	// generated. The builtin provider passes the new *builtin.UserDigest.
+	HookUserDigestCreated HookEvent = "user_digest_created"
+
+	// HookCleanupPerformed fires after a periodic (or forced) cleanup run.
+	// The builtin provider passes a *builtin.CleanupReport.
+	HookCleanupPerformed HookEvent = "cleanup_performed"
+)
+
+// RetrieveHookData is the payload of HookBeforeRetrieve and HookAfterRetrieve.
+// Result, Err and Duration are only set for HookAfterRetrieve.
+type RetrieveHookData struct {
+	Request  *RetrieveRequest
+	Result   *RetrieveResult
+	Err      error
+	Duration time.Duration
+}
+
+// MemorizeHookData is the payload of HookBeforeMemorize and HookAfterMemorize.
+// Err and Duration are only set for HookAfterMemorize.
+type MemorizeHookData struct {
+	Request  *MemorizeRequest
+	Err      error
+	Duration time.Duration
+}
+
+// Hooks is a concurrency-safe registry of HookHandlers. Providers hold one to
+// implement HookableProvider. The zero value is ready to use.
+//
+// Handlers of before_* events may veto the operation by returning an error,
+// which is returned to the caller unchanged. Errors from all other handlers
+// are logged and never affect the operation.
+type Hooks struct {
+	mu       sync.RWMutex
+	handlers map[HookEvent][]HookHandler
+}
+
+// RegisterHook registers a handler for the given event. Handlers run
+// synchronously in registration order.
+func (h *Hooks) RegisterHook(event HookEvent, handler HookHandler) {
+	if handler == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.handlers == nil {
+		h.handlers = make(map[HookEvent][]HookHandler)
+	}
+	h.handlers[event] = append(h.handlers[event], handler)
+}
+
+// Has reports whether any handler is registered for event.
+func (h *Hooks) Has(event HookEvent) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.handlers[event]) > 0
+}
+
+// Fire runs the handlers registered for event and stops at the first error.
+func (h *Hooks) Fire(ctx context.Context, event HookEvent, data any) error {
+	h.mu.RLock()
+	handlers := h.handlers[event]
+	h.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event, data); err != nil {
+			return fmt.Errorf("%s hook: %w", event, err)
+		}
+	}
+	return nil
+}
+
+// Notify runs every handler registered for event and logs their errors.
+func (h *Hooks) Notify(ctx context.Context, event HookEvent, data any) {
+	h.mu.RLock()
+	handlers := h.handlers[event]
+	h.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event, data); err != nil {
+			log.Printf("memory: %s hook failed: %v", event, err)
+		}
+	}
+}
+
+// WrapRetrieve runs retrieve between the before/after retrieve hooks.
+func (h *Hooks) WrapRetrieve(ctx context.Context, req *RetrieveRequest, retrieve func(context.Context, *RetrieveRequest) (*RetrieveResult, error)) (*RetrieveResult, error) {
+	if err := h.Fire(ctx, HookBeforeRetrieve, &RetrieveHookData{Request: req}); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	result, err := retrieve(ctx, req)
+	h.Notify(ctx, HookAfterRetrieve, &RetrieveHookData{
+		Request:  req,
+		Result:   result,
+		Err:      err,
+		Duration: time.Since(start),
+	})
+	return result, err
+}
+
+// WrapMemorize runs memorize between the before/after memorize hooks.
+func (h *Hooks) WrapMemorize(ctx context.Context, req *MemorizeRequest, memorize func(context.Context, *MemorizeRequest) error) error {
+	if err := h.Fire(ctx, HookBeforeMemorize, &MemorizeHookData{Request: req}); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := memorize(ctx, req)
+	h.Notify(ctx, HookAfterMemorize, &MemorizeHookData{
+		Request:  req,
+		Err:      err,
+		Duration: time.Since(start),
+	})
+	return err
+}
+
+// IsLifecycleHookEvent reports whether event is one of the before/after
+// retrieve/memorize events, as opposed to a change event.
+func IsLifecycleHookEvent(event HookEvent) bool {
+	switch event {
+	case HookBeforeRetrieve, HookAfterRetrieve, HookBeforeMemorize, HookAfterMemorize:
+		return true
+	}
+	return false
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -88,19 +90,122 @@ func (c *Client) Search(ctx context.Context, req SearchRequest) ([]SearchItem, e
 	return normalizeSearchItems(payload), nil
 }
 
-func (c *Client) postJSON(ctx context.Context, path string, requestBody any) (any, error) {
-	body, err := json.Marshal(requestBody)
+// GetAll lists every memory in the given scope. mem0 returns them unranked;
+// callers sort as needed.
+func (c *Client) GetAll(ctx context.Context, req ListRequest) ([]SearchItem, error) {
+	payload, err := c.doJSON(ctx, http.MethodGet, c.config.MemoriesPath, req.values(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeSearchItems(payload), nil
+}
+
+// Get fetches one memory by id. It returns nil, nil when the memory does not exist.
+func (c *Client) Get(ctx context.Context, memoryID string) (*SearchItem, error) {
+	payload, err := c.doJSON(ctx, http.MethodGet, c.memoryPath(memoryID, ""), nil, nil)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	obj, ok := payload.(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	item, ok := normalizeSearchItem(obj)
+	if !ok {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// Update replaces the text of one memory.
+func (c *Client) Update(ctx context.Context, memoryID, text string) error {
+	_, err := c.doJSON(ctx, http.MethodPut, c.memoryPath(memoryID, ""), nil, updateRequest{Text: text})
+	return err
+}
+
+// Delete removes one memory.
+func (c *Client) Delete(ctx context.Context, memoryID string) error {
+	_, err := c.doJSON(ctx, http.MethodDelete, c.memoryPath(memoryID, ""), nil, nil)
+	return err
+}
+
+// History returns the change history of one memory, oldest first.
+func (c *Client) History(ctx context.Context, memoryID string) ([]HistoryItem, error) {
+	payload, err := c.doJSON(ctx, http.MethodGet, c.memoryPath(memoryID, "history"), nil, nil)
 	if err != nil {
-		return nil, fmt.Errorf("marshal %s request: %w", path, err)
+		return nil, err
+	}
+	objects := unwrapObjects(payload, looksLikeHistoryObject)
+	items := make([]HistoryItem, 0, len(objects))
+	for _, obj := range objects {
+		items = append(items, HistoryItem{
+			ID:        firstString(obj["id"]),
+			MemoryID:  firstString(obj["memory_id"], obj["memoryId"]),
+			OldMemory: firstString(obj["old_memory"], obj["previous_value"]),
+			NewMemory: firstString(obj["new_memory"], obj["new_value"]),
+			Event:     strings.ToUpper(firstString(obj["event"], obj["action"])),
+			CreatedAt: firstString(obj["created_at"], obj["createdAt"]),
+			UpdatedAt: firstString(obj["updated_at"], obj["updatedAt"]),
+		})
+	}
+	return items, nil
+}
+
+// DeleteAll removes every memory in the given scope. At least one scope id
+// must be set so a misconfigured call cannot wipe the whole project.
+func (c *Client) DeleteAll(ctx context.Context, req ListRequest) error {
+	if strings.TrimSpace(req.UserID) == "" && strings.TrimSpace(req.AgentID) == "" && strings.TrimSpace(req.RunID) == "" {
+		return fmt.Errorf("delete all memories: user, agent or run id is required")
+	}
+	_, err := c.doJSON(ctx, http.MethodDelete, c.config.MemoriesPath, req.values(), nil)
+	return err
+}
+
+// memoryPath builds the path of one memory (and an optional sub resource),
+// keeping the trailing-slash style of MemoriesPath.
+func (c *Client) memoryPath(memoryID, sub string) string {
+	base := normalizePath(c.config.MemoriesPath)
+	trailing := strings.HasSuffix(base, "/")
+	path := strings.TrimRight(base, "/") + "/" + url.PathEscape(memoryID)
+	if sub != "" {
+		path += "/" + sub
 	}
+	if trailing {
+		path += "/"
+	}
+	return path
+}
 
-	url := c.baseURL + normalizePath(path)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+func (c *Client) postJSON(ctx context.Context, path string, requestBody any) (any, error) {
+	return c.doJSON(ctx, http.MethodPost, path, nil, requestBody)
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, requestBody any) (any, error) {
+	var reader io.Reader
+	if requestBody != nil {
+		body, err := json.Marshal(requestBody)
+		if err != nil {
+			return nil, fmt.Errorf("marshal %s request: %w", path, err)
+		}
+		reader = bytes.NewReader(body)
+	}
+
+	endpoint := c.baseURL + normalizePath(path)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
 	if err != nil {
 		return nil, fmt.Errorf("build %s request: %w", path, err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if requestBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	req.Header.Set("Accept", "application/json")
 	c.applyHeaders(req)
 
@@ -112,11 +217,12 @@ func (c *Client) postJSON(ctx context.Context, path string, requestBody any) (an
 
 	if resp.StatusCode >= http.StatusBadRequest {
 		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		msg := strings.TrimSpace(string(data))
-		if msg == "" {
-			return nil, fmt.Errorf("%s returned status %s", path, resp.Status)
+		return nil, &APIError{
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       strings.TrimSpace(string(data)),
 		}
-		return nil, fmt.Errorf("%s returned status %s: %s", path, resp.Status, msg)
 	}
 
 	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
@@ -220,20 +326,27 @@ func normalizeSearchItems(payload any) []SearchItem {
 }
 
 func unwrapSearchObjects(payload any) []map[string]any {
+	return unwrapObjects(payload, looksLikeMemoryObject)
+}
+
+// unwrapObjects extracts the item list from a bare array or the usual
+// results/items/memories/data envelopes; a single object is accepted when
+// looksLike recognizes it.
+func unwrapObjects(payload any, looksLike func(map[string]any) bool) []map[string]any {
 	switch v := payload.(type) {
 	case nil:
 		return nil
 	case []any:
 		return collectObjects(v)
 	case map[string]any:
-		for _, key := range []string{"results", "items", "memories", "data"} {
+		for _, key := range []string{"results", "items", "memories", "data", "history"} {
 			if raw, ok := v[key]; ok {
 				if arr, ok := raw.([]any); ok {
 					return collectObjects(arr)
 				}
 			}
 		}
-		if looksLikeMemoryObject(v) {
+		if looksLike(v) {
 			return []map[string]any{v}
 		}
 	}
@@ -272,6 +385,8 @@ func normalizeSearchItem(obj map[string]any) (SearchItem, bool) {
 		Score:      firstFloat(obj["score"], obj["similarity"]),
 		Categories: firstStringSlice(obj["categories"], obj["category"]),
 		Metadata:   firstMap(obj["metadata"]),
+		UserID:     firstString(obj["user_id"], obj["userId"]),
+		AgentID:    firstString(obj["agent_id"], obj["agentId"]),
 		CreatedAt:  firstString(obj["created_at"], obj["createdAt"]),
 		UpdatedAt:  firstString(obj["updated_at"], obj["updatedAt"]),
 	}
@@ -287,6 +402,10 @@ func looksLikeMemoryObject(obj map[string]any) bool {
 	return strings.TrimSpace(firstString(obj["memory"], obj["text"], obj["content"], obj["summary"])) != ""
 }
 
+func looksLikeHistoryObject(obj map[string]any) bool {
+	return firstString(obj["event"], obj["action"]) != ""
+}
+
 func firstString(values ...any) string {
 	for _, value := range values {
 		switch v := value.(type) {
@@ -0,0 +1,235 @@
+package mem0
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory"
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/memoryevent"
+)
+
+var _ memory.UserMemoryEventSearcher = (*Provider)(nil)
+
+// SearchUserMemoryEvents implements memory.UserMemoryEventSearcher so the
+// search_user_memory tool works with mem0. Each mem0 memory maps to one
+// event. Keywords are sent to mem0 as a semantic search query, so Match is
+// ignored; without keywords the user's memories are listed newest first.
+// Type, Since and Until are applied locally. The memory namespace in ctx
+// selects the mem0 agent, as in Retrieve.
+func (p *Provider) SearchUserMemoryEvents(ctx context.Context, query *memoryevent.Query) ([]*memoryevent.Event, error) {
+	if query == nil || strings.TrimSpace(query.UserID) == "" {
+		return nil, fmt.Errorf("mem0: user id is required")
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = p.config.SearchResultLimit
+	}
+
+	var items []SearchItem
+	switch {
+	case len(query.IDs) > 0:
+		for _, id := range query.IDs {
+			item, err := p.client.Get(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if item != nil {
+				items = append(items, *item)
+			}
+		}
+	case len(query.Keywords) > 0:
+		searchReq := SearchRequest{
+			Query:     strings.Join(query.Keywords, " "),
+			UserID:    query.UserID,
+			AgentID:   p.agentID(builtin.NamespaceFromContext(ctx)),
+			AppID:     p.config.AppID,
+			OrgID:     p.config.OrgID,
+			ProjectID: p.config.ProjectID,
+			Limit:     limit,
+		}
+		// Leave room for the local filters below.
+		if query.Type != "" || query.Since != nil || query.Until != nil {
+			searchReq.Limit = limit * 3
+		}
+		var err error
+		if items, err = p.client.Search(ctx, searchReq); err != nil {
+			return nil, err
+		}
+	default:
+		var err error
+		if items, err = p.client.GetAll(ctx, p.listRequest(ctx, query.UserID)); err != nil {
+			return nil, err
+		}
+		sortItemsNewestFirst(items)
+	}
+
+	namespace := builtin.NamespaceFromContext(ctx)
+	events := make([]*memoryevent.Event, 0, len(items))
+	for _, item := range items {
+		if !ownedBy(&item, query.UserID) {
+			continue
+		}
+		event := itemToEvent(item, query.UserID, namespace)
+		if query.Type != "" && event.Type != query.Type {
+			continue
+		}
+		if query.Since != nil && event.EventDate.Before(*query.Since) {
+			continue
+		}
+		if query.Until != nil && event.EventDate.After(*query.Until) {
+			continue
+		}
+		events = append(events, event)
+		if len(events) >= limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+// ListRecentUserMemoryEvents implements memory.UserMemoryEventSearcher,
+// returning the user's newest mem0 memories. limit <= 0 returns all of them.
+func (p *Provider) ListRecentUserMemoryEvents(ctx context.Context, userID string, limit int) ([]*memoryevent.Event, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, fmt.Errorf("mem0: user id is required")
+	}
+	items, err := p.client.GetAll(ctx, p.listRequest(ctx, userID))
+	if err != nil {
+		return nil, err
+	}
+	sortItemsNewestFirst(items)
+
+	namespace := builtin.NamespaceFromContext(ctx)
+	events := make([]*memoryevent.Event, 0, len(items))
+	for _, item := range items {
+		if !ownedBy(&item, userID) {
+			continue
+		}
+		events = append(events, itemToEvent(item, userID, namespace))
+		if limit > 0 && len(events) >= limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+// ExportUserMemories returns every mem0 memory of the user, oldest first,
+// e.g. to answer a data export request.
+func (p *Provider) ExportUserMemories(ctx context.Context, userID string) ([]*memoryevent.Event, error) {
+	events, err := p.ListRecentUserMemoryEvents(ctx, userID, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// UserMemoryHistory returns the change history of one of the user's memories.
+func (p *Provider) UserMemoryHistory(ctx context.Context, userID, memoryID string) ([]HistoryItem, error) {
+	if _, err := p.ownedMemory(ctx, userID, memoryID); err != nil {
+		return nil, err
+	}
+	return p.client.History(ctx, memoryID)
+}
+
+// DeleteUserMemoryEvent deletes one of the user's memories. Memories owned
+// by another user are rejected.
+func (p *Provider) DeleteUserMemoryEvent(ctx context.Context, userID, eventID string) error {
+	if _, err := p.ownedMemory(ctx, userID, eventID); err != nil {
+		return err
+	}
+	return p.client.Delete(ctx, eventID)
+}
+
+// DeleteAllUserMemories deletes every mem0 memory of the user in the current
+// scope: the namespace in ctx (or the configured AgentID) limits the delete
+// to one agent, otherwise memories of all agents are removed.
+func (p *Provider) DeleteAllUserMemories(ctx context.Context, userID string) error {
+	if strings.TrimSpace(userID) == "" {
+		return fmt.Errorf("mem0: user id is required")
+	}
+	return p.client.DeleteAll(ctx, p.listRequest(ctx, userID))
+}
+
+// ownedMemory fetches a memory and checks that it belongs to userID.
+func (p *Provider) ownedMemory(ctx context.Context, userID, memoryID string) (*SearchItem, error) {
+	if strings.TrimSpace(userID) == "" || strings.TrimSpace(memoryID) == "" {
+		return nil, fmt.Errorf("mem0: user id and memory id are required")
+	}
+	item, err := p.client.Get(ctx, memoryID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil || !ownedBy(item, userID) {
+		return nil, fmt.Errorf("mem0: memory %s not found", memoryID)
+	}
+	return item, nil
+}
+
+func (p *Provider) listRequest(ctx context.Context, userID string) ListRequest {
+	return ListRequest{
+		UserID:    userID,
+		AgentID:   p.agentID(builtin.NamespaceFromContext(ctx)),
+		AppID:     p.config.AppID,
+		OrgID:     p.config.OrgID,
+		ProjectID: p.config.ProjectID,
+	}
+}
+
+// ownedBy reports whether item belongs to userID. Items without a user id
+// (some OSS responses omit it) are trusted because they were fetched in the
+// user's scope.
+func ownedBy(item *SearchItem, userID string) bool {
+	return item.UserID == "" || item.UserID == userID
+}
+
+func sortItemsNewestFirst(items []SearchItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return parseMem0Time(items[i].CreatedAt).After(parseMem0Time(items[j].CreatedAt))
+	})
+}
+
+// itemToEvent maps a mem0 memory to a memoryevent.Event. Categories become
+// keywords; metadata may carry type, event_date and importance.
+func itemToEvent(item SearchItem, userID, namespace string) *memoryevent.Event {
+	createdAt := parseMem0Time(item.CreatedAt)
+	event := &memoryevent.Event{
+		ID:         item.ID,
+		UserID:     userID,
+		Namespace:  namespace,
+		Type:       memoryevent.TypeEvent,
+		EventDate:  createdAt,
+		Keywords:   item.Categories,
+		Summary:    item.Memory,
+		CreatedAt:  createdAt,
+		Importance: int(firstFloat(item.Metadata["importance"])),
+	}
+	if item.UserID != "" {
+		event.UserID = item.UserID
+	}
+	if t := firstString(item.Metadata["type"]); t == memoryevent.TypeMilestone || t == memoryevent.TypeEvent {
+		event.Type = t
+	}
+	if date := parseMem0Time(firstString(item.Metadata["event_date"])); !date.IsZero() {
+		event.EventDate = date
+	}
+	return event
+}
+
+// parseMem0Time parses the timestamps returned by hosted and OSS mem0, which
+// do not always carry a zone. It returns the zero time when raw is invalid.
+func parseMem0Time(raw string) time.Time {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,153 @@
+package mem0
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CoolBanHub/aggo/memory/memoryevent"
+)
+
+// fakeMem0 is a minimal in-memory stand-in for the mem0 memories API.
+type fakeMem0 struct {
+	mu       sync.Mutex
+	prefix   string
+	memories map[string]map[string]any
+	deleted  []string
+	queries  []string
+}
+
+func (f *fakeMem0) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, r.Method+" "+r.URL.String())
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, f.prefix), "/")
+	parts := strings.Split(rest, "/")
+	switch {
+	case rest == "search" && r.Method == http.MethodPost:
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		var results []any
+		for _, mem := range f.memories {
+			if strings.Contains(mem["memory"].(string), body["query"].(string)) {
+				results = append(results, mem)
+			}
+		}
+		writeJSON(w, results)
+	case rest == "" && r.Method == http.MethodGet:
+		var results []any
+		for _, mem := range f.memories {
+			if mem["user_id"] == r.URL.Query().Get("user_id") {
+				results = append(results, mem)
+			}
+		}
+		writeJSON(w, map[string]any{"results": results})
+	case rest == "" && r.Method == http.MethodDelete:
+		for id, mem := range f.memories {
+			if mem["user_id"] == r.URL.Query().Get("user_id") {
+				delete(f.memories, id)
+				f.deleted = append(f.deleted, id)
+			}
+		}
+		writeJSON(w, map[string]any{"message": "ok"})
+	case len(parts) == 2 && parts[1] == "history":
+		writeJSON(w, []any{map[string]any{"id": "h1", "memory_id": parts[0], "event": "add", "new_memory": "x"}})
+	case len(parts) == 1:
+		mem, ok := f.memories[parts[0]]
+		if !ok {
+			http.Error(w, `{"detail":"not found"}`, http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.memories, parts[0])
+			f.deleted = append(f.deleted, parts[0])
+			writeJSON(w, map[string]any{"message": "ok"})
+			return
+		}
+		writeJSON(w, mem)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestProviderUserMemoryEvents(t *testing.T) {
+	for _, tc := range []struct {
+		mode   Mode
+		prefix string
+	}{
+		{ModeHosted, "/v1/memories"},
+		{ModeOSS, "/memories"},
+	} {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			fake := &fakeMem0{prefix: tc.prefix, memories: map[string]map[string]any{
+				"m1": {"id": "m1", "memory": "喜欢爬山", "user_id": "u1", "categories": []any{"hobby"}, "created_at": "2026-01-01T08:00:00Z"},
+				"m2": {"id": "m2", "memory": "下周去上海出差", "user_id": "u1", "metadata": map[string]any{"type": "milestone"}, "created_at": "2026-03-01T08:00:00.123456"},
+				"m3": {"id": "m3", "memory": "喜欢游泳", "user_id": "u2", "created_at": "2026-02-01T08:00:00Z"},
+			}}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			provider, err := NewProvider(&ProviderConfig{BaseURL: server.URL, Mode: tc.mode})
+			if err != nil {
+				t.Fatalf("NewProvider: %v", err)
+			}
+			ctx := context.Background()
+
+			recent, err := provider.ListRecentUserMemoryEvents(ctx, "u1", 10)
+			if err != nil {
+				t.Fatalf("ListRecentUserMemoryEvents: %v", err)
+			}
+			if len(recent) != 2 || recent[0].ID != "m2" || recent[0].Type != memoryevent.TypeMilestone || recent[1].Keywords[0] != "hobby" {
+				t.Fatalf("recent events = %+v", recent)
+			}
+
+			found, err := provider.SearchUserMemoryEvents(ctx, &memoryevent.Query{UserID: "u1", Keywords: []string{"喜欢"}})
+			if err != nil {
+				t.Fatalf("SearchUserMemoryEvents: %v", err)
+			}
+			if len(found) != 1 || found[0].ID != "m1" {
+				t.Fatalf("search should only return the user's memories: %+v", found)
+			}
+
+			byID, err := provider.SearchUserMemoryEvents(ctx, &memoryevent.Query{UserID: "u1", IDs: []string{"m1", "m3", "missing"}})
+			if err != nil {
+				t.Fatalf("SearchUserMemoryEvents by id: %v", err)
+			}
+			if len(byID) != 1 || byID[0].ID != "m1" {
+				t.Fatalf("lookup by id = %+v", byID)
+			}
+
+			history, err := provider.UserMemoryHistory(ctx, "u1", "m1")
+			if err != nil || len(history) != 1 || history[0].Event != "ADD" {
+				t.Fatalf("UserMemoryHistory = %+v, %v", history, err)
+			}
+
+			if err := provider.DeleteUserMemoryEvent(ctx, "u1", "m3"); err == nil {
+				t.Fatal("deleting another user's memory should fail")
+			}
+			if err := provider.DeleteUserMemoryEvent(ctx, "u1", "m1"); err != nil {
+				t.Fatalf("DeleteUserMemoryEvent: %v", err)
+			}
+			if err := provider.DeleteAllUserMemories(ctx, "u1"); err != nil {
+				t.Fatalf("DeleteAllUserMemories: %v", err)
+			}
+			exported, err := provider.ExportUserMemories(ctx, "u1")
+			if err != nil || len(exported) != 0 {
+				t.Fatalf("ExportUserMemories after delete = %+v, %v", exported, err)
+			}
+			if _, ok := fake.memories["m3"]; !ok {
+				t.Fatal("other user's memory was deleted")
+			}
+		})
+	}
+}
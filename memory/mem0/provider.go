@@ -13,12 +13,16 @@ import (
 	"github.com/cloudwego/eino/schema"
 )
 
-var _ memory.MemoryProvider = (*Provider)(nil)
+var (
+	_ memory.MemoryProvider   = (*Provider)(nil)
+	_ memory.HookableProvider = (*Provider)(nil)
+)
 
 // Provider implements memory.MemoryProvider backed by a mem0-compatible API.
 type Provider struct {
 	client *Client
 	config *ProviderConfig
+	hooks  memory.Hooks
 }
 
 // NewProvider creates a mem0 provider with normalized config defaults.
@@ -36,6 +40,10 @@ func NewProvider(config *ProviderConfig) (*Provider, error) {
 
 // Retrieve fetches relevant mem0 context before a model call.
 func (p *Provider) Retrieve(ctx context.Context, req *memory.RetrieveRequest) (*memory.RetrieveResult, error) {
+	return p.hooks.WrapRetrieve(ctx, req, p.retrieve)
+}
+
+func (p *Provider) retrieve(ctx context.Context, req *memory.RetrieveRequest) (*memory.RetrieveResult, error) {
 	query := buildSearchQuery(req.Messages, p.config.SearchMsgLimit, p.config.QueryCharLimit)
 	if strings.TrimSpace(query) == "" {
 		return &memory.RetrieveResult{}, nil
@@ -44,7 +52,7 @@ func (p *Provider) Retrieve(ctx context.Context, req *memory.RetrieveRequest) (*
 	searchReq := SearchRequest{
 		Query:     query,
 		UserID:    req.UserID,
-		AgentID:   p.config.AgentID,
+		AgentID:   p.agentID(req.Namespace),
 		AppID:     p.config.AppID,
 		OrgID:     p.config.OrgID,
 		ProjectID: p.config.ProjectID,
@@ -73,15 +81,50 @@ func (p *Provider) Retrieve(ctx context.Context, req *memory.RetrieveRequest) (*
 		}
 	}
 
+	// Shared memories are stored in mem0 under their own user id, so each one
+	// is an extra search scoped to that id.
+	for _, sharedID := range req.SharedMemoryIDs {
+		sharedReq := searchReq
+		sharedReq.UserID = sharedID
+		sharedReq.AgentID = p.config.AgentID
+		sharedReq.RunID = ""
+		sharedItems, err := p.client.Search(ctx, sharedReq)
+		if err != nil {
+			log.Printf("mem0: Retrieve shared memory %s failed: %v", sharedID, err)
+			continue
+		}
+		sharedContext := FormatMemoryContext(sharedItems, p.config.OutputMemoryLimit)
+		if strings.TrimSpace(sharedContext) == "" {
+			continue
+		}
+		result.ContextMessages = append(result.ContextMessages,
+			schema.UserAgenticMessage(fmt.Sprintf("<shared_memory id=%q>\n%s\n</shared_memory>", sharedID, sharedContext)))
+	}
+
 	return result, nil
 }
 
+// agentID returns the mem0 agent id for a request: a memory namespace maps to
+// a mem0 agent, falling back to the configured AgentID.
+func (p *Provider) agentID(namespace string) string {
+	if namespace = strings.TrimSpace(namespace); namespace != "" {
+		return namespace
+	}
+	return p.config.AgentID
+}
+
 // Memorize persists the latest user + assistant turn into mem0.
 func (p *Provider) Memorize(ctx context.Context, req *memory.MemorizeRequest) error {
+	return p.hooks.WrapMemorize(ctx, req, p.memorize)
+}
+
+func (p *Provider) memorize(ctx context.Context, req *memory.MemorizeRequest) error {
 	var userText, assistantText string
 	for _, msg := range req.Messages {
 		content := strings.TrimSpace(agmsg.Text(msg))
-		if msg == nil || content == "" {
+		// Tool exchanges recorded by WithToolCallHistory are not conversation
+		// text for mem0.
+		if msg == nil || content == "" || agmsg.HasFunctionToolCall(msg) || agmsg.HasFunctionToolResult(msg) {
 			continue
 		}
 		switch msg.Role {
@@ -110,7 +153,7 @@ func (p *Provider) Memorize(ctx context.Context, req *memory.MemorizeRequest) er
 			{Role: "assistant", Content: assistantText},
 		},
 		UserID:    req.UserID,
-		AgentID:   p.config.AgentID,
+		AgentID:   p.agentID(req.Namespace),
 		AppID:     p.config.AppID,
 		OrgID:     p.config.OrgID,
 		ProjectID: p.config.ProjectID,
@@ -127,6 +170,13 @@ func (p *Provider) Memorize(ctx context.Context, req *memory.MemorizeRequest) er
 	return nil
 }
 
+// RegisterHook registers a lifecycle hook. Only the before/after
+// retrieve/memorize events fire; memories change server-side, so the change
+// events are never fired by this provider.
+func (p *Provider) RegisterHook(event memory.HookEvent, handler memory.HookHandler) {
+	p.hooks.RegisterHook(event, handler)
+}
+
 // Close releases resources held by the provider. Currently a no-op.
 func (p *Provider) Close() error {
 	return nil
@@ -217,6 +267,9 @@ func normalizeConfig(config *ProviderConfig) (*ProviderConfig, error) {
 	if strings.TrimSpace(cfg.SearchPath) == "" {
 		cfg.SearchPath = defaultSearchPath(cfg.Mode)
 	}
+	if strings.TrimSpace(cfg.MemoriesPath) == "" {
+		cfg.MemoriesPath = defaultMemoriesPath(cfg.Mode)
+	}
 	if strings.TrimSpace(cfg.Version) == "" && cfg.Mode == ModeHosted {
 		cfg.Version = defaultHostedVersion
 	}
@@ -247,6 +300,15 @@ func defaultSearchPath(mode Mode) string {
 	}
 }
 
+func defaultMemoriesPath(mode Mode) string {
+	switch mode {
+	case ModeOSS:
+		return "/memories"
+	default:
+		return "/v1/memories/"
+	}
+}
+
 func buildSearchQuery(messages []*schema.AgenticMessage, limit, maxChars int) string {
 	recent := recentConversationMessages(messages, limit)
 	if len(recent) == 0 {
@@ -1,7 +1,10 @@
 package mem0
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -37,7 +40,10 @@ type ProviderConfig struct {
 
 	AddPath    string
 	SearchPath string
-	Version    string
+	// MemoriesPath is the memory collection used by get-all, delete-all and,
+	// suffixed with a memory id, get/update/delete/history.
+	MemoriesPath string
+	Version      string
 
 	HTTPClient *http.Client
 	Timeout    time.Duration
@@ -98,10 +104,65 @@ type SearchItem struct {
 	Score      float64
 	Categories []string
 	Metadata   map[string]any
+	UserID     string
+	AgentID    string
 	CreatedAt  string
 	UpdatedAt  string
 }
 
+// ListRequest scopes get-all and delete-all calls.
+type ListRequest struct {
+	UserID    string
+	RunID     string
+	AgentID   string
+	AppID     string
+	OrgID     string
+	ProjectID string
+}
+
+func (r ListRequest) values() url.Values {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"user_id":    r.UserID,
+		"run_id":     r.RunID,
+		"agent_id":   r.AgentID,
+		"app_id":     r.AppID,
+		"org_id":     r.OrgID,
+		"project_id": r.ProjectID,
+	} {
+		if value = strings.TrimSpace(value); value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values
+}
+
+// HistoryItem is one change of a memory: Event is ADD, UPDATE or DELETE.
+type HistoryItem struct {
+	ID        string
+	MemoryID  string
+	OldMemory string
+	NewMemory string
+	Event     string
+	CreatedAt string
+	UpdatedAt string
+}
+
+// APIError is returned when a mem0 API answers with an error status.
+type APIError struct {
+	Path       string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s returned status %s", e.Path, e.Status)
+	}
+	return fmt.Sprintf("%s returned status %s: %s", e.Path, e.Status, e.Body)
+}
+
 type hostedAddRequest struct {
 	Messages  []ClientMessage `json:"messages"`
 	UserID    string          `json:"user_id,omitempty"`
@@ -132,6 +193,10 @@ type ossAddRequest struct {
 	Metadata  map[string]any  `json:"metadata,omitempty"`
 }
 
+type updateRequest struct {
+	Text string `json:"text"`
+}
+
 type ossSearchRequest struct {
 	Query     string `json:"query"`
 	UserID    string `json:"user_id,omitempty"`
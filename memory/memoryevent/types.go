@@ -29,6 +29,8 @@ type Event struct {
 	ID string `json:"id"`
 	// 用户 ID
 	UserID string `json:"userId"`
+	// 记忆命名空间（如 Agent ID），空为默认命名空间
+	Namespace string `json:"namespace,omitempty"`
 	// 事件类型 milestone / event
 	Type string `json:"type"`
 	// 事件发生日期（YYYY-MM-DD 起始的语义时间，不一定等于 CreatedAt）
@@ -39,12 +41,53 @@ type Event struct {
 	Summary string `json:"summary"`
 	// 入库时间
 	CreatedAt time.Time `json:"createdAt"`
+	// 重要程度 1~5，由 analyzer 抽取时给出；0 表示未评估，按 ImportanceDefault 处理
+	Importance int `json:"importance,omitempty"`
+	// 被检索命中的次数
+	AccessCount int `json:"accessCount,omitempty"`
+	// 最近一次被检索命中的时间
+	LastAccessedAt *time.Time `json:"lastAccessedAt,omitempty"`
+	// 取代该事件的事件 ID。非空表示事件已被整理合并或被更新的事实取代，
+	// 默认不再出现在最近事件与检索结果中，但保留原文以便追溯。
+	SupersededBy string `json:"supersededBy,omitempty"`
+	// 被取代的时间
+	SupersededAt *time.Time `json:"supersededAt,omitempty"`
+	// 来源事件 ID：由整理任务合并生成的事件记录其合并自哪些事件
+	Sources []string `json:"sources,omitempty"`
+}
+
+// Importance range.
+const (
+	ImportanceMin     = 1
+	ImportanceDefault = 3
+	ImportanceMax     = 5
+)
+
+// NormalizeImportance 把重要程度限制在 [ImportanceMin, ImportanceMax]，0 视为 ImportanceDefault
+func NormalizeImportance(v int) int {
+	switch {
+	case v == 0:
+		return ImportanceDefault
+	case v < ImportanceMin:
+		return ImportanceMin
+	case v > ImportanceMax:
+		return ImportanceMax
+	default:
+		return v
+	}
+}
+
+// Superseded 事件是否已被取代
+func (e *Event) Superseded() bool {
+	return e != nil && e.SupersededBy != ""
 }
 
 // Query 用户记忆事件检索条件
 type Query struct {
 	// 用户 ID（必填）
 	UserID string
+	// 只返回这些 ID 的事件，留空不限制
+	IDs []string
 	// 事件类型 milestone / event，留空匹配全部
 	Type string
 	// 关键词列表
@@ -56,4 +99,6 @@ type Query struct {
 	Until *time.Time
 	// 返回条数上限，<=0 由调用方按默认处理
 	Limit int
+	// 是否包含已被取代的事件，默认排除
+	IncludeSuperseded bool
 }
@@ -11,12 +11,16 @@ import (
 	"github.com/cloudwego/eino/schema"
 )
 
-var _ memory.MemoryProvider = (*Provider)(nil)
+var (
+	_ memory.MemoryProvider   = (*Provider)(nil)
+	_ memory.HookableProvider = (*Provider)(nil)
+)
 
 // Provider implements memory.MemoryProvider backed by the memu HTTP service.
 type Provider struct {
 	client *Client
 	config *ProviderConfig
+	hooks  memory.Hooks
 }
 
 // NewProvider creates a new memu Provider. It returns an error if config is nil
@@ -41,10 +45,22 @@ func NewProvider(config *ProviderConfig) (*Provider, error) {
 // Retrieve fetches relevant memory context from the memu service before a model call.
 // It gracefully degrades on error, returning an empty result.
 func (p *Provider) Retrieve(ctx context.Context, req *memory.RetrieveRequest) (*memory.RetrieveResult, error) {
+	return p.hooks.WrapRetrieve(ctx, req, p.retrieve)
+}
+
+func (p *Provider) retrieve(ctx context.Context, req *memory.RetrieveRequest) (*memory.RetrieveResult, error) {
 	memuReq := BuildRetrieveRequest(req.Messages, req.UserID, p.config.HistoryLimit)
 	if len(memuReq.Queries) == 0 {
 		return &memory.RetrieveResult{}, nil
 	}
+	// A memory namespace is scoped via memu's agent_id field. Shared memory
+	// (req.SharedMemoryIDs) is not supported by this provider.
+	if req.Namespace != "" {
+		if memuReq.Where == nil {
+			memuReq.Where = map[string]any{}
+		}
+		memuReq.Where["agent_id"] = req.Namespace
+	}
 
 	resp, err := p.client.Retrieve(ctx, memuReq)
 	if err != nil {
@@ -71,9 +87,15 @@ func (p *Provider) Retrieve(ctx context.Context, req *memory.RetrieveRequest) (*
 // Memorize persists a conversation turn to the memu service after a model call.
 // It extracts the user and assistant messages and sends them as a conversation turn.
 func (p *Provider) Memorize(ctx context.Context, req *memory.MemorizeRequest) error {
+	return p.hooks.WrapMemorize(ctx, req, p.memorize)
+}
+
+func (p *Provider) memorize(ctx context.Context, req *memory.MemorizeRequest) error {
 	var userText, assistantText string
 	for _, msg := range req.Messages {
-		if msg == nil {
+		// Tool exchanges recorded by WithToolCallHistory are not conversation
+		// text for memU.
+		if msg == nil || agmsg.HasFunctionToolCall(msg) || agmsg.HasFunctionToolResult(msg) {
 			continue
 		}
 		if msg.Role == schema.AgenticRoleTypeUser {
@@ -96,6 +118,12 @@ func (p *Provider) Memorize(ctx context.Context, req *memory.MemorizeRequest) er
 	if req.UserID != "" {
 		memReq.User = map[string]any{"user_id": req.UserID}
 	}
+	if req.Namespace != "" {
+		if memReq.User == nil {
+			memReq.User = map[string]any{}
+		}
+		memReq.User["agent_id"] = req.Namespace
+	}
 
 	_, err := p.client.Memorize(ctx, memReq)
 	if err != nil {
@@ -104,6 +132,13 @@ func (p *Provider) Memorize(ctx context.Context, req *memory.MemorizeRequest) er
 	return err
 }
 
+// RegisterHook registers a lifecycle hook. Only the before/after
+// retrieve/memorize events fire; memories change server-side, so the change
+// events are never fired by this provider.
+func (p *Provider) RegisterHook(event memory.HookEvent, handler memory.HookHandler) {
+	p.hooks.RegisterHook(event, handler)
+}
+
 // Close releases any resources held by the provider. Currently a no-op.
 func (p *Provider) Close() error {
 	return nil
@@ -12,8 +12,22 @@ import (
 	"github.com/cloudwego/eino/schema"
 )
 
+const (
+	// NamespaceSessionKey is the adk session value holding the memory
+	// namespace. It overrides WithNamespace for a single run, and the
+	// middleware writes the resolved namespace back so that memory tools
+	// operate on the same namespace.
+	NamespaceSessionKey = "memoryNamespace"
+	// SharedMemorySessionKey is the adk session value holding the shared
+	// memory IDs ([]string) for a single run; it overrides WithSharedMemory.
+	SharedMemorySessionKey = "sharedMemoryIDs"
+)
+
 const (
 	defaultMemorizeTimeout = 2 * time.Minute
+	// defaultToolResultMaxRunes caps each persisted tool result when tool
+	// call history is enabled without an explicit limit.
+	defaultToolResultMaxRunes = 2000
 	runtimeContextLayout   = "2006-01-02 15:04:05 -07:00"
 	runtimeContextDivider  = "\n\n-----\n"
 )
@@ -22,15 +36,84 @@ const (
 // It delegates to a MemoryProvider for retrieval and memorization.
 type MemoryMiddleware struct {
 	*adk.TypedBaseChatModelAgentMiddleware[*schema.AgenticMessage]
-	provider MemoryProvider
+	provider        MemoryProvider
+	namespace       string
+	sharedMemoryIDs []string
+
+	toolCallHistory    bool
+	toolResultMaxRunes int
+}
+
+// MiddlewareOption configures a MemoryMiddleware.
+type MiddlewareOption func(*MemoryMiddleware)
+
+// WithNamespace scopes the memory read and written by the middleware to
+// namespace, typically the agent ID. Agents with different namespaces keep
+// separate memories for the same user.
+func WithNamespace(namespace string) MiddlewareOption {
+	return func(m *MemoryMiddleware) {
+		m.namespace = strings.TrimSpace(namespace)
+	}
+}
+
+// WithSharedMemory retrieves the given shared (team / organization) memories
+// alongside the user's own memory on every run.
+func WithSharedMemory(ids ...string) MiddlewareOption {
+	return func(m *MemoryMiddleware) {
+		m.sharedMemoryIDs = append([]string(nil), ids...)
+	}
+}
+
+// WithToolCallHistory also memorizes the tool calls made during a turn and
+// their results, so the model still knows on later turns which tools it ran
+// and what they returned. Each result is truncated to maxResultRunes runes
+// (default 2000 when <= 0). Only providers that understand tool messages
+// persist them; the builtin provider replays them as function call / result
+// blocks in history, but keeps results out of memory analysis and summaries,
+// where a call is reduced to a one-line stub.
+func WithToolCallHistory(maxResultRunes int) MiddlewareOption {
+	return func(m *MemoryMiddleware) {
+		m.toolCallHistory = true
+		m.toolResultMaxRunes = maxResultRunes
+		if m.toolResultMaxRunes <= 0 {
+			m.toolResultMaxRunes = defaultToolResultMaxRunes
+		}
+	}
 }
 
 // NewMemoryMiddleware creates a MemoryMiddleware with a MemoryProvider.
-func NewMemoryMiddleware(provider MemoryProvider) *MemoryMiddleware {
-	return &MemoryMiddleware{
+func NewMemoryMiddleware(provider MemoryProvider, opts ...MiddlewareOption) *MemoryMiddleware {
+	m := &MemoryMiddleware{
 		TypedBaseChatModelAgentMiddleware: &adk.TypedBaseChatModelAgentMiddleware[*schema.AgenticMessage]{},
 		provider:                          provider,
 	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(m)
+		}
+	}
+	return m
+}
+
+// resolveNamespace returns the namespace for this run: the session value
+// when present, otherwise the configured one.
+func (m *MemoryMiddleware) resolveNamespace(ctx context.Context) string {
+	if value, ok := adk.GetSessionValue(ctx, NamespaceSessionKey); ok {
+		if namespace, ok := value.(string); ok {
+			return strings.TrimSpace(namespace)
+		}
+	}
+	return m.namespace
+}
+
+// resolveSharedMemoryIDs returns the shared memory IDs for this run.
+func (m *MemoryMiddleware) resolveSharedMemoryIDs(ctx context.Context) []string {
+	if value, ok := adk.GetSessionValue(ctx, SharedMemorySessionKey); ok {
+		if ids, ok := value.([]string); ok {
+			return ids
+		}
+	}
+	return m.sharedMemoryIDs
 }
 
 // BeforeAgent is called before the agent runs.
@@ -58,11 +141,18 @@ func (m *MemoryMiddleware) BeforeModelRewriteState(ctx context.Context, state *a
 		}
 	}
 
+	namespace := m.resolveNamespace(ctx)
+	if namespace != "" {
+		adk.AddSessionValue(ctx, NamespaceSessionKey, namespace)
+	}
+
 	// Call provider to retrieve context
 	result, err := m.provider.Retrieve(ctx, &RetrieveRequest{
-		UserID:    uid,
-		SessionID: sid,
-		Messages:  state.Messages,
+		UserID:          uid,
+		SessionID:       sid,
+		Namespace:       namespace,
+		SharedMemoryIDs: m.resolveSharedMemoryIDs(ctx),
+		Messages:        state.Messages,
 	})
 	if err != nil {
 		log.Printf("MemoryMiddleware: Retrieve failed: %v", err)
@@ -152,30 +242,39 @@ func (m *MemoryMiddleware) AfterModelRewriteState(ctx context.Context, state *ad
 			userMsg = originalMsg
 		}
 	}
-	if userMsg == nil {
-		for i := len(state.Messages) - 2; i >= 0; i-- {
-			if state.Messages[i] != nil && state.Messages[i].Role == schema.AgenticRoleTypeUser {
-				userMsg = state.Messages[i]
-				break
-			}
+	// Tool results are user-role messages too, so skip them when looking for
+	// the user message that started this turn.
+	turnStart := -1
+	for i := len(state.Messages) - 2; i >= 0; i-- {
+		if state.Messages[i] != nil && state.Messages[i].Role == schema.AgenticRoleTypeUser && !agmsg.HasFunctionToolResult(state.Messages[i]) {
+			turnStart = i
+			break
 		}
 	}
+	if userMsg == nil && turnStart >= 0 {
+		userMsg = state.Messages[turnStart]
+	}
 	assistantMsg := latestMsg
 
 	var messagesToMemorize []*schema.AgenticMessage
 	if userMsg != nil {
 		messagesToMemorize = append(messagesToMemorize, userMsg)
 	}
+	if m.toolCallHistory && turnStart >= 0 {
+		messagesToMemorize = append(messagesToMemorize, compactToolMessages(state.Messages[turnStart+1:len(state.Messages)-1], m.toolResultMaxRunes)...)
+	}
 	messagesToMemorize = append(messagesToMemorize, assistantMsg)
 
 	if len(messagesToMemorize) > 0 {
 		messagesToMemorize = append([]*schema.AgenticMessage(nil), messagesToMemorize...)
+		namespace := m.resolveNamespace(ctx)
 		go func() {
 			bgCtx, cancel := context.WithTimeout(context.Background(), defaultMemorizeTimeout)
 			defer cancel()
 			if err := m.provider.Memorize(bgCtx, &MemorizeRequest{
 				UserID:    uid,
 				SessionID: sid,
+				Namespace: namespace,
 				Messages:  messagesToMemorize,
 			}); err != nil {
 				log.Printf("MemoryMiddleware: Memorize failed: %v", err)
@@ -261,3 +360,62 @@ func hasCurrentTimeContext(text string) bool {
 func formatRuntimeCurrentTime(t time.Time) string {
 	return t.Format(runtimeContextLayout)
 }
+
+// toolResultTruncatedMarker prefixes the note appended to tool results cut
+// down by compactToolMessages.
+const toolResultTruncatedMarker = "\n...[truncated, "
+
+// compactToolMessages keeps the tool calls and tool results of a turn in a
+// form worth persisting: reasoning and other blocks are dropped, and each
+// result text is truncated to maxResultRunes runes.
+func compactToolMessages(messages []*schema.AgenticMessage, maxResultRunes int) []*schema.AgenticMessage {
+	var compacted []*schema.AgenticMessage
+	for _, msg := range messages {
+		if msg == nil {
+			continue
+		}
+		var blocks []*schema.ContentBlock
+		switch {
+		case msg.Role == schema.AgenticRoleTypeAssistant && agmsg.HasFunctionToolCall(msg):
+			for _, block := range msg.ContentBlocks {
+				if block == nil {
+					continue
+				}
+				if block.AssistantGenText != nil && strings.TrimSpace(block.AssistantGenText.Text) != "" {
+					blocks = append(blocks, schema.NewContentBlock(&schema.AssistantGenText{Text: block.AssistantGenText.Text}))
+				}
+				if block.FunctionToolCall != nil {
+					call := *block.FunctionToolCall
+					blocks = append(blocks, schema.NewContentBlock(&call))
+				}
+			}
+		case msg.Role == schema.AgenticRoleTypeUser && agmsg.HasFunctionToolResult(msg):
+			for _, block := range msg.ContentBlocks {
+				if block == nil || block.FunctionToolResult == nil {
+					continue
+				}
+				text := agmsg.Text(&schema.AgenticMessage{ContentBlocks: []*schema.ContentBlock{block}})
+				blocks = append(blocks, schema.NewContentBlock(&schema.FunctionToolResult{
+					CallID: block.FunctionToolResult.CallID,
+					Name:   block.FunctionToolResult.Name,
+					Content: []*schema.FunctionToolResultContentBlock{{
+						Type: schema.FunctionToolResultContentBlockTypeText,
+						Text: &schema.UserInputText{Text: truncateToolResult(text, maxResultRunes)},
+					}},
+				}))
+			}
+		}
+		if len(blocks) > 0 {
+			compacted = append(compacted, &schema.AgenticMessage{Role: msg.Role, ContentBlocks: blocks})
+		}
+	}
+	return compacted
+}
+
+func truncateToolResult(text string, maxRunes int) string {
+	runes := []rune(text)
+	if maxRunes <= 0 || len(runes) <= maxRunes {
+		return text
+	}
+	return string(runes[:maxRunes]) + fmt.Sprintf("%s%d chars total]", toolResultTruncatedMarker, len(runes))
+}
@@ -28,6 +28,15 @@ type RetrieveRequest struct {
 	// SessionID identifies the current conversation session.
 	SessionID string
 
+	// Namespace scopes the user's memory, typically to an agent ID, so that
+	// agents serving the same user keep separate memories. Empty is the
+	// default namespace shared by agents that do not set one.
+	Namespace string
+
+	// SharedMemoryIDs identify shared (team / organization) memories that are
+	// retrieved read-only alongside the user's own memory.
+	SharedMemoryIDs []string
+
 	// Messages are the current conversation messages used as context for retrieval.
 	Messages []*schema.AgenticMessage
 
@@ -62,6 +71,9 @@ type MemorizeRequest struct {
 	// SessionID identifies the conversation session.
 	SessionID string
 
+	// Namespace scopes the stored memory; see RetrieveRequest.Namespace.
+	Namespace string
+
 	// Messages are the conversation turn(s) to store.
 	Messages []*schema.AgenticMessage
 }
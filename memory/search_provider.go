@@ -3,10 +3,13 @@ package memory
 import (
 	"context"
 
+	"github.com/CoolBanHub/aggo/memory/builtin"
 	"github.com/CoolBanHub/aggo/memory/builtin/search"
 	"github.com/CoolBanHub/aggo/memory/memoryevent"
 )
 
+// SearchableProvider 支持检索对话原文的 provider，search_conversation_history 工具依赖该接口。
+// SearchQuery.SessionID 为空时应跨会话检索该用户的消息。
 type SearchableProvider interface {
 	MemoryProvider
 	SearchMessages(ctx context.Context, q *search.SearchQuery) ([]*search.SearchHit, error)
@@ -21,3 +24,27 @@ type UserMemoryEventSearcher interface {
 	SearchUserMemoryEvents(ctx context.Context, query *memoryevent.Query) ([]*memoryevent.Event, error)
 	ListRecentUserMemoryEvents(ctx context.Context, userID string, limit int) ([]*memoryevent.Event, error)
 }
+
+// UserMemoryEditor 让 remember/update/forget_user_memory 工具可以显式修改用户的长期记忆，
+// 修改立即生效，不等待 analyzer 的防抖队列。builtin provider 实现了该接口。
+//
+// 所有方法都以 userID 为作用域，实现侧不得修改其他用户的数据。
+// 文档类方法返回的 *builtin.UserMemoryRevision 在存储不支持版本历史或内容无变化时为 nil。
+type UserMemoryEditor interface {
+	// RememberUserMemoryFact 在记忆文档的 section 章节下追加一条事实，section 为空时写入默认章节；
+	// 文档中已有相同事实时 added 为 false
+	RememberUserMemoryFact(ctx context.Context, userID, section, fact string) (revision *builtin.UserMemoryRevision, added bool, err error)
+	// ReplaceUserMemoryText 替换记忆文档中的文本，返回替换次数
+	ReplaceUserMemoryText(ctx context.Context, userID, oldText, newText string) (int, error)
+	// ForgetUserMemoryText 删除记忆文档中包含 text 的行，返回删除行数
+	ForgetUserMemoryText(ctx context.Context, userID, text string) (int, error)
+	// ForgetUserMemorySection 删除记忆文档中的整个章节
+	ForgetUserMemorySection(ctx context.Context, userID, section string) (bool, error)
+
+	// SaveUserMemoryEvent 新增一条事件，event.UserID 必填
+	SaveUserMemoryEvent(ctx context.Context, event *memoryevent.Event) error
+	// CorrectUserMemoryEvent 用 corrected 中的非零字段更正事件，返回取代原事件的新事件
+	CorrectUserMemoryEvent(ctx context.Context, userID, eventID string, corrected *memoryevent.Event) (*memoryevent.Event, error)
+	// DeleteUserMemoryEvent 删除事件，事件不存在时返回 builtin.ErrUserMemoryEventNotFound
+	DeleteUserMemoryEvent(ctx context.Context, userID, eventID string) error
+}
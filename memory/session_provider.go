@@ -0,0 +1,25 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+)
+
+// SessionManager is an optional extension of MemoryProvider for providers that
+// keep conversation sessions and can list and manage them, e.g. to render a
+// chat sidebar. The builtin provider implements it when its storage implements
+// builtin.SessionStorage.
+type SessionManager interface {
+	// ListSessions returns the user's sessions ordered by last activity.
+	ListSessions(ctx context.Context, query *builtin.SessionQuery) ([]*builtin.Session, error)
+	// GetSession returns a session, or nil if it does not exist.
+	GetSession(ctx context.Context, sessionID, userID string) (*builtin.Session, error)
+	// RenameSession sets the session title; an empty title clears it.
+	RenameSession(ctx context.Context, sessionID, userID, title string) (*builtin.Session, error)
+	// ArchiveSession archives or unarchives a session.
+	ArchiveSession(ctx context.Context, sessionID, userID string, archived bool) (*builtin.Session, error)
+	// DeleteSession deletes a session with its messages, summary and search
+	// index entries.
+	DeleteSession(ctx context.Context, sessionID, userID string) error
+}
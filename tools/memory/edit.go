@@ -0,0 +1,257 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/CoolBanHub/aggo/memory"
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/memoryevent"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+// 记忆编辑工具只作用于 adk session 中的 userID，不接受 user_id 参数，
+// 防止模型被诱导修改其他用户的记忆。
+
+// RememberUserMemoryParams 是 remember_user_memory 工具参数。
+type RememberUserMemoryParams struct {
+	Kind       string   `json:"kind,omitempty" jsonschema:"description=记忆类型：fact=长期事实/偏好/约定，写入记忆文档；event=带日期的事件或里程碑，写入事件记录。默认 fact,enum=fact,enum=event"`
+	Content    string   `json:"content" jsonschema:"description=要记住的内容，一句精简的事实陈述，例如 '收货地址：上海市徐汇区XX路1号'"`
+	Section    string   `json:"section,omitempty" jsonschema:"description=kind=fact 时写入的记忆文档章节，例如 核心约定/基础信息。默认 基础信息"`
+	Type       string   `json:"type,omitempty" jsonschema:"description=kind=event 时的事件类型：milestone(任务里程碑) / event(事件记录)。默认 event,enum=milestone,enum=event"`
+	Date       string   `json:"date,omitempty" jsonschema:"description=kind=event 时的事件日期，格式 YYYY-MM-DD 或 RFC3339。默认今天"`
+	Keywords   []string `json:"keywords,omitempty" jsonschema:"description=kind=event 时的检索关键词"`
+	Importance int      `json:"importance,omitempty" jsonschema:"description=kind=event 时的重要程度 1~5，默认 3"`
+}
+
+// UpdateUserMemoryParams 是 update_user_memory 工具参数。
+type UpdateUserMemoryParams struct {
+	EventID    string   `json:"event_id,omitempty" jsonschema:"description=要更正的事件 ID（来自 search_user_memory 的结果）。与 old_text 二选一"`
+	Summary    string   `json:"summary,omitempty" jsonschema:"description=更正后的事件内容，留空保持不变"`
+	Type       string   `json:"type,omitempty" jsonschema:"description=更正后的事件类型，留空保持不变,enum=milestone,enum=event"`
+	Date       string   `json:"date,omitempty" jsonschema:"description=更正后的事件日期，格式 YYYY-MM-DD，留空保持不变"`
+	Keywords   []string `json:"keywords,omitempty" jsonschema:"description=更正后的关键词，留空保持不变"`
+	Importance int      `json:"importance,omitempty" jsonschema:"description=更正后的重要程度 1~5，留空保持不变"`
+	OldText    string   `json:"old_text,omitempty" jsonschema:"description=记忆文档中需要更正的原文，必须与文档内容完全一致"`
+	NewText    string   `json:"new_text,omitempty" jsonschema:"description=替换 old_text 的新内容"`
+}
+
+// ForgetUserMemoryParams 是 forget_user_memory 工具参数。
+type ForgetUserMemoryParams struct {
+	EventID string `json:"event_id,omitempty" jsonschema:"description=要删除的事件 ID（来自 search_user_memory 的结果）"`
+	Text    string `json:"text,omitempty" jsonschema:"description=删除记忆文档中包含该文本的所有条目，例如 旧地址"`
+	Section string `json:"section,omitempty" jsonschema:"description=删除记忆文档中的整个章节，例如 事件记录"`
+}
+
+// EditUserMemoryResult 是记忆编辑工具的返回值。
+type EditUserMemoryResult struct {
+	// 是否产生了修改
+	Changed bool `json:"changed"`
+	// 结果说明
+	Message string `json:"message"`
+	// 新增或更正后的事件 ID
+	EventID string `json:"event_id,omitempty"`
+	// 记忆文档的新版本 ID，可用于回滚
+	RevisionID string `json:"revision_id,omitempty"`
+}
+
+// RememberUserMemoryTool 构造 remember_user_memory 工具，让 Agent 在用户明确要求时立即记住一条事实或事件。
+func RememberUserMemoryTool(editor memory.UserMemoryEditor) (tool.BaseTool, error) {
+	if editor == nil {
+		return nil, errors.New("editor 不能为空")
+	}
+
+	name := "remember_user_memory"
+	desc := "立即把一条信息写入当前用户的长期记忆。" +
+		"仅在用户明确要求记住，或主动告知需要长期保留的个人信息、偏好、约定时调用。" +
+		"长期事实用 kind=fact，带日期的事件/里程碑用 kind=event。"
+
+	return utils.InferTool(name, desc, func(ctx context.Context, params RememberUserMemoryParams) (interface{}, error) {
+		userID, err := sessionUserID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return rememberUserMemory(namespaceContext(ctx), editor, userID, params)
+	})
+}
+
+// UpdateUserMemoryTool 构造 update_user_memory 工具，用于更正已记住的事件或记忆文档中的内容。
+func UpdateUserMemoryTool(editor memory.UserMemoryEditor) (tool.BaseTool, error) {
+	if editor == nil {
+		return nil, errors.New("editor 不能为空")
+	}
+
+	name := "update_user_memory"
+	desc := "更正当前用户长期记忆中过时或错误的内容。" +
+		"更正事件时传 event_id 与需要修改的字段；更正记忆文档时传 old_text 与 new_text。"
+
+	return utils.InferTool(name, desc, func(ctx context.Context, params UpdateUserMemoryParams) (interface{}, error) {
+		userID, err := sessionUserID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return updateUserMemory(namespaceContext(ctx), editor, userID, params)
+	})
+}
+
+// ForgetUserMemoryTool 构造 forget_user_memory 工具，在用户要求遗忘时立即删除对应记忆。
+func ForgetUserMemoryTool(editor memory.UserMemoryEditor) (tool.BaseTool, error) {
+	if editor == nil {
+		return nil, errors.New("editor 不能为空")
+	}
+
+	name := "forget_user_memory"
+	desc := "按用户要求删除当前用户的长期记忆，删除立即生效。" +
+		"删除事件传 event_id；删除记忆文档中的条目传 text；删除整个章节传 section。" +
+		"仅在用户明确要求遗忘或信息已失效时调用。"
+
+	return utils.InferTool(name, desc, func(ctx context.Context, params ForgetUserMemoryParams) (interface{}, error) {
+		userID, err := sessionUserID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return forgetUserMemory(namespaceContext(ctx), editor, userID, params)
+	})
+}
+
+// UserMemoryEditTools 返回 remember/update/forget_user_memory 三个工具。
+func UserMemoryEditTools(editor memory.UserMemoryEditor) ([]tool.BaseTool, error) {
+	builders := []func(memory.UserMemoryEditor) (tool.BaseTool, error){
+		RememberUserMemoryTool,
+		UpdateUserMemoryTool,
+		ForgetUserMemoryTool,
+	}
+	tools := make([]tool.BaseTool, 0, len(builders))
+	for _, build := range builders {
+		t, err := build(editor)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, t)
+	}
+	return tools, nil
+}
+
+func rememberUserMemory(ctx context.Context, editor memory.UserMemoryEditor, userID string, params RememberUserMemoryParams) (*EditUserMemoryResult, error) {
+	content := strings.TrimSpace(params.Content)
+	if content == "" {
+		return nil, errors.New("content 不能为空")
+	}
+
+	switch strings.TrimSpace(params.Kind) {
+	case "", "fact":
+		revision, added, err := editor.RememberUserMemoryFact(ctx, userID, params.Section, content)
+		if err != nil {
+			return nil, fmt.Errorf("写入记忆失败: %w", err)
+		}
+		if !added {
+			return &EditUserMemoryResult{Message: "记忆中已存在该内容，未做修改"}, nil
+		}
+		result := &EditUserMemoryResult{Changed: true, Message: "已记住"}
+		if revision != nil {
+			result.RevisionID = revision.ID
+		}
+		return result, nil
+	case "event":
+		evt := &memoryevent.Event{
+			UserID:     userID,
+			Type:       strings.TrimSpace(params.Type),
+			Summary:    content,
+			Keywords:   params.Keywords,
+			Importance: params.Importance,
+		}
+		if evt.Type == "" {
+			evt.Type = memoryevent.TypeEvent
+		}
+		if evt.Importance != 0 {
+			evt.Importance = memoryevent.NormalizeImportance(evt.Importance)
+		}
+		if date, ok := parseToolDate(params.Date); ok {
+			evt.EventDate = date
+		}
+		if err := editor.SaveUserMemoryEvent(ctx, evt); err != nil {
+			return nil, fmt.Errorf("写入事件失败: %w", err)
+		}
+		return &EditUserMemoryResult{Changed: true, Message: "已记录事件", EventID: evt.ID}, nil
+	default:
+		return nil, fmt.Errorf("不支持的 kind: %s", params.Kind)
+	}
+}
+
+func updateUserMemory(ctx context.Context, editor memory.UserMemoryEditor, userID string, params UpdateUserMemoryParams) (*EditUserMemoryResult, error) {
+	if eventID := strings.TrimSpace(params.EventID); eventID != "" {
+		corrected := &memoryevent.Event{
+			Type:     strings.TrimSpace(params.Type),
+			Summary:  strings.TrimSpace(params.Summary),
+			Keywords: params.Keywords,
+		}
+		if params.Importance != 0 {
+			corrected.Importance = memoryevent.NormalizeImportance(params.Importance)
+		}
+		if date, ok := parseToolDate(params.Date); ok {
+			corrected.EventDate = date
+		}
+		evt, err := editor.CorrectUserMemoryEvent(ctx, userID, eventID, corrected)
+		if err != nil {
+			return nil, fmt.Errorf("更正事件失败: %w", err)
+		}
+		return &EditUserMemoryResult{Changed: true, Message: "已更正事件", EventID: evt.ID}, nil
+	}
+
+	if params.OldText == "" {
+		return nil, errors.New("请传入 event_id 或 old_text")
+	}
+	count, err := editor.ReplaceUserMemoryText(ctx, userID, params.OldText, params.NewText)
+	if err != nil {
+		return nil, fmt.Errorf("更正记忆失败: %w", err)
+	}
+	if count == 0 {
+		return &EditUserMemoryResult{Message: "记忆中未找到 old_text，未做修改"}, nil
+	}
+	return &EditUserMemoryResult{Changed: true, Message: fmt.Sprintf("已更正 %d 处", count)}, nil
+}
+
+func forgetUserMemory(ctx context.Context, editor memory.UserMemoryEditor, userID string, params ForgetUserMemoryParams) (*EditUserMemoryResult, error) {
+	switch {
+	case strings.TrimSpace(params.EventID) != "":
+		err := editor.DeleteUserMemoryEvent(ctx, userID, strings.TrimSpace(params.EventID))
+		if errors.Is(err, builtin.ErrUserMemoryEventNotFound) {
+			return &EditUserMemoryResult{Message: "未找到该事件，未做修改"}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("删除事件失败: %w", err)
+		}
+		return &EditUserMemoryResult{Changed: true, Message: "已删除事件"}, nil
+	case strings.TrimSpace(params.Section) != "":
+		found, err := editor.ForgetUserMemorySection(ctx, userID, params.Section)
+		if err != nil {
+			return nil, fmt.Errorf("删除章节失败: %w", err)
+		}
+		if !found {
+			return &EditUserMemoryResult{Message: "记忆中没有该章节，未做修改"}, nil
+		}
+		return &EditUserMemoryResult{Changed: true, Message: "已删除章节"}, nil
+	case strings.TrimSpace(params.Text) != "":
+		count, err := editor.ForgetUserMemoryText(ctx, userID, params.Text)
+		if err != nil {
+			return nil, fmt.Errorf("删除记忆失败: %w", err)
+		}
+		if count == 0 {
+			return &EditUserMemoryResult{Message: "记忆中未找到相关内容，未做修改"}, nil
+		}
+		return &EditUserMemoryResult{Changed: true, Message: fmt.Sprintf("已删除 %d 条", count)}, nil
+	default:
+		return nil, errors.New("请传入 event_id、text 或 section 之一")
+	}
+}
+
+func sessionUserID(ctx context.Context) (string, error) {
+	userID := sessionString(ctx, "userID")
+	if userID == "" {
+		return "", errors.New("无法确定 userID，请确保 adk session 中存在 userID")
+	}
+	return userID, nil
+}
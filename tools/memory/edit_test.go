@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/CoolBanHub/aggo/memory/builtin"
+	"github.com/CoolBanHub/aggo/memory/memoryevent"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+type recordingEditor struct {
+	userIDs []string
+	events  []*memoryevent.Event
+	facts   map[string]bool
+}
+
+func (e *recordingEditor) RememberUserMemoryFact(_ context.Context, userID, _, fact string) (*builtin.UserMemoryRevision, bool, error) {
+	e.userIDs = append(e.userIDs, userID)
+	if e.facts[fact] {
+		return nil, false, nil
+	}
+	if e.facts == nil {
+		e.facts = make(map[string]bool)
+	}
+	e.facts[fact] = true
+	return &builtin.UserMemoryRevision{ID: "r1"}, true, nil
+}
+
+func (e *recordingEditor) ReplaceUserMemoryText(_ context.Context, userID, _, _ string) (int, error) {
+	e.userIDs = append(e.userIDs, userID)
+	return 0, nil
+}
+
+func (e *recordingEditor) ForgetUserMemoryText(_ context.Context, userID, _ string) (int, error) {
+	e.userIDs = append(e.userIDs, userID)
+	return 1, nil
+}
+
+func (e *recordingEditor) ForgetUserMemorySection(_ context.Context, userID, _ string) (bool, error) {
+	e.userIDs = append(e.userIDs, userID)
+	return false, nil
+}
+
+func (e *recordingEditor) SaveUserMemoryEvent(_ context.Context, event *memoryevent.Event) error {
+	e.userIDs = append(e.userIDs, event.UserID)
+	e.events = append(e.events, event)
+	return nil
+}
+
+func (e *recordingEditor) CorrectUserMemoryEvent(_ context.Context, userID, _ string, corrected *memoryevent.Event) (*memoryevent.Event, error) {
+	e.userIDs = append(e.userIDs, userID)
+	return &memoryevent.Event{ID: "new", UserID: userID, Summary: corrected.Summary}, nil
+}
+
+func (e *recordingEditor) DeleteUserMemoryEvent(_ context.Context, userID, eventID string) error {
+	e.userIDs = append(e.userIDs, userID)
+	if eventID != "e1" {
+		return fmt.Errorf("%w: %s", builtin.ErrUserMemoryEventNotFound, eventID)
+	}
+	return nil
+}
+
+func TestUserMemoryEditToolsRequireSessionUserID(t *testing.T) {
+	editor := &recordingEditor{}
+	tools, err := UserMemoryEditTools(editor)
+	if err != nil {
+		t.Fatalf("build tools err: %v", err)
+	}
+	for _, bt := range tools {
+		run, ok := bt.(tool.InvokableTool)
+		if !ok {
+			t.Fatalf("tool is not invokable: %T", bt)
+		}
+		if _, err := run.InvokableRun(context.Background(), `{"content":"x","text":"x"}`); err == nil {
+			t.Fatal("expected error without session userID")
+		}
+	}
+	if len(editor.userIDs) != 0 {
+		t.Fatalf("editor should not be called: %v", editor.userIDs)
+	}
+}
+
+func TestUserMemoryEditDispatch(t *testing.T) {
+	ctx := context.Background()
+	editor := &recordingEditor{}
+
+	res, err := rememberUserMemory(ctx, editor, "u1", RememberUserMemoryParams{Kind: "event", Content: "完成迁移", Date: "2025-03-20", Importance: 9})
+	if err != nil || !res.Changed {
+		t.Fatalf("remember event res=%+v err=%v", res, err)
+	}
+	evt := editor.events[0]
+	if evt.UserID != "u1" || evt.Type != memoryevent.TypeEvent || evt.Importance != memoryevent.ImportanceMax || evt.EventDate.Format("2006-01-02") != "2025-03-20" {
+		t.Fatalf("unexpected event: %+v", evt)
+	}
+
+	if res, err := rememberUserMemory(ctx, editor, "u1", RememberUserMemoryParams{Content: "不吃辣"}); err != nil || !res.Changed || res.RevisionID != "r1" {
+		t.Fatalf("remember fact res=%+v err=%v", res, err)
+	}
+	if res, err := rememberUserMemory(ctx, editor, "u1", RememberUserMemoryParams{Content: "不吃辣"}); err != nil || res.Changed {
+		t.Fatalf("remembering an existing fact should not report a change, res=%+v err=%v", res, err)
+	}
+	if res, err := forgetUserMemory(ctx, editor, "u1", ForgetUserMemoryParams{EventID: "e1"}); err != nil || !res.Changed {
+		t.Fatalf("forget event res=%+v err=%v", res, err)
+	}
+	if res, err := forgetUserMemory(ctx, editor, "u1", ForgetUserMemoryParams{EventID: "missing"}); err != nil || res.Changed {
+		t.Fatalf("forgetting a missing event should not report a change, res=%+v err=%v", res, err)
+	}
+	if _, err := rememberUserMemory(ctx, editor, "u1", RememberUserMemoryParams{Kind: "other", Content: "x"}); err == nil {
+		t.Fatal("expected unsupported kind error")
+	}
+	if _, err := updateUserMemory(ctx, editor, "u1", UpdateUserMemoryParams{}); err == nil {
+		t.Fatal("expected error without event_id or old_text")
+	}
+	if res, err := updateUserMemory(ctx, editor, "u1", UpdateUserMemoryParams{OldText: "a", NewText: "b"}); err != nil || res.Changed {
+		t.Fatalf("replace without match res=%+v err=%v", res, err)
+	}
+	if _, err := forgetUserMemory(ctx, editor, "u1", ForgetUserMemoryParams{}); err == nil {
+		t.Fatal("expected error without target")
+	}
+	if res, err := forgetUserMemory(ctx, editor, "u1", ForgetUserMemoryParams{Text: "旧地址"}); err != nil || !res.Changed {
+		t.Fatalf("forget text res=%+v err=%v", res, err)
+	}
+	for _, id := range editor.userIDs {
+		if id != "u1" {
+			t.Fatalf("editor called with unexpected userID %q", id)
+		}
+	}
+}
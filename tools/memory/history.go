@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory"
+	"github.com/CoolBanHub/aggo/memory/builtin/search"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+const (
+	// 片段为空时（如向量检索命中）截取正文的字数
+	maxHistorySnippetRunes = 160
+	// 检索范围：当前会话 / 该用户的全部会话
+	historyScopeSession = "session"
+	historyScopeAll     = "all"
+)
+
+// SearchConversationHistoryParams 是 search_conversation_history 工具参数。
+type SearchConversationHistoryParams struct {
+	Query    string   `json:"query,omitempty" jsonschema:"description=要查找的内容，用一句自然语言描述，例如 '上次讨论的部署方案'。向量/混合检索模式下按语义匹配"`
+	Keywords []string `json:"keywords,omitempty" jsonschema:"description=关键词列表，未传时从 query 中提取。建议传 1~5 个具体关键词"`
+	Match    string   `json:"match,omitempty" jsonschema:"description=关键词匹配方式：any=任意命中即可，all=必须全部命中。默认 any,enum=any,enum=all"`
+	Role     string   `json:"role,omitempty" jsonschema:"description=只检索该角色的消息：user / assistant。留空匹配全部,enum=user,enum=assistant"`
+	Since    string   `json:"since,omitempty" jsonschema:"description=起始时间，格式 YYYY-MM-DD 或 RFC3339。仅返回此后的消息"`
+	Until    string   `json:"until,omitempty" jsonschema:"description=结束时间，格式 YYYY-MM-DD 或 RFC3339。仅返回此前的消息"`
+	Scope    string   `json:"scope,omitempty" jsonschema:"description=检索范围：session=当前会话，all=该用户的全部会话。默认 session,enum=session,enum=all"`
+	Limit    int      `json:"limit,omitempty" jsonschema:"description=返回上限，默认 5，最大 20"`
+}
+
+// SearchConversationHistoryResultItem 是 search_conversation_history 工具返回的单条消息。
+type SearchConversationHistoryResultItem struct {
+	MessageID string  `json:"message_id"`
+	SessionID string  `json:"session_id"`
+	Role      string  `json:"role"`
+	Time      string  `json:"time"`
+	Snippet   string  `json:"snippet"`
+	Score     float64 `json:"score"`
+}
+
+// SearchConversationHistoryResult 是 search_conversation_history 工具返回值。
+type SearchConversationHistoryResult struct {
+	Total    int                                   `json:"total"`
+	Messages []SearchConversationHistoryResultItem `json:"messages"`
+}
+
+// SearchConversationHistoryTool 构造 search_conversation_history 工具。
+//
+//	provider: 实现了 SearchableProvider 接口的对象（一般直接传 memory provider）。
+//
+// 工具从 adk.SessionValues 取 userID 与 sessionID，只能检索当前用户的对话。
+func SearchConversationHistoryTool(provider memory.SearchableProvider) (tool.BaseTool, error) {
+	if provider == nil {
+		return nil, errors.New("provider 不能为空")
+	}
+
+	name := "search_conversation_history"
+	desc := "检索当前用户过往的对话原文。" +
+		"上下文只包含最近若干条消息，需要回忆更早聊过的具体内容（方案、数字、链接、原话）时调用。" +
+		"默认只查当前会话，scope=all 时查该用户的全部会话。"
+
+	return utils.InferTool(name, desc, func(ctx context.Context, params SearchConversationHistoryParams) (interface{}, error) {
+		return searchConversationHistory(ctx, provider, sessionString(ctx, "userID"), sessionString(ctx, "sessionID"), params)
+	})
+}
+
+func searchConversationHistory(ctx context.Context, provider memory.SearchableProvider, userID, sessionID string, params SearchConversationHistoryParams) (*SearchConversationHistoryResult, error) {
+	if userID == "" {
+		return nil, errors.New("无法确定 userID，请确保 adk session 中存在 userID")
+	}
+
+	query := &search.SearchQuery{
+		UserID:   userID,
+		Role:     strings.TrimSpace(params.Role),
+		Keywords: params.Keywords,
+		Match:    strings.TrimSpace(params.Match),
+		Query:    strings.TrimSpace(params.Query),
+		Limit:    clampLimit(params.Limit, 5, 20),
+	}
+	if query.Query == "" && len(query.Keywords) == 0 {
+		return nil, errors.New("query 与 keywords 不能同时为空")
+	}
+
+	switch strings.TrimSpace(params.Scope) {
+	case "", historyScopeSession:
+		if sessionID == "" {
+			return nil, errors.New("无法确定 sessionID，请使用 scope=all 或确保 adk session 中存在 sessionID")
+		}
+		query.SessionID = sessionID
+	case historyScopeAll:
+	default:
+		return nil, fmt.Errorf("不支持的 scope: %s", params.Scope)
+	}
+
+	if since, ok := parseToolDate(params.Since); ok {
+		query.Since = &since
+	}
+	if until, ok := parseToolDate(params.Until); ok {
+		query.Until = &until
+	}
+
+	hits, err := provider.SearchMessages(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("检索失败: %w", err)
+	}
+
+	result := &SearchConversationHistoryResult{
+		Messages: make([]SearchConversationHistoryResultItem, 0, len(hits)),
+	}
+	for _, hit := range hits {
+		if hit == nil || hit.Message == nil {
+			continue
+		}
+		msg := hit.Message
+		snippet := strings.TrimSpace(hit.Snippet)
+		if snippet == "" {
+			snippet = strings.TrimSpace(search.SearchText(msg))
+			if r := []rune(snippet); len(r) > maxHistorySnippetRunes {
+				snippet = string(r[:maxHistorySnippetRunes]) + "…"
+			}
+		}
+		item := SearchConversationHistoryResultItem{
+			MessageID: msg.ID,
+			SessionID: msg.SessionID,
+			Role:      msg.Role,
+			Snippet:   snippet,
+			Score:     hit.Score,
+		}
+		if !msg.CreatedAt.IsZero() {
+			item.Time = msg.CreatedAt.Format(time.RFC3339)
+		}
+		result.Messages = append(result.Messages, item)
+	}
+	result.Total = len(result.Messages)
+	return result, nil
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CoolBanHub/aggo/memory"
+	"github.com/CoolBanHub/aggo/memory/builtin/search"
+)
+
+type recordingSearchProvider struct {
+	memory.MemoryProvider
+	queries []*search.SearchQuery
+	hits    []*search.SearchHit
+}
+
+func (p *recordingSearchProvider) SearchMessages(_ context.Context, q *search.SearchQuery) ([]*search.SearchHit, error) {
+	p.queries = append(p.queries, q)
+	return p.hits, nil
+}
+
+func TestSearchConversationHistoryScope(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2025, 3, 20, 10, 0, 0, 0, time.UTC)
+	provider := &recordingSearchProvider{hits: []*search.SearchHit{{
+		Message: &search.Message{ID: "m1", SessionID: "s0", Role: "user", Content: strings.Repeat("长", 200), CreatedAt: createdAt},
+		Score:   0.5,
+	}}}
+
+	if _, err := searchConversationHistory(ctx, provider, "", "s1", SearchConversationHistoryParams{Query: "部署"}); err == nil {
+		t.Fatal("expected error without userID")
+	}
+	if _, err := searchConversationHistory(ctx, provider, "u1", "", SearchConversationHistoryParams{Query: "部署"}); err == nil {
+		t.Fatal("session scope requires sessionID")
+	}
+
+	res, err := searchConversationHistory(ctx, provider, "u1", "s1", SearchConversationHistoryParams{Query: "部署", Role: "user", Since: "2025-03-01"})
+	if err != nil {
+		t.Fatalf("search err: %v", err)
+	}
+	q := provider.queries[0]
+	if q.UserID != "u1" || q.SessionID != "s1" || q.Role != "user" || q.Since == nil || q.Limit != 5 {
+		t.Fatalf("unexpected query: %+v", q)
+	}
+	item := res.Messages[0]
+	if res.Total != 1 || item.MessageID != "m1" || item.Time != "2025-03-20T10:00:00Z" || len([]rune(item.Snippet)) != maxHistorySnippetRunes+1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	if _, err := searchConversationHistory(ctx, provider, "u1", "s1", SearchConversationHistoryParams{Keywords: []string{"部署"}, Scope: "all", Limit: 50}); err != nil {
+		t.Fatalf("search all err: %v", err)
+	}
+	if q := provider.queries[1]; q.SessionID != "" || q.Limit != 20 {
+		t.Fatalf("scope=all should search across sessions: %+v", q)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/CoolBanHub/aggo/memory"
+	"github.com/CoolBanHub/aggo/memory/builtin"
 	"github.com/CoolBanHub/aggo/memory/memoryevent"
 	"github.com/cloudwego/eino/adk"
 	"github.com/cloudwego/eino/components/tool"
@@ -57,7 +58,7 @@ func SearchUserMemoryTool(provider memory.UserMemoryEventSearcher) (tool.BaseToo
 		"支持关键词、时间窗、事件类型过滤。"
 
 	return utils.InferTool(name, desc, func(ctx context.Context, params SearchUserMemoryParams) (interface{}, error) {
-		return searchUserMemory(ctx, provider, params)
+		return searchUserMemory(namespaceContext(ctx), provider, params)
 	})
 }
 
@@ -121,6 +122,12 @@ func sessionString(ctx context.Context, key string) string {
 	return ""
 }
 
+// namespaceContext 把 adk session 中的记忆命名空间（由 MemoryMiddleware 写入）带入 ctx，
+// 使工具读写的用户记忆与当前 Agent 的命名空间一致
+func namespaceContext(ctx context.Context) context.Context {
+	return builtin.WithNamespace(ctx, sessionString(ctx, memory.NamespaceSessionKey))
+}
+
 func clampLimit(v, def, max int) int {
 	if v <= 0 {
 		return def
@@ -75,9 +75,29 @@ func GetCronTools(service *cronPkg.CronService, opts ...cron.CronOption) []tool.
 // 入参可以是 memory.MemoryProvider 也可以直接是 UserMemoryEventSearcher；
 // 如果传入的 provider 不支持事件检索（例如 mem0/memu），返回 nil 工具与无错误，由调用方决定是否注册。
 func GetUserMemorySearchTool(provider any) (tool.BaseTool, error) {
-	searcher, ok := provider.(aggomemory.UserMemoryEventSearcher)
+	searcher, ok := aggomemory.As[aggomemory.UserMemoryEventSearcher](provider)
 	if !ok {
 		return nil, nil
 	}
 	return memorytool.SearchUserMemoryTool(searcher)
 }
+
+// GetConversationHistorySearchTool 获取检索对话原文的 search_conversation_history 工具。
+// 如果传入的 provider 未实现 SearchableProvider，返回 nil 工具与无错误，由调用方决定是否注册。
+func GetConversationHistorySearchTool(provider any) (tool.BaseTool, error) {
+	searchable, ok := aggomemory.As[aggomemory.SearchableProvider](provider)
+	if !ok {
+		return nil, nil
+	}
+	return memorytool.SearchConversationHistoryTool(searchable)
+}
+
+// GetUserMemoryEditTools 获取 remember/update/forget_user_memory 记忆编辑工具。
+// 如果传入的 provider 未实现 UserMemoryEditor（例如 mem0/memu），返回 nil 与无错误，由调用方决定是否注册。
+func GetUserMemoryEditTools(provider any) ([]tool.BaseTool, error) {
+	editor, ok := aggomemory.As[aggomemory.UserMemoryEditor](provider)
+	if !ok {
+		return nil, nil
+	}
+	return memorytool.UserMemoryEditTools(editor)
+}